@@ -0,0 +1,61 @@
+package certificates
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/services"
+)
+
+// CRLWorker refreshes CRLs before their nextUpdate and rotates delegated OCSP responders before
+// they expire
+type CRLWorker struct {
+	stop               chan struct{}
+	certificateService services.CertificateService
+	ocspService        services.OCSPService
+}
+
+func NewCRLWorker(
+	certificateService services.CertificateService,
+	ocspService services.OCSPService,
+) *CRLWorker {
+	return &CRLWorker{
+		stop:               make(chan struct{}),
+		certificateService: certificateService,
+		ocspService:        ocspService,
+	}
+}
+
+func (w *CRLWorker) Start(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	for {
+		numRefreshed, err := w.certificateService.RefreshDueCRLs(ctx)
+		if err != nil {
+			log.WithError(err).Error("Error refreshing CRLs")
+		} else if numRefreshed > 0 {
+			log.Infof("Refreshed %d CRLs", numRefreshed)
+		}
+
+		numRotated, err := w.ocspService.RotateDueResponders(ctx)
+		if err != nil {
+			log.WithError(err).Error("Error rotating OCSP responders")
+		} else if numRotated > 0 {
+			log.Infof("Rotated %d OCSP responders", numRotated)
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Hour):
+		}
+	}
+}
+
+// Stop ends Start after its current run. It must be called at most once.
+func (w *CRLWorker) Stop(ctx context.Context) {
+	close(w.stop)
+}
@@ -0,0 +1,97 @@
+package certificates
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+)
+
+const notificationLease = "notifications"
+
+// NotificationWorker schedules and sends expiry notifications. Like the RenewalWorker only the
+// replica holding the lease does any work, so nobody is notified twice.
+type NotificationWorker struct {
+	stop                chan struct{}
+	notificationService services.NotificationService
+	leaseRepository     repositories.LeaseRepository
+	holder              string
+	interval            time.Duration
+}
+
+func NewNotificationWorker(
+	notificationService services.NotificationService,
+	leaseRepository repositories.LeaseRepository,
+	holder string,
+	interval time.Duration,
+) *NotificationWorker {
+	return &NotificationWorker{
+		stop:                make(chan struct{}),
+		notificationService: notificationService,
+		leaseRepository:     leaseRepository,
+		holder:              holder,
+		interval:            interval,
+	}
+}
+
+func (w *NotificationWorker) Start(ctx context.Context) {
+	log := logger.Get(ctx)
+	firstRun := true
+
+	for {
+		if !firstRun {
+			select {
+			case <-w.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(w.interval):
+			}
+		}
+		firstRun = false
+
+		acquired, err := w.leaseRepository.AcquireLease(
+			ctx,
+			notificationLease,
+			w.holder,
+			2*w.interval,
+		)
+		if err != nil {
+			log.WithError(err).Error("Error acquiring notification lease")
+			continue
+		}
+
+		if !acquired {
+			continue
+		}
+
+		numScheduled, err := w.notificationService.ScheduleDue(ctx)
+		if err != nil {
+			log.WithError(err).Error("Error scheduling notifications")
+		} else if numScheduled > 0 {
+			log.Infof("Scheduled %d notifications", numScheduled)
+		}
+
+		numDelivered, err := w.notificationService.DeliverDue(ctx)
+		if err != nil {
+			log.WithError(err).Error("Error delivering notifications")
+			continue
+		}
+
+		if numDelivered > 0 {
+			log.Infof("Delivered %d notifications", numDelivered)
+		}
+	}
+}
+
+// Stop ends Start after its current run and releases the lease. It must be called at most once.
+func (w *NotificationWorker) Stop(ctx context.Context) {
+	close(w.stop)
+
+	err := w.leaseRepository.ReleaseLease(ctx, notificationLease, w.holder)
+	if err != nil {
+		logger.Get(ctx).WithError(err).Error("Error releasing notification lease")
+	}
+}
@@ -0,0 +1,87 @@
+package certificates
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+)
+
+const renewalLease = "auto-renew"
+
+// RenewalWorker renews certificates with a due auto-renew policy. Only the replica holding the
+// renewal lease does any work, and each policy is claimed before it is renewed, so a certificate
+// is never renewed twice even when a run outlives the lease.
+type RenewalWorker struct {
+	stop             chan struct{}
+	autoRenewService services.AutoRenewService
+	leaseRepository  repositories.LeaseRepository
+	holder           string
+	interval         time.Duration
+}
+
+func NewRenewalWorker(
+	autoRenewService services.AutoRenewService,
+	leaseRepository repositories.LeaseRepository,
+	holder string,
+	interval time.Duration,
+) *RenewalWorker {
+	return &RenewalWorker{
+		stop:             make(chan struct{}),
+		autoRenewService: autoRenewService,
+		leaseRepository:  leaseRepository,
+		holder:           holder,
+		interval:         interval,
+	}
+}
+
+func (w *RenewalWorker) Start(ctx context.Context) {
+	log := logger.Get(ctx)
+	firstRun := true
+
+	for {
+		if !firstRun {
+			select {
+			case <-w.stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-time.After(w.interval):
+			}
+		}
+		firstRun = false
+
+		// The lease outlives one interval so the holder keeps it between runs
+		acquired, err := w.leaseRepository.AcquireLease(ctx, renewalLease, w.holder, 2*w.interval)
+		if err != nil {
+			log.WithError(err).Error("Error acquiring auto-renew lease")
+			continue
+		}
+
+		if !acquired {
+			continue
+		}
+
+		numRenewed, err := w.autoRenewService.RenewDue(ctx)
+		if err != nil {
+			log.WithError(err).Error("Error renewing certificates")
+			continue
+		}
+
+		if numRenewed > 0 {
+			log.Infof("Renewed %d certificates", numRenewed)
+		}
+	}
+}
+
+// Stop ends Start after its current run and releases the lease. It must be called at most once.
+func (w *RenewalWorker) Stop(ctx context.Context) {
+	close(w.stop)
+
+	err := w.leaseRepository.ReleaseLease(ctx, renewalLease, w.holder)
+	if err != nil {
+		logger.Get(ctx).WithError(err).Error("Error releasing auto-renew lease")
+	}
+}
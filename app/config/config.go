@@ -1,24 +1,66 @@
 package config
 
 import (
+	"time"
+
 	"github.com/caarlos0/env/v7"
 )
 
 const (
-	DB_TYPE_MYSQL = "mysql"
-	DB_TYPE_NEO4J = "neo4j"
+	DB_TYPE_MYSQL    = "mysql"
+	DB_TYPE_NEO4J    = "neo4j"
+	DB_TYPE_POSTGRES = "postgres"
+	DB_TYPE_SQLITE   = "sqlite"
+	// DB_TYPE_MEMORY keeps everything in process and loses it on restart
+	DB_TYPE_MEMORY = "memory"
 )
 
 type Config struct {
 	Database
+	Server
+	SMTP
 }
 
 type Database struct {
-	Type     string `env:"DB_TYPE"`
+	// Type selects the backend, MySQL when empty
+	Type string `env:"DB_TYPE"`
+	// Name is the database name, or the database file path for SQLite
 	Name     string `env:"DB_NAME"`
 	Username string `env:"DB_USERNAME"`
 	Password string `env:"DB_PASSWORD"`
 	Hostname string `env:"DB_HOSTNAME"`
+	// Port defaults to the backend's standard port when zero
+	Port int `env:"DB_PORT"`
+	// SSLMode is the PostgreSQL sslmode
+	SSLMode string `env:"DB_SSL_MODE" envDefault:"prefer"`
+	// AutoMigrate applies pending schema migrations at startup
+	AutoMigrate bool `env:"DB_AUTO_MIGRATE" envDefault:"true"`
+}
+
+type Server struct {
+	// PublicURL is the externally reachable base URL of the API, used to advertise CRL and OCSP
+	// endpoints in issued certificates. Nothing is advertised when empty.
+	PublicURL string `env:"PUBLIC_URL"`
+	// SecretKey seals secrets the server needs to act unattended, such as CA key passwords
+	// for ACME issuance and scheduled CRL refreshes, and keys the audit log hash chain
+	SecretKey string `env:"SECRET_KEY"`
+	// AutoRenewInterval is how often due auto-renew policies are checked
+	AutoRenewInterval time.Duration `env:"AUTO_RENEW_INTERVAL" envDefault:"10m"`
+	// NotificationInterval is how often expiry notifications are scheduled and sent
+	NotificationInterval time.Duration `env:"NOTIFICATION_INTERVAL" envDefault:"5m"`
+	// WebhookAllowedNetworks are comma separated CIDR ranges notification webhooks may reach
+	// even though they are loopback, link-local or private
+	WebhookAllowedNetworks []string `env:"WEBHOOK_ALLOWED_NETWORKS"`
+}
+
+// SMTP configures the email notification channel, which is unavailable when Host is empty
+type SMTP struct {
+	Host string `env:"SMTP_HOST"`
+	Port int    `env:"SMTP_PORT" envDefault:"587"`
+	// Username enables PLAIN authentication when set
+	Username string `env:"SMTP_USERNAME"`
+	Password string `env:"SMTP_PASSWORD"`
+	From     string `env:"SMTP_FROM"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -0,0 +1,45 @@
+package request
+
+import (
+	"context"
+	"net"
+	"net/http"
+)
+
+type key int
+
+const (
+	contextKey key = iota
+)
+
+// Source describes where a request came from. It is empty for work the server does by itself,
+// such as background renewals.
+type Source struct {
+	IP        string
+	UserAgent string
+}
+
+func Get(ctx context.Context) Source {
+	source, _ := ctx.Value(contextKey).(Source)
+
+	return source
+}
+
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, contextKey, source)
+}
+
+// Middleware records the Source of every request on its context
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			ctx := WithSource(r.Context(), Source{IP: ip, UserAgent: r.UserAgent()})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		},
+	)
+}
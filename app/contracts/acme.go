@@ -0,0 +1,89 @@
+package contracts
+
+import "time"
+
+// ACME protocol objects as defined in RFC 8555 section 7.1
+
+type AcmeDirectory struct {
+	NewNonce   string             `json:"newNonce"`
+	NewAccount string             `json:"newAccount"`
+	NewOrder   string             `json:"newOrder"`
+	RevokeCert string             `json:"revokeCert"`
+	Meta       *AcmeDirectoryMeta `json:"meta,omitempty"`
+}
+
+type AcmeDirectoryMeta struct {
+	ExternalAccountRequired bool `json:"externalAccountRequired"`
+}
+
+type AcmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type AcmeAccountRequest struct {
+	Contact              []string `json:"contact"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+	OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	Status               string   `json:"status"`
+}
+
+type AcmeAccount struct {
+	Status  string   `json:"status"`
+	Contact []string `json:"contact,omitempty"`
+	Orders  string   `json:"orders"`
+}
+
+type AcmeNewOrderRequest struct {
+	Identifiers []AcmeIdentifier `json:"identifiers"`
+	NotBefore   *time.Time       `json:"notBefore"`
+	NotAfter    *time.Time       `json:"notAfter"`
+}
+
+type AcmeOrder struct {
+	Status         string           `json:"status"`
+	Expires        time.Time        `json:"expires"`
+	Identifiers    []AcmeIdentifier `json:"identifiers"`
+	NotBefore      *time.Time       `json:"notBefore,omitempty"`
+	NotAfter       *time.Time       `json:"notAfter,omitempty"`
+	Error          *AcmeProblem     `json:"error,omitempty"`
+	Authorizations []string         `json:"authorizations"`
+	Finalize       string           `json:"finalize"`
+	Certificate    string           `json:"certificate,omitempty"`
+}
+
+type AcmeOrderList struct {
+	Orders []string `json:"orders"`
+}
+
+type AcmeAuthorization struct {
+	Identifier AcmeIdentifier  `json:"identifier"`
+	Status     string          `json:"status"`
+	Expires    time.Time       `json:"expires"`
+	Challenges []AcmeChallenge `json:"challenges"`
+	Wildcard   bool            `json:"wildcard,omitempty"`
+}
+
+type AcmeChallenge struct {
+	Type      string       `json:"type"`
+	URL       string       `json:"url"`
+	Status    string       `json:"status"`
+	Token     string       `json:"token"`
+	Validated *time.Time   `json:"validated,omitempty"`
+	Error     *AcmeProblem `json:"error,omitempty"`
+}
+
+type AcmeFinalizeRequest struct {
+	CSR string `json:"csr"`
+}
+
+type AcmeRevokeCertRequest struct {
+	Certificate string `json:"certificate"`
+	Reason      int    `json:"reason"`
+}
+
+type AcmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status,omitempty"`
+}
@@ -0,0 +1,34 @@
+package contracts
+
+import "time"
+
+type AuditEntryResponse struct {
+	Sequence   int64     `json:"sequence"`
+	ActorID    string    `json:"actorId,omitempty"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"targetType,omitempty"`
+	TargetID   string    `json:"targetId,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	SourceIP   string    `json:"sourceIp,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	PrevHash   string    `json:"prevHash"`
+	Hash       string    `json:"hash"`
+}
+
+// AuditLogResponse is one page of entries, newest first. NextBefore is passed as before to get
+// the following page and is zero on the last one.
+type AuditLogResponse struct {
+	Entries    []*AuditEntryResponse `json:"entries"`
+	NextBefore int64                 `json:"nextBefore,omitempty"`
+}
+
+// AuditVerificationResponse reports whether the hash chain is intact. FirstInvalidSequence is
+// the first entry that doesn't match its hash or doesn't follow its predecessor.
+type AuditVerificationResponse struct {
+	Valid                bool   `json:"valid"`
+	Entries              int64  `json:"entries"`
+	FirstInvalidSequence int64  `json:"firstInvalidSequence,omitempty"`
+	Error                string `json:"error,omitempty"`
+}
@@ -0,0 +1,28 @@
+package contracts
+
+import "time"
+
+// EnableAutoRenewRequest flags a certificate for unattended renewal once its remaining lifetime
+// drops below ThresholdPercent of its validity or ThresholdDays, whichever comes first. The
+// passwords are those RenewCertificateRequest would take and are stored sealed.
+type EnableAutoRenewRequest struct {
+	ThresholdPercent  int    `json:"thresholdPercent"`
+	ThresholdDays     int    `json:"thresholdDays"`
+	CAKeyPassword     string `json:"caKeyPassword"`
+	KeyPassword       string `json:"keyPassword"`
+	RevokePredecessor bool   `json:"revokePredecessor"`
+}
+
+type AutoRenewResponse struct {
+	CertificateID        string     `json:"certificateId"`
+	ThresholdPercent     int        `json:"thresholdPercent"`
+	ThresholdDays        int        `json:"thresholdDays"`
+	RevokePredecessor    bool       `json:"revokePredecessor"`
+	RenewAt              time.Time  `json:"renewAt"`
+	NextAttempt          time.Time  `json:"nextAttempt"`
+	Status               string     `json:"status"`
+	Failures             int        `json:"failures"`
+	LastAttempt          *time.Time `json:"lastAttempt,omitempty"`
+	LastError            string     `json:"lastError,omitempty"`
+	RenewedCertificateID string     `json:"renewedCertificateId,omitempty"`
+}
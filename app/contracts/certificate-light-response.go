@@ -3,8 +3,11 @@ package contracts
 import "time"
 
 type CertificateLightResponse struct {
-	ID      string    `json:"id"`
-	Name    string    `json:"name"`
-	Type    string    `json:"type"`
-	Created time.Time `json:"created"`
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Created  time.Time `json:"created"`
+	Replaces string    `json:"replaces,omitempty"`
+	// RefreshError is set on CAs whose CRL can no longer be refreshed on schedule
+	RefreshError string `json:"refreshError,omitempty"`
 }
@@ -0,0 +1,35 @@
+package contracts
+
+import "time"
+
+// CertificateProfileRequest describes a reusable issuance policy. KeyUsages and ExtKeyUsages use
+// the same names as CreateCertificateRequest.KeyUsages, AllowedKeyAlgorithms the KeyAlgorithm
+// names. A MaxValidityDays or AllowedKeyAlgorithms left empty is unrestricted, as is a CA path
+// length when MaxPathLen is -1.
+type CertificateProfileRequest struct {
+	Name                 string                    `json:"name"`
+	KeyUsages            []string                  `json:"keyUsages"`
+	ExtKeyUsages         []string                  `json:"extKeyUsages"`
+	MaxValidityDays      int                       `json:"maxValidityDays"`
+	AllowedKeyAlgorithms []string                  `json:"allowedKeyAlgorithms"`
+	IsCA                 bool                      `json:"isCA"`
+	MaxPathLen           int                       `json:"maxPathLen"`
+	Subject              CertificateProfileSubject `json:"subject"`
+}
+
+// CertificateProfileSubject holds subject fields used when the request leaves them empty
+type CertificateProfileSubject struct {
+	Organization       string `json:"organization"`
+	OrganizationalUnit string `json:"organizationalUnit"`
+	Country            string `json:"country"`
+	Province           string `json:"province"`
+	Locality           string `json:"locality"`
+	StreetAddress      string `json:"streetAddress"`
+	PostalCode         string `json:"postalCode"`
+}
+
+type CertificateProfileResponse struct {
+	ID string `json:"id"`
+	CertificateProfileRequest
+	Created time.Time `json:"created"`
+}
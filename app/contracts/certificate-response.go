@@ -6,26 +6,33 @@ import (
 )
 
 type CertificateResponse struct {
-	ID                 string    `json:"id"`
-	OwnerID            string    `json:"ownerId"`
-	Name               string    `json:"name"`
-	Type               string    `json:"type"`
-	Created            time.Time `json:"created"`
-	KeyID              string    `json:"keyId"`
-	SignatureAlgorithm string    `json:"signatureAlgorithm"`
-	PublicKeyAlgorithm string    `json:"publicKeyAlgorithm"`
-	Version            int       `json:"version"`
-	SerialNumber       int       `json:"serialNumber"`
-	Issuer             *PkixName `json:"issuer"`
-	Subject            *PkixName `json:"subject"`
-	NotBefore          time.Time `json:"notBefore"`
-	NotAfter           time.Time `json:"notAfter"`
-	KeyUsage           []string  `json:"keyUsage"`
-	ExtKeyUsage        []string  `json:"extKeyUsage"`
-	IsCA               bool      `json:"isCA"`
-	MaxPathLen         int       `json:"maxPathLen"`
-	MaxPathLenZero     bool      `json:"maxPathLenZero"`
-	DNSNames           []string  `json:"sanDNSNames"`
+	ID                 string     `json:"id"`
+	OwnerID            string     `json:"ownerId"`
+	Name               string     `json:"name"`
+	Type               string     `json:"type"`
+	Created            time.Time  `json:"created"`
+	KeyID              string     `json:"keyId,omitempty"`
+	SignatureAlgorithm string     `json:"signatureAlgorithm"`
+	PublicKeyAlgorithm string     `json:"publicKeyAlgorithm"`
+	Version            int        `json:"version"`
+	SerialNumber       string     `json:"serialNumber"`
+	Issuer             *PkixName  `json:"issuer"`
+	Subject            *PkixName  `json:"subject"`
+	NotBefore          time.Time  `json:"notBefore"`
+	NotAfter           time.Time  `json:"notAfter"`
+	KeyUsage           []string   `json:"keyUsage"`
+	ExtKeyUsage        []string   `json:"extKeyUsage"`
+	IsCA               bool       `json:"isCA"`
+	MaxPathLen         int        `json:"maxPathLen"`
+	MaxPathLenZero     bool       `json:"maxPathLenZero"`
+	DNSNames           []string   `json:"sanDNSNames"`
+	RevokedAt          *time.Time `json:"revokedAt,omitempty"`
+	RevocationReason   string     `json:"revocationReason,omitempty"`
+	Replaces           string     `json:"replaces,omitempty"`
+	// RefreshError is set on CAs whose CRL can no longer be refreshed on schedule
+	RefreshError string `json:"refreshError,omitempty"`
+	// History lists every certificate in the renewal lineage, this one included, oldest first
+	History []*CertificateLightResponse `json:"history"`
 }
 
 type PkixName struct {
@@ -15,4 +15,5 @@ type CreateCARequest struct {
 	ParentKeyPassword string    `json:"parentKeyPassword"`
 	KeyID             string    `json:"key"`
 	KeyPassword       string    `json:"keyPassword"`
+	ProfileID         string    `json:"profileId"`
 }
@@ -11,4 +11,5 @@ type CreateCertificateRequest struct {
 	SubjectAlternativeNames []string  `json:"subjectAlternativeNames"`
 	Expiration              time.Time `json:"expiration"`
 	CAKeyPassword           string    `json:"caKeyPassword"`
+	ProfileID               string    `json:"profileId"`
 }
@@ -4,4 +4,5 @@ type CreateKeyRequest struct {
 	Algorithm KeyAlgorithm `json:"algorithm"`
 	Name      string       `json:"name"`
 	Password  string       `json:"password"`
+	KeyParameters
 }
@@ -0,0 +1,10 @@
+package contracts
+
+type EnableAcmeRequest struct {
+	CAKeyPassword    string `json:"caKeyPassword"`
+	CertValidityDays int    `json:"certValidityDays"`
+}
+
+type AcmeDirectoryLink struct {
+	Directory string `json:"directory"`
+}
@@ -0,0 +1,13 @@
+package contracts
+
+// ImportCertificatesRequest carries PEM certificates, or base64 encoded DER certificates or a
+// PKCS#12 bundle. SourcePassword decrypts a PKCS#12 bundle, whose key is stored as KeyName under
+// KeyPassword. KeyID links an already imported key instead, KeyPassword then being its password.
+type ImportCertificatesRequest struct {
+	Name           string `json:"name"`
+	Data           string `json:"data"`
+	SourcePassword string `json:"sourcePassword"`
+	KeyID          string `json:"keyId"`
+	KeyName        string `json:"keyName"`
+	KeyPassword    string `json:"keyPassword"`
+}
@@ -0,0 +1,9 @@
+package contracts
+
+// ImportCertificatesResponse lists the certificates in issuer first order. KeyCertificateID is the
+// certificate the imported or referenced key was linked to.
+type ImportCertificatesResponse struct {
+	Certificates     []*CertificateLightResponse `json:"certificates"`
+	Key              *KeyLightResponse           `json:"key,omitempty"`
+	KeyCertificateID string                      `json:"keyCertificateId,omitempty"`
+}
@@ -0,0 +1,10 @@
+package contracts
+
+// ImportKeyRequest carries a PEM encoded private key. SourcePassword decrypts Data and the key is
+// stored encrypted under Password.
+type ImportKeyRequest struct {
+	Name           string `json:"name"`
+	Data           string `json:"data"`
+	SourcePassword string `json:"sourcePassword"`
+	Password       string `json:"password"`
+}
@@ -0,0 +1,5 @@
+package contracts
+
+type IssueOCSPResponderRequest struct {
+	CAKeyPassword string `json:"caKeyPassword"`
+}
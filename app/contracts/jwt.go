@@ -0,0 +1,63 @@
+package contracts
+
+import "time"
+
+// CreateJWKSetRequest starts an empty key set. OverlapSeconds is how long a replaced key stays
+// published, a day when left at zero.
+type CreateJWKSetRequest struct {
+	Name           string `json:"name"`
+	OverlapSeconds int    `json:"overlapSeconds"`
+}
+
+type JWKSetResponse struct {
+	ID             string               `json:"id"`
+	Name           string               `json:"name"`
+	OverlapSeconds int                  `json:"overlapSeconds"`
+	Keys           []*JWKSetKeyResponse `json:"keys"`
+	Created        time.Time            `json:"created"`
+}
+
+type JWKSetKeyResponse struct {
+	Kid       string     `json:"kid"`
+	KeyID     string     `json:"keyId"`
+	Algorithm string     `json:"algorithm"`
+	Active    bool       `json:"active"`
+	RetiresAt *time.Time `json:"retiresAt,omitempty"`
+	Created   time.Time  `json:"created"`
+}
+
+// RotateJWKSetKeyRequest makes one of the user's keys the set's signing key. Algorithm follows
+// the key type, RSA keys sign with RS256 unless PS256 is asked for.
+type RotateJWKSetKeyRequest struct {
+	KeyID       string `json:"keyId"`
+	KeyPassword string `json:"keyPassword"`
+	Algorithm   string `json:"algorithm,omitempty"`
+}
+
+// SignJWTRequest signs Claims with the set's signing key. iat is added when missing, and exp
+// when ExpiresInSeconds is set.
+type SignJWTRequest struct {
+	Claims           map[string]interface{} `json:"claims"`
+	KeyPassword      string                 `json:"keyPassword"`
+	ExpiresInSeconds int                    `json:"expiresInSeconds,omitempty"`
+}
+
+type SignJWTResponse struct {
+	Token     string `json:"token"`
+	Kid       string `json:"kid"`
+	Algorithm string `json:"algorithm"`
+}
+
+type VerifyJWTRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyJWTResponse is valid when the token was signed by a key the set publishes and its exp
+// and nbf claims hold, Error describes why it isn't otherwise
+type VerifyJWTResponse struct {
+	Valid     bool                   `json:"valid"`
+	Kid       string                 `json:"kid,omitempty"`
+	Algorithm string                 `json:"algorithm,omitempty"`
+	Claims    map[string]interface{} `json:"claims,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
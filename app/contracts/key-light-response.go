@@ -7,4 +7,6 @@ type KeyLightResponse struct {
 	Name      string    `json:"name"`
 	Created   time.Time `json:"created"`
 	Algorithm string    `json:"algorithm"`
+	KeySize   int       `json:"keySize,omitempty"`
+	Curve     string    `json:"curve,omitempty"`
 }
@@ -0,0 +1,10 @@
+package contracts
+
+type KeyTypeResponse struct {
+	ID             KeyAlgorithm `json:"id"`
+	Name           string       `json:"name"`
+	KeySizes       []int        `json:"keySizes,omitempty"`
+	Curves         []KeyCurve   `json:"curves,omitempty"`
+	DefaultKeySize int          `json:"defaultKeySize,omitempty"`
+	DefaultCurve   KeyCurve     `json:"defaultCurve,omitempty"`
+}
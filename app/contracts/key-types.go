@@ -33,3 +33,46 @@ func AlgorithmFromString(algorithm string) (KeyAlgorithm, error) {
 		return Unknown, errors.New("unknown algorithm")
 	}
 }
+
+type KeyCurve string
+
+const (
+	P256 KeyCurve = "P-256"
+	P384 KeyCurve = "P-384"
+	P521 KeyCurve = "P-521"
+)
+
+// Defaults keep keys created without parameters identical to those made before they existed
+const (
+	DefaultRSAKeySize = 4096
+	DefaultECDSACurve = P521
+)
+
+var RSAKeySizes = []int{2048, 3072, 4096}
+var ECDSACurves = []KeyCurve{P256, P384, P521}
+
+// KeyParameters selects the key size for RSA or the curve for ECDSA, ED25519 takes neither
+type KeyParameters struct {
+	KeySize int      `json:"keySize,omitempty"`
+	Curve   KeyCurve `json:"curve,omitempty"`
+}
+
+// KeyTypes lists every supported algorithm with its parameter sets
+var KeyTypes = []*KeyTypeResponse{
+	{
+		ID:           ECDSA,
+		Name:         ECDSA.String(),
+		Curves:       ECDSACurves,
+		DefaultCurve: DefaultECDSACurve,
+	},
+	{
+		ID:   ED25519,
+		Name: ED25519.String(),
+	},
+	{
+		ID:             RSA,
+		Name:           RSA.String(),
+		KeySizes:       RSAKeySizes,
+		DefaultKeySize: DefaultRSAKeySize,
+	},
+}
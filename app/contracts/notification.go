@@ -0,0 +1,56 @@
+package contracts
+
+import "time"
+
+const (
+	NotificationChannelWebhook = "webhook"
+	NotificationChannelEmail   = "email"
+)
+
+// NotificationRuleRequest asks to be told DaysBefore days ahead of certificates expiring, for
+// instance [30, 14, 7, 1]. CAID limits the rule to certificates issued by one CA. Target is the
+// webhook URL or the email address, Secret is the HMAC key webhook payloads are signed with.
+type NotificationRuleRequest struct {
+	Name       string `json:"name"`
+	CAID       string `json:"caId"`
+	DaysBefore []int  `json:"daysBefore"`
+	Channel    string `json:"channel"`
+	Target     string `json:"target"`
+	Secret     string `json:"secret"`
+}
+
+type NotificationRuleResponse struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	CAID       string    `json:"caId,omitempty"`
+	DaysBefore []int     `json:"daysBefore"`
+	Channel    string    `json:"channel"`
+	Target     string    `json:"target"`
+	Created    time.Time `json:"created"`
+}
+
+type NotificationDeliveryResponse struct {
+	ID            string     `json:"id"`
+	CertificateID string     `json:"certificateId"`
+	DaysBefore    int        `json:"daysBefore"`
+	NotAfter      time.Time  `json:"notAfter"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttempt   time.Time  `json:"nextAttempt"`
+	LastAttempt   *time.Time `json:"lastAttempt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	Created       time.Time  `json:"created"`
+}
+
+// ExpiryNotification is the body of webhook deliveries and the content of notification emails
+type ExpiryNotification struct {
+	Type          string    `json:"type"`
+	DeliveryID    string    `json:"deliveryId"`
+	RuleID        string    `json:"ruleId"`
+	CertificateID string    `json:"certificateId"`
+	Name          string    `json:"name"`
+	CommonName    string    `json:"commonName"`
+	SerialNumber  string    `json:"serialNumber"`
+	NotAfter      time.Time `json:"notAfter"`
+	DaysBefore    int       `json:"daysBefore"`
+}
@@ -0,0 +1,25 @@
+package contracts
+
+import "time"
+
+// RenewCertificateRequest issues a successor with the predecessor's key, subject, SANs and
+// usages. Name and Expiration default to the predecessor's name and validity period. CAKeyPassword
+// unlocks the issuing CA's key, KeyPassword the certificate's own key, which signs self-signed
+// roots and sets up the CRL and OCSP responder of a renewed CA.
+type RenewCertificateRequest struct {
+	Name              string    `json:"name"`
+	Expiration        time.Time `json:"expiration"`
+	CAKeyPassword     string    `json:"caKeyPassword"`
+	KeyPassword       string    `json:"keyPassword"`
+	RevokePredecessor bool      `json:"revokePredecessor"`
+}
+
+// RekeyCertificateRequest renews a certificate onto another key. KeyID selects an existing key
+// unlocked by KeyPassword, without one a key is generated and stored under KeyPassword. Generated
+// keys use the predecessor's algorithm and parameters unless Algorithm is set.
+type RekeyCertificateRequest struct {
+	RenewCertificateRequest
+	KeyID     string        `json:"keyId"`
+	Algorithm *KeyAlgorithm `json:"algorithm"`
+	KeyParameters
+}
@@ -0,0 +1,50 @@
+package contracts
+
+import "errors"
+
+// RevocationReason is a CRLReason code as defined in RFC 5280 section 5.3.1
+type RevocationReason int
+
+const (
+	ReasonUnspecified          RevocationReason = 0
+	ReasonKeyCompromise        RevocationReason = 1
+	ReasonCACompromise         RevocationReason = 2
+	ReasonAffiliationChanged   RevocationReason = 3
+	ReasonSuperseded           RevocationReason = 4
+	ReasonCessationOfOperation RevocationReason = 5
+	ReasonCertificateHold      RevocationReason = 6
+	ReasonRemoveFromCRL        RevocationReason = 8
+	ReasonPrivilegeWithdrawn   RevocationReason = 9
+	ReasonAACompromise         RevocationReason = 10
+)
+
+var RevocationReasonStrings = map[RevocationReason]string{
+	ReasonUnspecified:          "unspecified",
+	ReasonKeyCompromise:        "keyCompromise",
+	ReasonCACompromise:         "cACompromise",
+	ReasonAffiliationChanged:   "affiliationChanged",
+	ReasonSuperseded:           "superseded",
+	ReasonCessationOfOperation: "cessationOfOperation",
+	ReasonCertificateHold:      "certificateHold",
+	ReasonRemoveFromCRL:        "removeFromCRL",
+	ReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+	ReasonAACompromise:         "aACompromise",
+}
+
+func (r RevocationReason) String() string {
+	return RevocationReasonStrings[r]
+}
+
+func RevocationReasonFromString(reason string) (RevocationReason, error) {
+	if reason == "" {
+		return ReasonUnspecified, nil
+	}
+
+	for code, str := range RevocationReasonStrings {
+		if str == reason {
+			return code, nil
+		}
+	}
+
+	return ReasonUnspecified, errors.New("unknown revocation reason")
+}
@@ -0,0 +1,6 @@
+package contracts
+
+type RevokeCertificateRequest struct {
+	Reason        string `json:"reason"`
+	CAKeyPassword string `json:"caKeyPassword"`
+}
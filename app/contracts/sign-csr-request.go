@@ -0,0 +1,18 @@
+package contracts
+
+import "time"
+
+// SignCSRRequest signs a PKCS#10 request generated outside the platform. CSR holds either a PEM
+// block or base64 encoded DER. KeyUsages, CommonName and SubjectAlternativeNames override the
+// values requested in the CSR when set. Each alternative name is taken as an IP address, email
+// address, URI or DNS name by its form.
+type SignCSRRequest struct {
+	Name                    string    `json:"name"`
+	CSR                     string    `json:"csr"`
+	KeyUsages               []string  `json:"keyUsages"`
+	CommonName              string    `json:"commonName"`
+	SubjectAlternativeNames []string  `json:"subjectAlternativeNames"`
+	Expiration              time.Time `json:"expiration"`
+	CAKeyPassword           string    `json:"caKeyPassword"`
+	ProfileID               string    `json:"profileId"`
+}
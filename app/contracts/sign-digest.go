@@ -0,0 +1,20 @@
+package contracts
+
+// SignDigestRequest signs a digest the caller computed, base64 encoded, without the key leaving
+// the platform. Hash names the algorithm the digest was computed with: sha256, sha384 or sha512.
+// Ed25519 keys sign Digest as the message itself when Hash is empty, and as Ed25519ph when it is
+// sha512. Padding is pkcs1v15, the default, or pss and only applies to RSA keys. SaltLength sets
+// the PSS salt, which is as long as the hash when left at zero.
+type SignDigestRequest struct {
+	KeyPassword string `json:"keyPassword"`
+	Digest      string `json:"digest"`
+	Hash        string `json:"hash,omitempty"`
+	Padding     string `json:"padding,omitempty"`
+	SaltLength  int    `json:"saltLength,omitempty"`
+}
+
+// SignDigestResponse carries the base64 encoded signature. ECDSA signatures are ASN.1 DER, the
+// form crypto.Signer returns.
+type SignDigestResponse struct {
+	Signature string `json:"signature"`
+}
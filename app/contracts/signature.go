@@ -0,0 +1,52 @@
+package contracts
+
+import "time"
+
+// SignDataRequest asks for a detached CMS signature made with a certificate the user owns and
+// its platform managed key. Either Data or Digest is given, both base64 encoded. Digest is the
+// hash of the content under DigestAlgorithm, one of sha256 (the default), sha384 or sha512.
+// IncludeChain embeds the issuing CA certificates and IncludeRevocationInfo their latest CRLs.
+type SignDataRequest struct {
+	CertificateID         string `json:"certificateId"`
+	KeyPassword           string `json:"keyPassword"`
+	Data                  string `json:"data,omitempty"`
+	Digest                string `json:"digest,omitempty"`
+	DigestAlgorithm       string `json:"digestAlgorithm"`
+	IncludeChain          bool   `json:"includeChain"`
+	IncludeRevocationInfo bool   `json:"includeRevocationInfo"`
+}
+
+// SignDataResponse carries the DER encoded CMS SignedData, base64 encoded
+type SignDataResponse struct {
+	Signature       string    `json:"signature"`
+	CertificateID   string    `json:"certificateId"`
+	DigestAlgorithm string    `json:"digestAlgorithm"`
+	SigningTime     time.Time `json:"signingTime"`
+}
+
+// VerifySignatureRequest checks a detached CMS signature over Data, or over a Digest computed
+// with DigestAlgorithm, against the platform CA TrustAnchorID. Like signing, binary fields are
+// base64 encoded. Data can be left out when the signature encapsulates its content.
+type VerifySignatureRequest struct {
+	Signature       string `json:"signature"`
+	Data            string `json:"data,omitempty"`
+	Digest          string `json:"digest,omitempty"`
+	DigestAlgorithm string `json:"digestAlgorithm"`
+	TrustAnchorID   string `json:"trustAnchorId"`
+}
+
+// VerifySignatureResponse is valid only when every signer verified, Error describes the first
+// failure otherwise
+type VerifySignatureResponse struct {
+	Valid   bool              `json:"valid"`
+	Signers []*SignerResponse `json:"signers"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type SignerResponse struct {
+	Subject      string    `json:"subject"`
+	SerialNumber string    `json:"serialNumber"`
+	SigningTime  time.Time `json:"signingTime"`
+	Valid        bool      `json:"valid"`
+	Error        string    `json:"error,omitempty"`
+}
@@ -0,0 +1,58 @@
+package contracts
+
+import "time"
+
+const (
+	SSHCertTypeUser = "user"
+	SSHCertTypeHost = "host"
+)
+
+// CreateSSHCARequest turns one of the user's keys into an SSH CA, KeyPassword unlocks it once to
+// check it can sign
+type CreateSSHCARequest struct {
+	Name        string `json:"name"`
+	KeyID       string `json:"keyId"`
+	KeyPassword string `json:"keyPassword"`
+}
+
+// SSHCAResponse carries the CA public key in authorized_keys format
+type SSHCAResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	KeyID     string    `json:"keyId"`
+	PublicKey string    `json:"publicKey"`
+	Created   time.Time `json:"created"`
+}
+
+// SignSSHCertificateRequest certifies PublicKey, given in authorized_keys format. KeyID is the
+// certificate's key ID, logged by sshd when the certificate is used. NotBefore defaults to now.
+// ForceCommand and SourceAddresses, a list of addresses and CIDR ranges, become critical options
+// of user certificates. Extensions default to the permit-* set ssh-keygen grants user
+// certificates, an empty object grants none.
+type SignSSHCertificateRequest struct {
+	CertType        string            `json:"certType"`
+	PublicKey       string            `json:"publicKey"`
+	KeyID           string            `json:"keyId"`
+	Principals      []string          `json:"principals"`
+	NotBefore       time.Time         `json:"notBefore"`
+	Expiration      time.Time         `json:"expiration"`
+	ForceCommand    string            `json:"forceCommand"`
+	SourceAddresses []string          `json:"sourceAddresses"`
+	Extensions      map[string]string `json:"extensions"`
+	CAKeyPassword   string            `json:"caKeyPassword"`
+}
+
+// SSHCertificateResponse carries the certificate in the format ssh expects in *-cert.pub files
+type SSHCertificateResponse struct {
+	ID          string     `json:"id"`
+	CAID        string     `json:"caId"`
+	Serial      uint64     `json:"serial"`
+	CertType    string     `json:"certType"`
+	KeyID       string     `json:"keyId"`
+	Principals  []string   `json:"principals"`
+	ValidAfter  time.Time  `json:"validAfter"`
+	ValidBefore time.Time  `json:"validBefore"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	Certificate string     `json:"certificate"`
+	Created     time.Time  `json:"created"`
+}
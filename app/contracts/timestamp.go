@@ -0,0 +1,61 @@
+package contracts
+
+import "time"
+
+// CreateTimestampAuthorityRequest turns a certificate the user owns into an RFC 3161 TSA. The
+// certificate's only extended key usage has to be a critical timeStamping. KeyPassword is sealed
+// with the server secret so time stamps can be issued unattended. Policy is the TSA policy OID
+// put in tokens, AcceptedPolicies are other OIDs requesters may ask for. AccuracyMillis is how far
+// off the token time may be, left out of tokens when zero. RequireNonce rejects requests without
+// a nonce.
+type CreateTimestampAuthorityRequest struct {
+	Name             string   `json:"name"`
+	CertificateID    string   `json:"certificateId"`
+	KeyPassword      string   `json:"keyPassword"`
+	Policy           string   `json:"policy"`
+	AcceptedPolicies []string `json:"acceptedPolicies"`
+	AccuracyMillis   int      `json:"accuracyMillis"`
+	RequireNonce     bool     `json:"requireNonce"`
+}
+
+type TimestampAuthorityResponse struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	CertificateID    string    `json:"certificateId"`
+	Policy           string    `json:"policy"`
+	AcceptedPolicies []string  `json:"acceptedPolicies"`
+	AccuracyMillis   int       `json:"accuracyMillis"`
+	RequireNonce     bool      `json:"requireNonce"`
+	Created          time.Time `json:"created"`
+}
+
+// TimestampTokenResponse describes an issued token, MessageImprint is hex encoded
+type TimestampTokenResponse struct {
+	ID             string    `json:"id"`
+	SerialNumber   string    `json:"serialNumber"`
+	GenTime        time.Time `json:"genTime"`
+	Policy         string    `json:"policy"`
+	HashAlgorithm  string    `json:"hashAlgorithm"`
+	MessageImprint string    `json:"messageImprint"`
+	Nonce          string    `json:"nonce,omitempty"`
+}
+
+// VerifyTimestampRequest checks a token against the data it stamps, or the digest of that data
+// under the token's hash algorithm. Token is either a TimeStampResp or the bare token, and like
+// Data and Digest is base64 encoded.
+type VerifyTimestampRequest struct {
+	Token  string `json:"token"`
+	Data   string `json:"data,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// VerifyTimestampResponse is valid when the token was issued by the TSA over the given data,
+// Error describes why it isn't otherwise
+type VerifyTimestampResponse struct {
+	Valid        bool      `json:"valid"`
+	SerialNumber string    `json:"serialNumber,omitempty"`
+	GenTime      time.Time `json:"genTime,omitempty"`
+	Policy       string    `json:"policy,omitempty"`
+	Nonce        string    `json:"nonce,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
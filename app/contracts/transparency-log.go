@@ -0,0 +1,46 @@
+package contracts
+
+// Hashes, signatures and leaf inputs are base64 encoded, and timestamps are milliseconds since
+// the epoch, as in RFC 6962.
+
+type TransparencyLogResponse struct {
+	CAID string `json:"caId"`
+	// LogID is the SHA-256 hash of the public key, empty until the first tree head is signed
+	LogID string `json:"logId,omitempty"`
+	// PublicKey is the PEM encoded key tree heads are signed with
+	PublicKey string `json:"publicKey,omitempty"`
+	TreeSize  int64  `json:"treeSize"`
+}
+
+type SignedTreeHeadResponse struct {
+	TreeSize       int64  `json:"treeSize"`
+	Timestamp      int64  `json:"timestamp"`
+	SHA256RootHash string `json:"sha256RootHash"`
+	// TreeHeadSignature is a TLS DigitallySigned struct over the RFC 6962 TreeHeadSignature
+	TreeHeadSignature string `json:"treeHeadSignature"`
+}
+
+type InclusionProofResponse struct {
+	LeafIndex     int64    `json:"leafIndex"`
+	LeafHash      string   `json:"leafHash"`
+	CertificateID string   `json:"certificateId"`
+	TreeSize      int64    `json:"treeSize"`
+	AuditPath     []string `json:"auditPath"`
+}
+
+type ConsistencyProofResponse struct {
+	First       int64    `json:"first"`
+	Second      int64    `json:"second"`
+	Consistency []string `json:"consistency"`
+}
+
+type TransparencyLogEntryResponse struct {
+	LeafIndex     int64  `json:"leafIndex"`
+	CertificateID string `json:"certificateId"`
+	Timestamp     int64  `json:"timestamp"`
+	LeafInput     string `json:"leafInput"`
+}
+
+type TransparencyLogEntriesResponse struct {
+	Entries []*TransparencyLogEntryResponse `json:"entries"`
+}
@@ -0,0 +1,697 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+const maxAcmeRequestSize = 64 * 1024
+
+type AcmeController struct {
+	authService services.AuthService
+	acmeService services.AcmeService
+	publicURL   string
+}
+
+func NewAcmeController(
+	authService services.AuthService,
+	acmeService services.AcmeService,
+	publicURL string,
+) *AcmeController {
+	return &AcmeController{
+		authService: authService,
+		acmeService: acmeService,
+		publicURL:   publicURL,
+	}
+}
+
+// acmeLinks builds the absolute URLs handed out to ACME clients for a single CA
+type acmeLinks struct {
+	base string
+}
+
+func (c *AcmeController) links(r *http.Request) *acmeLinks {
+	base := c.publicURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+
+	return &acmeLinks{
+		base: base + "/certificate-authorities/" + mux.Vars(r)["caId"] + "/acme",
+	}
+}
+
+func (l *acmeLinks) directory() string         { return l.base + "/directory" }
+func (l *acmeLinks) newNonce() string          { return l.base + "/new-nonce" }
+func (l *acmeLinks) newAccount() string        { return l.base + "/new-account" }
+func (l *acmeLinks) newOrder() string          { return l.base + "/new-order" }
+func (l *acmeLinks) revokeCert() string        { return l.base + "/revoke-cert" }
+func (l *acmeLinks) accountPrefix() string     { return l.base + "/account/" }
+func (l *acmeLinks) account(id string) string  { return l.accountPrefix() + id }
+func (l *acmeLinks) orders(id string) string   { return l.account(id) + "/orders" }
+func (l *acmeLinks) order(id string) string    { return l.base + "/order/" + id }
+func (l *acmeLinks) finalize(id string) string { return l.order(id) + "/finalize" }
+func (l *acmeLinks) authz(id string) string    { return l.base + "/authz/" + id }
+func (l *acmeLinks) chall(id string) string    { return l.base + "/chall/" + id }
+func (l *acmeLinks) cert(id string) string     { return l.base + "/cert/" + id }
+
+func (l *acmeLinks) requestURL(r *http.Request) string {
+	return l.base + acmeSubPath(r)
+}
+
+// acmeSubPath returns the part of the request path following the /acme prefix
+func acmeSubPath(r *http.Request) string {
+	prefix := "/certificate-authorities/" + mux.Vars(r)["caId"] + "/acme"
+	return r.URL.Path[len(prefix):]
+}
+
+// acmeHandler wraps every ACME protocol endpoint so that responses always carry a fresh nonce
+// and disabled directories are hidden
+func (c *AcmeController) acmeHandler(
+	handler func(w http.ResponseWriter, r *http.Request, links *acmeLinks),
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		links := c.links(r)
+
+		err := c.acmeService.IsEnabled(ctx, mux.Vars(r)["caId"])
+		if err != nil {
+			c.writeError(w, r, err)
+			return
+		}
+
+		nonce, err := c.acmeService.NewNonce(ctx)
+		if err != nil {
+			c.writeError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Replay-Nonce", nonce)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Add("Link", "<"+links.directory()+">;rel=\"index\"")
+
+		handler(w, r, links)
+	}
+}
+
+// verify authenticates the JWS body of a POST request
+func (c *AcmeController) verify(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+	useJWK bool,
+) (*services.AcmeRequest, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAcmeRequestSize))
+	if err != nil {
+		c.writeError(w, r, err)
+		return nil, false
+	}
+
+	req, err := c.acmeService.VerifyRequest(
+		r.Context(),
+		mux.Vars(r)["caId"],
+		links.requestURL(r),
+		links.accountPrefix(),
+		body,
+		useJWK,
+	)
+	if err != nil {
+		c.writeError(w, r, err)
+		return nil, false
+	}
+
+	return req, true
+}
+
+func (c *AcmeController) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.Get(r.Context()).WithError(err).Error("failed to encode response")
+	}
+}
+
+func (c *AcmeController) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	acmeErr := &services.AcmeError{}
+	if !errors.As(err, &acmeErr) {
+		logger.Get(r.Context()).WithError(err).Error("ACME request failed")
+		acmeErr = &services.AcmeError{
+			Type:   "urn:ietf:params:acme:error:serverInternal",
+			Detail: "internal server error",
+			Status: http.StatusInternalServerError,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(acmeErr.Status)
+
+	err = json.NewEncoder(w).Encode(acmeErr.ToProblem())
+	if err != nil {
+		logger.Get(r.Context()).WithError(err).Error("failed to encode response")
+	}
+}
+
+func (c *AcmeController) directoryHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	c.writeJSON(
+		w, r, http.StatusOK, &contracts.AcmeDirectory{
+			NewNonce:   links.newNonce(),
+			NewAccount: links.newAccount(),
+			NewOrder:   links.newOrder(),
+			RevokeCert: links.revokeCert(),
+			Meta: &contracts.AcmeDirectoryMeta{
+				ExternalAccountRequired: false,
+			},
+		},
+	)
+}
+
+func (c *AcmeController) newNonceHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	if r.Method == http.MethodGet {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *AcmeController) newAccountHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, true)
+	if !ok {
+		return
+	}
+
+	account, created, err := c.acmeService.NewAccount(r.Context(), req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Location", links.account(account.ID))
+	c.writeJSON(w, r, status, acmeAccountResponse(account, links))
+}
+
+func (c *AcmeController) accountHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	if req.Account.ID != mux.Vars(r)["accountId"] {
+		c.writeError(w, r, &services.AcmeError{
+			Type:   "urn:ietf:params:acme:error:unauthorized",
+			Detail: "account does not match key",
+			Status: http.StatusForbidden,
+		})
+		return
+	}
+
+	account, err := c.acmeService.UpdateAccount(r.Context(), req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	c.writeJSON(w, r, http.StatusOK, acmeAccountResponse(account, links))
+}
+
+func (c *AcmeController) ordersHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	orders, err := c.acmeService.GetOrders(r.Context(), req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	resp := &contracts.AcmeOrderList{Orders: make([]string, 0, len(orders))}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, links.order(order.ID))
+	}
+
+	c.writeJSON(w, r, http.StatusOK, resp)
+}
+
+func (c *AcmeController) newOrderHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	order, authorizations, err := c.acmeService.NewOrder(r.Context(), req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", links.order(order.ID))
+	c.writeJSON(w, r, http.StatusCreated, acmeOrderResponse(order, authorizations, links))
+}
+
+func (c *AcmeController) orderHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	order, authorizations, err := c.acmeService.GetOrder(
+		r.Context(),
+		req,
+		mux.Vars(r)["orderId"],
+	)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	c.writeJSON(w, r, http.StatusOK, acmeOrderResponse(order, authorizations, links))
+}
+
+func (c *AcmeController) finalizeHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	order, authorizations, err := c.acmeService.FinalizeOrder(
+		r.Context(),
+		req,
+		mux.Vars(r)["orderId"],
+	)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", links.order(order.ID))
+	c.writeJSON(w, r, http.StatusOK, acmeOrderResponse(order, authorizations, links))
+}
+
+func (c *AcmeController) authzHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	authorization, challenges, err := c.acmeService.GetAuthorization(
+		r.Context(),
+		req,
+		mux.Vars(r)["authzId"],
+	)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	c.writeJSON(
+		w,
+		r,
+		http.StatusOK,
+		acmeAuthorizationResponse(authorization, challenges, links),
+	)
+}
+
+func (c *AcmeController) challengeHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	challenge, authorization, err := c.acmeService.RespondToChallenge(
+		r.Context(),
+		req,
+		mux.Vars(r)["challengeId"],
+	)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Link", "<"+links.authz(authorization.ID)+">;rel=\"up\"")
+	c.writeJSON(w, r, http.StatusOK, acmeChallengeResponse(challenge, links))
+}
+
+func (c *AcmeController) certHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	chain, err := c.acmeService.GetCertificateChain(r.Context(), req, mux.Vars(r)["orderId"])
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	_, err = w.Write(chain)
+	if err != nil {
+		logger.Get(r.Context()).WithError(err).Error("failed to write certificate chain")
+	}
+}
+
+func (c *AcmeController) revokeCertHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+	links *acmeLinks,
+) {
+	req, ok := c.verify(w, r, links, false)
+	if !ok {
+		return
+	}
+
+	err := c.acmeService.RevokeCert(r.Context(), req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *AcmeController) enableHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.EnableAcmeRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = c.acmeService.EnableForUser(ctx, mux.Vars(r)["caId"], user.ID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCertUnautorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.WithError(err).Error("failed to enable ACME")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.writeJSON(
+		w, r, http.StatusOK, &contracts.AcmeDirectoryLink{
+			Directory: c.links(r).directory(),
+		},
+	)
+}
+
+func (c *AcmeController) disableHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err = c.acmeService.DisableForUser(ctx, mux.Vars(r)["caId"], user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCertUnautorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.WithError(err).Error("failed to disable ACME")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func acmeAccountResponse(account *daos.AcmeAccount, links *acmeLinks) *contracts.AcmeAccount {
+	contact := make([]string, 0)
+	_ = json.Unmarshal([]byte(account.Contact), &contact)
+
+	return &contracts.AcmeAccount{
+		Status:  account.Status,
+		Contact: contact,
+		Orders:  links.orders(account.ID),
+	}
+}
+
+func acmeOrderResponse(
+	order *daos.AcmeOrder,
+	authorizations []*daos.AcmeAuthorization,
+	links *acmeLinks,
+) *contracts.AcmeOrder {
+	resp := &contracts.AcmeOrder{
+		Status:         order.Status,
+		Expires:        order.Expires,
+		Identifiers:    make([]contracts.AcmeIdentifier, 0),
+		NotBefore:      order.NotBefore,
+		NotAfter:       order.NotAfter,
+		Authorizations: make([]string, len(authorizations)),
+		Finalize:       links.finalize(order.ID),
+	}
+
+	_ = json.Unmarshal([]byte(order.Identifiers), &resp.Identifiers)
+	resp.Error = acmeProblem(order.Error)
+
+	for i, authorization := range authorizations {
+		resp.Authorizations[i] = links.authz(authorization.ID)
+	}
+
+	if order.CertificateID != "" {
+		resp.Certificate = links.cert(order.ID)
+	}
+
+	return resp
+}
+
+func acmeAuthorizationResponse(
+	authorization *daos.AcmeAuthorization,
+	challenges []*daos.AcmeChallenge,
+	links *acmeLinks,
+) *contracts.AcmeAuthorization {
+	resp := &contracts.AcmeAuthorization{
+		Identifier: contracts.AcmeIdentifier{
+			Type:  authorization.IdentifierType,
+			Value: authorization.IdentifierValue,
+		},
+		Status:     authorization.Status,
+		Expires:    authorization.Expires,
+		Challenges: make([]contracts.AcmeChallenge, len(challenges)),
+		Wildcard:   authorization.Wildcard,
+	}
+
+	for i, challenge := range challenges {
+		resp.Challenges[i] = *acmeChallengeResponse(challenge, links)
+	}
+
+	return resp
+}
+
+func acmeChallengeResponse(
+	challenge *daos.AcmeChallenge,
+	links *acmeLinks,
+) *contracts.AcmeChallenge {
+	return &contracts.AcmeChallenge{
+		Type:      challenge.Type,
+		URL:       links.chall(challenge.ID),
+		Status:    challenge.Status,
+		Token:     challenge.Token,
+		Validated: challenge.Validated,
+		Error:     acmeProblem(challenge.Error),
+	}
+}
+
+func acmeProblem(data string) *contracts.AcmeProblem {
+	if data == "" {
+		return nil
+	}
+
+	problem := &contracts.AcmeProblem{}
+	if json.Unmarshal([]byte(data), problem) != nil {
+		return nil
+	}
+
+	return problem
+}
+
+func (c *AcmeController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	caParams := swagger.ParameterValue{
+		"caId": swagger.Parameter{
+			Description: "Certificate Authority ID",
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/certificate-authorities/{caId}/acme",
+		c.enableHandler,
+		swagger.Definitions{
+			PathParams: caParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.EnableAcmeRequest{}},
+				},
+				Description: "Expose an ACME directory for the CA",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.AcmeDirectoryLink{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodDelete,
+		"/certificate-authorities/{caId}/acme",
+		c.disableHandler,
+		swagger.Definitions{
+			PathParams: caParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	protocolRoutes := []struct {
+		method  string
+		path    string
+		handler func(w http.ResponseWriter, r *http.Request, links *acmeLinks)
+		params  []string
+	}{
+		{http.MethodGet, "/directory", c.directoryHandler, nil},
+		{http.MethodHead, "/new-nonce", c.newNonceHandler, nil},
+		{http.MethodGet, "/new-nonce", c.newNonceHandler, nil},
+		{http.MethodPost, "/new-account", c.newAccountHandler, nil},
+		{http.MethodPost, "/account/{accountId}", c.accountHandler, []string{"accountId"}},
+		{http.MethodPost, "/account/{accountId}/orders", c.ordersHandler, []string{"accountId"}},
+		{http.MethodPost, "/new-order", c.newOrderHandler, nil},
+		{http.MethodPost, "/order/{orderId}", c.orderHandler, []string{"orderId"}},
+		{http.MethodPost, "/order/{orderId}/finalize", c.finalizeHandler, []string{"orderId"}},
+		{http.MethodPost, "/authz/{authzId}", c.authzHandler, []string{"authzId"}},
+		{http.MethodPost, "/chall/{challengeId}", c.challengeHandler, []string{"challengeId"}},
+		{http.MethodPost, "/cert/{orderId}", c.certHandler, []string{"orderId"}},
+		{http.MethodPost, "/revoke-cert", c.revokeCertHandler, nil},
+	}
+
+	for _, route := range protocolRoutes {
+		params := swagger.ParameterValue{
+			"caId": caParams["caId"],
+		}
+		for _, param := range route.params {
+			params[param] = swagger.Parameter{Description: "ACME resource ID"}
+		}
+
+		_, err = router.AddRoute(
+			route.method,
+			"/certificate-authorities/{caId}/acme"+route.path,
+			c.acmeHandler(route.handler),
+			swagger.Definitions{
+				PathParams: params,
+			},
+		)
+		if err != nil {
+			log.WithError(err).Error("failed to setup route")
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+type AuditController struct {
+	authService  services.AuthService
+	auditService services.AuditService
+}
+
+func NewAuditController(
+	authService services.AuthService,
+	auditService services.AuditService,
+) *AuditController {
+	return &AuditController{
+		authService:  authService,
+		auditService: auditService,
+	}
+}
+
+func (c *AuditController) getEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.auditService.GetEntriesForUser(ctx, user.ID, filter)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAuditQuery) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		log.WithError(err).Error("failed to get audit entries")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+// auditFilterFromQuery reads the filters of GET /audit-log, times are RFC 3339
+func auditFilterFromQuery(r *http.Request) (*repositories.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := &repositories.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("targetType"),
+		TargetID:   query.Get("targetId"),
+		Outcome:    query.Get("outcome"),
+	}
+
+	var err error
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if until := query.Get("until"); until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if before := query.Get("before"); before != "" {
+		filter.BeforeSequence, err = strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return filter, nil
+}
+
+func (c *AuditController) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	_, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.auditService.Verify(ctx)
+	if err != nil {
+		log.WithError(err).Error("failed to verify audit log")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *AuditController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/audit-log",
+		c.getEntriesHandler,
+		swagger.Definitions{
+			Querystring: swagger.ParameterValue{
+				"action": swagger.Parameter{
+					Description: "Only entries for this action, e.g. key.decrypt",
+				},
+				"targetType": swagger.Parameter{
+					Description: "Only entries on this kind of target: key, certificate, " +
+						"ssh_certificate or user",
+				},
+				"targetId": swagger.Parameter{
+					Description: "Only entries on this target",
+				},
+				"outcome": swagger.Parameter{
+					Description: "success or failure",
+				},
+				"since": swagger.Parameter{
+					Description: "Only entries at or after this RFC 3339 time",
+				},
+				"until": swagger.Parameter{
+					Description: "Only entries before this RFC 3339 time",
+				},
+				"before": swagger.Parameter{
+					Description: "Only entries older than this sequence number, for paging",
+				},
+				"limit": swagger.Parameter{
+					Description: "Entries per page, 50 by default and at most 500",
+				},
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.AuditLogResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/audit-log/verify",
+		c.verifyHandler,
+		swagger.Definitions{
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.AuditVerificationResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+type AutoRenewController struct {
+	authService      services.AuthService
+	autoRenewService services.AutoRenewService
+}
+
+func NewAutoRenewController(
+	authService services.AuthService,
+	autoRenewService services.AutoRenewService,
+) *AutoRenewController {
+	return &AutoRenewController{
+		authService:      authService,
+		autoRenewService: autoRenewService,
+	}
+}
+
+func (c *AutoRenewController) enableHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.EnableAutoRenewRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.autoRenewService.EnableForUser(ctx, mux.Vars(r)["id"], user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *AutoRenewController) getHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.autoRenewService.GetForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *AutoRenewController) disableHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err = c.autoRenewService.DisableForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *AutoRenewController) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAutoRenewPolicy),
+		errors.Is(err, services.ErrCertNotRenewable):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, services.ErrCertUnautorized),
+		errors.Is(err, services.ErrKeyUnauthorized),
+		errors.Is(err, x509.IncorrectPasswordError):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, repositories.ErrNoRecord):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, services.ErrCertAlreadyRevoked):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		logger.Get(r.Context()).WithError(err).Error("auto-renew request failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (c *AutoRenewController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	idParams := swagger.ParameterValue{
+		"id": swagger.Parameter{
+			Description: "Certificate ID",
+		},
+	}
+
+	policyResponse := map[int]swagger.ContentValue{
+		http.StatusOK: {
+			Content: swagger.Content{
+				"application/json": {Value: contracts.AutoRenewResponse{}},
+			},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/certificates/{id}/auto-renew",
+		c.enableHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.EnableAutoRenewRequest{}},
+				},
+				Description: "Renew the certificate unattended once its remaining lifetime " +
+					"drops below a threshold, the key passwords are stored sealed",
+			},
+			Responses: policyResponse,
+			Security:  securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificates/{id}/auto-renew",
+		c.getHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			Responses:  policyResponse,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodDelete,
+		"/certificates/{id}/auto-renew",
+		c.disableHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
@@ -2,7 +2,11 @@ package controllers
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"net/http"
 
 	swagger "github.com/davidebianchi/gswagger"
@@ -10,28 +14,56 @@ import (
 	"github.com/fapiko/john-hancock-platform/app/context/logger"
 	"github.com/fapiko/john-hancock-platform/app/contracts"
 	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
 	"github.com/fapiko/john-hancock-platform/app/services"
 	"github.com/gorilla/mux"
 )
 
+// keyPasswordHeader carries key passwords on GET requests so they stay out of URLs and logs
+const keyPasswordHeader = "X-Key-Password"
+
+// exportPasswordHeader carries the passphrase a downloaded private key is re-encrypted with
+const exportPasswordHeader = "X-Export-Password"
+
 type CertificateAuthorityController struct {
 	authService           services.AuthService
 	certificateService    services.CertificateService
+	ocspService           services.OCSPService
 	certificateRepository repositories.CertRepository
+	transactor            repositories.Transactor
+	auditService          services.AuditService
+	transparencyLog       services.TransparencyLogService
 }
 
 func NewCertificateAuthorityController(
 	authService services.AuthService,
 	certService services.CertificateService,
+	ocspService services.OCSPService,
 	certRepo repositories.CertRepository,
+	transactor repositories.Transactor,
+	auditService services.AuditService,
+	transparencyLog services.TransparencyLogService,
 ) *CertificateAuthorityController {
 	return &CertificateAuthorityController{
 		authService:           authService,
 		certificateService:    certService,
+		ocspService:           ocspService,
 		certificateRepository: certRepo,
+		transactor:            transactor,
+		auditService:          auditService,
+		transparencyLog:       transparencyLog,
 	}
 }
 
+func (c *CertificateAuthorityController) setupCA(
+	ctx context.Context,
+	caID string,
+	userID string,
+	keyPassword string,
+) error {
+	return services.SetupCA(ctx, c.certificateService, c.ocspService, caID, userID, keyPassword)
+}
+
 func (c *CertificateAuthorityController) getCAHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := logger.Get(ctx)
@@ -116,22 +148,51 @@ func (c *CertificateAuthorityController) createCAHandler(w http.ResponseWriter,
 		certType = services.CertTypeRootCA
 	}
 
-	certData, err := c.certificateService.CreateCACert(ctx, req, user.ID, certType)
+	var cert *daos.Certificate
+	err = c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			certData, err := c.certificateService.CreateCACert(ctx, req, user.ID, certType)
+			if err != nil {
+				return err
+			}
+
+			cert, err = c.certificateRepository.CreateCert(
+				ctx,
+				user.ID,
+				req.Name,
+				certData,
+				certType.String(),
+				req.ParentCA,
+				req.KeyID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to store certificate: %w", err)
+			}
+
+			err = c.transparencyLog.AppendCertificate(ctx, cert)
+			if err != nil {
+				return err
+			}
+
+			return c.setupCA(ctx, cert.ID, user.ID, req.KeyPassword)
+		},
+	)
 	if err != nil {
-		log.WithError(err).Error("failed to generate certificate")
-		w.WriteHeader(http.StatusInternalServerError)
+		c.auditService.Record(ctx, user.ID, services.AuditCertificateCreate, "", err)
+
+		switch {
+		case errors.Is(err, services.ErrProfileViolation):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrProfileUnauthorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			log.WithError(err).Error("failed to create certificate authority")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		return
 	}
 
-	cert, err := c.certificateRepository.CreateCert(
-		ctx,
-		user.ID,
-		req.Name,
-		certData,
-		certType.String(),
-		req.ParentCA,
-		req.KeyID,
-	)
+	c.auditService.Record(ctx, user.ID, services.AuditCertificateCreate, cert.ID, nil)
 
 	resp := &contracts.CreateCAResponse{
 		ID:      cert.ID,
@@ -170,8 +231,15 @@ func (c *CertificateAuthorityController) createCertHandler(w http.ResponseWriter
 
 	resp, err := c.certificateService.CreateCert(ctx, certAuthorityId, req, user.ID)
 	if err != nil {
-		log.WithError(err).Error("failed to generate certificate")
-		w.WriteHeader(http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, services.ErrProfileViolation):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrProfileUnauthorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			log.WithError(err).Error("failed to generate certificate")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -182,6 +250,163 @@ func (c *CertificateAuthorityController) createCertHandler(w http.ResponseWriter
 	}
 }
 
+func (c *CertificateAuthorityController) signCSRHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+	certAuthorityId := vars["caId"]
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.SignCSRRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.certificateService.SignCSR(ctx, certAuthorityId, user.ID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCSR),
+			errors.Is(err, services.ErrProfileViolation):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrProfileUnauthorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.WithError(err).Error("failed to sign CSR")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *CertificateAuthorityController) importCertificatesHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.ImportCertificatesRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var resp *contracts.ImportCertificatesResponse
+	err = c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			var err error
+			resp, err = c.certificateService.ImportCertificates(ctx, user.ID, req)
+			if err != nil {
+				return err
+			}
+
+			// An imported CA with its key can sign, so it gets the same setup as a new one
+			for _, cert := range resp.Certificates {
+				if cert.ID == resp.KeyCertificateID &&
+					cert.Type != services.CertTypeCertificate.String() {
+					return c.setupCA(ctx, cert.ID, user.ID, req.KeyPassword)
+				}
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCertData),
+			errors.Is(err, services.ErrKeyMismatch),
+			errors.Is(err, services.ErrInvalidKeyParameters),
+			errors.Is(err, services.ErrUnsupportedKeyAlgorithm):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrKeyUnauthorized),
+			errors.Is(err, x509.IncorrectPasswordError):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, services.ErrCertSerialConflict):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			log.WithError(err).Error("failed to import certificates")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *CertificateAuthorityController) getCertificateBySerialHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	cert, err := c.certificateService.GetCertBySerialForUser(
+		ctx,
+		vars["caId"],
+		vars["serial"],
+		user.ID,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSerialNumber):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrCertUnautorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.WithError(err).Error("failed to get cert")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(cert)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
 func (c *CertificateAuthorityController) getCertificatesHandler(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -291,20 +516,260 @@ func (c *CertificateAuthorityController) downloadCertificateHandler(
 	}
 
 	cert, err := c.certificateService.GetCert(ctx, certId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoRecord) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		log.WithError(err).Error("failed to get cert")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
 
-	certPem, err := c.certificateService.GetCertAsPEMForUser(ctx, certId, user.ID)
+	export, err := c.certificateService.ExportCertForUser(
+		ctx,
+		certId,
+		user.ID,
+		services.CertificateFormat(r.URL.Query().Get("format")),
+		r.Header.Get(keyPasswordHeader),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownCertFormat),
+			errors.Is(err, services.ErrCertHasNoKey):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrCertUnautorized),
+			errors.Is(err, x509.IncorrectPasswordError):
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			log.WithError(err).Error("failed to export cert")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", "attachment; filename="+cert.Name+".pem")
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set(
+		"Content-Disposition",
+		"attachment; filename="+cert.Name+export.Extension,
+	)
 	w.Header().Set("Content-Transfer-Encoding", "binary")
 	w.Header().Set("Expires", "0")
-	_, err = w.Write([]byte(certPem))
+	_, err = w.Write(export.Data)
 	if err != nil {
 		log.WithError(err).Error("failed to write cert")
 		return
 	}
 }
 
+func (c *CertificateAuthorityController) revokeCertificateHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+	certId := vars["id"]
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.RevokeCertificateRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reason, err := contracts.RevocationReasonFromString(req.Reason)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = c.certificateService.RevokeCertForUser(ctx, certId, user.ID, reason, req.CAKeyPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCertUnautorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, services.ErrCertAlreadyRevoked):
+			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.WithError(err).Error("failed to revoke cert")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *CertificateAuthorityController) renewCertificateHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+	certId := vars["id"]
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.RenewCertificateRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.issueSuccessor(
+		w, r, user.ID, req.KeyPassword,
+		func(ctx context.Context) (*contracts.CertificateLightResponse, error) {
+			return c.certificateService.RenewCertForUser(ctx, certId, user.ID, req)
+		},
+	)
+}
+
+func (c *CertificateAuthorityController) rekeyCertificateHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+	certId := vars["id"]
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.RekeyCertificateRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.issueSuccessor(
+		w, r, user.ID, req.KeyPassword,
+		func(ctx context.Context) (*contracts.CertificateLightResponse, error) {
+			return c.certificateService.RekeyCertForUser(ctx, certId, user.ID, req)
+		},
+	)
+}
+
+// issueSuccessor writes the result of a renewal or re-key. A renewed CA gets its own CRL and OCSP
+// responder in the same transaction, exactly like a new one.
+func (c *CertificateAuthorityController) issueSuccessor(
+	w http.ResponseWriter,
+	r *http.Request,
+	userID string,
+	keyPassword string,
+	issue func(ctx context.Context) (*contracts.CertificateLightResponse, error),
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	var resp *contracts.CertificateLightResponse
+	err := c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			var err error
+			resp, err = issue(ctx)
+			if err != nil {
+				return err
+			}
+
+			if resp.Type != services.CertTypeRootCA.String() &&
+				resp.Type != services.CertTypeIntermediateCA.String() {
+				return nil
+			}
+
+			return c.setupCA(ctx, resp.ID, userID, keyPassword)
+		},
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCertNotRenewable),
+			errors.Is(err, services.ErrInvalidKeyParameters),
+			errors.Is(err, services.ErrUnsupportedKeyAlgorithm):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrCertUnautorized),
+			errors.Is(err, services.ErrKeyUnauthorized),
+			errors.Is(err, x509.IncorrectPasswordError):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, services.ErrCertAlreadyRevoked):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			log.WithError(err).Error("failed to issue successor certificate")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *CertificateAuthorityController) getCRLHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+	certAuthorityId := vars["caId"]
+
+	crl, err := c.certificateService.GetCRL(ctx, certAuthorityId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoRecord) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		log.WithError(err).Error("failed to get CRL")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "pem":
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		crl = pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl})
+	case "", "der":
+		w.Header().Set("Content-Type", "application/pkix-crl")
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_, err = w.Write(crl)
+	if err != nil {
+		log.WithError(err).Error("failed to write CRL")
+		return
+	}
+}
+
 func (c *CertificateAuthorityController) SetupRoutes(
 	ctx context.Context,
 	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
@@ -371,6 +836,61 @@ func (c *CertificateAuthorityController) SetupRoutes(
 		},
 	)
 
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/certificates/import",
+		c.importCertificatesHandler,
+		swagger.Definitions{
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.ImportCertificatesRequest{}},
+				},
+				Description: "Import PEM or DER certificates and chains, or a PKCS#12 bundle",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.ImportCertificatesResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/certificate-authorities/{caId}/sign-csr",
+		c.signCSRHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"caId": swagger.Parameter{
+					Description: "Certificate Authority ID",
+				},
+			},
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.SignCSRRequest{}},
+				},
+				Description: "Issue a certificate for an externally generated PKCS#10 request",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.CertificateLightResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
 	_, err = router.AddRoute(
 		http.MethodGet,
 		"/certificate-authorities/{caId}/certificates",
@@ -385,6 +905,51 @@ func (c *CertificateAuthorityController) SetupRoutes(
 		},
 	)
 
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/serials/{serial}",
+		c.getCertificateBySerialHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"caId": swagger.Parameter{
+					Description: "Certificate Authority ID",
+				},
+				"serial": swagger.Parameter{
+					Description: "Serial number in decimal, or hex prefixed with 0x",
+				},
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.CertificateResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/crl",
+		c.getCRLHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"caId": swagger.Parameter{
+					Description: "Certificate Authority ID",
+				},
+			},
+			Querystring: swagger.ParameterValue{
+				"format": swagger.Parameter{
+					Description: "CRL encoding, der (default) or pem",
+				},
+			},
+		},
+	)
+
 	_, err = router.AddRoute(
 		http.MethodGet,
 		"/certificates/{id}",
@@ -413,6 +978,67 @@ func (c *CertificateAuthorityController) SetupRoutes(
 		},
 	)
 
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/certificates/{id}/revoke",
+		c.revokeCertificateHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"id": swagger.Parameter{
+					Description: "Certificate ID",
+				},
+			},
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.RevokeCertificateRequest{}},
+				},
+				Description: "Revoke a certificate and regenerate the issuer's CRL",
+			},
+			Security: securityRequirements,
+		},
+	)
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/certificates/{id}/renew",
+		c.renewCertificateHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"id": swagger.Parameter{
+					Description: "Certificate ID",
+				},
+			},
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.RenewCertificateRequest{}},
+				},
+				Description: "Issue a successor with the same key, subject, SANs and usages",
+			},
+			Security: securityRequirements,
+		},
+	)
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/certificates/{id}/rekey",
+		c.rekeyCertificateHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"id": swagger.Parameter{
+					Description: "Certificate ID",
+				},
+			},
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.RekeyCertificateRequest{}},
+				},
+				Description: "Issue a successor with the same subject, SANs and usages on " +
+					"another key",
+			},
+			Security: securityRequirements,
+		},
+	)
+
 	_, err = router.AddRoute(
 		http.MethodGet,
 		"/certificates/{id}/download",
@@ -425,7 +1051,12 @@ func (c *CertificateAuthorityController) SetupRoutes(
 			},
 			Querystring: swagger.ParameterValue{
 				"format": swagger.Parameter{
-					Description: "Download format type",
+					Description: "pem (default), der, chain-pem, fullchain-pem, p7b or p12",
+				},
+			},
+			Headers: swagger.ParameterValue{
+				keyPasswordHeader: swagger.Parameter{
+					Description: "Password of the certificate's key, also protects p12 downloads",
 				},
 			},
 			Security: securityRequirements,
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+type CertificateProfileController struct {
+	authService    services.AuthService
+	profileService services.CertificateProfileService
+}
+
+func NewCertificateProfileController(
+	authService services.AuthService,
+	profileService services.CertificateProfileService,
+) *CertificateProfileController {
+	return &CertificateProfileController{
+		authService:    authService,
+		profileService: profileService,
+	}
+}
+
+func (c *CertificateProfileController) createProfileHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.CertificateProfileRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.profileService.CreateProfileForUser(ctx, user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *CertificateProfileController) getProfilesHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.profileService.GetProfilesForUser(ctx, user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *CertificateProfileController) getProfileHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.profileService.GetProfileForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *CertificateProfileController) updateProfileHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.CertificateProfileRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.profileService.UpdateProfileForUser(ctx, mux.Vars(r)["id"], user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *CertificateProfileController) deleteProfileHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err = c.profileService.DeleteProfileForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *CertificateProfileController) writeError(
+	w http.ResponseWriter,
+	r *http.Request,
+	err error,
+) {
+	switch {
+	case errors.Is(err, services.ErrInvalidProfile):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, services.ErrProfileUnauthorized):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, repositories.ErrNoRecord):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		logger.Get(r.Context()).WithError(err).Error("certificate profile request failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (c *CertificateProfileController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	idParams := swagger.ParameterValue{
+		"id": swagger.Parameter{
+			Description: "Certificate profile ID",
+		},
+	}
+
+	requestBody := &swagger.ContentValue{
+		Content: swagger.Content{
+			"application/json": {Value: contracts.CertificateProfileRequest{}},
+		},
+	}
+
+	profileResponse := map[int]swagger.ContentValue{
+		http.StatusOK: {
+			Content: swagger.Content{
+				"application/json": {Value: contracts.CertificateProfileResponse{}},
+			},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/certificate-profiles",
+		c.createProfileHandler,
+		swagger.Definitions{
+			RequestBody: requestBody,
+			Responses:   profileResponse,
+			Security:    securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-profiles",
+		c.getProfilesHandler,
+		swagger.Definitions{
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-profiles/{id}",
+		c.getProfileHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			Responses:  profileResponse,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/certificate-profiles/{id}",
+		c.updateProfileHandler,
+		swagger.Definitions{
+			PathParams:  idParams,
+			RequestBody: requestBody,
+			Responses:   profileResponse,
+			Security:    securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodDelete,
+		"/certificate-profiles/{id}",
+		c.deleteProfileHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
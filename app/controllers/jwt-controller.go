@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+	"go.step.sm/crypto/jose"
+)
+
+type JWTController struct {
+	authService services.AuthService
+	jwtService  services.JWTService
+}
+
+func NewJWTController(
+	authService services.AuthService,
+	jwtService services.JWTService,
+) *JWTController {
+	return &JWTController{
+		authService: authService,
+		jwtService:  jwtService,
+	}
+}
+
+func (c *JWTController) createSetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.CreateJWKSetRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.jwtService.CreateSetForUser(ctx, user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *JWTController) getSetsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.jwtService.GetSetsForUser(ctx, user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *JWTController) rotateKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.RotateJWKSetKeyRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.jwtService.RotateKeyForUser(ctx, mux.Vars(r)["id"], user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *JWTController) retireKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	err = c.jwtService.RetireKeyForUser(ctx, vars["id"], vars["kid"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *JWTController) setJWKSHandler(w http.ResponseWriter, r *http.Request) {
+	jwks, err := c.jwtService.GetJWKS(r.Context(), mux.Vars(r)["id"])
+	c.writeJWKS(w, r, jwks, err)
+}
+
+func (c *JWTController) userJWKSHandler(w http.ResponseWriter, r *http.Request) {
+	jwks, err := c.jwtService.GetJWKSForUser(r.Context(), mux.Vars(r)["id"])
+	c.writeJWKS(w, r, jwks, err)
+}
+
+func (c *JWTController) writeJWKS(
+	w http.ResponseWriter,
+	r *http.Request,
+	jwks *jose.JSONWebKeySet,
+	err error,
+) {
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	err = json.NewEncoder(w).Encode(jwks)
+	if err != nil {
+		logger.Get(r.Context()).WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *JWTController) signHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.SignJWTRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.jwtService.SignForUser(ctx, mux.Vars(r)["id"], user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *JWTController) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	req := &contracts.VerifyJWTRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.jwtService.Verify(ctx, mux.Vars(r)["id"], req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *JWTController) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidJWTRequest):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, services.ErrJWKSetUnauthorized),
+		errors.Is(err, services.ErrKeyUnauthorized),
+		errors.Is(err, x509.IncorrectPasswordError):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, repositories.ErrNoRecord):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, services.ErrJWKAlreadyInSet):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		logger.Get(r.Context()).WithError(err).Error("JWT request failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (c *JWTController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	setIDParams := swagger.ParameterValue{
+		"id": swagger.Parameter{
+			Description: "Key set ID",
+		},
+	}
+
+	setResponse := map[int]swagger.ContentValue{
+		http.StatusOK: {
+			Content: swagger.Content{
+				"application/json": {Value: contracts.JWKSetResponse{}},
+			},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/jwk-sets",
+		c.createSetHandler,
+		swagger.Definitions{
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.CreateJWKSetRequest{}},
+				},
+				Description: "Start a key set to sign JWTs with",
+			},
+			Responses: setResponse,
+			Security:  securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/jwk-sets",
+		c.getSetsHandler,
+		swagger.Definitions{
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/jwk-sets/{id}/keys",
+		c.rotateKeyHandler,
+		swagger.Definitions{
+			PathParams: setIDParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.RotateJWKSetKeyRequest{}},
+				},
+				Description: "Rotate the set onto a new signing key, the replaced key stays " +
+					"published for the set's overlap",
+			},
+			Responses: setResponse,
+			Security:  securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodDelete,
+		"/jwk-sets/{id}/keys/{kid}",
+		c.retireKeyHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"id": swagger.Parameter{
+					Description: "Key set ID",
+				},
+				"kid": swagger.Parameter{
+					Description: "Key ID as published in the JWKS",
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/jwk-sets/{id}/jwks.json",
+		c.setJWKSHandler,
+		swagger.Definitions{
+			PathParams: setIDParams,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/users/{id}/jwks.json",
+		c.userJWKSHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"id": swagger.Parameter{
+					Description: "User ID",
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/jwk-sets/{id}/sign",
+		c.signHandler,
+		swagger.Definitions{
+			PathParams: setIDParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.SignJWTRequest{}},
+				},
+				Description: "Sign claims with the set's current key",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.SignJWTResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/jwk-sets/{id}/verify",
+		c.verifyHandler,
+		swagger.Definitions{
+			PathParams: setIDParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.VerifyJWTRequest{}},
+				},
+				Description: "Check a token against the keys the set publishes",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.VerifyJWTResponse{}},
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
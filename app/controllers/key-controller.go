@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	swagger "github.com/davidebianchi/gswagger"
@@ -37,7 +39,7 @@ func (c *KeyController) getKeyTypesHandler(w http.ResponseWriter, r *http.Reques
 	ctx := r.Context()
 	log := logger.Get(ctx)
 
-	err := json.NewEncoder(w).Encode(contracts.KeyAlgorithmStrings)
+	err := json.NewEncoder(w).Encode(contracts.KeyTypes)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		log.WithError(err).Error("failed to encode key types")
@@ -62,8 +64,22 @@ func (c *KeyController) createKeyHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp, err := c.keyService.CreateKey(ctx, user.ID, req.Name, req.Algorithm, req.Password)
+	resp, err := c.keyService.CreateKey(
+		ctx,
+		user.ID,
+		req.Name,
+		req.Algorithm,
+		req.KeyParameters,
+		req.Password,
+	)
 	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedKeyAlgorithm) ||
+			errors.Is(err, services.ErrInvalidKeyParameters) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		log.WithError(err).Error("failed to create key")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -75,6 +91,54 @@ func (c *KeyController) createKeyHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+func (c *KeyController) importKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.ImportKeyRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.keyService.ImportKey(
+		ctx,
+		user.ID,
+		req.Name,
+		[]byte(req.Data),
+		req.SourcePassword,
+		req.Password,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidKeyData),
+			errors.Is(err, services.ErrInvalidKeyParameters),
+			errors.Is(err, services.ErrUnsupportedKeyAlgorithm):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, x509.IncorrectPasswordError):
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			log.WithError(err).Error("failed to import key")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
 func (c *KeyController) getKeysHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -97,6 +161,75 @@ func (c *KeyController) getKeysHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (c *KeyController) deleteKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err = c.keyService.DeleteKeyForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, services.ErrKeyUnauthorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			log.WithError(err).Error("failed to delete key")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *KeyController) signDigestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.SignDigestRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.keyService.SignDigestForUser(ctx, mux.Vars(r)["id"], user.ID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSignDigestRequest):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrKeyUnauthorized),
+			errors.Is(err, x509.IncorrectPasswordError):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.WithError(err).Error("failed to sign digest")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
 func (c *KeyController) downloadKeyHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := logger.Get(ctx)
@@ -112,6 +245,11 @@ func (c *KeyController) downloadKeyHandler(w http.ResponseWriter, r *http.Reques
 
 	key, err := c.keyRepository.GetKey(ctx, keyId)
 	if err != nil {
+		if errors.Is(err, repositories.ErrNoRecord) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
@@ -121,13 +259,39 @@ func (c *KeyController) downloadKeyHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", "attachment; filename="+key.Name+".pem")
+	query := r.URL.Query()
+	export, err := c.keyService.ExportKeyForUser(
+		ctx,
+		keyId,
+		user.ID,
+		services.KeyFormat(query.Get("format")),
+		r.Header.Get(keyPasswordHeader),
+		r.Header.Get(exportPasswordHeader),
+		query.Get("cipher"),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownKeyFormat),
+			errors.Is(err, services.ErrKeyFormatUnsupported),
+			errors.Is(err, services.ErrUnknownKeyCipher):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, services.ErrKeyUnauthorized),
+			errors.Is(err, x509.IncorrectPasswordError):
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			log.WithError(err).Error("failed to export key")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+key.Name+export.Extension)
 	w.Header().Set("Content-Transfer-Encoding", "binary")
 	w.Header().Set("Expires", "0")
-	_, err = w.Write(key.Data)
+	_, err = w.Write(export.Data)
 	if err != nil {
-		log.WithError(err).Error("failed to write cert")
+		log.WithError(err).Error("failed to write key")
 		return
 	}
 }
@@ -151,6 +315,31 @@ func (c *KeyController) RegisterRoutes(
 		log.WithError(err).Fatal("failed to add route")
 	}
 
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/keys/import",
+		c.importKeyHandler,
+		swagger.Definitions{
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.ImportKeyRequest{}},
+				},
+				Description: "Import a PEM encoded PKCS#1, PKCS#8, SEC1 or OpenSSH private key",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.KeyLightResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Fatal("failed to add route")
+	}
+
 	_, err = router.AddRoute(
 		http.MethodGet,
 		"/keys/types",
@@ -181,10 +370,73 @@ func (c *KeyController) RegisterRoutes(
 			},
 			Querystring: swagger.ParameterValue{
 				"format": swagger.Parameter{
-					Description: "Download format type",
+					Description: "Download format type: pkcs8-pem, pkcs8-der, pkcs1, sec1, openssh, " +
+						"jwk, spki-pem, ssh-public or jwk-public. Empty returns the stored key",
+				},
+				"cipher": swagger.Parameter{
+					Description: "Cipher for encrypted PEM exports: aes128, aes192, aes256 or 3des",
+				},
+			},
+			Headers: swagger.ParameterValue{
+				keyPasswordHeader: swagger.Parameter{
+					Description: "Current password of the key",
+				},
+				exportPasswordHeader: swagger.Parameter{
+					Description: "Passphrase to encrypt the exported private key with",
 				},
 			},
 			Security: securityRequirements,
 		},
 	)
+	if err != nil {
+		log.WithError(err).Fatal("failed to add route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/keys/{id}/sign",
+		c.signDigestHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"id": swagger.Parameter{
+					Description: "Key ID",
+				},
+			},
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.SignDigestRequest{}},
+				},
+				Description: "Sign a digest computed by the caller, the key never leaves the " +
+					"platform",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.SignDigestResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Fatal("failed to add route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodDelete,
+		"/keys/{id}",
+		c.deleteKeyHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"id": swagger.Parameter{
+					Description: "Key ID",
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Fatal("failed to add route")
+	}
 }
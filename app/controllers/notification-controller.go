@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+type NotificationController struct {
+	authService         services.AuthService
+	notificationService services.NotificationService
+}
+
+func NewNotificationController(
+	authService services.AuthService,
+	notificationService services.NotificationService,
+) *NotificationController {
+	return &NotificationController{
+		authService:         authService,
+		notificationService: notificationService,
+	}
+}
+
+func (c *NotificationController) createRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.NotificationRuleRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.notificationService.CreateRuleForUser(ctx, user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *NotificationController) getRulesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.notificationService.GetRulesForUser(ctx, user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *NotificationController) getRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.notificationService.GetRuleForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *NotificationController) updateRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.NotificationRuleRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.notificationService.UpdateRuleForUser(ctx, mux.Vars(r)["id"], user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *NotificationController) deleteRuleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err = c.notificationService.DeleteRuleForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *NotificationController) getDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.notificationService.GetDeliveriesForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *NotificationController) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidNotificationRule):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, services.ErrNotificationRuleUnauthorized),
+		errors.Is(err, services.ErrCertUnautorized):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, repositories.ErrNoRecord):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		logger.Get(r.Context()).WithError(err).Error("notification request failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (c *NotificationController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	idParams := swagger.ParameterValue{
+		"id": swagger.Parameter{
+			Description: "Notification rule ID",
+		},
+	}
+
+	requestBody := &swagger.ContentValue{
+		Content: swagger.Content{
+			"application/json": {Value: contracts.NotificationRuleRequest{}},
+		},
+	}
+
+	ruleResponse := map[int]swagger.ContentValue{
+		http.StatusOK: {
+			Content: swagger.Content{
+				"application/json": {Value: contracts.NotificationRuleResponse{}},
+			},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/notification-rules",
+		c.createRuleHandler,
+		swagger.Definitions{
+			RequestBody: requestBody,
+			Responses:   ruleResponse,
+			Security:    securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/notification-rules",
+		c.getRulesHandler,
+		swagger.Definitions{
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/notification-rules/{id}",
+		c.getRuleHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			Responses:  ruleResponse,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/notification-rules/{id}",
+		c.updateRuleHandler,
+		swagger.Definitions{
+			PathParams:  idParams,
+			RequestBody: requestBody,
+			Responses:   ruleResponse,
+			Security:    securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodDelete,
+		"/notification-rules/{id}",
+		c.deleteRuleHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/notification-rules/{id}/deliveries",
+		c.getDeliveriesHandler,
+		swagger.Definitions{
+			PathParams: idParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+// maxOCSPRequestSize bounds POSTed OCSP requests, which are well under 1KB in practice
+const maxOCSPRequestSize = 64 * 1024
+
+type OCSPController struct {
+	authService services.AuthService
+	ocspService services.OCSPService
+}
+
+func NewOCSPController(
+	authService services.AuthService,
+	ocspService services.OCSPService,
+) *OCSPController {
+	return &OCSPController{
+		authService: authService,
+		ocspService: ocspService,
+	}
+}
+
+func (c *OCSPController) ocspPostHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+	certAuthorityId := vars["caId"]
+
+	req, err := io.ReadAll(io.LimitReader(r.Body, maxOCSPRequestSize))
+	if err != nil {
+		log.WithError(err).Error("failed to read OCSP request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.writeOCSPResponse(w, c.ocspService.Respond(ctx, certAuthorityId, req))
+}
+
+func (c *OCSPController) ocspGetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	vars := mux.Vars(r)
+	certAuthorityId := vars["caId"]
+
+	req, err := base64.StdEncoding.DecodeString(vars["request"])
+	if err != nil {
+		req, err = base64.URLEncoding.DecodeString(vars["request"])
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.writeOCSPResponse(w, c.ocspService.Respond(ctx, certAuthorityId, req))
+}
+
+func (c *OCSPController) writeOCSPResponse(w http.ResponseWriter, resp []byte) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	_, _ = w.Write(resp)
+}
+
+func (c *OCSPController) issueResponderHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	vars := mux.Vars(r)
+	certAuthorityId := vars["caId"]
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.IssueOCSPResponderRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.ocspService.IssueResponderForUser(
+		ctx,
+		certAuthorityId,
+		user.ID,
+		req.CAKeyPassword,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCertUnautorized):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			log.WithError(err).Error("failed to issue OCSP responder")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *OCSPController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/certificate-authorities/{caId}/ocsp",
+		c.ocspPostHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"caId": swagger.Parameter{
+					Description: "Certificate Authority ID",
+				},
+			},
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					// A []byte schema carries contentEncoding, which the route
+					// validation rejects, so the raw body is described as a string
+					"application/ocsp-request": {Value: ""},
+				},
+				Description: "DER encoded OCSP request",
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	// The GET form carries the base64 encoded request in the path, which may contain slashes
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/ocsp/{request:.+}",
+		c.ocspGetHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"caId": swagger.Parameter{
+					Description: "Certificate Authority ID",
+				},
+				"request:.+": swagger.Parameter{
+					Description: "Base64 encoded DER OCSP request",
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/certificate-authorities/{caId}/ocsp-responder",
+		c.issueResponderHandler,
+		swagger.Definitions{
+			PathParams: swagger.ParameterValue{
+				"caId": swagger.Parameter{
+					Description: "Certificate Authority ID",
+				},
+			},
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.IssueOCSPResponderRequest{}},
+				},
+				Description: "Issue a new delegated OCSP signing certificate for the CA",
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
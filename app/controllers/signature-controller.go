@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+type SignatureController struct {
+	authService      services.AuthService
+	signatureService services.SignatureService
+}
+
+func NewSignatureController(
+	authService services.AuthService,
+	signatureService services.SignatureService,
+) *SignatureController {
+	return &SignatureController{
+		authService:      authService,
+		signatureService: signatureService,
+	}
+}
+
+func (c *SignatureController) signHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.SignDataRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.signatureService.SignDataForUser(ctx, user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *SignatureController) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.VerifySignatureRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.signatureService.VerifyForUser(ctx, user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *SignatureController) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSignatureRequest),
+		errors.Is(err, services.ErrCertHasNoKey),
+		errors.Is(err, services.ErrUnknownCertFormat):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, services.ErrCertUnautorized),
+		errors.Is(err, services.ErrKeyUnauthorized),
+		errors.Is(err, x509.IncorrectPasswordError):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, repositories.ErrNoRecord):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		logger.Get(r.Context()).WithError(err).Error("signature request failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (c *SignatureController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/signatures",
+		c.signHandler,
+		swagger.Definitions{
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.SignDataRequest{}},
+				},
+				Description: "Make a detached CMS signature over data or its digest",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.SignDataResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/signatures/verify",
+		c.verifyHandler,
+		swagger.Definitions{
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.VerifySignatureRequest{}},
+				},
+				Description: "Check a detached CMS signature against one of your CAs",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.VerifySignatureResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/gorilla/mux"
+)
+
+type SSHController struct {
+	authService services.AuthService
+	sshService  services.SSHService
+}
+
+func NewSSHController(
+	authService services.AuthService,
+	sshService services.SSHService,
+) *SSHController {
+	return &SSHController{
+		authService: authService,
+		sshService:  sshService,
+	}
+}
+
+func (c *SSHController) createCAHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.CreateSSHCARequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.sshService.CreateCAForUser(ctx, user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *SSHController) getCAsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.sshService.GetCAsForUser(ctx, user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *SSHController) getCAPublicKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	query := r.URL.Query()
+	data, err := c.sshService.ExportCAPublicKey(
+		ctx,
+		mux.Vars(r)["id"],
+		services.SSHCAKeyFormat(query.Get("format")),
+		query.Get("hosts"),
+	)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, err = w.Write(data)
+	if err != nil {
+		log.WithError(err).Error("failed to write SSH CA key")
+		return
+	}
+}
+
+func (c *SSHController) getKRLHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	krl, err := c.sshService.GetKRL(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, err = w.Write(krl)
+	if err != nil {
+		log.WithError(err).Error("failed to write KRL")
+		return
+	}
+}
+
+func (c *SSHController) signCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.SignSSHCertificateRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.sshService.SignCertificateForUser(ctx, mux.Vars(r)["id"], user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *SSHController) getCertificatesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.sshService.GetCertificatesForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *SSHController) getCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.sshService.GetCertificateForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *SSHController) revokeCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	err = c.sshService.RevokeCertificateForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *SSHController) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSSHCertificateRequest),
+		errors.Is(err, services.ErrUnknownKeyFormat):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, services.ErrSSHCAUnauthorized),
+		errors.Is(err, services.ErrSSHCertificateUnauthorized),
+		errors.Is(err, services.ErrKeyUnauthorized),
+		errors.Is(err, x509.IncorrectPasswordError):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, repositories.ErrNoRecord):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, services.ErrSSHCertificateAlreadyRevoked):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		logger.Get(r.Context()).WithError(err).Error("SSH request failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (c *SSHController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	caIDParams := swagger.ParameterValue{
+		"id": swagger.Parameter{
+			Description: "SSH CA ID",
+		},
+	}
+
+	certIDParams := swagger.ParameterValue{
+		"id": swagger.Parameter{
+			Description: "SSH certificate ID",
+		},
+	}
+
+	certResponse := map[int]swagger.ContentValue{
+		http.StatusOK: {
+			Content: swagger.Content{
+				"application/json": {Value: contracts.SSHCertificateResponse{}},
+			},
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/ssh-certificate-authorities",
+		c.createCAHandler,
+		swagger.Definitions{
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.CreateSSHCARequest{}},
+				},
+				Description: "Use one of your keys as an SSH CA",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.SSHCAResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/ssh-certificate-authorities",
+		c.getCAsHandler,
+		swagger.Definitions{
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/ssh-certificate-authorities/{id}/public-key",
+		c.getCAPublicKeyHandler,
+		swagger.Definitions{
+			PathParams: caIDParams,
+			Querystring: swagger.ParameterValue{
+				"format": swagger.Parameter{
+					Description: "trusted-user-ca-keys (default) for sshd's TrustedUserCAKeys, " +
+						"or known-hosts for an @cert-authority line",
+				},
+				"hosts": swagger.Parameter{
+					Description: "known_hosts host patterns the CA is trusted for, * by default",
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/ssh-certificate-authorities/{id}/krl",
+		c.getKRLHandler,
+		swagger.Definitions{
+			PathParams: caIDParams,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/ssh-certificate-authorities/{id}/certificates",
+		c.signCertificateHandler,
+		swagger.Definitions{
+			PathParams: caIDParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.SignSSHCertificateRequest{}},
+				},
+				Description: "Sign a user or host certificate",
+			},
+			Responses: certResponse,
+			Security:  securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/ssh-certificate-authorities/{id}/certificates",
+		c.getCertificatesHandler,
+		swagger.Definitions{
+			PathParams: caIDParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/ssh-certificates/{id}",
+		c.getCertificateHandler,
+		swagger.Definitions{
+			PathParams: certIDParams,
+			Responses:  certResponse,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/ssh-certificates/{id}/revoke",
+		c.revokeCertificateHandler,
+		swagger.Definitions{
+			PathParams: certIDParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+	"github.com/gorilla/mux"
+)
+
+// maxTimestampRequestSize bounds POSTed time stamp requests, which are a few hundred bytes
+const maxTimestampRequestSize = 64 * 1024
+
+type TimestampController struct {
+	authService      services.AuthService
+	timestampService services.TimestampService
+}
+
+func NewTimestampController(
+	authService services.AuthService,
+	timestampService services.TimestampService,
+) *TimestampController {
+	return &TimestampController{
+		authService:      authService,
+		timestampService: timestampService,
+	}
+}
+
+func (c *TimestampController) createAuthorityHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	req := &contracts.CreateTimestampAuthorityRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.timestampService.CreateAuthorityForUser(ctx, user.ID, req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *TimestampController) getAuthoritiesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.timestampService.GetAuthoritiesForUser(ctx, user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *TimestampController) getTokensHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	user, err := c.authService.GetUserForRequest(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	resp, err := c.timestampService.GetTokensForUser(ctx, mux.Vars(r)["id"], user.ID)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *TimestampController) timestampHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	req, err := io.ReadAll(io.LimitReader(r.Body, maxTimestampRequestSize))
+	if err != nil {
+		log.WithError(err).Error("failed to read time stamp request")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.timestampService.Respond(ctx, mux.Vars(r)["id"], req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	_, err = w.Write(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to write time stamp response")
+		return
+	}
+}
+
+func (c *TimestampController) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := logger.Get(ctx)
+
+	req := &contracts.VerifyTimestampRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		log.WithError(err).Error("failed to decode request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.timestampService.Verify(ctx, mux.Vars(r)["id"], req)
+	if err != nil {
+		c.writeError(w, r, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *TimestampController) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTimestampRequest),
+		errors.Is(err, services.ErrCertHasNoKey),
+		errors.Is(err, services.ErrUnknownCertFormat):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, services.ErrTimestampAuthorityUnauthorized),
+		errors.Is(err, services.ErrCertUnautorized),
+		errors.Is(err, services.ErrKeyUnauthorized),
+		errors.Is(err, x509.IncorrectPasswordError):
+		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, repositories.ErrNoRecord):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, utils.ErrNoSecret):
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		logger.Get(r.Context()).WithError(err).Error("time stamp request failed")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (c *TimestampController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	securityRequirements := swagger.SecurityRequirements{
+		{
+			"apiKey": {},
+		},
+	}
+
+	tsaIDParams := swagger.ParameterValue{
+		"id": swagger.Parameter{
+			Description: "TSA ID",
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodPut,
+		"/timestamp-authorities",
+		c.createAuthorityHandler,
+		swagger.Definitions{
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.CreateTimestampAuthorityRequest{}},
+				},
+				Description: "Run a TSA on one of your time stamping certificates",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.TimestampAuthorityResponse{}},
+					},
+				},
+			},
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/timestamp-authorities",
+		c.getAuthoritiesHandler,
+		swagger.Definitions{
+			Security: securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/timestamp-authorities/{id}/timestamp",
+		c.timestampHandler,
+		swagger.Definitions{
+			PathParams: tsaIDParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					// A []byte schema carries contentEncoding, which the route
+					// validation rejects, so the raw body is described as a string
+					"application/timestamp-query": {Value: ""},
+				},
+				Description: "DER encoded RFC 3161 TimeStampReq",
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/timestamp-authorities/{id}/tokens",
+		c.getTokensHandler,
+		swagger.Definitions{
+			PathParams: tsaIDParams,
+			Security:   securityRequirements,
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodPost,
+		"/timestamp-authorities/{id}/verify",
+		c.verifyHandler,
+		swagger.Definitions{
+			PathParams: tsaIDParams,
+			RequestBody: &swagger.ContentValue{
+				Content: swagger.Content{
+					"application/json": {Value: contracts.VerifyTimestampRequest{}},
+				},
+				Description: "Check a token against the data it stamps",
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.VerifyTimestampResponse{}},
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
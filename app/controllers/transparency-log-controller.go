@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+	"github.com/gorilla/mux"
+)
+
+// TransparencyLogController serves the issuance logs publicly, like OCSP and CRLs, so auditors
+// and monitors need no account
+type TransparencyLogController struct {
+	transparencyLogService services.TransparencyLogService
+}
+
+func NewTransparencyLogController(
+	transparencyLogService services.TransparencyLogService,
+) *TransparencyLogController {
+	return &TransparencyLogController{
+		transparencyLogService: transparencyLogService,
+	}
+}
+
+func (c *TransparencyLogController) getLogHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	resp, err := c.transparencyLogService.GetLog(ctx, mux.Vars(r)["caId"])
+	c.writeResponse(ctx, w, resp, err)
+}
+
+func (c *TransparencyLogController) getSTHHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	resp, err := c.transparencyLogService.GetSignedTreeHead(ctx, mux.Vars(r)["caId"])
+	c.writeResponse(ctx, w, resp, err)
+}
+
+func (c *TransparencyLogController) getProofByHashHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	leafHash, err := base64.StdEncoding.DecodeString(query.Get("hash"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	treeSize, err := optionalInt64(query.Get("treeSize"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.transparencyLogService.GetInclusionProof(
+		ctx,
+		mux.Vars(r)["caId"],
+		leafHash,
+		query.Get("certificateId"),
+		treeSize,
+	)
+	c.writeResponse(ctx, w, resp, err)
+}
+
+func (c *TransparencyLogController) getConsistencyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	first, err := strconv.ParseInt(query.Get("first"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	second, err := strconv.ParseInt(query.Get("second"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.transparencyLogService.GetConsistencyProof(
+		ctx,
+		mux.Vars(r)["caId"],
+		first,
+		second,
+	)
+	c.writeResponse(ctx, w, resp, err)
+}
+
+func (c *TransparencyLogController) getEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+
+	start, err := strconv.ParseInt(query.Get("start"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	end, err := strconv.ParseInt(query.Get("end"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp, err := c.transparencyLogService.GetEntries(ctx, mux.Vars(r)["caId"], start, end)
+	c.writeResponse(ctx, w, resp, err)
+}
+
+// optionalInt64 parses a query parameter that defaults to zero
+func optionalInt64(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (c *TransparencyLogController) writeResponse(
+	ctx context.Context,
+	w http.ResponseWriter,
+	resp interface{},
+	err error,
+) {
+	log := logger.Get(ctx)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTransparencyQuery):
+			w.WriteHeader(http.StatusBadRequest)
+		case errors.Is(err, repositories.ErrNoRecord):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, utils.ErrNoSecret):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			log.WithError(err).Error("failed to read transparency log")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.WithError(err).Error("failed to encode response")
+		return
+	}
+}
+
+func (c *TransparencyLogController) SetupRoutes(
+	ctx context.Context,
+	router *swagger.Router[gorilla.HandlerFunc, *mux.Route],
+) {
+	log := logger.Get(ctx)
+
+	caIDParam := swagger.ParameterValue{
+		"caId": swagger.Parameter{
+			Description: "Certificate Authority ID",
+		},
+	}
+
+	var err error
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/transparency-log",
+		c.getLogHandler,
+		swagger.Definitions{
+			PathParams: caIDParam,
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.TransparencyLogResponse{}},
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/transparency-log/sth",
+		c.getSTHHandler,
+		swagger.Definitions{
+			PathParams: caIDParam,
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.SignedTreeHeadResponse{}},
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/transparency-log/proof-by-hash",
+		c.getProofByHashHandler,
+		swagger.Definitions{
+			PathParams: caIDParam,
+			Querystring: swagger.ParameterValue{
+				"hash": swagger.Parameter{
+					Description: "Base64 encoded leaf hash, certificateId may be given instead",
+				},
+				"certificateId": swagger.Parameter{
+					Description: "ID of the logged certificate",
+				},
+				"treeSize": swagger.Parameter{
+					Description: "Tree size to prove inclusion in, the current size by default",
+				},
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.InclusionProofResponse{}},
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/transparency-log/consistency",
+		c.getConsistencyHandler,
+		swagger.Definitions{
+			PathParams: caIDParam,
+			Querystring: swagger.ParameterValue{
+				"first": swagger.Parameter{
+					Description: "Size of the older tree",
+				},
+				"second": swagger.Parameter{
+					Description: "Size of the newer tree",
+				},
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {Value: contracts.ConsistencyProofResponse{}},
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+
+	_, err = router.AddRoute(
+		http.MethodGet,
+		"/certificate-authorities/{caId}/transparency-log/entries",
+		c.getEntriesHandler,
+		swagger.Definitions{
+			PathParams: caIDParam,
+			Querystring: swagger.ParameterValue{
+				"start": swagger.Parameter{
+					Description: "Index of the first entry",
+				},
+				"end": swagger.Parameter{
+					Description: "Index of the last entry, at most 1000 are returned",
+				},
+			},
+			Responses: map[int]swagger.ContentValue{
+				http.StatusOK: {
+					Content: swagger.Content{
+						"application/json": {
+							Value: contracts.TransparencyLogEntriesResponse{},
+						},
+					},
+				},
+			},
+		},
+	)
+	if err != nil {
+		log.WithError(err).Error("failed to setup route")
+	}
+}
@@ -14,25 +14,26 @@ import (
 	"github.com/davidebianchi/gswagger/support/gorilla"
 	"github.com/fapiko/john-hancock-platform/app/context/logger"
 	"github.com/fapiko/john-hancock-platform/app/contracts"
-	"github.com/fapiko/john-hancock-platform/app/persistence/graphdb"
 	"github.com/fapiko/john-hancock-platform/app/repositories"
 	"github.com/fapiko/john-hancock-platform/app/services"
-	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserController struct {
 	UserRepository repositories.UserRepository
 	AuthService    services.AuthService
+	AuditService   services.AuditService
 }
 
 func NewController(
 	userRepository repositories.UserRepository,
 	authService services.AuthService,
+	auditService services.AuditService,
 ) *UserController {
 	return &UserController{
 		UserRepository: userRepository,
 		AuthService:    authService,
+		AuditService:   auditService,
 	}
 }
 
@@ -55,7 +56,9 @@ func (c *UserController) createUserHandler(w http.ResponseWriter, r *http.Reques
 	log := logger.Get(r.Context())
 	user, err := c.UserRepository.CreateUser(r.Context(), createUserReq)
 	if err != nil {
-		if err.(*neo4j.Neo4jError).Code == graphdb.SchemaFailedCode {
+		c.AuditService.Record(r.Context(), "", services.AuditUserCreate, "", err)
+
+		if errors.Is(err, repositories.ErrDuplicateRecord) {
 			w.WriteHeader(http.StatusConflict)
 		} else {
 			w.WriteHeader(http.StatusInternalServerError)
@@ -64,6 +67,8 @@ func (c *UserController) createUserHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	c.AuditService.Record(r.Context(), user.ID, services.AuditUserCreate, user.ID, nil)
+
 	resp, err := json.Marshal(user.ToResponse())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -107,9 +112,12 @@ func (c *UserController) loginUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Logins are recorded against the email address tried, the actor is only known once it
+	// matches a user
 	user, err := c.UserRepository.GetUserByEmail(ctx, loginRequest.Email)
 	if err != nil {
 		log.WithError(err).Error("failed to get user")
+		c.AuditService.Record(ctx, "", services.AuditUserLogin, loginRequest.Email, err)
 
 		// Throw unauthorized to avoid leaking user existence
 		w.WriteHeader(http.StatusUnauthorized)
@@ -118,6 +126,8 @@ func (c *UserController) loginUserHandler(w http.ResponseWriter, r *http.Request
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginRequest.Password))
 	if err != nil {
+		c.AuditService.Record(ctx, user.ID, services.AuditUserLogin, loginRequest.Email, err)
+
 		// Only log if we had a bcrypt failure
 		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 			log.WithError(err).Error("failed to compare password")
@@ -128,6 +138,7 @@ func (c *UserController) loginUserHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	session, err := c.UserRepository.CreateSession(ctx, user.ID)
+	c.AuditService.Record(ctx, user.ID, services.AuditUserLogin, loginRequest.Email, err)
 	if err != nil {
 		log.WithError(err).Error("failed to create session")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/fapiko/john-hancock-platform/app/config"
+	"github.com/fapiko/john-hancock-platform/app/persistence/migrate"
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openSQLDatabase connects to the configured SQL backend and returns it with the migration driver
+// and the migrations directory for its dialect
+func openSQLDatabase(cfg config.Database) (*gorm.DB, migrate.Driver, string, error) {
+	var dialector gorm.Dialector
+	var newDriver func(db *gorm.DB) *migrate.SQLDriver
+	switch cfg.Type {
+	case "", config.DB_TYPE_MYSQL:
+		port := cfg.Port
+		if port == 0 {
+			port = 3306
+		}
+
+		dialector = mysql.Open(
+			fmt.Sprintf(
+				"%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+				cfg.Username,
+				cfg.Password,
+				cfg.Hostname,
+				port,
+				cfg.Name,
+			),
+		)
+		newDriver = migrate.NewMySQLDriver
+	case config.DB_TYPE_POSTGRES:
+		port := cfg.Port
+		if port == 0 {
+			port = 5432
+		}
+
+		dialector = postgres.Open(
+			fmt.Sprintf(
+				"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+				cfg.Hostname,
+				port,
+				cfg.Username,
+				cfg.Password,
+				cfg.Name,
+				cfg.SSLMode,
+			),
+		)
+		newDriver = migrate.NewPostgresDriver
+	case config.DB_TYPE_SQLITE:
+		// the background workers write concurrently with requests, so wait on the lock instead of
+		// failing with SQLITE_BUSY. Transactions take the write lock up front, as a deferred one
+		// that reads first can't upgrade once another connection has committed.
+		dialector = sqlite.Open(
+			cfg.Name + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate",
+		)
+		newDriver = migrate.NewSQLiteDriver
+	default:
+		return nil, nil, "", fmt.Errorf("unknown database type %q", cfg.Type)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	dir := cfg.Type
+	if dir == "" {
+		dir = config.DB_TYPE_MYSQL
+	}
+
+	return db, newDriver(db), dir, nil
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/sirupsen/logrus"
+)
+
+type Type string
+
+const (
+	CertificateRenewed       Type = "certificate.renewed"
+	CertificateRenewalFailed Type = "certificate.renewal_failed"
+)
+
+// Event describes something that happened to a user's resource outside of a request. Data
+// carries type specific details such as a successor's ID or an error message.
+type Event struct {
+	Type          Type
+	Time          time.Time
+	UserID        string
+	CertificateID string
+	Data          map[string]string
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event *Event)
+}
+
+type Subscriber func(ctx context.Context, event *Event)
+
+var _ Publisher = (*Dispatcher)(nil)
+
+// Dispatcher hands every published event to each subscriber in turn, on the publisher's goroutine
+type Dispatcher struct {
+	mu          sync.RWMutex
+	subscribers []Subscriber
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+func (d *Dispatcher) Subscribe(subscriber Subscriber) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subscribers = append(d.subscribers, subscriber)
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	d.mu.RLock()
+	subscribers := d.subscribers
+	d.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		subscriber(ctx, event)
+	}
+}
+
+// Log is a Subscriber writing every event to the server log
+func Log(ctx context.Context, event *Event) {
+	fields := logrus.Fields{
+		"event":         event.Type,
+		"userID":        event.UserID,
+		"certificateID": event.CertificateID,
+	}
+	for key, value := range event.Data {
+		fields[key] = value
+	}
+
+	logger.Get(ctx).WithFields(fields).Info("event")
+}
@@ -2,25 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	stdLog "log"
 
 	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/fapiko/john-hancock-platform/app/certificates"
 	"github.com/fapiko/john-hancock-platform/app/config"
 	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/context/request"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
 	"github.com/fapiko/john-hancock-platform/app/controllers"
+	"github.com/fapiko/john-hancock-platform/app/events"
+	"github.com/fapiko/john-hancock-platform/app/persistence/migrate"
 	"github.com/fapiko/john-hancock-platform/app/repositories"
 	"github.com/fapiko/john-hancock-platform/app/services"
 	"github.com/fapiko/john-hancock-platform/app/users"
+	"github.com/fapiko/john-hancock-platform/migrations"
 	"github.com/gorilla/handlers"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"github.com/sirupsen/logrus"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
 
 	swagger "github.com/davidebianchi/gswagger"
 	"github.com/getkin/kin-openapi/openapi3"
@@ -37,6 +45,7 @@ func main() {
 	}
 
 	muxRouter := mux.NewRouter()
+	muxRouter.Use(request.Middleware)
 
 	muxRouter.PathPrefix("/swagger/").Handler(
 		http.StripPrefix(
@@ -74,6 +83,18 @@ func main() {
 	var certificateRepository repositories.CertRepository
 	var keyRepository repositories.KeyRepository
 	var userRepository repositories.UserRepository
+	var acmeRepository repositories.AcmeRepository
+	var profileRepository repositories.CertificateProfileRepository
+	var autoRenewRepository repositories.AutoRenewRepository
+	var leaseRepository repositories.LeaseRepository
+	var notificationRepository repositories.NotificationRepository
+	var auditRepository repositories.AuditRepository
+	var transparencyLogRepository repositories.TransparencyLogRepository
+	var sshRepository repositories.SSHRepository
+	var timestampRepository repositories.TimestampRepository
+	var jwkSetRepository repositories.JWKSetRepository
+	var transactor repositories.Transactor
+	var migrator *migrate.Migrator
 	if cfg.Database.Type == config.DB_TYPE_NEO4J {
 		neo4jDriver, err := neo4j.NewDriver(
 			"bolt://localhost:7687",
@@ -89,49 +110,284 @@ func main() {
 			}
 		}()
 
+		migrator, err = migrate.NewMigrator(
+			migrate.NewNeo4jDriver(neo4jDriver),
+			migrations.FS,
+			"neo4j",
+		)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		certificateRepository = repositories.NewCertRepositoryNeo4j(neo4jDriver)
+		keyRepository = repositories.NewKeyRepositoryNeo4j(neo4jDriver)
 		userRepository = repositories.NewUserRepositoryNeo4j(neo4jDriver)
-	} else {
-		dsn := fmt.Sprintf(
-			"%s:%s@tcp(%s:3306)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			cfg.Database.Username,
-			cfg.Database.Password,
-			cfg.Database.Hostname,
-			cfg.Database.Name,
+		acmeRepository = repositories.NewAcmeRepositoryNeo4j(neo4jDriver)
+		profileRepository = repositories.NewCertificateProfileRepositoryNeo4j(neo4jDriver)
+		autoRenewRepository = repositories.NewAutoRenewRepositoryNeo4j(neo4jDriver)
+		leaseRepository = repositories.NewLeaseRepositoryNeo4j(neo4jDriver)
+		notificationRepository = repositories.NewNotificationRepositoryNeo4j(neo4jDriver)
+		auditRepository = repositories.NewAuditRepositoryNeo4j(neo4jDriver)
+		transparencyLogRepository = repositories.NewTransparencyLogRepositoryNeo4j(neo4jDriver)
+		sshRepository = repositories.NewSSHRepositoryNeo4j(neo4jDriver)
+		timestampRepository = repositories.NewTimestampRepositoryNeo4j(neo4jDriver)
+		jwkSetRepository = repositories.NewJWKSetRepositoryNeo4j(neo4jDriver)
+		transactor = repositories.NewTransactorNeo4j(neo4jDriver)
+	} else if cfg.Database.Type == config.DB_TYPE_MEMORY {
+		certMemory := repositories.NewCertRepositoryMemory()
+		keyMemory := repositories.NewKeyRepositoryMemory()
+		userMemory := repositories.NewUserRepositoryMemory()
+		acmeMemory := repositories.NewAcmeRepositoryMemory()
+		profileMemory := repositories.NewCertificateProfileRepositoryMemory()
+		autoRenewMemory := repositories.NewAutoRenewRepositoryMemory()
+		notificationMemory := repositories.NewNotificationRepositoryMemory()
+		transparencyLogMemory := repositories.NewTransparencyLogRepositoryMemory()
+		sshMemory := repositories.NewSSHRepositoryMemory()
+		timestampMemory := repositories.NewTimestampRepositoryMemory()
+		jwkSetMemory := repositories.NewJWKSetRepositoryMemory()
+
+		certificateRepository = certMemory
+		keyRepository = keyMemory
+		userRepository = userMemory
+		acmeRepository = acmeMemory
+		profileRepository = profileMemory
+		autoRenewRepository = autoRenewMemory
+		leaseRepository = repositories.NewLeaseRepositoryMemory()
+		notificationRepository = notificationMemory
+		auditRepository = repositories.NewAuditRepositoryMemory()
+		transparencyLogRepository = transparencyLogMemory
+		sshRepository = sshMemory
+		timestampRepository = timestampMemory
+		jwkSetRepository = jwkSetMemory
+		transactor = repositories.NewTransactorMemory(
+			certMemory,
+			keyMemory,
+			userMemory,
+			acmeMemory,
+			profileMemory,
+			autoRenewMemory,
+			notificationMemory,
+			transparencyLogMemory,
+			sshMemory,
+			timestampMemory,
+			jwkSetMemory,
 		)
+	} else {
+		db, migrationDriver, migrationDir, err := openSQLDatabase(cfg.Database)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		migrator, err = migrate.NewMigrator(migrationDriver, migrations.FS, migrationDir)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		certificateRepository = repositories.NewCertRepositorySQL(db)
+		keyRepository = repositories.NewKeyRepositorySQL(db)
+		userRepository = repositories.NewUserRepositorySQL(db)
+		acmeRepository = repositories.NewAcmeRepositorySQL(db)
+		profileRepository = repositories.NewCertificateProfileRepositorySQL(db)
+		autoRenewRepository = repositories.NewAutoRenewRepositorySQL(db)
+		leaseRepository = repositories.NewLeaseRepositorySQL(db)
+		notificationRepository = repositories.NewNotificationRepositorySQL(db)
+		auditRepository = repositories.NewAuditRepositorySQL(db)
+		transparencyLogRepository = repositories.NewTransparencyLogRepositorySQL(db)
+		sshRepository = repositories.NewSSHRepositorySQL(db)
+		timestampRepository = repositories.NewTimestampRepositorySQL(db)
+		jwkSetRepository = repositories.NewJWKSetRepositorySQL(db)
+		transactor = repositories.NewTransactorSQL(db)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if migrator == nil {
+			log.Fatal("The memory database has no schema to migrate")
+		}
+
+		err = runMigrateCommand(ctx, migrator, os.Args[2:])
+		if err != nil {
+			log.WithError(err).Fatal("Error running migrations")
+		}
+
+		return
+	}
 
-		db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if migrator != nil && cfg.Database.AutoMigrate {
+		_, err = migrator.Up(ctx)
 		if err != nil {
-			panic(err)
+			log.WithError(err).Fatal("Error applying migrations")
 		}
+	}
 
-		certificateRepository = repositories.NewCertRepositoryMySQL(db)
-		keyRepository = repositories.NewKeyRepositoryMySQL(db)
-		userRepository = repositories.NewUserRepositoryMySql(db)
+	if cfg.Server.SecretKey == "" {
+		log.Warn("SECRET_KEY is not set, the audit log chain is not keyed")
 	}
+	auditService := services.NewAuditServiceImpl(auditRepository, cfg.Server.SecretKey)
+	go auditService.WriteQueued(ctx)
 
-	authService := services.NewAuthService(userRepository)
-	keyService := services.NewKeyServiceImpl(keyRepository)
+	authService := services.NewAuthService(userRepository, auditService)
+	transparencyLogService := services.NewTransparencyLogServiceImpl(
+		transparencyLogRepository,
+		certificateRepository,
+		transactor,
+		cfg.Server.SecretKey,
+	)
+	keyService := services.NewKeyServiceImpl(
+		keyRepository,
+		certificateRepository,
+		transactor,
+		auditService,
+	)
 	certificateService := services.NewCertificateServiceImpl(
 		certificateRepository,
 		keyRepository,
+		profileRepository,
+		keyService,
+		transactor,
+		auditService,
+		transparencyLogService,
+		cfg.Server.PublicURL,
+		cfg.Server.SecretKey,
+	)
+	profileService := services.NewCertificateProfileServiceImpl(profileRepository)
+	sshService := services.NewSSHServiceImpl(sshRepository, keyService, auditService)
+	signatureService := services.NewSignatureServiceImpl(
+		certificateRepository,
+		keyService,
+		auditService,
+	)
+	timestampService := services.NewTimestampServiceImpl(
+		timestampRepository,
+		certificateRepository,
+		keyService,
+		cfg.Server.SecretKey,
+	)
+	jwtService := services.NewJWTServiceImpl(
+		jwkSetRepository,
+		keyService,
+		transactor,
+		auditService,
+	)
+	ocspService := services.NewOCSPServiceImpl(
+		certificateRepository,
+		keyService,
+		transparencyLogService,
+		transactor,
+		cfg.Server.SecretKey,
+	)
+	acmeService := services.NewAcmeServiceImpl(
+		acmeRepository,
+		certificateRepository,
+		certificateService,
+		keyService,
+		transactor,
+		cfg.Server.SecretKey,
+		services.DefaultAcmeValidators(),
+	)
+
+	dispatcher := events.NewDispatcher()
+	dispatcher.Subscribe(events.Log)
+
+	autoRenewService := services.NewAutoRenewServiceImpl(
+		autoRenewRepository,
+		certificateRepository,
+		certificateService,
+		ocspService,
 		keyService,
+		transactor,
+		dispatcher,
+		cfg.Server.SecretKey,
+	)
+
+	webhookChannel, err := services.NewWebhookChannel(cfg.Server.WebhookAllowedNetworks)
+	if err != nil {
+		log.WithError(err).Fatal("Error configuring webhook notifications")
+	}
+
+	notificationChannels := map[string]services.NotificationChannel{
+		contracts.NotificationChannelWebhook: webhookChannel,
+	}
+	if cfg.SMTP.Host != "" {
+		notificationChannels[contracts.NotificationChannelEmail] = services.NewSMTPChannel(
+			cfg.SMTP.Host,
+			cfg.SMTP.Port,
+			cfg.SMTP.Username,
+			cfg.SMTP.Password,
+			cfg.SMTP.From,
+		)
+	}
+
+	notificationService := services.NewNotificationServiceImpl(
+		notificationRepository,
+		certificateRepository,
+		certificateService,
+		notificationChannels,
+		cfg.Server.SecretKey,
 	)
 
 	caController := controllers.NewCertificateAuthorityController(
 		authService,
 		certificateService,
+		ocspService,
 		certificateRepository,
+		transactor,
+		auditService,
+		transparencyLogService,
+	)
+	ocspController := controllers.NewOCSPController(authService, ocspService)
+	acmeController := controllers.NewAcmeController(authService, acmeService, cfg.Server.PublicURL)
+	profileController := controllers.NewCertificateProfileController(authService, profileService)
+	autoRenewController := controllers.NewAutoRenewController(authService, autoRenewService)
+	notificationController := controllers.NewNotificationController(
+		authService,
+		notificationService,
 	)
+	auditController := controllers.NewAuditController(authService, auditService)
+	transparencyLogController := controllers.NewTransparencyLogController(transparencyLogService)
+	sshController := controllers.NewSSHController(authService, sshService)
+	signatureController := controllers.NewSignatureController(authService, signatureService)
+	timestampController := controllers.NewTimestampController(authService, timestampService)
+	jwtController := controllers.NewJWTController(authService, jwtService)
 	keyController := controllers.NewKeyController(authService, keyService, keyRepository)
-	userController := controllers.NewController(userRepository, authService)
+	userController := controllers.NewController(userRepository, authService, auditService)
 
 	caController.SetupRoutes(ctx, router)
+	ocspController.SetupRoutes(ctx, router)
+	acmeController.SetupRoutes(ctx, router)
+	profileController.SetupRoutes(ctx, router)
+	autoRenewController.SetupRoutes(ctx, router)
+	notificationController.SetupRoutes(ctx, router)
+	auditController.SetupRoutes(ctx, router)
+	transparencyLogController.SetupRoutes(ctx, router)
+	sshController.SetupRoutes(ctx, router)
+	signatureController.SetupRoutes(ctx, router)
+	timestampController.SetupRoutes(ctx, router)
+	jwtController.SetupRoutes(ctx, router)
 	keyController.RegisterRoutes(ctx, router)
 	userController.SetupRoutes(ctx, router)
 
 	sessionWorker := users.NewSessionWorker(userRepository)
 	go sessionWorker.Start(ctx)
 
+	crlWorker := certificates.NewCRLWorker(certificateService, ocspService)
+	go crlWorker.Start(ctx)
+
+	renewalWorker := certificates.NewRenewalWorker(
+		autoRenewService,
+		leaseRepository,
+		workerHolder(),
+		cfg.Server.AutoRenewInterval,
+	)
+	go renewalWorker.Start(ctx)
+
+	notificationWorker := certificates.NewNotificationWorker(
+		notificationService,
+		leaseRepository,
+		workerHolder(),
+		cfg.Server.NotificationInterval,
+	)
+	go notificationWorker.Start(ctx)
+
 	err = router.GenerateAndExposeOpenapi()
 	if err != nil {
 		log.WithError(err).Error("Error generating swagger")
@@ -146,6 +402,8 @@ func main() {
 				"Content-Language",
 				"Content-Type",
 				"Origin",
+				"X-Key-Password",
+				"X-Export-Password",
 			},
 		),
 		handlers.ExposedHeaders([]string{"Content-Disposition"}),
@@ -163,9 +421,45 @@ func main() {
 			return ctx
 		},
 	}
+	// Stop the workers on shutdown so their leases are released for the other replicas
+	shutdownCtx, stopSignals := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-shutdownCtx.Done()
+
+		sessionWorker.Stop(ctx)
+		crlWorker.Stop(ctx)
+		renewalWorker.Stop(ctx)
+		notificationWorker.Stop(ctx)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+
+		err := srv.Shutdown(timeoutCtx)
+		if err != nil {
+			log.WithError(err).Error("Error shutting down server")
+		}
+	}()
+
 	log.Infof("Swagger up and running at http://0.0.0.0%s/swagger/", srv.Addr)
 	err = srv.ListenAndServe()
-	if err != nil {
+	if !errors.Is(err, http.ErrServerClosed) {
 		log.WithError(err).Error("Error starting server")
+		return
+	}
+
+	<-shutdownDone
+}
+
+// workerHolder identifies this replica when taking leases on background jobs
+func workerHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
 	}
+
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
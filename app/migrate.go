@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/persistence/migrate"
+)
+
+var errMigrateUsage = errors.New("usage: john-hancock migrate [up | down [steps] | status]")
+
+// runMigrateCommand handles `john-hancock migrate [up | down [steps] | status]`
+func runMigrateCommand(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	log := logger.Get(ctx)
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		migrated, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("applied %d migrations", len(migrated))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errMigrateUsage
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("reverted %d migrations", len(reverted))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+
+			fmt.Printf("%d-%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		return errMigrateUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+// Package migrate applies the versioned schema migrations embedded by the migrations package.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+)
+
+const downSuffix = ".down"
+
+var ErrInvalidMigration = errors.New("invalid migration file")
+var ErrNoDownMigration = errors.New("migration has no down script")
+
+// Migration is a schema change loaded from <version>-<name>.<ext> and <version>-<name>.down.<ext>
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Driver runs migrations against one database backend and records the applied versions there
+type Driver interface {
+	// Init creates the version bookkeeping when it does not exist yet
+	Init(ctx context.Context) error
+	// Lock blocks until no other migrator holds the database and returns the function releasing
+	// it, so replicas starting together don't apply the same migration twice
+	Lock(ctx context.Context) (func() error, error)
+	AppliedVersions(ctx context.Context) (map[int]bool, error)
+	// Apply runs statements, then records the migration as applied when up or forgets it otherwise
+	Apply(ctx context.Context, migration *Migration, statements []string, up bool) error
+}
+
+type MigrationStatus struct {
+	*Migration
+	Applied bool
+}
+
+type Migrator struct {
+	driver     Driver
+	migrations []*Migration
+}
+
+// NewMigrator loads the migrations found in dir of fsys
+func NewMigrator(driver Driver, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		driver:     driver,
+		migrations: migrations,
+	}, nil
+}
+
+// Load reads every migration in dir, ordered by version
+func Load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fileName := entry.Name()
+		base := strings.TrimSuffix(fileName, path.Ext(fileName))
+		down := strings.HasSuffix(base, downSuffix)
+		base = strings.TrimSuffix(base, downSuffix)
+
+		versionStr, name, ok := strings.Cut(base, "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, fileName)
+		}
+
+		version, err := strconv.Atoi(versionStr)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, fileName)
+		}
+
+		script, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf(
+				"%w: version %d is used by %s and %s",
+				ErrInvalidMigration,
+				version,
+				migration.Name,
+				name,
+			)
+		}
+
+		if down {
+			migration.Down = string(script)
+		} else {
+			migration.Up = string(script)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf(
+				"%w: version %d has no up script",
+				ErrInvalidMigration,
+				migration.Version,
+			)
+		}
+
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(
+		migrations, func(i, j int) bool {
+			return migrations[i].Version < migrations[j].Version
+		},
+	)
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in version order and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	log := logger.Get(ctx)
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.driver.AppliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated := make([]*Migration, 0)
+	for _, migration := range m.migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		log.Infof("applying migration %d-%s", migration.Version, migration.Name)
+		err = m.driver.Apply(ctx, migration, SplitStatements(migration.Up), true)
+		if err != nil {
+			return migrated, fmt.Errorf(
+				"migration %d-%s: %w",
+				migration.Version,
+				migration.Name,
+				err,
+			)
+		}
+
+		migrated = append(migrated, migration)
+	}
+
+	return migrated, nil
+}
+
+// Down reverts the latest steps applied migrations and returns the ones reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	log := logger.Get(ctx)
+
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.driver.AppliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := make([]*Migration, 0, steps)
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		migration := m.migrations[i]
+		if !applied[migration.Version] {
+			continue
+		}
+
+		if migration.Down == "" {
+			return reverted, fmt.Errorf(
+				"%w: %d-%s",
+				ErrNoDownMigration,
+				migration.Version,
+				migration.Name,
+			)
+		}
+
+		log.Infof("reverting migration %d-%s", migration.Version, migration.Name)
+		err = m.driver.Apply(ctx, migration, SplitStatements(migration.Down), false)
+		if err != nil {
+			return reverted, fmt.Errorf(
+				"migration %d-%s: %w",
+				migration.Version,
+				migration.Name,
+				err,
+			)
+		}
+
+		reverted = append(reverted, migration)
+	}
+
+	return reverted, nil
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = &MigrationStatus{
+			Migration: migration,
+			Applied:   applied[migration.Version],
+		}
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	err := m.driver.Init(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.driver.AppliedVersions(ctx)
+}
+
+// lock initializes the bookkeeping and takes the migration lock. Failing to release it is only
+// logged, the migrations themselves have been applied by then.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	err := m.driver.Init(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := m.driver.Lock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("migration lock: %w", err)
+	}
+
+	return func() {
+		err := release()
+		if err != nil {
+			logger.Get(ctx).WithError(err).Error("failed to release migration lock")
+		}
+	}, nil
+}
+
+// SplitStatements breaks a script into the statements ended by a semicolon at the end of a line.
+// Lines starting with -- or // are comments.
+func SplitStatements(script string) []string {
+	statements := make([]string, 0)
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			statements = appendStatement(statements, current.String())
+			current.Reset()
+		}
+	}
+
+	return appendStatement(statements, current.String())
+}
+
+func appendStatement(statements []string, statement string) []string {
+	statement = strings.TrimSuffix(strings.TrimSpace(statement), ";")
+	if statement == "" {
+		return statements
+	}
+
+	return append(statements, statement)
+}
@@ -0,0 +1,183 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/persistence/graphdb"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+const (
+	// neo4jLockTTL bounds how long a migrator that died holding the lock blocks the others, it
+	// has to outlast the slowest migration
+	neo4jLockTTL  = 15 * time.Minute
+	neo4jLockPoll = time.Second
+)
+
+var _ Driver = (*Neo4jDriver)(nil)
+
+// Neo4jDriver records applied versions as SchemaMigration nodes. Neo4j does not allow schema
+// and data changes in one transaction, so every statement runs in its own.
+type Neo4jDriver struct {
+	driver neo4j.Driver
+}
+
+func NewNeo4jDriver(driver neo4j.Driver) *Neo4jDriver {
+	return &Neo4jDriver{
+		driver: driver,
+	}
+}
+
+func (d *Neo4jDriver) run(ctx context.Context, cypher string, params map[string]interface{}) (
+	[]*neo4j.Record,
+	error,
+) {
+	session := d.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer func() {
+		err := session.Close()
+		if err != nil {
+			logger.Get(ctx).WithError(err).Error("failed to close session")
+		}
+	}()
+
+	result, err := session.Run(cypher, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Collect()
+}
+
+func (d *Neo4jDriver) Init(ctx context.Context) error {
+	_, err := d.run(
+		ctx,
+		`CREATE CONSTRAINT schema_migration_version_unique IF NOT EXISTS
+		FOR (m:SchemaMigration)
+		REQUIRE m.version IS UNIQUE`,
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.run(
+		ctx,
+		`CREATE CONSTRAINT schema_migration_lock_name_unique IF NOT EXISTS
+		FOR (l:SchemaMigrationLock)
+		REQUIRE l.name IS UNIQUE`,
+		nil,
+	)
+
+	return err
+}
+
+// Lock claims the SchemaMigrationLock node, polling while another migrator holds it. Setting
+// claimedAt first takes the node's write lock, so the holder is read after any competing claim
+// has committed.
+func (d *Neo4jDriver) Lock(ctx context.Context) (func() error, error) {
+	holder := uuid.New().String()
+	for {
+		now := time.Now().UTC()
+		records, err := d.run(
+			ctx,
+			`MERGE (l:SchemaMigrationLock {name: 'migrate'})
+			SET l.claimedAt = $now
+			WITH l
+			WHERE l.holder IS NULL OR l.expires < $now
+			SET l.holder = $holder, l.expires = $expires
+			RETURN l.holder`,
+			map[string]interface{}{
+				"holder":  holder,
+				"now":     now,
+				"expires": now.Add(neo4jLockTTL),
+			},
+		)
+
+		var neo4jErr *neo4j.Neo4jError
+		if errors.As(err, &neo4jErr) && neo4jErr.Code == graphdb.SchemaFailedCode {
+			// Another migrator created the lock node first
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(records) > 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(neo4jLockPoll):
+		}
+	}
+
+	return func() error {
+		_, err := d.run(
+			context.Background(),
+			`MATCH (l:SchemaMigrationLock {name: 'migrate', holder: $holder}) DELETE l`,
+			map[string]interface{}{
+				"holder": holder,
+			},
+		)
+
+		return err
+	}, nil
+}
+
+func (d *Neo4jDriver) AppliedVersions(ctx context.Context) (map[int]bool, error) {
+	records, err := d.run(ctx, `MATCH (m:SchemaMigration) RETURN m.version`, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(records))
+	for _, record := range records {
+		applied[int(record.Values[0].(int64))] = true
+	}
+
+	return applied, nil
+}
+
+func (d *Neo4jDriver) Apply(
+	ctx context.Context,
+	migration *Migration,
+	statements []string,
+	up bool,
+) error {
+	for _, statement := range statements {
+		_, err := d.run(ctx, statement, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !up {
+		_, err := d.run(
+			ctx,
+			`MATCH (m:SchemaMigration {version: $version}) DELETE m`,
+			map[string]interface{}{
+				"version": migration.Version,
+			},
+		)
+
+		return err
+	}
+
+	_, err := d.run(
+		ctx,
+		`CREATE (m:SchemaMigration {version: $version, name: $name, appliedAt: $appliedAt})`,
+		map[string]interface{}{
+			"version":   migration.Version,
+			"name":      migration.Name,
+			"appliedAt": time.Now().UTC(),
+		},
+	)
+
+	return err
+}
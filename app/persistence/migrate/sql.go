@@ -0,0 +1,153 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var _ Driver = (*SQLDriver)(nil)
+
+// SQLDriver records applied versions in a schema_migrations table
+type SQLDriver struct {
+	db *gorm.DB
+	// createTable is the dialect specific DDL for schema_migrations
+	createTable string
+	// lockQuery takes the session level lock named by lockKey and returns 1 once held, an empty
+	// lockQuery leaves migrations unlocked
+	lockQuery   string
+	unlockQuery string
+	lockKey     interface{}
+}
+
+// migrationLockID is the Postgres advisory lock key, arbitrary but shared by every replica
+const migrationLockID int64 = 7_238_461_091
+
+func NewMySQLDriver(db *gorm.DB) *SQLDriver {
+	return &SQLDriver{
+		db: db,
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT       NOT NULL,
+			name       VARCHAR(255) NOT NULL,
+			applied_at DATETIME(3)  NOT NULL,
+			PRIMARY KEY (version)
+		)`,
+		lockQuery:   "SELECT GET_LOCK(?, -1)",
+		unlockQuery: "SELECT RELEASE_LOCK(?)",
+		lockKey:     "schema_migrations",
+	}
+}
+
+func NewPostgresDriver(db *gorm.DB) *SQLDriver {
+	return &SQLDriver{
+		db: db,
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT       NOT NULL,
+			name       VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMPTZ  NOT NULL,
+			PRIMARY KEY (version)
+		)`,
+		lockQuery:   "SELECT 1 FROM pg_advisory_lock($1)",
+		unlockQuery: "SELECT pg_advisory_unlock($1)",
+		lockKey:     migrationLockID,
+	}
+}
+
+// NewSQLiteDriver leaves migrations unlocked, a SQLite database belongs to a single process
+func NewSQLiteDriver(db *gorm.DB) *SQLDriver {
+	return &SQLDriver{
+		db: db,
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER      NOT NULL,
+			name       VARCHAR(255) NOT NULL,
+			applied_at DATETIME     NOT NULL,
+			PRIMARY KEY (version)
+		)`,
+	}
+}
+
+func (d *SQLDriver) Init(ctx context.Context) error {
+	return d.db.WithContext(ctx).Exec(d.createTable).Error
+}
+
+// Lock holds a connection for as long as the lock, which is tied to the database session
+func (d *SQLDriver) Lock(ctx context.Context) (func() error, error) {
+	if d.lockQuery == "" {
+		return func() error { return nil }, nil
+	}
+
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked int
+	err = conn.QueryRowContext(ctx, d.lockQuery, d.lockKey).Scan(&locked)
+	if err == nil && locked != 1 {
+		err = errors.New("lock not granted")
+	}
+	if err != nil {
+		return nil, errors.Join(err, conn.Close())
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), d.unlockQuery, d.lockKey)
+		return errors.Join(err, conn.Close())
+	}, nil
+}
+
+func (d *SQLDriver) AppliedVersions(ctx context.Context) (map[int]bool, error) {
+	versions := make([]int, 0)
+	result := d.db.WithContext(ctx).Raw("SELECT version FROM schema_migrations").Scan(&versions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	applied := make(map[int]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	return applied, nil
+}
+
+// Apply runs the migration in a transaction. MySQL commits DDL implicitly, so a failing statement
+// there leaves the earlier ones of the same migration in place.
+func (d *SQLDriver) Apply(
+	ctx context.Context,
+	migration *Migration,
+	statements []string,
+	up bool,
+) error {
+	return d.db.WithContext(ctx).Transaction(
+		func(tx *gorm.DB) error {
+			for _, statement := range statements {
+				err := tx.Exec(statement).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			if !up {
+				return tx.Exec(
+					"DELETE FROM schema_migrations WHERE version = ?",
+					migration.Version,
+				).Error
+			}
+
+			return tx.Exec(
+				"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version,
+				migration.Name,
+				time.Now().UTC(),
+			).Error
+		},
+	)
+}
@@ -0,0 +1,232 @@
+// Package remotesigner provides a crypto.Signer backed by a key held on the platform. Digests
+// are sent to the key's signing endpoint, so the private key never reaches the application. A
+// Signer can be used wherever Go takes a crypto.Signer, such as tls.Certificate.PrivateKey or
+// x509.CreateCertificate.
+package remotesigner
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+var _ crypto.Signer = (*Signer)(nil)
+
+// ErrUnsupportedOptions is returned for signer options the platform can't sign with
+var ErrUnsupportedOptions = errors.New("unsupported signer options")
+
+// hashNames are the hashes the signing endpoint accepts, by the names it knows them under
+var hashNames = map[crypto.Hash]string{
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+// StatusError is returned when the platform answers a request with anything but 200 OK
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf(
+		"remote signer: platform responded %d %s",
+		e.StatusCode,
+		http.StatusText(e.StatusCode),
+	)
+}
+
+type Config struct {
+	// BaseURL is where the platform API is served, such as https://signing.example.com
+	BaseURL string
+	// Token is a session ID of the user owning the key
+	Token       string
+	KeyID       string
+	KeyPassword string
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+type Signer struct {
+	config    Config
+	client    *http.Client
+	publicKey crypto.PublicKey
+}
+
+// New fetches the public half of the key, which the Signer hands out without going back to the
+// platform
+func New(ctx context.Context, config Config) (*Signer, error) {
+	s := &Signer{
+		config: config,
+		client: config.HTTPClient,
+	}
+
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		s.keyURL("download")+"?format=spki-pem",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("remote signer: public key is not valid PEM")
+	}
+
+	s.publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs digest on the platform, rand is ignored as the platform supplies its own
+// randomness. Ed25519 keys sign the message itself unless opts are ed25519.Options asking for
+// Ed25519ph.
+func (s *Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.SignContext(context.Background(), digest, opts)
+}
+
+// SignContext is Sign with a context bounding the request to the platform
+func (s *Signer) SignContext(
+	ctx context.Context,
+	digest []byte,
+	opts crypto.SignerOpts,
+) ([]byte, error) {
+	request, err := s.signDigestRequest(digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		s.keyURL("sign"),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	body, err = s.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &contracts.SignDigestResponse{}
+	err = json.Unmarshal(body, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(resp.Signature)
+}
+
+// signDigestRequest describes opts the way the signing endpoint expects
+func (s *Signer) signDigestRequest(
+	digest []byte,
+	opts crypto.SignerOpts,
+) (*contracts.SignDigestRequest, error) {
+	request := &contracts.SignDigestRequest{
+		KeyPassword: s.config.KeyPassword,
+		Digest:      base64.StdEncoding.EncodeToString(digest),
+	}
+
+	hash := opts.HashFunc()
+	if hash != 0 {
+		name, ok := hashNames[hash]
+		if !ok {
+			return nil, fmt.Errorf("%w: hash %s", ErrUnsupportedOptions, hash)
+		}
+
+		request.Hash = name
+	}
+
+	switch opts := opts.(type) {
+	case *rsa.PSSOptions:
+		request.Padding = "pss"
+
+		publicKey, ok := s.publicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: PSS needs an RSA key", ErrUnsupportedOptions)
+		}
+
+		switch opts.SaltLength {
+		case rsa.PSSSaltLengthEqualsHash:
+		case rsa.PSSSaltLengthAuto:
+			// Signing with the automatic length uses the largest salt that fits
+			request.SaltLength = (publicKey.N.BitLen()+6)/8 - hash.Size() - 2
+		default:
+			request.SaltLength = opts.SaltLength
+		}
+	case *ed25519.Options:
+		if opts.Context != "" {
+			return nil, fmt.Errorf("%w: Ed25519 contexts", ErrUnsupportedOptions)
+		}
+	default:
+		if _, ok := s.publicKey.(*rsa.PublicKey); ok {
+			request.Padding = "pkcs1v15"
+		}
+	}
+
+	return request, nil
+}
+
+func (s *Signer) keyURL(action string) string {
+	return strings.TrimSuffix(s.config.BaseURL, "/") + "/keys/" +
+		url.PathEscape(s.config.KeyID) + "/" + action
+}
+
+// do sends an authenticated request and returns the body of a 200 OK response
+func (s *Signer) do(req *http.Request) ([]byte, error) {
+	req.Header.Set("Authorization", s.config.Token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,325 @@
+package remotesigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fapiko/john-hancock-platform/app/context/request"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/controllers"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/services"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/support/gorilla"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+)
+
+const (
+	testUserEmail   = "remote.signer@example.com"
+	testKeyPassword = "key password"
+)
+
+// newTestPlatform serves the key controller routes from memory repositories holding key, and
+// returns a Signer for it
+func newTestPlatform(t *testing.T, key crypto.PrivateKey) *Signer {
+	ctx := context.Background()
+	userRepository := repositories.NewUserRepositoryMemory()
+	keyRepository := repositories.NewKeyRepositoryMemory()
+	certRepository := repositories.NewCertRepositoryMemory()
+	auditService := services.NewAuditServiceImpl(repositories.NewAuditRepositoryMemory(), "secret")
+	keyService := services.NewKeyServiceImpl(
+		keyRepository,
+		certRepository,
+		repositories.NewTransactorMemory(keyRepository, certRepository),
+		auditService,
+	)
+
+	user, err := userRepository.CreateUser(
+		ctx,
+		&contracts.CreateUserRequest{
+			FirstName: "Remote",
+			LastName:  "Signer",
+			Email:     testUserEmail,
+			Password:  "user password",
+		},
+	)
+	require.NoError(t, err)
+
+	session, err := userRepository.CreateSession(ctx, user.ID)
+	require.NoError(t, err)
+
+	stored, err := keyService.ImportPrivateKey(ctx, user.ID, "Remote", key, testKeyPassword)
+	require.NoError(t, err)
+
+	muxRouter := mux.NewRouter()
+	muxRouter.Use(request.Middleware)
+	router, err := swagger.NewRouter(
+		gorilla.NewRouter(muxRouter), swagger.Options{
+			Context: ctx,
+			Openapi: &openapi3.T{
+				Info: &openapi3.Info{
+					Title:   "Remote Signer Test",
+					Version: "1.0.0",
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	controllers.NewKeyController(
+		services.NewAuthService(userRepository, auditService),
+		keyService,
+		keyRepository,
+	).RegisterRoutes(ctx, router)
+
+	server := httptest.NewServer(muxRouter)
+	t.Cleanup(server.Close)
+
+	signer, err := New(
+		ctx,
+		Config{
+			BaseURL:     server.URL + "/",
+			Token:       session.ID,
+			KeyID:       stored.ID,
+			KeyPassword: testKeyPassword,
+			HTTPClient:  server.Client(),
+		},
+	)
+	require.NoError(t, err)
+
+	return signer
+}
+
+// selfSignedCertificate has signer sign a certificate for its own key, valid for 127.0.0.1
+func selfSignedCertificate(
+	t *testing.T,
+	signer crypto.Signer,
+	algorithm x509.SignatureAlgorithm,
+) *x509.Certificate {
+	return testutils.Certificate(
+		t,
+		&x509.Certificate{
+			Subject:            pkix.Name{CommonName: "Remote Signer Test"},
+			KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			IPAddresses:        []net.IP{net.IPv4(127, 0, 0, 1)},
+			SignatureAlgorithm: algorithm,
+			IsCA:               true,
+
+			BasicConstraintsValid: true,
+		},
+		signer,
+		nil,
+		nil,
+	)
+}
+
+var signerTests = []struct {
+	name      string
+	keyKind   string
+	algorithm x509.SignatureAlgorithm
+}{
+	{name: "RSA PKCS#1", keyKind: "rsa", algorithm: x509.SHA256WithRSA},
+	{name: "RSA PKCS#1 SHA-512", keyKind: "rsa", algorithm: x509.SHA512WithRSA},
+	{name: "RSA PSS", keyKind: "rsa", algorithm: x509.SHA256WithRSAPSS},
+	{name: "RSA PSS SHA-384", keyKind: "rsa", algorithm: x509.SHA384WithRSAPSS},
+	{name: "ECDSA", keyKind: "ecdsa", algorithm: x509.ECDSAWithSHA256},
+	{name: "ECDSA SHA-384", keyKind: "ecdsa", algorithm: x509.ECDSAWithSHA384},
+	{name: "Ed25519", keyKind: "ed25519", algorithm: x509.PureEd25519},
+}
+
+// newTestSigners starts a platform for a key of each kind the signer tests use
+func newTestSigners(t *testing.T) map[string]*Signer {
+	signers := map[string]*Signer{}
+	for _, kind := range []string{"rsa", "ecdsa", "ed25519"} {
+		key := testutils.Key(t, kind)
+		signers[kind] = newTestPlatform(t, key)
+
+		publicKey := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+		require.True(t, publicKey.Equal(signers[kind].Public()))
+	}
+
+	return signers
+}
+
+func TestSignerCreateCertificate(t *testing.T) {
+	signers := newTestSigners(t)
+
+	for _, test := range signerTests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				signer := signers[test.keyKind]
+				cert := selfSignedCertificate(t, signer, test.algorithm)
+				assert.Equal(t, test.algorithm, cert.SignatureAlgorithm)
+				assert.NoError(t, cert.CheckSignatureFrom(cert))
+			},
+		)
+	}
+}
+
+func TestSignerTLS(t *testing.T) {
+	signers := newTestSigners(t)
+	versions := map[string]uint16{"TLS 1.2": tls.VersionTLS12, "TLS 1.3": tls.VersionTLS13}
+
+	for _, test := range signerTests {
+		for versionName, version := range versions {
+			t.Run(
+				fmt.Sprintf("%s %s", test.name, versionName), func(t *testing.T) {
+					signer := signers[test.keyKind]
+					cert := selfSignedCertificate(t, signer, test.algorithm)
+
+					server := httptest.NewUnstartedServer(
+						http.HandlerFunc(
+							func(w http.ResponseWriter, r *http.Request) {
+								_, _ = io.WriteString(w, "signed remotely")
+							},
+						),
+					)
+					server.TLS = &tls.Config{
+						Certificates: []tls.Certificate{
+							{Certificate: [][]byte{cert.Raw}, PrivateKey: signer},
+						},
+					}
+					server.StartTLS()
+					defer server.Close()
+
+					roots := x509.NewCertPool()
+					roots.AddCert(cert)
+					client := &http.Client{
+						Transport: &http.Transport{
+							TLSClientConfig: &tls.Config{
+								RootCAs:    roots,
+								MinVersion: version,
+								MaxVersion: version,
+							},
+						},
+					}
+
+					resp, err := client.Get(server.URL)
+					require.NoError(t, err)
+					defer resp.Body.Close()
+
+					body, err := io.ReadAll(resp.Body)
+					require.NoError(t, err)
+					assert.Equal(t, "signed remotely", string(body))
+					assert.Equal(t, version, resp.TLS.Version)
+				},
+			)
+		}
+	}
+}
+
+func TestSignerRSAPSSSaltLength(t *testing.T) {
+	key := testutils.Key(t, "rsa").(*rsa.PrivateKey)
+	signer := newTestPlatform(t, key)
+	digest := sha256.Sum256([]byte("remote"))
+	maxSaltLength := (key.N.BitLen()+6)/8 - sha256.Size - 2
+
+	tests := []struct {
+		name       string
+		saltLength int
+		expected   int
+	}{
+		{name: "equals hash", saltLength: rsa.PSSSaltLengthEqualsHash, expected: sha256.Size},
+		{name: "auto", saltLength: rsa.PSSSaltLengthAuto, expected: maxSaltLength},
+		{name: "explicit", saltLength: 20, expected: 20},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				signature, err := signer.Sign(
+					rand.Reader,
+					digest[:],
+					&rsa.PSSOptions{SaltLength: test.saltLength, Hash: crypto.SHA256},
+				)
+				require.NoError(t, err)
+
+				assert.NoError(
+					t,
+					rsa.VerifyPSS(
+						&key.PublicKey,
+						crypto.SHA256,
+						digest[:],
+						signature,
+						&rsa.PSSOptions{SaltLength: test.expected},
+					),
+				)
+			},
+		)
+	}
+}
+
+func TestSignerEd25519(t *testing.T) {
+	key := testutils.Key(t, "ed25519")
+	publicKey := key.Public().(ed25519.PublicKey)
+	signer := newTestPlatform(t, key)
+	message := []byte("remote")
+	digest := sha512.Sum512(message)
+
+	signature, err := signer.Sign(rand.Reader, message, crypto.Hash(0))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(publicKey, message, signature), "Ed25519")
+
+	opts := &ed25519.Options{Hash: crypto.SHA512}
+	signature, err = signer.Sign(rand.Reader, digest[:], opts)
+	require.NoError(t, err)
+	assert.NoError(t, ed25519.VerifyWithOptions(publicKey, digest[:], signature, opts), "Ed25519ph")
+
+	_, err = signer.Sign(
+		rand.Reader,
+		message,
+		&ed25519.Options{Context: "remote signer test"},
+	)
+	assert.ErrorIs(t, err, ErrUnsupportedOptions, "Ed25519ctx")
+}
+
+func TestSignerErrors(t *testing.T) {
+	key := testutils.Key(t, "ecdsa")
+	signer := newTestPlatform(t, key)
+	digest := sha256.Sum256([]byte("remote"))
+
+	_, err := signer.Sign(rand.Reader, digest[:20], crypto.SHA1)
+	assert.ErrorIs(t, err, ErrUnsupportedOptions, "SHA-1")
+
+	_, err = signer.Sign(
+		rand.Reader,
+		digest[:],
+		&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: crypto.SHA256},
+	)
+	assert.ErrorIs(t, err, ErrUnsupportedOptions, "PSS with an ECDSA key")
+
+	_, err = signer.Sign(rand.Reader, digest[:16], crypto.SHA256)
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr, "digest of the wrong length")
+	assert.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+
+	wrongPassword := *signer
+	wrongPassword.config.KeyPassword = "wrong"
+	_, err = wrongPassword.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.ErrorAs(t, err, &statusErr, "wrong key password")
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+
+	_, err = New(context.Background(), Config{BaseURL: signer.config.BaseURL, KeyID: "key"})
+	require.ErrorAs(t, err, &statusErr, "no session")
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+}
@@ -0,0 +1,416 @@
+package repositories
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ AcmeRepository = (*AcmeRepositoryMemory)(nil)
+
+type AcmeRepositoryMemory struct {
+	memoryTransactional
+
+	mu             sync.RWMutex
+	directories    map[string]daos.AcmeDirectory
+	nonces         map[string]time.Time
+	accounts       map[string]daos.AcmeAccount
+	orders         map[string]daos.AcmeOrder
+	authorizations map[string]daos.AcmeAuthorization
+	challenges     map[string]daos.AcmeChallenge
+}
+
+func NewAcmeRepositoryMemory() *AcmeRepositoryMemory {
+	return &AcmeRepositoryMemory{
+		directories:    make(map[string]daos.AcmeDirectory),
+		nonces:         make(map[string]time.Time),
+		accounts:       make(map[string]daos.AcmeAccount),
+		orders:         make(map[string]daos.AcmeOrder),
+		authorizations: make(map[string]daos.AcmeAuthorization),
+		challenges:     make(map[string]daos.AcmeChallenge),
+	}
+}
+
+func (a *AcmeRepositoryMemory) snapshot() func() {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	directories := maps.Clone(a.directories)
+	nonces := maps.Clone(a.nonces)
+	accounts := maps.Clone(a.accounts)
+	orders := maps.Clone(a.orders)
+	authorizations := maps.Clone(a.authorizations)
+	challenges := maps.Clone(a.challenges)
+
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		a.directories = directories
+		a.nonces = nonces
+		a.accounts = accounts
+		a.orders = orders
+		a.authorizations = authorizations
+		a.challenges = challenges
+	}
+}
+
+func (a *AcmeRepositoryMemory) SaveDirectory(
+	ctx context.Context,
+	directory *daos.AcmeDirectory,
+) error {
+	if directory.Created.IsZero() {
+		directory.Created = time.Now()
+	}
+
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.directories[directory.CertificateID] = *directory
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) GetDirectory(
+	ctx context.Context,
+	caID string,
+) (*daos.AcmeDirectory, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	directory, ok := a.directories[caID]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &directory, nil
+}
+
+func (a *AcmeRepositoryMemory) DeleteDirectory(ctx context.Context, caID string) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.directories, caID)
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) CreateNonce(ctx context.Context, value string) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.nonces[value]; ok {
+		return ErrDuplicateRecord
+	}
+
+	a.nonces[value] = time.Now()
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) ConsumeNonce(
+	ctx context.Context,
+	value string,
+	issuedAfter time.Time,
+) (bool, error) {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	created, ok := a.nonces[value]
+	if !ok || !created.After(issuedAfter) {
+		return false, nil
+	}
+
+	delete(a.nonces, value)
+
+	return true, nil
+}
+
+func (a *AcmeRepositoryMemory) DeleteNoncesCreatedBefore(
+	ctx context.Context,
+	before time.Time,
+) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for value, created := range a.nonces {
+		if !created.After(before) {
+			delete(a.nonces, value)
+		}
+	}
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) CreateAccount(ctx context.Context, account *daos.AcmeAccount) error {
+	account.ID = uuid.New().String()
+	account.Created = time.Now()
+
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.accounts[account.ID] = *account
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) GetAccount(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeAccount, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	account, ok := a.accounts[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &account, nil
+}
+
+func (a *AcmeRepositoryMemory) GetAccountByThumbprint(
+	ctx context.Context,
+	caID string,
+	thumbprint string,
+) (*daos.AcmeAccount, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, account := range a.accounts {
+		if account.CertificateID == caID && account.Thumbprint == thumbprint {
+			return &account, nil
+		}
+	}
+
+	return nil, ErrNoRecord
+}
+
+func (a *AcmeRepositoryMemory) UpdateAccount(ctx context.Context, account *daos.AcmeAccount) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.accounts[account.ID] = *account
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) CreateOrder(
+	ctx context.Context,
+	order *daos.AcmeOrder,
+	authorizations []*daos.AcmeAuthorization,
+	challenges [][]*daos.AcmeChallenge,
+) error {
+	now := time.Now()
+	order.ID = uuid.New().String()
+	order.Created = now
+
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.orders[order.ID] = *order
+
+	for i, authorization := range authorizations {
+		authorization.ID = uuid.New().String()
+		authorization.OrderID = order.ID
+		authorization.Created = now
+		a.authorizations[authorization.ID] = *authorization
+
+		for _, challenge := range challenges[i] {
+			challenge.ID = uuid.New().String()
+			challenge.AuthorizationID = authorization.ID
+			challenge.Created = now
+			a.challenges[challenge.ID] = *challenge
+		}
+	}
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) GetOrder(ctx context.Context, id string) (*daos.AcmeOrder, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	order, ok := a.orders[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &order, nil
+}
+
+func (a *AcmeRepositoryMemory) GetOrdersByAccount(
+	ctx context.Context,
+	accountID string,
+) ([]*daos.AcmeOrder, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	orders := make([]*daos.AcmeOrder, 0)
+	for _, order := range a.orders {
+		if order.AccountID == accountID {
+			order := order
+			orders = append(orders, &order)
+		}
+	}
+
+	sort.Slice(
+		orders, func(i, j int) bool {
+			return orders[i].Created.Before(orders[j].Created)
+		},
+	)
+
+	return orders, nil
+}
+
+func (a *AcmeRepositoryMemory) UpdateOrder(ctx context.Context, order *daos.AcmeOrder) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.orders[order.ID] = *order
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) UpdateOrderStatus(
+	ctx context.Context,
+	id string,
+	fromStatus string,
+	toStatus string,
+) (bool, error) {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	order, ok := a.orders[id]
+	if !ok || order.Status != fromStatus {
+		return false, nil
+	}
+
+	order.Status = toStatus
+	a.orders[id] = order
+
+	return true, nil
+}
+
+func (a *AcmeRepositoryMemory) GetAuthorization(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeAuthorization, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	authorization, ok := a.authorizations[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &authorization, nil
+}
+
+func (a *AcmeRepositoryMemory) GetAuthorizationsByOrder(
+	ctx context.Context,
+	orderID string,
+) ([]*daos.AcmeAuthorization, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	authorizations := make([]*daos.AcmeAuthorization, 0)
+	for _, authorization := range a.authorizations {
+		if authorization.OrderID == orderID {
+			authorization := authorization
+			authorizations = append(authorizations, &authorization)
+		}
+	}
+
+	sort.Slice(
+		authorizations, func(i, j int) bool {
+			if !authorizations[i].Created.Equal(authorizations[j].Created) {
+				return authorizations[i].Created.Before(authorizations[j].Created)
+			}
+
+			return authorizations[i].IdentifierValue < authorizations[j].IdentifierValue
+		},
+	)
+
+	return authorizations, nil
+}
+
+func (a *AcmeRepositoryMemory) UpdateAuthorization(
+	ctx context.Context,
+	authorization *daos.AcmeAuthorization,
+) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.authorizations[authorization.ID] = *authorization
+
+	return nil
+}
+
+func (a *AcmeRepositoryMemory) GetChallenge(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeChallenge, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	challenge, ok := a.challenges[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &challenge, nil
+}
+
+func (a *AcmeRepositoryMemory) GetChallengesByAuthorization(
+	ctx context.Context,
+	authorizationID string,
+) ([]*daos.AcmeChallenge, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	challenges := make([]*daos.AcmeChallenge, 0)
+	for _, challenge := range a.challenges {
+		if challenge.AuthorizationID == authorizationID {
+			challenge := challenge
+			challenges = append(challenges, &challenge)
+		}
+	}
+
+	sort.Slice(
+		challenges, func(i, j int) bool {
+			return challenges[i].Type < challenges[j].Type
+		},
+	)
+
+	return challenges, nil
+}
+
+func (a *AcmeRepositoryMemory) UpdateChallenge(
+	ctx context.Context,
+	challenge *daos.AcmeChallenge,
+) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.challenges[challenge.ID] = *challenge
+
+	return nil
+}
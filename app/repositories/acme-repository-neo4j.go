@@ -0,0 +1,411 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ AcmeRepository = (*AcmeRepositoryNeo4j)(nil)
+
+// AcmeRepositoryNeo4j keeps orders linked to their authorizations with HAS_AUTHORIZATION and
+// authorizations to their challenges with HAS_CHALLENGE. Directories, accounts and orders refer
+// to their CA and account by property, as the SQL tables do.
+type AcmeRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewAcmeRepositoryNeo4j(driver neo4j.Driver) *AcmeRepositoryNeo4j {
+	return &AcmeRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (a *AcmeRepositoryNeo4j) SaveDirectory(
+	ctx context.Context,
+	directory *daos.AcmeDirectory,
+) error {
+	if directory.Created.IsZero() {
+		directory.Created = time.Now()
+	}
+
+	cypher := `MERGE (d:AcmeDirectory {certificateID: $certificateID})
+				SET d = $props`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": directory.CertificateID,
+			"props":         directory.Props(),
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) GetDirectory(
+	ctx context.Context,
+	caID string,
+) (*daos.AcmeDirectory, error) {
+	cypher := `MATCH (d:AcmeDirectory {certificateID: $certificateID}) RETURN d`
+	record, err := neo4jReadTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": caID,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAcmeDirectoryFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AcmeRepositoryNeo4j) DeleteDirectory(ctx context.Context, caID string) error {
+	cypher := `MATCH (d:AcmeDirectory {certificateID: $certificateID}) DELETE d`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": caID,
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) CreateNonce(ctx context.Context, value string) error {
+	cypher := `CREATE (n:AcmeNonce {value: $value, created: $created})`
+	err := neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"value":   value,
+			"created": time.Now().In(time.UTC),
+		},
+	)
+
+	return neo4jDuplicate(err)
+}
+
+func (a *AcmeRepositoryNeo4j) ConsumeNonce(
+	ctx context.Context,
+	value string,
+	issuedAfter time.Time,
+) (bool, error) {
+	cypher := `OPTIONAL MATCH (n:AcmeNonce {value: $value})
+				WHERE n.created > $issuedAfter
+				DELETE n
+				RETURN count(n)`
+	record, err := neo4jWriteTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"value":       value,
+			"issuedAfter": issuedAfter.In(time.UTC),
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return record.Values[0].(int64) == 1, nil
+}
+
+func (a *AcmeRepositoryNeo4j) DeleteNoncesCreatedBefore(
+	ctx context.Context,
+	before time.Time,
+) error {
+	cypher := `MATCH (n:AcmeNonce) WHERE n.created <= $before DELETE n`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"before": before.In(time.UTC),
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) CreateAccount(ctx context.Context, account *daos.AcmeAccount) error {
+	account.ID = uuid.New().String()
+	account.Created = time.Now()
+
+	cypher := `CREATE (a:AcmeAccount) SET a = $props`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"props": account.Props(),
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) GetAccount(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeAccount, error) {
+	cypher := `MATCH (a:AcmeAccount {uuid: $uuid}) RETURN a`
+	record, err := neo4jReadTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAcmeAccountFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AcmeRepositoryNeo4j) GetAccountByThumbprint(
+	ctx context.Context,
+	caID string,
+	thumbprint string,
+) (*daos.AcmeAccount, error) {
+	cypher := `MATCH (a:AcmeAccount {certificateID: $certificateID, thumbprint: $thumbprint})
+				RETURN a LIMIT 1`
+	record, err := neo4jReadTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": caID,
+			"thumbprint":    thumbprint,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAcmeAccountFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AcmeRepositoryNeo4j) UpdateAccount(ctx context.Context, account *daos.AcmeAccount) error {
+	cypher := `MATCH (a:AcmeAccount {uuid: $uuid}) SET a = $props`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid":  account.ID,
+			"props": account.Props(),
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) CreateOrder(
+	ctx context.Context,
+	order *daos.AcmeOrder,
+	authorizations []*daos.AcmeAuthorization,
+	challenges [][]*daos.AcmeChallenge,
+) error {
+	now := time.Now()
+	order.ID = uuid.New().String()
+	order.Created = now
+
+	authorizationParams := make([]interface{}, len(authorizations))
+	for i, authorization := range authorizations {
+		authorization.ID = uuid.New().String()
+		authorization.OrderID = order.ID
+		authorization.Created = now
+
+		challengeParams := make([]interface{}, len(challenges[i]))
+		for j, challenge := range challenges[i] {
+			challenge.ID = uuid.New().String()
+			challenge.AuthorizationID = authorization.ID
+			challenge.Created = now
+			challengeParams[j] = challenge.Props()
+		}
+
+		authorizationParams[i] = map[string]interface{}{
+			"props":      authorization.Props(),
+			"challenges": challengeParams,
+		}
+	}
+
+	// One statement, so the order is never stored without its authorizations
+	cypher := `CREATE (o:AcmeOrder)
+				SET o = $order
+				WITH o
+				UNWIND $authorizations AS authorization
+				CREATE (o)-[:HAS_AUTHORIZATION]->(a:AcmeAuthorization)
+				SET a = authorization.props
+				WITH a, authorization
+				UNWIND authorization.challenges AS challenge
+				CREATE (a)-[:HAS_CHALLENGE]->(c:AcmeChallenge)
+				SET c = challenge`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"order":          order.Props(),
+			"authorizations": authorizationParams,
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) GetOrder(ctx context.Context, id string) (*daos.AcmeOrder, error) {
+	cypher := `MATCH (o:AcmeOrder {uuid: $uuid}) RETURN o`
+	record, err := neo4jReadTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAcmeOrderFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AcmeRepositoryNeo4j) GetOrdersByAccount(
+	ctx context.Context,
+	accountID string,
+) ([]*daos.AcmeOrder, error) {
+	cypher := `MATCH (o:AcmeOrder {accountID: $accountID}) RETURN o ORDER BY o.created`
+	records, err := neo4jReadTxCollect(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"accountID": accountID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*daos.AcmeOrder, len(records))
+	for i, record := range records {
+		orders[i] = daos.NewAcmeOrderFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return orders, nil
+}
+
+func (a *AcmeRepositoryNeo4j) UpdateOrder(ctx context.Context, order *daos.AcmeOrder) error {
+	cypher := `MATCH (o:AcmeOrder {uuid: $uuid}) SET o = $props`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid":  order.ID,
+			"props": order.Props(),
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) UpdateOrderStatus(
+	ctx context.Context,
+	id string,
+	fromStatus string,
+	toStatus string,
+) (bool, error) {
+	cypher := `OPTIONAL MATCH (o:AcmeOrder {uuid: $uuid})
+				WHERE o.status = $fromStatus
+				SET o.status = $toStatus
+				RETURN count(o)`
+	record, err := neo4jWriteTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid":       id,
+			"fromStatus": fromStatus,
+			"toStatus":   toStatus,
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return record.Values[0].(int64) == 1, nil
+}
+
+func (a *AcmeRepositoryNeo4j) GetAuthorization(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeAuthorization, error) {
+	cypher := `MATCH (a:AcmeAuthorization {uuid: $uuid}) RETURN a`
+	record, err := neo4jReadTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAcmeAuthorizationFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AcmeRepositoryNeo4j) GetAuthorizationsByOrder(
+	ctx context.Context,
+	orderID string,
+) ([]*daos.AcmeAuthorization, error) {
+	cypher := `MATCH (:AcmeOrder {uuid: $orderID})-[:HAS_AUTHORIZATION]->(a:AcmeAuthorization)
+				RETURN a ORDER BY a.created, a.identifierValue`
+	records, err := neo4jReadTxCollect(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"orderID": orderID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizations := make([]*daos.AcmeAuthorization, len(records))
+	for i, record := range records {
+		authorizations[i] = daos.NewAcmeAuthorizationFromProps(
+			record.Values[0].(neo4j.Node).Props,
+		)
+	}
+
+	return authorizations, nil
+}
+
+func (a *AcmeRepositoryNeo4j) UpdateAuthorization(
+	ctx context.Context,
+	authorization *daos.AcmeAuthorization,
+) error {
+	cypher := `MATCH (a:AcmeAuthorization {uuid: $uuid}) SET a = $props`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid":  authorization.ID,
+			"props": authorization.Props(),
+		},
+	)
+}
+
+func (a *AcmeRepositoryNeo4j) GetChallenge(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeChallenge, error) {
+	cypher := `MATCH (c:AcmeChallenge {uuid: $uuid}) RETURN c`
+	record, err := neo4jReadTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAcmeChallengeFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AcmeRepositoryNeo4j) GetChallengesByAuthorization(
+	ctx context.Context,
+	authorizationID string,
+) ([]*daos.AcmeChallenge, error) {
+	cypher := `MATCH (:AcmeAuthorization {uuid: $authorizationID})-[:HAS_CHALLENGE]->
+					(c:AcmeChallenge)
+				RETURN c ORDER BY c.type`
+	records, err := neo4jReadTxCollect(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"authorizationID": authorizationID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	challenges := make([]*daos.AcmeChallenge, len(records))
+	for i, record := range records {
+		challenges[i] = daos.NewAcmeChallengeFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return challenges, nil
+}
+
+func (a *AcmeRepositoryNeo4j) UpdateChallenge(
+	ctx context.Context,
+	challenge *daos.AcmeChallenge,
+) error {
+	cypher := `MATCH (c:AcmeChallenge {uuid: $uuid}) SET c = $props`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"uuid":  challenge.ID,
+			"props": challenge.Props(),
+		},
+	)
+}
@@ -0,0 +1,247 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ AcmeRepository = (*AcmeRepositorySQL)(nil)
+
+type AcmeRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewAcmeRepositorySQL(db *gorm.DB) *AcmeRepositorySQL {
+	return &AcmeRepositorySQL{
+		db: db,
+	}
+}
+
+func (a *AcmeRepositorySQL) SaveDirectory(
+	ctx context.Context,
+	directory *daos.AcmeDirectory,
+) error {
+	if directory.Created.IsZero() {
+		directory.Created = time.Now()
+	}
+
+	return gormDB(ctx, a.db).Save(directory).Error
+}
+
+func (a *AcmeRepositorySQL) GetDirectory(
+	ctx context.Context,
+	caID string,
+) (*daos.AcmeDirectory, error) {
+	directory := &daos.AcmeDirectory{}
+	result := gormDB(ctx, a.db).Where("certificate_id = ?", caID).First(directory)
+
+	return directory, convertNotFound(result.Error)
+}
+
+func (a *AcmeRepositorySQL) DeleteDirectory(ctx context.Context, caID string) error {
+	result := gormDB(ctx, a.db).Delete(&daos.AcmeDirectory{CertificateID: caID})
+
+	return result.Error
+}
+
+func (a *AcmeRepositorySQL) CreateNonce(ctx context.Context, value string) error {
+	return gormDB(ctx, a.db).Create(
+		&daos.AcmeNonce{
+			Value:   value,
+			Created: time.Now(),
+		},
+	).Error
+}
+
+func (a *AcmeRepositorySQL) ConsumeNonce(
+	ctx context.Context,
+	value string,
+	issuedAfter time.Time,
+) (bool, error) {
+	result := gormDB(ctx, a.db).
+		Where("value = ? AND created > ?", value, issuedAfter).
+		Delete(&daos.AcmeNonce{})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (a *AcmeRepositorySQL) DeleteNoncesCreatedBefore(
+	ctx context.Context,
+	before time.Time,
+) error {
+	return gormDB(ctx, a.db).Where("created <= ?", before).Delete(&daos.AcmeNonce{}).Error
+}
+
+func (a *AcmeRepositorySQL) CreateAccount(ctx context.Context, account *daos.AcmeAccount) error {
+	account.ID = uuid.New().String()
+	account.Created = time.Now()
+
+	return gormDB(ctx, a.db).Create(account).Error
+}
+
+func (a *AcmeRepositorySQL) GetAccount(ctx context.Context, id string) (*daos.AcmeAccount, error) {
+	account := &daos.AcmeAccount{}
+	result := gormDB(ctx, a.db).Where("id = ?", id).First(account)
+
+	return account, convertNotFound(result.Error)
+}
+
+func (a *AcmeRepositorySQL) GetAccountByThumbprint(
+	ctx context.Context,
+	caID string,
+	thumbprint string,
+) (*daos.AcmeAccount, error) {
+	account := &daos.AcmeAccount{}
+	result := gormDB(ctx, a.db).
+		Where("certificate_id = ? AND thumbprint = ?", caID, thumbprint).
+		First(account)
+
+	return account, convertNotFound(result.Error)
+}
+
+func (a *AcmeRepositorySQL) UpdateAccount(ctx context.Context, account *daos.AcmeAccount) error {
+	return gormDB(ctx, a.db).Save(account).Error
+}
+
+func (a *AcmeRepositorySQL) CreateOrder(
+	ctx context.Context,
+	order *daos.AcmeOrder,
+	authorizations []*daos.AcmeAuthorization,
+	challenges [][]*daos.AcmeChallenge,
+) error {
+	now := time.Now()
+	order.ID = uuid.New().String()
+	order.Created = now
+
+	return gormDB(ctx, a.db).Transaction(
+		func(tx *gorm.DB) error {
+			err := tx.Create(order).Error
+			if err != nil {
+				return err
+			}
+
+			for i, authorization := range authorizations {
+				authorization.ID = uuid.New().String()
+				authorization.OrderID = order.ID
+				authorization.Created = now
+
+				err = tx.Create(authorization).Error
+				if err != nil {
+					return err
+				}
+
+				for _, challenge := range challenges[i] {
+					challenge.ID = uuid.New().String()
+					challenge.AuthorizationID = authorization.ID
+					challenge.Created = now
+
+					err = tx.Create(challenge).Error
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		},
+	)
+}
+
+func (a *AcmeRepositorySQL) GetOrder(ctx context.Context, id string) (*daos.AcmeOrder, error) {
+	order := &daos.AcmeOrder{}
+	result := gormDB(ctx, a.db).Where("id = ?", id).First(order)
+
+	return order, convertNotFound(result.Error)
+}
+
+func (a *AcmeRepositorySQL) GetOrdersByAccount(
+	ctx context.Context,
+	accountID string,
+) ([]*daos.AcmeOrder, error) {
+	orders := make([]*daos.AcmeOrder, 0)
+	result := gormDB(ctx, a.db).Where("account_id = ?", accountID).Find(&orders)
+
+	return orders, result.Error
+}
+
+func (a *AcmeRepositorySQL) UpdateOrder(ctx context.Context, order *daos.AcmeOrder) error {
+	return gormDB(ctx, a.db).Save(order).Error
+}
+
+func (a *AcmeRepositorySQL) UpdateOrderStatus(
+	ctx context.Context,
+	id string,
+	fromStatus string,
+	toStatus string,
+) (bool, error) {
+	result := gormDB(ctx, a.db).
+		Model(&daos.AcmeOrder{}).
+		Where("id = ? AND status = ?", id, fromStatus).
+		Update("status", toStatus)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (a *AcmeRepositorySQL) GetAuthorization(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeAuthorization, error) {
+	authorization := &daos.AcmeAuthorization{}
+	result := gormDB(ctx, a.db).Where("id = ?", id).First(authorization)
+
+	return authorization, convertNotFound(result.Error)
+}
+
+func (a *AcmeRepositorySQL) GetAuthorizationsByOrder(
+	ctx context.Context,
+	orderID string,
+) ([]*daos.AcmeAuthorization, error) {
+	authorizations := make([]*daos.AcmeAuthorization, 0)
+	result := gormDB(ctx, a.db).
+		Where("order_id = ?", orderID).
+		Order("created, identifier_value").
+		Find(&authorizations)
+
+	return authorizations, result.Error
+}
+
+func (a *AcmeRepositorySQL) UpdateAuthorization(
+	ctx context.Context,
+	authorization *daos.AcmeAuthorization,
+) error {
+	return gormDB(ctx, a.db).Save(authorization).Error
+}
+
+func (a *AcmeRepositorySQL) GetChallenge(
+	ctx context.Context,
+	id string,
+) (*daos.AcmeChallenge, error) {
+	challenge := &daos.AcmeChallenge{}
+	result := gormDB(ctx, a.db).Where("id = ?", id).First(challenge)
+
+	return challenge, convertNotFound(result.Error)
+}
+
+func (a *AcmeRepositorySQL) GetChallengesByAuthorization(
+	ctx context.Context,
+	authorizationID string,
+) ([]*daos.AcmeChallenge, error) {
+	challenges := make([]*daos.AcmeChallenge, 0)
+	result := gormDB(ctx, a.db).
+		Where("authorization_id = ?", authorizationID).
+		Order("type").
+		Find(&challenges)
+
+	return challenges, result.Error
+}
+
+func (a *AcmeRepositorySQL) UpdateChallenge(
+	ctx context.Context,
+	challenge *daos.AcmeChallenge,
+) error {
+	return gormDB(ctx, a.db).Save(challenge).Error
+}
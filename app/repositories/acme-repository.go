@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+type AcmeRepository interface {
+	SaveDirectory(ctx context.Context, directory *daos.AcmeDirectory) error
+	GetDirectory(ctx context.Context, caID string) (*daos.AcmeDirectory, error)
+	DeleteDirectory(ctx context.Context, caID string) error
+
+	CreateNonce(ctx context.Context, value string) error
+	// ConsumeNonce deletes the nonce, returning false if it was never issued or already used
+	ConsumeNonce(ctx context.Context, value string, issuedAfter time.Time) (bool, error)
+	DeleteNoncesCreatedBefore(ctx context.Context, before time.Time) error
+
+	CreateAccount(ctx context.Context, account *daos.AcmeAccount) error
+	GetAccount(ctx context.Context, id string) (*daos.AcmeAccount, error)
+	GetAccountByThumbprint(
+		ctx context.Context,
+		caID string,
+		thumbprint string,
+	) (*daos.AcmeAccount, error)
+	UpdateAccount(ctx context.Context, account *daos.AcmeAccount) error
+
+	// CreateOrder stores a new order along with its authorizations, challenges[i] holding the
+	// challenges for authorizations[i]
+	CreateOrder(
+		ctx context.Context,
+		order *daos.AcmeOrder,
+		authorizations []*daos.AcmeAuthorization,
+		challenges [][]*daos.AcmeChallenge,
+	) error
+	GetOrder(ctx context.Context, id string) (*daos.AcmeOrder, error)
+	GetOrdersByAccount(ctx context.Context, accountID string) ([]*daos.AcmeOrder, error)
+	UpdateOrder(ctx context.Context, order *daos.AcmeOrder) error
+	// UpdateOrderStatus moves an order from one status to another, returning false if it was no
+	// longer in fromStatus
+	UpdateOrderStatus(
+		ctx context.Context,
+		id string,
+		fromStatus string,
+		toStatus string,
+	) (bool, error)
+
+	GetAuthorization(ctx context.Context, id string) (*daos.AcmeAuthorization, error)
+	GetAuthorizationsByOrder(
+		ctx context.Context,
+		orderID string,
+	) ([]*daos.AcmeAuthorization, error)
+	UpdateAuthorization(ctx context.Context, authorization *daos.AcmeAuthorization) error
+
+	GetChallenge(ctx context.Context, id string) (*daos.AcmeChallenge, error)
+	GetChallengesByAuthorization(
+		ctx context.Context,
+		authorizationID string,
+	) ([]*daos.AcmeChallenge, error)
+	UpdateChallenge(ctx context.Context, challenge *daos.AcmeChallenge) error
+}
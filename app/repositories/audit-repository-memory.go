@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+var _ AuditRepository = (*AuditRepositoryMemory)(nil)
+
+// AuditRepositoryMemory keeps entries in sequence order. It is deliberately left out of
+// TransactorMemory so rolled back operations keep their entries.
+type AuditRepositoryMemory struct {
+	mu      sync.RWMutex
+	entries []daos.AuditEntry
+}
+
+func NewAuditRepositoryMemory() *AuditRepositoryMemory {
+	return &AuditRepositoryMemory{
+		entries: make([]daos.AuditEntry, 0),
+	}
+}
+
+func (a *AuditRepositoryMemory) CreateEntry(ctx context.Context, entry *daos.AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Entries only ever get appended at the head, so anything at or below it is taken
+	if len(a.entries) > 0 && entry.Sequence <= a.entries[len(a.entries)-1].Sequence {
+		return ErrDuplicateRecord
+	}
+
+	a.entries = append(a.entries, *entry)
+
+	return nil
+}
+
+func (a *AuditRepositoryMemory) GetLatestEntry(ctx context.Context) (*daos.AuditEntry, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if len(a.entries) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	entry := a.entries[len(a.entries)-1]
+
+	return &entry, nil
+}
+
+func (a *AuditRepositoryMemory) GetEntries(
+	ctx context.Context,
+	filter *AuditFilter,
+) ([]*daos.AuditEntry, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries := make([]*daos.AuditEntry, 0)
+	for i := len(a.entries) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+
+		entry := a.entries[i]
+		if auditFilterMatches(filter, &entry) {
+			entries = append(entries, &entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func (a *AuditRepositoryMemory) GetEntriesAfter(
+	ctx context.Context,
+	afterSequence int64,
+	limit int,
+) ([]*daos.AuditEntry, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries := make([]*daos.AuditEntry, 0)
+	for _, entry := range a.entries {
+		if len(entries) == limit {
+			break
+		}
+
+		if entry.Sequence > afterSequence {
+			entry := entry
+			entries = append(entries, &entry)
+		}
+	}
+
+	return entries, nil
+}
+
+func auditFilterMatches(filter *AuditFilter, entry *daos.AuditEntry) bool {
+	return (filter.ActorID == "" || entry.ActorID == filter.ActorID) &&
+		(filter.Action == "" || entry.Action == filter.Action) &&
+		(filter.TargetType == "" || entry.TargetType == filter.TargetType) &&
+		(filter.TargetID == "" || entry.TargetID == filter.TargetID) &&
+		(filter.Outcome == "" || entry.Outcome == filter.Outcome) &&
+		(filter.Since.IsZero() || !entry.Timestamp.Before(filter.Since)) &&
+		(filter.Until.IsZero() || entry.Timestamp.Before(filter.Until)) &&
+		(filter.BeforeSequence == 0 || entry.Sequence < filter.BeforeSequence)
+}
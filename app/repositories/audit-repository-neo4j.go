@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ AuditRepository = (*AuditRepositoryNeo4j)(nil)
+
+type AuditRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewAuditRepositoryNeo4j(driver neo4j.Driver) *AuditRepositoryNeo4j {
+	return &AuditRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (a *AuditRepositoryNeo4j) CreateEntry(ctx context.Context, entry *daos.AuditEntry) error {
+	cypher := `CREATE (e:AuditEntry) SET e = $props`
+	err := neo4jWriteTx(
+		withoutTransaction(ctx), a.driver, cypher, map[string]interface{}{
+			"props": entry.Props(),
+		},
+	)
+
+	return neo4jDuplicate(err)
+}
+
+func (a *AuditRepositoryNeo4j) GetLatestEntry(ctx context.Context) (*daos.AuditEntry, error) {
+	cypher := `MATCH (e:AuditEntry) RETURN e ORDER BY e.sequence DESC LIMIT 1`
+	record, err := neo4jReadTxSingle(
+		withoutTransaction(ctx), a.driver, cypher, map[string]interface{}{},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAuditEntryFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AuditRepositoryNeo4j) GetEntries(
+	ctx context.Context,
+	filter *AuditFilter,
+) ([]*daos.AuditEntry, error) {
+	conditions := make([]string, 0)
+	params := map[string]interface{}{}
+
+	equals := map[string]string{
+		"actorID":    filter.ActorID,
+		"action":     filter.Action,
+		"targetType": filter.TargetType,
+		"targetID":   filter.TargetID,
+		"outcome":    filter.Outcome,
+	}
+	for prop, value := range equals {
+		if value != "" {
+			conditions = append(conditions, fmt.Sprintf("e.%s = $%s", prop, prop))
+			params[prop] = value
+		}
+	}
+
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "e.timestamp >= $since")
+		params["since"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "e.timestamp < $until")
+		params["until"] = filter.Until
+	}
+	if filter.BeforeSequence > 0 {
+		conditions = append(conditions, "e.sequence < $before")
+		params["before"] = filter.BeforeSequence
+	}
+
+	cypher := `MATCH (e:AuditEntry)`
+	if len(conditions) > 0 {
+		cypher += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	cypher += ` RETURN e ORDER BY e.sequence DESC`
+	if filter.Limit > 0 {
+		cypher += ` LIMIT $limit`
+		params["limit"] = filter.Limit
+	}
+
+	return a.collectEntries(ctx, cypher, params)
+}
+
+func (a *AuditRepositoryNeo4j) GetEntriesAfter(
+	ctx context.Context,
+	afterSequence int64,
+	limit int,
+) ([]*daos.AuditEntry, error) {
+	cypher := `MATCH (e:AuditEntry) WHERE e.sequence > $after
+				RETURN e ORDER BY e.sequence LIMIT $limit`
+
+	return a.collectEntries(
+		ctx, cypher, map[string]interface{}{
+			"after": afterSequence,
+			"limit": limit,
+		},
+	)
+}
+
+func (a *AuditRepositoryNeo4j) collectEntries(
+	ctx context.Context,
+	cypher string,
+	params map[string]interface{},
+) ([]*daos.AuditEntry, error) {
+	records, err := neo4jReadTxCollect(withoutTransaction(ctx), a.driver, cypher, params)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*daos.AuditEntry, len(records))
+	for i, record := range records {
+		entries[i] = daos.NewAuditEntryFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return entries, nil
+}
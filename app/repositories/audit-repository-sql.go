@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"gorm.io/gorm"
+)
+
+var _ AuditRepository = (*AuditRepositorySQL)(nil)
+
+type AuditRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewAuditRepositorySQL(db *gorm.DB) *AuditRepositorySQL {
+	return &AuditRepositorySQL{
+		db: db,
+	}
+}
+
+func (a *AuditRepositorySQL) CreateEntry(ctx context.Context, entry *daos.AuditEntry) error {
+	err := gormDB(withoutTransaction(ctx), a.db).Create(entry).Error
+
+	return convertDuplicate(err)
+}
+
+func (a *AuditRepositorySQL) GetLatestEntry(ctx context.Context) (*daos.AuditEntry, error) {
+	entry := &daos.AuditEntry{}
+	result := gormDB(withoutTransaction(ctx), a.db).Order("sequence DESC").First(entry)
+
+	return entry, convertNotFound(result.Error)
+}
+
+func (a *AuditRepositorySQL) GetEntries(
+	ctx context.Context,
+	filter *AuditFilter,
+) ([]*daos.AuditEntry, error) {
+	query := gormDB(withoutTransaction(ctx), a.db)
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("timestamp >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("timestamp < ?", filter.Until)
+	}
+	if filter.BeforeSequence > 0 {
+		query = query.Where("sequence < ?", filter.BeforeSequence)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	entries := make([]*daos.AuditEntry, 0)
+	result := query.Order("sequence DESC").Find(&entries)
+
+	return entries, result.Error
+}
+
+func (a *AuditRepositorySQL) GetEntriesAfter(
+	ctx context.Context,
+	afterSequence int64,
+	limit int,
+) ([]*daos.AuditEntry, error) {
+	entries := make([]*daos.AuditEntry, 0)
+	result := gormDB(withoutTransaction(ctx), a.db).
+		Where("sequence > ?", afterSequence).
+		Order("sequence").
+		Limit(limit).
+		Find(&entries)
+
+	return entries, result.Error
+}
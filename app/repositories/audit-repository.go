@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+// AuditFilter narrows down GetEntries, empty fields match everything
+type AuditFilter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Outcome    string
+	Since      time.Time
+	Until      time.Time
+	// BeforeSequence only returns entries older than the given one when set
+	BeforeSequence int64
+	Limit          int
+}
+
+// AuditRepository is append only. Entries are written outside of any transaction on the context
+// so a rolled back operation keeps its record.
+type AuditRepository interface {
+	// CreateEntry returns ErrDuplicateRecord when the sequence number is already taken
+	CreateEntry(ctx context.Context, entry *daos.AuditEntry) error
+	// GetLatestEntry returns the head of the chain
+	GetLatestEntry(ctx context.Context) (*daos.AuditEntry, error)
+	// GetEntries returns the entries matching filter, newest first
+	GetEntries(ctx context.Context, filter *AuditFilter) ([]*daos.AuditEntry, error)
+	// GetEntriesAfter returns up to limit entries following afterSequence, oldest first
+	GetEntriesAfter(
+		ctx context.Context,
+		afterSequence int64,
+		limit int,
+	) ([]*daos.AuditEntry, error)
+}
@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+var _ AutoRenewRepository = (*AutoRenewRepositoryMemory)(nil)
+var _ LeaseRepository = (*LeaseRepositoryMemory)(nil)
+
+type AutoRenewRepositoryMemory struct {
+	memoryTransactional
+
+	mu       sync.RWMutex
+	policies map[string]daos.AutoRenewPolicy
+}
+
+func NewAutoRenewRepositoryMemory() *AutoRenewRepositoryMemory {
+	return &AutoRenewRepositoryMemory{
+		policies: make(map[string]daos.AutoRenewPolicy),
+	}
+}
+
+func (a *AutoRenewRepositoryMemory) snapshot() func() {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	policies := make(map[string]daos.AutoRenewPolicy, len(a.policies))
+	for id, policy := range a.policies {
+		policies[id] = policy
+	}
+
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		a.policies = policies
+	}
+}
+
+func (a *AutoRenewRepositoryMemory) SavePolicy(
+	ctx context.Context,
+	policy *daos.AutoRenewPolicy,
+) error {
+	if policy.Created.IsZero() {
+		policy.Created = time.Now()
+	}
+
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.policies[policy.CertificateID] = *policy
+
+	return nil
+}
+
+func (a *AutoRenewRepositoryMemory) GetPolicy(
+	ctx context.Context,
+	certificateID string,
+) (*daos.AutoRenewPolicy, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	policy, ok := a.policies[certificateID]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &policy, nil
+}
+
+func (a *AutoRenewRepositoryMemory) DeletePolicy(ctx context.Context, certificateID string) error {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.policies, certificateID)
+
+	return nil
+}
+
+func (a *AutoRenewRepositoryMemory) GetPoliciesDue(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.AutoRenewPolicy, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	policies := make([]*daos.AutoRenewPolicy, 0)
+	for _, policy := range a.policies {
+		if isAutoRenewDue(&policy, before) {
+			policy := policy
+			policies = append(policies, &policy)
+		}
+	}
+
+	sort.Slice(
+		policies, func(i, j int) bool {
+			return policies[i].NextAttempt.Before(policies[j].NextAttempt)
+		},
+	)
+
+	return policies, nil
+}
+
+func (a *AutoRenewRepositoryMemory) ClaimPolicy(
+	ctx context.Context,
+	certificateID string,
+	now time.Time,
+	until time.Time,
+) (bool, error) {
+	defer a.beginWrite(ctx)()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	policy, ok := a.policies[certificateID]
+	if !ok || !isAutoRenewDue(&policy, now) {
+		return false, nil
+	}
+
+	policy.Status = daos.AutoRenewRenewing
+	policy.NextAttempt = until
+	a.policies[certificateID] = policy
+
+	return true, nil
+}
+
+func isAutoRenewDue(policy *daos.AutoRenewPolicy, before time.Time) bool {
+	if policy.NextAttempt.After(before) {
+		return false
+	}
+
+	return policy.Status == daos.AutoRenewPending || policy.Status == daos.AutoRenewRenewing
+}
+
+// LeaseRepositoryMemory only coordinates within one process, which is all a memory store has
+type LeaseRepositoryMemory struct {
+	mu     sync.Mutex
+	leases map[string]daos.Lease
+}
+
+func NewLeaseRepositoryMemory() *LeaseRepositoryMemory {
+	return &LeaseRepositoryMemory{
+		leases: make(map[string]daos.Lease),
+	}
+}
+
+func (l *LeaseRepositoryMemory) AcquireLease(
+	ctx context.Context,
+	name string,
+	holder string,
+	ttl time.Duration,
+) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	lease, ok := l.leases[name]
+	if ok && lease.Holder != holder && !lease.Expires.Before(now) {
+		return false, nil
+	}
+
+	l.leases[name] = daos.Lease{
+		Name:    name,
+		Holder:  holder,
+		Expires: now.Add(ttl),
+	}
+
+	return true, nil
+}
+
+func (l *LeaseRepositoryMemory) ReleaseLease(ctx context.Context, name string, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if lease, ok := l.leases[name]; ok && lease.Holder == holder {
+		delete(l.leases, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ AutoRenewRepository = (*AutoRenewRepositoryNeo4j)(nil)
+var _ LeaseRepository = (*LeaseRepositoryNeo4j)(nil)
+
+// AutoRenewRepositoryNeo4j keeps policies as standalone nodes keyed by certificate ID, so a
+// policy outlives its certificate the same way it does in SQL
+type AutoRenewRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewAutoRenewRepositoryNeo4j(driver neo4j.Driver) *AutoRenewRepositoryNeo4j {
+	return &AutoRenewRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (a *AutoRenewRepositoryNeo4j) SavePolicy(
+	ctx context.Context,
+	policy *daos.AutoRenewPolicy,
+) error {
+	if policy.Created.IsZero() {
+		policy.Created = time.Now()
+	}
+
+	cypher := `MERGE (p:AutoRenewPolicy {certificateID: $certificateID})
+				SET p = $props`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": policy.CertificateID,
+			"props":         policy.Props(),
+		},
+	)
+}
+
+func (a *AutoRenewRepositoryNeo4j) GetPolicy(
+	ctx context.Context,
+	certificateID string,
+) (*daos.AutoRenewPolicy, error) {
+	cypher := `MATCH (p:AutoRenewPolicy {certificateID: $certificateID}) RETURN p`
+	record, err := neo4jReadTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": certificateID,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewAutoRenewPolicyFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (a *AutoRenewRepositoryNeo4j) DeletePolicy(ctx context.Context, certificateID string) error {
+	cypher := `MATCH (p:AutoRenewPolicy {certificateID: $certificateID}) DELETE p`
+
+	return neo4jWriteTx(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": certificateID,
+		},
+	)
+}
+
+func (a *AutoRenewRepositoryNeo4j) GetPoliciesDue(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.AutoRenewPolicy, error) {
+	cypher := `MATCH (p:AutoRenewPolicy)
+				WHERE p.status IN $statuses AND p.nextAttempt <= $before
+				RETURN p ORDER BY p.nextAttempt`
+	records, err := neo4jReadTxCollect(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"statuses": autoRenewDueStatuses,
+			"before":   before.In(time.UTC),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]*daos.AutoRenewPolicy, len(records))
+	for i, record := range records {
+		policies[i] = daos.NewAutoRenewPolicyFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return policies, nil
+}
+
+func (a *AutoRenewRepositoryNeo4j) ClaimPolicy(
+	ctx context.Context,
+	certificateID string,
+	now time.Time,
+	until time.Time,
+) (bool, error) {
+	// Touching the node first takes its write lock, so a concurrent claim waits and then sees
+	// this one's status instead of both passing the check
+	cypher := `MATCH (p:AutoRenewPolicy {certificateID: $certificateID})
+				SET p._lock = true
+				REMOVE p._lock
+				WITH p
+				WHERE p.status IN $statuses AND p.nextAttempt <= $now
+				SET p.status = $renewing, p.nextAttempt = $until
+				RETURN p.certificateID`
+	_, err := neo4jWriteTxSingle(
+		ctx, a.driver, cypher, map[string]interface{}{
+			"certificateID": certificateID,
+			"statuses":      autoRenewDueStatuses,
+			"now":           now.In(time.UTC),
+			"renewing":      daos.AutoRenewRenewing,
+			"until":         until.In(time.UTC),
+		},
+	)
+
+	err = neo4jNotFound(err)
+	if errors.Is(err, ErrNoRecord) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+type LeaseRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewLeaseRepositoryNeo4j(driver neo4j.Driver) *LeaseRepositoryNeo4j {
+	return &LeaseRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (l *LeaseRepositoryNeo4j) AcquireLease(
+	ctx context.Context,
+	name string,
+	holder string,
+	ttl time.Duration,
+) (bool, error) {
+	now := time.Now()
+	cypher := `MERGE (l:Lease {name: $name})
+				ON CREATE SET l.holder = $holder, l.expires = $expires
+				WITH l
+				WHERE l.holder = $holder OR l.expires < $now
+				SET l.holder = $holder, l.expires = $expires
+				RETURN l.name`
+	_, err := neo4jWriteTxSingle(
+		ctx, l.driver, cypher, map[string]interface{}{
+			"name":    name,
+			"holder":  holder,
+			"now":     now.In(time.UTC),
+			"expires": now.Add(ttl).In(time.UTC),
+		},
+	)
+
+	// The lease is someone else's when nothing comes back, or when a concurrent MERGE created
+	// it first and tripped the uniqueness constraint
+	err = neo4jDuplicate(neo4jNotFound(err))
+	if errors.Is(err, ErrNoRecord) || errors.Is(err, ErrDuplicateRecord) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (l *LeaseRepositoryNeo4j) ReleaseLease(ctx context.Context, name string, holder string) error {
+	cypher := `MATCH (l:Lease {name: $name, holder: $holder}) DELETE l`
+
+	return neo4jWriteTx(
+		ctx, l.driver, cypher, map[string]interface{}{
+			"name":   name,
+			"holder": holder,
+		},
+	)
+}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"gorm.io/gorm"
+)
+
+var _ AutoRenewRepository = (*AutoRenewRepositorySQL)(nil)
+var _ LeaseRepository = (*LeaseRepositorySQL)(nil)
+
+type AutoRenewRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewAutoRenewRepositorySQL(db *gorm.DB) *AutoRenewRepositorySQL {
+	return &AutoRenewRepositorySQL{
+		db: db,
+	}
+}
+
+func (a *AutoRenewRepositorySQL) SavePolicy(
+	ctx context.Context,
+	policy *daos.AutoRenewPolicy,
+) error {
+	if policy.Created.IsZero() {
+		policy.Created = time.Now()
+	}
+
+	return gormDB(ctx, a.db).Save(policy).Error
+}
+
+func (a *AutoRenewRepositorySQL) GetPolicy(
+	ctx context.Context,
+	certificateID string,
+) (*daos.AutoRenewPolicy, error) {
+	policy := &daos.AutoRenewPolicy{}
+	result := gormDB(ctx, a.db).Where("certificate_id = ?", certificateID).First(policy)
+
+	return policy, convertNotFound(result.Error)
+}
+
+func (a *AutoRenewRepositorySQL) DeletePolicy(ctx context.Context, certificateID string) error {
+	return gormDB(ctx, a.db).Delete(&daos.AutoRenewPolicy{CertificateID: certificateID}).Error
+}
+
+func (a *AutoRenewRepositorySQL) GetPoliciesDue(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.AutoRenewPolicy, error) {
+	policies := make([]*daos.AutoRenewPolicy, 0)
+	result := gormDB(ctx, a.db).
+		Where("status IN (?) AND next_attempt <= ?", autoRenewDueStatuses, before).
+		Order("next_attempt").
+		Find(&policies)
+
+	return policies, result.Error
+}
+
+func (a *AutoRenewRepositorySQL) ClaimPolicy(
+	ctx context.Context,
+	certificateID string,
+	now time.Time,
+	until time.Time,
+) (bool, error) {
+	result := gormDB(ctx, a.db).
+		Model(&daos.AutoRenewPolicy{}).
+		Where(
+			"certificate_id = ? AND status IN (?) AND next_attempt <= ?",
+			certificateID,
+			autoRenewDueStatuses,
+			now,
+		).
+		Updates(map[string]interface{}{"status": daos.AutoRenewRenewing, "next_attempt": until})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+type LeaseRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewLeaseRepositorySQL(db *gorm.DB) *LeaseRepositorySQL {
+	return &LeaseRepositorySQL{
+		db: db,
+	}
+}
+
+func (l *LeaseRepositorySQL) AcquireLease(
+	ctx context.Context,
+	name string,
+	holder string,
+	ttl time.Duration,
+) (bool, error) {
+	now := time.Now()
+	result := gormDB(ctx, l.db).
+		Model(&daos.Lease{}).
+		Where("name = ? AND (holder = ? OR expires < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires": now.Add(ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// Nobody has held the lease yet, when two replicas race to create it the loser sees a
+	// duplicate and backs off
+	err := gormDB(ctx, l.db).Create(
+		&daos.Lease{
+			Name:    name,
+			Holder:  holder,
+			Expires: now.Add(ttl),
+		},
+	).Error
+	if errors.Is(convertDuplicate(err), ErrDuplicateRecord) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (l *LeaseRepositorySQL) ReleaseLease(ctx context.Context, name string, holder string) error {
+	return gormDB(ctx, l.db).
+		Where("name = ? AND holder = ?", name, holder).
+		Delete(&daos.Lease{}).
+		Error
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+// autoRenewDueStatuses are the statuses of policies that are attempted once NextAttempt passes
+var autoRenewDueStatuses = []string{daos.AutoRenewPending, daos.AutoRenewRenewing}
+
+type AutoRenewRepository interface {
+	// SavePolicy creates or replaces the policy of a certificate
+	SavePolicy(ctx context.Context, policy *daos.AutoRenewPolicy) error
+	GetPolicy(ctx context.Context, certificateID string) (*daos.AutoRenewPolicy, error)
+	DeletePolicy(ctx context.Context, certificateID string) error
+	// GetPoliciesDue returns the pending or abandoned renewing policies whose next attempt is not
+	// after before
+	GetPoliciesDue(ctx context.Context, before time.Time) ([]*daos.AutoRenewPolicy, error)
+	// ClaimPolicy moves a due policy to renewing until the given time, returning false when it is
+	// not due, such as when another worker claimed it first
+	ClaimPolicy(
+		ctx context.Context,
+		certificateID string,
+		now time.Time,
+		until time.Time,
+	) (bool, error)
+}
+
+// LeaseRepository coordinates background jobs between replicas sharing a database
+type LeaseRepository interface {
+	// AcquireLease takes or extends the named lease for holder until ttl from now. It returns
+	// false while another holder's lease is still current.
+	AcquireLease(ctx context.Context, name string, holder string, ttl time.Duration) (bool, error)
+	// ReleaseLease gives the lease up early if holder still has it
+	ReleaseLease(ctx context.Context, name string, holder string) error
+}
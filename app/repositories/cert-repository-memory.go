@@ -0,0 +1,461 @@
+package repositories
+
+import (
+	"context"
+	"crypto/x509"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ CertRepository = (*CertRepositoryMemory)(nil)
+
+type CertRepositoryMemory struct {
+	memoryTransactional
+
+	mu    sync.RWMutex
+	certs map[string]daos.Certificate
+	// crls holds every CRL issued by a CA, keyed by the CA's certificate ID
+	crls map[string][]daos.CRL
+}
+
+func NewCertRepositoryMemory() *CertRepositoryMemory {
+	return &CertRepositoryMemory{
+		certs: make(map[string]daos.Certificate),
+		crls:  make(map[string][]daos.CRL),
+	}
+}
+
+func (c *CertRepositoryMemory) snapshot() func() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	certs := make(map[string]daos.Certificate, len(c.certs))
+	for id, cert := range c.certs {
+		certs[id] = cert
+	}
+
+	crls := make(map[string][]daos.CRL, len(c.crls))
+	for caID, caCRLs := range c.crls {
+		crls[caID] = append([]daos.CRL(nil), caCRLs...)
+	}
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.certs = certs
+		c.crls = crls
+	}
+}
+
+// filterCerts copies out the certificates matching filter. The caller must hold the lock.
+func (c *CertRepositoryMemory) filterCerts(
+	filter func(cert *daos.Certificate) bool,
+) []*daos.Certificate {
+	certs := make([]*daos.Certificate, 0)
+	for _, cert := range c.certs {
+		cert := cert
+		if filter(&cert) {
+			certs = append(certs, &cert)
+		}
+	}
+
+	return certs
+}
+
+func (c *CertRepositoryMemory) CreateCert(
+	ctx context.Context,
+	userId string,
+	name string,
+	data []byte,
+	certType string,
+	parentCA string,
+	keyId string,
+) (*daos.Certificate, error) {
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	certDao := daos.Certificate{
+		ID:                uuid.New().String(),
+		Name:              name,
+		Data:              data,
+		Type:              certType,
+		UserID:            userId,
+		Created:           time.Now(),
+		ParentCertificate: parentCA,
+		KeyID:             keyId,
+	}
+
+	if parentCA != "" {
+		certDao.SerialNumber = cert.SerialNumber.String()
+	}
+
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if certDao.SerialNumber != "" {
+		for _, existing := range c.certs {
+			if existing.ParentCertificate == parentCA &&
+				existing.SerialNumber == certDao.SerialNumber {
+				return nil, ErrDuplicateRecord
+			}
+		}
+	}
+
+	c.certs[certDao.ID] = certDao
+
+	return &certDao, nil
+}
+
+func (c *CertRepositoryMemory) DeleteCertByID(ctx context.Context, id string) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.certs, id)
+	delete(c.crls, id)
+
+	return nil
+}
+
+func (c *CertRepositoryMemory) GetCertByID(ctx context.Context, id string) (
+	*daos.Certificate,
+	error,
+) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cert, ok := c.certs[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &cert, nil
+}
+
+func (c *CertRepositoryMemory) GetCertsByUserID(
+	ctx context.Context,
+	userId string,
+	certTypes []string,
+) ([]*daos.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.filterCerts(
+		func(cert *daos.Certificate) bool {
+			if cert.UserID != userId {
+				return false
+			}
+
+			for _, certType := range certTypes {
+				if cert.Type == certType {
+					return true
+				}
+			}
+
+			return false
+		},
+	), nil
+}
+
+func (c *CertRepositoryMemory) GetCertsByType(
+	ctx context.Context,
+	certTypes []string,
+) ([]*daos.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.filterCerts(
+		func(cert *daos.Certificate) bool {
+			for _, certType := range certTypes {
+				if cert.Type == certType {
+					return true
+				}
+			}
+
+			return false
+		},
+	), nil
+}
+
+func (c *CertRepositoryMemory) GetKeyIDByCertID(ctx context.Context, certID string) (
+	string,
+	error,
+) {
+	cert, err := c.GetCertByID(ctx, certID)
+	if err != nil {
+		return "", err
+	}
+
+	return cert.KeyID, nil
+}
+
+func (c *CertRepositoryMemory) GetCertsByParentCA(
+	ctx context.Context,
+	parentCA string,
+) ([]*daos.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.filterCerts(
+		func(cert *daos.Certificate) bool {
+			return cert.ParentCertificate == parentCA
+		},
+	), nil
+}
+
+func (c *CertRepositoryMemory) GetCertByIssuerAndSerial(
+	ctx context.Context,
+	parentCA string,
+	serialNumber *big.Int,
+) (*daos.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	certs := c.filterCerts(
+		func(cert *daos.Certificate) bool {
+			return cert.ParentCertificate == parentCA &&
+				cert.SerialNumber == serialNumber.String()
+		},
+	)
+	if len(certs) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return certs[0], nil
+}
+
+func (c *CertRepositoryMemory) SetCertKeyID(
+	ctx context.Context,
+	id string,
+	keyId string,
+) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cert, ok := c.certs[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	cert.KeyID = keyId
+	c.certs[id] = cert
+
+	return nil
+}
+
+func (c *CertRepositoryMemory) SetCertReplaces(
+	ctx context.Context,
+	id string,
+	replacesID string,
+) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cert, ok := c.certs[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	cert.Replaces = replacesID
+	c.certs[id] = cert
+
+	return nil
+}
+
+func (c *CertRepositoryMemory) SetCertSealedKeyPassword(
+	ctx context.Context,
+	id string,
+	sealedKeyPassword []byte,
+) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cert, ok := c.certs[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	cert.SealedKeyPassword = sealedKeyPassword
+	c.certs[id] = cert
+
+	return nil
+}
+
+func (c *CertRepositoryMemory) SetCertRefreshError(
+	ctx context.Context,
+	id string,
+	refreshError string,
+) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cert, ok := c.certs[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	cert.RefreshError = refreshError
+	c.certs[id] = cert
+
+	return nil
+}
+
+func (c *CertRepositoryMemory) GetCertsReplacing(
+	ctx context.Context,
+	id string,
+) ([]*daos.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.filterCerts(
+		func(cert *daos.Certificate) bool {
+			return cert.Replaces == id
+		},
+	), nil
+}
+
+func (c *CertRepositoryMemory) UnlinkKey(ctx context.Context, keyId string) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, cert := range c.certs {
+		if cert.KeyID == keyId {
+			cert.KeyID = ""
+			c.certs[id] = cert
+		}
+	}
+
+	return nil
+}
+
+func (c *CertRepositoryMemory) RevokeCertByID(
+	ctx context.Context,
+	id string,
+	revokedAt time.Time,
+	reason int,
+) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cert, ok := c.certs[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	cert.RevokedAt = &revokedAt
+	cert.RevocationReason = reason
+	c.certs[id] = cert
+
+	return nil
+}
+
+func (c *CertRepositoryMemory) GetRevokedCertsByParentCA(
+	ctx context.Context,
+	parentCA string,
+) ([]*daos.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.filterCerts(
+		func(cert *daos.Certificate) bool {
+			return cert.ParentCertificate == parentCA && cert.IsRevoked()
+		},
+	), nil
+}
+
+func (c *CertRepositoryMemory) CreateCRL(
+	ctx context.Context,
+	caID string,
+	number int64,
+	data []byte,
+	thisUpdate time.Time,
+	nextUpdate time.Time,
+) (*daos.CRL, error) {
+	crlDao := daos.CRL{
+		ID:            uuid.New().String(),
+		CertificateID: caID,
+		Number:        number,
+		Data:          data,
+		ThisUpdate:    thisUpdate,
+		NextUpdate:    nextUpdate,
+		Created:       time.Now(),
+	}
+
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existing := range c.crls[caID] {
+		if existing.Number == number {
+			return nil, ErrDuplicateRecord
+		}
+	}
+
+	c.crls[caID] = append(c.crls[caID], crlDao)
+
+	return &crlDao, nil
+}
+
+// latestCRL returns the highest numbered CRL of a CA. The caller must hold the lock.
+func (c *CertRepositoryMemory) latestCRL(caID string) (*daos.CRL, bool) {
+	crls := c.crls[caID]
+	if len(crls) == 0 {
+		return nil, false
+	}
+
+	latest := crls[0]
+	for _, crl := range crls[1:] {
+		if crl.Number > latest.Number {
+			latest = crl
+		}
+	}
+
+	return &latest, true
+}
+
+func (c *CertRepositoryMemory) GetLatestCRL(ctx context.Context, caID string) (
+	*daos.CRL,
+	error,
+) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	crl, ok := c.latestCRL(caID)
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return crl, nil
+}
+
+func (c *CertRepositoryMemory) GetLatestCRLsDueBefore(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.CRL, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	crls := make([]*daos.CRL, 0)
+	for caID := range c.crls {
+		crl, ok := c.latestCRL(caID)
+		if ok && !crl.NextUpdate.After(before) {
+			crls = append(crls, crl)
+		}
+	}
+
+	return crls, nil
+}
@@ -0,0 +1,461 @@
+package repositories
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j/db"
+)
+
+var _ CertRepository = (*CertRepositoryNeo4j)(nil)
+
+// certReturn projects the relationship backed fields into the certificate bound to c
+const certReturn = `OPTIONAL MATCH (c)-[:ISSUED_BY]->(p:Certificate)
+				OPTIONAL MATCH (c)-[:USES_KEY]->(k:Key)
+				OPTIONAL MATCH (c)-[:REPLACES]->(r:Certificate)
+				RETURN c {.*, parentCertificate: coalesce(p.uuid, ''), keyID: coalesce(k.uuid, ''),
+					replaces: coalesce(r.uuid, '')}`
+
+// CertRepositoryNeo4j stores certificates as nodes owned by their user, linked to the issuing CA
+// with ISSUED_BY and to their key with USES_KEY. Renewals point at their predecessor with
+// REPLACES and CRLs hang off their CA with HAS_CRL.
+type CertRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewCertRepositoryNeo4j(driver neo4j.Driver) *CertRepositoryNeo4j {
+	return &CertRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+// issuerSerial backs the uniqueness constraint on serial numbers per issuing CA
+func issuerSerial(parentCA string, serialNumber string) string {
+	return parentCA + ":" + serialNumber
+}
+
+func certsFromRecords(records []*db.Record) []*daos.Certificate {
+	certs := make([]*daos.Certificate, len(records))
+	for i, record := range records {
+		certs[i] = daos.NewCertificateFromProps(record.Values[0].(map[string]interface{}))
+	}
+
+	return certs
+}
+
+func (c *CertRepositoryNeo4j) CreateCert(
+	ctx context.Context,
+	userId string,
+	name string,
+	data []byte,
+	certType string,
+	parentCA string,
+	keyId string,
+) (*daos.Certificate, error) {
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	certDao := &daos.Certificate{
+		ID:                uuid.New().String(),
+		Name:              name,
+		Data:              data,
+		Type:              certType,
+		UserID:            userId,
+		Created:           time.Now(),
+		ParentCertificate: parentCA,
+		KeyID:             keyId,
+	}
+
+	props := certDao.Props()
+	if parentCA != "" {
+		certDao.SerialNumber = cert.SerialNumber.String()
+		props["serialNumber"] = certDao.SerialNumber
+		props["issuerSerial"] = issuerSerial(parentCA, certDao.SerialNumber)
+	}
+
+	cypher := `MATCH (u:User {uuid: $userID})
+				CREATE (u)-[:OWNS]->(c:Certificate)
+				SET c = $props
+				WITH c
+				OPTIONAL MATCH (p:Certificate {uuid: $parentCA})
+				OPTIONAL MATCH (k:Key {uuid: $keyID})
+				FOREACH (issuer IN CASE WHEN p IS NULL THEN [] ELSE [p] END |
+					CREATE (c)-[:ISSUED_BY]->(issuer))
+				FOREACH (usedKey IN CASE WHEN k IS NULL THEN [] ELSE [k] END |
+					CREATE (c)-[:USES_KEY]->(usedKey))
+				RETURN c.uuid`
+	_, err = neo4jWriteTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"userID":   userId,
+			"props":    props,
+			"parentCA": parentCA,
+			"keyID":    keyId,
+		},
+	)
+	if err != nil {
+		return nil, neo4jDuplicate(neo4jNotFound(err))
+	}
+
+	return certDao, nil
+}
+
+func (c *CertRepositoryNeo4j) DeleteCertByID(ctx context.Context, id string) error {
+	cypher := `MATCH (c:Certificate {uuid: $uuid})
+				OPTIONAL MATCH (c)-[:HAS_CRL]->(l:CRL)
+				WITH c, collect(l) AS crls
+				FOREACH (crl IN crls | DETACH DELETE crl)
+				DETACH DELETE c`
+
+	return neo4jWriteTx(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+}
+
+func (c *CertRepositoryNeo4j) GetCertByID(ctx context.Context, id string) (
+	*daos.Certificate,
+	error,
+) {
+	cypher := `MATCH (c:Certificate {uuid: $uuid}) ` + certReturn
+	record, err := neo4jReadTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewCertificateFromProps(record.Values[0].(map[string]interface{})), nil
+}
+
+func (c *CertRepositoryNeo4j) GetCertsByUserID(
+	ctx context.Context,
+	userId string,
+	certTypes []string,
+) ([]*daos.Certificate, error) {
+	cypher := `MATCH (:User {uuid: $userID})-[:OWNS]->(c:Certificate)
+				WHERE c.type IN $certTypes ` + certReturn
+	records, err := neo4jReadTxCollect(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"userID":    userId,
+			"certTypes": certTypes,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return certsFromRecords(records), nil
+}
+
+func (c *CertRepositoryNeo4j) GetCertsByType(
+	ctx context.Context,
+	certTypes []string,
+) ([]*daos.Certificate, error) {
+	cypher := `MATCH (c:Certificate) WHERE c.type IN $certTypes ` + certReturn
+	records, err := neo4jReadTxCollect(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"certTypes": certTypes,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return certsFromRecords(records), nil
+}
+
+func (c *CertRepositoryNeo4j) GetKeyIDByCertID(ctx context.Context, certID string) (string, error) {
+	cert, err := c.GetCertByID(ctx, certID)
+	if err != nil {
+		return "", err
+	}
+
+	return cert.KeyID, nil
+}
+
+// certsIssuedBy matches the certificates issued by parentCA, or the parentless ones when empty
+func certsIssuedBy(parentCA string, revokedOnly bool) string {
+	match := `MATCH (c:Certificate)-[:ISSUED_BY]->(:Certificate {uuid: $parentCA})`
+	conditions := make([]string, 0, 2)
+	if parentCA == "" {
+		match = `MATCH (c:Certificate)`
+		conditions = append(conditions, `NOT (c)-[:ISSUED_BY]->(:Certificate)`)
+	}
+
+	if revokedOnly {
+		conditions = append(conditions, `c.revokedAt IS NOT NULL`)
+	}
+
+	if len(conditions) > 0 {
+		match += "\nWHERE " + strings.Join(conditions, " AND ")
+	}
+
+	return match + "\n" + certReturn
+}
+
+func (c *CertRepositoryNeo4j) GetCertsByParentCA(
+	ctx context.Context,
+	parentCA string,
+) ([]*daos.Certificate, error) {
+	cypher := certsIssuedBy(parentCA, false)
+	records, err := neo4jReadTxCollect(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"parentCA": parentCA,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return certsFromRecords(records), nil
+}
+
+func (c *CertRepositoryNeo4j) GetCertByIssuerAndSerial(
+	ctx context.Context,
+	parentCA string,
+	serialNumber *big.Int,
+) (*daos.Certificate, error) {
+	cypher := `MATCH (c:Certificate {issuerSerial: $issuerSerial}) ` + certReturn
+	record, err := neo4jReadTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"issuerSerial": issuerSerial(parentCA, serialNumber.String()),
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewCertificateFromProps(record.Values[0].(map[string]interface{})), nil
+}
+
+func (c *CertRepositoryNeo4j) SetCertKeyID(
+	ctx context.Context,
+	id string,
+	keyId string,
+) error {
+	cypher := `MATCH (c:Certificate {uuid: $uuid}), (k:Key {uuid: $keyID})
+				OPTIONAL MATCH (c)-[old:USES_KEY]->(:Key)
+				DELETE old
+				CREATE (c)-[:USES_KEY]->(k)
+				RETURN c.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid":  id,
+			"keyID": keyId,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (c *CertRepositoryNeo4j) SetCertReplaces(
+	ctx context.Context,
+	id string,
+	replacesID string,
+) error {
+	cypher := `MATCH (c:Certificate {uuid: $uuid}), (r:Certificate {uuid: $replaces})
+				OPTIONAL MATCH (c)-[old:REPLACES]->(:Certificate)
+				DELETE old
+				CREATE (c)-[:REPLACES]->(r)
+				RETURN c.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid":     id,
+			"replaces": replacesID,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (c *CertRepositoryNeo4j) SetCertSealedKeyPassword(
+	ctx context.Context,
+	id string,
+	sealedKeyPassword []byte,
+) error {
+	cypher := `MATCH (c:Certificate {uuid: $uuid})
+				SET c.sealedKeyPassword = $sealedKeyPassword
+				RETURN c.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid":              id,
+			"sealedKeyPassword": sealedKeyPassword,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (c *CertRepositoryNeo4j) SetCertRefreshError(
+	ctx context.Context,
+	id string,
+	refreshError string,
+) error {
+	cypher := `MATCH (c:Certificate {uuid: $uuid})
+				SET c.refreshError = $refreshError
+				RETURN c.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid":         id,
+			"refreshError": refreshError,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (c *CertRepositoryNeo4j) GetCertsReplacing(
+	ctx context.Context,
+	id string,
+) ([]*daos.Certificate, error) {
+	cypher := `MATCH (c:Certificate)-[:REPLACES]->(:Certificate {uuid: $uuid}) ` + certReturn
+	records, err := neo4jReadTxCollect(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return certsFromRecords(records), nil
+}
+
+func (c *CertRepositoryNeo4j) UnlinkKey(ctx context.Context, keyId string) error {
+	cypher := `MATCH (:Certificate)-[r:USES_KEY]->(:Key {uuid: $keyID}) DELETE r`
+
+	return neo4jWriteTx(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"keyID": keyId,
+		},
+	)
+}
+
+func (c *CertRepositoryNeo4j) RevokeCertByID(
+	ctx context.Context,
+	id string,
+	revokedAt time.Time,
+	reason int,
+) error {
+	cypher := `MATCH (c:Certificate {uuid: $uuid})
+				SET c.revokedAt = $revokedAt, c.revocationReason = $reason
+				RETURN c.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"uuid":      id,
+			"revokedAt": revokedAt.In(time.UTC),
+			"reason":    reason,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (c *CertRepositoryNeo4j) GetRevokedCertsByParentCA(
+	ctx context.Context,
+	parentCA string,
+) ([]*daos.Certificate, error) {
+	cypher := certsIssuedBy(parentCA, true)
+	records, err := neo4jReadTxCollect(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"parentCA": parentCA,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return certsFromRecords(records), nil
+}
+
+func (c *CertRepositoryNeo4j) CreateCRL(
+	ctx context.Context,
+	caID string,
+	number int64,
+	data []byte,
+	thisUpdate time.Time,
+	nextUpdate time.Time,
+) (*daos.CRL, error) {
+	crlDao := &daos.CRL{
+		ID:            uuid.New().String(),
+		CertificateID: caID,
+		Number:        number,
+		Data:          data,
+		ThisUpdate:    thisUpdate,
+		NextUpdate:    nextUpdate,
+		Created:       time.Now(),
+	}
+
+	props := crlDao.Props()
+	// caNumber backs the uniqueness constraint on CRL numbers per CA
+	props["caNumber"] = fmt.Sprintf("%s:%d", caID, number)
+
+	cypher := `MATCH (ca:Certificate {uuid: $caID})
+				CREATE (ca)-[:HAS_CRL]->(l:CRL)
+				SET l = $props
+				RETURN l.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"caID":  caID,
+			"props": props,
+		},
+	)
+	if err != nil {
+		return nil, neo4jDuplicate(neo4jNotFound(err))
+	}
+
+	return crlDao, nil
+}
+
+func (c *CertRepositoryNeo4j) GetLatestCRL(ctx context.Context, caID string) (*daos.CRL, error) {
+	cypher := `MATCH (l:CRL {certificateID: $caID})
+				RETURN l ORDER BY l.number DESC LIMIT 1`
+	record, err := neo4jReadTxSingle(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"caID": caID,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewCRLFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (c *CertRepositoryNeo4j) GetLatestCRLsDueBefore(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.CRL, error) {
+	cypher := `MATCH (l:CRL)
+				WITH l.certificateID AS caID, max(l.number) AS number
+				MATCH (l:CRL {certificateID: caID, number: number})
+				WHERE l.nextUpdate <= $before
+				RETURN l`
+	records, err := neo4jReadTxCollect(
+		ctx, c.driver, cypher, map[string]interface{}{
+			"before": before.In(time.UTC),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	crls := make([]*daos.CRL, len(records))
+	for i, record := range records {
+		crls[i] = daos.NewCRLFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return crls, nil
+}
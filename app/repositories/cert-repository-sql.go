@@ -0,0 +1,319 @@
+package repositories
+
+import (
+	"context"
+	"crypto/x509"
+	"math/big"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ CertRepository = (*CertRepositorySQL)(nil)
+
+type CertRepositorySQL struct {
+	db *gorm.DB
+}
+
+func (c *CertRepositorySQL) DeleteCertByID(ctx context.Context, id string) error {
+	return gormDB(ctx, c.db).Transaction(
+		func(tx *gorm.DB) error {
+			err := tx.Where("certificate_id = ?", id).Delete(&daos.CRL{}).Error
+			if err != nil {
+				return err
+			}
+
+			return tx.Delete(&daos.Certificate{ID: id}).Error
+		},
+	)
+}
+
+func (c *CertRepositorySQL) GetCertsByParentCA(
+	ctx context.Context,
+	parentCA string,
+) ([]*daos.Certificate, error) {
+	certs := make([]*daos.Certificate, 0)
+	result := gormDB(ctx, c.db).Where("parent_certificate = ?", parentCA).Find(&certs)
+
+	return certs, result.Error
+}
+
+func NewCertRepositorySQL(db *gorm.DB) *CertRepositorySQL {
+	return &CertRepositorySQL{
+		db: db,
+	}
+}
+
+func (c *CertRepositorySQL) CreateCert(
+	ctx context.Context,
+	userId string,
+	name string,
+	data []byte,
+	certType string,
+	parentCA string,
+	keyId string,
+) (*daos.Certificate, error) {
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	certDao := &daos.Certificate{
+		ID:                uuid.New().String(),
+		Name:              name,
+		Data:              data,
+		Type:              certType,
+		UserID:            userId,
+		Created:           time.Now(),
+		ParentCertificate: parentCA,
+		KeyID:             keyId,
+	}
+
+	// Serials are only unique per issuer, so certificates without one on the platform skip it
+	if parentCA != "" {
+		certDao.SerialNumber = cert.SerialNumber.String()
+	}
+
+	result := gormDB(ctx, c.db).Create(certDao)
+	return certDao, convertDuplicate(result.Error)
+}
+
+func (c *CertRepositorySQL) GetCertsByUserID(
+	ctx context.Context,
+	userId string,
+	certTypes []string,
+) ([]*daos.Certificate, error) {
+	certs := make([]*daos.Certificate, 0)
+	result := gormDB(ctx, c.db).Where(
+		"user_id = ? AND type IN (?)",
+		userId,
+		certTypes,
+	).Find(&certs)
+
+	return certs, result.Error
+}
+
+func (c *CertRepositorySQL) GetCertsByType(
+	ctx context.Context,
+	certTypes []string,
+) ([]*daos.Certificate, error) {
+	certs := make([]*daos.Certificate, 0)
+	result := gormDB(ctx, c.db).Where("type IN (?)", certTypes).Find(&certs)
+
+	return certs, result.Error
+}
+
+func (c *CertRepositorySQL) GetCertByID(ctx context.Context, id string) (
+	*daos.Certificate,
+	error,
+) {
+	cert := &daos.Certificate{}
+	result := gormDB(ctx, c.db).Where("id = ?", id).First(cert)
+	return cert, convertNotFound(result.Error)
+}
+
+func (c *CertRepositorySQL) GetCertByIssuerAndSerial(
+	ctx context.Context,
+	parentCA string,
+	serialNumber *big.Int,
+) (*daos.Certificate, error) {
+	cert := &daos.Certificate{}
+	result := gormDB(ctx, c.db).Where(
+		"parent_certificate = ? AND serial_number = ?",
+		parentCA,
+		serialNumber.String(),
+	).First(cert)
+
+	return cert, convertNotFound(result.Error)
+}
+
+func (c *CertRepositorySQL) GetKeyIDByCertID(ctx context.Context, certID string) (string, error) {
+	cert, err := c.GetCertByID(ctx, certID)
+	if err != nil {
+		return "", err
+	}
+
+	return cert.KeyID, nil
+}
+
+func (c *CertRepositorySQL) SetCertKeyID(
+	ctx context.Context,
+	id string,
+	keyId string,
+) error {
+	result := gormDB(ctx, c.db).Model(&daos.Certificate{ID: id}).Update("key_id", keyId)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (c *CertRepositorySQL) SetCertReplaces(
+	ctx context.Context,
+	id string,
+	replacesID string,
+) error {
+	result := gormDB(ctx, c.db).Model(&daos.Certificate{ID: id}).Update("replaces", replacesID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (c *CertRepositorySQL) SetCertSealedKeyPassword(
+	ctx context.Context,
+	id string,
+	sealedKeyPassword []byte,
+) error {
+	result := gormDB(ctx, c.db).Model(&daos.Certificate{ID: id}).Update(
+		"sealed_key_password",
+		sealedKeyPassword,
+	)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (c *CertRepositorySQL) SetCertRefreshError(
+	ctx context.Context,
+	id string,
+	refreshError string,
+) error {
+	result := gormDB(ctx, c.db).Model(&daos.Certificate{ID: id}).Update(
+		"refresh_error",
+		refreshError,
+	)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (c *CertRepositorySQL) GetCertsReplacing(
+	ctx context.Context,
+	id string,
+) ([]*daos.Certificate, error) {
+	certs := make([]*daos.Certificate, 0)
+	result := gormDB(ctx, c.db).Where("replaces = ?", id).Find(&certs)
+
+	return certs, result.Error
+}
+
+func (c *CertRepositorySQL) UnlinkKey(ctx context.Context, keyId string) error {
+	return gormDB(ctx, c.db).
+		Model(&daos.Certificate{}).
+		Where("key_id = ?", keyId).
+		Update("key_id", nil).
+		Error
+}
+
+func (c *CertRepositorySQL) RevokeCertByID(
+	ctx context.Context,
+	id string,
+	revokedAt time.Time,
+	reason int,
+) error {
+	result := gormDB(ctx, c.db).Model(&daos.Certificate{ID: id}).Updates(
+		map[string]interface{}{
+			"revoked_at":        revokedAt,
+			"revocation_reason": reason,
+		},
+	)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (c *CertRepositorySQL) GetRevokedCertsByParentCA(
+	ctx context.Context,
+	parentCA string,
+) ([]*daos.Certificate, error) {
+	certs := make([]*daos.Certificate, 0)
+	result := gormDB(ctx, c.db).Where(
+		"parent_certificate = ? AND revoked_at IS NOT NULL",
+		parentCA,
+	).Find(&certs)
+
+	return certs, result.Error
+}
+
+func (c *CertRepositorySQL) CreateCRL(
+	ctx context.Context,
+	caID string,
+	number int64,
+	data []byte,
+	thisUpdate time.Time,
+	nextUpdate time.Time,
+) (*daos.CRL, error) {
+	crlDao := &daos.CRL{
+		ID:            uuid.New().String(),
+		CertificateID: caID,
+		Number:        number,
+		Data:          data,
+		ThisUpdate:    thisUpdate,
+		NextUpdate:    nextUpdate,
+		Created:       time.Now(),
+	}
+
+	result := gormDB(ctx, c.db).Create(crlDao)
+	return crlDao, convertDuplicate(result.Error)
+}
+
+func (c *CertRepositorySQL) GetLatestCRL(ctx context.Context, caID string) (*daos.CRL, error) {
+	crl := &daos.CRL{}
+	result := gormDB(ctx, c.db).
+		Where("certificate_id = ?", caID).
+		Order("number DESC").
+		First(crl)
+
+	return crl, convertNotFound(result.Error)
+}
+
+func (c *CertRepositorySQL) GetLatestCRLsDueBefore(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.CRL, error) {
+	latest := gormDB(ctx, c.db).Model(&daos.CRL{}).
+		Select("certificate_id, MAX(number) AS number").
+		Group("certificate_id")
+
+	crls := make([]*daos.CRL, 0)
+	result := gormDB(ctx, c.db).
+		Joins(
+			"JOIN (?) latest ON latest.certificate_id = crls.certificate_id AND latest.number = crls.number",
+			latest,
+		).
+		Where("crls.next_update <= ?", before).
+		Find(&crls)
+
+	return crls, result.Error
+}
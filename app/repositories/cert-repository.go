@@ -2,11 +2,15 @@ package repositories
 
 import (
 	"context"
+	"math/big"
+	"time"
 
 	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
 )
 
 type CertRepository interface {
+	// CreateCert stores a DER encoded certificate. The serial number is read from data and must
+	// be unique amongst certificates with the same parentCA, it is not recorded without one.
 	CreateCert(
 		ctx context.Context,
 		userId string,
@@ -33,6 +37,12 @@ type CertRepository interface {
 		certTypes []string,
 	) ([]*daos.Certificate, error)
 
+	// GetCertsByType returns the certificates of the given types across every user
+	GetCertsByType(
+		ctx context.Context,
+		certTypes []string,
+	) ([]*daos.Certificate, error)
+
 	GetKeyIDByCertID(
 		ctx context.Context,
 		certID string,
@@ -42,4 +52,81 @@ type CertRepository interface {
 		ctx context.Context,
 		parentCA string,
 	) ([]*daos.Certificate, error)
+
+	GetCertByIssuerAndSerial(
+		ctx context.Context,
+		parentCA string,
+		serialNumber *big.Int,
+	) (*daos.Certificate, error)
+
+	// SetCertKeyID links a certificate stored without a key to a platform managed key
+	SetCertKeyID(
+		ctx context.Context,
+		id string,
+		keyId string,
+	) error
+
+	// SetCertReplaces records that a certificate was renewed or re-keyed from replacesID
+	SetCertReplaces(
+		ctx context.Context,
+		id string,
+		replacesID string,
+	) error
+
+	// SetCertSealedKeyPassword stores the server sealed password of a certificate's key
+	SetCertSealedKeyPassword(
+		ctx context.Context,
+		id string,
+		sealedKeyPassword []byte,
+	) error
+
+	// SetCertRefreshError records why the scheduled refresh of a CA failed, empty clears it
+	SetCertRefreshError(
+		ctx context.Context,
+		id string,
+		refreshError string,
+	) error
+
+	// GetCertsReplacing returns the certificates renewed or re-keyed from id
+	GetCertsReplacing(
+		ctx context.Context,
+		id string,
+	) ([]*daos.Certificate, error)
+
+	// UnlinkKey detaches every certificate from a key that is about to be deleted
+	UnlinkKey(
+		ctx context.Context,
+		keyId string,
+	) error
+
+	RevokeCertByID(
+		ctx context.Context,
+		id string,
+		revokedAt time.Time,
+		reason int,
+	) error
+
+	GetRevokedCertsByParentCA(
+		ctx context.Context,
+		parentCA string,
+	) ([]*daos.Certificate, error)
+
+	CreateCRL(
+		ctx context.Context,
+		caID string,
+		number int64,
+		data []byte,
+		thisUpdate time.Time,
+		nextUpdate time.Time,
+	) (*daos.CRL, error)
+
+	GetLatestCRL(
+		ctx context.Context,
+		caID string,
+	) (*daos.CRL, error)
+
+	GetLatestCRLsDueBefore(
+		ctx context.Context,
+		before time.Time,
+	) ([]*daos.CRL, error)
 }
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ CertificateProfileRepository = (*CertificateProfileRepositoryMemory)(nil)
+
+type CertificateProfileRepositoryMemory struct {
+	memoryTransactional
+
+	mu       sync.RWMutex
+	profiles map[string]daos.CertificateProfile
+}
+
+func NewCertificateProfileRepositoryMemory() *CertificateProfileRepositoryMemory {
+	return &CertificateProfileRepositoryMemory{
+		profiles: make(map[string]daos.CertificateProfile),
+	}
+}
+
+func (c *CertificateProfileRepositoryMemory) snapshot() func() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	profiles := make(map[string]daos.CertificateProfile, len(c.profiles))
+	for id, profile := range c.profiles {
+		profiles[id] = profile
+	}
+
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.profiles = profiles
+	}
+}
+
+func (c *CertificateProfileRepositoryMemory) CreateProfile(
+	ctx context.Context,
+	profile *daos.CertificateProfile,
+) error {
+	profile.ID = uuid.New().String()
+	profile.Created = time.Now()
+
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.profiles[profile.ID] = *profile
+
+	return nil
+}
+
+func (c *CertificateProfileRepositoryMemory) GetProfile(
+	ctx context.Context,
+	id string,
+) (*daos.CertificateProfile, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	profile, ok := c.profiles[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &profile, nil
+}
+
+func (c *CertificateProfileRepositoryMemory) GetProfilesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.CertificateProfile, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	profiles := make([]*daos.CertificateProfile, 0)
+	for _, profile := range c.profiles {
+		if profile.UserID == userID {
+			profile := profile
+			profiles = append(profiles, &profile)
+		}
+	}
+
+	sort.Slice(
+		profiles, func(i, j int) bool {
+			return profiles[i].Name < profiles[j].Name
+		},
+	)
+
+	return profiles, nil
+}
+
+// UpdateProfile replaces every field but the owner and creation time
+func (c *CertificateProfileRepositoryMemory) UpdateProfile(
+	ctx context.Context,
+	profile *daos.CertificateProfile,
+) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	existing, ok := c.profiles[profile.ID]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	updated := *profile
+	updated.UserID = existing.UserID
+	updated.Created = existing.Created
+	c.profiles[profile.ID] = updated
+
+	return nil
+}
+
+func (c *CertificateProfileRepositoryMemory) DeleteProfile(ctx context.Context, id string) error {
+	defer c.beginWrite(ctx)()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.profiles, id)
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ CertificateProfileRepository = (*CertificateProfileRepositoryNeo4j)(nil)
+
+type CertificateProfileRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewCertificateProfileRepositoryNeo4j(
+	driver neo4j.Driver,
+) *CertificateProfileRepositoryNeo4j {
+	return &CertificateProfileRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (r *CertificateProfileRepositoryNeo4j) CreateProfile(
+	ctx context.Context,
+	profile *daos.CertificateProfile,
+) error {
+	profile.ID = uuid.New().String()
+	profile.Created = time.Now()
+
+	cypher := `MATCH (u:User {uuid: $userID})
+				CREATE (u)-[:HAS_PROFILE]->(p:CertificateProfile)
+				SET p = $props`
+
+	return neo4jWriteTx(
+		ctx, r.driver, cypher, map[string]interface{}{
+			"userID": profile.UserID,
+			"props":  profile.Props(),
+		},
+	)
+}
+
+func (r *CertificateProfileRepositoryNeo4j) GetProfile(
+	ctx context.Context,
+	id string,
+) (*daos.CertificateProfile, error) {
+	cypher := `MATCH (p:CertificateProfile {uuid: $uuid}) RETURN p`
+	record, err := neo4jReadTxSingle(
+		ctx, r.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewCertificateProfileFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (r *CertificateProfileRepositoryNeo4j) GetProfilesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.CertificateProfile, error) {
+	cypher := `MATCH (:User {uuid: $userID})-[:HAS_PROFILE]->(p:CertificateProfile)
+				RETURN p ORDER BY p.name`
+	records, err := neo4jReadTxCollect(
+		ctx, r.driver, cypher, map[string]interface{}{
+			"userID": userID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]*daos.CertificateProfile, len(records))
+	for i, record := range records {
+		profiles[i] = daos.NewCertificateProfileFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return profiles, nil
+}
+
+func (r *CertificateProfileRepositoryNeo4j) UpdateProfile(
+	ctx context.Context,
+	profile *daos.CertificateProfile,
+) error {
+	props := profile.Props()
+	delete(props, "uuid")
+	delete(props, "userID")
+	delete(props, "created")
+
+	cypher := `MATCH (p:CertificateProfile {uuid: $uuid})
+				SET p += $props
+				RETURN p`
+	_, err := neo4jWriteTxSingle(
+		ctx, r.driver, cypher, map[string]interface{}{
+			"uuid":  profile.ID,
+			"props": props,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (r *CertificateProfileRepositoryNeo4j) DeleteProfile(ctx context.Context, id string) error {
+	cypher := `MATCH (p:CertificateProfile {uuid: $uuid}) DETACH DELETE p`
+
+	return neo4jWriteTx(
+		ctx, r.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ CertificateProfileRepository = (*CertificateProfileRepositorySQL)(nil)
+
+type CertificateProfileRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewCertificateProfileRepositorySQL(db *gorm.DB) *CertificateProfileRepositorySQL {
+	return &CertificateProfileRepositorySQL{
+		db: db,
+	}
+}
+
+func (c *CertificateProfileRepositorySQL) CreateProfile(
+	ctx context.Context,
+	profile *daos.CertificateProfile,
+) error {
+	profile.ID = uuid.New().String()
+	profile.Created = time.Now()
+
+	return gormDB(ctx, c.db).Create(profile).Error
+}
+
+func (c *CertificateProfileRepositorySQL) GetProfile(
+	ctx context.Context,
+	id string,
+) (*daos.CertificateProfile, error) {
+	profile := &daos.CertificateProfile{}
+	result := gormDB(ctx, c.db).Where("id = ?", id).First(profile)
+
+	return profile, convertNotFound(result.Error)
+}
+
+func (c *CertificateProfileRepositorySQL) GetProfilesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.CertificateProfile, error) {
+	profiles := make([]*daos.CertificateProfile, 0)
+	result := gormDB(ctx, c.db).Where("user_id = ?", userID).Order("name").Find(&profiles)
+
+	return profiles, result.Error
+}
+
+func (c *CertificateProfileRepositorySQL) UpdateProfile(
+	ctx context.Context,
+	profile *daos.CertificateProfile,
+) error {
+	result := gormDB(ctx, c.db).Select("*").Omit("user_id", "created").Updates(profile)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (c *CertificateProfileRepositorySQL) DeleteProfile(ctx context.Context, id string) error {
+	result := gormDB(ctx, c.db).Delete(&daos.CertificateProfile{ID: id})
+
+	return result.Error
+}
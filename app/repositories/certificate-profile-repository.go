@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+type CertificateProfileRepository interface {
+	// CreateProfile assigns the ID and creation time before storing the profile
+	CreateProfile(ctx context.Context, profile *daos.CertificateProfile) error
+	GetProfile(ctx context.Context, id string) (*daos.CertificateProfile, error)
+	GetProfilesForUser(ctx context.Context, userID string) ([]*daos.CertificateProfile, error)
+	UpdateProfile(ctx context.Context, profile *daos.CertificateProfile) error
+	DeleteProfile(ctx context.Context, id string) error
+}
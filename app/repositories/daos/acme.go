@@ -0,0 +1,225 @@
+package daos
+
+import "time"
+
+// AcmeDirectory marks a CA as exposing an ACME endpoint. The CA key password is sealed with the
+// server secret so orders can be finalized without a user present.
+type AcmeDirectory struct {
+	CertificateID     string `gorm:"size:36;primary_key;"`
+	UserID            string
+	SealedKeyPassword []byte
+	CertValidityDays  int
+	Created           time.Time
+}
+
+type AcmeAccount struct {
+	ID            string `gorm:"size:36;primary_key;"`
+	CertificateID string
+	Thumbprint    string
+	JWK           string
+	Contact       string
+	Status        string
+	Created       time.Time
+}
+
+type AcmeOrder struct {
+	ID            string `gorm:"size:36;primary_key;"`
+	AccountID     string
+	Status        string
+	Identifiers   string
+	NotBefore     *time.Time
+	NotAfter      *time.Time
+	Expires       time.Time
+	Error         string
+	CertificateID string
+	Created       time.Time
+}
+
+type AcmeAuthorization struct {
+	ID              string `gorm:"size:36;primary_key;"`
+	OrderID         string
+	IdentifierType  string
+	IdentifierValue string
+	Wildcard        bool
+	Status          string
+	Expires         time.Time
+	Created         time.Time
+}
+
+type AcmeChallenge struct {
+	ID              string `gorm:"size:36;primary_key;"`
+	AuthorizationID string
+	Type            string
+	Token           string
+	Status          string
+	Validated       *time.Time
+	Error           string
+	Created         time.Time
+}
+
+type AcmeNonce struct {
+	Value   string `gorm:"primary_key;"`
+	Created time.Time
+}
+
+func NewAcmeDirectoryFromProps(props map[string]interface{}) *AcmeDirectory {
+	directory := &AcmeDirectory{
+		CertificateID:    props["certificateID"].(string),
+		UserID:           props["userID"].(string),
+		CertValidityDays: int(props["certValidityDays"].(int64)),
+		Created:          props["created"].(time.Time),
+	}
+
+	if sealedKeyPassword, ok := props["sealedKeyPassword"].([]byte); ok {
+		directory.SealedKeyPassword = sealedKeyPassword
+	}
+
+	return directory
+}
+
+// Props is the inverse of NewAcmeDirectoryFromProps
+func (d *AcmeDirectory) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"certificateID":     d.CertificateID,
+		"userID":            d.UserID,
+		"sealedKeyPassword": d.SealedKeyPassword,
+		"certValidityDays":  d.CertValidityDays,
+		"created":           d.Created.In(time.UTC),
+	}
+}
+
+func NewAcmeAccountFromProps(props map[string]interface{}) *AcmeAccount {
+	return &AcmeAccount{
+		ID:            props["uuid"].(string),
+		CertificateID: props["certificateID"].(string),
+		Thumbprint:    props["thumbprint"].(string),
+		JWK:           props["jwk"].(string),
+		Contact:       props["contact"].(string),
+		Status:        props["status"].(string),
+		Created:       props["created"].(time.Time),
+	}
+}
+
+// Props is the inverse of NewAcmeAccountFromProps
+func (a *AcmeAccount) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":          a.ID,
+		"certificateID": a.CertificateID,
+		"thumbprint":    a.Thumbprint,
+		"jwk":           a.JWK,
+		"contact":       a.Contact,
+		"status":        a.Status,
+		"created":       a.Created.In(time.UTC),
+	}
+}
+
+func NewAcmeOrderFromProps(props map[string]interface{}) *AcmeOrder {
+	order := &AcmeOrder{
+		ID:            props["uuid"].(string),
+		AccountID:     props["accountID"].(string),
+		Status:        props["status"].(string),
+		Identifiers:   props["identifiers"].(string),
+		Expires:       props["expires"].(time.Time),
+		Error:         props["error"].(string),
+		CertificateID: props["certificateID"].(string),
+		Created:       props["created"].(time.Time),
+	}
+
+	if notBefore, ok := props["notBefore"].(time.Time); ok {
+		order.NotBefore = &notBefore
+	}
+
+	if notAfter, ok := props["notAfter"].(time.Time); ok {
+		order.NotAfter = &notAfter
+	}
+
+	return order
+}
+
+// Props is the inverse of NewAcmeOrderFromProps
+func (o *AcmeOrder) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"uuid":          o.ID,
+		"accountID":     o.AccountID,
+		"status":        o.Status,
+		"identifiers":   o.Identifiers,
+		"expires":       o.Expires.In(time.UTC),
+		"error":         o.Error,
+		"certificateID": o.CertificateID,
+		"created":       o.Created.In(time.UTC),
+	}
+
+	if o.NotBefore != nil {
+		props["notBefore"] = o.NotBefore.In(time.UTC)
+	}
+
+	if o.NotAfter != nil {
+		props["notAfter"] = o.NotAfter.In(time.UTC)
+	}
+
+	return props
+}
+
+func NewAcmeAuthorizationFromProps(props map[string]interface{}) *AcmeAuthorization {
+	return &AcmeAuthorization{
+		ID:              props["uuid"].(string),
+		OrderID:         props["orderID"].(string),
+		IdentifierType:  props["identifierType"].(string),
+		IdentifierValue: props["identifierValue"].(string),
+		Wildcard:        props["wildcard"].(bool),
+		Status:          props["status"].(string),
+		Expires:         props["expires"].(time.Time),
+		Created:         props["created"].(time.Time),
+	}
+}
+
+// Props is the inverse of NewAcmeAuthorizationFromProps
+func (a *AcmeAuthorization) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":            a.ID,
+		"orderID":         a.OrderID,
+		"identifierType":  a.IdentifierType,
+		"identifierValue": a.IdentifierValue,
+		"wildcard":        a.Wildcard,
+		"status":          a.Status,
+		"expires":         a.Expires.In(time.UTC),
+		"created":         a.Created.In(time.UTC),
+	}
+}
+
+func NewAcmeChallengeFromProps(props map[string]interface{}) *AcmeChallenge {
+	challenge := &AcmeChallenge{
+		ID:              props["uuid"].(string),
+		AuthorizationID: props["authorizationID"].(string),
+		Type:            props["type"].(string),
+		Token:           props["token"].(string),
+		Status:          props["status"].(string),
+		Error:           props["error"].(string),
+		Created:         props["created"].(time.Time),
+	}
+
+	if validated, ok := props["validated"].(time.Time); ok {
+		challenge.Validated = &validated
+	}
+
+	return challenge
+}
+
+// Props is the inverse of NewAcmeChallengeFromProps
+func (c *AcmeChallenge) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"uuid":            c.ID,
+		"authorizationID": c.AuthorizationID,
+		"type":            c.Type,
+		"token":           c.Token,
+		"status":          c.Status,
+		"error":           c.Error,
+		"created":         c.Created.In(time.UTC),
+	}
+
+	if c.Validated != nil {
+		props["validated"] = c.Validated.In(time.UTC)
+	}
+
+	return props
+}
@@ -0,0 +1,82 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry records one security relevant operation. Entries form a chain, each one holding the
+// hash of its predecessor, so editing or removing an entry breaks every hash after it.
+type AuditEntry struct {
+	Sequence int64 `gorm:"primary_key;autoIncrement:false"`
+	// ActorID is the user performing the operation, empty when they could not be identified
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Outcome    string
+	Error      string
+	SourceIP   string
+	UserAgent  string
+	Timestamp  time.Time
+	PrevHash   string
+	Hash       string
+}
+
+func NewAuditEntryFromProps(props map[string]interface{}) *AuditEntry {
+	return &AuditEntry{
+		Sequence:   props["sequence"].(int64),
+		ActorID:    props["actorID"].(string),
+		Action:     props["action"].(string),
+		TargetType: props["targetType"].(string),
+		TargetID:   props["targetID"].(string),
+		Outcome:    props["outcome"].(string),
+		Error:      props["error"].(string),
+		SourceIP:   props["sourceIP"].(string),
+		UserAgent:  props["userAgent"].(string),
+		Timestamp:  props["timestamp"].(time.Time),
+		PrevHash:   props["prevHash"].(string),
+		Hash:       props["hash"].(string),
+	}
+}
+
+// Props is the inverse of NewAuditEntryFromProps
+func (e *AuditEntry) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"sequence":   e.Sequence,
+		"actorID":    e.ActorID,
+		"action":     e.Action,
+		"targetType": e.TargetType,
+		"targetID":   e.TargetID,
+		"outcome":    e.Outcome,
+		"error":      e.Error,
+		"sourceIP":   e.SourceIP,
+		"userAgent":  e.UserAgent,
+		"timestamp":  e.Timestamp.In(time.UTC),
+		"prevHash":   e.PrevHash,
+		"hash":       e.Hash,
+	}
+}
+
+func (e *AuditEntry) ToResponse() *contracts.AuditEntryResponse {
+	return &contracts.AuditEntryResponse{
+		Sequence:   e.Sequence,
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Outcome:    e.Outcome,
+		Error:      e.Error,
+		SourceIP:   e.SourceIP,
+		UserAgent:  e.UserAgent,
+		Timestamp:  e.Timestamp,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
@@ -0,0 +1,128 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+const (
+	// AutoRenewPending policies are renewed once NextAttempt passes
+	AutoRenewPending = "pending"
+	// AutoRenewRenewing policies are claimed by a worker until NextAttempt, after which an
+	// abandoned claim is due again
+	AutoRenewRenewing = "renewing"
+	// AutoRenewRenewed policies have handed over to the successor's policy
+	AutoRenewRenewed = "renewed"
+	// AutoRenewFailed policies hit an error retrying can't fix and are no longer attempted
+	AutoRenewFailed = "failed"
+)
+
+// AutoRenewPolicy flags a certificate for unattended renewal. The key passwords are sealed with
+// the server secret, the same way ACME directories keep theirs.
+type AutoRenewPolicy struct {
+	CertificateID       string `gorm:"size:36;primary_key;"`
+	UserID              string
+	ThresholdPercent    int
+	ThresholdDays       int
+	SealedCAKeyPassword []byte
+	SealedKeyPassword   []byte
+	RevokePredecessor   bool
+	// RenewAt is when the certificate's remaining lifetime drops below the threshold
+	RenewAt time.Time
+	// NextAttempt starts out as RenewAt and is pushed back after every failed attempt
+	NextAttempt          time.Time
+	Status               string
+	Failures             int
+	LastAttempt          *time.Time
+	LastError            string
+	RenewedCertificateID string `gorm:"default:null"`
+	Created              time.Time
+}
+
+func NewAutoRenewPolicyFromProps(props map[string]interface{}) *AutoRenewPolicy {
+	policy := &AutoRenewPolicy{
+		CertificateID:     props["certificateID"].(string),
+		UserID:            props["userID"].(string),
+		ThresholdPercent:  int(props["thresholdPercent"].(int64)),
+		ThresholdDays:     int(props["thresholdDays"].(int64)),
+		RevokePredecessor: props["revokePredecessor"].(bool),
+		RenewAt:           props["renewAt"].(time.Time),
+		NextAttempt:       props["nextAttempt"].(time.Time),
+		Status:            props["status"].(string),
+		Failures:          int(props["failures"].(int64)),
+		LastError:         props["lastError"].(string),
+		Created:           props["created"].(time.Time),
+	}
+
+	// Neo4j drops properties set to null, so an empty password comes back missing
+	if sealed, ok := props["sealedCAKeyPassword"].([]byte); ok {
+		policy.SealedCAKeyPassword = sealed
+	}
+
+	if sealed, ok := props["sealedKeyPassword"].([]byte); ok {
+		policy.SealedKeyPassword = sealed
+	}
+
+	if lastAttempt, ok := props["lastAttempt"].(time.Time); ok {
+		policy.LastAttempt = &lastAttempt
+	}
+
+	if renewedCertificateID, ok := props["renewedCertificateID"].(string); ok {
+		policy.RenewedCertificateID = renewedCertificateID
+	}
+
+	return policy
+}
+
+// Props is the inverse of NewAutoRenewPolicyFromProps
+func (p *AutoRenewPolicy) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"certificateID":       p.CertificateID,
+		"userID":              p.UserID,
+		"thresholdPercent":    p.ThresholdPercent,
+		"thresholdDays":       p.ThresholdDays,
+		"sealedCAKeyPassword": p.SealedCAKeyPassword,
+		"sealedKeyPassword":   p.SealedKeyPassword,
+		"revokePredecessor":   p.RevokePredecessor,
+		"renewAt":             p.RenewAt.In(time.UTC),
+		"nextAttempt":         p.NextAttempt.In(time.UTC),
+		"status":              p.Status,
+		"failures":            p.Failures,
+		"lastError":           p.LastError,
+		"created":             p.Created.In(time.UTC),
+	}
+
+	if p.LastAttempt != nil {
+		props["lastAttempt"] = p.LastAttempt.In(time.UTC)
+	}
+
+	if p.RenewedCertificateID != "" {
+		props["renewedCertificateID"] = p.RenewedCertificateID
+	}
+
+	return props
+}
+
+func (p *AutoRenewPolicy) ToResponse() *contracts.AutoRenewResponse {
+	return &contracts.AutoRenewResponse{
+		CertificateID:        p.CertificateID,
+		ThresholdPercent:     p.ThresholdPercent,
+		ThresholdDays:        p.ThresholdDays,
+		RevokePredecessor:    p.RevokePredecessor,
+		RenewAt:              p.RenewAt,
+		NextAttempt:          p.NextAttempt,
+		Status:               p.Status,
+		Failures:             p.Failures,
+		LastAttempt:          p.LastAttempt,
+		LastError:            p.LastError,
+		RenewedCertificateID: p.RenewedCertificateID,
+	}
+}
+
+// Lease lets one replica at a time run a background job, it is free once Expires has passed
+type Lease struct {
+	Name    string `gorm:"size:64;primary_key;"`
+	Holder  string
+	Expires time.Time
+}
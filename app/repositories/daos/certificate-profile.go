@@ -0,0 +1,109 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+// CertificateProfile holds the issuance policy applied to certificates that reference it
+type CertificateProfile struct {
+	ID                   string `gorm:"size:36;primary_key;"`
+	UserID               string
+	Name                 string
+	KeyUsages            []string `gorm:"serializer:json"`
+	ExtKeyUsages         []string `gorm:"serializer:json"`
+	MaxValidityDays      int
+	AllowedKeyAlgorithms []string `gorm:"serializer:json"`
+	IsCA                 bool
+	MaxPathLen           int
+	Organization         string
+	OrganizationalUnit   string
+	Country              string
+	Province             string
+	Locality             string
+	StreetAddress        string
+	PostalCode           string
+	Created              time.Time
+}
+
+func NewCertificateProfileFromProps(props map[string]interface{}) *CertificateProfile {
+	return &CertificateProfile{
+		ID:                   props["uuid"].(string),
+		UserID:               props["userID"].(string),
+		Name:                 props["name"].(string),
+		KeyUsages:            stringsFromProp(props["keyUsages"]),
+		ExtKeyUsages:         stringsFromProp(props["extKeyUsages"]),
+		MaxValidityDays:      int(props["maxValidityDays"].(int64)),
+		AllowedKeyAlgorithms: stringsFromProp(props["allowedKeyAlgorithms"]),
+		IsCA:                 props["isCA"].(bool),
+		MaxPathLen:           int(props["maxPathLen"].(int64)),
+		Organization:         props["organization"].(string),
+		OrganizationalUnit:   props["organizationalUnit"].(string),
+		Country:              props["country"].(string),
+		Province:             props["province"].(string),
+		Locality:             props["locality"].(string),
+		StreetAddress:        props["streetAddress"].(string),
+		PostalCode:           props["postalCode"].(string),
+		Created:              props["created"].(time.Time),
+	}
+}
+
+// Props is the inverse of NewCertificateProfileFromProps
+func (p *CertificateProfile) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":                 p.ID,
+		"userID":               p.UserID,
+		"name":                 p.Name,
+		"keyUsages":            p.KeyUsages,
+		"extKeyUsages":         p.ExtKeyUsages,
+		"maxValidityDays":      p.MaxValidityDays,
+		"allowedKeyAlgorithms": p.AllowedKeyAlgorithms,
+		"isCA":                 p.IsCA,
+		"maxPathLen":           p.MaxPathLen,
+		"organization":         p.Organization,
+		"organizationalUnit":   p.OrganizationalUnit,
+		"country":              p.Country,
+		"province":             p.Province,
+		"locality":             p.Locality,
+		"streetAddress":        p.StreetAddress,
+		"postalCode":           p.PostalCode,
+		"created":              p.Created.In(time.UTC),
+	}
+}
+
+func (p *CertificateProfile) ToResponse() *contracts.CertificateProfileResponse {
+	return &contracts.CertificateProfileResponse{
+		ID: p.ID,
+		CertificateProfileRequest: contracts.CertificateProfileRequest{
+			Name:                 p.Name,
+			KeyUsages:            p.KeyUsages,
+			ExtKeyUsages:         p.ExtKeyUsages,
+			MaxValidityDays:      p.MaxValidityDays,
+			AllowedKeyAlgorithms: p.AllowedKeyAlgorithms,
+			IsCA:                 p.IsCA,
+			MaxPathLen:           p.MaxPathLen,
+			Subject: contracts.CertificateProfileSubject{
+				Organization:       p.Organization,
+				OrganizationalUnit: p.OrganizationalUnit,
+				Country:            p.Country,
+				Province:           p.Province,
+				Locality:           p.Locality,
+				StreetAddress:      p.StreetAddress,
+				PostalCode:         p.PostalCode,
+			},
+		},
+		Created: p.Created,
+	}
+}
+
+// stringsFromProp converts a neo4j list property, which the driver returns as []interface{}
+func stringsFromProp(prop interface{}) []string {
+	values, _ := prop.([]interface{})
+	result := make([]string, len(values))
+	for i, value := range values {
+		result[i] = value.(string)
+	}
+
+	return result
+}
@@ -7,21 +7,99 @@ import (
 )
 
 type Certificate struct {
-	ID                string `gorm:"type:uuid;primary_key;"`
+	ID                string `gorm:"size:36;primary_key;"`
 	UserID            string
 	Name              string
 	Data              []byte
 	Type              string
 	Created           time.Time
-	ParentCertificate string
-	KeyID             string
+	ParentCertificate string `gorm:"size:36;uniqueIndex:idx_cert_issuer_serial"`
+	// SerialNumber is the decimal form of the certificate serial, unique per issuing CA. It is
+	// null for root and other certificates whose issuer is not on the platform.
+	SerialNumber string `gorm:"size:64;uniqueIndex:idx_cert_issuer_serial;default:null"`
+	// KeyID is empty for certificates issued from a CSR, where the platform never sees the key
+	KeyID            string `gorm:"default:null"`
+	RevokedAt        *time.Time
+	RevocationReason int
+	// Replaces is the ID of the certificate this one renewed or re-keyed, empty for originals
+	Replaces string `gorm:"size:36;default:null"`
+	// SealedKeyPassword is the password of KeyID sealed with the server secret, kept for keys the
+	// server unlocks unattended such as a CA's for scheduled CRLs
+	SealedKeyPassword []byte `gorm:"default:null"`
+	// RefreshError is why the last scheduled refresh of a CA failed, empty once one succeeds
+	RefreshError string `gorm:"default:null"`
+}
+
+// NewCertificateFromProps reads a certificate node. ParentCertificate, KeyID and Replaces are
+// stored as ISSUED_BY, USES_KEY and REPLACES relationships, so queries project them into props
+// alongside the node's own.
+func NewCertificateFromProps(props map[string]interface{}) *Certificate {
+	cert := &Certificate{
+		ID:                props["uuid"].(string),
+		UserID:            props["userID"].(string),
+		Name:              props["name"].(string),
+		Data:              props["data"].([]byte),
+		Type:              props["type"].(string),
+		Created:           props["created"].(time.Time),
+		ParentCertificate: props["parentCertificate"].(string),
+		KeyID:             props["keyID"].(string),
+		Replaces:          props["replaces"].(string),
+		RevocationReason:  int(props["revocationReason"].(int64)),
+	}
+
+	if serialNumber, ok := props["serialNumber"].(string); ok {
+		cert.SerialNumber = serialNumber
+	}
+
+	if revokedAt, ok := props["revokedAt"].(time.Time); ok {
+		cert.RevokedAt = &revokedAt
+	}
+
+	if sealedKeyPassword, ok := props["sealedKeyPassword"].([]byte); ok {
+		cert.SealedKeyPassword = sealedKeyPassword
+	}
+
+	if refreshError, ok := props["refreshError"].(string); ok {
+		cert.RefreshError = refreshError
+	}
+
+	return cert
+}
+
+// Props is the inverse of NewCertificateFromProps, without the relationship backed fields
+func (d *Certificate) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"uuid":             d.ID,
+		"userID":           d.UserID,
+		"name":             d.Name,
+		"data":             d.Data,
+		"type":             d.Type,
+		"created":          d.Created.In(time.UTC),
+		"revocationReason": d.RevocationReason,
+	}
+
+	if d.SerialNumber != "" {
+		props["serialNumber"] = d.SerialNumber
+	}
+
+	if d.RevokedAt != nil {
+		props["revokedAt"] = d.RevokedAt.In(time.UTC)
+	}
+
+	return props
+}
+
+func (d *Certificate) IsRevoked() bool {
+	return d.RevokedAt != nil
 }
 
 func (d *Certificate) ToLightResponse() *contracts.CertificateLightResponse {
 	return &contracts.CertificateLightResponse{
-		ID:      d.ID,
-		Name:    d.Name,
-		Type:    d.Type,
-		Created: d.Created,
+		ID:           d.ID,
+		Name:         d.Name,
+		Type:         d.Type,
+		Created:      d.Created,
+		Replaces:     d.Replaces,
+		RefreshError: d.RefreshError,
 	}
 }
@@ -0,0 +1,39 @@
+package daos
+
+import "time"
+
+// CRL is a signed certificate revocation list for the CA identified by CertificateID
+type CRL struct {
+	ID            string `gorm:"size:36;primary_key;"`
+	CertificateID string `gorm:"uniqueIndex:idx_crl_number"`
+	Number        int64  `gorm:"uniqueIndex:idx_crl_number"`
+	Data          []byte
+	ThisUpdate    time.Time
+	NextUpdate    time.Time
+	Created       time.Time
+}
+
+func NewCRLFromProps(props map[string]interface{}) *CRL {
+	return &CRL{
+		ID:            props["uuid"].(string),
+		CertificateID: props["certificateID"].(string),
+		Number:        props["number"].(int64),
+		Data:          props["data"].([]byte),
+		ThisUpdate:    props["thisUpdate"].(time.Time),
+		NextUpdate:    props["nextUpdate"].(time.Time),
+		Created:       props["created"].(time.Time),
+	}
+}
+
+// Props is the inverse of NewCRLFromProps
+func (c *CRL) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":          c.ID,
+		"certificateID": c.CertificateID,
+		"number":        c.Number,
+		"data":          c.Data,
+		"thisUpdate":    c.ThisUpdate.In(time.UTC),
+		"nextUpdate":    c.NextUpdate.In(time.UTC),
+		"created":       c.Created.In(time.UTC),
+	}
+}
@@ -0,0 +1,112 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+// JWKSet groups the keys a user signs JWTs with, their public halves are published as a JWKS.
+// The newest key signs, the ones it replaced stay published for OverlapSeconds so the tokens
+// they signed keep verifying.
+type JWKSet struct {
+	ID             string `gorm:"size:36;primary_key;"`
+	UserID         string
+	Name           string
+	OverlapSeconds int
+	Created        time.Time
+}
+
+func NewJWKSetFromProps(props map[string]interface{}) *JWKSet {
+	return &JWKSet{
+		ID:             props["uuid"].(string),
+		UserID:         props["userID"].(string),
+		Name:           props["name"].(string),
+		OverlapSeconds: int(props["overlapSeconds"].(int64)),
+		Created:        props["created"].(time.Time),
+	}
+}
+
+// Props is the inverse of NewJWKSetFromProps
+func (s *JWKSet) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":           s.ID,
+		"userID":         s.UserID,
+		"name":           s.Name,
+		"overlapSeconds": s.OverlapSeconds,
+		"created":        s.Created.In(time.UTC),
+	}
+}
+
+// JWKSetKey is a key's membership of a JWKSet. JWK holds the public key as published, so the
+// JWKS can be served without touching the key.
+type JWKSetKey struct {
+	ID    string `gorm:"size:36;primary_key;"`
+	SetID string
+	KeyID string
+	// Kid is the RFC 7638 thumbprint of the public key, unique per set
+	Kid       string
+	Algorithm string
+	JWK       string
+	// RetiresAt is when the key stops being published, nil while it is the set's signing key
+	RetiresAt *time.Time
+	Created   time.Time
+}
+
+func NewJWKSetKeyFromProps(props map[string]interface{}) *JWKSetKey {
+	key := &JWKSetKey{
+		ID:        props["uuid"].(string),
+		SetID:     props["setID"].(string),
+		KeyID:     props["keyID"].(string),
+		Kid:       props["kid"].(string),
+		Algorithm: props["algorithm"].(string),
+		JWK:       props["jwk"].(string),
+		Created:   props["created"].(time.Time),
+	}
+
+	if retiresAt, ok := props["retiresAt"].(time.Time); ok {
+		key.RetiresAt = &retiresAt
+	}
+
+	return key
+}
+
+// Props is the inverse of NewJWKSetKeyFromProps
+func (k *JWKSetKey) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"uuid":      k.ID,
+		"setID":     k.SetID,
+		"keyID":     k.KeyID,
+		"kid":       k.Kid,
+		"algorithm": k.Algorithm,
+		"jwk":       k.JWK,
+		"created":   k.Created.In(time.UTC),
+	}
+
+	if k.RetiresAt != nil {
+		props["retiresAt"] = k.RetiresAt.In(time.UTC)
+	}
+
+	return props
+}
+
+// IsActive reports whether the key is the set's signing key
+func (k *JWKSetKey) IsActive() bool {
+	return k.RetiresAt == nil
+}
+
+// IsPublished reports whether the key belongs in the set's JWKS at the given time
+func (k *JWKSetKey) IsPublished(at time.Time) bool {
+	return k.RetiresAt == nil || k.RetiresAt.After(at)
+}
+
+func (k *JWKSetKey) ToResponse() *contracts.JWKSetKeyResponse {
+	return &contracts.JWKSetKeyResponse{
+		Kid:       k.Kid,
+		KeyID:     k.KeyID,
+		Algorithm: k.Algorithm,
+		Active:    k.IsActive(),
+		RetiresAt: k.RetiresAt,
+		Created:   k.Created,
+	}
+}
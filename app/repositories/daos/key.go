@@ -3,10 +3,47 @@ package daos
 import "time"
 
 type Key struct {
-	ID        string `gorm:"type:uuid;primary_key;"`
+	ID        string `gorm:"size:36;primary_key;"`
 	UserID    string
 	Name      string
 	Data      []byte
+	PublicKey []byte // PKIX DER, empty for keys stored before it was kept
 	Algorithm string
+	KeySize   int    // RSA only
+	Curve     string // ECDSA only
 	Created   time.Time
 }
+
+func NewKeyFromProps(props map[string]interface{}) *Key {
+	key := &Key{
+		ID:        props["uuid"].(string),
+		UserID:    props["userID"].(string),
+		Name:      props["name"].(string),
+		Data:      props["data"].([]byte),
+		Algorithm: props["algorithm"].(string),
+		KeySize:   int(props["keySize"].(int64)),
+		Curve:     props["curve"].(string),
+		Created:   props["created"].(time.Time),
+	}
+
+	if publicKey, ok := props["publicKey"].([]byte); ok {
+		key.PublicKey = publicKey
+	}
+
+	return key
+}
+
+// Props is the inverse of NewKeyFromProps
+func (k *Key) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":      k.ID,
+		"userID":    k.UserID,
+		"name":      k.Name,
+		"data":      k.Data,
+		"publicKey": k.PublicKey,
+		"algorithm": k.Algorithm,
+		"keySize":   k.KeySize,
+		"curve":     k.Curve,
+		"created":   k.Created.In(time.UTC),
+	}
+}
@@ -0,0 +1,164 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+const (
+	// DeliveryPending deliveries are sent once NextAttempt passes
+	DeliveryPending = "pending"
+	// DeliveryDelivered deliveries were accepted by their channel
+	DeliveryDelivered = "delivered"
+	// DeliveryFailed deliveries ran out of attempts
+	DeliveryFailed = "failed"
+)
+
+// NotificationRule warns a user ahead of certificates expiring. The webhook secret is sealed with
+// the server secret as it has to be read back to sign payloads.
+type NotificationRule struct {
+	ID     string `gorm:"size:36;primary_key;"`
+	UserID string
+	Name   string
+	// CAID limits the rule to certificates issued by one CA, every certificate when empty
+	CAID         string `gorm:"column:ca_id"`
+	DaysBefore   []int  `gorm:"serializer:json"`
+	Channel      string
+	Target       string
+	SealedSecret []byte
+	Created      time.Time
+}
+
+func NewNotificationRuleFromProps(props map[string]interface{}) *NotificationRule {
+	rule := &NotificationRule{
+		ID:         props["uuid"].(string),
+		UserID:     props["userID"].(string),
+		Name:       props["name"].(string),
+		CAID:       props["caID"].(string),
+		DaysBefore: intsFromProp(props["daysBefore"]),
+		Channel:    props["channel"].(string),
+		Target:     props["target"].(string),
+		Created:    props["created"].(time.Time),
+	}
+
+	if sealed, ok := props["sealedSecret"].([]byte); ok {
+		rule.SealedSecret = sealed
+	}
+
+	return rule
+}
+
+// Props is the inverse of NewNotificationRuleFromProps
+func (r *NotificationRule) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":         r.ID,
+		"userID":       r.UserID,
+		"name":         r.Name,
+		"caID":         r.CAID,
+		"daysBefore":   r.DaysBefore,
+		"channel":      r.Channel,
+		"target":       r.Target,
+		"sealedSecret": r.SealedSecret,
+		"created":      r.Created.In(time.UTC),
+	}
+}
+
+func (r *NotificationRule) ToResponse() *contracts.NotificationRuleResponse {
+	return &contracts.NotificationRuleResponse{
+		ID:         r.ID,
+		Name:       r.Name,
+		CAID:       r.CAID,
+		DaysBefore: r.DaysBefore,
+		Channel:    r.Channel,
+		Target:     r.Target,
+		Created:    r.Created,
+	}
+}
+
+// NotificationDelivery is one notification of a rule about a certificate reaching one of the
+// rule's thresholds. There is at most one per rule, certificate and threshold, which is how
+// the scheduler avoids notifying twice.
+type NotificationDelivery struct {
+	ID            string `gorm:"size:36;primary_key;"`
+	RuleID        string
+	CertificateID string
+	DaysBefore    int
+	NotAfter      time.Time
+	Status        string
+	Attempts      int
+	// NextAttempt is pushed back after every failed attempt
+	NextAttempt time.Time
+	LastAttempt *time.Time
+	LastError   string
+	Created     time.Time
+}
+
+func NewNotificationDeliveryFromProps(props map[string]interface{}) *NotificationDelivery {
+	delivery := &NotificationDelivery{
+		ID:            props["uuid"].(string),
+		RuleID:        props["ruleID"].(string),
+		CertificateID: props["certificateID"].(string),
+		DaysBefore:    int(props["daysBefore"].(int64)),
+		NotAfter:      props["notAfter"].(time.Time),
+		Status:        props["status"].(string),
+		Attempts:      int(props["attempts"].(int64)),
+		NextAttempt:   props["nextAttempt"].(time.Time),
+		LastError:     props["lastError"].(string),
+		Created:       props["created"].(time.Time),
+	}
+
+	if lastAttempt, ok := props["lastAttempt"].(time.Time); ok {
+		delivery.LastAttempt = &lastAttempt
+	}
+
+	return delivery
+}
+
+// Props is the inverse of NewNotificationDeliveryFromProps
+func (d *NotificationDelivery) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"uuid":          d.ID,
+		"ruleID":        d.RuleID,
+		"certificateID": d.CertificateID,
+		"daysBefore":    d.DaysBefore,
+		"notAfter":      d.NotAfter.In(time.UTC),
+		"status":        d.Status,
+		"attempts":      d.Attempts,
+		"nextAttempt":   d.NextAttempt.In(time.UTC),
+		"lastError":     d.LastError,
+		"created":       d.Created.In(time.UTC),
+	}
+
+	if d.LastAttempt != nil {
+		props["lastAttempt"] = d.LastAttempt.In(time.UTC)
+	}
+
+	return props
+}
+
+func (d *NotificationDelivery) ToResponse() *contracts.NotificationDeliveryResponse {
+	return &contracts.NotificationDeliveryResponse{
+		ID:            d.ID,
+		CertificateID: d.CertificateID,
+		DaysBefore:    d.DaysBefore,
+		NotAfter:      d.NotAfter,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		NextAttempt:   d.NextAttempt,
+		LastAttempt:   d.LastAttempt,
+		LastError:     d.LastError,
+		Created:       d.Created,
+	}
+}
+
+// intsFromProp converts a neo4j list property, which the driver returns as []interface{}
+func intsFromProp(prop interface{}) []int {
+	values, _ := prop.([]interface{})
+	result := make([]int, len(values))
+	for i, value := range values {
+		result[i] = int(value.(int64))
+	}
+
+	return result
+}
@@ -7,10 +7,10 @@ import (
 )
 
 type Session struct {
-	ID         string `gorm:"type:uuid;primary_key;"`
+	ID         string `gorm:"size:36;primary_key;"`
 	Created    time.Time
 	Expiration time.Time
-	UserID     string `gorm:"type:uuid;"`
+	UserID     string `gorm:"size:36;"`
 }
 
 func (s *Session) ToResponse() *contracts.SessionResponse {
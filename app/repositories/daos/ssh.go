@@ -0,0 +1,125 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+// SSHCertificateAuthority signs SSH certificates with one of its owner's keys. The public key is
+// kept in SSH wire format so it can be published without unlocking the key.
+type SSHCertificateAuthority struct {
+	ID        string `gorm:"size:36;primary_key;"`
+	UserID    string
+	Name      string
+	KeyID     string
+	PublicKey []byte
+	Created   time.Time
+}
+
+func NewSSHCertificateAuthorityFromProps(props map[string]interface{}) *SSHCertificateAuthority {
+	return &SSHCertificateAuthority{
+		ID:        props["uuid"].(string),
+		UserID:    props["userID"].(string),
+		Name:      props["name"].(string),
+		KeyID:     props["keyID"].(string),
+		PublicKey: props["publicKey"].([]byte),
+		Created:   props["created"].(time.Time),
+	}
+}
+
+// Props is the inverse of NewSSHCertificateAuthorityFromProps
+func (a *SSHCertificateAuthority) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":      a.ID,
+		"userID":    a.UserID,
+		"name":      a.Name,
+		"keyID":     a.KeyID,
+		"publicKey": a.PublicKey,
+		"created":   a.Created.In(time.UTC),
+	}
+}
+
+// SSHCertificate is an issued OpenSSH certificate. Data holds it in wire format, the other fields
+// are copied out of it for listing and revocation.
+type SSHCertificate struct {
+	ID     string `gorm:"size:36;primary_key;"`
+	UserID string
+	CAID   string `gorm:"column:ca_id"`
+	// Serial is unique per CA and always positive, OpenSSH can't revoke serial zero
+	Serial   int64
+	CertType string
+	// KeyIdentity is the certificate's key ID, which sshd logs on authentication
+	KeyIdentity string
+	Principals  []string `gorm:"serializer:json"`
+	ValidAfter  time.Time
+	ValidBefore time.Time
+	Data        []byte
+	RevokedAt   *time.Time
+	Created     time.Time
+}
+
+func NewSSHCertificateFromProps(props map[string]interface{}) *SSHCertificate {
+	cert := &SSHCertificate{
+		ID:          props["uuid"].(string),
+		UserID:      props["userID"].(string),
+		CAID:        props["caID"].(string),
+		Serial:      props["serial"].(int64),
+		CertType:    props["certType"].(string),
+		KeyIdentity: props["keyIdentity"].(string),
+		Principals:  stringsFromProp(props["principals"]),
+		ValidAfter:  props["validAfter"].(time.Time),
+		ValidBefore: props["validBefore"].(time.Time),
+		Data:        props["data"].([]byte),
+		Created:     props["created"].(time.Time),
+	}
+
+	if revokedAt, ok := props["revokedAt"].(time.Time); ok {
+		cert.RevokedAt = &revokedAt
+	}
+
+	return cert
+}
+
+// Props is the inverse of NewSSHCertificateFromProps
+func (c *SSHCertificate) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"uuid":        c.ID,
+		"userID":      c.UserID,
+		"caID":        c.CAID,
+		"serial":      c.Serial,
+		"certType":    c.CertType,
+		"keyIdentity": c.KeyIdentity,
+		"principals":  c.Principals,
+		"validAfter":  c.ValidAfter.In(time.UTC),
+		"validBefore": c.ValidBefore.In(time.UTC),
+		"data":        c.Data,
+		"created":     c.Created.In(time.UTC),
+	}
+
+	if c.RevokedAt != nil {
+		props["revokedAt"] = c.RevokedAt.In(time.UTC)
+	}
+
+	return props
+}
+
+func (c *SSHCertificate) IsRevoked() bool {
+	return c.RevokedAt != nil
+}
+
+// ToResponse leaves Certificate for the caller, which has to marshal Data
+func (c *SSHCertificate) ToResponse() *contracts.SSHCertificateResponse {
+	return &contracts.SSHCertificateResponse{
+		ID:          c.ID,
+		CAID:        c.CAID,
+		Serial:      uint64(c.Serial),
+		CertType:    c.CertType,
+		KeyID:       c.KeyIdentity,
+		Principals:  c.Principals,
+		ValidAfter:  c.ValidAfter,
+		ValidBefore: c.ValidBefore,
+		RevokedAt:   c.RevokedAt,
+		Created:     c.Created,
+	}
+}
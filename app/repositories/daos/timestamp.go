@@ -0,0 +1,126 @@
+package daos
+
+import (
+	"encoding/hex"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+// TimestampAuthority issues RFC 3161 time stamps signed by CertificateID's key. The key password
+// is sealed with the server secret so requests can be answered unattended.
+type TimestampAuthority struct {
+	ID                string `gorm:"size:36;primary_key;"`
+	UserID            string
+	Name              string
+	CertificateID     string
+	SealedKeyPassword []byte
+	// Policy is put in tokens unless the request asks for one of AcceptedPolicies
+	Policy           string
+	AcceptedPolicies []string `gorm:"serializer:json"`
+	// AccuracyMillis is left out of tokens when zero
+	AccuracyMillis int
+	RequireNonce   bool
+	Created        time.Time
+}
+
+func NewTimestampAuthorityFromProps(props map[string]interface{}) *TimestampAuthority {
+	return &TimestampAuthority{
+		ID:                props["uuid"].(string),
+		UserID:            props["userID"].(string),
+		Name:              props["name"].(string),
+		CertificateID:     props["certificateID"].(string),
+		SealedKeyPassword: props["sealedKeyPassword"].([]byte),
+		Policy:            props["policy"].(string),
+		AcceptedPolicies:  stringsFromProp(props["acceptedPolicies"]),
+		AccuracyMillis:    int(props["accuracyMillis"].(int64)),
+		RequireNonce:      props["requireNonce"].(bool),
+		Created:           props["created"].(time.Time),
+	}
+}
+
+// Props is the inverse of NewTimestampAuthorityFromProps
+func (a *TimestampAuthority) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":              a.ID,
+		"userID":            a.UserID,
+		"name":              a.Name,
+		"certificateID":     a.CertificateID,
+		"sealedKeyPassword": a.SealedKeyPassword,
+		"policy":            a.Policy,
+		"acceptedPolicies":  a.AcceptedPolicies,
+		"accuracyMillis":    a.AccuracyMillis,
+		"requireNonce":      a.RequireNonce,
+		"created":           a.Created.In(time.UTC),
+	}
+}
+
+func (a *TimestampAuthority) ToResponse() *contracts.TimestampAuthorityResponse {
+	return &contracts.TimestampAuthorityResponse{
+		ID:               a.ID,
+		Name:             a.Name,
+		CertificateID:    a.CertificateID,
+		Policy:           a.Policy,
+		AcceptedPolicies: a.AcceptedPolicies,
+		AccuracyMillis:   a.AccuracyMillis,
+		RequireNonce:     a.RequireNonce,
+		Created:          a.Created,
+	}
+}
+
+// Timestamp records a token issued by a TSA. Token is the DER ContentInfo, the other fields are
+// copied out of its TSTInfo for listing and verification.
+type Timestamp struct {
+	ID    string `gorm:"size:36;primary_key;"`
+	TSAID string `gorm:"column:tsa_id"`
+	// SerialNumber is the decimal form of the token serial, unique per TSA
+	SerialNumber   string
+	GenTime        time.Time
+	Policy         string
+	HashAlgorithm  string
+	MessageImprint []byte
+	// Nonce is the decimal form of the request nonce, empty when there was none
+	Nonce string
+	Token []byte
+}
+
+func NewTimestampFromProps(props map[string]interface{}) *Timestamp {
+	return &Timestamp{
+		ID:             props["uuid"].(string),
+		TSAID:          props["tsaID"].(string),
+		SerialNumber:   props["serialNumber"].(string),
+		GenTime:        props["genTime"].(time.Time),
+		Policy:         props["policy"].(string),
+		HashAlgorithm:  props["hashAlgorithm"].(string),
+		MessageImprint: props["messageImprint"].([]byte),
+		Nonce:          props["nonce"].(string),
+		Token:          props["token"].([]byte),
+	}
+}
+
+// Props is the inverse of NewTimestampFromProps
+func (t *Timestamp) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"uuid":           t.ID,
+		"tsaID":          t.TSAID,
+		"serialNumber":   t.SerialNumber,
+		"genTime":        t.GenTime.In(time.UTC),
+		"policy":         t.Policy,
+		"hashAlgorithm":  t.HashAlgorithm,
+		"messageImprint": t.MessageImprint,
+		"nonce":          t.Nonce,
+		"token":          t.Token,
+	}
+}
+
+func (t *Timestamp) ToResponse() *contracts.TimestampTokenResponse {
+	return &contracts.TimestampTokenResponse{
+		ID:             t.ID,
+		SerialNumber:   t.SerialNumber,
+		GenTime:        t.GenTime,
+		Policy:         t.Policy,
+		HashAlgorithm:  t.HashAlgorithm,
+		MessageImprint: hex.EncodeToString(t.MessageImprint),
+		Nonce:          t.Nonce,
+	}
+}
@@ -0,0 +1,110 @@
+package daos
+
+import (
+	"time"
+)
+
+// TransparencyLog is the Merkle tree log of the certificates a CA issued
+type TransparencyLog struct {
+	CAID     string `gorm:"primary_key;column:ca_id"`
+	TreeSize int64
+	// PublicKey is the PKIX DER encoded key signing tree heads, empty until the first one is
+	// signed
+	PublicKey        []byte
+	SealedPrivateKey []byte
+	// TreeHeadSize is the size of the tree the stored head covers, which lags TreeSize when the
+	// last append couldn't sign one
+	TreeHeadSize      int64
+	TreeHeadTimestamp int64
+	RootHash          []byte
+	TreeHeadSignature []byte
+	Created           time.Time
+}
+
+func NewTransparencyLogFromProps(props map[string]interface{}) *TransparencyLog {
+	log := &TransparencyLog{
+		CAID:     props["caID"].(string),
+		TreeSize: props["treeSize"].(int64),
+		Created:  props["created"].(time.Time),
+	}
+
+	if publicKey, ok := props["publicKey"].([]byte); ok {
+		log.PublicKey = publicKey
+	}
+
+	if sealedPrivateKey, ok := props["sealedPrivateKey"].([]byte); ok {
+		log.SealedPrivateKey = sealedPrivateKey
+	}
+
+	if treeHeadSize, ok := props["treeHeadSize"].(int64); ok {
+		log.TreeHeadSize = treeHeadSize
+		log.TreeHeadTimestamp = props["treeHeadTimestamp"].(int64)
+		log.RootHash = props["rootHash"].([]byte)
+	}
+
+	if treeHeadSignature, ok := props["treeHeadSignature"].([]byte); ok {
+		log.TreeHeadSignature = treeHeadSignature
+	}
+
+	return log
+}
+
+// TransparencyLogEntry is one leaf of a log. LeafInput is the RFC 6962 MerkleTreeLeaf, which
+// embeds the certificate, so entries outlive certificates deleted later.
+type TransparencyLogEntry struct {
+	CAID          string `gorm:"primary_key;column:ca_id"`
+	LeafIndex     int64  `gorm:"primary_key;autoIncrement:false"`
+	CertificateID string
+	LeafHash      []byte
+	LeafInput     []byte
+}
+
+func NewTransparencyLogEntryFromProps(props map[string]interface{}) *TransparencyLogEntry {
+	return &TransparencyLogEntry{
+		CAID:          props["caID"].(string),
+		LeafIndex:     props["leafIndex"].(int64),
+		CertificateID: props["certificateID"].(string),
+		LeafHash:      props["leafHash"].([]byte),
+		LeafInput:     props["leafInput"].([]byte),
+	}
+}
+
+// Props is the inverse of NewTransparencyLogEntryFromProps
+func (e *TransparencyLogEntry) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"caID":          e.CAID,
+		"leafIndex":     e.LeafIndex,
+		"certificateID": e.CertificateID,
+		"leafHash":      e.LeafHash,
+		"leafInput":     e.LeafInput,
+	}
+}
+
+// TransparencyLogNode is the hash of a perfect subtree of a log, covering the 2^Level leaves
+// from NodeIndex << Level. Storing them as leaves are appended keeps roots and proofs from
+// rehashing the whole tree.
+type TransparencyLogNode struct {
+	CAID      string `gorm:"primary_key;column:ca_id"`
+	Level     int    `gorm:"primary_key;autoIncrement:false"`
+	NodeIndex int64  `gorm:"primary_key;autoIncrement:false"`
+	Hash      []byte
+}
+
+func NewTransparencyLogNodeFromProps(props map[string]interface{}) *TransparencyLogNode {
+	return &TransparencyLogNode{
+		CAID:      props["caID"].(string),
+		Level:     int(props["level"].(int64)),
+		NodeIndex: props["nodeIndex"].(int64),
+		Hash:      props["hash"].([]byte),
+	}
+}
+
+// Props is the inverse of NewTransparencyLogNodeFromProps
+func (n *TransparencyLogNode) Props() map[string]interface{} {
+	return map[string]interface{}{
+		"caID":      n.CAID,
+		"level":     int64(n.Level),
+		"nodeIndex": n.NodeIndex,
+		"hash":      n.Hash,
+	}
+}
@@ -15,7 +15,7 @@ func NewUserFromProps(props map[string]interface{}) *User {
 }
 
 type User struct {
-	ID        string `gorm:"type:uuid;primary_key;"`
+	ID        string `gorm:"size:36;primary_key;"`
 	FirstName string
 	LastName  string
 	Email     string
@@ -3,3 +3,6 @@ package repositories
 import "errors"
 
 var ErrNoRecord = errors.New("no record found")
+
+// ErrDuplicateRecord is returned when a write would break a uniqueness constraint
+var ErrDuplicateRecord = errors.New("record already exists")
@@ -1,11 +1,22 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 
+	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
+const (
+	mysqlDuplicateEntry        = 1062
+	postgresUniqueViolation    = "23505"
+	sqliteConstraintUnique     = 2067
+	sqliteConstraintPrimaryKey = 1555
+)
+
 func convertNotFound(err error) error {
 	if err != nil && errors.Is(err, gorm.ErrRecordNotFound) {
 		return ErrNoRecord
@@ -13,3 +24,61 @@ func convertNotFound(err error) error {
 
 	return err
 }
+
+// convertDuplicate maps each SQL driver's unique constraint violation onto ErrDuplicateRecord
+func convertDuplicate(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return ErrDuplicateRecord
+	}
+
+	var postgresErr *pgconn.PgError
+	if errors.As(err, &postgresErr) && postgresErr.Code == postgresUniqueViolation {
+		return ErrDuplicateRecord
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqliteConstraintUnique || sqliteErr.Code() == sqliteConstraintPrimaryKey) {
+		return ErrDuplicateRecord
+	}
+
+	return err
+}
+
+var _ Transactor = (*TransactorSQL)(nil)
+
+// TransactorSQL runs operations in a gorm transaction
+type TransactorSQL struct {
+	db *gorm.DB
+}
+
+func NewTransactorSQL(db *gorm.DB) *TransactorSQL {
+	return &TransactorSQL{
+		db: db,
+	}
+}
+
+func (t *TransactorSQL) WithinTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context) error,
+) error {
+	if _, ok := ctx.Value(gormTransactionKey).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	return t.db.WithContext(ctx).Transaction(
+		func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, gormTransactionKey, tx))
+		},
+	)
+}
+
+// gormDB returns the transaction open on ctx, or db when there is none
+func gormDB(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(gormTransactionKey).(*gorm.DB); ok {
+		return tx
+	}
+
+	return db.WithContext(ctx)
+}
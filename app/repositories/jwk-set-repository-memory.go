@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ JWKSetRepository = (*JWKSetRepositoryMemory)(nil)
+
+type JWKSetRepositoryMemory struct {
+	memoryTransactional
+
+	mu   sync.RWMutex
+	sets map[string]daos.JWKSet
+	keys map[string]daos.JWKSetKey
+}
+
+func NewJWKSetRepositoryMemory() *JWKSetRepositoryMemory {
+	return &JWKSetRepositoryMemory{
+		sets: make(map[string]daos.JWKSet),
+		keys: make(map[string]daos.JWKSetKey),
+	}
+}
+
+func (s *JWKSetRepositoryMemory) snapshot() func() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets := make(map[string]daos.JWKSet, len(s.sets))
+	for id, set := range s.sets {
+		sets[id] = set
+	}
+
+	keys := make(map[string]daos.JWKSetKey, len(s.keys))
+	for id, key := range s.keys {
+		keys[id] = key
+	}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.sets = sets
+		s.keys = keys
+	}
+}
+
+func (s *JWKSetRepositoryMemory) CreateSet(ctx context.Context, set *daos.JWKSet) error {
+	set.ID = uuid.New().String()
+	set.Created = time.Now()
+
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sets[set.ID] = *set
+
+	return nil
+}
+
+func (s *JWKSetRepositoryMemory) GetSet(ctx context.Context, id string) (*daos.JWKSet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, ok := s.sets[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &set, nil
+}
+
+func (s *JWKSetRepositoryMemory) GetSetsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.JWKSet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets := make([]*daos.JWKSet, 0)
+	for _, set := range s.sets {
+		if set.UserID == userID {
+			set := set
+			sets = append(sets, &set)
+		}
+	}
+
+	sort.Slice(
+		sets, func(i, j int) bool {
+			return sets[i].Name < sets[j].Name
+		},
+	)
+
+	return sets, nil
+}
+
+func (s *JWKSetRepositoryMemory) AddKey(ctx context.Context, key *daos.JWKSetKey) error {
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sets[key.SetID]; !ok {
+		return ErrNoRecord
+	}
+
+	for _, existing := range s.keys {
+		if existing.SetID == key.SetID && existing.Kid == key.Kid {
+			return ErrDuplicateRecord
+		}
+	}
+
+	key.ID = uuid.New().String()
+	key.Created = time.Now()
+	s.keys[key.ID] = *key
+
+	return nil
+}
+
+func (s *JWKSetRepositoryMemory) GetKeysForSet(
+	ctx context.Context,
+	setID string,
+) ([]*daos.JWKSetKey, error) {
+	return s.filterKeys(
+		func(key *daos.JWKSetKey) bool {
+			return key.SetID == setID
+		},
+	), nil
+}
+
+func (s *JWKSetRepositoryMemory) GetKeysForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.JWKSetKey, error) {
+	return s.filterKeys(
+		func(key *daos.JWKSetKey) bool {
+			return s.sets[key.SetID].UserID == userID
+		},
+	), nil
+}
+
+// filterKeys returns the matching keys newest first
+func (s *JWKSetRepositoryMemory) filterKeys(
+	match func(key *daos.JWKSetKey) bool,
+) []*daos.JWKSetKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*daos.JWKSetKey, 0)
+	for _, key := range s.keys {
+		key := key
+		if match(&key) {
+			keys = append(keys, &key)
+		}
+	}
+
+	sort.Slice(
+		keys, func(i, j int) bool {
+			return keys[i].Created.After(keys[j].Created)
+		},
+	)
+
+	return keys
+}
+
+func (s *JWKSetRepositoryMemory) RetireKey(
+	ctx context.Context,
+	id string,
+	retiresAt time.Time,
+) error {
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	key.RetiresAt = &retiresAt
+	s.keys[id] = key
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ JWKSetRepository = (*JWKSetRepositoryNeo4j)(nil)
+
+type JWKSetRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewJWKSetRepositoryNeo4j(driver neo4j.Driver) *JWKSetRepositoryNeo4j {
+	return &JWKSetRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (s *JWKSetRepositoryNeo4j) CreateSet(ctx context.Context, set *daos.JWKSet) error {
+	set.ID = uuid.New().String()
+	set.Created = time.Now()
+
+	cypher := `MATCH (u:User {uuid: $userID})
+				CREATE (u)-[:HAS_JWK_SET]->(s:JWKSet)
+				SET s = $props`
+
+	return neo4jWriteTx(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"userID": set.UserID,
+			"props":  set.Props(),
+		},
+	)
+}
+
+func (s *JWKSetRepositoryNeo4j) GetSet(ctx context.Context, id string) (*daos.JWKSet, error) {
+	cypher := `MATCH (s:JWKSet {uuid: $uuid}) RETURN s`
+	record, err := neo4jReadTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewJWKSetFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (s *JWKSetRepositoryNeo4j) GetSetsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.JWKSet, error) {
+	cypher := `MATCH (:User {uuid: $userID})-[:HAS_JWK_SET]->(s:JWKSet)
+				RETURN s ORDER BY s.name`
+	records, err := neo4jReadTxCollect(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"userID": userID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]*daos.JWKSet, len(records))
+	for i, record := range records {
+		sets[i] = daos.NewJWKSetFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return sets, nil
+}
+
+func (s *JWKSetRepositoryNeo4j) AddKey(ctx context.Context, key *daos.JWKSetKey) error {
+	key.ID = uuid.New().String()
+	key.Created = time.Now()
+
+	// Community edition can't enforce uniqueness over several properties, so the set and kid
+	// are combined into one constrained property
+	props := key.Props()
+	props["setKid"] = key.SetID + "/" + key.Kid
+
+	cypher := `MATCH (s:JWKSet {uuid: $setID})
+				CREATE (s)-[:HAS_JWK]->(k:JWKSetKey)
+				SET k = $props
+				RETURN k.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"setID": key.SetID,
+			"props": props,
+		},
+	)
+
+	return neo4jDuplicate(neo4jNotFound(err))
+}
+
+func (s *JWKSetRepositoryNeo4j) GetKeysForSet(
+	ctx context.Context,
+	setID string,
+) ([]*daos.JWKSetKey, error) {
+	cypher := `MATCH (:JWKSet {uuid: $id})-[:HAS_JWK]->(k:JWKSetKey)
+				RETURN k ORDER BY k.created DESC`
+
+	return s.collectKeys(ctx, cypher, setID)
+}
+
+func (s *JWKSetRepositoryNeo4j) GetKeysForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.JWKSetKey, error) {
+	cypher := `MATCH (:User {uuid: $id})-[:HAS_JWK_SET]->(:JWKSet)-[:HAS_JWK]->(k:JWKSetKey)
+				RETURN k ORDER BY k.created DESC`
+
+	return s.collectKeys(ctx, cypher, userID)
+}
+
+func (s *JWKSetRepositoryNeo4j) collectKeys(
+	ctx context.Context,
+	cypher string,
+	id string,
+) ([]*daos.JWKSetKey, error) {
+	records, err := neo4jReadTxCollect(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"id": id,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*daos.JWKSetKey, len(records))
+	for i, record := range records {
+		keys[i] = daos.NewJWKSetKeyFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return keys, nil
+}
+
+func (s *JWKSetRepositoryNeo4j) RetireKey(
+	ctx context.Context,
+	id string,
+	retiresAt time.Time,
+) error {
+	cypher := `MATCH (k:JWKSetKey {uuid: $uuid})
+				SET k.retiresAt = $retiresAt
+				RETURN k.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"uuid":      id,
+			"retiresAt": retiresAt.In(time.UTC),
+		},
+	)
+
+	return neo4jNotFound(err)
+}
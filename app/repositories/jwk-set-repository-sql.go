@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ JWKSetRepository = (*JWKSetRepositorySQL)(nil)
+
+type JWKSetRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewJWKSetRepositorySQL(db *gorm.DB) *JWKSetRepositorySQL {
+	return &JWKSetRepositorySQL{
+		db: db,
+	}
+}
+
+func (s *JWKSetRepositorySQL) CreateSet(ctx context.Context, set *daos.JWKSet) error {
+	set.ID = uuid.New().String()
+	set.Created = time.Now()
+
+	return gormDB(ctx, s.db).Create(set).Error
+}
+
+func (s *JWKSetRepositorySQL) GetSet(ctx context.Context, id string) (*daos.JWKSet, error) {
+	set := &daos.JWKSet{}
+	result := gormDB(ctx, s.db).Where("id = ?", id).First(set)
+
+	return set, convertNotFound(result.Error)
+}
+
+func (s *JWKSetRepositorySQL) GetSetsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.JWKSet, error) {
+	sets := make([]*daos.JWKSet, 0)
+	result := gormDB(ctx, s.db).Where("user_id = ?", userID).Order("name").Find(&sets)
+
+	return sets, result.Error
+}
+
+func (s *JWKSetRepositorySQL) AddKey(ctx context.Context, key *daos.JWKSetKey) error {
+	key.ID = uuid.New().String()
+	key.Created = time.Now()
+
+	return convertDuplicate(gormDB(ctx, s.db).Create(key).Error)
+}
+
+func (s *JWKSetRepositorySQL) GetKeysForSet(
+	ctx context.Context,
+	setID string,
+) ([]*daos.JWKSetKey, error) {
+	keys := make([]*daos.JWKSetKey, 0)
+	result := gormDB(ctx, s.db).Where("set_id = ?", setID).Order("created DESC").Find(&keys)
+
+	return keys, result.Error
+}
+
+func (s *JWKSetRepositorySQL) GetKeysForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.JWKSetKey, error) {
+	db := gormDB(ctx, s.db)
+
+	sets := db.Model(&daos.JWKSet{}).Select("id").Where("user_id = ?", userID)
+
+	keys := make([]*daos.JWKSetKey, 0)
+	result := db.Where("set_id IN (?)", sets).Order("created DESC").Find(&keys)
+
+	return keys, result.Error
+}
+
+func (s *JWKSetRepositorySQL) RetireKey(
+	ctx context.Context,
+	id string,
+	retiresAt time.Time,
+) error {
+	result := gormDB(ctx, s.db).
+		Model(&daos.JWKSetKey{ID: id}).
+		Update("retires_at", retiresAt)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
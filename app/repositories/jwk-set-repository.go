@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+type JWKSetRepository interface {
+	// CreateSet assigns the ID and creation time before storing the set
+	CreateSet(ctx context.Context, set *daos.JWKSet) error
+	GetSet(ctx context.Context, id string) (*daos.JWKSet, error)
+	GetSetsForUser(ctx context.Context, userID string) ([]*daos.JWKSet, error)
+
+	// AddKey assigns the ID and creation time before storing the key. It returns
+	// ErrDuplicateRecord when the key is already in the set.
+	AddKey(ctx context.Context, key *daos.JWKSetKey) error
+	// GetKeysForSet returns the keys of a set, newest first
+	GetKeysForSet(ctx context.Context, setID string) ([]*daos.JWKSetKey, error)
+	// GetKeysForUser returns the keys of every set the user owns, newest first
+	GetKeysForUser(ctx context.Context, userID string) ([]*daos.JWKSetKey, error)
+	RetireKey(ctx context.Context, id string, retiresAt time.Time) error
+}
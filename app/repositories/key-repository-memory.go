@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ KeyRepository = (*KeyRepositoryMemory)(nil)
+
+type KeyRepositoryMemory struct {
+	memoryTransactional
+
+	mu   sync.RWMutex
+	keys map[string]daos.Key
+}
+
+func NewKeyRepositoryMemory() *KeyRepositoryMemory {
+	return &KeyRepositoryMemory{
+		keys: make(map[string]daos.Key),
+	}
+}
+
+func (k *KeyRepositoryMemory) snapshot() func() {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make(map[string]daos.Key, len(k.keys))
+	for id, keyDao := range k.keys {
+		keys[id] = keyDao
+	}
+
+	return func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		k.keys = keys
+	}
+}
+
+func (k *KeyRepositoryMemory) CreateKey(
+	ctx context.Context,
+	userId string,
+	data []byte,
+	publicKey []byte,
+	algorithm string,
+	keySize int,
+	curve string,
+	name string,
+) (*daos.Key, error) {
+	keyDao := daos.Key{
+		ID:        uuid.New().String(),
+		UserID:    userId,
+		Data:      data,
+		PublicKey: publicKey,
+		Algorithm: algorithm,
+		KeySize:   keySize,
+		Curve:     curve,
+		Name:      name,
+		Created:   time.Now(),
+	}
+
+	defer k.beginWrite(ctx)()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys[keyDao.ID] = keyDao
+
+	return &keyDao, nil
+}
+
+func (k *KeyRepositoryMemory) GetKey(ctx context.Context, id string) (*daos.Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keyDao, ok := k.keys[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &keyDao, nil
+}
+
+func (k *KeyRepositoryMemory) DeleteKey(ctx context.Context, id string) error {
+	defer k.beginWrite(ctx)()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.keys, id)
+
+	return nil
+}
+
+func (k *KeyRepositoryMemory) GetKeysForUser(
+	ctx context.Context,
+	userId string,
+) ([]*daos.Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]*daos.Key, 0)
+	for _, keyDao := range k.keys {
+		if keyDao.UserID == userId {
+			keyDao := keyDao
+			keys = append(keys, &keyDao)
+		}
+	}
+
+	sort.Slice(
+		keys, func(i, j int) bool {
+			return keys[i].Created.Before(keys[j].Created)
+		},
+	)
+
+	return keys, nil
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ KeyRepository = (*KeyRepositoryNeo4j)(nil)
+
+type KeyRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewKeyRepositoryNeo4j(driver neo4j.Driver) *KeyRepositoryNeo4j {
+	return &KeyRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (k *KeyRepositoryNeo4j) CreateKey(
+	ctx context.Context,
+	userId string,
+	data []byte,
+	publicKey []byte,
+	algorithm string,
+	keySize int,
+	curve string,
+	name string,
+) (*daos.Key, error) {
+	keyDao := &daos.Key{
+		ID:        uuid.New().String(),
+		UserID:    userId,
+		Data:      data,
+		PublicKey: publicKey,
+		Algorithm: algorithm,
+		KeySize:   keySize,
+		Curve:     curve,
+		Name:      name,
+		Created:   time.Now(),
+	}
+
+	cypher := `MATCH (u:User {uuid: $userID})
+				CREATE (u)-[:OWNS]->(k:Key)
+				SET k = $props
+				RETURN k.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, k.driver, cypher, map[string]interface{}{
+			"userID": userId,
+			"props":  keyDao.Props(),
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return keyDao, nil
+}
+
+func (k *KeyRepositoryNeo4j) GetKey(ctx context.Context, id string) (*daos.Key, error) {
+	cypher := `MATCH (k:Key {uuid: $uuid}) RETURN k`
+	record, err := neo4jReadTxSingle(
+		ctx, k.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewKeyFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (k *KeyRepositoryNeo4j) GetKeysForUser(
+	ctx context.Context,
+	userId string,
+) ([]*daos.Key, error) {
+	cypher := `MATCH (:User {uuid: $userID})-[:OWNS]->(k:Key) RETURN k ORDER BY k.created`
+	records, err := neo4jReadTxCollect(
+		ctx, k.driver, cypher, map[string]interface{}{
+			"userID": userId,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*daos.Key, len(records))
+	for i, record := range records {
+		keys[i] = daos.NewKeyFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return keys, nil
+}
+
+func (k *KeyRepositoryNeo4j) DeleteKey(ctx context.Context, id string) error {
+	cypher := `MATCH (k:Key {uuid: $uuid}) DETACH DELETE k`
+
+	return neo4jWriteTx(
+		ctx, k.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+}
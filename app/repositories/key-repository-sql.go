@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ KeyRepository = (*KeyRepositorySQL)(nil)
+
+type KeyRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewKeyRepositorySQL(db *gorm.DB) *KeyRepositorySQL {
+	return &KeyRepositorySQL{
+		db: db,
+	}
+}
+
+func (k *KeyRepositorySQL) CreateKey(
+	ctx context.Context,
+	userId string,
+	data []byte,
+	publicKey []byte,
+	algorithm string,
+	keySize int,
+	curve string,
+	name string,
+) (*daos.Key, error) {
+	keyDao := &daos.Key{
+		ID:        uuid.New().String(),
+		UserID:    userId,
+		Data:      data,
+		PublicKey: publicKey,
+		Algorithm: algorithm,
+		KeySize:   keySize,
+		Curve:     curve,
+		Name:      name,
+		Created:   time.Now(),
+	}
+
+	result := gormDB(ctx, k.db).Create(keyDao)
+	return keyDao, result.Error
+}
+
+func (k *KeyRepositorySQL) GetKey(ctx context.Context, id string) (*daos.Key, error) {
+	keyDao := &daos.Key{}
+	result := gormDB(ctx, k.db).Where("id = ?", id).First(keyDao)
+
+	return keyDao, convertNotFound(result.Error)
+}
+
+func (k *KeyRepositorySQL) GetKeysForUser(ctx context.Context, userId string) (
+	[]*daos.Key,
+	error,
+) {
+	keys := make([]*daos.Key, 0)
+	result := gormDB(ctx, k.db).Where("user_id = ?", userId).Find(&keys)
+
+	return keys, result.Error
+}
+
+func (k *KeyRepositorySQL) DeleteKey(ctx context.Context, id string) error {
+	return gormDB(ctx, k.db).Delete(&daos.Key{ID: id}).Error
+}
@@ -7,14 +7,19 @@ import (
 )
 
 type KeyRepository interface {
+	// CreateKey stores the PEM encoded private key data along with its PKIX DER publicKey
 	CreateKey(
 		ctx context.Context,
 		userId string,
 		data []byte,
+		publicKey []byte,
 		algorithm string,
+		keySize int,
+		curve string,
 		name string,
 	) (*daos.Key, error)
 	GetKey(ctx context.Context, id string) (*daos.Key, error)
+	DeleteKey(ctx context.Context, id string) error
 	GetKeysForUser(
 		ctx context.Context,
 		userId string,
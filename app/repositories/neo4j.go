@@ -2,6 +2,9 @@ package repositories
 
 import (
 	"context"
+	"errors"
+
+	"github.com/fapiko/john-hancock-platform/app/persistence/graphdb"
 
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
 	"github.com/neo4j/neo4j-go-driver/v4/neo4j/db"
@@ -10,13 +13,71 @@ import (
 
 const NeoErrNoRecordsMsg = "Result contains no more records"
 
-func neo4jReadTxSingle(
+var _ Transactor = (*TransactorNeo4j)(nil)
+
+// TransactorNeo4j runs operations in an explicit Neo4j transaction
+type TransactorNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewTransactorNeo4j(driver neo4j.Driver) *TransactorNeo4j {
+	return &TransactorNeo4j{
+		driver: driver,
+	}
+}
+
+func (t *TransactorNeo4j) WithinTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context) error,
+) error {
+	if _, ok := ctx.Value(neo4jTransactionKey).(neo4j.Transaction); ok {
+		return fn(ctx)
+	}
+
+	session := t.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer func() {
+		err := session.Close()
+		if err != nil {
+			log.Errorf("failed to close session: %v", err)
+		}
+	}()
+
+	tx, err := session.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := tx.Close()
+		if err != nil {
+			log.Errorf("failed to close transaction: %v", err)
+		}
+	}()
+
+	err = fn(context.WithValue(ctx, neo4jTransactionKey, tx))
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		if rollbackErr != nil {
+			log.Errorf("failed to roll back transaction: %v", rollbackErr)
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// neo4jRun runs work in the transaction open on ctx, or in a managed transaction of its own
+func neo4jRun(
 	ctx context.Context,
 	driver neo4j.Driver,
-	query string,
-	params map[string]interface{},
-) (*db.Record, error) {
-	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	accessMode neo4j.AccessMode,
+	work neo4j.TransactionWork,
+) (interface{}, error) {
+	if tx, ok := ctx.Value(neo4jTransactionKey).(neo4j.Transaction); ok {
+		return work(tx)
+	}
+
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: accessMode})
 	defer func() {
 		err := session.Close()
 		if err != nil {
@@ -24,12 +85,22 @@ func neo4jReadTxSingle(
 		}
 	}()
 
-	result, err := session.ReadTransaction(
-		func(tx neo4j.Transaction) (interface{}, error) {
-			res, err := session.Run(
-				query, params,
-			)
+	if accessMode == neo4j.AccessModeRead {
+		return session.ReadTransaction(work)
+	}
+
+	return session.WriteTransaction(work)
+}
 
+func neo4jReadTxSingle(
+	ctx context.Context,
+	driver neo4j.Driver,
+	query string,
+	params map[string]interface{},
+) (*db.Record, error) {
+	result, err := neo4jRun(
+		ctx, driver, neo4j.AccessModeRead, func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run(query, params)
 			if err != nil {
 				return 0, err
 			}
@@ -45,24 +116,15 @@ func neo4jReadTxSingle(
 	return result.(*db.Record), nil
 }
 
-func neo4jWriteTxSingle(ctx context.Context, driver neo4j.Driver, query string) (
-	*db.Record,
-	error,
-) {
-	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-	defer func() {
-		err := session.Close()
-		if err != nil {
-			log.Errorf("failed to close session: %v", err)
-		}
-	}()
-
-	result, err := session.WriteTransaction(
-		func(tx neo4j.Transaction) (interface{}, error) {
-			res, err := session.Run(
-				query, map[string]interface{}{},
-			)
-
+func neo4jWriteTxSingle(
+	ctx context.Context,
+	driver neo4j.Driver,
+	query string,
+	params map[string]interface{},
+) (*db.Record, error) {
+	result, err := neo4jRun(
+		ctx, driver, neo4j.AccessModeWrite, func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run(query, params)
 			if err != nil {
 				return 0, err
 			}
@@ -77,3 +139,67 @@ func neo4jWriteTxSingle(ctx context.Context, driver neo4j.Driver, query string)
 
 	return result.(*db.Record), nil
 }
+
+// neo4jWriteTx runs a write query whose results are not needed
+func neo4jWriteTx(
+	ctx context.Context,
+	driver neo4j.Driver,
+	query string,
+	params map[string]interface{},
+) error {
+	_, err := neo4jRun(
+		ctx, driver, neo4j.AccessModeWrite, func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run(query, params)
+			if err != nil {
+				return nil, err
+			}
+
+			return res.Consume()
+		},
+	)
+
+	return err
+}
+
+func neo4jReadTxCollect(
+	ctx context.Context,
+	driver neo4j.Driver,
+	query string,
+	params map[string]interface{},
+) ([]*db.Record, error) {
+	result, err := neo4jRun(
+		ctx, driver, neo4j.AccessModeRead, func(tx neo4j.Transaction) (interface{}, error) {
+			res, err := tx.Run(query, params)
+			if err != nil {
+				return nil, err
+			}
+
+			return res.Collect()
+		},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*db.Record), nil
+}
+
+// neo4jNotFound maps the driver's empty result error onto ErrNoRecord
+func neo4jNotFound(err error) error {
+	if err != nil && err.Error() == NeoErrNoRecordsMsg {
+		return ErrNoRecord
+	}
+
+	return err
+}
+
+// neo4jDuplicate maps a uniqueness constraint violation onto ErrDuplicateRecord
+func neo4jDuplicate(err error) error {
+	var neo4jErr *neo4j.Neo4jError
+	if errors.As(err, &neo4jErr) && neo4jErr.Code == graphdb.SchemaFailedCode {
+		return ErrDuplicateRecord
+	}
+
+	return err
+}
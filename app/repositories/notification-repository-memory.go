@@ -0,0 +1,260 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ NotificationRepository = (*NotificationRepositoryMemory)(nil)
+
+type NotificationRepositoryMemory struct {
+	memoryTransactional
+
+	mu         sync.RWMutex
+	rules      map[string]daos.NotificationRule
+	deliveries map[string]daos.NotificationDelivery
+}
+
+func NewNotificationRepositoryMemory() *NotificationRepositoryMemory {
+	return &NotificationRepositoryMemory{
+		rules:      make(map[string]daos.NotificationRule),
+		deliveries: make(map[string]daos.NotificationDelivery),
+	}
+}
+
+func (n *NotificationRepositoryMemory) snapshot() func() {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	rules := make(map[string]daos.NotificationRule, len(n.rules))
+	for id, rule := range n.rules {
+		rules[id] = rule
+	}
+
+	deliveries := make(map[string]daos.NotificationDelivery, len(n.deliveries))
+	for id, delivery := range n.deliveries {
+		deliveries[id] = delivery
+	}
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		n.rules = rules
+		n.deliveries = deliveries
+	}
+}
+
+func (n *NotificationRepositoryMemory) CreateRule(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+) error {
+	rule.ID = uuid.New().String()
+	rule.Created = time.Now()
+
+	defer n.beginWrite(ctx)()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.rules[rule.ID] = *rule
+
+	return nil
+}
+
+func (n *NotificationRepositoryMemory) GetRule(
+	ctx context.Context,
+	id string,
+) (*daos.NotificationRule, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	rule, ok := n.rules[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &rule, nil
+}
+
+func (n *NotificationRepositoryMemory) GetRulesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.NotificationRule, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	rules := make([]*daos.NotificationRule, 0)
+	for _, rule := range n.rules {
+		if rule.UserID == userID {
+			rule := rule
+			rules = append(rules, &rule)
+		}
+	}
+
+	sort.Slice(
+		rules, func(i, j int) bool {
+			return rules[i].Name < rules[j].Name
+		},
+	)
+
+	return rules, nil
+}
+
+func (n *NotificationRepositoryMemory) GetRules(
+	ctx context.Context,
+) ([]*daos.NotificationRule, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	rules := make([]*daos.NotificationRule, 0, len(n.rules))
+	for _, rule := range n.rules {
+		rule := rule
+		rules = append(rules, &rule)
+	}
+
+	sort.Slice(
+		rules, func(i, j int) bool {
+			return rules[i].Created.Before(rules[j].Created)
+		},
+	)
+
+	return rules, nil
+}
+
+// UpdateRule replaces every field but the owner and creation time
+func (n *NotificationRepositoryMemory) UpdateRule(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+) error {
+	defer n.beginWrite(ctx)()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	existing, ok := n.rules[rule.ID]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	updated := *rule
+	updated.UserID = existing.UserID
+	updated.Created = existing.Created
+	n.rules[rule.ID] = updated
+
+	return nil
+}
+
+func (n *NotificationRepositoryMemory) DeleteRule(ctx context.Context, id string) error {
+	defer n.beginWrite(ctx)()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.rules, id)
+	for deliveryID, delivery := range n.deliveries {
+		if delivery.RuleID == id {
+			delete(n.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+func (n *NotificationRepositoryMemory) CreateDelivery(
+	ctx context.Context,
+	delivery *daos.NotificationDelivery,
+) error {
+	defer n.beginWrite(ctx)()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, existing := range n.deliveries {
+		if existing.RuleID == delivery.RuleID &&
+			existing.CertificateID == delivery.CertificateID &&
+			existing.DaysBefore == delivery.DaysBefore {
+			return ErrDuplicateRecord
+		}
+	}
+
+	delivery.ID = uuid.New().String()
+	delivery.Created = time.Now()
+	n.deliveries[delivery.ID] = *delivery
+
+	return nil
+}
+
+// UpdateDelivery replaces every field but the creation time
+func (n *NotificationRepositoryMemory) UpdateDelivery(
+	ctx context.Context,
+	delivery *daos.NotificationDelivery,
+) error {
+	defer n.beginWrite(ctx)()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	existing, ok := n.deliveries[delivery.ID]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	updated := *delivery
+	updated.Created = existing.Created
+	n.deliveries[delivery.ID] = updated
+
+	return nil
+}
+
+func (n *NotificationRepositoryMemory) GetDeliveriesForRule(
+	ctx context.Context,
+	ruleID string,
+) ([]*daos.NotificationDelivery, error) {
+	return n.filterDeliveries(
+		func(delivery *daos.NotificationDelivery) bool {
+			return delivery.RuleID == ruleID
+		},
+		func(a, b *daos.NotificationDelivery) bool {
+			return a.Created.After(b.Created)
+		},
+	), nil
+}
+
+func (n *NotificationRepositoryMemory) GetDeliveriesDue(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.NotificationDelivery, error) {
+	return n.filterDeliveries(
+		func(delivery *daos.NotificationDelivery) bool {
+			return delivery.Status == daos.DeliveryPending && !delivery.NextAttempt.After(before)
+		},
+		func(a, b *daos.NotificationDelivery) bool {
+			return a.NextAttempt.Before(b.NextAttempt)
+		},
+	), nil
+}
+
+func (n *NotificationRepositoryMemory) filterDeliveries(
+	match func(delivery *daos.NotificationDelivery) bool,
+	less func(a, b *daos.NotificationDelivery) bool,
+) []*daos.NotificationDelivery {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	deliveries := make([]*daos.NotificationDelivery, 0)
+	for _, delivery := range n.deliveries {
+		delivery := delivery
+		if match(&delivery) {
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	sort.Slice(
+		deliveries, func(i, j int) bool {
+			return less(deliveries[i], deliveries[j])
+		},
+	)
+
+	return deliveries
+}
@@ -0,0 +1,230 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ NotificationRepository = (*NotificationRepositoryNeo4j)(nil)
+
+type NotificationRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewNotificationRepositoryNeo4j(driver neo4j.Driver) *NotificationRepositoryNeo4j {
+	return &NotificationRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (n *NotificationRepositoryNeo4j) CreateRule(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+) error {
+	rule.ID = uuid.New().String()
+	rule.Created = time.Now()
+
+	cypher := `MATCH (u:User {uuid: $userID})
+				CREATE (u)-[:HAS_NOTIFICATION_RULE]->(r:NotificationRule)
+				SET r = $props`
+
+	return neo4jWriteTx(
+		ctx, n.driver, cypher, map[string]interface{}{
+			"userID": rule.UserID,
+			"props":  rule.Props(),
+		},
+	)
+}
+
+func (n *NotificationRepositoryNeo4j) GetRule(
+	ctx context.Context,
+	id string,
+) (*daos.NotificationRule, error) {
+	cypher := `MATCH (r:NotificationRule {uuid: $uuid}) RETURN r`
+	record, err := neo4jReadTxSingle(
+		ctx, n.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewNotificationRuleFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (n *NotificationRepositoryNeo4j) GetRulesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.NotificationRule, error) {
+	cypher := `MATCH (:User {uuid: $userID})-[:HAS_NOTIFICATION_RULE]->(r:NotificationRule)
+				RETURN r ORDER BY r.name`
+
+	return n.collectRules(ctx, cypher, map[string]interface{}{"userID": userID})
+}
+
+func (n *NotificationRepositoryNeo4j) GetRules(
+	ctx context.Context,
+) ([]*daos.NotificationRule, error) {
+	cypher := `MATCH (r:NotificationRule) RETURN r ORDER BY r.created`
+
+	return n.collectRules(ctx, cypher, map[string]interface{}{})
+}
+
+func (n *NotificationRepositoryNeo4j) collectRules(
+	ctx context.Context,
+	cypher string,
+	params map[string]interface{},
+) ([]*daos.NotificationRule, error) {
+	records, err := neo4jReadTxCollect(ctx, n.driver, cypher, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*daos.NotificationRule, len(records))
+	for i, record := range records {
+		rules[i] = daos.NewNotificationRuleFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return rules, nil
+}
+
+func (n *NotificationRepositoryNeo4j) UpdateRule(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+) error {
+	props := rule.Props()
+	delete(props, "uuid")
+	delete(props, "userID")
+	delete(props, "created")
+
+	cypher := `MATCH (r:NotificationRule {uuid: $uuid})
+				SET r += $props
+				RETURN r`
+	_, err := neo4jWriteTxSingle(
+		ctx, n.driver, cypher, map[string]interface{}{
+			"uuid":  rule.ID,
+			"props": props,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (n *NotificationRepositoryNeo4j) DeleteRule(ctx context.Context, id string) error {
+	cypher := `MATCH (r:NotificationRule {uuid: $uuid})
+				OPTIONAL MATCH (r)-[:HAS_DELIVERY]->(d:NotificationDelivery)
+				DETACH DELETE r, d`
+
+	return neo4jWriteTx(
+		ctx, n.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+}
+
+func (n *NotificationRepositoryNeo4j) CreateDelivery(
+	ctx context.Context,
+	delivery *daos.NotificationDelivery,
+) error {
+	delivery.ID = uuid.New().String()
+	delivery.Created = time.Now()
+
+	// Community edition can't enforce uniqueness over several properties, so the rule,
+	// certificate and threshold are combined into one constrained stage property
+	props := delivery.Props()
+	props["stage"] = fmt.Sprintf(
+		"%s/%s/%d",
+		delivery.RuleID,
+		delivery.CertificateID,
+		delivery.DaysBefore,
+	)
+
+	cypher := `MATCH (r:NotificationRule {uuid: $ruleID})
+				CREATE (r)-[:HAS_DELIVERY]->(d:NotificationDelivery)
+				SET d = $props
+				RETURN d.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, n.driver, cypher, map[string]interface{}{
+			"ruleID": delivery.RuleID,
+			"props":  props,
+		},
+	)
+
+	return neo4jDuplicate(neo4jNotFound(err))
+}
+
+func (n *NotificationRepositoryNeo4j) UpdateDelivery(
+	ctx context.Context,
+	delivery *daos.NotificationDelivery,
+) error {
+	props := delivery.Props()
+	delete(props, "uuid")
+	delete(props, "ruleID")
+	delete(props, "certificateID")
+	delete(props, "daysBefore")
+	delete(props, "created")
+
+	// lastAttempt is removed rather than left behind when it has been cleared
+	cypher := `MATCH (d:NotificationDelivery {uuid: $uuid})
+				REMOVE d.lastAttempt
+				SET d += $props
+				RETURN d`
+	_, err := neo4jWriteTxSingle(
+		ctx, n.driver, cypher, map[string]interface{}{
+			"uuid":  delivery.ID,
+			"props": props,
+		},
+	)
+
+	return neo4jNotFound(err)
+}
+
+func (n *NotificationRepositoryNeo4j) GetDeliveriesForRule(
+	ctx context.Context,
+	ruleID string,
+) ([]*daos.NotificationDelivery, error) {
+	cypher := `MATCH (:NotificationRule {uuid: $ruleID})-[:HAS_DELIVERY]->(d:NotificationDelivery)
+				RETURN d ORDER BY d.created DESC`
+
+	return n.collectDeliveries(ctx, cypher, map[string]interface{}{"ruleID": ruleID})
+}
+
+func (n *NotificationRepositoryNeo4j) GetDeliveriesDue(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.NotificationDelivery, error) {
+	cypher := `MATCH (d:NotificationDelivery)
+				WHERE d.status = $status AND d.nextAttempt <= $before
+				RETURN d ORDER BY d.nextAttempt`
+
+	return n.collectDeliveries(
+		ctx, cypher, map[string]interface{}{
+			"status": daos.DeliveryPending,
+			"before": before.In(time.UTC),
+		},
+	)
+}
+
+func (n *NotificationRepositoryNeo4j) collectDeliveries(
+	ctx context.Context,
+	cypher string,
+	params map[string]interface{},
+) ([]*daos.NotificationDelivery, error) {
+	records, err := neo4jReadTxCollect(ctx, n.driver, cypher, params)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*daos.NotificationDelivery, len(records))
+	for i, record := range records {
+		deliveries[i] = daos.NewNotificationDeliveryFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return deliveries, nil
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ NotificationRepository = (*NotificationRepositorySQL)(nil)
+
+type NotificationRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepositorySQL(db *gorm.DB) *NotificationRepositorySQL {
+	return &NotificationRepositorySQL{
+		db: db,
+	}
+}
+
+func (n *NotificationRepositorySQL) CreateRule(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+) error {
+	rule.ID = uuid.New().String()
+	rule.Created = time.Now()
+
+	return gormDB(ctx, n.db).Create(rule).Error
+}
+
+func (n *NotificationRepositorySQL) GetRule(
+	ctx context.Context,
+	id string,
+) (*daos.NotificationRule, error) {
+	rule := &daos.NotificationRule{}
+	result := gormDB(ctx, n.db).Where("id = ?", id).First(rule)
+
+	return rule, convertNotFound(result.Error)
+}
+
+func (n *NotificationRepositorySQL) GetRulesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.NotificationRule, error) {
+	rules := make([]*daos.NotificationRule, 0)
+	result := gormDB(ctx, n.db).Where("user_id = ?", userID).Order("name").Find(&rules)
+
+	return rules, result.Error
+}
+
+func (n *NotificationRepositorySQL) GetRules(ctx context.Context) ([]*daos.NotificationRule, error) {
+	rules := make([]*daos.NotificationRule, 0)
+	result := gormDB(ctx, n.db).Order("created").Find(&rules)
+
+	return rules, result.Error
+}
+
+func (n *NotificationRepositorySQL) UpdateRule(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+) error {
+	result := gormDB(ctx, n.db).Select("*").Omit("user_id", "created").Updates(rule)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (n *NotificationRepositorySQL) DeleteRule(ctx context.Context, id string) error {
+	err := gormDB(ctx, n.db).Where("rule_id = ?", id).Delete(&daos.NotificationDelivery{}).Error
+	if err != nil {
+		return err
+	}
+
+	return gormDB(ctx, n.db).Delete(&daos.NotificationRule{ID: id}).Error
+}
+
+func (n *NotificationRepositorySQL) CreateDelivery(
+	ctx context.Context,
+	delivery *daos.NotificationDelivery,
+) error {
+	delivery.ID = uuid.New().String()
+	delivery.Created = time.Now()
+
+	// The scheduler runs into existing deliveries on every pass, skipping them quietly keeps
+	// them out of the error log
+	result := gormDB(ctx, n.db).Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrDuplicateRecord
+	}
+
+	return nil
+}
+
+func (n *NotificationRepositorySQL) UpdateDelivery(
+	ctx context.Context,
+	delivery *daos.NotificationDelivery,
+) error {
+	result := gormDB(ctx, n.db).Select("*").Omit("created").Updates(delivery)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (n *NotificationRepositorySQL) GetDeliveriesForRule(
+	ctx context.Context,
+	ruleID string,
+) ([]*daos.NotificationDelivery, error) {
+	deliveries := make([]*daos.NotificationDelivery, 0)
+	result := gormDB(ctx, n.db).
+		Where("rule_id = ?", ruleID).
+		Order("created DESC").
+		Find(&deliveries)
+
+	return deliveries, result.Error
+}
+
+func (n *NotificationRepositorySQL) GetDeliveriesDue(
+	ctx context.Context,
+	before time.Time,
+) ([]*daos.NotificationDelivery, error) {
+	deliveries := make([]*daos.NotificationDelivery, 0)
+	result := gormDB(ctx, n.db).
+		Where("status = ? AND next_attempt <= ?", daos.DeliveryPending, before).
+		Order("next_attempt").
+		Find(&deliveries)
+
+	return deliveries, result.Error
+}
@@ -0,0 +1,29 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+type NotificationRepository interface {
+	// CreateRule assigns the ID and creation time before storing the rule
+	CreateRule(ctx context.Context, rule *daos.NotificationRule) error
+	GetRule(ctx context.Context, id string) (*daos.NotificationRule, error)
+	GetRulesForUser(ctx context.Context, userID string) ([]*daos.NotificationRule, error)
+	// GetRules returns the rules of every user
+	GetRules(ctx context.Context) ([]*daos.NotificationRule, error)
+	UpdateRule(ctx context.Context, rule *daos.NotificationRule) error
+	// DeleteRule deletes a rule along with its deliveries
+	DeleteRule(ctx context.Context, id string) error
+
+	// CreateDelivery assigns the ID and creation time before storing the delivery. It returns
+	// ErrDuplicateRecord when the rule already has a delivery for the certificate and threshold.
+	CreateDelivery(ctx context.Context, delivery *daos.NotificationDelivery) error
+	UpdateDelivery(ctx context.Context, delivery *daos.NotificationDelivery) error
+	// GetDeliveriesForRule returns a rule's deliveries, newest first
+	GetDeliveriesForRule(ctx context.Context, ruleID string) ([]*daos.NotificationDelivery, error)
+	// GetDeliveriesDue returns the pending deliveries whose next attempt is not after before
+	GetDeliveriesDue(ctx context.Context, before time.Time) ([]*daos.NotificationDelivery, error)
+}
@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ SSHRepository = (*SSHRepositoryMemory)(nil)
+
+type SSHRepositoryMemory struct {
+	memoryTransactional
+
+	mu    sync.RWMutex
+	cas   map[string]daos.SSHCertificateAuthority
+	certs map[string]daos.SSHCertificate
+}
+
+func NewSSHRepositoryMemory() *SSHRepositoryMemory {
+	return &SSHRepositoryMemory{
+		cas:   make(map[string]daos.SSHCertificateAuthority),
+		certs: make(map[string]daos.SSHCertificate),
+	}
+}
+
+func (s *SSHRepositoryMemory) snapshot() func() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cas := make(map[string]daos.SSHCertificateAuthority, len(s.cas))
+	for id, ca := range s.cas {
+		cas[id] = ca
+	}
+
+	certs := make(map[string]daos.SSHCertificate, len(s.certs))
+	for id, cert := range s.certs {
+		certs[id] = cert
+	}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.cas = cas
+		s.certs = certs
+	}
+}
+
+func (s *SSHRepositoryMemory) CreateCA(
+	ctx context.Context,
+	ca *daos.SSHCertificateAuthority,
+) error {
+	ca.ID = uuid.New().String()
+	ca.Created = time.Now()
+
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cas[ca.ID] = *ca
+
+	return nil
+}
+
+func (s *SSHRepositoryMemory) GetCA(
+	ctx context.Context,
+	id string,
+) (*daos.SSHCertificateAuthority, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ca, ok := s.cas[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &ca, nil
+}
+
+func (s *SSHRepositoryMemory) GetCAsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.SSHCertificateAuthority, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cas := make([]*daos.SSHCertificateAuthority, 0)
+	for _, ca := range s.cas {
+		if ca.UserID == userID {
+			ca := ca
+			cas = append(cas, &ca)
+		}
+	}
+
+	sort.Slice(
+		cas, func(i, j int) bool {
+			return cas[i].Name < cas[j].Name
+		},
+	)
+
+	return cas, nil
+}
+
+func (s *SSHRepositoryMemory) CreateCertificate(
+	ctx context.Context,
+	cert *daos.SSHCertificate,
+) error {
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cas[cert.CAID]; !ok {
+		return ErrNoRecord
+	}
+
+	for _, existing := range s.certs {
+		if existing.CAID == cert.CAID && existing.Serial == cert.Serial {
+			return ErrDuplicateRecord
+		}
+	}
+
+	cert.ID = uuid.New().String()
+	cert.Created = time.Now()
+	s.certs[cert.ID] = *cert
+
+	return nil
+}
+
+func (s *SSHRepositoryMemory) GetCertificate(
+	ctx context.Context,
+	id string,
+) (*daos.SSHCertificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cert, ok := s.certs[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &cert, nil
+}
+
+func (s *SSHRepositoryMemory) GetCertificatesForCA(
+	ctx context.Context,
+	caID string,
+) ([]*daos.SSHCertificate, error) {
+	certs := s.filterCertificates(
+		func(cert *daos.SSHCertificate) bool {
+			return cert.CAID == caID
+		},
+	)
+
+	sort.Slice(
+		certs, func(i, j int) bool {
+			return certs[i].Created.After(certs[j].Created)
+		},
+	)
+
+	return certs, nil
+}
+
+func (s *SSHRepositoryMemory) GetRevokedCertificates(
+	ctx context.Context,
+	caID string,
+) ([]*daos.SSHCertificate, error) {
+	certs := s.filterCertificates(
+		func(cert *daos.SSHCertificate) bool {
+			return cert.CAID == caID && cert.IsRevoked()
+		},
+	)
+
+	sort.Slice(
+		certs, func(i, j int) bool {
+			return certs[i].Serial < certs[j].Serial
+		},
+	)
+
+	return certs, nil
+}
+
+func (s *SSHRepositoryMemory) filterCertificates(
+	match func(cert *daos.SSHCertificate) bool,
+) []*daos.SSHCertificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	certs := make([]*daos.SSHCertificate, 0)
+	for _, cert := range s.certs {
+		cert := cert
+		if match(&cert) {
+			certs = append(certs, &cert)
+		}
+	}
+
+	return certs
+}
+
+func (s *SSHRepositoryMemory) RevokeCertificate(
+	ctx context.Context,
+	id string,
+	revokedAt time.Time,
+) error {
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cert, ok := s.certs[id]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	cert.RevokedAt = &revokedAt
+	s.certs[id] = cert
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ SSHRepository = (*SSHRepositoryNeo4j)(nil)
+
+type SSHRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewSSHRepositoryNeo4j(driver neo4j.Driver) *SSHRepositoryNeo4j {
+	return &SSHRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (s *SSHRepositoryNeo4j) CreateCA(
+	ctx context.Context,
+	ca *daos.SSHCertificateAuthority,
+) error {
+	ca.ID = uuid.New().String()
+	ca.Created = time.Now()
+
+	cypher := `MATCH (u:User {uuid: $userID})
+				CREATE (u)-[:HAS_SSH_CA]->(a:SSHCertificateAuthority)
+				SET a = $props`
+
+	return neo4jWriteTx(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"userID": ca.UserID,
+			"props":  ca.Props(),
+		},
+	)
+}
+
+func (s *SSHRepositoryNeo4j) GetCA(
+	ctx context.Context,
+	id string,
+) (*daos.SSHCertificateAuthority, error) {
+	cypher := `MATCH (a:SSHCertificateAuthority {uuid: $uuid}) RETURN a`
+	record, err := neo4jReadTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewSSHCertificateAuthorityFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (s *SSHRepositoryNeo4j) GetCAsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.SSHCertificateAuthority, error) {
+	cypher := `MATCH (:User {uuid: $userID})-[:HAS_SSH_CA]->(a:SSHCertificateAuthority)
+				RETURN a ORDER BY a.name`
+	records, err := neo4jReadTxCollect(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"userID": userID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cas := make([]*daos.SSHCertificateAuthority, len(records))
+	for i, record := range records {
+		cas[i] = daos.NewSSHCertificateAuthorityFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return cas, nil
+}
+
+func (s *SSHRepositoryNeo4j) CreateCertificate(
+	ctx context.Context,
+	cert *daos.SSHCertificate,
+) error {
+	cert.ID = uuid.New().String()
+	cert.Created = time.Now()
+
+	// Community edition can't enforce uniqueness over several properties, so the CA and serial
+	// are combined into one constrained property
+	props := cert.Props()
+	props["caSerial"] = fmt.Sprintf("%s/%d", cert.CAID, cert.Serial)
+
+	cypher := `MATCH (a:SSHCertificateAuthority {uuid: $caID})
+				CREATE (a)-[:ISSUED_SSH_CERTIFICATE]->(c:SSHCertificate)
+				SET c = $props
+				RETURN c.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"caID":  cert.CAID,
+			"props": props,
+		},
+	)
+
+	return neo4jDuplicate(neo4jNotFound(err))
+}
+
+func (s *SSHRepositoryNeo4j) GetCertificate(
+	ctx context.Context,
+	id string,
+) (*daos.SSHCertificate, error) {
+	cypher := `MATCH (c:SSHCertificate {uuid: $uuid}) RETURN c`
+	record, err := neo4jReadTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewSSHCertificateFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (s *SSHRepositoryNeo4j) GetCertificatesForCA(
+	ctx context.Context,
+	caID string,
+) ([]*daos.SSHCertificate, error) {
+	cypher := `MATCH (:SSHCertificateAuthority {uuid: $caID})-[:ISSUED_SSH_CERTIFICATE]->
+				(c:SSHCertificate)
+				RETURN c ORDER BY c.created DESC`
+
+	return s.collectCertificates(ctx, cypher, caID)
+}
+
+func (s *SSHRepositoryNeo4j) GetRevokedCertificates(
+	ctx context.Context,
+	caID string,
+) ([]*daos.SSHCertificate, error) {
+	cypher := `MATCH (:SSHCertificateAuthority {uuid: $caID})-[:ISSUED_SSH_CERTIFICATE]->
+				(c:SSHCertificate)
+				WHERE c.revokedAt IS NOT NULL
+				RETURN c ORDER BY c.serial`
+
+	return s.collectCertificates(ctx, cypher, caID)
+}
+
+func (s *SSHRepositoryNeo4j) collectCertificates(
+	ctx context.Context,
+	cypher string,
+	caID string,
+) ([]*daos.SSHCertificate, error) {
+	records, err := neo4jReadTxCollect(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"caID": caID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]*daos.SSHCertificate, len(records))
+	for i, record := range records {
+		certs[i] = daos.NewSSHCertificateFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return certs, nil
+}
+
+func (s *SSHRepositoryNeo4j) RevokeCertificate(
+	ctx context.Context,
+	id string,
+	revokedAt time.Time,
+) error {
+	cypher := `MATCH (c:SSHCertificate {uuid: $uuid})
+				SET c.revokedAt = $revokedAt
+				RETURN c.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"uuid":      id,
+			"revokedAt": revokedAt.In(time.UTC),
+		},
+	)
+
+	return neo4jNotFound(err)
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ SSHRepository = (*SSHRepositorySQL)(nil)
+
+type SSHRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewSSHRepositorySQL(db *gorm.DB) *SSHRepositorySQL {
+	return &SSHRepositorySQL{
+		db: db,
+	}
+}
+
+func (s *SSHRepositorySQL) CreateCA(ctx context.Context, ca *daos.SSHCertificateAuthority) error {
+	ca.ID = uuid.New().String()
+	ca.Created = time.Now()
+
+	return gormDB(ctx, s.db).Create(ca).Error
+}
+
+func (s *SSHRepositorySQL) GetCA(
+	ctx context.Context,
+	id string,
+) (*daos.SSHCertificateAuthority, error) {
+	ca := &daos.SSHCertificateAuthority{}
+	result := gormDB(ctx, s.db).Where("id = ?", id).First(ca)
+
+	return ca, convertNotFound(result.Error)
+}
+
+func (s *SSHRepositorySQL) GetCAsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.SSHCertificateAuthority, error) {
+	cas := make([]*daos.SSHCertificateAuthority, 0)
+	result := gormDB(ctx, s.db).Where("user_id = ?", userID).Order("name").Find(&cas)
+
+	return cas, result.Error
+}
+
+func (s *SSHRepositorySQL) CreateCertificate(
+	ctx context.Context,
+	cert *daos.SSHCertificate,
+) error {
+	cert.ID = uuid.New().String()
+	cert.Created = time.Now()
+
+	return convertDuplicate(gormDB(ctx, s.db).Create(cert).Error)
+}
+
+func (s *SSHRepositorySQL) GetCertificate(
+	ctx context.Context,
+	id string,
+) (*daos.SSHCertificate, error) {
+	cert := &daos.SSHCertificate{}
+	result := gormDB(ctx, s.db).Where("id = ?", id).First(cert)
+
+	return cert, convertNotFound(result.Error)
+}
+
+func (s *SSHRepositorySQL) GetCertificatesForCA(
+	ctx context.Context,
+	caID string,
+) ([]*daos.SSHCertificate, error) {
+	certs := make([]*daos.SSHCertificate, 0)
+	result := gormDB(ctx, s.db).Where("ca_id = ?", caID).Order("created DESC").Find(&certs)
+
+	return certs, result.Error
+}
+
+func (s *SSHRepositorySQL) GetRevokedCertificates(
+	ctx context.Context,
+	caID string,
+) ([]*daos.SSHCertificate, error) {
+	certs := make([]*daos.SSHCertificate, 0)
+	result := gormDB(ctx, s.db).
+		Where("ca_id = ? AND revoked_at IS NOT NULL", caID).
+		Order("serial").
+		Find(&certs)
+
+	return certs, result.Error
+}
+
+func (s *SSHRepositorySQL) RevokeCertificate(
+	ctx context.Context,
+	id string,
+	revokedAt time.Time,
+) error {
+	result := gormDB(ctx, s.db).
+		Model(&daos.SSHCertificate{ID: id}).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+type SSHRepository interface {
+	// CreateCA assigns the ID and creation time before storing the CA
+	CreateCA(ctx context.Context, ca *daos.SSHCertificateAuthority) error
+	GetCA(ctx context.Context, id string) (*daos.SSHCertificateAuthority, error)
+	GetCAsForUser(ctx context.Context, userID string) ([]*daos.SSHCertificateAuthority, error)
+
+	// CreateCertificate assigns the ID and creation time before storing the certificate. It
+	// returns ErrDuplicateRecord when the CA already issued the serial.
+	CreateCertificate(ctx context.Context, cert *daos.SSHCertificate) error
+	GetCertificate(ctx context.Context, id string) (*daos.SSHCertificate, error)
+	// GetCertificatesForCA returns the certificates a CA issued, newest first
+	GetCertificatesForCA(ctx context.Context, caID string) ([]*daos.SSHCertificate, error)
+	GetRevokedCertificates(ctx context.Context, caID string) ([]*daos.SSHCertificate, error)
+	RevokeCertificate(ctx context.Context, id string, revokedAt time.Time) error
+}
@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+)
+
+var _ TimestampRepository = (*TimestampRepositoryMemory)(nil)
+
+type TimestampRepositoryMemory struct {
+	memoryTransactional
+
+	mu          sync.RWMutex
+	authorities map[string]daos.TimestampAuthority
+	tokens      map[string]daos.Timestamp
+}
+
+func NewTimestampRepositoryMemory() *TimestampRepositoryMemory {
+	return &TimestampRepositoryMemory{
+		authorities: make(map[string]daos.TimestampAuthority),
+		tokens:      make(map[string]daos.Timestamp),
+	}
+}
+
+func (s *TimestampRepositoryMemory) snapshot() func() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	authorities := make(map[string]daos.TimestampAuthority, len(s.authorities))
+	for id, tsa := range s.authorities {
+		authorities[id] = tsa
+	}
+
+	tokens := make(map[string]daos.Timestamp, len(s.tokens))
+	for id, token := range s.tokens {
+		tokens[id] = token
+	}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.authorities = authorities
+		s.tokens = tokens
+	}
+}
+
+func (s *TimestampRepositoryMemory) CreateAuthority(
+	ctx context.Context,
+	tsa *daos.TimestampAuthority,
+) error {
+	tsa.ID = uuid.New().String()
+	tsa.Created = time.Now()
+
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.authorities[tsa.ID] = *tsa
+
+	return nil
+}
+
+func (s *TimestampRepositoryMemory) GetAuthority(
+	ctx context.Context,
+	id string,
+) (*daos.TimestampAuthority, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tsa, ok := s.authorities[id]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &tsa, nil
+}
+
+func (s *TimestampRepositoryMemory) GetAuthoritiesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.TimestampAuthority, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tsas := make([]*daos.TimestampAuthority, 0)
+	for _, tsa := range s.authorities {
+		if tsa.UserID == userID {
+			tsa := tsa
+			tsas = append(tsas, &tsa)
+		}
+	}
+
+	sort.Slice(
+		tsas, func(i, j int) bool {
+			return tsas[i].Name < tsas[j].Name
+		},
+	)
+
+	return tsas, nil
+}
+
+func (s *TimestampRepositoryMemory) CreateToken(ctx context.Context, token *daos.Timestamp) error {
+	defer s.beginWrite(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.authorities[token.TSAID]; !ok {
+		return ErrNoRecord
+	}
+
+	for _, existing := range s.tokens {
+		if existing.TSAID == token.TSAID && existing.SerialNumber == token.SerialNumber {
+			return ErrDuplicateRecord
+		}
+	}
+
+	token.ID = uuid.New().String()
+	s.tokens[token.ID] = *token
+
+	return nil
+}
+
+func (s *TimestampRepositoryMemory) GetTokenBySerial(
+	ctx context.Context,
+	tsaID string,
+	serialNumber string,
+) (*daos.Timestamp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.TSAID == tsaID && token.SerialNumber == serialNumber {
+			return &token, nil
+		}
+	}
+
+	return nil, ErrNoRecord
+}
+
+func (s *TimestampRepositoryMemory) GetTokensForAuthority(
+	ctx context.Context,
+	tsaID string,
+) ([]*daos.Timestamp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]*daos.Timestamp, 0)
+	for _, token := range s.tokens {
+		if token.TSAID == tsaID {
+			token := token
+			tokens = append(tokens, &token)
+		}
+	}
+
+	sort.Slice(
+		tokens, func(i, j int) bool {
+			return tokens[i].GenTime.After(tokens[j].GenTime)
+		},
+	)
+
+	return tokens, nil
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ TimestampRepository = (*TimestampRepositoryNeo4j)(nil)
+
+type TimestampRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewTimestampRepositoryNeo4j(driver neo4j.Driver) *TimestampRepositoryNeo4j {
+	return &TimestampRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (s *TimestampRepositoryNeo4j) CreateAuthority(
+	ctx context.Context,
+	tsa *daos.TimestampAuthority,
+) error {
+	tsa.ID = uuid.New().String()
+	tsa.Created = time.Now()
+
+	cypher := `MATCH (u:User {uuid: $userID})
+				CREATE (u)-[:HAS_TSA]->(a:TimestampAuthority)
+				SET a = $props`
+
+	return neo4jWriteTx(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"userID": tsa.UserID,
+			"props":  tsa.Props(),
+		},
+	)
+}
+
+func (s *TimestampRepositoryNeo4j) GetAuthority(
+	ctx context.Context,
+	id string,
+) (*daos.TimestampAuthority, error) {
+	cypher := `MATCH (a:TimestampAuthority {uuid: $uuid}) RETURN a`
+	record, err := neo4jReadTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"uuid": id,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewTimestampAuthorityFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (s *TimestampRepositoryNeo4j) GetAuthoritiesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.TimestampAuthority, error) {
+	cypher := `MATCH (:User {uuid: $userID})-[:HAS_TSA]->(a:TimestampAuthority)
+				RETURN a ORDER BY a.name`
+	records, err := neo4jReadTxCollect(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"userID": userID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tsas := make([]*daos.TimestampAuthority, len(records))
+	for i, record := range records {
+		tsas[i] = daos.NewTimestampAuthorityFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return tsas, nil
+}
+
+func (s *TimestampRepositoryNeo4j) CreateToken(ctx context.Context, token *daos.Timestamp) error {
+	token.ID = uuid.New().String()
+
+	// Community edition can't enforce uniqueness over several properties, so the TSA and serial
+	// are combined into one constrained property
+	props := token.Props()
+	props["tsaSerial"] = token.TSAID + "/" + token.SerialNumber
+
+	cypher := `MATCH (a:TimestampAuthority {uuid: $tsaID})
+				CREATE (a)-[:ISSUED_TIMESTAMP]->(t:Timestamp)
+				SET t = $props
+				RETURN t.uuid`
+	_, err := neo4jWriteTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"tsaID": token.TSAID,
+			"props": props,
+		},
+	)
+
+	return neo4jDuplicate(neo4jNotFound(err))
+}
+
+func (s *TimestampRepositoryNeo4j) GetTokenBySerial(
+	ctx context.Context,
+	tsaID string,
+	serialNumber string,
+) (*daos.Timestamp, error) {
+	cypher := `MATCH (t:Timestamp {tsaSerial: $tsaSerial}) RETURN t`
+	record, err := neo4jReadTxSingle(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"tsaSerial": tsaID + "/" + serialNumber,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewTimestampFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (s *TimestampRepositoryNeo4j) GetTokensForAuthority(
+	ctx context.Context,
+	tsaID string,
+) ([]*daos.Timestamp, error) {
+	cypher := `MATCH (:TimestampAuthority {uuid: $tsaID})-[:ISSUED_TIMESTAMP]->(t:Timestamp)
+				RETURN t ORDER BY t.genTime DESC`
+	records, err := neo4jReadTxCollect(
+		ctx, s.driver, cypher, map[string]interface{}{
+			"tsaID": tsaID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]*daos.Timestamp, len(records))
+	for i, record := range records {
+		tokens[i] = daos.NewTimestampFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return tokens, nil
+}
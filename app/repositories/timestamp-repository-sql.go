@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var _ TimestampRepository = (*TimestampRepositorySQL)(nil)
+
+type TimestampRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewTimestampRepositorySQL(db *gorm.DB) *TimestampRepositorySQL {
+	return &TimestampRepositorySQL{
+		db: db,
+	}
+}
+
+func (s *TimestampRepositorySQL) CreateAuthority(
+	ctx context.Context,
+	tsa *daos.TimestampAuthority,
+) error {
+	tsa.ID = uuid.New().String()
+	tsa.Created = time.Now()
+
+	return gormDB(ctx, s.db).Create(tsa).Error
+}
+
+func (s *TimestampRepositorySQL) GetAuthority(
+	ctx context.Context,
+	id string,
+) (*daos.TimestampAuthority, error) {
+	tsa := &daos.TimestampAuthority{}
+	result := gormDB(ctx, s.db).Where("id = ?", id).First(tsa)
+
+	return tsa, convertNotFound(result.Error)
+}
+
+func (s *TimestampRepositorySQL) GetAuthoritiesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*daos.TimestampAuthority, error) {
+	tsas := make([]*daos.TimestampAuthority, 0)
+	result := gormDB(ctx, s.db).Where("user_id = ?", userID).Order("name").Find(&tsas)
+
+	return tsas, result.Error
+}
+
+func (s *TimestampRepositorySQL) CreateToken(ctx context.Context, token *daos.Timestamp) error {
+	token.ID = uuid.New().String()
+
+	return convertDuplicate(gormDB(ctx, s.db).Create(token).Error)
+}
+
+func (s *TimestampRepositorySQL) GetTokenBySerial(
+	ctx context.Context,
+	tsaID string,
+	serialNumber string,
+) (*daos.Timestamp, error) {
+	token := &daos.Timestamp{}
+	result := gormDB(ctx, s.db).
+		Where("tsa_id = ? AND serial_number = ?", tsaID, serialNumber).
+		First(token)
+
+	return token, convertNotFound(result.Error)
+}
+
+func (s *TimestampRepositorySQL) GetTokensForAuthority(
+	ctx context.Context,
+	tsaID string,
+) ([]*daos.Timestamp, error) {
+	tokens := make([]*daos.Timestamp, 0)
+	result := gormDB(ctx, s.db).Where("tsa_id = ?", tsaID).Order("gen_time DESC").Find(&tokens)
+
+	return tokens, result.Error
+}
@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+type TimestampRepository interface {
+	// CreateAuthority assigns the ID and creation time before storing the TSA
+	CreateAuthority(ctx context.Context, tsa *daos.TimestampAuthority) error
+	GetAuthority(ctx context.Context, id string) (*daos.TimestampAuthority, error)
+	GetAuthoritiesForUser(ctx context.Context, userID string) ([]*daos.TimestampAuthority, error)
+
+	// CreateToken assigns the ID before storing the token. It returns ErrDuplicateRecord when the
+	// TSA already issued the serial.
+	CreateToken(ctx context.Context, token *daos.Timestamp) error
+	GetTokenBySerial(ctx context.Context, tsaID string, serialNumber string) (*daos.Timestamp, error)
+	// GetTokensForAuthority returns the tokens a TSA issued, newest first
+	GetTokensForAuthority(ctx context.Context, tsaID string) ([]*daos.Timestamp, error)
+}
@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+)
+
+var _ Transactor = (*TransactorMemory)(nil)
+
+// memorySnapshotter is implemented by the memory repositories so a failed transaction can put
+// back their previous contents
+type memorySnapshotter interface {
+	// snapshot copies the current contents and returns a function restoring them
+	snapshot() func()
+	setTransactor(transactor *TransactorMemory)
+}
+
+// memoryTransactional is embedded by the memory repositories. Once covered by a transactor, their
+// writes made outside a transaction wait for the open one to finish, so restoring a snapshot on
+// rollback can't discard them.
+type memoryTransactional struct {
+	transactor *TransactorMemory
+}
+
+func (m *memoryTransactional) setTransactor(transactor *TransactorMemory) {
+	m.transactor = transactor
+}
+
+// beginWrite holds off a write until no transaction is open, unless ctx belongs to that
+// transaction, and returns the function ending it. It is taken before the repository's own lock.
+func (m *memoryTransactional) beginWrite(ctx context.Context) func() {
+	if m.transactor == nil || ctx.Value(memoryTransactionKey) == m.transactor {
+		return func() {}
+	}
+
+	m.transactor.mu.Lock()
+	return m.transactor.mu.Unlock
+}
+
+// TransactorMemory serializes transactions and restores every repository it covers when one
+// fails. Writes to those repositories outside a transaction wait for the open one to finish.
+type TransactorMemory struct {
+	mu           sync.Mutex
+	repositories []memorySnapshotter
+}
+
+func NewTransactorMemory(repositories ...memorySnapshotter) *TransactorMemory {
+	t := &TransactorMemory{
+		repositories: repositories,
+	}
+
+	for _, repository := range repositories {
+		repository.setTransactor(t)
+	}
+
+	return t
+}
+
+func (t *TransactorMemory) WithinTransaction(
+	ctx context.Context,
+	fn func(ctx context.Context) error,
+) error {
+	if ctx.Value(memoryTransactionKey) != nil {
+		return fn(ctx)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	restores := make([]func(), len(t.repositories))
+	for i, repository := range t.repositories {
+		restores[i] = repository.snapshot()
+	}
+
+	err := fn(context.WithValue(ctx, memoryTransactionKey, t))
+	if err != nil {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+
+	return err
+}
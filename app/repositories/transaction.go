@@ -0,0 +1,27 @@
+package repositories
+
+import "context"
+
+type transactionKey int
+
+const (
+	gormTransactionKey transactionKey = iota
+	neo4jTransactionKey
+	memoryTransactionKey
+)
+
+// Transactor runs multi-step operations atomically across repositories. Every repository call
+// made with the context handed to fn joins the transaction, which is rolled back when fn returns
+// an error. Calls made while a transaction is already open join it instead of nesting.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// withoutTransaction hides any transaction open on ctx, so calls made with the returned context
+// commit on their own whatever happens to it
+func withoutTransaction(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, gormTransactionKey, nil)
+	ctx = context.WithValue(ctx, neo4jTransactionKey, nil)
+
+	return context.WithValue(ctx, memoryTransactionKey, nil)
+}
@@ -0,0 +1,303 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+var _ TransparencyLogRepository = (*TransparencyLogRepositoryMemory)(nil)
+
+type TransparencyLogRepositoryMemory struct {
+	memoryTransactional
+
+	mu      sync.RWMutex
+	logs    map[string]daos.TransparencyLog
+	entries map[string][]daos.TransparencyLogEntry
+	nodes   map[transparencyLogNodeKey][]byte
+}
+
+type transparencyLogNodeKey struct {
+	caID string
+	TransparencyLogNodeID
+}
+
+func NewTransparencyLogRepositoryMemory() *TransparencyLogRepositoryMemory {
+	return &TransparencyLogRepositoryMemory{
+		logs:    make(map[string]daos.TransparencyLog),
+		entries: make(map[string][]daos.TransparencyLogEntry),
+		nodes:   make(map[transparencyLogNodeKey][]byte),
+	}
+}
+
+func (t *TransparencyLogRepositoryMemory) snapshot() func() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	logs := make(map[string]daos.TransparencyLog, len(t.logs))
+	for caID, log := range t.logs {
+		logs[caID] = log
+	}
+
+	// Entries are never changed in place, so the slices can be shared
+	entries := make(map[string][]daos.TransparencyLogEntry, len(t.entries))
+	for caID, logEntries := range t.entries {
+		entries[caID] = logEntries[:len(logEntries):len(logEntries)]
+	}
+
+	nodes := make(map[transparencyLogNodeKey][]byte, len(t.nodes))
+	for key, hash := range t.nodes {
+		nodes[key] = hash
+	}
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		t.logs = logs
+		t.entries = entries
+		t.nodes = nodes
+	}
+}
+
+func (t *TransparencyLogRepositoryMemory) EnsureLog(ctx context.Context, caID string) error {
+	defer t.beginWrite(ctx)()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.logs[caID]; !ok {
+		t.logs[caID] = daos.TransparencyLog{
+			CAID:    caID,
+			Created: time.Now(),
+		}
+	}
+
+	return nil
+}
+
+func (t *TransparencyLogRepositoryMemory) GetLog(
+	ctx context.Context,
+	caID string,
+) (*daos.TransparencyLog, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	log, ok := t.logs[caID]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &log, nil
+}
+
+func (t *TransparencyLogRepositoryMemory) SetLogKey(
+	ctx context.Context,
+	caID string,
+	publicKey []byte,
+	sealedPrivateKey []byte,
+) error {
+	defer t.beginWrite(ctx)()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	log, ok := t.logs[caID]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	if log.PublicKey != nil {
+		return ErrDuplicateRecord
+	}
+
+	log.PublicKey = publicKey
+	log.SealedPrivateKey = sealedPrivateKey
+	t.logs[caID] = log
+
+	return nil
+}
+
+func (t *TransparencyLogRepositoryMemory) AppendEntry(
+	ctx context.Context,
+	entry *daos.TransparencyLogEntry,
+) error {
+	defer t.beginWrite(ctx)()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	log, ok := t.logs[entry.CAID]
+	if !ok {
+		return ErrNoRecord
+	}
+
+	entry.LeafIndex = log.TreeSize
+	log.TreeSize++
+	t.logs[entry.CAID] = log
+	t.entries[entry.CAID] = append(t.entries[entry.CAID], *entry)
+
+	return nil
+}
+
+func (t *TransparencyLogRepositoryMemory) SetTreeHead(
+	ctx context.Context,
+	caID string,
+	treeSize int64,
+	timestamp int64,
+	rootHash []byte,
+	signature []byte,
+) error {
+	defer t.beginWrite(ctx)()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	log, ok := t.logs[caID]
+	if !ok || log.TreeSize != treeSize {
+		return nil
+	}
+
+	log.TreeHeadSize = treeSize
+	log.TreeHeadTimestamp = timestamp
+	log.RootHash = rootHash
+	log.TreeHeadSignature = signature
+	t.logs[caID] = log
+
+	return nil
+}
+
+func (t *TransparencyLogRepositoryMemory) GetLeafHashes(
+	ctx context.Context,
+	caID string,
+	start int64,
+	end int64,
+) ([][]byte, error) {
+	entries := t.filterEntries(
+		caID, func(entry *daos.TransparencyLogEntry) bool {
+			return entry.LeafIndex >= start && entry.LeafIndex < end
+		},
+	)
+
+	hashes := make([][]byte, len(entries))
+	for i, entry := range entries {
+		hashes[i] = entry.LeafHash
+	}
+
+	return hashes, nil
+}
+
+func (t *TransparencyLogRepositoryMemory) AddNodes(
+	ctx context.Context,
+	nodes []*daos.TransparencyLogNode,
+) error {
+	defer t.beginWrite(ctx)()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, node := range nodes {
+		key := transparencyLogNodeKey{
+			caID: node.CAID,
+			TransparencyLogNodeID: TransparencyLogNodeID{
+				Level:     node.Level,
+				NodeIndex: node.NodeIndex,
+			},
+		}
+		if _, ok := t.nodes[key]; !ok {
+			t.nodes[key] = node.Hash
+		}
+	}
+
+	return nil
+}
+
+func (t *TransparencyLogRepositoryMemory) GetNodes(
+	ctx context.Context,
+	caID string,
+	ids []TransparencyLogNodeID,
+) ([]*daos.TransparencyLogNode, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	nodes := make([]*daos.TransparencyLogNode, 0, len(ids))
+	for _, id := range ids {
+		hash, ok := t.nodes[transparencyLogNodeKey{caID: caID, TransparencyLogNodeID: id}]
+		if ok {
+			nodes = append(
+				nodes, &daos.TransparencyLogNode{
+					CAID:      caID,
+					Level:     id.Level,
+					NodeIndex: id.NodeIndex,
+					Hash:      hash,
+				},
+			)
+		}
+	}
+
+	return nodes, nil
+}
+
+func (t *TransparencyLogRepositoryMemory) GetEntries(
+	ctx context.Context,
+	caID string,
+	start int64,
+	end int64,
+) ([]*daos.TransparencyLogEntry, error) {
+	return t.filterEntries(
+		caID, func(entry *daos.TransparencyLogEntry) bool {
+			return entry.LeafIndex >= start && entry.LeafIndex <= end
+		},
+	), nil
+}
+
+func (t *TransparencyLogRepositoryMemory) GetEntryByLeafHash(
+	ctx context.Context,
+	caID string,
+	leafHash []byte,
+) (*daos.TransparencyLogEntry, error) {
+	entries := t.filterEntries(
+		caID, func(entry *daos.TransparencyLogEntry) bool {
+			return bytes.Equal(entry.LeafHash, leafHash)
+		},
+	)
+	if len(entries) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return entries[0], nil
+}
+
+func (t *TransparencyLogRepositoryMemory) GetEntryByCertificateID(
+	ctx context.Context,
+	caID string,
+	certificateID string,
+) (*daos.TransparencyLogEntry, error) {
+	entries := t.filterEntries(
+		caID, func(entry *daos.TransparencyLogEntry) bool {
+			return entry.CertificateID == certificateID
+		},
+	)
+	if len(entries) == 0 {
+		return nil, ErrNoRecord
+	}
+
+	return entries[0], nil
+}
+
+// filterEntries returns the matching entries of a log in leaf order
+func (t *TransparencyLogRepositoryMemory) filterEntries(
+	caID string,
+	match func(entry *daos.TransparencyLogEntry) bool,
+) []*daos.TransparencyLogEntry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	entries := make([]*daos.TransparencyLogEntry, 0)
+	for _, entry := range t.entries[caID] {
+		entry := entry
+		if match(&entry) {
+			entries = append(entries, &entry)
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,275 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/neo4j/neo4j-go-driver/v4/neo4j"
+)
+
+var _ TransparencyLogRepository = (*TransparencyLogRepositoryNeo4j)(nil)
+
+type TransparencyLogRepositoryNeo4j struct {
+	driver neo4j.Driver
+}
+
+func NewTransparencyLogRepositoryNeo4j(driver neo4j.Driver) *TransparencyLogRepositoryNeo4j {
+	return &TransparencyLogRepositoryNeo4j{
+		driver: driver,
+	}
+}
+
+func (t *TransparencyLogRepositoryNeo4j) EnsureLog(ctx context.Context, caID string) error {
+	cypher := `MERGE (l:TransparencyLog {caID: $caID})
+				ON CREATE SET l.treeSize = 0, l.created = $created`
+
+	return neo4jWriteTx(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID":    caID,
+			"created": time.Now(),
+		},
+	)
+}
+
+func (t *TransparencyLogRepositoryNeo4j) GetLog(
+	ctx context.Context,
+	caID string,
+) (*daos.TransparencyLog, error) {
+	cypher := `MATCH (l:TransparencyLog {caID: $caID}) RETURN l`
+	record, err := neo4jReadTxSingle(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID": caID,
+		},
+	)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewTransparencyLogFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
+
+func (t *TransparencyLogRepositoryNeo4j) SetLogKey(
+	ctx context.Context,
+	caID string,
+	publicKey []byte,
+	sealedPrivateKey []byte,
+) error {
+	cypher := `MATCH (l:TransparencyLog {caID: $caID})
+				WITH l, l.publicKey IS NULL AS unset
+				FOREACH (_ IN CASE WHEN unset THEN [1] ELSE [] END |
+					SET l.publicKey = $publicKey, l.sealedPrivateKey = $sealedPrivateKey)
+				RETURN unset`
+	record, err := neo4jWriteTxSingle(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID":             caID,
+			"publicKey":        publicKey,
+			"sealedPrivateKey": sealedPrivateKey,
+		},
+	)
+	if err != nil {
+		return neo4jNotFound(err)
+	}
+
+	if !record.Values[0].(bool) {
+		return ErrDuplicateRecord
+	}
+
+	return nil
+}
+
+func (t *TransparencyLogRepositoryNeo4j) AppendEntry(
+	ctx context.Context,
+	entry *daos.TransparencyLogEntry,
+) error {
+	// Setting the size write locks the log node, holding back other appends
+	cypher := `MATCH (l:TransparencyLog {caID: $caID})
+				SET l.treeSize = l.treeSize + 1
+				CREATE (l)-[:HAS_ENTRY]->(e:TransparencyLogEntry)
+				SET e = $props, e.leafIndex = l.treeSize - 1
+				RETURN e.leafIndex`
+	record, err := neo4jWriteTxSingle(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID":  entry.CAID,
+			"props": entry.Props(),
+		},
+	)
+	if err != nil {
+		return neo4jNotFound(err)
+	}
+
+	entry.LeafIndex = record.Values[0].(int64)
+
+	return nil
+}
+
+func (t *TransparencyLogRepositoryNeo4j) SetTreeHead(
+	ctx context.Context,
+	caID string,
+	treeSize int64,
+	timestamp int64,
+	rootHash []byte,
+	signature []byte,
+) error {
+	cypher := `MATCH (l:TransparencyLog {caID: $caID}) WHERE l.treeSize = $treeSize
+				SET l.treeHeadSize = $treeSize, l.treeHeadTimestamp = $timestamp,
+					l.rootHash = $rootHash, l.treeHeadSignature = $signature`
+
+	return neo4jWriteTx(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID":      caID,
+			"treeSize":  treeSize,
+			"timestamp": timestamp,
+			"rootHash":  rootHash,
+			"signature": signature,
+		},
+	)
+}
+
+func (t *TransparencyLogRepositoryNeo4j) GetLeafHashes(
+	ctx context.Context,
+	caID string,
+	start int64,
+	end int64,
+) ([][]byte, error) {
+	cypher := `MATCH (e:TransparencyLogEntry {caID: $caID})
+				WHERE e.leafIndex >= $start AND e.leafIndex < $end
+				RETURN e.leafHash ORDER BY e.leafIndex`
+	records, err := neo4jReadTxCollect(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID":  caID,
+			"start": start,
+			"end":   end,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([][]byte, len(records))
+	for i, record := range records {
+		hashes[i] = record.Values[0].([]byte)
+	}
+
+	return hashes, nil
+}
+
+func (t *TransparencyLogRepositoryNeo4j) AddNodes(
+	ctx context.Context,
+	nodes []*daos.TransparencyLogNode,
+) error {
+	props := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		props[i] = node.Props()
+	}
+
+	cypher := `UNWIND $nodes AS node
+				MERGE (n:TransparencyLogNode
+					{caID: node.caID, level: node.level, nodeIndex: node.nodeIndex})
+				ON CREATE SET n.hash = node.hash`
+
+	return neo4jWriteTx(ctx, t.driver, cypher, map[string]interface{}{"nodes": props})
+}
+
+func (t *TransparencyLogRepositoryNeo4j) GetNodes(
+	ctx context.Context,
+	caID string,
+	ids []TransparencyLogNodeID,
+) ([]*daos.TransparencyLogNode, error) {
+	params := make([]interface{}, len(ids))
+	for i, id := range ids {
+		params[i] = map[string]interface{}{
+			"level":     int64(id.Level),
+			"nodeIndex": id.NodeIndex,
+		}
+	}
+
+	cypher := `UNWIND $ids AS id
+				MATCH (n:TransparencyLogNode
+					{caID: $caID, level: id.level, nodeIndex: id.nodeIndex})
+				RETURN n`
+	records, err := neo4jReadTxCollect(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID": caID,
+			"ids":  params,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*daos.TransparencyLogNode, len(records))
+	for i, record := range records {
+		nodes[i] = daos.NewTransparencyLogNodeFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return nodes, nil
+}
+
+func (t *TransparencyLogRepositoryNeo4j) GetEntries(
+	ctx context.Context,
+	caID string,
+	start int64,
+	end int64,
+) ([]*daos.TransparencyLogEntry, error) {
+	cypher := `MATCH (e:TransparencyLogEntry {caID: $caID})
+				WHERE e.leafIndex >= $start AND e.leafIndex <= $end
+				RETURN e ORDER BY e.leafIndex`
+	records, err := neo4jReadTxCollect(
+		ctx, t.driver, cypher, map[string]interface{}{
+			"caID":  caID,
+			"start": start,
+			"end":   end,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*daos.TransparencyLogEntry, len(records))
+	for i, record := range records {
+		entries[i] = daos.NewTransparencyLogEntryFromProps(record.Values[0].(neo4j.Node).Props)
+	}
+
+	return entries, nil
+}
+
+func (t *TransparencyLogRepositoryNeo4j) GetEntryByLeafHash(
+	ctx context.Context,
+	caID string,
+	leafHash []byte,
+) (*daos.TransparencyLogEntry, error) {
+	cypher := `MATCH (e:TransparencyLogEntry {caID: $caID}) WHERE e.leafHash = $leafHash
+				RETURN e ORDER BY e.leafIndex LIMIT 1`
+
+	return t.getEntry(ctx, cypher, map[string]interface{}{"caID": caID, "leafHash": leafHash})
+}
+
+func (t *TransparencyLogRepositoryNeo4j) GetEntryByCertificateID(
+	ctx context.Context,
+	caID string,
+	certificateID string,
+) (*daos.TransparencyLogEntry, error) {
+	cypher := `MATCH (e:TransparencyLogEntry {caID: $caID, certificateID: $certificateID})
+				RETURN e LIMIT 1`
+
+	return t.getEntry(
+		ctx, cypher, map[string]interface{}{
+			"caID":          caID,
+			"certificateID": certificateID,
+		},
+	)
+}
+
+func (t *TransparencyLogRepositoryNeo4j) getEntry(
+	ctx context.Context,
+	cypher string,
+	params map[string]interface{},
+) (*daos.TransparencyLogEntry, error) {
+	record, err := neo4jReadTxSingle(ctx, t.driver, cypher, params)
+	if err != nil {
+		return nil, neo4jNotFound(err)
+	}
+
+	return daos.NewTransparencyLogEntryFromProps(record.Values[0].(neo4j.Node).Props), nil
+}
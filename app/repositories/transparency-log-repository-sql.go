@@ -0,0 +1,215 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var _ TransparencyLogRepository = (*TransparencyLogRepositorySQL)(nil)
+
+type TransparencyLogRepositorySQL struct {
+	db *gorm.DB
+}
+
+func NewTransparencyLogRepositorySQL(db *gorm.DB) *TransparencyLogRepositorySQL {
+	return &TransparencyLogRepositorySQL{
+		db: db,
+	}
+}
+
+func (t *TransparencyLogRepositorySQL) EnsureLog(ctx context.Context, caID string) error {
+	log := &daos.TransparencyLog{
+		CAID:    caID,
+		Created: time.Now(),
+	}
+
+	return gormDB(ctx, t.db).Clauses(clause.OnConflict{DoNothing: true}).Create(log).Error
+}
+
+func (t *TransparencyLogRepositorySQL) GetLog(
+	ctx context.Context,
+	caID string,
+) (*daos.TransparencyLog, error) {
+	log := &daos.TransparencyLog{}
+	result := gormDB(ctx, t.db).Where("ca_id = ?", caID).First(log)
+
+	return log, convertNotFound(result.Error)
+}
+
+func (t *TransparencyLogRepositorySQL) SetLogKey(
+	ctx context.Context,
+	caID string,
+	publicKey []byte,
+	sealedPrivateKey []byte,
+) error {
+	result := gormDB(ctx, t.db).
+		Model(&daos.TransparencyLog{}).
+		Where("ca_id = ? AND public_key IS NULL", caID).
+		Updates(
+			map[string]interface{}{
+				"public_key":         publicKey,
+				"sealed_private_key": sealedPrivateKey,
+			},
+		)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		_, err := t.GetLog(ctx, caID)
+		if err != nil {
+			return err
+		}
+
+		return ErrDuplicateRecord
+	}
+
+	return nil
+}
+
+func (t *TransparencyLogRepositorySQL) AppendEntry(
+	ctx context.Context,
+	entry *daos.TransparencyLogEntry,
+) error {
+	return gormDB(ctx, t.db).Transaction(
+		func(tx *gorm.DB) error {
+			// Bumping the size first takes the row lock that holds back other appends
+			result := tx.Model(&daos.TransparencyLog{}).
+				Where("ca_id = ?", entry.CAID).
+				Update("tree_size", gorm.Expr("tree_size + 1"))
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected == 0 {
+				return ErrNoRecord
+			}
+
+			log := &daos.TransparencyLog{}
+			err := tx.Where("ca_id = ?", entry.CAID).First(log).Error
+			if err != nil {
+				return err
+			}
+
+			entry.LeafIndex = log.TreeSize - 1
+
+			return tx.Create(entry).Error
+		},
+	)
+}
+
+func (t *TransparencyLogRepositorySQL) SetTreeHead(
+	ctx context.Context,
+	caID string,
+	treeSize int64,
+	timestamp int64,
+	rootHash []byte,
+	signature []byte,
+) error {
+	return gormDB(ctx, t.db).
+		Model(&daos.TransparencyLog{}).
+		Where("ca_id = ? AND tree_size = ?", caID, treeSize).
+		Updates(
+			map[string]interface{}{
+				"tree_head_size":      treeSize,
+				"tree_head_timestamp": timestamp,
+				"root_hash":           rootHash,
+				"tree_head_signature": signature,
+			},
+		).Error
+}
+
+func (t *TransparencyLogRepositorySQL) GetLeafHashes(
+	ctx context.Context,
+	caID string,
+	start int64,
+	end int64,
+) ([][]byte, error) {
+	hashes := make([][]byte, 0)
+	result := gormDB(ctx, t.db).
+		Model(&daos.TransparencyLogEntry{}).
+		Where("ca_id = ? AND leaf_index >= ? AND leaf_index < ?", caID, start, end).
+		Order("leaf_index").
+		Pluck("leaf_hash", &hashes)
+
+	return hashes, result.Error
+}
+
+func (t *TransparencyLogRepositorySQL) AddNodes(
+	ctx context.Context,
+	nodes []*daos.TransparencyLogNode,
+) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	return gormDB(ctx, t.db).Clauses(clause.OnConflict{DoNothing: true}).Create(nodes).Error
+}
+
+func (t *TransparencyLogRepositorySQL) GetNodes(
+	ctx context.Context,
+	caID string,
+	ids []TransparencyLogNodeID,
+) ([]*daos.TransparencyLogNode, error) {
+	nodes := make([]*daos.TransparencyLogNode, 0, len(ids))
+	if len(ids) == 0 {
+		return nodes, nil
+	}
+
+	db := gormDB(ctx, t.db)
+	matchIDs := db.Session(&gorm.Session{NewDB: true}).
+		Where("level = ? AND node_index = ?", ids[0].Level, ids[0].NodeIndex)
+	for _, id := range ids[1:] {
+		matchIDs = matchIDs.Or("level = ? AND node_index = ?", id.Level, id.NodeIndex)
+	}
+
+	result := db.Where("ca_id = ?", caID).Where(matchIDs).Find(&nodes)
+
+	return nodes, result.Error
+}
+
+func (t *TransparencyLogRepositorySQL) GetEntries(
+	ctx context.Context,
+	caID string,
+	start int64,
+	end int64,
+) ([]*daos.TransparencyLogEntry, error) {
+	entries := make([]*daos.TransparencyLogEntry, 0)
+	result := gormDB(ctx, t.db).
+		Where("ca_id = ? AND leaf_index >= ? AND leaf_index <= ?", caID, start, end).
+		Order("leaf_index").
+		Find(&entries)
+
+	return entries, result.Error
+}
+
+func (t *TransparencyLogRepositorySQL) GetEntryByLeafHash(
+	ctx context.Context,
+	caID string,
+	leafHash []byte,
+) (*daos.TransparencyLogEntry, error) {
+	entry := &daos.TransparencyLogEntry{}
+	result := gormDB(ctx, t.db).
+		Where("ca_id = ? AND leaf_hash = ?", caID, leafHash).
+		Order("leaf_index").
+		First(entry)
+
+	return entry, convertNotFound(result.Error)
+}
+
+func (t *TransparencyLogRepositorySQL) GetEntryByCertificateID(
+	ctx context.Context,
+	caID string,
+	certificateID string,
+) (*daos.TransparencyLogEntry, error) {
+	entry := &daos.TransparencyLogEntry{}
+	result := gormDB(ctx, t.db).
+		Where("ca_id = ? AND certificate_id = ?", caID, certificateID).
+		First(entry)
+
+	return entry, convertNotFound(result.Error)
+}
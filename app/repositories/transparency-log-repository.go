@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+// TransparencyLogNodeID picks out a subtree hash of a log
+type TransparencyLogNodeID struct {
+	Level     int
+	NodeIndex int64
+}
+
+// TransparencyLogRepository stores the per CA issuance logs. Entries are append only, nothing
+// removes them.
+type TransparencyLogRepository interface {
+	// EnsureLog creates an empty log for the CA unless it already has one
+	EnsureLog(ctx context.Context, caID string) error
+	GetLog(ctx context.Context, caID string) (*daos.TransparencyLog, error)
+	// SetLogKey stores the tree head signing key, returning ErrDuplicateRecord when the log
+	// already has one
+	SetLogKey(ctx context.Context, caID string, publicKey []byte, sealedPrivateKey []byte) error
+	// AppendEntry gives entry the next leaf index of its log, which must exist. Appends to one
+	// log are serialized until the surrounding transaction ends.
+	AppendEntry(ctx context.Context, entry *daos.TransparencyLogEntry) error
+	// SetTreeHead stores the signed head of the tree of treeSize leaves. It does nothing once
+	// the log has grown past treeSize, so a slow signer can't replace a newer head.
+	SetTreeHead(
+		ctx context.Context,
+		caID string,
+		treeSize int64,
+		timestamp int64,
+		rootHash []byte,
+		signature []byte,
+	) error
+	// GetLeafHashes returns the hashes of the leaves from start up to but excluding end, in order
+	GetLeafHashes(ctx context.Context, caID string, start int64, end int64) ([][]byte, error)
+	// AddNodes stores subtree hashes, skipping those already stored
+	AddNodes(ctx context.Context, nodes []*daos.TransparencyLogNode) error
+	// GetNodes returns the stored subtree hashes among ids, in no particular order
+	GetNodes(
+		ctx context.Context,
+		caID string,
+		ids []TransparencyLogNodeID,
+	) ([]*daos.TransparencyLogNode, error)
+	// GetEntries returns the entries from start to end inclusive
+	GetEntries(
+		ctx context.Context,
+		caID string,
+		start int64,
+		end int64,
+	) ([]*daos.TransparencyLogEntry, error)
+	GetEntryByLeafHash(
+		ctx context.Context,
+		caID string,
+		leafHash []byte,
+	) (*daos.TransparencyLogEntry, error)
+	GetEntryByCertificateID(
+		ctx context.Context,
+		caID string,
+		certificateID string,
+	) (*daos.TransparencyLogEntry, error)
+}
@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ UserRepository = (*UserRepositoryMemory)(nil)
+
+// UserRepositoryMemory keeps users and sessions in process, for development and tests
+type UserRepositoryMemory struct {
+	memoryTransactional
+
+	mu       sync.RWMutex
+	users    map[string]daos.User
+	sessions map[string]daos.Session
+}
+
+func NewUserRepositoryMemory() *UserRepositoryMemory {
+	return &UserRepositoryMemory{
+		users:    make(map[string]daos.User),
+		sessions: make(map[string]daos.Session),
+	}
+}
+
+func (u *UserRepositoryMemory) snapshot() func() {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	users := make(map[string]daos.User, len(u.users))
+	for id, user := range u.users {
+		users[id] = user
+	}
+
+	sessions := make(map[string]daos.Session, len(u.sessions))
+	for id, session := range u.sessions {
+		sessions[id] = session
+	}
+
+	return func() {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+
+		u.users = users
+		u.sessions = sessions
+	}
+}
+
+func (u *UserRepositoryMemory) CleanupSessions(ctx context.Context) (int, error) {
+	defer u.beginWrite(ctx)()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for id, session := range u.sessions {
+		if !session.Expiration.After(now) {
+			delete(u.sessions, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (u *UserRepositoryMemory) CreateSession(
+	ctx context.Context,
+	userID string,
+) (*contracts.SessionResponse, error) {
+	sessionDao := daos.Session{
+		ID:         uuid.New().String(),
+		Created:    time.Now(),
+		Expiration: time.Now().Add(sessionExpiration),
+		UserID:     userID,
+	}
+
+	defer u.beginWrite(ctx)()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.sessions[sessionDao.ID] = sessionDao
+
+	return sessionDao.ToResponse(), nil
+}
+
+func (u *UserRepositoryMemory) CreateUser(
+	ctx context.Context,
+	createUser *contracts.CreateUserRequest,
+) (*daos.User, error) {
+	passwordHashData, err := bcrypt.GenerateFromPassword([]byte(createUser.Password), bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := daos.User{
+		ID:        uuid.New().String(),
+		FirstName: createUser.FirstName,
+		LastName:  createUser.LastName,
+		Email:     createUser.Email,
+		Password:  string(passwordHashData),
+	}
+
+	defer u.beginWrite(ctx)()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for _, existing := range u.users {
+		if existing.Email == user.Email {
+			return nil, ErrDuplicateRecord
+		}
+	}
+
+	u.users[user.ID] = user
+
+	return &user, nil
+}
+
+func (u *UserRepositoryMemory) GetUserByEmail(ctx context.Context, email string) (
+	*daos.User,
+	error,
+) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for _, user := range u.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNoRecord
+}
+
+func (u *UserRepositoryMemory) GetUserBySessionID(ctx context.Context, sessionID string) (
+	*daos.User,
+	error,
+) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	session, ok := u.sessions[sessionID]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	user, ok := u.users[session.UserID]
+	if !ok {
+		return nil, ErrNoRecord
+	}
+
+	return &user, nil
+}
@@ -28,9 +28,12 @@ func (r *UserRepositoryNeo4j) CleanupSessions(ctx context.Context) (int, error)
 		WHERE s.expires <= datetime({timezone: 'UTC'})
 		DETACH DELETE s RETURN count(s) AS deleted`
 
-	record, err := neo4jWriteTxSingle(ctx, r.driver, query)
+	record, err := neo4jWriteTxSingle(ctx, r.driver, query, map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
 
-	return int(record.Values[0].(int64)), err
+	return int(record.Values[0].(int64)), nil
 }
 
 func (r *UserRepositoryNeo4j) CreateSession(
@@ -43,15 +46,14 @@ func (r *UserRepositoryNeo4j) CreateSession(
 		Expires:   time.Now().Add(sessionExpiration),
 	}
 
-	session := r.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
 	cypher := `MATCH (u:User {uuid: $userID})
 				CREATE (u)-[:HAS_SESSION]->(s:Session {
 					uuid: $uuid,
-					createdAt: $createdAt, 
+					createdAt: $createdAt,
 					expires: $expires
 				})`
-	_, err := session.Run(
-		cypher, map[string]interface{}{
+	err := neo4jWriteTx(
+		ctx, r.driver, cypher, map[string]interface{}{
 			"userID":    userID,
 			"uuid":      sessionResp.ID,
 			"createdAt": sessionResp.CreatedAt.In(time.UTC),
@@ -72,10 +74,9 @@ func (r *UserRepositoryNeo4j) CreateUser(
 	passwordHash := string(passwordHashData)
 	userID := uuid.New().String()
 
-	session := r.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
 	cypher := "CREATE (u:User {uuid: $uuid, firstName: $firstName, lastName: $lastName, email: $email, password: $password})"
-	_, err = session.Run(
-		cypher, map[string]interface{}{
+	err = neo4jWriteTx(
+		ctx, r.driver, cypher, map[string]interface{}{
 			"uuid":      userID,
 			"firstName": createUser.FirstName,
 			"lastName":  createUser.LastName,
@@ -84,7 +85,7 @@ func (r *UserRepositoryNeo4j) CreateUser(
 		},
 	)
 	if err != nil {
-		return nil, err
+		return nil, neo4jDuplicate(err)
 	}
 
 	user := &daos.User{
@@ -101,29 +102,17 @@ func (r *UserRepositoryNeo4j) GetUserByEmail(ctx context.Context, email string)
 	*daos.User,
 	error,
 ) {
-	session := r.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	cypher := "MATCH (u:User {email: $email}) RETURN u"
-	result, err := session.Run(
-		cypher, map[string]interface{}{
+	record, err := neo4jReadTxSingle(
+		ctx, r.driver, cypher, map[string]interface{}{
 			"email": email,
 		},
 	)
 	if err != nil {
-		if err.Error() == NeoErrNoRecordsMsg {
-			return nil, ErrNoRecord
-		}
-		return nil, err
+		return nil, neo4jNotFound(err)
 	}
 
-	record, err := result.Single()
-	if err != nil {
-		return nil, err
-	}
-
-	props := record.Values[0].(neo4j.Node).Props
-	user := daos.NewUserFromProps(props)
-
-	return user, nil
+	return daos.NewUserFromProps(record.Values[0].(neo4j.Node).Props), nil
 }
 
 func (r *UserRepositoryNeo4j) GetUserBySessionID(ctx context.Context, sessionID string) (
@@ -137,7 +126,7 @@ func (r *UserRepositoryNeo4j) GetUserBySessionID(ctx context.Context, sessionID
 
 	result, err := neo4jReadTxSingle(ctx, r.driver, cypher, params)
 	if err != nil {
-		return nil, err
+		return nil, neo4jNotFound(err)
 	}
 
 	return daos.NewUserFromProps(result.Values[0].(neo4j.Node).Props), nil
@@ -11,24 +11,24 @@ import (
 	"gorm.io/gorm"
 )
 
-var _ UserRepository = (*UserRepositoryMySql)(nil)
+var _ UserRepository = (*UserRepositorySQL)(nil)
 
-type UserRepositoryMySql struct {
+type UserRepositorySQL struct {
 	db *gorm.DB
 }
 
-func NewUserRepositoryMySql(db *gorm.DB) *UserRepositoryMySql {
-	return &UserRepositoryMySql{
+func NewUserRepositorySQL(db *gorm.DB) *UserRepositorySQL {
+	return &UserRepositorySQL{
 		db: db,
 	}
 }
 
-func (u *UserRepositoryMySql) CleanupSessions(ctx context.Context) (int, error) {
-	result := u.db.Where("expiration <= ?", time.Now()).Delete(&daos.Session{})
+func (u *UserRepositorySQL) CleanupSessions(ctx context.Context) (int, error) {
+	result := gormDB(ctx, u.db).Where("expiration <= ?", time.Now()).Delete(&daos.Session{})
 	return int(result.RowsAffected), result.Error
 }
 
-func (u *UserRepositoryMySql) CreateSession(
+func (u *UserRepositorySQL) CreateSession(
 	ctx context.Context,
 	userID string,
 ) (*contracts.SessionResponse, error) {
@@ -39,7 +39,7 @@ func (u *UserRepositoryMySql) CreateSession(
 		UserID:     userID,
 	}
 
-	result := u.db.WithContext(ctx).Create(sessionDao)
+	result := gormDB(ctx, u.db).Create(sessionDao)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -47,7 +47,7 @@ func (u *UserRepositoryMySql) CreateSession(
 	return sessionDao.ToResponse(), nil
 }
 
-func (u *UserRepositoryMySql) CreateUser(
+func (u *UserRepositorySQL) CreateUser(
 	ctx context.Context,
 	createUser *contracts.CreateUserRequest,
 ) (*daos.User, error) {
@@ -66,35 +66,35 @@ func (u *UserRepositoryMySql) CreateUser(
 		Password:  passwordHash,
 	}
 
-	result := u.db.WithContext(ctx).Create(user)
+	result := gormDB(ctx, u.db).Create(user)
 
-	return user, result.Error
+	return user, convertDuplicate(result.Error)
 }
 
-func (u *UserRepositoryMySql) GetUserByEmail(ctx context.Context, email string) (
+func (u *UserRepositorySQL) GetUserByEmail(ctx context.Context, email string) (
 	*daos.User,
 	error,
 ) {
 	user := &daos.User{}
-	result := u.db.WithContext(ctx).Where("email = ?", email).First(user)
+	result := gormDB(ctx, u.db).Where("email = ?", email).First(user)
 
 	return user, convertNotFound(result.Error)
 }
 
-func (u *UserRepositoryMySql) GetUserBySessionID(ctx context.Context, sessionID string) (
+func (u *UserRepositorySQL) GetUserBySessionID(ctx context.Context, sessionID string) (
 	*daos.User,
 	error,
 ) {
 	// Fetch session by ID
 	session := &daos.Session{}
-	result := u.db.WithContext(ctx).Where("id = ?", sessionID).First(session)
+	result := gormDB(ctx, u.db).Where("id = ?", sessionID).First(session)
 	if result.Error != nil {
 		return nil, convertNotFound(result.Error)
 	}
 
 	// Fetch user by ID
 	user := &daos.User{}
-	result = u.db.WithContext(ctx).Where("id = ?", session.UserID).First(user)
+	result = gormDB(ctx, u.db).Where("id = ?", session.UserID).First(user)
 
 	return user, convertNotFound(result.Error)
 }
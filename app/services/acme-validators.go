@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	AcmeChallengeHTTP01 = "http-01"
+	AcmeChallengeDNS01  = "dns-01"
+)
+
+// AcmeChallengeValidator proves control of an identifier for a single ACME challenge type.
+// Implementations are registered with the AcmeService by challenge type.
+type AcmeChallengeValidator interface {
+	Validate(ctx context.Context, domain string, token string, keyAuthorization string) error
+}
+
+// DefaultAcmeValidators returns the http-01 and dns-01 validators with production settings
+func DefaultAcmeValidators() map[string]AcmeChallengeValidator {
+	return map[string]AcmeChallengeValidator{
+		AcmeChallengeHTTP01: NewHTTP01Validator(80),
+		AcmeChallengeDNS01:  NewDNS01Validator(net.DefaultResolver),
+	}
+}
+
+type HTTP01Validator struct {
+	client *http.Client
+	port   int
+}
+
+func NewHTTP01Validator(port int) *HTTP01Validator {
+	return &HTTP01Validator{
+		client: &http.Client{Timeout: 10 * time.Second},
+		port:   port,
+	}
+}
+
+func (v *HTTP01Validator) Validate(
+	ctx context.Context,
+	domain string,
+	token string,
+	keyAuthorization string,
+) error {
+	host := domain
+	if v.port != 80 {
+		host = net.JoinHostPort(domain, fmt.Sprint(v.port))
+	}
+
+	url := "http://" + host + "/.well-known/acme-challenge/" + token
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8*1024))
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(string(body)) != keyAuthorization {
+		return fmt.Errorf("key authorization served at %s does not match", url)
+	}
+
+	return nil
+}
+
+type DNS01Validator struct {
+	resolver *net.Resolver
+}
+
+func NewDNS01Validator(resolver *net.Resolver) *DNS01Validator {
+	return &DNS01Validator{
+		resolver: resolver,
+	}
+}
+
+func (v *DNS01Validator) Validate(
+	ctx context.Context,
+	domain string,
+	token string,
+	keyAuthorization string,
+) error {
+	name := "_acme-challenge." + strings.TrimPrefix(domain, "*.")
+	records, err := v.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no matching TXT record found at %s", name)
+}
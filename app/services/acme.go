@@ -0,0 +1,1138 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+	"go.step.sm/crypto/jose"
+)
+
+const (
+	AcmeStatusPending     = "pending"
+	AcmeStatusProcessing  = "processing"
+	AcmeStatusReady       = "ready"
+	AcmeStatusValid       = "valid"
+	AcmeStatusInvalid     = "invalid"
+	AcmeStatusDeactivated = "deactivated"
+
+	acmeNonceValidity          = time.Hour
+	acmeOrderValidity          = 7 * 24 * time.Hour
+	acmeChallengeTimeout       = 30 * time.Second
+	acmeDefaultCertValidityDay = 90
+)
+
+var acmeSignatureAlgorithms = map[string]bool{
+	jose.RS256: true,
+	jose.PS256: true,
+	jose.ES256: true,
+	jose.ES384: true,
+	jose.ES512: true,
+	jose.EdDSA: true,
+}
+
+// AcmeError is an RFC 8555 problem document
+type AcmeError struct {
+	Type   string
+	Detail string
+	Status int
+}
+
+func (e *AcmeError) Error() string {
+	return e.Type + ": " + e.Detail
+}
+
+func (e *AcmeError) ToProblem() *contracts.AcmeProblem {
+	return &contracts.AcmeProblem{
+		Type:   e.Type,
+		Detail: e.Detail,
+		Status: e.Status,
+	}
+}
+
+func newAcmeError(errType string, status int, detail string) *AcmeError {
+	return &AcmeError{
+		Type:   "urn:ietf:params:acme:error:" + errType,
+		Detail: detail,
+		Status: status,
+	}
+}
+
+var ErrAcmeNotEnabled = newAcmeError(
+	"malformed",
+	http.StatusNotFound,
+	"ACME is not enabled for this certificate authority",
+)
+
+// AcmeRequest is an authenticated ACME request, Account is nil for requests signed with a JWK
+type AcmeRequest struct {
+	CAID    string
+	Account *daos.AcmeAccount
+	JWK     *jose.JSONWebKey
+	Payload []byte
+}
+
+var _ AcmeService = (*AcmeServiceImpl)(nil)
+
+type AcmeService interface {
+	EnableForUser(
+		ctx context.Context,
+		caID string,
+		userID string,
+		request *contracts.EnableAcmeRequest,
+	) error
+	DisableForUser(ctx context.Context, caID string, userID string) error
+	IsEnabled(ctx context.Context, caID string) error
+	NewNonce(ctx context.Context) (string, error)
+	// VerifyRequest checks the JWS envelope of an ACME POST. Requests are signed with the
+	// account key referenced by a kid under accountURLPrefix unless useJWK is set.
+	VerifyRequest(
+		ctx context.Context,
+		caID string,
+		requestURL string,
+		accountURLPrefix string,
+		body []byte,
+		useJWK bool,
+	) (*AcmeRequest, error)
+	NewAccount(ctx context.Context, req *AcmeRequest) (*daos.AcmeAccount, bool, error)
+	UpdateAccount(ctx context.Context, req *AcmeRequest) (*daos.AcmeAccount, error)
+	GetOrders(ctx context.Context, req *AcmeRequest) ([]*daos.AcmeOrder, error)
+	NewOrder(
+		ctx context.Context,
+		req *AcmeRequest,
+	) (*daos.AcmeOrder, []*daos.AcmeAuthorization, error)
+	GetOrder(
+		ctx context.Context,
+		req *AcmeRequest,
+		orderID string,
+	) (*daos.AcmeOrder, []*daos.AcmeAuthorization, error)
+	GetAuthorization(
+		ctx context.Context,
+		req *AcmeRequest,
+		authorizationID string,
+	) (*daos.AcmeAuthorization, []*daos.AcmeChallenge, error)
+	RespondToChallenge(
+		ctx context.Context,
+		req *AcmeRequest,
+		challengeID string,
+	) (*daos.AcmeChallenge, *daos.AcmeAuthorization, error)
+	FinalizeOrder(
+		ctx context.Context,
+		req *AcmeRequest,
+		orderID string,
+	) (*daos.AcmeOrder, []*daos.AcmeAuthorization, error)
+	GetCertificateChain(ctx context.Context, req *AcmeRequest, orderID string) ([]byte, error)
+	RevokeCert(ctx context.Context, req *AcmeRequest) error
+}
+
+type AcmeServiceImpl struct {
+	acmeRepository     repositories.AcmeRepository
+	certRepository     repositories.CertRepository
+	certificateService CertificateService
+	keyService         KeyService
+	transactor         repositories.Transactor
+	secretKey          string
+	validators         map[string]AcmeChallengeValidator
+}
+
+func NewAcmeServiceImpl(
+	acmeRepository repositories.AcmeRepository,
+	certRepository repositories.CertRepository,
+	certificateService CertificateService,
+	keyService KeyService,
+	transactor repositories.Transactor,
+	secretKey string,
+	validators map[string]AcmeChallengeValidator,
+) *AcmeServiceImpl {
+	return &AcmeServiceImpl{
+		acmeRepository:     acmeRepository,
+		certRepository:     certRepository,
+		certificateService: certificateService,
+		keyService:         keyService,
+		transactor:         transactor,
+		secretKey:          secretKey,
+		validators:         validators,
+	}
+}
+
+func (a *AcmeServiceImpl) EnableForUser(
+	ctx context.Context,
+	caID string,
+	userID string,
+	request *contracts.EnableAcmeRequest,
+) error {
+	ca, err := a.certRepository.GetCertByID(ctx, caID)
+	if err != nil {
+		return err
+	}
+
+	if ca.UserID != userID {
+		return ErrCertUnautorized
+	}
+
+	if ca.Type != CertTypeRootCA.String() && ca.Type != CertTypeIntermediateCA.String() {
+		return errors.New("certificate is not a certificate authority")
+	}
+
+	// Make sure the password actually unlocks the key before it is stored
+	_, err = a.keyService.GetDecryptedKeyForUser(ctx, ca.KeyID, userID, request.CAKeyPassword)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := utils.Seal(a.secretKey, []byte(request.CAKeyPassword))
+	if err != nil {
+		return err
+	}
+
+	validityDays := request.CertValidityDays
+	if validityDays <= 0 {
+		validityDays = acmeDefaultCertValidityDay
+	}
+
+	return a.acmeRepository.SaveDirectory(
+		ctx, &daos.AcmeDirectory{
+			CertificateID:     caID,
+			UserID:            userID,
+			SealedKeyPassword: sealed,
+			CertValidityDays:  validityDays,
+		},
+	)
+}
+
+func (a *AcmeServiceImpl) DisableForUser(ctx context.Context, caID string, userID string) error {
+	directory, err := a.acmeRepository.GetDirectory(ctx, caID)
+	if err != nil {
+		return err
+	}
+
+	if directory.UserID != userID {
+		return ErrCertUnautorized
+	}
+
+	return a.acmeRepository.DeleteDirectory(ctx, caID)
+}
+
+func (a *AcmeServiceImpl) IsEnabled(ctx context.Context, caID string) error {
+	_, err := a.getDirectory(ctx, caID)
+	return err
+}
+
+func (a *AcmeServiceImpl) NewNonce(ctx context.Context) (string, error) {
+	nonce, err := randomBase64URL(16)
+	if err != nil {
+		return "", err
+	}
+
+	err = a.acmeRepository.DeleteNoncesCreatedBefore(ctx, time.Now().Add(-acmeNonceValidity))
+	if err != nil {
+		return "", err
+	}
+
+	err = a.acmeRepository.CreateNonce(ctx, nonce)
+	return nonce, err
+}
+
+func (a *AcmeServiceImpl) VerifyRequest(
+	ctx context.Context,
+	caID string,
+	requestURL string,
+	accountURLPrefix string,
+	body []byte,
+	useJWK bool,
+) (*AcmeRequest, error) {
+	_, err := a.getDirectory(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	jws, err := jose.ParseJWS(string(body))
+	if err != nil {
+		return nil, newAcmeError("malformed", http.StatusBadRequest, "invalid JWS: "+err.Error())
+	}
+
+	if len(jws.Signatures) != 1 {
+		return nil, newAcmeError("malformed", http.StatusBadRequest, "JWS must have one signature")
+	}
+
+	header := jws.Signatures[0].Protected
+	if !acmeSignatureAlgorithms[header.Algorithm] {
+		return nil, newAcmeError(
+			"badSignatureAlgorithm",
+			http.StatusBadRequest,
+			"unsupported JWS algorithm "+header.Algorithm,
+		)
+	}
+
+	ok, err := a.acmeRepository.ConsumeNonce(
+		ctx,
+		header.Nonce,
+		time.Now().Add(-acmeNonceValidity),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, newAcmeError("badNonce", http.StatusBadRequest, "invalid or reused nonce")
+	}
+
+	url, _ := header.ExtraHeaders[jose.HeaderKey("url")].(string)
+	if url != requestURL {
+		return nil, newAcmeError("unauthorized", http.StatusUnauthorized, "url header mismatch")
+	}
+
+	req := &AcmeRequest{CAID: caID}
+	if useJWK {
+		if header.JSONWebKey == nil || header.KeyID != "" {
+			return nil, newAcmeError("malformed", http.StatusBadRequest, "request must use jwk")
+		}
+
+		if !header.JSONWebKey.Valid() || !header.JSONWebKey.IsPublic() {
+			return nil, newAcmeError("badPublicKey", http.StatusBadRequest, "invalid account key")
+		}
+
+		req.JWK = header.JSONWebKey
+	} else {
+		if header.JSONWebKey != nil || !strings.HasPrefix(header.KeyID, accountURLPrefix) {
+			return nil, newAcmeError("malformed", http.StatusBadRequest, "request must use kid")
+		}
+
+		account, err := a.acmeRepository.GetAccount(
+			ctx,
+			strings.TrimPrefix(header.KeyID, accountURLPrefix),
+		)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNoRecord) {
+				return nil, newAcmeError(
+					"accountDoesNotExist",
+					http.StatusBadRequest,
+					"unknown account",
+				)
+			}
+
+			return nil, err
+		}
+
+		if account.CertificateID != caID || account.Status != AcmeStatusValid {
+			return nil, newAcmeError("unauthorized", http.StatusUnauthorized, "account is not valid")
+		}
+
+		jwk := &jose.JSONWebKey{}
+		err = jwk.UnmarshalJSON([]byte(account.JWK))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Account = account
+		req.JWK = jwk
+	}
+
+	req.Payload, err = jws.Verify(req.JWK.Key)
+	if err != nil {
+		return nil, newAcmeError("malformed", http.StatusBadRequest, "invalid JWS signature")
+	}
+
+	return req, nil
+}
+
+func (a *AcmeServiceImpl) NewAccount(
+	ctx context.Context,
+	req *AcmeRequest,
+) (*daos.AcmeAccount, bool, error) {
+	payload := &contracts.AcmeAccountRequest{}
+	err := json.Unmarshal(req.Payload, payload)
+	if err != nil {
+		return nil, false, newAcmeError("malformed", http.StatusBadRequest, "invalid payload")
+	}
+
+	thumbprint, err := jwkThumbprint(req.JWK)
+	if err != nil {
+		return nil, false, err
+	}
+
+	account, err := a.acmeRepository.GetAccountByThumbprint(ctx, req.CAID, thumbprint)
+	if err == nil {
+		return account, false, nil
+	}
+
+	if !errors.Is(err, repositories.ErrNoRecord) {
+		return nil, false, err
+	}
+
+	if payload.OnlyReturnExisting {
+		return nil, false, newAcmeError(
+			"accountDoesNotExist",
+			http.StatusBadRequest,
+			"no account exists for this key",
+		)
+	}
+
+	contact, err := acmeContact(payload.Contact)
+	if err != nil {
+		return nil, false, err
+	}
+
+	jwk, err := req.JWK.MarshalJSON()
+	if err != nil {
+		return nil, false, err
+	}
+
+	account = &daos.AcmeAccount{
+		CertificateID: req.CAID,
+		Thumbprint:    thumbprint,
+		JWK:           string(jwk),
+		Contact:       contact,
+		Status:        AcmeStatusValid,
+	}
+
+	err = a.acmeRepository.CreateAccount(ctx, account)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return account, true, nil
+}
+
+func (a *AcmeServiceImpl) UpdateAccount(
+	ctx context.Context,
+	req *AcmeRequest,
+) (*daos.AcmeAccount, error) {
+	// POST-as-GET
+	if len(req.Payload) == 0 {
+		return req.Account, nil
+	}
+
+	payload := &contracts.AcmeAccountRequest{}
+	err := json.Unmarshal(req.Payload, payload)
+	if err != nil {
+		return nil, newAcmeError("malformed", http.StatusBadRequest, "invalid payload")
+	}
+
+	account := req.Account
+	if payload.Contact != nil {
+		account.Contact, err = acmeContact(payload.Contact)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch payload.Status {
+	case "":
+	case AcmeStatusDeactivated:
+		account.Status = AcmeStatusDeactivated
+	default:
+		return nil, newAcmeError("malformed", http.StatusBadRequest, "invalid account status")
+	}
+
+	err = a.acmeRepository.UpdateAccount(ctx, account)
+	return account, err
+}
+
+func (a *AcmeServiceImpl) GetOrders(
+	ctx context.Context,
+	req *AcmeRequest,
+) ([]*daos.AcmeOrder, error) {
+	return a.acmeRepository.GetOrdersByAccount(ctx, req.Account.ID)
+}
+
+func (a *AcmeServiceImpl) NewOrder(
+	ctx context.Context,
+	req *AcmeRequest,
+) (*daos.AcmeOrder, []*daos.AcmeAuthorization, error) {
+	payload := &contracts.AcmeNewOrderRequest{}
+	err := json.Unmarshal(req.Payload, payload)
+	if err != nil {
+		return nil, nil, newAcmeError("malformed", http.StatusBadRequest, "invalid payload")
+	}
+
+	if len(payload.Identifiers) == 0 {
+		return nil, nil, newAcmeError("malformed", http.StatusBadRequest, "no identifiers")
+	}
+
+	expires := time.Now().Add(acmeOrderValidity)
+	identifiers := make([]contracts.AcmeIdentifier, 0, len(payload.Identifiers))
+	authorizations := make([]*daos.AcmeAuthorization, 0, len(payload.Identifiers))
+	challenges := make([][]*daos.AcmeChallenge, 0, len(payload.Identifiers))
+	seen := make(map[string]bool)
+
+	for _, identifier := range payload.Identifiers {
+		if identifier.Type != "dns" {
+			return nil, nil, newAcmeError(
+				"unsupportedIdentifier",
+				http.StatusBadRequest,
+				"only dns identifiers are supported",
+			)
+		}
+
+		value := strings.ToLower(identifier.Value)
+		domain := strings.TrimPrefix(value, "*.")
+		wildcard := domain != value
+		if !validDNSName(domain) {
+			return nil, nil, newAcmeError(
+				"rejectedIdentifier",
+				http.StatusBadRequest,
+				"invalid dns identifier "+identifier.Value,
+			)
+		}
+
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+
+		authzChallenges, err := a.newChallenges(wildcard)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		identifiers = append(identifiers, contracts.AcmeIdentifier{Type: "dns", Value: value})
+		authorizations = append(
+			authorizations, &daos.AcmeAuthorization{
+				IdentifierType:  "dns",
+				IdentifierValue: domain,
+				Wildcard:        wildcard,
+				Status:          AcmeStatusPending,
+				Expires:         expires,
+			},
+		)
+		challenges = append(challenges, authzChallenges)
+	}
+
+	identifiersJSON, err := json.Marshal(identifiers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	order := &daos.AcmeOrder{
+		AccountID:   req.Account.ID,
+		Status:      AcmeStatusPending,
+		Identifiers: string(identifiersJSON),
+		NotBefore:   payload.NotBefore,
+		NotAfter:    payload.NotAfter,
+		Expires:     expires,
+	}
+
+	err = a.acmeRepository.CreateOrder(ctx, order, authorizations, challenges)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, authorizations, nil
+}
+
+func (a *AcmeServiceImpl) GetOrder(
+	ctx context.Context,
+	req *AcmeRequest,
+	orderID string,
+) (*daos.AcmeOrder, []*daos.AcmeAuthorization, error) {
+	order, err := a.getOrderForAccount(ctx, req, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authorizations, err := a.acmeRepository.GetAuthorizationsByOrder(ctx, order.ID)
+	return order, authorizations, err
+}
+
+func (a *AcmeServiceImpl) GetAuthorization(
+	ctx context.Context,
+	req *AcmeRequest,
+	authorizationID string,
+) (*daos.AcmeAuthorization, []*daos.AcmeChallenge, error) {
+	authorization, _, err := a.getAuthorizationForAccount(ctx, req, authorizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	challenges, err := a.acmeRepository.GetChallengesByAuthorization(ctx, authorization.ID)
+	return authorization, challenges, err
+}
+
+func (a *AcmeServiceImpl) RespondToChallenge(
+	ctx context.Context,
+	req *AcmeRequest,
+	challengeID string,
+) (*daos.AcmeChallenge, *daos.AcmeAuthorization, error) {
+	challenge, err := a.acmeRepository.GetChallenge(ctx, challengeID)
+	if err != nil {
+		return nil, nil, acmeNotFound(err)
+	}
+
+	authorization, order, err := a.getAuthorizationForAccount(
+		ctx,
+		req,
+		challenge.AuthorizationID,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if challenge.Status != AcmeStatusPending || authorization.Status != AcmeStatusPending {
+		return challenge, authorization, nil
+	}
+
+	validator, ok := a.validators[challenge.Type]
+	if !ok {
+		return nil, nil, newAcmeError(
+			"malformed",
+			http.StatusBadRequest,
+			"unsupported challenge type",
+		)
+	}
+
+	thumbprint, err := jwkThumbprint(req.JWK)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	validateCtx, cancel := context.WithTimeout(ctx, acmeChallengeTimeout)
+	defer cancel()
+
+	err = validator.Validate(
+		validateCtx,
+		authorization.IdentifierValue,
+		challenge.Token,
+		challenge.Token+"."+thumbprint,
+	)
+	if err != nil {
+		logger.Get(ctx).WithError(err).Infof("ACME challenge %s failed", challenge.ID)
+
+		problem, _ := json.Marshal(
+			newAcmeError("incorrectResponse", http.StatusForbidden, err.Error()).ToProblem(),
+		)
+		challenge.Status = AcmeStatusInvalid
+		challenge.Error = string(problem)
+		authorization.Status = AcmeStatusInvalid
+		order.Status = AcmeStatusInvalid
+		order.Error = string(problem)
+	} else {
+		now := time.Now()
+		challenge.Status = AcmeStatusValid
+		challenge.Validated = &now
+		authorization.Status = AcmeStatusValid
+	}
+
+	err = a.acmeRepository.UpdateChallenge(ctx, challenge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = a.acmeRepository.UpdateAuthorization(ctx, authorization)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if order.Status == AcmeStatusPending {
+		authorizations, err := a.acmeRepository.GetAuthorizationsByOrder(ctx, order.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ready := true
+		for _, authz := range authorizations {
+			if authz.Status != AcmeStatusValid {
+				ready = false
+			}
+		}
+
+		if ready {
+			order.Status = AcmeStatusReady
+		}
+	}
+
+	err = a.acmeRepository.UpdateOrder(ctx, order)
+	return challenge, authorization, err
+}
+
+func (a *AcmeServiceImpl) FinalizeOrder(
+	ctx context.Context,
+	req *AcmeRequest,
+	orderID string,
+) (*daos.AcmeOrder, []*daos.AcmeAuthorization, error) {
+	order, err := a.getOrderForAccount(ctx, req, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if order.Status != AcmeStatusReady {
+		return nil, nil, newAcmeError(
+			"orderNotReady",
+			http.StatusForbidden,
+			"order is "+order.Status,
+		)
+	}
+
+	payload := &contracts.AcmeFinalizeRequest{}
+	err = json.Unmarshal(req.Payload, payload)
+	if err != nil {
+		return nil, nil, newAcmeError("malformed", http.StatusBadRequest, "invalid payload")
+	}
+
+	csr, err := parseAcmeCSR(payload.CSR)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identifiers := make([]contracts.AcmeIdentifier, 0)
+	err = json.Unmarshal([]byte(order.Identifiers), &identifiers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = checkCSRMatchesIdentifiers(csr, identifiers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	directory, err := a.getDirectory(ctx, req.CAID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caKeyPassword, err := utils.Open(a.secretKey, directory.SealedKeyPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notAfter, err := a.certNotAfter(ctx, directory, order)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		names[i] = identifier.Value
+	}
+
+	keyUsages := []string{"digitalSignature", "serverAuth", "clientAuth"}
+	if _, ok := csr.PublicKey.(*rsa.PublicKey); ok {
+		keyUsages = append(keyUsages, "keyEncipherment")
+	}
+
+	err = a.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			// Claim the order first so concurrent finalize requests can't both issue for it
+			claimed, err := a.acmeRepository.UpdateOrderStatus(
+				ctx,
+				order.ID,
+				AcmeStatusReady,
+				AcmeStatusProcessing,
+			)
+			if err != nil {
+				return err
+			}
+
+			if !claimed {
+				return newAcmeError("orderNotReady", http.StatusForbidden, "order is being finalized")
+			}
+
+			cert, err := a.certificateService.IssueCert(
+				ctx,
+				req.CAID,
+				directory.UserID,
+				string(caKeyPassword),
+				&IssueCertParams{
+					Name:                    names[0] + " (ACME)",
+					PublicKey:               csr.PublicKey,
+					CommonName:              names[0],
+					SubjectAlternativeNames: names,
+					KeyUsages:               keyUsages,
+					NotAfter:                notAfter,
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			order.Status = AcmeStatusValid
+			order.CertificateID = cert.ID
+			return a.acmeRepository.UpdateOrder(ctx, order)
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authorizations, err := a.acmeRepository.GetAuthorizationsByOrder(ctx, order.ID)
+	return order, authorizations, err
+}
+
+func (a *AcmeServiceImpl) GetCertificateChain(
+	ctx context.Context,
+	req *AcmeRequest,
+	orderID string,
+) ([]byte, error) {
+	order, err := a.getOrderForAccount(ctx, req, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Status != AcmeStatusValid || order.CertificateID == "" {
+		return nil, newAcmeError("malformed", http.StatusNotFound, "certificate not issued")
+	}
+
+	cert, err := a.certRepository.GetCertByID(ctx, order.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Data})
+
+	// Include intermediates, relying parties are expected to already hold the root
+	for parentID := cert.ParentCertificate; parentID != ""; {
+		parent, err := a.certRepository.GetCertByID(ctx, parentID)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent.ParentCertificate == "" {
+			break
+		}
+
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: parent.Data})...)
+		parentID = parent.ParentCertificate
+	}
+
+	return chain, nil
+}
+
+func (a *AcmeServiceImpl) RevokeCert(ctx context.Context, req *AcmeRequest) error {
+	payload := &contracts.AcmeRevokeCertRequest{}
+	err := json.Unmarshal(req.Payload, payload)
+	if err != nil {
+		return newAcmeError("malformed", http.StatusBadRequest, "invalid payload")
+	}
+
+	reason := contracts.RevocationReason(payload.Reason)
+	if _, ok := contracts.RevocationReasonStrings[reason]; !ok ||
+		reason == contracts.ReasonRemoveFromCRL {
+
+		return newAcmeError("badRevocationReason", http.StatusBadRequest, "invalid reason")
+	}
+
+	der, err := base64.RawURLEncoding.DecodeString(payload.Certificate)
+	if err != nil {
+		return newAcmeError("malformed", http.StatusBadRequest, "invalid certificate encoding")
+	}
+
+	orders, err := a.acmeRepository.GetOrdersByAccount(ctx, req.Account.ID)
+	if err != nil {
+		return err
+	}
+
+	var cert *daos.Certificate
+	for _, order := range orders {
+		if order.CertificateID == "" {
+			continue
+		}
+
+		orderCert, err := a.certRepository.GetCertByID(ctx, order.CertificateID)
+		if err != nil {
+			return err
+		}
+
+		if string(orderCert.Data) == string(der) {
+			cert = orderCert
+			break
+		}
+	}
+
+	if cert == nil {
+		return newAcmeError(
+			"unauthorized",
+			http.StatusForbidden,
+			"certificate was not issued to this account",
+		)
+	}
+
+	directory, err := a.getDirectory(ctx, req.CAID)
+	if err != nil {
+		return err
+	}
+
+	caKeyPassword, err := utils.Open(a.secretKey, directory.SealedKeyPassword)
+	if err != nil {
+		return err
+	}
+
+	err = a.certificateService.RevokeCertForUser(
+		ctx,
+		cert.ID,
+		directory.UserID,
+		reason,
+		string(caKeyPassword),
+	)
+	if errors.Is(err, ErrCertAlreadyRevoked) {
+		return newAcmeError("alreadyRevoked", http.StatusBadRequest, err.Error())
+	}
+
+	return err
+}
+
+func (a *AcmeServiceImpl) getDirectory(
+	ctx context.Context,
+	caID string,
+) (*daos.AcmeDirectory, error) {
+	directory, err := a.acmeRepository.GetDirectory(ctx, caID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoRecord) {
+			return nil, ErrAcmeNotEnabled
+		}
+
+		return nil, err
+	}
+
+	return directory, nil
+}
+
+func (a *AcmeServiceImpl) getOrderForAccount(
+	ctx context.Context,
+	req *AcmeRequest,
+	orderID string,
+) (*daos.AcmeOrder, error) {
+	order, err := a.acmeRepository.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, acmeNotFound(err)
+	}
+
+	if order.AccountID != req.Account.ID {
+		return nil, newAcmeError("unauthorized", http.StatusForbidden, "order belongs to another account")
+	}
+
+	if order.Status != AcmeStatusValid &&
+		order.Status != AcmeStatusInvalid &&
+		time.Now().After(order.Expires) {
+
+		order.Status = AcmeStatusInvalid
+		err = a.acmeRepository.UpdateOrder(ctx, order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func (a *AcmeServiceImpl) getAuthorizationForAccount(
+	ctx context.Context,
+	req *AcmeRequest,
+	authorizationID string,
+) (*daos.AcmeAuthorization, *daos.AcmeOrder, error) {
+	authorization, err := a.acmeRepository.GetAuthorization(ctx, authorizationID)
+	if err != nil {
+		return nil, nil, acmeNotFound(err)
+	}
+
+	order, err := a.getOrderForAccount(ctx, req, authorization.OrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return authorization, order, nil
+}
+
+func (a *AcmeServiceImpl) newChallenges(wildcard bool) ([]*daos.AcmeChallenge, error) {
+	types := make([]string, 0, len(a.validators))
+	for challengeType := range a.validators {
+		// Wildcards can only be proven through DNS
+		if wildcard && challengeType == AcmeChallengeHTTP01 {
+			continue
+		}
+		types = append(types, challengeType)
+	}
+	sort.Strings(types)
+
+	if len(types) == 0 {
+		return nil, newAcmeError(
+			"rejectedIdentifier",
+			http.StatusBadRequest,
+			"no challenge types available for identifier",
+		)
+	}
+
+	challenges := make([]*daos.AcmeChallenge, len(types))
+	for i, challengeType := range types {
+		token, err := randomBase64URL(32)
+		if err != nil {
+			return nil, err
+		}
+
+		challenges[i] = &daos.AcmeChallenge{
+			Type:   challengeType,
+			Token:  token,
+			Status: AcmeStatusPending,
+		}
+	}
+
+	return challenges, nil
+}
+
+// certNotAfter caps the directory's default lifetime by the order's notAfter and the CA's own
+// expiry
+func (a *AcmeServiceImpl) certNotAfter(
+	ctx context.Context,
+	directory *daos.AcmeDirectory,
+	order *daos.AcmeOrder,
+) (time.Time, error) {
+	notAfter := time.Now().AddDate(0, 0, directory.CertValidityDays)
+	if order.NotAfter != nil && order.NotAfter.Before(notAfter) {
+		notAfter = *order.NotAfter
+	}
+
+	ca, err := a.certRepository.GetCertByID(ctx, directory.CertificateID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	caCert, err := x509.ParseCertificate(ca.Data)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if caCert.NotAfter.Before(notAfter) {
+		notAfter = caCert.NotAfter
+	}
+
+	return notAfter, nil
+}
+
+func parseAcmeCSR(encoded string) (*x509.CertificateRequest, error) {
+	der, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, newAcmeError("badCSR", http.StatusBadRequest, "invalid CSR encoding")
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, newAcmeError("badCSR", http.StatusBadRequest, err.Error())
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, newAcmeError("badCSR", http.StatusBadRequest, err.Error())
+	}
+
+	return csr, nil
+}
+
+func checkCSRMatchesIdentifiers(
+	csr *x509.CertificateRequest,
+	identifiers []contracts.AcmeIdentifier,
+) error {
+	if len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return newAcmeError("badCSR", http.StatusBadRequest, "only DNS names may be requested")
+	}
+
+	names := make(map[string]bool)
+	for _, name := range csr.DNSNames {
+		names[strings.ToLower(name)] = true
+	}
+
+	if csr.Subject.CommonName != "" {
+		names[strings.ToLower(csr.Subject.CommonName)] = true
+	}
+
+	if len(names) != len(identifiers) {
+		return newAcmeError("badCSR", http.StatusBadRequest, "CSR names do not match order")
+	}
+
+	for _, identifier := range identifiers {
+		if !names[identifier.Value] {
+			return newAcmeError(
+				"badCSR",
+				http.StatusBadRequest,
+				"CSR is missing "+identifier.Value,
+			)
+		}
+	}
+
+	return nil
+}
+
+func acmeContact(contact []string) (string, error) {
+	for _, c := range contact {
+		if !strings.HasPrefix(c, "mailto:") {
+			return "", newAcmeError(
+				"unsupportedContact",
+				http.StatusBadRequest,
+				"only mailto contacts are supported",
+			)
+		}
+	}
+
+	data, err := json.Marshal(contact)
+	return string(data), err
+}
+
+func acmeNotFound(err error) error {
+	if errors.Is(err, repositories.ErrNoRecord) {
+		return newAcmeError("malformed", http.StatusNotFound, "resource not found")
+	}
+
+	return err
+}
+
+func jwkThumbprint(jwk *jose.JSONWebKey) (string, error) {
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+func randomBase64URL(n int) (string, error) {
+	data := make([]byte, n)
+	_, err := rand.Read(data)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func validDNSName(name string) bool {
+	return strings.Contains(name, ".") && validHostname(name)
+}
+
+// validHostname reports whether name is made of lowercase letter, digit and hyphen labels
+func validHostname(name string) bool {
+	if len(name) == 0 || len(name) > 253 || net.ParseIP(name) != nil {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,412 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+)
+
+const (
+	acmeTestURL           = "https://pki.example.com/acme/new-order"
+	acmeTestAccountPrefix = "https://pki.example.com/acme/account/"
+)
+
+// acmeTestPlatform is the ACME service over an enabled root CA
+type acmeTestPlatform struct {
+	*certificateTestPlatform
+	acmeRepository *repositories.AcmeRepositoryMemory
+	acmeService    *AcmeServiceImpl
+	ca             *daos.Certificate
+}
+
+func newAcmeTestPlatform(t *testing.T) *acmeTestPlatform {
+	platform := newCertificateTestPlatform(t, "secret")
+	acmeRepository := repositories.NewAcmeRepositoryMemory()
+	acmeService := NewAcmeServiceImpl(
+		acmeRepository,
+		platform.certRepository,
+		platform.certificateService,
+		platform.keyService,
+		platform.ocspService.transactor,
+		"secret",
+		DefaultAcmeValidators(),
+	)
+
+	ca := platform.createRootCA(t, "ACME Test CA")
+	err := acmeService.EnableForUser(
+		context.Background(),
+		ca.ID,
+		certificateTestUserID,
+		&contracts.EnableAcmeRequest{CAKeyPassword: certificateTestKeyPassword},
+	)
+	require.NoError(t, err)
+
+	return &acmeTestPlatform{
+		certificateTestPlatform: platform,
+		acmeRepository:          acmeRepository,
+		acmeService:             acmeService,
+		ca:                      ca,
+	}
+}
+
+// acmeJWS signs payload as an ACME client would, embedding the key when kid is empty
+func acmeJWS(
+	t *testing.T,
+	key crypto.Signer,
+	nonce string,
+	url string,
+	kid string,
+	payload []byte,
+) []byte {
+	options := new(jose.SignerOptions).
+		WithHeader(jose.HeaderKey("nonce"), nonce).
+		WithHeader(jose.HeaderKey("url"), url)
+	if kid != "" {
+		options = options.WithHeader(jose.HeaderKey("kid"), kid)
+	} else {
+		options.EmbedJWK = true
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, options)
+	require.NoError(t, err)
+
+	jws, err := signer.Sign(payload)
+	require.NoError(t, err)
+
+	return []byte(jws.FullSerialize())
+}
+
+// newAccount registers key as an ACME account and returns its kid
+func (p *acmeTestPlatform) newAccount(t *testing.T, key crypto.Signer) (*AcmeRequest, string) {
+	ctx := context.Background()
+	nonce, err := p.acmeService.NewNonce(ctx)
+	require.NoError(t, err)
+
+	req, err := p.acmeService.VerifyRequest(
+		ctx,
+		p.ca.ID,
+		acmeTestURL,
+		acmeTestAccountPrefix,
+		acmeJWS(t, key, nonce, acmeTestURL, "", []byte(`{"termsOfServiceAgreed":true}`)),
+		true,
+	)
+	require.NoError(t, err)
+
+	account, created, err := p.acmeService.NewAccount(ctx, req)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	req.Account = account
+	return req, acmeTestAccountPrefix + account.ID
+}
+
+func TestAcmeVerifyRequest(t *testing.T) {
+	ctx := context.Background()
+	platform := newAcmeTestPlatform(t)
+	accountKey := testutils.Key(t, "ecdsa")
+	_, kid := platform.newAccount(t, accountKey)
+
+	jwk, err := (&jose.JSONWebKey{Key: accountKey.Public()}).MarshalJSON()
+	require.NoError(t, err)
+	otherCAAccount := &daos.AcmeAccount{
+		CertificateID: "other-ca",
+		JWK:           string(jwk),
+		Status:        AcmeStatusValid,
+	}
+	require.NoError(t, platform.acmeRepository.CreateAccount(ctx, otherCAAccount))
+
+	payload := []byte(`{}`)
+
+	tests := []struct {
+		name          string
+		nonce         func(t *testing.T) string
+		url           string
+		kid           string
+		useJWK        bool
+		expectedError string
+	}{
+		{name: "jwk", url: acmeTestURL, useJWK: true},
+		{name: "kid", url: acmeTestURL, kid: kid},
+		{
+			name:          "unknown nonce",
+			nonce:         func(t *testing.T) string { return "unknown" },
+			url:           acmeTestURL,
+			kid:           kid,
+			expectedError: "urn:ietf:params:acme:error:badNonce",
+		},
+		{
+			name: "reused nonce",
+			nonce: func(t *testing.T) string {
+				nonce, err := platform.acmeService.NewNonce(ctx)
+				require.NoError(t, err)
+				_, err = platform.acmeService.VerifyRequest(
+					ctx,
+					platform.ca.ID,
+					acmeTestURL,
+					acmeTestAccountPrefix,
+					acmeJWS(t, accountKey, nonce, acmeTestURL, kid, payload),
+					false,
+				)
+				require.NoError(t, err)
+
+				return nonce
+			},
+			url:           acmeTestURL,
+			kid:           kid,
+			expectedError: "urn:ietf:params:acme:error:badNonce",
+		},
+		{
+			name:          "url mismatch",
+			url:           "https://pki.example.com/acme/new-account",
+			kid:           kid,
+			expectedError: "urn:ietf:params:acme:error:unauthorized",
+		},
+		{
+			name:          "jwk where kid is required",
+			url:           acmeTestURL,
+			expectedError: "urn:ietf:params:acme:error:malformed",
+		},
+		{
+			name:          "kid where jwk is required",
+			url:           acmeTestURL,
+			kid:           kid,
+			useJWK:        true,
+			expectedError: "urn:ietf:params:acme:error:malformed",
+		},
+		{
+			name:          "kid outside the account url",
+			url:           acmeTestURL,
+			kid:           "https://elsewhere.example.com/account/1",
+			expectedError: "urn:ietf:params:acme:error:malformed",
+		},
+		{
+			name:          "unknown account",
+			url:           acmeTestURL,
+			kid:           acmeTestAccountPrefix + "unknown",
+			expectedError: "urn:ietf:params:acme:error:accountDoesNotExist",
+		},
+		{
+			name:          "account of another CA",
+			url:           acmeTestURL,
+			kid:           acmeTestAccountPrefix + otherCAAccount.ID,
+			expectedError: "urn:ietf:params:acme:error:unauthorized",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				var nonce string
+				if test.nonce != nil {
+					nonce = test.nonce(t)
+				} else {
+					var err error
+					nonce, err = platform.acmeService.NewNonce(ctx)
+					require.NoError(t, err)
+				}
+
+				req, err := platform.acmeService.VerifyRequest(
+					ctx,
+					platform.ca.ID,
+					acmeTestURL,
+					acmeTestAccountPrefix,
+					acmeJWS(t, accountKey, nonce, test.url, test.kid, payload),
+					test.useJWK,
+				)
+				if test.expectedError != "" {
+					var acmeErr *AcmeError
+					require.True(t, errors.As(err, &acmeErr), "got %v", err)
+					assert.Equal(t, test.expectedError, acmeErr.Type)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, platform.ca.ID, req.CAID)
+				assert.Equal(t, payload, req.Payload)
+				assert.Equal(t, test.useJWK, req.Account == nil)
+			},
+		)
+	}
+
+	t.Run(
+		"signed by another key", func(t *testing.T) {
+			nonce, err := platform.acmeService.NewNonce(ctx)
+			require.NoError(t, err)
+
+			_, err = platform.acmeService.VerifyRequest(
+				ctx,
+				platform.ca.ID,
+				acmeTestURL,
+				acmeTestAccountPrefix,
+				acmeJWS(t, testutils.Key(t, "ecdsa"), nonce, acmeTestURL, kid, payload),
+				false,
+			)
+			var acmeErr *AcmeError
+			require.True(t, errors.As(err, &acmeErr), "got %v", err)
+			assert.Equal(t, "invalid JWS signature", acmeErr.Detail)
+		},
+	)
+
+	t.Run(
+		"ACME disabled", func(t *testing.T) {
+			_, err := platform.acmeService.VerifyRequest(
+				ctx,
+				"other-ca",
+				acmeTestURL,
+				acmeTestAccountPrefix,
+				acmeJWS(t, accountKey, "nonce", acmeTestURL, kid, payload),
+				false,
+			)
+			assert.ErrorIs(t, err, ErrAcmeNotEnabled)
+		},
+	)
+}
+
+// acmeCSR returns a base64url CSR for names signed by a new key
+func acmeCSR(t *testing.T, commonName string, names ...string) string {
+	der, err := x509.CreateCertificateRequest(
+		rand.Reader,
+		&x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}, DNSNames: names},
+		testutils.Key(t, "ecdsa"),
+	)
+	require.NoError(t, err)
+
+	return base64.RawURLEncoding.EncodeToString(der)
+}
+
+func TestAcmeFinalizeOrder(t *testing.T) {
+	ctx := context.Background()
+	platform := newAcmeTestPlatform(t)
+	req, _ := platform.newAccount(t, testutils.Key(t, "ecdsa"))
+
+	newOrder := func(t *testing.T, ready bool) *daos.AcmeOrder {
+		req.Payload = []byte(
+			`{"identifiers":[{"type":"dns","value":"a.example.com"},` +
+				`{"type":"dns","value":"b.example.com"}]}`,
+		)
+		order, _, err := platform.acmeService.NewOrder(ctx, req)
+		require.NoError(t, err)
+
+		if ready {
+			claimed, err := platform.acmeRepository.UpdateOrderStatus(
+				ctx,
+				order.ID,
+				AcmeStatusPending,
+				AcmeStatusReady,
+			)
+			require.NoError(t, err)
+			require.True(t, claimed)
+		}
+
+		return order
+	}
+
+	tests := []struct {
+		name          string
+		ready         bool
+		csr           string
+		expectedError string
+	}{
+		{
+			name:  "matching CSR",
+			ready: true,
+			csr:   acmeCSR(t, "a.example.com", "a.example.com", "b.example.com"),
+		},
+		{
+			name:          "CSR missing a name",
+			ready:         true,
+			csr:           acmeCSR(t, "a.example.com", "a.example.com"),
+			expectedError: "urn:ietf:params:acme:error:badCSR",
+		},
+		{
+			name:          "CSR with an extra name",
+			ready:         true,
+			csr:           acmeCSR(t, "", "a.example.com", "b.example.com", "c.example.com"),
+			expectedError: "urn:ietf:params:acme:error:badCSR",
+		},
+		{
+			name:          "invalid CSR",
+			ready:         true,
+			csr:           base64.RawURLEncoding.EncodeToString([]byte("not a CSR")),
+			expectedError: "urn:ietf:params:acme:error:badCSR",
+		},
+		{
+			name:          "order not ready",
+			csr:           acmeCSR(t, "a.example.com", "a.example.com", "b.example.com"),
+			expectedError: "urn:ietf:params:acme:error:orderNotReady",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				order := newOrder(t, test.ready)
+				req.Payload, _ = json.Marshal(&contracts.AcmeFinalizeRequest{CSR: test.csr})
+
+				finalized, authorizations, err := platform.acmeService.FinalizeOrder(
+					ctx,
+					req,
+					order.ID,
+				)
+				if test.expectedError != "" {
+					var acmeErr *AcmeError
+					require.True(t, errors.As(err, &acmeErr), "got %v", err)
+					assert.Equal(t, test.expectedError, acmeErr.Type)
+
+					stored, err := platform.acmeRepository.GetOrder(ctx, order.ID)
+					require.NoError(t, err)
+					assert.Empty(t, stored.CertificateID)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, AcmeStatusValid, finalized.Status)
+				assert.Len(t, authorizations, 2)
+
+				certDao, err := platform.certRepository.GetCertByID(ctx, finalized.CertificateID)
+				require.NoError(t, err)
+				assert.Equal(t, platform.ca.ID, certDao.ParentCertificate)
+
+				cert, err := x509.ParseCertificate(certDao.Data)
+				require.NoError(t, err)
+				assert.ElementsMatch(t, []string{"a.example.com", "b.example.com"}, cert.DNSNames)
+
+				_, _, err = platform.acmeService.FinalizeOrder(ctx, req, order.ID)
+				var acmeErr *AcmeError
+				require.True(t, errors.As(err, &acmeErr), "finalized only once")
+				assert.Equal(t, "urn:ietf:params:acme:error:orderNotReady", acmeErr.Type)
+			},
+		)
+	}
+
+	t.Run(
+		"order of another account", func(t *testing.T) {
+			order := newOrder(t, true)
+			other, _ := platform.newAccount(t, testutils.Key(t, "ecdsa"))
+			other.Payload, _ = json.Marshal(
+				&contracts.AcmeFinalizeRequest{
+					CSR: acmeCSR(t, "a.example.com", "a.example.com", "b.example.com"),
+				},
+			)
+
+			_, _, err := platform.acmeService.FinalizeOrder(ctx, other, order.ID)
+			var acmeErr *AcmeError
+			require.True(t, errors.As(err, &acmeErr), "got %v", err)
+			assert.Equal(t, "urn:ietf:params:acme:error:unauthorized", acmeErr.Type)
+		},
+	)
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/context/request"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+)
+
+var _ AuditService = (*AuditServiceImpl)(nil)
+
+var ErrInvalidAuditQuery = errors.New("invalid audit log query")
+
+// AuditAction names a recorded operation as <target type>.<verb>
+type AuditAction string
+
+const (
+	AuditKeyCreate  AuditAction = "key.create"
+	AuditKeyImport  AuditAction = "key.import"
+	AuditKeyExport  AuditAction = "key.export"
+	AuditKeyDecrypt AuditAction = "key.decrypt"
+	AuditKeyDelete  AuditAction = "key.delete"
+	AuditKeySign    AuditAction = "key.sign"
+
+	AuditCertificateCreate   AuditAction = "certificate.create"
+	AuditCertificateSignCSR  AuditAction = "certificate.sign_csr"
+	AuditCertificateImport   AuditAction = "certificate.import"
+	AuditCertificateDownload AuditAction = "certificate.download"
+	AuditCertificateRevoke   AuditAction = "certificate.revoke"
+	AuditCertificateDelete   AuditAction = "certificate.delete"
+	AuditCertificateRenew    AuditAction = "certificate.renew"
+	AuditCertificateRekey    AuditAction = "certificate.rekey"
+	AuditCertificateSignData AuditAction = "certificate.sign_data"
+
+	AuditSSHCertificateSign   AuditAction = "ssh_certificate.sign"
+	AuditSSHCertificateRevoke AuditAction = "ssh_certificate.revoke"
+
+	AuditJWKSetSignJWT AuditAction = "jwk_set.sign_jwt"
+
+	AuditUserCreate        AuditAction = "user.create"
+	AuditUserLogin         AuditAction = "user.login"
+	AuditUserOAuthValidate AuditAction = "user.oauth_validate"
+)
+
+const (
+	// auditGenesisHash is the previous hash of the first entry in the chain
+	auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+	// auditAppendAttempts bounds the retries when another replica takes the next sequence number
+	auditAppendAttempts = 5
+	auditDefaultLimit   = 50
+	auditMaxLimit       = 500
+	// auditVerifyPageSize is how many entries Verify loads at a time
+	auditVerifyPageSize = 500
+	// auditEnqueueTimeout is how long Record waits on a full queue before dropping the entry
+	auditEnqueueTimeout = 5 * time.Second
+	// auditChainKeyPurpose separates the chain key from other keys derived from the server secret
+	auditChainKeyPurpose = "audit-chain"
+)
+
+// AuditService keeps a hash chained log of security relevant operations. Every entry carries the
+// hash of the one before it, so an entry that is edited or removed in the database breaks the
+// chain from that point on, which Verify reports. The hashes are keyed with the server secret, so
+// rewriting the chain takes the secret as well as database access.
+type AuditService interface {
+	// Record queues an entry for action on targetID by actorID, err being the operation's
+	// outcome. The source IP and user agent come from the request on ctx. Recording never fails
+	// the operation, entries that can't be written are logged instead.
+	Record(
+		ctx context.Context,
+		actorID string,
+		action AuditAction,
+		targetID string,
+		err error,
+	)
+	// GetEntriesForUser returns a page of the entries whose actor is userID, newest first
+	GetEntriesForUser(
+		ctx context.Context,
+		userID string,
+		filter *repositories.AuditFilter,
+	) (*contracts.AuditLogResponse, error)
+	// Verify walks the whole chain checking every link and hash
+	Verify(ctx context.Context) (*contracts.AuditVerificationResponse, error)
+}
+
+// AuditServiceImpl hands recorded entries to WriteQueued through a channel. Writing them from a
+// single goroutine keeps the chain in order within the process and keeps entries out of any
+// transaction the recorded operation runs in, so failures that roll back are still logged.
+type AuditServiceImpl struct {
+	auditRepository repositories.AuditRepository
+	queue           chan *daos.AuditEntry
+	chainKey        []byte
+}
+
+// NewAuditServiceImpl keys the chain with a key derived from secretKey. Without a secret the
+// hashes are plain SHA-256, which anyone able to edit the database can recompute.
+func NewAuditServiceImpl(
+	auditRepository repositories.AuditRepository,
+	secretKey string,
+) *AuditServiceImpl {
+	// The only failure is a missing secret, which leaves the key empty
+	chainKey, _ := utils.DeriveKey(secretKey, auditChainKeyPurpose)
+
+	return &AuditServiceImpl{
+		auditRepository: auditRepository,
+		queue:           make(chan *daos.AuditEntry, 1024),
+		chainKey:        chainKey,
+	}
+}
+
+func (a *AuditServiceImpl) Record(
+	ctx context.Context,
+	actorID string,
+	action AuditAction,
+	targetID string,
+	err error,
+) {
+	source := request.Get(ctx)
+	targetType, _, _ := strings.Cut(string(action), ".")
+
+	entry := &daos.AuditEntry{
+		ActorID:    actorID,
+		Action:     string(action),
+		TargetType: targetType,
+		TargetID:   targetID,
+		Outcome:    daos.AuditSuccess,
+		SourceIP:   source.IP,
+		UserAgent:  source.UserAgent,
+		// Databases keep timestamps to varying precision, the hash has to survive the round trip
+		Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+	}
+
+	if err != nil {
+		entry.Outcome = daos.AuditFailure
+		entry.Error = err.Error()
+	}
+
+	select {
+	case a.queue <- entry:
+		return
+	default:
+	}
+
+	// The writer has fallen behind or stopped, wait a while for room rather than hanging the
+	// operation forever
+	timer := time.NewTimer(auditEnqueueTimeout)
+	defer timer.Stop()
+
+	var reason string
+	select {
+	case a.queue <- entry:
+		return
+	case <-ctx.Done():
+		reason = ctx.Err().Error()
+	case <-timer.C:
+		reason = "audit queue is full"
+	}
+
+	logger.Get(ctx).
+		WithField("actorID", entry.ActorID).
+		WithField("action", entry.Action).
+		WithField("targetID", entry.TargetID).
+		WithField("outcome", entry.Outcome).
+		WithField("timestamp", entry.Timestamp).
+		Errorf("dropped audit entry: %s", reason)
+}
+
+// WriteQueued appends recorded entries to the chain until ctx is done
+func (a *AuditServiceImpl) WriteQueued(ctx context.Context) {
+	log := logger.Get(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case entry := <-a.queue:
+			err := a.appendEntry(ctx, entry)
+			if err != nil {
+				log.WithError(err).
+					WithField("action", entry.Action).
+					WithField("targetID", entry.TargetID).
+					Error("failed to write audit entry")
+			}
+		}
+	}
+}
+
+// appendEntry links entry to the current head of the chain. Replicas share the chain, so when
+// another one appends first the sequence number is taken and the entry is linked again.
+func (a *AuditServiceImpl) appendEntry(ctx context.Context, entry *daos.AuditEntry) error {
+	var err error
+
+	for attempt := 0; attempt < auditAppendAttempts; attempt++ {
+		var head *daos.AuditEntry
+		head, err = a.auditRepository.GetLatestEntry(ctx)
+		switch {
+		case errors.Is(err, repositories.ErrNoRecord):
+			entry.Sequence = 1
+			entry.PrevHash = auditGenesisHash
+		case err != nil:
+			return err
+		default:
+			entry.Sequence = head.Sequence + 1
+			entry.PrevHash = head.Hash
+		}
+
+		entry.Hash, err = auditHash(a.chainKey, entry)
+		if err != nil {
+			return err
+		}
+
+		err = a.auditRepository.CreateEntry(ctx, entry)
+		if !errors.Is(err, repositories.ErrDuplicateRecord) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// auditHash is the hex HMAC-SHA256 under key of every field but the hash itself, encoded as a
+// JSON array so the input is unambiguous. An empty key falls back to plain SHA-256.
+func auditHash(key []byte, entry *daos.AuditEntry) (string, error) {
+	data, err := json.Marshal(
+		[]interface{}{
+			entry.Sequence,
+			entry.PrevHash,
+			entry.Timestamp.UTC().Format(time.RFC3339Nano),
+			entry.ActorID,
+			entry.Action,
+			entry.TargetType,
+			entry.TargetID,
+			entry.Outcome,
+			entry.Error,
+			entry.SourceIP,
+			entry.UserAgent,
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if len(key) == 0 {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (a *AuditServiceImpl) GetEntriesForUser(
+	ctx context.Context,
+	userID string,
+	filter *repositories.AuditFilter,
+) (*contracts.AuditLogResponse, error) {
+	if filter.Limit < 0 || filter.Limit > auditMaxLimit {
+		return nil, fmt.Errorf(
+			"%w: limit must be between 1 and %d",
+			ErrInvalidAuditQuery,
+			auditMaxLimit,
+		)
+	}
+
+	if filter.Outcome != "" && filter.Outcome != daos.AuditSuccess &&
+		filter.Outcome != daos.AuditFailure {
+		return nil, fmt.Errorf(
+			"%w: outcome must be %s or %s",
+			ErrInvalidAuditQuery,
+			daos.AuditSuccess,
+			daos.AuditFailure,
+		)
+	}
+
+	scoped := *filter
+	scoped.ActorID = userID
+	if scoped.Limit == 0 {
+		scoped.Limit = auditDefaultLimit
+	}
+
+	entries, err := a.auditRepository.GetEntries(ctx, &scoped)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &contracts.AuditLogResponse{
+		Entries: make([]*contracts.AuditEntryResponse, len(entries)),
+	}
+	for i, entry := range entries {
+		resp.Entries[i] = entry.ToResponse()
+	}
+
+	if len(entries) == scoped.Limit {
+		resp.NextBefore = entries[len(entries)-1].Sequence
+	}
+
+	return resp, nil
+}
+
+func (a *AuditServiceImpl) Verify(
+	ctx context.Context,
+) (*contracts.AuditVerificationResponse, error) {
+	resp := &contracts.AuditVerificationResponse{Valid: true}
+	prev := &daos.AuditEntry{Hash: auditGenesisHash}
+
+	for {
+		entries, err := a.auditRepository.GetEntriesAfter(ctx, prev.Sequence, auditVerifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			hash, err := auditHash(a.chainKey, entry)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case entry.Sequence != prev.Sequence+1:
+				resp.Error = fmt.Sprintf(
+					"entries %d to %d are missing",
+					prev.Sequence+1,
+					entry.Sequence-1,
+				)
+			case entry.PrevHash != prev.Hash:
+				resp.Error = "previous hash does not match the preceding entry"
+			case entry.Hash != hash:
+				resp.Error = "hash does not match the entry's contents"
+			}
+
+			if resp.Error != "" {
+				resp.Valid = false
+				resp.FirstInvalidSequence = entry.Sequence
+
+				return resp, nil
+			}
+
+			resp.Entries++
+			prev = entry
+		}
+
+		if len(entries) < auditVerifyPageSize {
+			return resp, nil
+		}
+	}
+}
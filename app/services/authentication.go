@@ -31,6 +31,7 @@ type AuthService interface {
 
 type AuthServiceImpl struct {
 	userRepository repositories.UserRepository
+	auditService   AuditService
 }
 
 func (s *AuthServiceImpl) GetUserForRequest(ctx context.Context, r *http.Request) (
@@ -54,9 +55,13 @@ func (s *AuthServiceImpl) GetUserForRequest(ctx context.Context, r *http.Request
 	return user, nil
 }
 
-func NewAuthService(userRepository repositories.UserRepository) AuthService {
+func NewAuthService(
+	userRepository repositories.UserRepository,
+	auditService AuditService,
+) AuthService {
 	return &AuthServiceImpl{
 		userRepository: userRepository,
+		auditService:   auditService,
 	}
 }
 
@@ -64,7 +69,16 @@ func (s *AuthServiceImpl) ValidateOAuthToken(
 	ctx context.Context,
 	provider string,
 	accessToken string,
-) (*daos.User, error) {
+) (user *daos.User, err error) {
+	defer func() {
+		var userID string
+		if user != nil {
+			userID = user.ID
+		}
+
+		s.auditService.Record(ctx, userID, AuditUserOAuthValidate, userID, err)
+	}()
+
 	// TODO: CONFIGURE THIS
 	const aud = "834953141481-an55r41f085lol5fknij3rp5g9e8ho19.apps.googleusercontent.com"
 
@@ -76,7 +90,7 @@ func (s *AuthServiceImpl) ValidateOAuthToken(
 	claims := parseOAuthClaims(payload.Claims)
 
 	// See if user exists
-	user, err := s.userRepository.GetUserByEmail(ctx, payload.Claims["email"].(string))
+	user, err = s.userRepository.GetUserByEmail(ctx, payload.Claims["email"].(string))
 
 	if err != nil && errors.Is(err, repositories.ErrNoRecord) {
 		password, err := utils.GenerateRandomString(32)
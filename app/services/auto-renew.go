@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/events"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+)
+
+const (
+	// autoRenewDefaultThresholdPercent applies when a policy sets neither threshold
+	autoRenewDefaultThresholdPercent = 30
+	// autoRenewRetryDelay is the wait after the first failed attempt, doubling with each failure
+	// up to autoRenewMaxRetryDelay
+	autoRenewRetryDelay    = 15 * time.Minute
+	autoRenewMaxRetryDelay = 24 * time.Hour
+	// autoRenewClaimTTL is how long a worker has to renew a policy it claimed before another
+	// may take it over
+	autoRenewClaimTTL = time.Hour
+)
+
+var ErrInvalidAutoRenewPolicy = errors.New("invalid auto-renew policy")
+
+var _ AutoRenewService = (*AutoRenewServiceImpl)(nil)
+
+type AutoRenewService interface {
+	EnableForUser(
+		ctx context.Context,
+		certID string,
+		userID string,
+		request *contracts.EnableAutoRenewRequest,
+	) (*contracts.AutoRenewResponse, error)
+	GetForUser(ctx context.Context, certID string, userID string) (*contracts.AutoRenewResponse, error)
+	DisableForUser(ctx context.Context, certID string, userID string) error
+	// RenewDue renews every certificate whose policy is due and returns how many succeeded.
+	// The outcome of each attempt is recorded on its policy and published as an event.
+	RenewDue(ctx context.Context) (int, error)
+}
+
+type AutoRenewServiceImpl struct {
+	autoRenewRepository repositories.AutoRenewRepository
+	certRepository      repositories.CertRepository
+	certificateService  CertificateService
+	ocspService         OCSPService
+	keyService          KeyService
+	transactor          repositories.Transactor
+	publisher           events.Publisher
+	secretKey           string
+}
+
+func NewAutoRenewServiceImpl(
+	autoRenewRepository repositories.AutoRenewRepository,
+	certRepository repositories.CertRepository,
+	certificateService CertificateService,
+	ocspService OCSPService,
+	keyService KeyService,
+	transactor repositories.Transactor,
+	publisher events.Publisher,
+	secretKey string,
+) *AutoRenewServiceImpl {
+	return &AutoRenewServiceImpl{
+		autoRenewRepository: autoRenewRepository,
+		certRepository:      certRepository,
+		certificateService:  certificateService,
+		ocspService:         ocspService,
+		keyService:          keyService,
+		transactor:          transactor,
+		publisher:           publisher,
+		secretKey:           secretKey,
+	}
+}
+
+func (a *AutoRenewServiceImpl) EnableForUser(
+	ctx context.Context,
+	certID string,
+	userID string,
+	request *contracts.EnableAutoRenewRequest,
+) (*contracts.AutoRenewResponse, error) {
+	certDao, err := a.certRepository.GetCertByID(ctx, certID)
+	if err != nil {
+		return nil, err
+	}
+
+	if certDao.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	if certDao.IsRevoked() {
+		return nil, ErrCertAlreadyRevoked
+	}
+
+	cert, err := x509.ParseCertificate(certDao.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isRenewable(certDao, cert) {
+		return nil, ErrCertNotRenewable
+	}
+
+	thresholdPercent, thresholdDays := request.ThresholdPercent, request.ThresholdDays
+	if thresholdPercent < 0 || thresholdPercent >= 100 || thresholdDays < 0 {
+		return nil, fmt.Errorf(
+			"%w: thresholds must be a percentage below 100 and a positive number of days",
+			ErrInvalidAutoRenewPolicy,
+		)
+	}
+
+	if thresholdPercent == 0 && thresholdDays == 0 {
+		thresholdPercent = autoRenewDefaultThresholdPercent
+	}
+
+	// Make sure the passwords actually unlock the keys before they are stored
+	if certDao.ParentCertificate != "" {
+		caKeyID, err := a.certRepository.GetKeyIDByCertID(ctx, certDao.ParentCertificate)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = a.keyService.GetDecryptedKeyForUser(ctx, caKeyID, userID, request.CAKeyPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if certDao.ParentCertificate == "" || isCAType(certDao.Type) {
+		_, err = a.keyService.GetDecryptedKeyForUser(ctx, certDao.KeyID, userID, request.KeyPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sealedCAKeyPassword, err := utils.Seal(a.secretKey, []byte(request.CAKeyPassword))
+	if err != nil {
+		return nil, err
+	}
+
+	sealedKeyPassword, err := utils.Seal(a.secretKey, []byte(request.KeyPassword))
+	if err != nil {
+		return nil, err
+	}
+
+	// Successors keep the validity period, so a threshold covering all of it would renew them
+	// back to back
+	renewAt := autoRenewAt(cert, thresholdPercent, thresholdDays)
+	if !renewAt.After(cert.NotBefore) {
+		return nil, fmt.Errorf(
+			"%w: threshold covers the whole validity period",
+			ErrInvalidAutoRenewPolicy,
+		)
+	}
+
+	policy := &daos.AutoRenewPolicy{
+		CertificateID:       certID,
+		UserID:              userID,
+		ThresholdPercent:    thresholdPercent,
+		ThresholdDays:       thresholdDays,
+		SealedCAKeyPassword: sealedCAKeyPassword,
+		SealedKeyPassword:   sealedKeyPassword,
+		RevokePredecessor:   request.RevokePredecessor,
+		RenewAt:             renewAt,
+		NextAttempt:         renewAt,
+		Status:              daos.AutoRenewPending,
+	}
+
+	err = a.autoRenewRepository.SavePolicy(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.ToResponse(), nil
+}
+
+func (a *AutoRenewServiceImpl) GetForUser(
+	ctx context.Context,
+	certID string,
+	userID string,
+) (*contracts.AutoRenewResponse, error) {
+	policy, err := a.getPolicyForUser(ctx, certID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.ToResponse(), nil
+}
+
+func (a *AutoRenewServiceImpl) DisableForUser(
+	ctx context.Context,
+	certID string,
+	userID string,
+) error {
+	_, err := a.getPolicyForUser(ctx, certID, userID)
+	if err != nil {
+		return err
+	}
+
+	return a.autoRenewRepository.DeletePolicy(ctx, certID)
+}
+
+func (a *AutoRenewServiceImpl) getPolicyForUser(
+	ctx context.Context,
+	certID string,
+	userID string,
+) (*daos.AutoRenewPolicy, error) {
+	policy, err := a.autoRenewRepository.GetPolicy(ctx, certID)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	return policy, nil
+}
+
+func (a *AutoRenewServiceImpl) RenewDue(ctx context.Context) (int, error) {
+	log := logger.Get(ctx)
+
+	due, err := a.autoRenewRepository.GetPoliciesDue(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	renewed := 0
+	for _, policy := range due {
+		// Claiming each policy right before renewing it keeps replicas from renewing it twice,
+		// even when a slow run outlives the worker's lease
+		now := time.Now()
+		claimed, err := a.autoRenewRepository.ClaimPolicy(
+			ctx,
+			policy.CertificateID,
+			now,
+			now.Add(autoRenewClaimTTL),
+		)
+		if err != nil {
+			log.WithError(err).Errorf("failed to claim auto-renew policy %s", policy.CertificateID)
+			continue
+		}
+
+		if !claimed {
+			continue
+		}
+
+		policy.Status = daos.AutoRenewRenewing
+		policy.NextAttempt = now.Add(autoRenewClaimTTL)
+
+		err = a.renew(ctx, policy)
+		if err != nil {
+			log.WithError(err).Warnf("unable to auto-renew certificate %s", policy.CertificateID)
+			continue
+		}
+
+		renewed++
+	}
+
+	return renewed, nil
+}
+
+// renew issues the successor of a policy's certificate and hands the policy over to it
+func (a *AutoRenewServiceImpl) renew(ctx context.Context, policy *daos.AutoRenewPolicy) error {
+	caKeyPassword, err := utils.Open(a.secretKey, policy.SealedCAKeyPassword)
+	if err != nil {
+		return a.recordFailure(ctx, policy, err)
+	}
+
+	keyPassword, err := utils.Open(a.secretKey, policy.SealedKeyPassword)
+	if err != nil {
+		return a.recordFailure(ctx, policy, err)
+	}
+
+	now := time.Now()
+	var successor *contracts.CertificateLightResponse
+	err = a.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			var err error
+			successor, err = a.certificateService.RenewCertForUser(
+				ctx,
+				policy.CertificateID,
+				policy.UserID,
+				&contracts.RenewCertificateRequest{
+					CAKeyPassword:     string(caKeyPassword),
+					KeyPassword:       string(keyPassword),
+					RevokePredecessor: policy.RevokePredecessor,
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			if isCAType(successor.Type) {
+				err = SetupCA(
+					ctx,
+					a.certificateService,
+					a.ocspService,
+					successor.ID,
+					policy.UserID,
+					string(keyPassword),
+				)
+				if err != nil {
+					return err
+				}
+			}
+
+			successorDao, err := a.certRepository.GetCertByID(ctx, successor.ID)
+			if err != nil {
+				return err
+			}
+
+			successorCert, err := x509.ParseCertificate(successorDao.Data)
+			if err != nil {
+				return err
+			}
+
+			renewAt := autoRenewAt(successorCert, policy.ThresholdPercent, policy.ThresholdDays)
+			err = a.autoRenewRepository.SavePolicy(
+				ctx, &daos.AutoRenewPolicy{
+					CertificateID:       successor.ID,
+					UserID:              policy.UserID,
+					ThresholdPercent:    policy.ThresholdPercent,
+					ThresholdDays:       policy.ThresholdDays,
+					SealedCAKeyPassword: policy.SealedCAKeyPassword,
+					SealedKeyPassword:   policy.SealedKeyPassword,
+					RevokePredecessor:   policy.RevokePredecessor,
+					RenewAt:             renewAt,
+					NextAttempt:         renewAt,
+					Status:              daos.AutoRenewPending,
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			policy.Status = daos.AutoRenewRenewed
+			policy.LastAttempt = &now
+			policy.LastError = ""
+			policy.RenewedCertificateID = successor.ID
+
+			return a.autoRenewRepository.SavePolicy(ctx, policy)
+		},
+	)
+	if err != nil {
+		return a.recordFailure(ctx, policy, err)
+	}
+
+	a.publisher.Publish(
+		ctx, &events.Event{
+			Type:          events.CertificateRenewed,
+			UserID:        policy.UserID,
+			CertificateID: policy.CertificateID,
+			Data: map[string]string{
+				"successorID": successor.ID,
+			},
+		},
+	)
+
+	return nil
+}
+
+// recordFailure stores the outcome of a failed attempt and schedules the next one, unless the
+// error means retrying can never succeed
+func (a *AutoRenewServiceImpl) recordFailure(
+	ctx context.Context,
+	policy *daos.AutoRenewPolicy,
+	cause error,
+) error {
+	now := time.Now()
+	policy.Failures++
+	policy.LastAttempt = &now
+	policy.LastError = cause.Error()
+
+	permanent := isPermanentRenewalError(cause)
+	if permanent {
+		policy.Status = daos.AutoRenewFailed
+	} else {
+		policy.Status = daos.AutoRenewPending
+		policy.NextAttempt = now.Add(
+			retryBackoff(policy.Failures, autoRenewRetryDelay, autoRenewMaxRetryDelay),
+		)
+	}
+
+	err := a.autoRenewRepository.SavePolicy(ctx, policy)
+	if err != nil {
+		logger.Get(ctx).WithError(err).Errorf(
+			"failed to record auto-renew failure for certificate %s",
+			policy.CertificateID,
+		)
+	}
+
+	a.publisher.Publish(
+		ctx, &events.Event{
+			Type:          events.CertificateRenewalFailed,
+			UserID:        policy.UserID,
+			CertificateID: policy.CertificateID,
+			Data: map[string]string{
+				"error":       cause.Error(),
+				"permanent":   fmt.Sprint(permanent),
+				"nextAttempt": policy.NextAttempt.Format(time.RFC3339),
+			},
+		},
+	)
+
+	return cause
+}
+
+func isPermanentRenewalError(err error) bool {
+	return errors.Is(err, repositories.ErrNoRecord) ||
+		errors.Is(err, ErrCertAlreadyRevoked) ||
+		errors.Is(err, ErrCertNotRenewable) ||
+		errors.Is(err, ErrCertUnautorized) ||
+		errors.Is(err, ErrKeyUnauthorized) ||
+		errors.Is(err, x509.IncorrectPasswordError)
+}
+
+// retryBackoff is the wait after the given number of failed attempts, starting at delay and
+// doubling with each failure up to maxDelay
+func retryBackoff(failures int, delay time.Duration, maxDelay time.Duration) time.Duration {
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
+
+// autoRenewAt is when the remaining lifetime of cert drops below thresholdPercent of its
+// validity or thresholdDays, whichever comes first
+func autoRenewAt(cert *x509.Certificate, thresholdPercent int, thresholdDays int) time.Time {
+	remaining := cert.NotAfter.Sub(cert.NotBefore) * time.Duration(thresholdPercent) / 100
+	if days := time.Duration(thresholdDays) * 24 * time.Hour; days > remaining {
+		remaining = days
+	}
+
+	return cert.NotAfter.Add(-remaining)
+}
+
+func isCAType(certType string) bool {
+	return certType == CertTypeRootCA.String() || certType == CertTypeIntermediateCA.String()
+}
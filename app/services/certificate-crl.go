@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+)
+
+const (
+	// crlValidity is how far in the future nextUpdate is set on a freshly generated CRL
+	crlValidity = 7 * 24 * time.Hour
+	// crlRefreshWindow is how long before nextUpdate a CRL is regenerated on schedule
+	crlRefreshWindow = 24 * time.Hour
+)
+
+func (c *CertificateServiceImpl) RevokeCertForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+	reason contracts.RevocationReason,
+	caKeyPassword string,
+) (err error) {
+	defer func() {
+		c.auditService.Record(ctx, userID, AuditCertificateRevoke, id, err)
+	}()
+
+	if reason == contracts.ReasonRemoveFromCRL {
+		return errors.New("removeFromCRL is only valid in delta CRLs")
+	}
+
+	cert, err := c.certRepository.GetCertByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if cert.UserID != userID {
+		return ErrCertUnautorized
+	}
+
+	if cert.IsRevoked() {
+		return ErrCertAlreadyRevoked
+	}
+
+	// The revocation only sticks once the issuer's CRL lists it
+	return c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			err := c.certRepository.RevokeCertByID(ctx, id, time.Now(), int(reason))
+			if err != nil {
+				return err
+			}
+
+			// Self-signed roots have no issuer to publish a CRL for them
+			if cert.ParentCertificate == "" {
+				return nil
+			}
+
+			return c.GenerateCRLForUser(ctx, cert.ParentCertificate, userID, caKeyPassword)
+		},
+	)
+}
+
+// SetupCA publishes the initial CRL of a CA able to sign and issues its OCSP responder. Every
+// path creating a CA, from scratch, by import or by renewal, runs it before committing.
+func SetupCA(
+	ctx context.Context,
+	certificateService CertificateService,
+	ocspService OCSPService,
+	caID string,
+	userID string,
+	keyPassword string,
+) error {
+	err := certificateService.GenerateCRLForUser(ctx, caID, userID, keyPassword)
+	if err != nil {
+		return fmt.Errorf("failed to generate initial CRL: %w", err)
+	}
+
+	_, err = ocspService.IssueResponderForUser(ctx, caID, userID, keyPassword)
+	if errors.Is(err, utils.ErrNoSecret) {
+		// The responder key can't be protected without a server secret, so OCSP falls back to
+		// the CA key, which only works when it has no password
+		logger.Get(ctx).Warnf("no OCSP responder issued for CA %s without a server secret", caID)
+	} else if err != nil {
+		return fmt.Errorf("failed to issue OCSP responder: %w", err)
+	}
+
+	return nil
+}
+
+// GenerateCRLForUser signs a new CRL for the CA. Once caKeyPassword has unlocked the CA key it
+// is sealed with the server secret and kept, so RefreshDueCRLs can regenerate the CRL unattended.
+func (c *CertificateServiceImpl) GenerateCRLForUser(
+	ctx context.Context,
+	caID string,
+	userID string,
+	caKeyPassword string,
+) error {
+	ca, err := c.certRepository.GetCertByID(ctx, caID)
+	if err != nil {
+		return err
+	}
+
+	if ca.UserID != userID {
+		return ErrCertUnautorized
+	}
+
+	_, err = c.generateCRL(ctx, ca, caKeyPassword)
+	if err != nil {
+		return err
+	}
+
+	refreshError := ""
+	sealed, err := utils.Seal(c.secretKey, []byte(caKeyPassword))
+	if err != nil {
+		// The CRL is still valid, it just can't be refreshed until the secret is configured
+		logger.Get(ctx).WithError(err).Warnf("unable to seal key password of CA %s", ca.ID)
+		refreshError = fmt.Sprintf("unable to seal CA key password: %s", err)
+	} else {
+		err = c.certRepository.SetCertSealedKeyPassword(ctx, ca.ID, sealed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.setRefreshError(ctx, ca, refreshError)
+}
+
+func (c *CertificateServiceImpl) GetCRL(ctx context.Context, caID string) ([]byte, error) {
+	crl, err := c.certRepository.GetLatestCRL(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	return crl.Data, nil
+}
+
+// RefreshDueCRLs regenerates every CRL whose nextUpdate falls within the refresh window, unlocking
+// the CA key with the password sealed by GenerateCRLForUser. CAs that can't be refreshed have
+// the failure recorded on them, so it shows on the CA until a refresh succeeds again.
+func (c *CertificateServiceImpl) RefreshDueCRLs(ctx context.Context) (int, error) {
+	log := logger.Get(ctx)
+
+	due, err := c.certRepository.GetLatestCRLsDueBefore(ctx, time.Now().Add(crlRefreshWindow))
+	if err != nil {
+		return 0, err
+	}
+
+	refreshed := 0
+	for _, crl := range due {
+		ca, err := c.certRepository.GetCertByID(ctx, crl.CertificateID)
+		if err != nil {
+			log.WithError(err).Errorf("failed to get CA %s for CRL refresh", crl.CertificateID)
+			continue
+		}
+
+		err = c.refreshCRL(ctx, ca)
+		if err != nil {
+			log.WithError(err).Warnf("unable to refresh CRL for CA %s", ca.ID)
+
+			err = c.setRefreshError(ctx, ca, fmt.Sprintf("unable to refresh CRL: %s", err))
+			if err != nil {
+				log.WithError(err).Errorf("failed to record CRL refresh failure of CA %s", ca.ID)
+			}
+			continue
+		}
+
+		err = c.setRefreshError(ctx, ca, "")
+		if err != nil {
+			log.WithError(err).Errorf("failed to clear CRL refresh failure of CA %s", ca.ID)
+		}
+
+		refreshed++
+	}
+
+	return refreshed, nil
+}
+
+func (c *CertificateServiceImpl) refreshCRL(ctx context.Context, ca *daos.Certificate) error {
+	caKeyPassword, err := unsealKeyPassword(c.secretKey, ca)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.generateCRL(ctx, ca, caKeyPassword)
+	return err
+}
+
+// unsealKeyPassword opens the sealed key password of a certificate. Keys of CAs set up before
+// passwords were sealed are assumed to have none.
+func unsealKeyPassword(secretKey string, cert *daos.Certificate) (string, error) {
+	if len(cert.SealedKeyPassword) == 0 {
+		return "", nil
+	}
+
+	password, err := utils.Open(secretKey, cert.SealedKeyPassword)
+	if err != nil {
+		return "", fmt.Errorf("unable to open sealed key password: %w", err)
+	}
+
+	return string(password), nil
+}
+
+// setRefreshError records the outcome of a refresh on the CA, skipping writes that change nothing
+func (c *CertificateServiceImpl) setRefreshError(
+	ctx context.Context,
+	ca *daos.Certificate,
+	refreshError string,
+) error {
+	if ca.RefreshError == refreshError {
+		return nil
+	}
+
+	err := c.certRepository.SetCertRefreshError(ctx, ca.ID, refreshError)
+	if err != nil {
+		return err
+	}
+
+	ca.RefreshError = refreshError
+	return nil
+}
+
+func (c *CertificateServiceImpl) generateCRL(
+	ctx context.Context,
+	ca *daos.Certificate,
+	caKeyPassword string,
+) (*daos.CRL, error) {
+	caCert, err := x509.ParseCertificate(ca.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	caKey, err := c.keyService.GetDecryptedKeyForUser(ctx, ca.KeyID, ca.UserID, caKeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := caKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("CA key is not capable of signing")
+	}
+
+	revoked, err := c.certRepository.GetRevokedCertsByParentCA(ctx, ca.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]x509.RevocationListEntry, len(revoked))
+	for i, revokedCert := range revoked {
+		cert, err := x509.ParseCertificate(revokedCert.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[i] = x509.RevocationListEntry{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: *revokedCert.RevokedAt,
+			ReasonCode:     revokedCert.RevocationReason,
+		}
+	}
+
+	var number int64 = 1
+	latest, err := c.certRepository.GetLatestCRL(ctx, ca.ID)
+	if err == nil {
+		number = latest.Number + 1
+	} else if !errors.Is(err, repositories.ErrNoRecord) {
+		return nil, err
+	}
+
+	thisUpdate := time.Now()
+	nextUpdate := thisUpdate.Add(crlValidity)
+
+	data, err := x509.CreateRevocationList(
+		rand.Reader,
+		&x509.RevocationList{
+			RevokedCertificateEntries: entries,
+			Number:                    big.NewInt(number),
+			ThisUpdate:                thisUpdate,
+			NextUpdate:                nextUpdate,
+		},
+		caCert,
+		signer,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.certRepository.CreateCRL(ctx, ca.ID, number, data, thisUpdate, nextUpdate)
+}
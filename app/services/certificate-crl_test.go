@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	certificateTestUserID      = "user"
+	certificateTestKeyPassword = "password"
+)
+
+// certificateTestPlatform is the certificate, key, profile and OCSP services over memory
+// repositories
+type certificateTestPlatform struct {
+	certRepository     *repositories.CertRepositoryMemory
+	keyRepository      *repositories.KeyRepositoryMemory
+	keyService         KeyService
+	profileService     *CertificateProfileServiceImpl
+	certificateService *CertificateServiceImpl
+	ocspService        *OCSPServiceImpl
+}
+
+func newCertificateTestPlatform(t *testing.T, secretKey string) *certificateTestPlatform {
+	certRepository := repositories.NewCertRepositoryMemory()
+	keyRepository := repositories.NewKeyRepositoryMemory()
+	logRepository := repositories.NewTransparencyLogRepositoryMemory()
+	profileRepository := repositories.NewCertificateProfileRepositoryMemory()
+	transactor := repositories.NewTransactorMemory(certRepository, keyRepository, logRepository)
+	keyService := NewKeyServiceImpl(
+		keyRepository,
+		certRepository,
+		transactor,
+		NewAuditServiceImpl(repositories.NewAuditRepositoryMemory(), secretKey),
+	)
+	transparencyLog := NewTransparencyLogServiceImpl(
+		logRepository,
+		certRepository,
+		transactor,
+		secretKey,
+	)
+
+	return &certificateTestPlatform{
+		certRepository: certRepository,
+		keyRepository:  keyRepository,
+		keyService:     keyService,
+		profileService: NewCertificateProfileServiceImpl(profileRepository),
+		certificateService: NewCertificateServiceImpl(
+			certRepository,
+			keyRepository,
+			profileRepository,
+			keyService,
+			transactor,
+			NewAuditServiceImpl(repositories.NewAuditRepositoryMemory(), secretKey),
+			transparencyLog,
+			"https://pki.example.com",
+			secretKey,
+		),
+		ocspService: NewOCSPServiceImpl(
+			certRepository,
+			keyService,
+			transparencyLog,
+			transactor,
+			secretKey,
+		),
+	}
+}
+
+// createRootCA creates a root CA with a new P-256 key, as the CA controller does, without
+// publishing its CRL or issuing its OCSP responder
+func (p *certificateTestPlatform) createRootCA(t *testing.T, name string) *daos.Certificate {
+	ctx := context.Background()
+	key, err := p.keyService.CreateKey(
+		ctx,
+		certificateTestUserID,
+		name,
+		contracts.ECDSA,
+		contracts.KeyParameters{Curve: contracts.P256},
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	data, err := p.certificateService.CreateCACert(
+		ctx,
+		&contracts.CreateCARequest{
+			Name:        name,
+			Expiration:  time.Now().Add(30 * 24 * time.Hour),
+			KeyID:       key.ID,
+			KeyPassword: certificateTestKeyPassword,
+		},
+		certificateTestUserID,
+		CertTypeRootCA,
+	)
+	require.NoError(t, err)
+
+	ca, err := p.certRepository.CreateCert(
+		ctx,
+		certificateTestUserID,
+		name,
+		data,
+		CertTypeRootCA.String(),
+		"",
+		key.ID,
+	)
+	require.NoError(t, err)
+
+	return ca
+}
+
+// issueLeaf stores a certificate under ca without signing it with the CA key, which is all the
+// revocation paths need
+func (p *certificateTestPlatform) issueLeaf(
+	t *testing.T,
+	ca *daos.Certificate,
+) (*daos.Certificate, *x509.Certificate) {
+	key := testutils.Key(t, "ecdsa")
+	cert := testutils.LeafCertificate(t, "Leaf", key, nil, nil)
+
+	leaf, err := p.certRepository.CreateCert(
+		context.Background(),
+		certificateTestUserID,
+		"Leaf",
+		cert.Raw,
+		CertTypeCertificate.String(),
+		ca.ID,
+		"",
+	)
+	require.NoError(t, err)
+
+	return leaf, cert
+}
+
+// latestCRL returns the newest CRL of the CA after checking the CA signed it
+func (p *certificateTestPlatform) latestCRL(
+	t *testing.T,
+	ca *daos.Certificate,
+) (*daos.CRL, *x509.RevocationList) {
+	crl, err := p.certRepository.GetLatestCRL(context.Background(), ca.ID)
+	require.NoError(t, err)
+
+	caCert, err := x509.ParseCertificate(ca.Data)
+	require.NoError(t, err)
+
+	revocationList, err := x509.ParseRevocationList(crl.Data)
+	require.NoError(t, err)
+	require.NoError(t, revocationList.CheckSignatureFrom(caCert))
+	assert.Equal(t, crl.Number, revocationList.Number.Int64())
+
+	return crl, revocationList
+}
+
+func TestGenerateCRLForUser(t *testing.T) {
+	ctx := context.Background()
+	platform := newCertificateTestPlatform(t, "secret")
+	ca := platform.createRootCA(t, "CRL Test CA")
+
+	err := platform.certificateService.GenerateCRLForUser(
+		ctx,
+		ca.ID,
+		certificateTestUserID,
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	crl, revocationList := platform.latestCRL(t, ca)
+	assert.Equal(t, int64(1), crl.Number)
+	assert.Empty(t, revocationList.RevokedCertificateEntries)
+	assert.WithinDuration(t, time.Now().Add(crlValidity), revocationList.NextUpdate, time.Minute)
+
+	stored, err := platform.certRepository.GetCertByID(ctx, ca.ID)
+	require.NoError(t, err)
+	password, err := utils.Open("secret", stored.SealedKeyPassword)
+	require.NoError(t, err)
+	assert.Equal(t, certificateTestKeyPassword, string(password))
+	assert.Empty(t, stored.RefreshError)
+
+	leaf, leafCert := platform.issueLeaf(t, ca)
+	err = platform.certificateService.RevokeCertForUser(
+		ctx,
+		leaf.ID,
+		certificateTestUserID,
+		contracts.ReasonKeyCompromise,
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	crl, revocationList = platform.latestCRL(t, ca)
+	assert.Equal(t, int64(2), crl.Number, "revoking publishes the next CRL")
+	require.Len(t, revocationList.RevokedCertificateEntries, 1)
+	entry := revocationList.RevokedCertificateEntries[0]
+	assert.Equal(t, leafCert.SerialNumber, entry.SerialNumber)
+	assert.Equal(t, int(contracts.ReasonKeyCompromise), entry.ReasonCode)
+
+	tests := []struct {
+		name     string
+		userID   string
+		password string
+		wantErr  error
+	}{
+		{
+			name:     "other user",
+			userID:   "other",
+			password: certificateTestKeyPassword,
+			wantErr:  ErrCertUnautorized,
+		},
+		{
+			name:     "wrong password",
+			userID:   certificateTestUserID,
+			password: "wrong",
+			wantErr:  x509.IncorrectPasswordError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				err := platform.certificateService.GenerateCRLForUser(
+					ctx,
+					ca.ID,
+					test.userID,
+					test.password,
+				)
+				assert.ErrorIs(t, err, test.wantErr)
+
+				crl, _ := platform.latestCRL(t, ca)
+				assert.Equal(t, int64(2), crl.Number, "no CRL is published")
+			},
+		)
+	}
+}
+
+func TestGenerateCRLForUserWithoutSecret(t *testing.T) {
+	ctx := context.Background()
+	platform := newCertificateTestPlatform(t, "")
+	ca := platform.createRootCA(t, "CRL Test CA")
+
+	err := platform.certificateService.GenerateCRLForUser(
+		ctx,
+		ca.ID,
+		certificateTestUserID,
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err, "the CRL is published even though it can't be refreshed")
+	platform.latestCRL(t, ca)
+
+	stored, err := platform.certRepository.GetCertByID(ctx, ca.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.SealedKeyPassword)
+	assert.Contains(t, stored.RefreshError, "unable to seal CA key password")
+}
+
+func TestRefreshDueCRLs(t *testing.T) {
+	ctx := context.Background()
+	platform := newCertificateTestPlatform(t, "secret")
+
+	due := platform.createRootCA(t, "Due CA")
+	notDue := platform.createRootCA(t, "Not Due CA")
+	for _, ca := range []*daos.Certificate{due, notDue} {
+		err := platform.certificateService.GenerateCRLForUser(
+			ctx,
+			ca.ID,
+			certificateTestUserID,
+			certificateTestKeyPassword,
+		)
+		require.NoError(t, err)
+	}
+
+	// Stand in for the CRL having aged into the refresh window
+	latest, _ := platform.latestCRL(t, due)
+	_, err := platform.certRepository.CreateCRL(
+		ctx,
+		due.ID,
+		latest.Number+1,
+		latest.Data,
+		time.Now().Add(-crlValidity),
+		time.Now().Add(time.Hour),
+	)
+	require.NoError(t, err)
+	require.NoError(t, platform.certRepository.SetCertRefreshError(ctx, due.ID, "earlier failure"))
+
+	refreshed, err := platform.certificateService.RefreshDueCRLs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, refreshed)
+
+	crl, revocationList := platform.latestCRL(t, due)
+	assert.Equal(t, latest.Number+2, crl.Number)
+	assert.WithinDuration(t, time.Now().Add(crlValidity), revocationList.NextUpdate, time.Minute)
+
+	stored, err := platform.certRepository.GetCertByID(ctx, due.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.RefreshError, "a successful refresh clears the failure")
+
+	crl, _ = platform.latestCRL(t, notDue)
+	assert.Equal(t, int64(1), crl.Number)
+
+	refreshed, err = platform.certificateService.RefreshDueCRLs(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, refreshed, "the fresh CRL is no longer due")
+}
+
+func TestRefreshDueCRLsFailure(t *testing.T) {
+	ctx := context.Background()
+	platform := newCertificateTestPlatform(t, "secret")
+	ca := platform.createRootCA(t, "CRL Test CA")
+
+	err := platform.certificateService.GenerateCRLForUser(
+		ctx,
+		ca.ID,
+		certificateTestUserID,
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	latest, _ := platform.latestCRL(t, ca)
+	_, err = platform.certRepository.CreateCRL(
+		ctx,
+		ca.ID,
+		latest.Number+1,
+		latest.Data,
+		time.Now().Add(-crlValidity),
+		time.Now().Add(time.Hour),
+	)
+	require.NoError(t, err)
+
+	// A server restarted with another secret can't open the sealed password
+	platform.certificateService.secretKey = "rotated"
+
+	refreshed, err := platform.certificateService.RefreshDueCRLs(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, refreshed)
+
+	stored, err := platform.certRepository.GetCertByID(ctx, ca.ID)
+	require.NoError(t, err)
+	assert.Contains(t, stored.RefreshError, "unable to refresh CRL")
+
+	crl, err := platform.certRepository.GetLatestCRL(ctx, ca.ID)
+	require.NoError(t, err)
+	assert.Equal(t, latest.Number+1, crl.Number, "no CRL is published")
+}
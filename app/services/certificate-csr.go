@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+var (
+	oidExtensionKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// csrKeyUsageNames maps key usage bit positions to the names accepted by keyUsages. certSign
+// and crlSign are left out since CSRs are only ever signed as leaf certificates.
+var csrKeyUsageNames = []string{
+	"digitalSignature",
+	"contentCommitment",
+	"keyEncipherment",
+	"dataEncipherment",
+	"keyAgreement",
+	"",
+	"",
+	"encipherOnly",
+	"decipherOnly",
+}
+
+var csrExtKeyUsageNames = map[string]string{
+	"1.3.6.1.5.5.7.3.1": "serverAuth",
+	"1.3.6.1.5.5.7.3.2": "clientAuth",
+	"1.3.6.1.5.5.7.3.3": "codeSigning",
+	"1.3.6.1.5.5.7.3.4": "emailProtection",
+	"1.3.6.1.5.5.7.3.5": "ipsec_end_system",
+	"1.3.6.1.5.5.7.3.6": "ipsec_tunnel",
+	"1.3.6.1.5.5.7.3.7": "ipsec_user",
+	"1.3.6.1.5.5.7.3.8": "timeStamping",
+	"1.3.6.1.5.5.7.3.9": "ocspSigning",
+}
+
+func (c *CertificateServiceImpl) SignCSR(
+	ctx context.Context,
+	caID string,
+	userID string,
+	request *contracts.SignCSRRequest,
+) (resp *contracts.CertificateLightResponse, err error) {
+	defer func() {
+		c.auditService.Record(
+			ctx, userID, AuditCertificateSignCSR, certificateLightResponseID(resp), err,
+		)
+	}()
+
+	csr, err := ParseCSR(request.CSR)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &IssueCertParams{
+		Name:                    request.Name,
+		PublicKey:               csr.PublicKey,
+		Subject:                 &csr.Subject,
+		CommonName:              csr.Subject.CommonName,
+		SubjectAlternativeNames: csr.DNSNames,
+		IPAddresses:             csr.IPAddresses,
+		EmailAddresses:          csr.EmailAddresses,
+		URIs:                    csr.URIs,
+		KeyUsages:               request.KeyUsages,
+		NotAfter:                request.Expiration,
+		ProfileID:               request.ProfileID,
+	}
+
+	if request.CommonName != "" {
+		params.CommonName = request.CommonName
+	}
+
+	if len(request.SubjectAlternativeNames) > 0 {
+		params.SubjectAlternativeNames = nil
+		params.IPAddresses = nil
+		params.EmailAddresses = nil
+		params.URIs = nil
+
+		err = sortSubjectAlternativeNames(params, request.SubjectAlternativeNames)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(params.KeyUsages) == 0 {
+		params.KeyUsages, err = csrRequestedKeyUsages(csr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params.Name == "" {
+		params.Name = params.CommonName
+	}
+
+	return c.issueCert(ctx, caID, userID, request.CAKeyPassword, params)
+}
+
+// sortSubjectAlternativeNames files each name under the SAN type its form matches: IP address,
+// email address, URI with a host, URN or DNS name, which may be a wildcard
+func sortSubjectAlternativeNames(params *IssueCertParams, names []string) error {
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			params.IPAddresses = append(params.IPAddresses, ip)
+			continue
+		}
+
+		if strings.Contains(name, "@") {
+			address, err := mail.ParseAddress(name)
+			if err != nil || address.Address != name || address.Name != "" {
+				return fmt.Errorf("%w: %q is not a valid email address", ErrInvalidCSR, name)
+			}
+
+			params.EmailAddresses = append(params.EmailAddresses, name)
+			continue
+		}
+
+		if strings.Contains(name, ":") {
+			uri, err := url.Parse(name)
+			if err != nil || !uri.IsAbs() || (uri.Host == "" && !strings.EqualFold(uri.Scheme, "urn")) {
+				return fmt.Errorf("%w: %q is not a valid URI", ErrInvalidCSR, name)
+			}
+
+			params.URIs = append(params.URIs, uri)
+			continue
+		}
+
+		if !validHostname(strings.TrimPrefix(strings.ToLower(name), "*.")) {
+			return fmt.Errorf(
+				"%w: %q is not an IP address, email address, URI or DNS name",
+				ErrInvalidCSR,
+				name,
+			)
+		}
+
+		params.SubjectAlternativeNames = append(params.SubjectAlternativeNames, name)
+	}
+
+	return nil
+}
+
+// ParseCSR decodes a PEM or base64 DER encoded PKCS#10 request and verifies its signature
+func ParseCSR(encoded string) (*x509.CertificateRequest, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	var der []byte
+	if strings.HasPrefix(encoded, "-----BEGIN") {
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil ||
+			(block.Type != "CERTIFICATE REQUEST" && block.Type != "NEW CERTIFICATE REQUEST") {
+			return nil, fmt.Errorf("%w: no certificate request PEM block found", ErrInvalidCSR)
+		}
+		der = block.Bytes
+	} else {
+		var err error
+		der, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidCSR, err)
+		}
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCSR, err)
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCSR, err)
+	}
+
+	return csr, nil
+}
+
+// csrRequestedKeyUsages reads the key usage and extended key usage extensions requested in a CSR
+func csrRequestedKeyUsages(csr *x509.CertificateRequest) ([]string, error) {
+	keyUsages := make([]string, 0)
+
+	for _, ext := range csr.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionKeyUsage):
+			var bits asn1.BitString
+			_, err := asn1.Unmarshal(ext.Value, &bits)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid key usage extension", ErrInvalidCSR)
+			}
+
+			for i, name := range csrKeyUsageNames {
+				if name != "" && bits.At(i) == 1 {
+					keyUsages = append(keyUsages, name)
+				}
+			}
+		case ext.Id.Equal(oidExtensionExtKeyUsage):
+			var oids []asn1.ObjectIdentifier
+			_, err := asn1.Unmarshal(ext.Value, &oids)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid extended key usage extension", ErrInvalidCSR)
+			}
+
+			for _, oid := range oids {
+				name, ok := csrExtKeyUsageNames[oid.String()]
+				if ok {
+					keyUsages = append(keyUsages, name)
+				}
+			}
+		}
+	}
+
+	return keyUsages, nil
+}
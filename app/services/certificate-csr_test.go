@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortSubjectAlternativeNames(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expectedDNS   []string
+		expectedIP    []net.IP
+		expectedEmail []string
+		expectedURI   []string
+		wantErr       bool
+	}{
+		{name: "dns", value: "www.example.com", expectedDNS: []string{"www.example.com"}},
+		{name: "single label", value: "localhost", expectedDNS: []string{"localhost"}},
+		{name: "wildcard", value: "*.Example.com", expectedDNS: []string{"*.Example.com"}},
+		{name: "ipv4", value: "10.0.0.1", expectedIP: []net.IP{net.ParseIP("10.0.0.1")}},
+		{name: "ipv6", value: "::1", expectedIP: []net.IP{net.ParseIP("::1")}},
+		{
+			name:          "email",
+			value:         "admin@example.com",
+			expectedEmail: []string{"admin@example.com"},
+		},
+		{
+			name:        "uri",
+			value:       "spiffe://example.com/service",
+			expectedURI: []string{"spiffe://example.com/service"},
+		},
+		{name: "urn", value: "urn:uuid:6e8bc430", expectedURI: []string{"urn:uuid:6e8bc430"}},
+		{name: "email with a display name", value: "Admin <admin@example.com>", wantErr: true},
+		{name: "invalid email", value: "admin@", wantErr: true},
+		{name: "relative uri", value: "example.com:443", wantErr: true},
+		{name: "uri without host", value: "https://", wantErr: true},
+		{name: "space", value: "www example.com", wantErr: true},
+		{name: "empty label", value: "www..example.com", wantErr: true},
+		{name: "inner wildcard", value: "www.*.example.com", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				params := &IssueCertParams{}
+				err := sortSubjectAlternativeNames(params, []string{test.value})
+				if test.wantErr {
+					assert.ErrorIs(t, err, ErrInvalidCSR)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedDNS, params.SubjectAlternativeNames)
+				assert.Equal(t, test.expectedIP, params.IPAddresses)
+				assert.Equal(t, test.expectedEmail, params.EmailAddresses)
+
+				var uris []string
+				for _, uri := range params.URIs {
+					uris = append(uris, uri.String())
+				}
+				assert.Equal(t, test.expectedURI, uris)
+			},
+		)
+	}
+}
+
+func TestSignCSRSubjectAlternativeNames(t *testing.T) {
+	ctx := context.Background()
+	platform := newCertificateTestPlatform(t, "secret")
+	ca := platform.createRootCA(t, "CSR Test CA")
+
+	der, err := x509.CreateCertificateRequest(
+		rand.Reader,
+		&x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: "csr.example.com"},
+			DNSNames: []string{"csr.example.com"},
+		},
+		testutils.Key(t, "ecdsa"),
+	)
+	require.NoError(t, err)
+	csr := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+
+	request := &contracts.SignCSRRequest{
+		CSR:        csr,
+		KeyUsages:  []string{"digitalSignature"},
+		Expiration: time.Now().Add(24 * time.Hour),
+		SubjectAlternativeNames: []string{
+			"www.example.com",
+			"10.0.0.1",
+			"admin@example.com",
+			"spiffe://example.com/service",
+		},
+		CAKeyPassword: certificateTestKeyPassword,
+	}
+
+	resp, err := platform.certificateService.SignCSR(ctx, ca.ID, certificateTestUserID, request)
+	require.NoError(t, err)
+
+	certDao, err := platform.certRepository.GetCertByID(ctx, resp.ID)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certDao.Data)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"www.example.com", "csr.example.com"}, cert.DNSNames, "and the CN")
+	require.Len(t, cert.IPAddresses, 1)
+	assert.True(t, net.ParseIP("10.0.0.1").Equal(cert.IPAddresses[0]))
+	assert.Equal(t, []string{"admin@example.com"}, cert.EmailAddresses)
+	require.Len(t, cert.URIs, 1)
+	assert.Equal(t, "spiffe://example.com/service", cert.URIs[0].String())
+
+	request.SubjectAlternativeNames = []string{"www.example.com", "not a name"}
+	_, err = platform.certificateService.SignCSR(ctx, ca.ID, certificateTestUserID, request)
+	assert.ErrorIs(t, err, ErrInvalidCSR)
+}
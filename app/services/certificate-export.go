@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/smallstep/pkcs7"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type CertificateFormat string
+
+const (
+	CertFormatPEM          CertificateFormat = "pem"
+	CertFormatDER          CertificateFormat = "der"
+	CertFormatChainPEM     CertificateFormat = "chain-pem"
+	CertFormatFullChainPEM CertificateFormat = "fullchain-pem"
+	CertFormatPKCS7        CertificateFormat = "p7b"
+	CertFormatPKCS12       CertificateFormat = "p12"
+)
+
+// maxChainLength guards against ParentCertificate loops when walking up to the root
+const maxChainLength = 10
+
+var ErrUnknownCertFormat = errors.New("unknown certificate format")
+var ErrCertHasNoKey = errors.New("certificate has no platform managed key")
+
+// CertificateExport is an encoded certificate along with how it should be served
+type CertificateExport struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+func (c *CertificateServiceImpl) ExportCertForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+	format CertificateFormat,
+	keyPassword string,
+) (export *CertificateExport, err error) {
+	defer func() {
+		c.auditService.Record(ctx, userID, AuditCertificateDownload, id, err)
+	}()
+
+	cert, err := c.certRepository.GetCertByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	switch format {
+	case CertFormatPEM, "":
+		return &CertificateExport{
+			Data:        pemCertificates([]*daos.Certificate{cert}),
+			ContentType: "application/x-pem-file",
+			Extension:   ".pem",
+		}, nil
+	case CertFormatDER:
+		return &CertificateExport{
+			Data:        cert.Data,
+			ContentType: "application/pkix-cert",
+			Extension:   ".der",
+		}, nil
+	case CertFormatChainPEM, CertFormatFullChainPEM:
+		chain, err := c.certChain(ctx, cert, format == CertFormatFullChainPEM)
+		if err != nil {
+			return nil, err
+		}
+
+		return &CertificateExport{
+			Data:        pemCertificates(chain),
+			ContentType: "application/pem-certificate-chain",
+			Extension:   ".pem",
+		}, nil
+	case CertFormatPKCS7:
+		chain, err := c.certChain(ctx, cert, true)
+		if err != nil {
+			return nil, err
+		}
+
+		var der []byte
+		for _, chainCert := range chain {
+			der = append(der, chainCert.Data...)
+		}
+
+		data, err := pkcs7.DegenerateCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+
+		return &CertificateExport{
+			Data:        data,
+			ContentType: "application/x-pkcs7-certificates",
+			Extension:   ".p7b",
+		}, nil
+	case CertFormatPKCS12:
+		data, err := c.pkcs12(ctx, cert, userID, keyPassword)
+		if err != nil {
+			return nil, err
+		}
+
+		return &CertificateExport{
+			Data:        data,
+			ContentType: "application/x-pkcs12",
+			Extension:   ".p12",
+		}, nil
+	default:
+		return nil, ErrUnknownCertFormat
+	}
+}
+
+// certChain walks ParentCertificate from cert upwards. The root is only included when
+// includeRoot is set, a root certificate on its own is always returned.
+func (c *CertificateServiceImpl) certChain(
+	ctx context.Context,
+	cert *daos.Certificate,
+	includeRoot bool,
+) ([]*daos.Certificate, error) {
+	return certChain(ctx, c.certRepository, cert, includeRoot)
+}
+
+func certChain(
+	ctx context.Context,
+	certRepository repositories.CertRepository,
+	cert *daos.Certificate,
+	includeRoot bool,
+) ([]*daos.Certificate, error) {
+	chain := []*daos.Certificate{cert}
+
+	current := cert
+	for current.ParentCertificate != "" {
+		if len(chain) >= maxChainLength {
+			return nil, errors.New("certificate chain is too long")
+		}
+
+		parent, err := certRepository.GetCertByID(ctx, current.ParentCertificate)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent.ParentCertificate == "" && !includeRoot {
+			break
+		}
+
+		chain = append(chain, parent)
+		current = parent
+	}
+
+	return chain, nil
+}
+
+// pkcs12 bundles the decrypted private key with the full chain, protected by the key's password
+func (c *CertificateServiceImpl) pkcs12(
+	ctx context.Context,
+	cert *daos.Certificate,
+	userID string,
+	keyPassword string,
+) ([]byte, error) {
+	if cert.KeyID == "" {
+		return nil, ErrCertHasNoKey
+	}
+
+	key, err := c.keyService.GetDecryptedKeyForUser(ctx, cert.KeyID, userID, keyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := c.certChain(ctx, cert, true)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]*x509.Certificate, len(chain))
+	for i, chainCert := range chain {
+		certs[i], err = x509.ParseCertificate(chainCert.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pkcs12.Modern.Encode(key, certs[0], certs[1:], keyPassword)
+}
+
+func pemCertificates(certs []*daos.Certificate) []byte {
+	var data []byte
+	for _, cert := range certs {
+		data = append(
+			data, pem.EncodeToMemory(
+				&pem.Block{
+					Type:  "CERTIFICATE",
+					Bytes: cert.Data,
+				},
+			)...,
+		)
+	}
+
+	return data
+}
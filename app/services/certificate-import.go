@@ -0,0 +1,398 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var ErrInvalidCertData = errors.New("invalid certificate data")
+var ErrKeyMismatch = errors.New("private key does not match any imported certificate")
+var ErrCertSerialConflict = errors.New("a different certificate with this issuer and serial exists")
+
+// ImportCertificates stores externally created certificates. Each certificate is linked to its
+// issuer when that issuer is part of the import or one of the user's CAs, and the private key
+// from a PKCS#12 bundle or request.KeyID is linked to the certificate it belongs to.
+// Certificates already stored for the user are reused rather than duplicated.
+func (c *CertificateServiceImpl) ImportCertificates(
+	ctx context.Context,
+	userID string,
+	request *contracts.ImportCertificatesRequest,
+) (*contracts.ImportCertificatesResponse, error) {
+	var response *contracts.ImportCertificatesResponse
+	err := c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			var err error
+			response, err = c.importCertificates(ctx, userID, request)
+			return err
+		},
+	)
+	if err != nil {
+		c.auditService.Record(ctx, userID, AuditCertificateImport, "", err)
+		return nil, err
+	}
+
+	for _, cert := range response.Certificates {
+		c.auditService.Record(ctx, userID, AuditCertificateImport, cert.ID, nil)
+	}
+
+	return response, nil
+}
+
+func (c *CertificateServiceImpl) importCertificates(
+	ctx context.Context,
+	userID string,
+	request *contracts.ImportCertificatesRequest,
+) (*contracts.ImportCertificatesResponse, error) {
+	certs, key, err := parseCertificateImport(request.Data, request.SourcePassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if key != nil && request.KeyID != "" {
+		return nil, fmt.Errorf("%w: bundle already contains a private key", ErrInvalidCertData)
+	}
+
+	certs, err = orderCertificates(certs)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyID string
+	var keyCert *x509.Certificate
+	if request.KeyID != "" {
+		existingKey, err := c.keyService.GetDecryptedKeyForUser(
+			ctx,
+			request.KeyID,
+			userID,
+			request.KeyPassword,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		key = existingKey
+		keyID = request.KeyID
+	}
+
+	if key != nil {
+		keyCert = certificateForKey(certs, key)
+		if keyCert == nil {
+			return nil, ErrKeyMismatch
+		}
+	}
+
+	issuers, err := c.userIssuers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &contracts.ImportCertificatesResponse{
+		Certificates: make([]*contracts.CertificateLightResponse, 0, len(certs)),
+	}
+
+	if keyID == "" && key != nil {
+		keyName := request.KeyName
+		if keyName == "" {
+			keyName = certificateName(keyCert, request.Name)
+		}
+
+		response.Key, err = c.keyService.ImportPrivateKey(
+			ctx,
+			userID,
+			keyName,
+			key,
+			request.KeyPassword,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		keyID = response.Key.ID
+	}
+
+	primary := keyCert
+	if primary == nil {
+		primary = certs[len(certs)-1]
+	}
+
+	for _, cert := range certs {
+		var parentID string
+		if !isSelfSigned(cert) {
+			for _, issuer := range issuers {
+				if isIssuedBy(cert, issuer.cert) {
+					parentID = issuer.dao.ID
+					break
+				}
+			}
+		}
+
+		certKeyID := ""
+		if cert == keyCert {
+			certKeyID = keyID
+		}
+
+		dao, err := c.findImportedCert(ctx, parentID, cert, issuers)
+		switch {
+		case err == nil:
+			if dao.UserID != userID || !bytes.Equal(dao.Data, cert.Raw) {
+				return nil, ErrCertSerialConflict
+			}
+
+			if certKeyID != "" && dao.KeyID == "" {
+				err = c.certRepository.SetCertKeyID(ctx, dao.ID, certKeyID)
+				if err != nil {
+					return nil, err
+				}
+			}
+		case errors.Is(err, repositories.ErrNoRecord):
+			name := certificateName(cert, "")
+			if cert == primary && request.Name != "" {
+				name = request.Name
+			}
+
+			dao, err = c.certRepository.CreateCert(
+				ctx,
+				userID,
+				name,
+				cert.Raw,
+				importedCertType(cert).String(),
+				parentID,
+				certKeyID,
+			)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, err
+		}
+
+		if cert.IsCA {
+			issuers = append(issuers, &certificateIssuer{dao: dao, cert: cert})
+		}
+
+		if cert == keyCert {
+			response.KeyCertificateID = dao.ID
+		}
+
+		response.Certificates = append(response.Certificates, dao.ToLightResponse())
+	}
+
+	return response, nil
+}
+
+// findImportedCert looks for cert amongst those already stored. Serial numbers are only recorded
+// for certificates with an issuer, so parentless CAs are matched against the user's own CAs.
+func (c *CertificateServiceImpl) findImportedCert(
+	ctx context.Context,
+	parentID string,
+	cert *x509.Certificate,
+	issuers []*certificateIssuer,
+) (*daos.Certificate, error) {
+	if parentID != "" {
+		return c.certRepository.GetCertByIssuerAndSerial(ctx, parentID, cert.SerialNumber)
+	}
+
+	for _, issuer := range issuers {
+		if bytes.Equal(issuer.cert.Raw, cert.Raw) {
+			return issuer.dao, nil
+		}
+	}
+
+	return nil, repositories.ErrNoRecord
+}
+
+type certificateIssuer struct {
+	dao  *daos.Certificate
+	cert *x509.Certificate
+}
+
+// userIssuers loads the user's CAs so imported certificates can be linked to them
+func (c *CertificateServiceImpl) userIssuers(
+	ctx context.Context,
+	userID string,
+) ([]*certificateIssuer, error) {
+	cas, err := c.certRepository.GetCertsByUserID(
+		ctx,
+		userID,
+		[]string{CertTypeRootCA.String(), CertTypeIntermediateCA.String()},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := make([]*certificateIssuer, 0, len(cas))
+	for _, ca := range cas {
+		cert, err := x509.ParseCertificate(ca.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		issuers = append(issuers, &certificateIssuer{dao: ca, cert: cert})
+	}
+
+	return issuers, nil
+}
+
+// parseCertificateImport accepts PEM certificates, or base64 encoded DER certificates or PKCS#12
+func parseCertificateImport(
+	data string,
+	password string,
+) ([]*x509.Certificate, crypto.PrivateKey, error) {
+	if strings.Contains(data, "-----BEGIN") {
+		certs := make([]*x509.Certificate, 0)
+		rest := []byte(data)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+
+			if block.Type != "CERTIFICATE" {
+				return nil, nil, fmt.Errorf(
+					"%w: unexpected PEM block %s",
+					ErrInvalidCertData,
+					block.Type,
+				)
+			}
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %s", ErrInvalidCertData, err.Error())
+			}
+
+			certs = append(certs, cert)
+		}
+
+		if len(certs) == 0 {
+			return nil, nil, fmt.Errorf("%w: no certificates found", ErrInvalidCertData)
+		}
+
+		return certs, nil, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: data is neither PEM nor base64", ErrInvalidCertData)
+	}
+
+	certs, err := x509.ParseCertificates(der)
+	if err == nil && len(certs) > 0 {
+		return certs, nil, nil
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(der, password)
+	if err != nil {
+		// Bundles holding only certificates have no key bag
+		certs, trustErr := pkcs12.DecodeTrustStore(der, password)
+		if trustErr == nil && len(certs) > 0 {
+			return certs, nil, nil
+		}
+
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, nil, x509.IncorrectPasswordError
+		}
+
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidCertData, err.Error())
+	}
+
+	return append([]*x509.Certificate{cert}, caCerts...), key, nil
+}
+
+// orderCertificates sorts certs so every certificate comes after its issuer
+func orderCertificates(certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	ordered := make([]*x509.Certificate, 0, len(certs))
+	placed := make(map[*x509.Certificate]bool, len(certs))
+
+	for len(ordered) < len(certs) {
+		progress := false
+		for _, cert := range certs {
+			if placed[cert] {
+				continue
+			}
+
+			ready := true
+			for _, issuer := range certs {
+				if issuer != cert && !placed[issuer] && !isSelfSigned(cert) &&
+					isIssuedBy(cert, issuer) {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				ordered = append(ordered, cert)
+				placed[cert] = true
+				progress = true
+			}
+		}
+
+		if !progress {
+			return nil, fmt.Errorf("%w: certificates form an issuer loop", ErrInvalidCertData)
+		}
+	}
+
+	return ordered, nil
+}
+
+func certificateForKey(certs []*x509.Certificate, key crypto.PrivateKey) *x509.Certificate {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil
+	}
+
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok {
+		return nil
+	}
+
+	for _, cert := range certs {
+		if public.Equal(cert.PublicKey) {
+			return cert
+		}
+	}
+
+	return nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return isIssuedBy(cert, cert)
+}
+
+func isIssuedBy(cert *x509.Certificate, issuer *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, issuer.RawSubject) && cert.CheckSignatureFrom(issuer) == nil
+}
+
+func importedCertType(cert *x509.Certificate) CertificateType {
+	switch {
+	case cert.IsCA && isSelfSigned(cert):
+		return CertTypeRootCA
+	case cert.IsCA:
+		return CertTypeIntermediateCA
+	default:
+		return CertTypeCertificate
+	}
+}
+
+func certificateName(cert *x509.Certificate, fallback string) string {
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case fallback != "":
+		return fallback
+	default:
+		return cert.Subject.String()
+	}
+}
@@ -2,7 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto"
+	"crypto/x509/pkix"
 	"errors"
+	"net"
+	"net/url"
+	"time"
 
 	"github.com/fapiko/john-hancock-platform/app/contracts"
 )
@@ -13,18 +18,55 @@ const (
 	CertTypeRootCA         CertificateType = "root_ca"
 	CertTypeIntermediateCA CertificateType = "intermediate_ca"
 	CertTypeCertificate    CertificateType = "certificate"
+	CertTypeOCSPResponder  CertificateType = "ocsp_responder"
 )
 
 var ErrCertUnautorized = errors.New("user does not have access to this certificate")
+var ErrCertAlreadyRevoked = errors.New("certificate has already been revoked")
+var ErrInvalidCSR = errors.New("invalid certificate signing request")
+var ErrCertNotRenewable = errors.New("certificate issuer is not on the platform")
 
 func (ct CertificateType) String() string {
 	return string(ct)
 }
 
+// IssueCertParams describes a leaf certificate independently of where its private key lives.
+// KeyID is empty when the key is not managed by the platform. Subject is optional and has its
+// common name replaced by CommonName. When ProfileID is set the profile fills in anything left
+// empty and the request is rejected if it falls outside the profile.
+type IssueCertParams struct {
+	Name                    string
+	PublicKey               crypto.PublicKey
+	KeyID                   string
+	Subject                 *pkix.Name
+	CommonName              string
+	SubjectAlternativeNames []string
+	IPAddresses             []net.IP
+	EmailAddresses          []string
+	URIs                    []*url.URL
+	KeyUsages               []string
+	NotAfter                time.Time
+	ProfileID               string
+}
+
 type CertificateService interface {
 	DeleteCertForUser(ctx context.Context, id string, userID string) error
 	GetCert(ctx context.Context, id string) (*contracts.CertificateResponse, error)
+	GetCertBySerialForUser(
+		ctx context.Context,
+		caID string,
+		serialNumber string,
+		userID string,
+	) (*contracts.CertificateResponse, error)
 	GetCertAsPEMForUser(ctx context.Context, id string, userID string) (string, error)
+	// ExportCertForUser encodes a certificate, keyPassword is only used by CertFormatPKCS12
+	ExportCertForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+		format CertificateFormat,
+		keyPassword string,
+	) (*CertificateExport, error)
 	GetUserCerts(
 		ctx context.Context,
 		userId string,
@@ -45,9 +87,57 @@ type CertificateService interface {
 		request *contracts.CreateCertificateRequest,
 		userID string,
 	) (*contracts.CertificateLightResponse, error)
+	IssueCert(
+		ctx context.Context,
+		caID string,
+		userID string,
+		caKeyPassword string,
+		params *IssueCertParams,
+	) (*contracts.CertificateLightResponse, error)
+	SignCSR(
+		ctx context.Context,
+		caID string,
+		userID string,
+		request *contracts.SignCSRRequest,
+	) (*contracts.CertificateLightResponse, error)
+	// RenewCertForUser issues a successor to a certificate with the same key and a new
+	// validity window and serial number
+	RenewCertForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+		request *contracts.RenewCertificateRequest,
+	) (*contracts.CertificateLightResponse, error)
+	// RekeyCertForUser issues a successor to a certificate with another key
+	RekeyCertForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+		request *contracts.RekeyCertificateRequest,
+	) (*contracts.CertificateLightResponse, error)
+	ImportCertificates(
+		ctx context.Context,
+		userID string,
+		request *contracts.ImportCertificatesRequest,
+	) (*contracts.ImportCertificatesResponse, error)
 	GetCertsByParentCAForUser(
 		ctx context.Context,
 		parentCA string,
 		userID string,
 	) ([]*contracts.CertificateLightResponse, error)
+	RevokeCertForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+		reason contracts.RevocationReason,
+		caKeyPassword string,
+	) error
+	GenerateCRLForUser(
+		ctx context.Context,
+		caID string,
+		userID string,
+		caKeyPassword string,
+	) error
+	GetCRL(ctx context.Context, caID string) ([]byte, error)
+	RefreshDueCRLs(ctx context.Context) (int, error)
 }
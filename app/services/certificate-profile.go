@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+var ErrProfileUnauthorized = errors.New("user does not have access to this certificate profile")
+var ErrInvalidProfile = errors.New("invalid certificate profile")
+var ErrProfileViolation = errors.New("request is not allowed by the certificate profile")
+
+// ProfileUnlimitedPathLen as a profile's MaxPathLen leaves the path length of its CAs unlimited
+const ProfileUnlimitedPathLen = -1
+
+var _ CertificateProfileService = (*CertificateProfileServiceImpl)(nil)
+
+type CertificateProfileService interface {
+	CreateProfileForUser(
+		ctx context.Context,
+		userID string,
+		request *contracts.CertificateProfileRequest,
+	) (*contracts.CertificateProfileResponse, error)
+	GetProfileForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+	) (*contracts.CertificateProfileResponse, error)
+	GetProfilesForUser(
+		ctx context.Context,
+		userID string,
+	) ([]*contracts.CertificateProfileResponse, error)
+	UpdateProfileForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+		request *contracts.CertificateProfileRequest,
+	) (*contracts.CertificateProfileResponse, error)
+	DeleteProfileForUser(ctx context.Context, id string, userID string) error
+}
+
+type CertificateProfileServiceImpl struct {
+	profileRepository repositories.CertificateProfileRepository
+}
+
+func NewCertificateProfileServiceImpl(
+	profileRepository repositories.CertificateProfileRepository,
+) *CertificateProfileServiceImpl {
+	return &CertificateProfileServiceImpl{
+		profileRepository: profileRepository,
+	}
+}
+
+func (p *CertificateProfileServiceImpl) CreateProfileForUser(
+	ctx context.Context,
+	userID string,
+	request *contracts.CertificateProfileRequest,
+) (*contracts.CertificateProfileResponse, error) {
+	err := validateProfile(request)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := profileFromRequest(request)
+	profile.UserID = userID
+
+	err = p.profileRepository.CreateProfile(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.ToResponse(), nil
+}
+
+func (p *CertificateProfileServiceImpl) GetProfileForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) (*contracts.CertificateProfileResponse, error) {
+	profile, err := getProfileForUser(ctx, p.profileRepository, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.ToResponse(), nil
+}
+
+func (p *CertificateProfileServiceImpl) GetProfilesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*contracts.CertificateProfileResponse, error) {
+	profiles, err := p.profileRepository.GetProfilesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*contracts.CertificateProfileResponse, len(profiles))
+	for i, profile := range profiles {
+		response[i] = profile.ToResponse()
+	}
+
+	return response, nil
+}
+
+func (p *CertificateProfileServiceImpl) UpdateProfileForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+	request *contracts.CertificateProfileRequest,
+) (*contracts.CertificateProfileResponse, error) {
+	existing, err := getProfileForUser(ctx, p.profileRepository, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateProfile(request)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := profileFromRequest(request)
+	profile.ID = existing.ID
+	profile.UserID = existing.UserID
+	profile.Created = existing.Created
+
+	err = p.profileRepository.UpdateProfile(ctx, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile.ToResponse(), nil
+}
+
+func (p *CertificateProfileServiceImpl) DeleteProfileForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) error {
+	_, err := getProfileForUser(ctx, p.profileRepository, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return p.profileRepository.DeleteProfile(ctx, id)
+}
+
+func getProfileForUser(
+	ctx context.Context,
+	profileRepository repositories.CertificateProfileRepository,
+	id string,
+	userID string,
+) (*daos.CertificateProfile, error) {
+	profile, err := profileRepository.GetProfile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.UserID != userID {
+		return nil, ErrProfileUnauthorized
+	}
+
+	return profile, nil
+}
+
+func validateProfile(request *contracts.CertificateProfileRequest) error {
+	if request.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProfile)
+	}
+
+	for _, usage := range request.KeyUsages {
+		keyUsage, _, err := parseKeyUsages([]string{usage})
+		if err != nil || keyUsage == 0 {
+			return fmt.Errorf("%w: %q is not a key usage", ErrInvalidProfile, usage)
+		}
+	}
+
+	for _, usage := range request.ExtKeyUsages {
+		_, extKeyUsage, err := parseKeyUsages([]string{usage})
+		if err != nil || len(extKeyUsage) == 0 {
+			return fmt.Errorf("%w: %q is not an extended key usage", ErrInvalidProfile, usage)
+		}
+	}
+
+	for _, algorithm := range request.AllowedKeyAlgorithms {
+		_, err := contracts.AlgorithmFromString(algorithm)
+		if err != nil {
+			return fmt.Errorf("%w: unknown key algorithm %q", ErrInvalidProfile, algorithm)
+		}
+	}
+
+	if request.MaxValidityDays < 0 {
+		return fmt.Errorf("%w: validity must not be negative", ErrInvalidProfile)
+	}
+
+	if request.MaxPathLen < ProfileUnlimitedPathLen {
+		return fmt.Errorf(
+			"%w: path length must not be negative, or %d for unlimited",
+			ErrInvalidProfile,
+			ProfileUnlimitedPathLen,
+		)
+	}
+
+	return nil
+}
+
+func profileFromRequest(request *contracts.CertificateProfileRequest) *daos.CertificateProfile {
+	return &daos.CertificateProfile{
+		Name:                 request.Name,
+		KeyUsages:            request.KeyUsages,
+		ExtKeyUsages:         request.ExtKeyUsages,
+		MaxValidityDays:      request.MaxValidityDays,
+		AllowedKeyAlgorithms: request.AllowedKeyAlgorithms,
+		IsCA:                 request.IsCA,
+		MaxPathLen:           request.MaxPathLen,
+		Organization:         request.Subject.Organization,
+		OrganizationalUnit:   request.Subject.OrganizationalUnit,
+		Country:              request.Subject.Country,
+		Province:             request.Subject.Province,
+		Locality:             request.Subject.Locality,
+		StreetAddress:        request.Subject.StreetAddress,
+		PostalCode:           request.Subject.PostalCode,
+	}
+}
+
+// defaultCAProfile is applied to CA certificates created without a profile. A root CA may sign
+// one level of intermediates, which may only sign leaves.
+func defaultCAProfile(certificateType CertificateType) *daos.CertificateProfile {
+	maxPathLen := 0
+	if certificateType == CertTypeRootCA {
+		maxPathLen = 1
+	}
+
+	return &daos.CertificateProfile{
+		Name:         "Default CA",
+		ExtKeyUsages: []string{"any"},
+		IsCA:         true,
+		MaxPathLen:   maxPathLen,
+	}
+}
+
+// profileMaxPathLen sets the path length constraint of a CA certificate from the profile
+func profileMaxPathLen(profile *daos.CertificateProfile, cert *x509.Certificate) {
+	if profile.MaxPathLen == ProfileUnlimitedPathLen {
+		cert.MaxPathLen = -1
+		cert.MaxPathLenZero = false
+		return
+	}
+
+	cert.MaxPathLen = profile.MaxPathLen
+	cert.MaxPathLenZero = profile.MaxPathLen == 0
+}
+
+// profileKeyUsages returns the profile's usages when none were requested, otherwise checks that
+// every requested usage is permitted by the profile
+func profileKeyUsages(profile *daos.CertificateProfile, requested []string) ([]string, error) {
+	allowed := append(append([]string{}, profile.KeyUsages...), profile.ExtKeyUsages...)
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	for _, usage := range requested {
+		if !containsString(allowed, usage) {
+			return nil, fmt.Errorf("%w: key usage %q", ErrProfileViolation, usage)
+		}
+	}
+
+	return requested, nil
+}
+
+// profileNotAfter defaults an empty expiration to the profile's maximum and rejects anything later
+func profileNotAfter(profile *daos.CertificateProfile, notAfter time.Time) (time.Time, error) {
+	if profile.MaxValidityDays == 0 {
+		return notAfter, nil
+	}
+
+	maxNotAfter := time.Now().Add(time.Duration(profile.MaxValidityDays) * 24 * time.Hour)
+	if notAfter.IsZero() {
+		return maxNotAfter, nil
+	}
+
+	if notAfter.After(maxNotAfter) {
+		return time.Time{}, fmt.Errorf(
+			"%w: validity exceeds %d days",
+			ErrProfileViolation,
+			profile.MaxValidityDays,
+		)
+	}
+
+	return notAfter, nil
+}
+
+func profileCheckKeyAlgorithm(profile *daos.CertificateProfile, publicKey crypto.PublicKey) error {
+	if len(profile.AllowedKeyAlgorithms) == 0 {
+		return nil
+	}
+
+	var algorithm contracts.KeyAlgorithm
+	switch publicKey.(type) {
+	case *ecdsa.PublicKey:
+		algorithm = contracts.ECDSA
+	case ed25519.PublicKey:
+		algorithm = contracts.ED25519
+	case *rsa.PublicKey:
+		algorithm = contracts.RSA
+	default:
+		algorithm = contracts.Unknown
+	}
+
+	if !containsString(profile.AllowedKeyAlgorithms, algorithm.String()) {
+		return fmt.Errorf("%w: key algorithm not allowed", ErrProfileViolation)
+	}
+
+	return nil
+}
+
+// profileSubjectDefaults fills the subject fields left empty by the request
+func profileSubjectDefaults(profile *daos.CertificateProfile, subject *pkix.Name) {
+	defaults := []struct {
+		field *[]string
+		value string
+	}{
+		{&subject.Organization, profile.Organization},
+		{&subject.OrganizationalUnit, profile.OrganizationalUnit},
+		{&subject.Country, profile.Country},
+		{&subject.Province, profile.Province},
+		{&subject.Locality, profile.Locality},
+		{&subject.StreetAddress, profile.StreetAddress},
+		{&subject.PostalCode, profile.PostalCode},
+	}
+
+	for _, d := range defaults {
+		if d.value != "" && (len(*d.field) == 0 || (len(*d.field) == 1 && (*d.field)[0] == "")) {
+			*d.field = []string{d.value}
+		}
+	}
+}
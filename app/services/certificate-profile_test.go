@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateProfileMaxPathLen(t *testing.T) {
+	platform := newCertificateTestPlatform(t, "secret")
+
+	tests := []struct {
+		maxPathLen int
+		wantErr    bool
+	}{
+		{maxPathLen: ProfileUnlimitedPathLen},
+		{maxPathLen: 0},
+		{maxPathLen: 3},
+		{maxPathLen: -2, wantErr: true},
+	}
+
+	for _, test := range tests {
+		_, err := platform.profileService.CreateProfileForUser(
+			context.Background(),
+			certificateTestUserID,
+			&contracts.CertificateProfileRequest{
+				Name:       "CA",
+				IsCA:       true,
+				MaxPathLen: test.maxPathLen,
+			},
+		)
+		if test.wantErr {
+			assert.ErrorIs(t, err, ErrInvalidProfile, "max path length %d", test.maxPathLen)
+		} else {
+			assert.NoError(t, err, "max path length %d", test.maxPathLen)
+		}
+	}
+}
+
+func TestCreateCACertProfiles(t *testing.T) {
+	ctx := context.Background()
+	platform := newCertificateTestPlatform(t, "secret")
+	root := platform.createRootCA(t, "Profile Test Root")
+
+	profileID := func(t *testing.T, maxPathLen int) string {
+		profile, err := platform.profileService.CreateProfileForUser(
+			ctx,
+			certificateTestUserID,
+			&contracts.CertificateProfileRequest{
+				Name:         "CA",
+				ExtKeyUsages: []string{"serverAuth"},
+				IsCA:         true,
+				MaxPathLen:   maxPathLen,
+			},
+		)
+		require.NoError(t, err)
+
+		return profile.ID
+	}
+
+	tests := []struct {
+		name                string
+		certType            CertificateType
+		profileID           string
+		expectedMaxPathLen  int
+		expectedPathLenZero bool
+		expectedExtKeyUsage []x509.ExtKeyUsage
+	}{
+		{
+			name:                "default root",
+			certType:            CertTypeRootCA,
+			expectedMaxPathLen:  1,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		},
+		{
+			name:                "default intermediate",
+			certType:            CertTypeIntermediateCA,
+			expectedMaxPathLen:  0,
+			expectedPathLenZero: true,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		},
+		{
+			name:                "unlimited",
+			certType:            CertTypeRootCA,
+			profileID:           profileID(t, ProfileUnlimitedPathLen),
+			expectedMaxPathLen:  -1,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		{
+			name:                "zero",
+			certType:            CertTypeRootCA,
+			profileID:           profileID(t, 0),
+			expectedMaxPathLen:  0,
+			expectedPathLenZero: true,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+		{
+			name:                "limited",
+			certType:            CertTypeIntermediateCA,
+			profileID:           profileID(t, 2),
+			expectedMaxPathLen:  2,
+			expectedExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				key, err := platform.keyService.CreateKey(
+					ctx,
+					certificateTestUserID,
+					test.name,
+					contracts.ECDSA,
+					contracts.KeyParameters{Curve: contracts.P256},
+					certificateTestKeyPassword,
+				)
+				require.NoError(t, err)
+
+				request := &contracts.CreateCARequest{
+					Name:        test.name,
+					Expiration:  time.Now().Add(24 * time.Hour),
+					KeyID:       key.ID,
+					KeyPassword: certificateTestKeyPassword,
+					ProfileID:   test.profileID,
+				}
+				if test.certType == CertTypeIntermediateCA {
+					request.ParentCA = root.ID
+					request.ParentKeyPassword = certificateTestKeyPassword
+				}
+
+				data, err := platform.certificateService.CreateCACert(
+					ctx,
+					request,
+					certificateTestUserID,
+					test.certType,
+				)
+				require.NoError(t, err)
+
+				cert, err := x509.ParseCertificate(data)
+				require.NoError(t, err)
+				assert.True(t, cert.IsCA)
+				assert.Equal(t, x509.KeyUsageCertSign|x509.KeyUsageCRLSign, cert.KeyUsage)
+				assert.Equal(t, test.expectedMaxPathLen, cert.MaxPathLen)
+				assert.Equal(t, test.expectedPathLenZero, cert.MaxPathLenZero)
+				assert.Equal(t, test.expectedExtKeyUsage, cert.ExtKeyUsage)
+			},
+		)
+	}
+}
@@ -0,0 +1,414 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+// renewedExtensions are copied from the predecessor on renewal, the rest are rebuilt from the
+// template fields. A critical extended key usage is copied too, as the template would write it
+// non-critical.
+var renewedExtensions = []asn1.ObjectIdentifier{oidOCSPNoCheck}
+
+func (c *CertificateServiceImpl) RenewCertForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+	request *contracts.RenewCertificateRequest,
+) (resp *contracts.CertificateLightResponse, err error) {
+	defer func() {
+		c.auditService.Record(ctx, userID, AuditCertificateRenew, id, err)
+	}()
+
+	predecessor, cert, err := c.getRenewableCertForUser(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if predecessor.IsRevoked() {
+		return nil, ErrCertAlreadyRevoked
+	}
+
+	// Only self-signed roots need their own key, everything else is signed by the issuer
+	var key PrivateKey
+	if predecessor.ParentCertificate == "" {
+		key, err = c.keyService.GetDecryptedKeyForUser(
+			ctx,
+			predecessor.KeyID,
+			userID,
+			request.KeyPassword,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c.issueSuccessor(
+		ctx,
+		predecessor,
+		cert,
+		userID,
+		request,
+		cert.PublicKey,
+		predecessor.KeyID,
+		key,
+	)
+}
+
+func (c *CertificateServiceImpl) RekeyCertForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+	request *contracts.RekeyCertificateRequest,
+) (resp *contracts.CertificateLightResponse, err error) {
+	defer func() {
+		c.auditService.Record(ctx, userID, AuditCertificateRekey, id, err)
+	}()
+
+	predecessor, cert, err := c.getRenewableCertForUser(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			keyID := request.KeyID
+			if keyID == "" {
+				algorithm, params, err := publicKeyAlgorithmParameters(cert.PublicKey)
+				if err != nil {
+					return err
+				}
+
+				if request.Algorithm != nil {
+					algorithm, params = *request.Algorithm, request.KeyParameters
+				}
+
+				name := request.Name
+				if name == "" {
+					name = predecessor.Name
+				}
+
+				newKey, err := c.keyService.CreateKey(
+					ctx,
+					userID,
+					name,
+					algorithm,
+					params,
+					request.KeyPassword,
+				)
+				if err != nil {
+					return err
+				}
+
+				keyID = newKey.ID
+			}
+
+			key, err := c.keyService.GetDecryptedKeyForUser(ctx, keyID, userID, request.KeyPassword)
+			if err != nil {
+				return err
+			}
+
+			resp, err = c.issueSuccessor(
+				ctx,
+				predecessor,
+				cert,
+				userID,
+				&request.RenewCertificateRequest,
+				key.Public(),
+				keyID,
+				key,
+			)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// getRenewableCertForUser loads a certificate that the platform can issue a successor for
+func (c *CertificateServiceImpl) getRenewableCertForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) (*daos.Certificate, *x509.Certificate, error) {
+	certDao, err := c.certRepository.GetCertByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if certDao.UserID != userID {
+		return nil, nil, ErrCertUnautorized
+	}
+
+	cert, err := x509.ParseCertificate(certDao.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !isRenewable(certDao, cert) {
+		return nil, nil, ErrCertNotRenewable
+	}
+
+	return certDao, cert, nil
+}
+
+// isRenewable reports whether the platform can issue a successor for cert, either because its
+// issuer is on the platform or because it is a self-signed root with its key
+func isRenewable(certDao *daos.Certificate, cert *x509.Certificate) bool {
+	if certDao.ParentCertificate != "" {
+		return true
+	}
+
+	return certDao.KeyID != "" && bytes.Equal(cert.RawIssuer, cert.RawSubject)
+}
+
+// issueSuccessor signs a copy of cert for publicKey and stores it as replacing predecessor. key
+// is the private half of publicKey and only used to self-sign roots.
+func (c *CertificateServiceImpl) issueSuccessor(
+	ctx context.Context,
+	predecessor *daos.Certificate,
+	cert *x509.Certificate,
+	userID string,
+	request *contracts.RenewCertificateRequest,
+	publicKey crypto.PublicKey,
+	keyID string,
+	key PrivateKey,
+) (*contracts.CertificateLightResponse, error) {
+	notAfter := request.Expiration
+	if notAfter.IsZero() {
+		notAfter = time.Now().Add(cert.NotAfter.Sub(cert.NotBefore))
+	}
+
+	serialNumber, err := newSerialNumber(ctx, c.certRepository, predecessor.ParentCertificate)
+	if err != nil {
+		return nil, err
+	}
+
+	// RawSubject keeps the subject byte for byte so certificates issued by a renewed CA chain
+	// to either generation
+	certTemplate := x509.Certificate{
+		SerialNumber:          serialNumber,
+		RawSubject:            cert.RawSubject,
+		DNSNames:              cert.DNSNames,
+		IPAddresses:           cert.IPAddresses,
+		EmailAddresses:        cert.EmailAddresses,
+		URIs:                  cert.URIs,
+		NotBefore:             time.Now(),
+		NotAfter:              notAfter,
+		KeyUsage:              cert.KeyUsage,
+		ExtKeyUsage:           cert.ExtKeyUsage,
+		UnknownExtKeyUsage:    cert.UnknownExtKeyUsage,
+		BasicConstraintsValid: cert.BasicConstraintsValid,
+		IsCA:                  cert.IsCA,
+		MaxPathLen:            cert.MaxPathLen,
+		MaxPathLenZero:        cert.MaxPathLenZero,
+		ExtraExtensions:       renewedExtensionsOf(cert),
+	}
+
+	var parentCert *x509.Certificate
+	var parentKey PrivateKey
+	if predecessor.ParentCertificate == "" {
+		parentCert = &certTemplate
+		parentKey = key
+	} else {
+		parentCert, err = c.getX509CertificateForUser(ctx, predecessor.ParentCertificate, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		caKeyID, err := c.certRepository.GetKeyIDByCertID(ctx, predecessor.ParentCertificate)
+		if err != nil {
+			return nil, err
+		}
+
+		parentKey, err = c.keyService.GetDecryptedKeyForUser(
+			ctx,
+			caKeyID,
+			userID,
+			request.CAKeyPassword,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		certTemplate.OCSPServer, certTemplate.CRLDistributionPoints = c.revocationEndpoints(
+			predecessor.ParentCertificate,
+		)
+	}
+
+	certData, err := x509.CreateCertificate(
+		rand.Reader,
+		&certTemplate,
+		parentCert,
+		publicKey,
+		parentKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	name := request.Name
+	if name == "" {
+		name = predecessor.Name
+	}
+
+	var successor *daos.Certificate
+	err = c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			var err error
+			successor, err = c.certRepository.CreateCert(
+				ctx,
+				userID,
+				name,
+				certData,
+				predecessor.Type,
+				predecessor.ParentCertificate,
+				keyID,
+			)
+			if err != nil {
+				return err
+			}
+
+			err = c.certRepository.SetCertReplaces(ctx, successor.ID, predecessor.ID)
+			if err != nil {
+				return err
+			}
+			successor.Replaces = predecessor.ID
+
+			err = c.transparencyLog.AppendCertificate(ctx, successor)
+			if err != nil {
+				return err
+			}
+
+			if !request.RevokePredecessor || predecessor.IsRevoked() {
+				return nil
+			}
+
+			return c.RevokeCertForUser(
+				ctx,
+				predecessor.ID,
+				userID,
+				contracts.ReasonSuperseded,
+				request.CAKeyPassword,
+			)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return successor.ToLightResponse(), nil
+}
+
+func renewedExtensionsOf(cert *x509.Certificate) []pkix.Extension {
+	var extensions []pkix.Extension
+	for _, extension := range cert.Extensions {
+		if extension.Id.Equal(oidExtensionExtKeyUsage) && extension.Critical {
+			extensions = append(extensions, extension)
+			continue
+		}
+
+		for _, id := range renewedExtensions {
+			if extension.Id.Equal(id) {
+				extensions = append(extensions, extension)
+			}
+		}
+	}
+
+	return extensions
+}
+
+// publicKeyAlgorithmParameters is keyAlgorithmParameters for the public half of a key
+func publicKeyAlgorithmParameters(
+	key crypto.PublicKey,
+) (contracts.KeyAlgorithm, contracts.KeyParameters, error) {
+	switch typed := key.(type) {
+	case *rsa.PublicKey:
+		return contracts.RSA, contracts.KeyParameters{KeySize: typed.N.BitLen()}, nil
+	case *ecdsa.PublicKey:
+		for curveName, curve := range keyCurves {
+			if typed.Curve == curve {
+				return contracts.ECDSA, contracts.KeyParameters{Curve: curveName}, nil
+			}
+		}
+
+		return contracts.Unknown, contracts.KeyParameters{}, fmt.Errorf(
+			"%w: unsupported ECDSA curve %s",
+			ErrInvalidKeyParameters,
+			typed.Curve.Params().Name,
+		)
+	case ed25519.PublicKey:
+		return contracts.ED25519, contracts.KeyParameters{}, nil
+	default:
+		return contracts.Unknown, contracts.KeyParameters{}, ErrUnsupportedKeyAlgorithm
+	}
+}
+
+// certHistory returns the whole renewal lineage of a certificate, walking back to the original
+// and then forward through every successor, oldest first. A deleted predecessor ends the walk.
+func (c *CertificateServiceImpl) certHistory(
+	ctx context.Context,
+	certDao *daos.Certificate,
+) ([]*contracts.CertificateLightResponse, error) {
+	seen := map[string]bool{certDao.ID: true}
+	original := certDao
+	for original.Replaces != "" && !seen[original.Replaces] {
+		predecessor, err := c.certRepository.GetCertByID(ctx, original.Replaces)
+		if errors.Is(err, repositories.ErrNoRecord) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		seen[predecessor.ID] = true
+		original = predecessor
+	}
+
+	lineage := []*daos.Certificate{original}
+	visited := map[string]bool{original.ID: true}
+	for i := 0; i < len(lineage); i++ {
+		successors, err := c.certRepository.GetCertsReplacing(ctx, lineage[i].ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, successor := range successors {
+			if !visited[successor.ID] {
+				visited[successor.ID] = true
+				lineage = append(lineage, successor)
+			}
+		}
+	}
+
+	sort.SliceStable(
+		lineage, func(i, j int) bool {
+			return lineage[i].Created.Before(lineage[j].Created)
+		},
+	)
+
+	history := make([]*contracts.CertificateLightResponse, len(lineage))
+	for i, cert := range lineage {
+		history[i] = cert.ToLightResponse()
+	}
+
+	return history, nil
+}
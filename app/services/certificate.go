@@ -6,28 +6,46 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
-	"math/big"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/fapiko/john-hancock-platform/app/contracts"
 	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
 )
 
 var _ CertificateService = (*CertificateServiceImpl)(nil)
 
+// defaultCertValidity is used when neither the request nor its profile set an expiration
+const defaultCertValidity = 365 * 24 * time.Hour
+
+var oidExtKeyUsageTimeStamping = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+
 type CertificateServiceImpl struct {
-	certRepository repositories.CertRepository
-	keyRepository  repositories.KeyRepository
-	keyService     KeyService
+	certRepository    repositories.CertRepository
+	keyRepository     repositories.KeyRepository
+	profileRepository repositories.CertificateProfileRepository
+	keyService        KeyService
+	transactor        repositories.Transactor
+	auditService      AuditService
+	transparencyLog   TransparencyLogService
+	publicURL         string
+	secretKey         string
 }
 
 func (c *CertificateServiceImpl) DeleteCertForUser(
 	ctx context.Context,
 	id string,
 	userID string,
-) error {
+) (err error) {
+	defer func() {
+		c.auditService.Record(ctx, userID, AuditCertificateDelete, id, err)
+	}()
+
 	cert, err := c.certRepository.GetCertByID(ctx, id)
 	if err != nil {
 		return err
@@ -44,7 +62,11 @@ func (c *CertificateServiceImpl) GetCertAsPEMForUser(
 	ctx context.Context,
 	id string,
 	userID string,
-) (string, error) {
+) (certPEM string, err error) {
+	defer func() {
+		c.auditService.Record(ctx, userID, AuditCertificateDownload, id, err)
+	}()
+
 	cert, err := c.certRepository.GetCertByID(ctx, id)
 	if err != nil {
 		return "", err
@@ -89,6 +111,63 @@ func (c *CertificateServiceImpl) CreateCert(
 	caID string,
 	request *contracts.CreateCertificateRequest,
 	userID string,
+) (resp *contracts.CertificateLightResponse, err error) {
+	defer func() {
+		c.auditService.Record(
+			ctx, userID, AuditCertificateCreate, certificateLightResponseID(resp), err,
+		)
+	}()
+
+	certKey, err := c.keyService.GetDecryptedKeyForUser(
+		ctx,
+		request.KeyId,
+		userID,
+		request.KeyPassword,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.issueCert(
+		ctx,
+		caID,
+		userID,
+		request.CAKeyPassword,
+		&IssueCertParams{
+			Name:                    request.Name,
+			PublicKey:               certKey.Public(),
+			KeyID:                   request.KeyId,
+			CommonName:              request.CommonName,
+			SubjectAlternativeNames: request.SubjectAlternativeNames,
+			KeyUsages:               request.KeyUsages,
+			NotAfter:                request.Expiration,
+			ProfileID:               request.ProfileID,
+		},
+	)
+}
+
+func (c *CertificateServiceImpl) IssueCert(
+	ctx context.Context,
+	caID string,
+	userID string,
+	caKeyPassword string,
+	params *IssueCertParams,
+) (resp *contracts.CertificateLightResponse, err error) {
+	defer func() {
+		c.auditService.Record(
+			ctx, userID, AuditCertificateCreate, certificateLightResponseID(resp), err,
+		)
+	}()
+
+	return c.issueCert(ctx, caID, userID, caKeyPassword, params)
+}
+
+func (c *CertificateServiceImpl) issueCert(
+	ctx context.Context,
+	caID string,
+	userID string,
+	caKeyPassword string,
+	params *IssueCertParams,
 ) (*contracts.CertificateLightResponse, error) {
 	caCert, err := c.getX509CertificateForUser(ctx, caID, userID)
 	if err != nil {
@@ -103,57 +182,123 @@ func (c *CertificateServiceImpl) CreateCert(
 		ctx,
 		caKeyId,
 		userID,
-		request.CAKeyPassword,
+		caKeyPassword,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	certKey, err := c.keyService.GetDecryptedKeyForUser(
-		ctx,
-		request.KeyId,
-		userID,
-		request.KeyPassword,
-	)
+	subject := pkix.Name{}
+	if params.Subject != nil {
+		subject = *params.Subject
+	}
+	subject.CommonName = params.CommonName
+
+	requestedKeyUsages := params.KeyUsages
+	notAfter := params.NotAfter
+	if params.ProfileID != "" {
+		profile, err := getProfileForUser(ctx, c.profileRepository, params.ProfileID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if profile.IsCA {
+			return nil, fmt.Errorf("%w: profile is for CA certificates", ErrProfileViolation)
+		}
+
+		err = profileCheckKeyAlgorithm(profile, params.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		requestedKeyUsages, err = profileKeyUsages(profile, requestedKeyUsages)
+		if err != nil {
+			return nil, err
+		}
+
+		notAfter, err = profileNotAfter(profile, notAfter)
+		if err != nil {
+			return nil, err
+		}
+
+		profileSubjectDefaults(profile, &subject)
+	}
+
+	if notAfter.IsZero() {
+		notAfter = time.Now().Add(defaultCertValidity)
+	}
+
+	keyUsage, extKeyUsage, err := parseKeyUsages(requestedKeyUsages)
+	if err != nil {
+		return nil, err
+	}
+
+	extensions, err := extKeyUsageExtensions(extKeyUsage)
 	if err != nil {
 		return nil, err
 	}
 
-	keyUsage, extKeyUsage, err := c.keyUsages(request.KeyUsages)
+	ocspServers, crlDistributionPoints := c.revocationEndpoints(caID)
+
+	dnsNames := params.SubjectAlternativeNames
+	if validDNSName(strings.ToLower(strings.TrimPrefix(params.CommonName, "*."))) &&
+		!containsString(dnsNames, params.CommonName) {
+		dnsNames = append(dnsNames, params.CommonName)
+	}
+
+	serialNumber, err := newSerialNumber(ctx, c.certRepository, caID)
+	if err != nil {
+		return nil, err
+	}
 
 	certTemplate := x509.Certificate{
-		SerialNumber: big.NewInt(1),
-		Subject: pkix.Name{
-			CommonName: request.CommonName,
-		},
-		DNSNames:              append(request.SubjectAlternativeNames, request.CommonName),
+		SerialNumber:          serialNumber,
+		Subject:               subject,
+		DNSNames:              dnsNames,
+		IPAddresses:           params.IPAddresses,
+		EmailAddresses:        params.EmailAddresses,
+		URIs:                  params.URIs,
 		NotBefore:             time.Now(),
-		NotAfter:              request.Expiration,
+		NotAfter:              notAfter,
 		BasicConstraintsValid: true,
 		IsCA:                  false,
 		KeyUsage:              keyUsage,
 		ExtKeyUsage:           extKeyUsage,
+		OCSPServer:            ocspServers,
+		CRLDistributionPoints: crlDistributionPoints,
+		ExtraExtensions:       extensions,
 	}
 
 	cert, err := x509.CreateCertificate(
 		rand.Reader,
 		&certTemplate,
 		caCert,
-		certKey.Public(),
+		params.PublicKey,
 		caPrivateKey,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	dao, err := c.certRepository.CreateCert(
-		ctx,
-		userID,
-		request.Name,
-		cert,
-		CertTypeCertificate.String(),
-		caID,
-		request.KeyId,
+	var dao *daos.Certificate
+	err = c.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			var err error
+			dao, err = c.certRepository.CreateCert(
+				ctx,
+				userID,
+				params.Name,
+				cert,
+				CertTypeCertificate.String(),
+				caID,
+				params.KeyID,
+			)
+			if err != nil {
+				return err
+			}
+
+			return c.transparencyLog.AppendCertificate(ctx, dao)
+		},
 	)
 	if err != nil {
 		return nil, err
@@ -167,7 +312,17 @@ func (c *CertificateServiceImpl) CreateCert(
 	}, nil
 }
 
-func (c *CertificateServiceImpl) keyUsages(keyUsages []string) (
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseKeyUsages(keyUsages []string) (
 	x509.KeyUsage,
 	[]x509.ExtKeyUsage,
 	error,
@@ -213,6 +368,8 @@ func (c *CertificateServiceImpl) keyUsages(keyUsages []string) (
 			extKeyUsage = append(extKeyUsage, x509.ExtKeyUsageTimeStamping)
 		case "ocspSigning":
 			extKeyUsage = append(extKeyUsage, x509.ExtKeyUsageOCSPSigning)
+		case "any":
+			extKeyUsage = append(extKeyUsage, x509.ExtKeyUsageAny)
 		default:
 			return 0, nil, errors.New("invalid key usage")
 		}
@@ -221,6 +378,28 @@ func (c *CertificateServiceImpl) keyUsages(keyUsages []string) (
 	return keyUsage, extKeyUsage, nil
 }
 
+// extKeyUsageExtensions overrides the extended key usage of time stamping certificates, which
+// RFC 3161 requires to carry timeStamping alone in a critical extension. Go would write it
+// non-critical.
+func extKeyUsageExtensions(extKeyUsage []x509.ExtKeyUsage) ([]pkix.Extension, error) {
+	if len(extKeyUsage) != 1 || extKeyUsage[0] != x509.ExtKeyUsageTimeStamping {
+		return nil, nil
+	}
+
+	value, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageTimeStamping})
+	if err != nil {
+		return nil, err
+	}
+
+	return []pkix.Extension{
+		{
+			Id:       oidExtensionExtKeyUsage,
+			Critical: true,
+			Value:    value,
+		},
+	}, nil
+}
+
 func (c *CertificateServiceImpl) getX509CertificateForUser(
 	ctx context.Context,
 	id string,
@@ -241,15 +420,48 @@ func (c *CertificateServiceImpl) getX509CertificateForUser(
 func NewCertificateServiceImpl(
 	certRepository repositories.CertRepository,
 	keyRepository repositories.KeyRepository,
+	profileRepository repositories.CertificateProfileRepository,
 	keyService KeyService,
+	transactor repositories.Transactor,
+	auditService AuditService,
+	transparencyLog TransparencyLogService,
+	publicURL string,
+	secretKey string,
 ) *CertificateServiceImpl {
 	return &CertificateServiceImpl{
-		certRepository: certRepository,
-		keyRepository:  keyRepository,
-		keyService:     keyService,
+		certRepository:    certRepository,
+		keyRepository:     keyRepository,
+		profileRepository: profileRepository,
+		keyService:        keyService,
+		transactor:        transactor,
+		auditService:      auditService,
+		transparencyLog:   transparencyLog,
+		publicURL:         strings.TrimSuffix(publicURL, "/"),
+		secretKey:         secretKey,
 	}
 }
 
+// revocationEndpoints returns the OCSP responder and CRL distribution point URLs for
+// certificates issued by caID, or nothing when no public URL is configured.
+func (c *CertificateServiceImpl) revocationEndpoints(caID string) ([]string, []string) {
+	if c.publicURL == "" {
+		return nil, nil
+	}
+
+	caURL := c.publicURL + "/certificate-authorities/" + caID
+
+	return []string{caURL + "/ocsp"}, []string{caURL + "/crl"}
+}
+
+// certificateLightResponseID is the ID of a created certificate, or nothing when creating it failed
+func certificateLightResponseID(resp *contracts.CertificateLightResponse) string {
+	if resp == nil {
+		return ""
+	}
+
+	return resp.ID
+}
+
 type CertInfo struct {
 	Cert       *x509.Certificate
 	PrivateKey *rsa.PrivateKey
@@ -264,11 +476,46 @@ func (c *CertificateServiceImpl) GetCert(
 		return nil, err
 	}
 
+	return c.certResponse(ctx, certDao)
+}
+
+func (c *CertificateServiceImpl) GetCertBySerialForUser(
+	ctx context.Context,
+	caID string,
+	serialNumber string,
+	userID string,
+) (*contracts.CertificateResponse, error) {
+	serial, err := parseSerialNumber(serialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	certDao, err := c.certRepository.GetCertByIssuerAndSerial(ctx, caID, serial)
+	if err != nil {
+		return nil, err
+	}
+
+	if certDao.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	return c.certResponse(ctx, certDao)
+}
+
+func (c *CertificateServiceImpl) certResponse(
+	ctx context.Context,
+	certDao *daos.Certificate,
+) (*contracts.CertificateResponse, error) {
 	cert, err := x509.ParseCertificate(certDao.Data)
 	if err != nil {
 		return nil, err
 	}
 
+	history, err := c.certHistory(ctx, certDao)
+	if err != nil {
+		return nil, err
+	}
+
 	issuer := &contracts.PkixName{}
 	issuer.FromName(&cert.Issuer)
 
@@ -290,7 +537,7 @@ func (c *CertificateServiceImpl) GetCert(
 		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
 		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
 		Version:            cert.Version,
-		SerialNumber:       int(cert.SerialNumber.Int64()),
+		SerialNumber:       cert.SerialNumber.String(),
 		Issuer:             issuer,
 		Subject:            subject,
 		NotBefore:          cert.NotBefore,
@@ -301,6 +548,14 @@ func (c *CertificateServiceImpl) GetCert(
 		KeyUsage:           c.keyUsagesStr(cert.KeyUsage),
 		ExtKeyUsage:        c.extKeyUsagesStr(cert.ExtKeyUsage),
 		DNSNames:           cert.DNSNames,
+		Replaces:           certDao.Replaces,
+		RefreshError:       certDao.RefreshError,
+		History:            history,
+	}
+
+	if certDao.IsRevoked() {
+		certResponse.RevokedAt = certDao.RevokedAt
+		certResponse.RevocationReason = contracts.RevocationReason(certDao.RevocationReason).String()
 	}
 
 	return certResponse, nil
@@ -385,11 +640,6 @@ func (c *CertificateServiceImpl) CreateCACert(
 	userID string,
 	certificateType CertificateType,
 ) ([]byte, error) {
-	var maxPathLen = 0
-	if certificateType == CertTypeRootCA {
-		maxPathLen = 1 // TODO: Make this configurable - may want multiple intermediate CAs
-	}
-
 	var keyUsage = x509.KeyUsage(0)
 	var isCA = false
 	if certificateType == CertTypeRootCA || certificateType == CertTypeIntermediateCA {
@@ -402,8 +652,13 @@ func (c *CertificateServiceImpl) CreateCACert(
 		return nil, err
 	}
 
+	serialNumber, err := newSerialNumber(ctx, c.certRepository, request.ParentCA)
+	if err != nil {
+		return nil, err
+	}
+
 	certTemplate := x509.Certificate{
-		SerialNumber: big.NewInt(1),
+		SerialNumber: serialNumber,
 		Subject: pkix.Name{
 			Country:       []string{request.Country},
 			Organization:  []string{request.Organization},
@@ -416,13 +671,45 @@ func (c *CertificateServiceImpl) CreateCACert(
 		NotBefore:             time.Now(),
 		NotAfter:              request.Expiration,
 		KeyUsage:              keyUsage,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, // TODO: Make this configurable
 		IsCA:                  isCA,
-		MaxPathLen:            maxPathLen,
-		MaxPathLenZero:        true,
 		BasicConstraintsValid: true,
 	}
 
+	profile := defaultCAProfile(certificateType)
+	if request.ProfileID != "" {
+		profile, err = getProfileForUser(ctx, c.profileRepository, request.ProfileID, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !profile.IsCA {
+			return nil, fmt.Errorf("%w: profile is not for CA certificates", ErrProfileViolation)
+		}
+	}
+
+	err = profileCheckKeyAlgorithm(profile, key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	profileKeyUsage, profileExtKeyUsage, err := parseKeyUsages(
+		append(append([]string{}, profile.KeyUsages...), profile.ExtKeyUsages...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	certTemplate.KeyUsage |= profileKeyUsage
+	certTemplate.ExtKeyUsage = profileExtKeyUsage
+	profileMaxPathLen(profile, &certTemplate)
+
+	certTemplate.NotAfter, err = profileNotAfter(profile, request.Expiration)
+	if err != nil {
+		return nil, err
+	}
+
+	profileSubjectDefaults(profile, &certTemplate.Subject)
+
 	var parentCert *x509.Certificate
 	var parentKey PrivateKey
 	if request.ParentCA == "" {
@@ -434,6 +721,10 @@ func (c *CertificateServiceImpl) CreateCACert(
 			return nil, err
 		}
 
+		certTemplate.OCSPServer, certTemplate.CRLDistributionPoints = c.revocationEndpoints(
+			request.ParentCA,
+		)
+
 		keyId, err := c.certRepository.GetKeyIDByCertID(ctx, request.ParentCA)
 		if err != nil {
 			return nil, err
@@ -484,12 +775,7 @@ func (c *CertificateServiceImpl) GetUserCerts(
 
 	response := make([]*contracts.CertificateLightResponse, len(daos))
 	for i, dao := range daos {
-		response[i] = &contracts.CertificateLightResponse{
-			ID:      dao.ID,
-			Name:    dao.Name,
-			Type:    dao.Type,
-			Created: dao.Created,
-		}
+		response[i] = dao.ToLightResponse()
 	}
 
 	return response, nil
@@ -0,0 +1,551 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Cryptographic Message Syntax SignedData from RFC 5652, written by hand because the pkcs7
+// packages can neither sign a digest computed elsewhere nor carry CRLs
+
+var (
+	oidCMSData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCMSSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+	oidCMSAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidCMSAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidCMSAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidSignatureRSA         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSignatureSHA256RSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureSHA384RSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSignatureSHA512RSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidSignatureECDSASHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureECDSASHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidSignatureECDSASHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidSignatureEd25519     = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+var errUnsupportedCMS = errors.New("unsupported CMS structure")
+
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo cmsEncapsulatedContentInfo
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+type cmsEncapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                cmsIssuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsIssuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// cmsSignParams describes one signer of a SignedData. Content is left out of the message when
+// it is nil, producing a detached signature over Digest.
+type cmsSignParams struct {
+	ContentType asn1.ObjectIdentifier
+	Content     []byte
+	Digest      []byte
+	Hash        crypto.Hash
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+	SigningTime time.Time
+	// ExtraAttributes are signed alongside content type, signing time and message digest
+	ExtraAttributes []cmsAttribute
+	// Certificates and CRLs are DER encoded and embedded for the verifier's benefit
+	Certificates [][]byte
+	CRLs         [][]byte
+}
+
+// cmsSignedMessage is a parsed SignedData along with the certificates and CRLs it carries
+type cmsSignedMessage struct {
+	signedData   cmsSignedData
+	certificates []*x509.Certificate
+	crls         []*x509.RevocationList
+}
+
+// cmsSignerResult is the outcome of checking one SignerInfo
+type cmsSignerResult struct {
+	certificate *x509.Certificate
+	signingTime time.Time
+	err         error
+}
+
+func cmsDigestOID(hash crypto.Hash) (asn1.ObjectIdentifier, error) {
+	switch hash {
+	case crypto.SHA256:
+		return oidDigestSHA256, nil
+	case crypto.SHA384:
+		return oidDigestSHA384, nil
+	case crypto.SHA512:
+		return oidDigestSHA512, nil
+	default:
+		return nil, fmt.Errorf("%w: digest algorithm %s", errUnsupportedCMS, hash)
+	}
+}
+
+func cmsHashForOID(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidDigestSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidDigestSHA512):
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("%w: digest algorithm %s", errUnsupportedCMS, oid)
+	}
+}
+
+// cmsSignatureAlgorithm picks the signatureAlgorithm of a SignerInfo for key. RSA is plain
+// rsaEncryption as RFC 3370 recommends and Ed25519 requires SHA-512 per RFC 8419.
+func cmsSignatureAlgorithm(
+	key crypto.PublicKey,
+	hash crypto.Hash,
+) (pkix.AlgorithmIdentifier, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  oidSignatureRSA,
+			Parameters: asn1.NullRawValue,
+		}, nil
+	case *ecdsa.PublicKey:
+		switch hash {
+		case crypto.SHA256:
+			return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSASHA256}, nil
+		case crypto.SHA384:
+			return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSASHA384}, nil
+		case crypto.SHA512:
+			return pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSASHA512}, nil
+		}
+	case ed25519.PublicKey:
+		if hash == crypto.SHA512 {
+			return pkix.AlgorithmIdentifier{Algorithm: oidSignatureEd25519}, nil
+		}
+
+		return pkix.AlgorithmIdentifier{}, fmt.Errorf(
+			"%w: Ed25519 signatures require SHA-512",
+			errUnsupportedCMS,
+		)
+	}
+
+	return pkix.AlgorithmIdentifier{}, fmt.Errorf("%w: key or digest algorithm", errUnsupportedCMS)
+}
+
+func newCMSAttribute(oid asn1.ObjectIdentifier, value interface{}) (cmsAttribute, error) {
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		return cmsAttribute{}, err
+	}
+
+	return cmsAttribute{
+		Type: oid,
+		Values: asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      der,
+		},
+	}, nil
+}
+
+// marshalCMSAttributes returns the DER SET OF attributes, which is what gets signed. DER sorts
+// the members of a SET OF by their encoding.
+func marshalCMSAttributes(attributes []cmsAttribute) ([]byte, error) {
+	encoded := make([][]byte, len(attributes))
+	for i, attribute := range attributes {
+		der, err := asn1.Marshal(attribute)
+		if err != nil {
+			return nil, err
+		}
+
+		encoded[i] = der
+	}
+
+	sort.Slice(
+		encoded, func(i, j int) bool {
+			return bytes.Compare(encoded[i], encoded[j]) < 0
+		},
+	)
+
+	return asn1.Marshal(
+		asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      bytes.Join(encoded, nil),
+		},
+	)
+}
+
+// implicitSet wraps DER encoded members as an IMPLICIT [tag] SET OF, nil when there are none
+func implicitSet(tag int, members [][]byte) asn1.RawValue {
+	if len(members) == 0 {
+		return asn1.RawValue{}
+	}
+
+	return asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      bytes.Join(members, nil),
+	}
+}
+
+// signCMS produces a DER ContentInfo holding a SignedData with a single signer
+func signCMS(params *cmsSignParams) ([]byte, error) {
+	digestOID, err := cmsDigestOID(params.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params.Digest) != params.Hash.Size() {
+		return nil, fmt.Errorf("%w: digest length does not match %s", errUnsupportedCMS, params.Hash)
+	}
+
+	signatureAlgorithm, err := cmsSignatureAlgorithm(params.Key.Public(), params.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := newCMSAttribute(oidCMSAttributeContentType, params.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	signingTime, err := newCMSAttribute(oidCMSAttributeSigningTime, params.SigningTime.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	messageDigest, err := newCMSAttribute(oidCMSAttributeMessageDigest, params.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := append(
+		[]cmsAttribute{contentType, signingTime, messageDigest},
+		params.ExtraAttributes...,
+	)
+	signedAttrs, err := marshalCMSAttributes(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ed25519 signs the attributes themselves, everything else signs their digest
+	toSign := signedAttrs
+	var opts crypto.SignerOpts = params.Hash
+	if _, ok := params.Key.Public().(ed25519.PublicKey); ok {
+		opts = crypto.Hash(0)
+	} else {
+		h := params.Hash.New()
+		h.Write(signedAttrs)
+		toSign = h.Sum(nil)
+	}
+
+	signature, err := params.Key.Sign(rand.Reader, toSign, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signed attributes are carried as [0] IMPLICIT rather than the SET they were signed as
+	var attrs asn1.RawValue
+	_, err = asn1.Unmarshal(signedAttrs, &attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if !params.ContentType.Equal(oidCMSData) {
+		version = 3
+	}
+
+	signedData := cmsSignedData{
+		Version:          version,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: digestOID}},
+		EncapContentInfo: cmsEncapsulatedContentInfo{
+			EContentType: params.ContentType,
+			EContent:     params.Content,
+		},
+		Certificates: implicitSet(0, params.Certificates),
+		CRLs:         implicitSet(1, params.CRLs),
+		SignerInfos: []cmsSignerInfo{
+			{
+				Version: 1,
+				SID: cmsIssuerAndSerialNumber{
+					Issuer:       asn1.RawValue{FullBytes: params.Certificate.RawIssuer},
+					SerialNumber: params.Certificate.SerialNumber,
+				},
+				DigestAlgorithm: pkix.AlgorithmIdentifier{Algorithm: digestOID},
+				SignedAttrs: asn1.RawValue{
+					Class:      asn1.ClassContextSpecific,
+					Tag:        0,
+					IsCompound: true,
+					Bytes:      attrs.Bytes,
+				},
+				SignatureAlgorithm: signatureAlgorithm,
+				Signature:          signature,
+			},
+		},
+	}
+
+	inner, err := asn1.Marshal(signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	// RawValues are written with their own tag, so the EXPLICIT [0] has to be spelled out
+	return asn1.Marshal(
+		cmsContentInfo{
+			ContentType: oidCMSSignedData,
+			Content: asn1.RawValue{
+				Class:      asn1.ClassContextSpecific,
+				Tag:        0,
+				IsCompound: true,
+				Bytes:      inner,
+			},
+		},
+	)
+}
+
+// parseCMS reads a DER ContentInfo holding a SignedData
+func parseCMS(der []byte) (*cmsSignedMessage, error) {
+	var contentInfo cmsContentInfo
+	rest, err := asn1.Unmarshal(der, &contentInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", errUnsupportedCMS)
+	}
+
+	if !contentInfo.ContentType.Equal(oidCMSSignedData) {
+		return nil, fmt.Errorf("%w: content type %s", errUnsupportedCMS, contentInfo.ContentType)
+	}
+
+	message := &cmsSignedMessage{}
+	_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &message.signedData)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(message.signedData.Certificates.Bytes) > 0 {
+		message.certificates, err = x509.ParseCertificates(message.signedData.Certificates.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	crls := message.signedData.CRLs.Bytes
+	for len(crls) > 0 {
+		var raw asn1.RawValue
+		crls, err = asn1.Unmarshal(crls, &raw)
+		if err != nil {
+			return nil, err
+		}
+
+		crl, err := x509.ParseRevocationList(raw.FullBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		message.crls = append(message.crls, crl)
+	}
+
+	return message, nil
+}
+
+// signerCertificate finds the embedded certificate a SignerInfo refers to
+func (m *cmsSignedMessage) signerCertificate(sid *cmsIssuerAndSerialNumber) *x509.Certificate {
+	for _, cert := range m.certificates {
+		if cert.SerialNumber.Cmp(sid.SerialNumber) == 0 &&
+			bytes.Equal(cert.RawIssuer, sid.Issuer.FullBytes) {
+			return cert
+		}
+	}
+
+	return nil
+}
+
+// verifySigners checks every SignerInfo against the content. digestFor returns the digest of
+// the content under the given hash, which lets detached signatures be checked against a digest
+// computed elsewhere. Trust in the signer certificates is left to the caller.
+func (m *cmsSignedMessage) verifySigners(
+	digestFor func(hash crypto.Hash) ([]byte, error),
+) []*cmsSignerResult {
+	results := make([]*cmsSignerResult, len(m.signedData.SignerInfos))
+	for i := range m.signedData.SignerInfos {
+		results[i] = m.verifySigner(&m.signedData.SignerInfos[i], digestFor)
+	}
+
+	return results
+}
+
+func (m *cmsSignedMessage) verifySigner(
+	signerInfo *cmsSignerInfo,
+	digestFor func(hash crypto.Hash) ([]byte, error),
+) *cmsSignerResult {
+	result := &cmsSignerResult{
+		certificate: m.signerCertificate(&signerInfo.SID),
+	}
+
+	if result.certificate == nil {
+		result.err = errors.New("signer certificate is not included in the signature")
+		return result
+	}
+
+	if len(signerInfo.SignedAttrs.Bytes) == 0 {
+		result.err = fmt.Errorf("%w: signed attributes are required", errUnsupportedCMS)
+		return result
+	}
+
+	hash, err := cmsHashForOID(signerInfo.DigestAlgorithm.Algorithm)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	var messageDigest []byte
+	var contentType asn1.ObjectIdentifier
+	rest := signerInfo.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		var attribute cmsAttribute
+		rest, err = asn1.Unmarshal(rest, &attribute)
+		if err != nil {
+			result.err = err
+			return result
+		}
+
+		switch {
+		case attribute.Type.Equal(oidCMSAttributeMessageDigest):
+			_, err = asn1.Unmarshal(attribute.Values.Bytes, &messageDigest)
+		case attribute.Type.Equal(oidCMSAttributeContentType):
+			_, err = asn1.Unmarshal(attribute.Values.Bytes, &contentType)
+		case attribute.Type.Equal(oidCMSAttributeSigningTime):
+			_, err = asn1.Unmarshal(attribute.Values.Bytes, &result.signingTime)
+		}
+		if err != nil {
+			result.err = err
+			return result
+		}
+	}
+
+	if !contentType.Equal(m.signedData.EncapContentInfo.EContentType) {
+		result.err = errors.New("content type attribute does not match the content")
+		return result
+	}
+
+	digest, err := digestFor(hash)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	if !bytes.Equal(digest, messageDigest) {
+		result.err = errors.New("message digest does not match the content")
+		return result
+	}
+
+	signedAttrs, err := asn1.Marshal(
+		asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      signerInfo.SignedAttrs.Bytes,
+		},
+	)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	result.err = checkCMSSignature(
+		result.certificate,
+		signerInfo.SignatureAlgorithm.Algorithm,
+		hash,
+		signedAttrs,
+		signerInfo.Signature,
+	)
+
+	return result
+}
+
+func checkCMSSignature(
+	cert *x509.Certificate,
+	algorithm asn1.ObjectIdentifier,
+	hash crypto.Hash,
+	signed []byte,
+	signature []byte,
+) error {
+	var x509Algorithm x509.SignatureAlgorithm
+	switch {
+	case algorithm.Equal(oidSignatureRSA), algorithm.Equal(oidSignatureSHA256RSA),
+		algorithm.Equal(oidSignatureSHA384RSA), algorithm.Equal(oidSignatureSHA512RSA):
+		x509Algorithm = map[crypto.Hash]x509.SignatureAlgorithm{
+			crypto.SHA256: x509.SHA256WithRSA,
+			crypto.SHA384: x509.SHA384WithRSA,
+			crypto.SHA512: x509.SHA512WithRSA,
+		}[hash]
+	case algorithm.Equal(oidSignatureECDSASHA256):
+		x509Algorithm = x509.ECDSAWithSHA256
+	case algorithm.Equal(oidSignatureECDSASHA384):
+		x509Algorithm = x509.ECDSAWithSHA384
+	case algorithm.Equal(oidSignatureECDSASHA512):
+		x509Algorithm = x509.ECDSAWithSHA512
+	case algorithm.Equal(oidSignatureEd25519):
+		x509Algorithm = x509.PureEd25519
+	default:
+		return fmt.Errorf("%w: signature algorithm %s", errUnsupportedCMS, algorithm)
+	}
+
+	err := cert.CheckSignature(x509Algorithm, signed, signature)
+	if err != nil {
+		return fmt.Errorf("signature does not verify: %w", err)
+	}
+
+	return nil
+}
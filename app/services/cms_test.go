@@ -0,0 +1,347 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/smallstep/pkcs7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cmsTestSigner is a CA and a signer certificate it issued
+type cmsTestSigner struct {
+	ca      *x509.Certificate
+	caKey   crypto.Signer
+	cert    *x509.Certificate
+	key     crypto.Signer
+	hash    crypto.Hash
+	keyKind string
+}
+
+func newCMSTestSigner(t *testing.T, keyKind string, hash crypto.Hash) *cmsTestSigner {
+	caKey := testutils.Key(t, "ecdsa")
+	ca := testutils.CACertificate(t, "CMS Test CA", caKey, nil, nil)
+	key := testutils.Key(t, keyKind)
+
+	return &cmsTestSigner{
+		ca:      ca,
+		caKey:   caKey,
+		cert:    testutils.LeafCertificate(t, "CMS Test Signer", key, ca, caKey),
+		key:     key,
+		hash:    hash,
+		keyKind: keyKind,
+	}
+}
+
+func (s *cmsTestSigner) digest(content []byte) []byte {
+	h := s.hash.New()
+	h.Write(content)
+
+	return h.Sum(nil)
+}
+
+// sign signs content, detached when attach isn't set, embedding the signer certificate and crls
+func (s *cmsTestSigner) sign(t *testing.T, content []byte, attach bool, crls ...[]byte) []byte {
+	params := &cmsSignParams{
+		ContentType:  oidCMSData,
+		Digest:       s.digest(content),
+		Hash:         s.hash,
+		Certificate:  s.cert,
+		Key:          s.key,
+		SigningTime:  time.Now().UTC().Truncate(time.Second),
+		Certificates: [][]byte{s.cert.Raw},
+		CRLs:         crls,
+	}
+	if attach {
+		params.Content = content
+	}
+
+	der, err := signCMS(params)
+	require.NoError(t, err)
+
+	return der
+}
+
+func digestOf(content []byte) func(hash crypto.Hash) ([]byte, error) {
+	return func(hash crypto.Hash) ([]byte, error) {
+		h := hash.New()
+		h.Write(content)
+		return h.Sum(nil), nil
+	}
+}
+
+var cmsTestSigners = []struct {
+	keyKind string
+	hash    crypto.Hash
+}{
+	{keyKind: "rsa", hash: crypto.SHA256},
+	{keyKind: "ecdsa", hash: crypto.SHA256},
+	{keyKind: "ecdsa", hash: crypto.SHA384},
+	{keyKind: "ed25519", hash: crypto.SHA512},
+}
+
+func TestSignCMSRoundTrip(t *testing.T) {
+	content := []byte("the quick brown fox")
+
+	for _, test := range cmsTestSigners {
+		for _, attach := range []bool{true, false} {
+			t.Run(
+				fmt.Sprintf("%s %s attached %t", test.keyKind, test.hash, attach),
+				func(t *testing.T) {
+					signer := newCMSTestSigner(t, test.keyKind, test.hash)
+
+					message, err := parseCMS(signer.sign(t, content, attach))
+					require.NoError(t, err)
+
+					if attach {
+						assert.Equal(t, content, message.signedData.EncapContentInfo.EContent)
+					} else {
+						assert.Nil(t, message.signedData.EncapContentInfo.EContent)
+					}
+
+					results := message.verifySigners(digestOf(content))
+					require.Len(t, results, 1)
+					require.NoError(t, results[0].err)
+					assert.Equal(t, signer.cert.Raw, results[0].certificate.Raw)
+					assert.WithinDuration(t, time.Now(), results[0].signingTime, time.Minute)
+				},
+			)
+		}
+	}
+}
+
+// The pkcs7 package can't do Ed25519, the other signers are checked against it both ways
+func TestSignCMSInteroperatesWithPKCS7(t *testing.T) {
+	content := []byte("the quick brown fox")
+
+	for _, test := range cmsTestSigners[:3] {
+		t.Run(
+			fmt.Sprintf("%s %s", test.keyKind, test.hash), func(t *testing.T) {
+				signer := newCMSTestSigner(t, test.keyKind, test.hash)
+				roots := x509.NewCertPool()
+				roots.AddCert(signer.ca)
+
+				p7, err := pkcs7.Parse(signer.sign(t, content, true))
+				require.NoError(t, err)
+				assert.Equal(t, content, p7.Content)
+				assert.NoError(t, p7.VerifyWithChain(roots), "attached")
+
+				p7, err = pkcs7.Parse(signer.sign(t, content, false))
+				require.NoError(t, err)
+				p7.Content = content
+				assert.NoError(t, p7.VerifyWithChain(roots), "detached")
+
+				digestOID, err := cmsDigestOID(test.hash)
+				require.NoError(t, err)
+
+				signedData, err := pkcs7.NewSignedData(content)
+				require.NoError(t, err)
+				signedData.SetDigestAlgorithm(digestOID)
+				require.NoError(
+					t,
+					signedData.AddSigner(signer.cert, signer.key, pkcs7.SignerInfoConfig{}),
+				)
+
+				der, err := signedData.Finish()
+				require.NoError(t, err)
+
+				message, err := parseCMS(der)
+				require.NoError(t, err)
+
+				results := message.verifySigners(digestOf(content))
+				require.Len(t, results, 1)
+				assert.NoError(t, results[0].err, "signed by pkcs7")
+			},
+		)
+	}
+}
+
+// Signing a digest computed elsewhere gives the same signature as signing the data it hashes
+func TestSignCMSDigestOnly(t *testing.T) {
+	content := []byte("hashed by the client")
+	signer := newCMSTestSigner(t, "ecdsa", crypto.SHA256)
+
+	der := signer.sign(t, content, false)
+
+	p7, err := pkcs7.Parse(der)
+	require.NoError(t, err)
+	p7.Content = content
+	assert.NoError(t, p7.Verify())
+
+	message, err := parseCMS(der)
+	require.NoError(t, err)
+
+	results := message.verifySigners(
+		func(hash crypto.Hash) ([]byte, error) {
+			return signer.digest(content), nil
+		},
+	)
+	require.Len(t, results, 1)
+	assert.NoError(t, results[0].err)
+
+	_, err = signCMS(
+		&cmsSignParams{
+			ContentType: oidCMSData,
+			Digest:      signer.digest(content)[:20],
+			Hash:        crypto.SHA256,
+			Certificate: signer.cert,
+			Key:         signer.key,
+		},
+	)
+	assert.ErrorIs(t, err, errUnsupportedCMS, "digest of the wrong length")
+}
+
+func TestVerifyCMSTampered(t *testing.T) {
+	content := []byte("the quick brown fox")
+	signer := newCMSTestSigner(t, "rsa", crypto.SHA256)
+
+	t.Run(
+		"different content", func(t *testing.T) {
+			message, err := parseCMS(signer.sign(t, content, false))
+			require.NoError(t, err)
+
+			results := message.verifySigners(digestOf([]byte("the quick brown cat")))
+			require.Len(t, results, 1)
+			assert.ErrorContains(t, results[0].err, "message digest does not match")
+		},
+	)
+
+	t.Run(
+		"edited encapsulated content", func(t *testing.T) {
+			der := signer.sign(t, content, true)
+			tampered := bytes.Replace(der, []byte("brown"), []byte("green"), 1)
+			require.NotEqual(t, der, tampered)
+
+			message, err := parseCMS(tampered)
+			require.NoError(t, err)
+
+			results := message.verifySigners(
+				digestOf(message.signedData.EncapContentInfo.EContent),
+			)
+			require.Len(t, results, 1)
+			assert.ErrorContains(t, results[0].err, "message digest does not match")
+
+			p7, err := pkcs7.Parse(tampered)
+			require.NoError(t, err)
+			assert.Error(t, p7.Verify(), "pkcs7 agrees")
+		},
+	)
+
+	t.Run(
+		"edited signature", func(t *testing.T) {
+			message, err := parseCMS(signer.sign(t, content, false))
+			require.NoError(t, err)
+
+			message.signedData.SignerInfos[0].Signature[10] ^= 0xff
+
+			results := message.verifySigners(digestOf(content))
+			require.Len(t, results, 1)
+			assert.ErrorContains(t, results[0].err, "signature does not verify")
+		},
+	)
+
+	t.Run(
+		"signer certificate left out", func(t *testing.T) {
+			message, err := parseCMS(signer.sign(t, content, false))
+			require.NoError(t, err)
+
+			message.certificates = nil
+
+			results := message.verifySigners(digestOf(content))
+			require.Len(t, results, 1)
+			assert.ErrorContains(t, results[0].err, "signer certificate is not included")
+		},
+	)
+}
+
+func TestCheckSignerTrust(t *testing.T) {
+	ctx := context.Background()
+	content := []byte("the quick brown fox")
+	signer := newCMSTestSigner(t, "ecdsa", crypto.SHA256)
+	service := &SignatureServiceImpl{certRepository: repositories.NewCertRepositoryMemory()}
+	anchor := &daos.Certificate{ID: "anchor"}
+
+	checkTrust := func(der []byte, trusted *x509.Certificate) error {
+		message, err := parseCMS(der)
+		require.NoError(t, err)
+
+		results := message.verifySigners(digestOf(content))
+		require.Len(t, results, 1)
+		require.NoError(t, results[0].err)
+
+		roots := x509.NewCertPool()
+		roots.AddCert(trusted)
+
+		return service.checkSignerTrust(
+			ctx,
+			anchor,
+			message,
+			results[0].certificate,
+			roots,
+			x509.NewCertPool(),
+		)
+	}
+
+	t.Run(
+		"issuing CA", func(t *testing.T) {
+			assert.NoError(t, checkTrust(signer.sign(t, content, false), signer.ca))
+		},
+	)
+
+	t.Run(
+		"wrong trust anchor", func(t *testing.T) {
+			otherKey := testutils.Key(t, "ecdsa")
+			other := testutils.CACertificate(t, "CMS Test CA", otherKey, nil, nil)
+
+			err := checkTrust(signer.sign(t, content, false), other)
+			assert.ErrorAs(t, err, &x509.UnknownAuthorityError{})
+		},
+	)
+
+	t.Run(
+		"revoked by an embedded CRL", func(t *testing.T) {
+			crl := testutils.CRL(t, signer.ca, signer.caKey, signer.cert)
+			der := signer.sign(t, content, false, crl)
+
+			err := checkTrust(der, signer.ca)
+			assert.ErrorContains(t, err, "has been revoked")
+
+			p7, err := pkcs7.Parse(der)
+			require.NoError(t, err)
+			assert.Len(t, p7.CRLs, 1, "pkcs7 reads the embedded CRL")
+		},
+	)
+
+	t.Run(
+		"embedded CRL revoking someone else", func(t *testing.T) {
+			otherKey := testutils.Key(t, "ecdsa")
+			other := testutils.LeafCertificate(t, "Other Signer", otherKey, signer.ca, signer.caKey)
+			crl := testutils.CRL(t, signer.ca, signer.caKey, other)
+
+			assert.NoError(t, checkTrust(signer.sign(t, content, false, crl), signer.ca))
+		},
+	)
+
+	t.Run(
+		"embedded CRL from a different issuer", func(t *testing.T) {
+			otherKey := testutils.Key(t, "ecdsa")
+			other := testutils.CACertificate(t, "CMS Test CA", otherKey, nil, nil)
+			crl := testutils.CRL(t, other, otherKey, signer.cert)
+
+			assert.NoError(
+				t,
+				checkTrust(signer.sign(t, content, false, crl), signer.ca),
+				"CRLs the issuer didn't sign are ignored",
+			)
+		},
+	)
+}
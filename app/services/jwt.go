@@ -0,0 +1,560 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"go.step.sm/crypto/jose"
+)
+
+// defaultJWKSetOverlap is how long a replaced key stays published when the set doesn't say
+const defaultJWKSetOverlap = 24 * time.Hour
+
+// jwtLeeway allows for clock skew between the issuer and the verifier when checking exp and nbf
+const jwtLeeway = time.Minute
+
+var _ JWTService = (*JWTServiceImpl)(nil)
+
+var ErrJWKSetUnauthorized = errors.New("user does not have access to this key set")
+var ErrInvalidJWTRequest = errors.New("invalid JWT request")
+var ErrJWKAlreadyInSet = errors.New("key is already in the key set")
+
+// JWTService signs JWTs with keys KeyService manages. Keys are grouped into sets, each published
+// as a JWKS for relying parties to verify against. Rotating a set's key keeps the replaced key
+// published for the set's overlap window so the tokens it signed keep verifying.
+type JWTService interface {
+	CreateSetForUser(
+		ctx context.Context,
+		userID string,
+		request *contracts.CreateJWKSetRequest,
+	) (*contracts.JWKSetResponse, error)
+	GetSetsForUser(ctx context.Context, userID string) ([]*contracts.JWKSetResponse, error)
+	// RotateKeyForUser makes a key the set's signing key, retiring the one it replaces
+	RotateKeyForUser(
+		ctx context.Context,
+		setID string,
+		userID string,
+		request *contracts.RotateJWKSetKeyRequest,
+	) (*contracts.JWKSetResponse, error)
+	// RetireKeyForUser stops publishing a key straight away, for keys that can no longer be
+	// trusted. Tokens it signed stop verifying.
+	RetireKeyForUser(ctx context.Context, setID string, kid string, userID string) error
+	// GetJWKS returns the keys a set currently publishes
+	GetJWKS(ctx context.Context, setID string) (*jose.JSONWebKeySet, error)
+	// GetJWKSForUser returns the keys every set of the user currently publishes
+	GetJWKSForUser(ctx context.Context, userID string) (*jose.JSONWebKeySet, error)
+	SignForUser(
+		ctx context.Context,
+		setID string,
+		userID string,
+		request *contracts.SignJWTRequest,
+	) (*contracts.SignJWTResponse, error)
+	// Verify checks a token against the keys the set publishes. A token that doesn't verify is
+	// reported in the response, errors are kept for requests that can't be checked at all.
+	Verify(
+		ctx context.Context,
+		setID string,
+		request *contracts.VerifyJWTRequest,
+	) (*contracts.VerifyJWTResponse, error)
+}
+
+type JWTServiceImpl struct {
+	jwkSetRepository repositories.JWKSetRepository
+	keyService       KeyService
+	transactor       repositories.Transactor
+	auditService     AuditService
+}
+
+func NewJWTServiceImpl(
+	jwkSetRepository repositories.JWKSetRepository,
+	keyService KeyService,
+	transactor repositories.Transactor,
+	auditService AuditService,
+) *JWTServiceImpl {
+	return &JWTServiceImpl{
+		jwkSetRepository: jwkSetRepository,
+		keyService:       keyService,
+		transactor:       transactor,
+		auditService:     auditService,
+	}
+}
+
+func (s *JWTServiceImpl) CreateSetForUser(
+	ctx context.Context,
+	userID string,
+	request *contracts.CreateJWKSetRequest,
+) (*contracts.JWKSetResponse, error) {
+	if request.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidJWTRequest)
+	}
+
+	if request.OverlapSeconds < 0 {
+		return nil, fmt.Errorf("%w: overlap can't be negative", ErrInvalidJWTRequest)
+	}
+
+	overlapSeconds := request.OverlapSeconds
+	if overlapSeconds == 0 {
+		overlapSeconds = int(defaultJWKSetOverlap / time.Second)
+	}
+
+	set := &daos.JWKSet{
+		UserID:         userID,
+		Name:           request.Name,
+		OverlapSeconds: overlapSeconds,
+	}
+
+	err := s.jwkSetRepository.CreateSet(ctx, set)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwkSetResponse(set, nil), nil
+}
+
+func (s *JWTServiceImpl) GetSetsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*contracts.JWKSetResponse, error) {
+	sets, err := s.jwkSetRepository.GetSetsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.jwkSetRepository.GetKeysForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keysBySet := make(map[string][]*daos.JWKSetKey)
+	for _, key := range keys {
+		keysBySet[key.SetID] = append(keysBySet[key.SetID], key)
+	}
+
+	response := make([]*contracts.JWKSetResponse, len(sets))
+	for i, set := range sets {
+		response[i] = jwkSetResponse(set, keysBySet[set.ID])
+	}
+
+	return response, nil
+}
+
+func (s *JWTServiceImpl) RotateKeyForUser(
+	ctx context.Context,
+	setID string,
+	userID string,
+	request *contracts.RotateJWKSetKeyRequest,
+) (*contracts.JWKSetResponse, error) {
+	set, err := s.getSetForUser(ctx, setID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decrypting the key checks the user owns it and knows its password
+	key, err := s.keyService.GetDecryptedKeyForUser(ctx, request.KeyID, userID, request.KeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key is not capable of signing")
+	}
+
+	algorithm, err := jwtAlgorithm(signer.Public(), request.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidJWTRequest, err)
+	}
+
+	jwk := &jose.JSONWebKey{
+		Key:       signer.Public(),
+		Algorithm: algorithm,
+		Use:       "sig",
+	}
+
+	// An RSA key can be published for RS256 and PS256 alike, so the thumbprint alone doesn't
+	// tell its JWKs apart
+	thumbprint, err := jwkThumbprint(jwk)
+	if err != nil {
+		return nil, err
+	}
+	jwk.KeyID = thumbprint + "." + algorithm
+
+	encodedJWK, err := json.Marshal(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			keys, err := s.jwkSetRepository.GetKeysForSet(ctx, set.ID)
+			if err != nil {
+				return err
+			}
+
+			retiresAt := time.Now().UTC().Add(time.Duration(set.OverlapSeconds) * time.Second)
+			for _, key := range keys {
+				if !key.IsActive() {
+					continue
+				}
+
+				err = s.jwkSetRepository.RetireKey(ctx, key.ID, retiresAt)
+				if err != nil {
+					return err
+				}
+			}
+
+			return s.jwkSetRepository.AddKey(
+				ctx, &daos.JWKSetKey{
+					SetID:     set.ID,
+					KeyID:     request.KeyID,
+					Kid:       jwk.KeyID,
+					Algorithm: algorithm,
+					JWK:       string(encodedJWK),
+				},
+			)
+		},
+	)
+	if errors.Is(err, repositories.ErrDuplicateRecord) {
+		return nil, ErrJWKAlreadyInSet
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.jwkSetRepository.GetKeysForSet(ctx, set.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwkSetResponse(set, keys), nil
+}
+
+// jwtAlgorithm picks the JWS algorithm for a key. The curve fixes it for ECDSA keys, RSA keys
+// may sign with PS256 instead of the default RS256.
+func jwtAlgorithm(publicKey crypto.PublicKey, requested string) (string, error) {
+	var algorithm string
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		algorithm = jose.RS256
+		if requested == jose.PS256 {
+			algorithm = jose.PS256
+		}
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve {
+		case elliptic.P256():
+			algorithm = jose.ES256
+		case elliptic.P384():
+			algorithm = jose.ES384
+		case elliptic.P521():
+			algorithm = jose.ES512
+		default:
+			return "", errors.New("unsupported curve")
+		}
+	case ed25519.PublicKey:
+		algorithm = jose.EdDSA
+	default:
+		return "", errors.New("unsupported key type")
+	}
+
+	if requested != "" && requested != algorithm {
+		return "", fmt.Errorf("algorithm %s can't be used with this key", requested)
+	}
+
+	return algorithm, nil
+}
+
+func (s *JWTServiceImpl) RetireKeyForUser(
+	ctx context.Context,
+	setID string,
+	kid string,
+	userID string,
+) error {
+	set, err := s.getSetForUser(ctx, setID, userID)
+	if err != nil {
+		return err
+	}
+
+	keys, err := s.jwkSetRepository.GetKeysForSet(ctx, set.ID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, key := range keys {
+		if key.Kid != kid {
+			continue
+		}
+
+		if !key.IsPublished(now) {
+			return nil
+		}
+
+		return s.jwkSetRepository.RetireKey(ctx, key.ID, now)
+	}
+
+	return repositories.ErrNoRecord
+}
+
+func (s *JWTServiceImpl) GetJWKS(ctx context.Context, setID string) (*jose.JSONWebKeySet, error) {
+	set, err := s.jwkSetRepository.GetSet(ctx, setID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.jwkSetRepository.GetKeysForSet(ctx, set.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return publishedJWKS(keys)
+}
+
+func (s *JWTServiceImpl) GetJWKSForUser(
+	ctx context.Context,
+	userID string,
+) (*jose.JSONWebKeySet, error) {
+	keys, err := s.jwkSetRepository.GetKeysForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return publishedJWKS(keys)
+}
+
+// publishedJWKS builds the JWKS of the keys that are still published. A key in several sets is
+// listed once.
+func publishedJWKS(keys []*daos.JWKSetKey) (*jose.JSONWebKeySet, error) {
+	now := time.Now().UTC()
+	jwks := &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if !key.IsPublished(now) || seen[key.Kid] {
+			continue
+		}
+		seen[key.Kid] = true
+
+		var jwk jose.JSONWebKey
+		err := json.Unmarshal([]byte(key.JWK), &jwk)
+		if err != nil {
+			return nil, err
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks, nil
+}
+
+func (s *JWTServiceImpl) SignForUser(
+	ctx context.Context,
+	setID string,
+	userID string,
+	request *contracts.SignJWTRequest,
+) (resp *contracts.SignJWTResponse, err error) {
+	defer func() {
+		s.auditService.Record(ctx, userID, AuditJWKSetSignJWT, setID, err)
+	}()
+
+	if request.Claims == nil {
+		return nil, fmt.Errorf("%w: claims are required", ErrInvalidJWTRequest)
+	}
+
+	if request.ExpiresInSeconds < 0 {
+		return nil, fmt.Errorf("%w: expiry can't be negative", ErrInvalidJWTRequest)
+	}
+
+	set, err := s.getSetForUser(ctx, setID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.jwkSetRepository.GetKeysForSet(ctx, set.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var active *daos.JWKSetKey
+	for _, key := range keys {
+		if key.IsActive() {
+			active = key
+			break
+		}
+	}
+
+	if active == nil {
+		return nil, fmt.Errorf("%w: key set has no signing key", ErrInvalidJWTRequest)
+	}
+
+	key, err := s.keyService.GetDecryptedKeyForUser(ctx, active.KeyID, userID, request.KeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	claims := make(map[string]interface{}, len(request.Claims)+2)
+	for name, value := range request.Claims {
+		claims[name] = value
+	}
+
+	if _, ok := claims["iat"]; !ok {
+		claims["iat"] = now.Unix()
+	}
+
+	if request.ExpiresInSeconds > 0 {
+		claims["exp"] = now.Add(time.Duration(request.ExpiresInSeconds) * time.Second).Unix()
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(active.Algorithm), Key: key},
+		new(jose.SignerOptions).WithType("JWT").WithHeader(jose.HeaderKey("kid"), active.Kid),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		return nil, err
+	}
+
+	return &contracts.SignJWTResponse{
+		Token:     token,
+		Kid:       active.Kid,
+		Algorithm: active.Algorithm,
+	}, nil
+}
+
+func (s *JWTServiceImpl) Verify(
+	ctx context.Context,
+	setID string,
+	request *contracts.VerifyJWTRequest,
+) (*contracts.VerifyJWTResponse, error) {
+	set, err := s.jwkSetRepository.GetSet(ctx, setID)
+	if err != nil {
+		return nil, err
+	}
+
+	jws, err := jose.ParseJWS(request.Token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: token can't be parsed", ErrInvalidJWTRequest)
+	}
+
+	if len(jws.Signatures) != 1 {
+		return nil, fmt.Errorf("%w: token must have exactly one signature", ErrInvalidJWTRequest)
+	}
+
+	header := jws.Signatures[0].Protected
+	resp := &contracts.VerifyJWTResponse{
+		Kid:       header.KeyID,
+		Algorithm: header.Algorithm,
+	}
+
+	keys, err := s.jwkSetRepository.GetKeysForSet(ctx, set.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var signingKey *daos.JWKSetKey
+	for _, key := range keys {
+		if key.Kid == header.KeyID && key.IsPublished(now) {
+			signingKey = key
+			break
+		}
+	}
+
+	if signingKey == nil {
+		resp.Error = "key set does not publish a key with this kid"
+		return resp, nil
+	}
+
+	// The algorithm comes from the stored key, never the token, so a token can't pick a weaker
+	// way of being checked
+	if header.Algorithm != signingKey.Algorithm {
+		resp.Error = "token algorithm does not match the key"
+		return resp, nil
+	}
+
+	var jwk jose.JSONWebKey
+	err = json.Unmarshal([]byte(signingKey.JWK), &jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := jws.Verify(jwk.Key)
+	if err != nil {
+		resp.Error = "signature does not verify"
+		return resp, nil
+	}
+
+	var claims map[string]interface{}
+	var registeredClaims jose.Claims
+	if json.Unmarshal(payload, &claims) != nil || json.Unmarshal(payload, &registeredClaims) != nil {
+		resp.Error = "payload is not a valid claims set"
+		return resp, nil
+	}
+
+	resp.Claims = claims
+
+	err = registeredClaims.ValidateWithLeeway(jose.Expected{Time: now}, jwtLeeway)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+
+	resp.Valid = true
+	return resp, nil
+}
+
+func (s *JWTServiceImpl) getSetForUser(
+	ctx context.Context,
+	setID string,
+	userID string,
+) (*daos.JWKSet, error) {
+	set, err := s.jwkSetRepository.GetSet(ctx, setID)
+	if err != nil {
+		return nil, err
+	}
+
+	if set.UserID != userID {
+		return nil, ErrJWKSetUnauthorized
+	}
+
+	return set, nil
+}
+
+func jwkSetResponse(set *daos.JWKSet, keys []*daos.JWKSetKey) *contracts.JWKSetResponse {
+	keyResponses := make([]*contracts.JWKSetKeyResponse, len(keys))
+	for i, key := range keys {
+		keyResponses[i] = key.ToResponse()
+	}
+
+	return &contracts.JWKSetResponse{
+		ID:             set.ID,
+		Name:           set.Name,
+		OverlapSeconds: set.OverlapSeconds,
+		Keys:           keyResponses,
+		Created:        set.Created,
+	}
+}
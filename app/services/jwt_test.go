@@ -0,0 +1,546 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/jose"
+)
+
+const (
+	jwtTestUserID      = "user"
+	jwtTestKeyPassword = "password"
+)
+
+// jwtTestPlatform is the JWT service over memory repositories
+type jwtTestPlatform struct {
+	jwkSetRepository *repositories.JWKSetRepositoryMemory
+	keyService       KeyService
+	jwtService       *JWTServiceImpl
+}
+
+func newJWTTestPlatform() *jwtTestPlatform {
+	certRepository := repositories.NewCertRepositoryMemory()
+	keyRepository := repositories.NewKeyRepositoryMemory()
+	jwkSetRepository := repositories.NewJWKSetRepositoryMemory()
+	transactor := repositories.NewTransactorMemory(certRepository, keyRepository, jwkSetRepository)
+	auditService := NewAuditServiceImpl(repositories.NewAuditRepositoryMemory(), "secret")
+	keyService := NewKeyServiceImpl(keyRepository, certRepository, transactor, auditService)
+
+	return &jwtTestPlatform{
+		jwkSetRepository: jwkSetRepository,
+		keyService:       keyService,
+		jwtService: NewJWTServiceImpl(
+			jwkSetRepository,
+			keyService,
+			transactor,
+			auditService,
+		),
+	}
+}
+
+func (p *jwtTestPlatform) createKey(
+	t *testing.T,
+	algorithm contracts.KeyAlgorithm,
+	params contracts.KeyParameters,
+) string {
+	key, err := p.keyService.CreateKey(
+		context.Background(),
+		jwtTestUserID,
+		"JWT key",
+		algorithm,
+		params,
+		jwtTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	return key.ID
+}
+
+func (p *jwtTestPlatform) createSet(t *testing.T, name string) string {
+	set, err := p.jwtService.CreateSetForUser(
+		context.Background(),
+		jwtTestUserID,
+		&contracts.CreateJWKSetRequest{Name: name, OverlapSeconds: 3600},
+	)
+	require.NoError(t, err)
+
+	return set.ID
+}
+
+func (p *jwtTestPlatform) rotate(t *testing.T, setID string, keyID string, algorithm string) {
+	_, err := p.jwtService.RotateKeyForUser(
+		context.Background(),
+		setID,
+		jwtTestUserID,
+		&contracts.RotateJWKSetKeyRequest{
+			KeyID:       keyID,
+			KeyPassword: jwtTestKeyPassword,
+			Algorithm:   algorithm,
+		},
+	)
+	require.NoError(t, err)
+}
+
+func (p *jwtTestPlatform) sign(
+	t *testing.T,
+	setID string,
+	claims map[string]interface{},
+) *contracts.SignJWTResponse {
+	resp, err := p.jwtService.SignForUser(
+		context.Background(),
+		setID,
+		jwtTestUserID,
+		&contracts.SignJWTRequest{Claims: claims, KeyPassword: jwtTestKeyPassword},
+	)
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestJWTAlgorithm(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	p521, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey := testutils.Key(t, "rsa").Public()
+	p256 := testutils.Key(t, "ecdsa").Public()
+	ed25519Key := testutils.Key(t, "ed25519").Public()
+
+	tests := []struct {
+		name      string
+		publicKey crypto.PublicKey
+		requested string
+		expected  string
+		wantErr   bool
+	}{
+		{name: "rsa default", publicKey: rsaKey, expected: jose.RS256},
+		{name: "rsa RS256", publicKey: rsaKey, requested: jose.RS256, expected: jose.RS256},
+		{name: "rsa PS256", publicKey: rsaKey, requested: jose.PS256, expected: jose.PS256},
+		{name: "rsa ES256", publicKey: rsaKey, requested: jose.ES256, wantErr: true},
+		{name: "rsa HS256", publicKey: rsaKey, requested: jose.HS256, wantErr: true},
+		{name: "P-256", publicKey: p256, expected: jose.ES256},
+		{name: "P-384", publicKey: p384.Public(), expected: jose.ES384},
+		{name: "P-521", publicKey: p521.Public(), expected: jose.ES512},
+		{name: "P-256 ES384", publicKey: p256, requested: jose.ES384, wantErr: true},
+		{name: "P-256 RS256", publicKey: p256, requested: jose.RS256, wantErr: true},
+		{name: "P-224", publicKey: p224.Public(), wantErr: true},
+		{name: "ed25519", publicKey: ed25519Key, expected: jose.EdDSA},
+		{name: "ed25519 ES256", publicKey: ed25519Key, requested: jose.ES256, wantErr: true},
+		{name: "unsupported key", publicKey: []byte("secret"), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				algorithm, err := jwtAlgorithm(test.publicKey, test.requested)
+				if test.wantErr {
+					assert.Error(t, err)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, algorithm)
+			},
+		)
+	}
+}
+
+func TestJWTSignForUser(t *testing.T) {
+	ctx := context.Background()
+	platform := newJWTTestPlatform()
+	setID := platform.createSet(t, "services")
+	emptySetID := platform.createSet(t, "empty")
+	keyID := platform.createKey(t, contracts.ECDSA, contracts.KeyParameters{Curve: contracts.P256})
+	platform.rotate(t, setID, keyID, "")
+
+	tests := []struct {
+		name    string
+		setID   string
+		userID  string
+		request *contracts.SignJWTRequest
+		wantErr error
+	}{
+		{
+			name:   "signs",
+			setID:  setID,
+			userID: jwtTestUserID,
+			request: &contracts.SignJWTRequest{
+				Claims:      map[string]interface{}{"sub": "service"},
+				KeyPassword: jwtTestKeyPassword,
+			},
+		},
+		{
+			name:    "no claims",
+			setID:   setID,
+			userID:  jwtTestUserID,
+			request: &contracts.SignJWTRequest{KeyPassword: jwtTestKeyPassword},
+			wantErr: ErrInvalidJWTRequest,
+		},
+		{
+			name:   "negative expiry",
+			setID:  setID,
+			userID: jwtTestUserID,
+			request: &contracts.SignJWTRequest{
+				Claims:           map[string]interface{}{},
+				KeyPassword:      jwtTestKeyPassword,
+				ExpiresInSeconds: -1,
+			},
+			wantErr: ErrInvalidJWTRequest,
+		},
+		{
+			name:   "other user",
+			setID:  setID,
+			userID: "other",
+			request: &contracts.SignJWTRequest{
+				Claims:      map[string]interface{}{},
+				KeyPassword: jwtTestKeyPassword,
+			},
+			wantErr: ErrJWKSetUnauthorized,
+		},
+		{
+			name:   "no signing key",
+			setID:  emptySetID,
+			userID: jwtTestUserID,
+			request: &contracts.SignJWTRequest{
+				Claims:      map[string]interface{}{},
+				KeyPassword: jwtTestKeyPassword,
+			},
+			wantErr: ErrInvalidJWTRequest,
+		},
+		{
+			name:   "wrong password",
+			setID:  setID,
+			userID: jwtTestUserID,
+			request: &contracts.SignJWTRequest{
+				Claims:      map[string]interface{}{},
+				KeyPassword: "wrong",
+			},
+			wantErr: x509.IncorrectPasswordError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				resp, err := platform.jwtService.SignForUser(ctx, test.setID, test.userID, test.request)
+				if test.wantErr != nil {
+					assert.ErrorIs(t, err, test.wantErr)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, jose.ES256, resp.Algorithm)
+
+				jws, err := jose.ParseJWS(resp.Token)
+				require.NoError(t, err)
+				assert.Equal(t, resp.Kid, jws.Signatures[0].Protected.KeyID)
+
+				verified, err := platform.jwtService.Verify(
+					ctx,
+					test.setID,
+					&contracts.VerifyJWTRequest{Token: resp.Token},
+				)
+				require.NoError(t, err)
+				assert.True(t, verified.Valid, verified.Error)
+				assert.Equal(t, "service", verified.Claims["sub"])
+				assert.Contains(t, verified.Claims, "iat")
+			},
+		)
+	}
+}
+
+func TestJWTVerify(t *testing.T) {
+	ctx := context.Background()
+	platform := newJWTTestPlatform()
+	setID := platform.createSet(t, "services")
+	keyID := platform.createKey(t, contracts.RSA, contracts.KeyParameters{KeySize: 2048})
+	platform.rotate(t, setID, keyID, jose.RS256)
+
+	signed := platform.sign(t, setID, map[string]interface{}{"sub": "service"})
+	expired := platform.sign(
+		t,
+		setID,
+		map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()},
+	)
+	notYetValid := platform.sign(
+		t,
+		setID,
+		map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()},
+	)
+
+	privateKey, err := platform.keyService.GetDecryptedKeyForUser(
+		ctx,
+		keyID,
+		jwtTestUserID,
+		jwtTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	// forge signs the claims of signed with the given algorithm and key under its kid
+	forge := func(t *testing.T, algorithm jose.SignatureAlgorithm, key interface{}) string {
+		signer, err := jose.NewSigner(
+			jose.SigningKey{Algorithm: algorithm, Key: key},
+			new(jose.SignerOptions).WithHeader(jose.HeaderKey("kid"), signed.Kid),
+		)
+		require.NoError(t, err)
+
+		jws, err := signer.Sign([]byte(`{"sub":"service"}`))
+		require.NoError(t, err)
+
+		token, err := jws.CompactSerialize()
+		require.NoError(t, err)
+
+		return token
+	}
+
+	publicKeyDER, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	require.NoError(t, err)
+
+	otherSigner, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: testutils.Key(t, "rsa")},
+		new(jose.SignerOptions).WithHeader(jose.HeaderKey("kid"), "unknown"),
+	)
+	require.NoError(t, err)
+	unknownJWS, err := otherSigner.Sign([]byte(`{}`))
+	require.NoError(t, err)
+	unknownKid, err := unknownJWS.CompactSerialize()
+	require.NoError(t, err)
+
+	parts := strings.Split(signed.Token, ".")
+	tampered := parts[0] + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." +
+		parts[2]
+
+	tests := []struct {
+		name          string
+		token         string
+		expectedError string
+	}{
+		{name: "valid", token: signed.Token},
+		{name: "expired", token: expired.Token, expectedError: "exp"},
+		{name: "not yet valid", token: notYetValid.Token, expectedError: "nbf"},
+		{
+			name:          "tampered",
+			token:         tampered,
+			expectedError: "signature does not verify",
+		},
+		{
+			name:          "unknown kid",
+			token:         unknownKid,
+			expectedError: "key set does not publish a key with this kid",
+		},
+		{
+			name:          "PS256 with the RS256 key",
+			token:         forge(t, jose.PS256, privateKey),
+			expectedError: "token algorithm does not match the key",
+		},
+		{
+			name:          "HS256 keyed with the public key",
+			token:         forge(t, jose.HS256, publicKeyDER),
+			expectedError: "token algorithm does not match the key",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				resp, err := platform.jwtService.Verify(
+					ctx,
+					setID,
+					&contracts.VerifyJWTRequest{Token: test.token},
+				)
+				require.NoError(t, err)
+
+				if test.expectedError != "" {
+					assert.False(t, resp.Valid)
+					assert.Contains(t, resp.Error, test.expectedError)
+					return
+				}
+
+				assert.True(t, resp.Valid, resp.Error)
+				assert.Equal(t, signed.Kid, resp.Kid)
+				assert.Equal(t, jose.RS256, resp.Algorithm)
+			},
+		)
+	}
+
+	_, err = platform.jwtService.Verify(ctx, setID, &contracts.VerifyJWTRequest{Token: "garbage"})
+	assert.ErrorIs(t, err, ErrInvalidJWTRequest)
+}
+
+func TestJWTRotateKeyForUser(t *testing.T) {
+	ctx := context.Background()
+	platform := newJWTTestPlatform()
+	setID := platform.createSet(t, "services")
+	ecdsaKeyID := platform.createKey(
+		t,
+		contracts.ECDSA,
+		contracts.KeyParameters{Curve: contracts.P256},
+	)
+	rsaKeyID := platform.createKey(t, contracts.RSA, contracts.KeyParameters{KeySize: 2048})
+
+	platform.rotate(t, setID, ecdsaKeyID, "")
+	before := platform.sign(t, setID, map[string]interface{}{"sub": "service"})
+	assert.Equal(t, jose.ES256, before.Algorithm)
+
+	errorTests := []struct {
+		name    string
+		userID  string
+		request *contracts.RotateJWKSetKeyRequest
+		wantErr error
+	}{
+		{
+			name:   "algorithm of another key type",
+			userID: jwtTestUserID,
+			request: &contracts.RotateJWKSetKeyRequest{
+				KeyID:       ecdsaKeyID,
+				KeyPassword: jwtTestKeyPassword,
+				Algorithm:   jose.PS256,
+			},
+			wantErr: ErrInvalidJWTRequest,
+		},
+		{
+			name:   "key already in the set",
+			userID: jwtTestUserID,
+			request: &contracts.RotateJWKSetKeyRequest{
+				KeyID:       ecdsaKeyID,
+				KeyPassword: jwtTestKeyPassword,
+			},
+			wantErr: ErrJWKAlreadyInSet,
+		},
+		{
+			name:   "other user",
+			userID: "other",
+			request: &contracts.RotateJWKSetKeyRequest{
+				KeyID:       rsaKeyID,
+				KeyPassword: jwtTestKeyPassword,
+			},
+			wantErr: ErrJWKSetUnauthorized,
+		},
+		{
+			name:   "wrong password",
+			userID: jwtTestUserID,
+			request: &contracts.RotateJWKSetKeyRequest{
+				KeyID:       rsaKeyID,
+				KeyPassword: "wrong",
+			},
+			wantErr: x509.IncorrectPasswordError,
+		},
+	}
+
+	for _, test := range errorTests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				_, err := platform.jwtService.RotateKeyForUser(ctx, setID, test.userID, test.request)
+				assert.ErrorIs(t, err, test.wantErr)
+			},
+		)
+	}
+
+	set, err := platform.jwtService.RotateKeyForUser(
+		ctx,
+		setID,
+		jwtTestUserID,
+		&contracts.RotateJWKSetKeyRequest{
+			KeyID:       rsaKeyID,
+			KeyPassword: jwtTestKeyPassword,
+			Algorithm:   jose.PS256,
+		},
+	)
+	require.NoError(t, err)
+	require.Len(t, set.Keys, 2)
+	assert.True(t, set.Keys[0].Active)
+	assert.False(t, set.Keys[1].Active)
+	require.NotNil(t, set.Keys[1].RetiresAt)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), *set.Keys[1].RetiresAt, time.Minute)
+
+	after := platform.sign(t, setID, map[string]interface{}{"sub": "service"})
+	assert.Equal(t, jose.PS256, after.Algorithm, "only the new key signs")
+	assert.Equal(t, set.Keys[0].Kid, after.Kid)
+	assert.NotEqual(t, before.Kid, after.Kid)
+
+	jws, err := jose.ParseJWS(after.Token)
+	require.NoError(t, err)
+	assert.Equal(t, jose.PS256, jws.Signatures[0].Protected.Algorithm)
+
+	verify := func(t *testing.T, token string) *contracts.VerifyJWTResponse {
+		resp, err := platform.jwtService.Verify(ctx, setID, &contracts.VerifyJWTRequest{Token: token})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	resp := verify(t, before.Token)
+	assert.True(t, resp.Valid, "the retired key verifies within the overlap: %s", resp.Error)
+	resp = verify(t, after.Token)
+	assert.True(t, resp.Valid, resp.Error)
+
+	jwks, err := platform.jwtService.GetJWKS(ctx, setID)
+	require.NoError(t, err)
+	assert.Len(t, jwks.Keys, 2)
+
+	// Move the retired key past its overlap
+	keys, err := platform.jwkSetRepository.GetKeysForSet(ctx, setID)
+	require.NoError(t, err)
+	for _, key := range keys {
+		if key.Kid == before.Kid {
+			err = platform.jwkSetRepository.RetireKey(ctx, key.ID, time.Now().Add(-time.Second))
+			require.NoError(t, err)
+		}
+	}
+
+	resp = verify(t, before.Token)
+	assert.False(t, resp.Valid)
+	assert.Equal(t, "key set does not publish a key with this kid", resp.Error)
+	resp = verify(t, after.Token)
+	assert.True(t, resp.Valid, resp.Error)
+
+	jwks, err = platform.jwtService.GetJWKS(ctx, setID)
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, after.Kid, jwks.Keys[0].KeyID)
+}
+
+func TestJWTGetJWKSForUser(t *testing.T) {
+	ctx := context.Background()
+	platform := newJWTTestPlatform()
+	keyID := platform.createKey(t, contracts.RSA, contracts.KeyParameters{KeySize: 2048})
+
+	rs256SetID := platform.createSet(t, "rs256")
+	ps256SetID := platform.createSet(t, "ps256")
+	duplicateSetID := platform.createSet(t, "duplicate")
+	platform.rotate(t, rs256SetID, keyID, jose.RS256)
+	platform.rotate(t, ps256SetID, keyID, jose.PS256)
+	platform.rotate(t, duplicateSetID, keyID, jose.RS256)
+
+	jwks, err := platform.jwtService.GetJWKSForUser(ctx, jwtTestUserID)
+	require.NoError(t, err)
+	require.Len(t, jwks.Keys, 2, "each kid is published once")
+
+	algorithms := make(map[string]string)
+	for _, jwk := range jwks.Keys {
+		algorithms[jwk.KeyID] = jwk.Algorithm
+	}
+	require.Len(t, algorithms, 2)
+
+	for kid, algorithm := range algorithms {
+		assert.True(t, strings.HasSuffix(kid, "."+algorithm), kid)
+	}
+}
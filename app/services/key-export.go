@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ssh"
+)
+
+type KeyFormat string
+
+const (
+	KeyFormatPKCS8PEM  KeyFormat = "pkcs8-pem"
+	KeyFormatPKCS8DER  KeyFormat = "pkcs8-der"
+	KeyFormatPKCS1     KeyFormat = "pkcs1"
+	KeyFormatSEC1      KeyFormat = "sec1"
+	KeyFormatOpenSSH   KeyFormat = "openssh"
+	KeyFormatJWK       KeyFormat = "jwk"
+	KeyFormatSPKIPEM   KeyFormat = "spki-pem"
+	KeyFormatSSHPublic KeyFormat = "ssh-public"
+	KeyFormatJWKPublic KeyFormat = "jwk-public"
+)
+
+var ErrUnknownKeyFormat = errors.New("unknown key format")
+var ErrKeyFormatUnsupported = errors.New("key algorithm cannot be exported in this format")
+var ErrUnknownKeyCipher = errors.New("unknown key export cipher")
+
+// KeyExport is an encoded key along with how it should be served
+type KeyExport struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+}
+
+var keyExportCiphers = map[string]x509.PEMCipher{
+	"":       x509.PEMCipherAES256,
+	"aes128": x509.PEMCipherAES128,
+	"aes192": x509.PEMCipherAES192,
+	"aes256": x509.PEMCipherAES256,
+	"3des":   x509.PEMCipher3DES,
+}
+
+// ExportKeyForUser decrypts a key with its current password and re-encodes it in the
+// requested format. Private exports are encrypted with exportPassword when one is given,
+// using cipher for the PEM based formats. An empty format returns the stored PEM as is. Public
+// formats are served from the stored public key and don't need the password.
+func (k *KeyServiceImpl) ExportKeyForUser(
+	ctx context.Context,
+	keyId string,
+	userId string,
+	format KeyFormat,
+	password string,
+	exportPassword string,
+	cipher string,
+) (export *KeyExport, err error) {
+	defer func() {
+		k.auditService.Record(ctx, userId, AuditKeyExport, keyId, err)
+	}()
+
+	keyDao, err := k.keyRepository.GetKey(ctx, keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyDao.UserID != userId {
+		return nil, ErrKeyUnauthorized
+	}
+
+	if format == "" {
+		return &KeyExport{
+			Data:        keyDao.Data,
+			ContentType: "application/x-pem-file",
+			Extension:   ".pem",
+		}, nil
+	}
+
+	switch format {
+	case KeyFormatSPKIPEM, KeyFormatSSHPublic, KeyFormatJWKPublic:
+		publicKey, err := storedPublicKey(keyDao, password)
+		if err != nil {
+			return nil, err
+		}
+
+		return exportPublicKey(keyDao, publicKey, format)
+	}
+
+	pemCipher, ok := keyExportCiphers[strings.ToLower(cipher)]
+	if !ok {
+		return nil, ErrUnknownKeyCipher
+	}
+
+	key, err := decryptKey(keyDao, password)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case KeyFormatPKCS8PEM, KeyFormatPKCS8DER:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		block := &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+		if exportPassword != "" {
+			block, err = pemutil.EncryptPKCS8PrivateKey(
+				rand.Reader,
+				der,
+				[]byte(exportPassword),
+				pemCipher,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if format == KeyFormatPKCS8DER {
+			return &KeyExport{
+				Data:        block.Bytes,
+				ContentType: "application/pkcs8",
+				Extension:   ".p8",
+			}, nil
+		}
+
+		return keyExportPEM(block), nil
+	case KeyFormatPKCS1, KeyFormatSEC1:
+		var block *pem.Block
+		switch typed := key.(type) {
+		case *rsa.PrivateKey:
+			if format != KeyFormatPKCS1 {
+				return nil, ErrKeyFormatUnsupported
+			}
+
+			block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(typed)}
+		case *ecdsa.PrivateKey:
+			if format != KeyFormatSEC1 {
+				return nil, ErrKeyFormatUnsupported
+			}
+
+			der, err := x509.MarshalECPrivateKey(typed)
+			if err != nil {
+				return nil, err
+			}
+
+			block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+		default:
+			return nil, ErrKeyFormatUnsupported
+		}
+
+		if exportPassword != "" {
+			// Legacy software expecting PKCS#1/SEC1 only understands RFC 1423 encryption
+			//nolint:staticcheck
+			block, err = x509.EncryptPEMBlock(
+				rand.Reader,
+				block.Type,
+				block.Bytes,
+				[]byte(exportPassword),
+				pemCipher,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return keyExportPEM(block), nil
+	case KeyFormatOpenSSH:
+		opts := []pemutil.Options{pemutil.WithComment(keyDao.Name)}
+		if exportPassword != "" {
+			opts = append(opts, pemutil.WithPassword([]byte(exportPassword)))
+		}
+
+		block, err := pemutil.SerializeOpenSSHPrivateKey(key, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return &KeyExport{
+			Data:        pem.EncodeToMemory(block),
+			ContentType: "application/x-pem-file",
+			Extension:   "",
+		}, nil
+	case KeyFormatJWK:
+		jwk := &jose.JSONWebKey{Key: key}
+		jwk.KeyID, err = jose.Thumbprint(jwk)
+		if err != nil {
+			return nil, err
+		}
+
+		if exportPassword != "" {
+			jwe, err := jose.EncryptJWK(jwk, []byte(exportPassword))
+			if err != nil {
+				return nil, err
+			}
+
+			return &KeyExport{
+				Data:        []byte(jwe.FullSerialize()),
+				ContentType: "application/jose+json",
+				Extension:   ".json",
+			}, nil
+		}
+
+		data, err := json.Marshal(jwk)
+		if err != nil {
+			return nil, err
+		}
+
+		return &KeyExport{
+			Data:        data,
+			ContentType: "application/jwk+json",
+			Extension:   ".json",
+		}, nil
+	}
+
+	return nil, ErrUnknownKeyFormat
+}
+
+// storedPublicKey parses the public key kept alongside a key, decrypting the private key for
+// keys stored before the public half was kept
+func storedPublicKey(keyDao *daos.Key, password string) (crypto.PublicKey, error) {
+	if len(keyDao.PublicKey) > 0 {
+		return x509.ParsePKIXPublicKey(keyDao.PublicKey)
+	}
+
+	key, err := decryptKey(keyDao, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Public(), nil
+}
+
+func exportPublicKey(
+	keyDao *daos.Key,
+	publicKey crypto.PublicKey,
+	format KeyFormat,
+) (*KeyExport, error) {
+	switch format {
+	case KeyFormatSPKIPEM:
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return keyExportPEM(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+	case KeyFormatSSHPublic:
+		sshKey, err := ssh.NewPublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// MarshalAuthorizedKey terminates the line, the comment goes before the newline
+		line := strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(sshKey)), "\n")
+		if keyDao.Name != "" {
+			line += " " + keyDao.Name
+		}
+
+		return &KeyExport{
+			Data:        []byte(line + "\n"),
+			ContentType: "text/plain",
+			Extension:   ".pub",
+		}, nil
+	case KeyFormatJWKPublic:
+		jwk := &jose.JSONWebKey{Key: publicKey}
+		keyID, err := jose.Thumbprint(jwk)
+		if err != nil {
+			return nil, err
+		}
+		jwk.KeyID = keyID
+
+		data, err := json.Marshal(jwk)
+		if err != nil {
+			return nil, err
+		}
+
+		return &KeyExport{
+			Data:        data,
+			ContentType: "application/jwk+json",
+			Extension:   ".json",
+		}, nil
+	}
+
+	return nil, ErrUnknownKeyFormat
+}
+
+func keyExportPEM(block *pem.Block) *KeyExport {
+	return &KeyExport{
+		Data:        pem.EncodeToMemory(block),
+		ContentType: "application/x-pem-file",
+		Extension:   ".pem",
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestExportPublicKeyWithoutPassword(t *testing.T) {
+	ctx := context.Background()
+	keyRepository := repositories.NewKeyRepositoryMemory()
+	certRepository := repositories.NewCertRepositoryMemory()
+	keyService := NewKeyServiceImpl(
+		keyRepository,
+		certRepository,
+		repositories.NewTransactorMemory(keyRepository, certRepository),
+		NewAuditServiceImpl(repositories.NewAuditRepositoryMemory(), ""),
+	)
+
+	key := testutils.Key(t, "ecdsa")
+	stored, err := keyService.ImportPrivateKey(ctx, "user", "Exported", key, "password")
+	require.NoError(t, err)
+
+	export, err := keyService.ExportKeyForUser(
+		ctx,
+		stored.ID,
+		"user",
+		KeyFormatSPKIPEM,
+		"",
+		"",
+		"",
+	)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(export.Data)
+	require.NotNil(t, block)
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), publicKey)
+
+	export, err = keyService.ExportKeyForUser(
+		ctx,
+		stored.ID,
+		"user",
+		KeyFormatSSHPublic,
+		"",
+		"",
+		"",
+	)
+	require.NoError(t, err)
+
+	sshKey, comment, _, _, err := ssh.ParseAuthorizedKey(export.Data)
+	require.NoError(t, err)
+	assert.Equal(t, "Exported", comment)
+	assert.Equal(t, "ecdsa-sha2-nistp256", sshKey.Type())
+
+	export, err = keyService.ExportKeyForUser(
+		ctx,
+		stored.ID,
+		"user",
+		KeyFormatJWKPublic,
+		"",
+		"",
+		"",
+	)
+	require.NoError(t, err)
+	assert.Contains(t, string(export.Data), `"kid":`)
+	assert.NotContains(t, string(export.Data), `"d":`)
+
+	_, err = keyService.ExportKeyForUser(ctx, stored.ID, "user", KeyFormatPKCS8PEM, "", "", "")
+	assert.ErrorIs(t, err, x509.IncorrectPasswordError, "private formats still need it")
+
+	_, err = keyService.ExportKeyForUser(ctx, stored.ID, "other", KeyFormatSPKIPEM, "", "", "")
+	assert.ErrorIs(t, err, ErrKeyUnauthorized)
+
+	// Keys stored before the public half was kept fall back to decrypting
+	legacy, err := keyRepository.GetKey(ctx, stored.ID)
+	require.NoError(t, err)
+	legacyKey, err := keyRepository.CreateKey(
+		ctx,
+		"user",
+		legacy.Data,
+		nil,
+		legacy.Algorithm,
+		0,
+		legacy.Curve,
+		"Legacy",
+	)
+	require.NoError(t, err)
+
+	_, err = keyService.ExportKeyForUser(ctx, legacyKey.ID, "user", KeyFormatSPKIPEM, "", "", "")
+	assert.ErrorIs(t, err, x509.IncorrectPasswordError)
+
+	_, err = keyService.ExportKeyForUser(
+		ctx,
+		legacyKey.ID,
+		"user",
+		KeyFormatSPKIPEM,
+		"password",
+		"",
+		"",
+	)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrInvalidKeyData = errors.New("invalid private key data")
+
+// ImportKey parses a PEM encoded PKCS#1, PKCS#8, SEC1 or OpenSSH private key, decrypting it
+// with sourcePassword when it is encrypted, and stores it re-encrypted under password
+func (k *KeyServiceImpl) ImportKey(
+	ctx context.Context,
+	userId string,
+	name string,
+	data []byte,
+	sourcePassword string,
+	password string,
+) (resp *contracts.KeyLightResponse, err error) {
+	defer func() {
+		k.auditService.Record(ctx, userId, AuditKeyImport, keyLightResponseID(resp), err)
+	}()
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: not a PEM encoded key", ErrInvalidKeyData)
+	}
+
+	if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return nil, fmt.Errorf("%w: unexpected PEM block %s", ErrInvalidKeyData, block.Type)
+	}
+
+	encrypted := block.Headers["Proc-Type"] == "4,ENCRYPTED" ||
+		block.Type == "ENCRYPTED PRIVATE KEY"
+	if block.Type == "OPENSSH PRIVATE KEY" {
+		// OpenSSH keys record their encryption inside the block rather than in PEM headers
+		var missing *ssh.PassphraseMissingError
+		_, err := ssh.ParseRawPrivateKey(data)
+		encrypted = errors.As(err, &missing)
+	}
+	if encrypted && sourcePassword == "" {
+		return nil, x509.IncorrectPasswordError
+	}
+
+	opts := []pemutil.Options{pemutil.WithFirstBlock()}
+	if sourcePassword != "" {
+		opts = append(opts, pemutil.WithPassword([]byte(sourcePassword)))
+	}
+
+	key, err := pemutil.Parse(data, opts...)
+	if err != nil {
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			return nil, x509.IncorrectPasswordError
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrInvalidKeyData, err.Error())
+	}
+
+	return k.importPrivateKey(ctx, userId, name, key, password)
+}
+
+// ImportPrivateKey stores an already parsed private key, detecting its algorithm and parameters
+func (k *KeyServiceImpl) ImportPrivateKey(
+	ctx context.Context,
+	userId string,
+	name string,
+	key crypto.PrivateKey,
+	password string,
+) (resp *contracts.KeyLightResponse, err error) {
+	defer func() {
+		k.auditService.Record(ctx, userId, AuditKeyImport, keyLightResponseID(resp), err)
+	}()
+
+	return k.importPrivateKey(ctx, userId, name, key, password)
+}
+
+func (k *KeyServiceImpl) importPrivateKey(
+	ctx context.Context,
+	userId string,
+	name string,
+	key crypto.PrivateKey,
+	password string,
+) (*contracts.KeyLightResponse, error) {
+	algorithm, params, err := keyAlgorithmParameters(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dao, err := k.storeKey(ctx, userId, name, key, algorithm, params, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyLightResponse(dao), nil
+}
+
+func keyAlgorithmParameters(
+	key crypto.PrivateKey,
+) (contracts.KeyAlgorithm, contracts.KeyParameters, error) {
+	switch typed := key.(type) {
+	case *rsa.PrivateKey:
+		err := typed.Validate()
+		if err != nil {
+			return contracts.Unknown, contracts.KeyParameters{}, fmt.Errorf(
+				"%w: %s",
+				ErrInvalidKeyData,
+				err.Error(),
+			)
+		}
+
+		return contracts.RSA, contracts.KeyParameters{KeySize: typed.N.BitLen()}, nil
+	case *ecdsa.PrivateKey:
+		for curveName, curve := range keyCurves {
+			if typed.Curve == curve {
+				return contracts.ECDSA, contracts.KeyParameters{Curve: curveName}, nil
+			}
+		}
+
+		return contracts.Unknown, contracts.KeyParameters{}, fmt.Errorf(
+			"%w: unsupported ECDSA curve %s",
+			ErrInvalidKeyParameters,
+			typed.Curve.Params().Name,
+		)
+	case ed25519.PrivateKey:
+		return contracts.ED25519, contracts.KeyParameters{}, nil
+	default:
+		return contracts.Unknown, contracts.KeyParameters{}, ErrUnsupportedKeyAlgorithm
+	}
+}
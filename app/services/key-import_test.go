@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.step.sm/crypto/pemutil"
+)
+
+func TestImportKeyPasswords(t *testing.T) {
+	ctx := context.Background()
+	keyRepository := repositories.NewKeyRepositoryMemory()
+	certRepository := repositories.NewCertRepositoryMemory()
+	keyService := NewKeyServiceImpl(
+		keyRepository,
+		certRepository,
+		repositories.NewTransactorMemory(keyRepository, certRepository),
+		NewAuditServiceImpl(repositories.NewAuditRepositoryMemory(), ""),
+	)
+
+	key := testutils.Key(t, "ed25519")
+	encode := func(block *pem.Block, err error) []byte {
+		require.NoError(t, err)
+		return pem.EncodeToMemory(block)
+	}
+
+	pkcs8 := encode(
+		pemutil.Serialize(key, pemutil.WithPKCS8(true), pemutil.WithPassword([]byte("source"))),
+	)
+	openSSH := encode(
+		pemutil.SerializeOpenSSHPrivateKey(key, pemutil.WithPassword([]byte("source"))),
+	)
+	plainOpenSSH := encode(pemutil.SerializeOpenSSHPrivateKey(key))
+
+	tests := []struct {
+		name           string
+		data           []byte
+		sourcePassword string
+		wantErr        error
+	}{
+		{name: "pkcs8", data: pkcs8, sourcePassword: "source"},
+		{name: "pkcs8 without password", data: pkcs8, wantErr: x509.IncorrectPasswordError},
+		{
+			name:           "pkcs8 wrong password",
+			data:           pkcs8,
+			sourcePassword: "wrong",
+			wantErr:        x509.IncorrectPasswordError,
+		},
+		{name: "openssh", data: openSSH, sourcePassword: "source"},
+		{name: "openssh without password", data: openSSH, wantErr: x509.IncorrectPasswordError},
+		{
+			name:           "openssh wrong password",
+			data:           openSSH,
+			sourcePassword: "wrong",
+			wantErr:        x509.IncorrectPasswordError,
+		},
+		{name: "unencrypted openssh", data: plainOpenSSH},
+		{name: "garbage", data: []byte("not a key"), wantErr: ErrInvalidKeyData},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				resp, err := keyService.ImportKey(
+					ctx,
+					"user",
+					test.name,
+					test.data,
+					test.sourcePassword,
+					"password",
+				)
+				if test.wantErr != nil {
+					assert.ErrorIs(t, err, test.wantErr)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, contracts.ED25519.String(), resp.Algorithm)
+
+				imported, err := keyService.GetDecryptedKeyForUser(ctx, resp.ID, "user", "password")
+				require.NoError(t, err)
+				assert.Equal(t, key, imported)
+			},
+		)
+	}
+}
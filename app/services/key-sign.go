@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+var ErrInvalidSignDigestRequest = errors.New("invalid digest signing request")
+
+// SignDigestForUser signs a digest the caller computed with one of the user's keys, so
+// applications can use platform keys for arbitrary signatures without ever holding them
+func (k *KeyServiceImpl) SignDigestForUser(
+	ctx context.Context,
+	keyId string,
+	userId string,
+	request *contracts.SignDigestRequest,
+) (resp *contracts.SignDigestResponse, err error) {
+	defer func() {
+		k.auditService.Record(ctx, userId, AuditKeySign, keyId, err)
+	}()
+
+	digest, err := base64.StdEncoding.DecodeString(request.Digest)
+	if err != nil || len(digest) == 0 {
+		return nil, fmt.Errorf("%w: digest must be base64 encoded", ErrInvalidSignDigestRequest)
+	}
+
+	var hash crypto.Hash
+	if request.Hash != "" {
+		var ok bool
+		hash, ok = signatureDigestAlgorithms[request.Hash]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown hash %s", ErrInvalidSignDigestRequest, request.Hash)
+		}
+
+		if len(digest) != hash.Size() {
+			return nil, fmt.Errorf(
+				"%w: %s digests are %d bytes",
+				ErrInvalidSignDigestRequest,
+				request.Hash,
+				hash.Size(),
+			)
+		}
+	}
+
+	key, err := k.GetDecryptedKeyForUser(ctx, keyId, userId, request.KeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key is not capable of signing")
+	}
+
+	opts, err := signDigestOptions(signer.Public(), hash, request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignDigestRequest, err)
+	}
+
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contracts.SignDigestResponse{
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}, nil
+}
+
+// signDigestOptions checks the hash and padding suit the key, and turns them into the options
+// its crypto.Signer expects
+func signDigestOptions(
+	publicKey crypto.PublicKey,
+	hash crypto.Hash,
+	request *contracts.SignDigestRequest,
+) (crypto.SignerOpts, error) {
+	if request.SaltLength != 0 && request.Padding != "pss" {
+		return nil, errors.New("salt length only applies to pss padding")
+	}
+
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		if hash == 0 {
+			return nil, errors.New("hash is required for RSA keys")
+		}
+
+		switch request.Padding {
+		case "", "pkcs1v15":
+			return hash, nil
+		case "pss":
+			// The encoded message leaves room for the hash and two bytes besides the salt
+			maxSaltLength := (publicKey.N.BitLen()+6)/8 - hash.Size() - 2
+			if request.SaltLength < 0 || request.SaltLength > maxSaltLength {
+				return nil, fmt.Errorf("salt length must be between 0 and %d", maxSaltLength)
+			}
+
+			saltLength := request.SaltLength
+			if saltLength == 0 {
+				saltLength = rsa.PSSSaltLengthEqualsHash
+			}
+
+			return &rsa.PSSOptions{SaltLength: saltLength, Hash: hash}, nil
+		default:
+			return nil, fmt.Errorf("unknown padding %s", request.Padding)
+		}
+	case *ecdsa.PublicKey:
+		if hash == 0 {
+			return nil, errors.New("hash is required for ECDSA keys")
+		}
+
+		if request.Padding != "" {
+			return nil, errors.New("padding only applies to RSA keys")
+		}
+
+		return hash, nil
+	case ed25519.PublicKey:
+		if request.Padding != "" {
+			return nil, errors.New("padding only applies to RSA keys")
+		}
+
+		switch hash {
+		case 0:
+			return crypto.Hash(0), nil
+		case crypto.SHA512:
+			return &ed25519.Options{Hash: crypto.SHA512}, nil
+		default:
+			return nil, errors.New("Ed25519 keys sign messages or sha512 digests")
+		}
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+}
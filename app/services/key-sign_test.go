@@ -0,0 +1,157 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignDigestOptions(t *testing.T) {
+	rsaKey := testutils.Key(t, "rsa").Public()
+	ecdsaKey := testutils.Key(t, "ecdsa").Public()
+	ed25519Key := testutils.Key(t, "ed25519").Public()
+
+	// A 2048 bit modulus leaves 256 - 32 - 2 bytes for a SHA-256 PSS salt
+	const maxSaltLength = 222
+
+	tests := []struct {
+		name      string
+		publicKey crypto.PublicKey
+		hash      crypto.Hash
+		request   contracts.SignDigestRequest
+		expected  crypto.SignerOpts
+		wantErr   string
+	}{
+		{
+			name:      "RSA default padding",
+			publicKey: rsaKey,
+			hash:      crypto.SHA256,
+			expected:  crypto.SHA256,
+		},
+		{
+			name:      "RSA PKCS#1",
+			publicKey: rsaKey,
+			hash:      crypto.SHA384,
+			request:   contracts.SignDigestRequest{Padding: "pkcs1v15"},
+			expected:  crypto.SHA384,
+		},
+		{
+			name:      "RSA PSS salt as long as the hash",
+			publicKey: rsaKey,
+			hash:      crypto.SHA256,
+			request:   contracts.SignDigestRequest{Padding: "pss"},
+			expected: &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthEqualsHash,
+				Hash:       crypto.SHA256,
+			},
+		},
+		{
+			name:      "RSA PSS largest salt",
+			publicKey: rsaKey,
+			hash:      crypto.SHA256,
+			request:   contracts.SignDigestRequest{Padding: "pss", SaltLength: maxSaltLength},
+			expected:  &rsa.PSSOptions{SaltLength: maxSaltLength, Hash: crypto.SHA256},
+		},
+		{
+			name:      "RSA PSS salt too long",
+			publicKey: rsaKey,
+			hash:      crypto.SHA256,
+			request:   contracts.SignDigestRequest{Padding: "pss", SaltLength: maxSaltLength + 1},
+			wantErr:   "salt length must be between 0 and 222",
+		},
+		{
+			name:      "RSA PSS negative salt",
+			publicKey: rsaKey,
+			hash:      crypto.SHA256,
+			request:   contracts.SignDigestRequest{Padding: "pss", SaltLength: -1},
+			wantErr:   "salt length must be between",
+		},
+		{
+			name:      "RSA salt without PSS",
+			publicKey: rsaKey,
+			hash:      crypto.SHA256,
+			request:   contracts.SignDigestRequest{SaltLength: 20},
+			wantErr:   "salt length only applies to pss padding",
+		},
+		{
+			name:      "RSA unknown padding",
+			publicKey: rsaKey,
+			hash:      crypto.SHA256,
+			request:   contracts.SignDigestRequest{Padding: "oaep"},
+			wantErr:   "unknown padding oaep",
+		},
+		{
+			name:      "RSA without a hash",
+			publicKey: rsaKey,
+			wantErr:   "hash is required for RSA keys",
+		},
+		{
+			name:      "ECDSA",
+			publicKey: ecdsaKey,
+			hash:      crypto.SHA384,
+			expected:  crypto.SHA384,
+		},
+		{
+			name:      "ECDSA without a hash",
+			publicKey: ecdsaKey,
+			wantErr:   "hash is required for ECDSA keys",
+		},
+		{
+			name:      "ECDSA padding",
+			publicKey: ecdsaKey,
+			hash:      crypto.SHA256,
+			request:   contracts.SignDigestRequest{Padding: "pss"},
+			wantErr:   "padding only applies to RSA keys",
+		},
+		{
+			name:      "Ed25519 message",
+			publicKey: ed25519Key,
+			expected:  crypto.Hash(0),
+		},
+		{
+			name:      "Ed25519ph",
+			publicKey: ed25519Key,
+			hash:      crypto.SHA512,
+			expected:  &ed25519.Options{Hash: crypto.SHA512},
+		},
+		{
+			name:      "Ed25519 SHA-256 digest",
+			publicKey: ed25519Key,
+			hash:      crypto.SHA256,
+			wantErr:   "Ed25519 keys sign messages or sha512 digests",
+		},
+		{
+			name:      "Ed25519 padding",
+			publicKey: ed25519Key,
+			request:   contracts.SignDigestRequest{Padding: "pkcs1v15"},
+			wantErr:   "padding only applies to RSA keys",
+		},
+		{
+			name:      "unsupported key",
+			publicKey: struct{}{},
+			hash:      crypto.SHA256,
+			wantErr:   "unsupported key type",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				opts, err := signDigestOptions(test.publicKey, test.hash, &test.request)
+				if test.wantErr != "" {
+					assert.ErrorContains(t, err, test.wantErr)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, opts)
+			},
+		)
+	}
+}
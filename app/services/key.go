@@ -10,15 +10,27 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"fmt"
 
 	"github.com/fapiko/john-hancock-platform/app/contracts"
 	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
 	"go.step.sm/crypto/pemutil"
 	"golang.org/x/crypto/ed25519"
 )
 
 var _ KeyService = (*KeyServiceImpl)(nil)
 
+var ErrKeyUnauthorized = errors.New("key does not belong to user")
+var ErrUnsupportedKeyAlgorithm = errors.New("unsupported algorithm")
+var ErrInvalidKeyParameters = errors.New("invalid key parameters")
+
+var keyCurves = map[contracts.KeyCurve]elliptic.Curve{
+	contracts.P256: elliptic.P256(),
+	contracts.P384: elliptic.P384(),
+	contracts.P521: elliptic.P521(),
+}
+
 // PrivateKey is a  custom interface - all crypto packages implement this interface, but
 // crypto.PrivateKey type is any for backwards compat
 type PrivateKey interface {
@@ -32,6 +44,7 @@ type KeyService interface {
 		userId string,
 		name string,
 		algorithm contracts.KeyAlgorithm,
+		params contracts.KeyParameters,
 		password string,
 	) (
 		*contracts.KeyLightResponse,
@@ -47,15 +60,62 @@ type KeyService interface {
 		ctx context.Context,
 		userId string,
 	) ([]*contracts.KeyLightResponse, error)
+	// DeleteKeyForUser deletes a key, leaving the certificates that used it without one
+	DeleteKeyForUser(
+		ctx context.Context,
+		keyId string,
+		userId string,
+	) error
+	ImportKey(
+		ctx context.Context,
+		userId string,
+		name string,
+		data []byte,
+		sourcePassword string,
+		password string,
+	) (*contracts.KeyLightResponse, error)
+	ImportPrivateKey(
+		ctx context.Context,
+		userId string,
+		name string,
+		key crypto.PrivateKey,
+		password string,
+	) (*contracts.KeyLightResponse, error)
+	ExportKeyForUser(
+		ctx context.Context,
+		keyId string,
+		userId string,
+		format KeyFormat,
+		password string,
+		exportPassword string,
+		cipher string,
+	) (*KeyExport, error)
+	SignDigestForUser(
+		ctx context.Context,
+		keyId string,
+		userId string,
+		request *contracts.SignDigestRequest,
+	) (*contracts.SignDigestResponse, error)
 }
 
 type KeyServiceImpl struct {
-	keyRepository repositories.KeyRepository
+	keyRepository  repositories.KeyRepository
+	certRepository repositories.CertRepository
+	transactor     repositories.Transactor
+	auditService   AuditService
 }
 
-func NewKeyServiceImpl(keyRepository repositories.KeyRepository) *KeyServiceImpl {
+func NewKeyServiceImpl(
+	keyRepository repositories.KeyRepository,
+	certRepository repositories.CertRepository,
+	transactor repositories.Transactor,
+	auditService AuditService,
+) *KeyServiceImpl {
 	return &KeyServiceImpl{
-		keyRepository: keyRepository,
+		keyRepository:  keyRepository,
+		certRepository: certRepository,
+		transactor:     transactor,
+		auditService:   auditService,
 	}
 }
 
@@ -64,31 +124,67 @@ func (k *KeyServiceImpl) CreateKey(
 	userId string,
 	name string,
 	algorithm contracts.KeyAlgorithm,
+	params contracts.KeyParameters,
 	password string,
-) (*contracts.KeyLightResponse, error) {
+) (resp *contracts.KeyLightResponse, err error) {
+	defer func() {
+		k.auditService.Record(ctx, userId, AuditKeyCreate, keyLightResponseID(resp), err)
+	}()
+
 	var privKey any
-	var err error
+
+	params, err = keyParameters(algorithm, params)
+	if err != nil {
+		return nil, err
+	}
 
 	switch algorithm {
 	case contracts.RSA:
-		privKey, err = rsa.GenerateKey(rand.Reader, 4096)
+		privKey, err = rsa.GenerateKey(rand.Reader, params.KeySize)
 	case contracts.ECDSA:
-		privKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+		privKey, err = ecdsa.GenerateKey(keyCurves[params.Curve], rand.Reader)
 	case contracts.ED25519:
 		_, privKey, err = ed25519.GenerateKey(rand.Reader)
-	default:
-		return nil, errors.New("unsupported algorithm")
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	dao, err := k.storeKey(ctx, userId, name, privKey, algorithm, params, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyLightResponse(dao), nil
+}
+
+// storeKey persists privKey as PKCS#8 PEM, encrypted with AES-256 when password is set, and its
+// public key in the clear so public exports don't need the password
+func (k *KeyServiceImpl) storeKey(
+	ctx context.Context,
+	userId string,
+	name string,
+	privKey any,
+	algorithm contracts.KeyAlgorithm,
+	params contracts.KeyParameters,
+	password string,
+) (*daos.Key, error) {
 	data, err := x509.MarshalPKCS8PrivateKey(privKey)
 	if err != nil {
 		return nil, err
 	}
 
+	signer, ok := privKey.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKeyAlgorithm
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
 	var pemData []byte
 	if password == "" {
 		pemData = pem.EncodeToMemory(
@@ -111,17 +207,85 @@ func (k *KeyServiceImpl) CreateKey(
 		pemData = pem.EncodeToMemory(encrypted)
 	}
 
-	dao, err := k.keyRepository.CreateKey(ctx, userId, pemData, algorithm.String(), name)
-	if err != nil {
-		return nil, err
+	return k.keyRepository.CreateKey(
+		ctx,
+		userId,
+		pemData,
+		publicKey,
+		algorithm.String(),
+		params.KeySize,
+		string(params.Curve),
+		name,
+	)
+}
+
+// keyParameters validates the parameters requested for algorithm and fills in the defaults
+func keyParameters(
+	algorithm contracts.KeyAlgorithm,
+	params contracts.KeyParameters,
+) (contracts.KeyParameters, error) {
+	switch algorithm {
+	case contracts.RSA:
+		if params.Curve != "" {
+			return params, fmt.Errorf("%w: RSA keys do not take a curve", ErrInvalidKeyParameters)
+		}
+
+		if params.KeySize == 0 {
+			params.KeySize = contracts.DefaultRSAKeySize
+		}
+
+		for _, size := range contracts.RSAKeySizes {
+			if params.KeySize == size {
+				return params, nil
+			}
+		}
+
+		return params, fmt.Errorf(
+			"%w: unsupported RSA key size %d",
+			ErrInvalidKeyParameters,
+			params.KeySize,
+		)
+	case contracts.ECDSA:
+		if params.KeySize != 0 {
+			return params, fmt.Errorf("%w: ECDSA keys do not take a key size", ErrInvalidKeyParameters)
+		}
+
+		if params.Curve == "" {
+			params.Curve = contracts.DefaultECDSACurve
+		}
+
+		if _, ok := keyCurves[params.Curve]; !ok {
+			return params, fmt.Errorf(
+				"%w: unsupported ECDSA curve %s",
+				ErrInvalidKeyParameters,
+				params.Curve,
+			)
+		}
+
+		return params, nil
+	case contracts.ED25519:
+		if params.KeySize != 0 || params.Curve != "" {
+			return params, fmt.Errorf(
+				"%w: ED25519 keys do not take a key size or curve",
+				ErrInvalidKeyParameters,
+			)
+		}
+
+		return params, nil
+	default:
+		return params, ErrUnsupportedKeyAlgorithm
 	}
+}
 
+func keyLightResponse(dao *daos.Key) *contracts.KeyLightResponse {
 	return &contracts.KeyLightResponse{
 		ID:        dao.ID,
 		Name:      dao.Name,
 		Created:   dao.Created,
 		Algorithm: dao.Algorithm,
-	}, nil
+		KeySize:   dao.KeySize,
+		Curve:     dao.Curve,
+	}
 }
 
 func (k *KeyServiceImpl) GetDecryptedKeyForUser(
@@ -129,19 +293,76 @@ func (k *KeyServiceImpl) GetDecryptedKeyForUser(
 	keyId string,
 	userId string,
 	password string,
-) (PrivateKey, error) {
+) (key PrivateKey, err error) {
+	defer func() {
+		k.auditService.Record(ctx, userId, AuditKeyDecrypt, keyId, err)
+	}()
+
 	keyDao, err := k.keyRepository.GetKey(ctx, keyId)
 	if err != nil {
 		return nil, err
 	}
 
 	if keyDao.UserID != userId {
-		return nil, errors.New("key does not belong to user")
+		return nil, ErrKeyUnauthorized
+	}
+
+	return decryptKey(keyDao, password)
+}
+
+func (k *KeyServiceImpl) DeleteKeyForUser(
+	ctx context.Context,
+	keyId string,
+	userId string,
+) (err error) {
+	defer func() {
+		k.auditService.Record(ctx, userId, AuditKeyDelete, keyId, err)
+	}()
+
+	keyDao, err := k.keyRepository.GetKey(ctx, keyId)
+	if err != nil {
+		return err
+	}
+
+	if keyDao.UserID != userId {
+		return ErrKeyUnauthorized
+	}
+
+	return k.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			err := k.certRepository.UnlinkKey(ctx, keyId)
+			if err != nil {
+				return err
+			}
+
+			return k.keyRepository.DeleteKey(ctx, keyId)
+		},
+	)
+}
+
+// keyLightResponseID is the ID of a created key, or nothing when creating it failed
+func keyLightResponseID(resp *contracts.KeyLightResponse) string {
+	if resp == nil {
+		return ""
 	}
 
+	return resp.ID
+}
+
+// decryptKey parses the stored PKCS#8 PEM of a key, decrypting it with password when set
+func decryptKey(keyDao *daos.Key, password string) (PrivateKey, error) {
 	var data []byte
+	var err error
 	pemBlock, _ := pem.Decode(keyDao.Data)
+	if pemBlock == nil {
+		return nil, errors.New("stored key is not valid PEM")
+	}
+
 	if password == "" {
+		if pemBlock.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, x509.IncorrectPasswordError
+		}
+
 		data = pemBlock.Bytes
 	} else {
 
@@ -153,6 +374,11 @@ func (k *KeyServiceImpl) GetDecryptedKeyForUser(
 
 	keyInt, err := x509.ParsePKCS8PrivateKey(data)
 	if err != nil {
+		// A wrong password occasionally still yields valid padding, leaving garbage to parse
+		if password != "" {
+			return nil, x509.IncorrectPasswordError
+		}
+
 		return nil, err
 	}
 
@@ -175,12 +401,7 @@ func (k *KeyServiceImpl) GetKeysForUser(
 
 	keys := make([]*contracts.KeyLightResponse, len(daos))
 	for i, dao := range daos {
-		keys[i] = &contracts.KeyLightResponse{
-			ID:        dao.ID,
-			Name:      dao.Name,
-			Created:   dao.Created,
-			Algorithm: dao.Algorithm,
-		}
+		keys[i] = keyLightResponse(dao)
 	}
 
 	return keys, nil
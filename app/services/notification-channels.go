@@ -0,0 +1,260 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+)
+
+const (
+	// webhookTimestampHeader carries the unix time the payload was signed at
+	webhookTimestampHeader = "X-John-Hancock-Timestamp"
+	// webhookSignatureHeader carries sha256= followed by the hex HMAC-SHA256 of the timestamp,
+	// a period and the body, keyed with the rule's secret
+	webhookSignatureHeader = "X-John-Hancock-Signature"
+)
+
+// NotificationChannel delivers expiry notifications to one kind of target. Implementations are
+// registered with the NotificationService by channel name.
+type NotificationChannel interface {
+	// ValidateTarget checks a rule's target and secret before the rule is stored
+	ValidateTarget(target string, secret string) error
+	Send(
+		ctx context.Context,
+		target string,
+		secret []byte,
+		notification *contracts.ExpiryNotification,
+	) error
+}
+
+// ErrWebhookAddressForbidden is returned for webhooks resolving to a loopback, link-local or
+// private address outside the allowed networks
+var ErrWebhookAddressForbidden = errors.New("webhook address is not allowed")
+
+// WebhookChannel POSTs signed notifications to user supplied URLs. Since any user can pick the
+// target, it refuses to connect to internal addresses unless an operator allowed their network.
+type WebhookChannel struct {
+	client          *http.Client
+	allowedNetworks []*net.IPNet
+}
+
+// NewWebhookChannel takes the CIDR ranges webhooks may reach even though they are internal
+func NewWebhookChannel(allowedNetworks []string) (*WebhookChannel, error) {
+	c := &WebhookChannel{}
+
+	for _, network := range allowedNetworks {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(network))
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed webhook network %q: %w", network, err)
+		}
+
+		c.allowedNetworks = append(c.allowedNetworks, ipNet)
+	}
+
+	// Checking the address being dialed, rather than the one the URL resolved to up front, also
+	// covers DNS rebinding
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !c.addressAllowed(ip) {
+				return fmt.Errorf("%w: %s", ErrWebhookAddressForbidden, host)
+			}
+
+			return nil
+		},
+	}
+
+	c.client = &http.Client{
+		Timeout: 10 * time.Second,
+		// No proxy either, it would dial on the webhook's behalf past the check
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		// A redirect is treated as a failed delivery rather than followed somewhere else
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return c, nil
+}
+
+func (c *WebhookChannel) addressAllowed(ip net.IP) bool {
+	for _, network := range c.allowedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+func (c *WebhookChannel) ValidateTarget(target string, secret string) error {
+	endpoint, err := url.Parse(target)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") ||
+		endpoint.Host == "" {
+		return fmt.Errorf("%w: target must be an http or https URL", ErrInvalidNotificationRule)
+	}
+
+	// Host names are checked once they resolve, when the webhook is sent
+	if ip := net.ParseIP(endpoint.Hostname()); ip != nil && !c.addressAllowed(ip) {
+		return fmt.Errorf(
+			"%w: target must not be a loopback, link-local or private address",
+			ErrInvalidNotificationRule,
+		)
+	}
+
+	if secret == "" {
+		return fmt.Errorf("%w: webhooks need a secret to sign with", ErrInvalidNotificationRule)
+	}
+
+	return nil
+}
+
+func (c *WebhookChannel) Send(
+	ctx context.Context,
+	target string,
+	secret []byte,
+	notification *contracts.ExpiryNotification,
+) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	timestamp := fmt.Sprint(time.Now().Unix())
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, target)
+	}
+
+	return nil
+}
+
+type SMTPChannel struct {
+	address string
+	auth    smtp.Auth
+	from    string
+}
+
+// NewSMTPChannel sends from the given address through an SMTP server, authenticating with PLAIN
+// when a username is set
+func NewSMTPChannel(
+	host string,
+	port int,
+	username string,
+	password string,
+	from string,
+) *SMTPChannel {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTPChannel{
+		address: net.JoinHostPort(host, fmt.Sprint(port)),
+		auth:    auth,
+		from:    from,
+	}
+}
+
+func (c *SMTPChannel) ValidateTarget(target string, secret string) error {
+	address, err := mail.ParseAddress(target)
+	if err != nil || address.Name != "" {
+		return fmt.Errorf("%w: target must be an email address", ErrInvalidNotificationRule)
+	}
+
+	return nil
+}
+
+func (c *SMTPChannel) Send(
+	ctx context.Context,
+	target string,
+	secret []byte,
+	notification *contracts.ExpiryNotification,
+) error {
+	name := notification.CommonName
+	if name == "" {
+		name = notification.Name
+	}
+
+	// Certificate names are user controlled, so nothing in them may end the header early
+	name = strings.Map(
+		func(r rune) rune {
+			if unicode.IsControl(r) {
+				return -1
+			}
+
+			return r
+		},
+		name,
+	)
+
+	subject := fmt.Sprintf(
+		"Certificate %s expires %s",
+		name,
+		notification.NotAfter.UTC().Format(time.RFC1123),
+	)
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", c.from)
+	fmt.Fprintf(&message, "To: %s\r\n", target)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(
+		&message,
+		"The certificate %s (serial number %s) expires on %s.\r\n\r\n"+
+			"Certificate ID: %s\r\nNotification rule ID: %s\r\n",
+		name,
+		notification.SerialNumber,
+		notification.NotAfter.UTC().Format(time.RFC1123),
+		notification.CertificateID,
+		notification.RuleID,
+	)
+
+	return smtp.SendMail(c.address, c.auth, c.from, []string{target}, []byte(message.String()))
+}
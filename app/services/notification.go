@@ -0,0 +1,533 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+)
+
+const (
+	notificationTypeExpiring = "certificate.expiring"
+	// notificationMaxDaysBefore keeps thresholds within the longest validity anyone would issue
+	notificationMaxDaysBefore = 3650
+	// notificationMaxAttempts is how often a delivery is tried before it is marked failed
+	notificationMaxAttempts = 10
+	// notificationRetryDelay is the wait after the first failed attempt, doubling with each
+	// failure up to notificationMaxRetryDelay
+	notificationRetryDelay    = time.Minute
+	notificationMaxRetryDelay = 6 * time.Hour
+)
+
+var ErrNotificationRuleUnauthorized = errors.New(
+	"user does not have access to this notification rule",
+)
+var ErrInvalidNotificationRule = errors.New("invalid notification rule")
+
+var _ NotificationService = (*NotificationServiceImpl)(nil)
+
+type NotificationService interface {
+	CreateRuleForUser(
+		ctx context.Context,
+		userID string,
+		request *contracts.NotificationRuleRequest,
+	) (*contracts.NotificationRuleResponse, error)
+	GetRuleForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+	) (*contracts.NotificationRuleResponse, error)
+	GetRulesForUser(
+		ctx context.Context,
+		userID string,
+	) ([]*contracts.NotificationRuleResponse, error)
+	// UpdateRuleForUser replaces a rule, a webhook keeps its secret when the request has none
+	UpdateRuleForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+		request *contracts.NotificationRuleRequest,
+	) (*contracts.NotificationRuleResponse, error)
+	DeleteRuleForUser(ctx context.Context, id string, userID string) error
+	GetDeliveriesForUser(
+		ctx context.Context,
+		ruleID string,
+		userID string,
+	) ([]*contracts.NotificationDeliveryResponse, error)
+	// ScheduleDue records a delivery for every certificate that reached one of its rules'
+	// thresholds since the last run and returns how many were recorded. A certificate that
+	// skipped several thresholds is only notified about the nearest.
+	ScheduleDue(ctx context.Context) (int, error)
+	// DeliverDue sends the pending deliveries and returns how many went through
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+type NotificationServiceImpl struct {
+	notificationRepository repositories.NotificationRepository
+	certRepository         repositories.CertRepository
+	certificateService     CertificateService
+	channels               map[string]NotificationChannel
+	secretKey              string
+}
+
+func NewNotificationServiceImpl(
+	notificationRepository repositories.NotificationRepository,
+	certRepository repositories.CertRepository,
+	certificateService CertificateService,
+	channels map[string]NotificationChannel,
+	secretKey string,
+) *NotificationServiceImpl {
+	return &NotificationServiceImpl{
+		notificationRepository: notificationRepository,
+		certRepository:         certRepository,
+		certificateService:     certificateService,
+		channels:               channels,
+		secretKey:              secretKey,
+	}
+}
+
+func (n *NotificationServiceImpl) CreateRuleForUser(
+	ctx context.Context,
+	userID string,
+	request *contracts.NotificationRuleRequest,
+) (*contracts.NotificationRuleResponse, error) {
+	rule, err := n.ruleFromRequest(ctx, userID, request, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = n.notificationRepository.CreateRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule.ToResponse(), nil
+}
+
+func (n *NotificationServiceImpl) GetRuleForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) (*contracts.NotificationRuleResponse, error) {
+	rule, err := n.getRuleForUser(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule.ToResponse(), nil
+}
+
+func (n *NotificationServiceImpl) GetRulesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*contracts.NotificationRuleResponse, error) {
+	rules, err := n.notificationRepository.GetRulesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*contracts.NotificationRuleResponse, len(rules))
+	for i, rule := range rules {
+		response[i] = rule.ToResponse()
+	}
+
+	return response, nil
+}
+
+func (n *NotificationServiceImpl) UpdateRuleForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+	request *contracts.NotificationRuleRequest,
+) (*contracts.NotificationRuleResponse, error) {
+	existing, err := n.getRuleForUser(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := n.ruleFromRequest(ctx, userID, request, existing)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = existing.ID
+	rule.Created = existing.Created
+
+	err = n.notificationRepository.UpdateRule(ctx, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return rule.ToResponse(), nil
+}
+
+func (n *NotificationServiceImpl) DeleteRuleForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) error {
+	_, err := n.getRuleForUser(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return n.notificationRepository.DeleteRule(ctx, id)
+}
+
+func (n *NotificationServiceImpl) GetDeliveriesForUser(
+	ctx context.Context,
+	ruleID string,
+	userID string,
+) ([]*contracts.NotificationDeliveryResponse, error) {
+	_, err := n.getRuleForUser(ctx, ruleID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries, err := n.notificationRepository.GetDeliveriesForRule(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*contracts.NotificationDeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		response[i] = delivery.ToResponse()
+	}
+
+	return response, nil
+}
+
+func (n *NotificationServiceImpl) getRuleForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) (*daos.NotificationRule, error) {
+	rule, err := n.notificationRepository.GetRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.UserID != userID {
+		return nil, ErrNotificationRuleUnauthorized
+	}
+
+	return rule, nil
+}
+
+// ruleFromRequest validates a request and seals its secret. When updating, existing is the rule
+// being replaced and lends its secret to a webhook request without one.
+func (n *NotificationServiceImpl) ruleFromRequest(
+	ctx context.Context,
+	userID string,
+	request *contracts.NotificationRuleRequest,
+	existing *daos.NotificationRule,
+) (*daos.NotificationRule, error) {
+	if strings.TrimSpace(request.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidNotificationRule)
+	}
+
+	daysBefore, err := validateDaysBefore(request.DaysBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, ok := n.channels[request.Channel]
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: channel %q is not available",
+			ErrInvalidNotificationRule,
+			request.Channel,
+		)
+	}
+
+	keepSecret := request.Secret == "" && existing != nil &&
+		existing.Channel == request.Channel && len(existing.SealedSecret) > 0
+
+	secret := request.Secret
+	if keepSecret {
+		// Only checked for being present, the sealed secret is carried over below
+		secret = "existing"
+	}
+
+	err = channel.ValidateTarget(request.Target, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.CAID != "" {
+		ca, err := n.certRepository.GetCertByID(ctx, request.CAID)
+		if errors.Is(err, repositories.ErrNoRecord) {
+			return nil, fmt.Errorf("%w: CA not found", ErrInvalidNotificationRule)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if ca.UserID != userID {
+			return nil, ErrCertUnautorized
+		}
+
+		if !isCAType(ca.Type) {
+			return nil, fmt.Errorf("%w: %s is not a CA", ErrInvalidNotificationRule, ca.ID)
+		}
+	}
+
+	rule := &daos.NotificationRule{
+		UserID:     userID,
+		Name:       request.Name,
+		CAID:       request.CAID,
+		DaysBefore: daysBefore,
+		Channel:    request.Channel,
+		Target:     request.Target,
+	}
+
+	if keepSecret {
+		rule.SealedSecret = existing.SealedSecret
+	} else if request.Secret != "" {
+		rule.SealedSecret, err = utils.Seal(n.secretKey, []byte(request.Secret))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rule, nil
+}
+
+// validateDaysBefore returns the thresholds sorted from furthest to nearest without duplicates
+func validateDaysBefore(daysBefore []int) ([]int, error) {
+	if len(daysBefore) == 0 {
+		return nil, fmt.Errorf("%w: at least one threshold is required", ErrInvalidNotificationRule)
+	}
+
+	seen := make(map[int]bool, len(daysBefore))
+	sorted := make([]int, 0, len(daysBefore))
+	for _, days := range daysBefore {
+		if days < 0 || days > notificationMaxDaysBefore {
+			return nil, fmt.Errorf(
+				"%w: thresholds must be between 0 and %d days",
+				ErrInvalidNotificationRule,
+				notificationMaxDaysBefore,
+			)
+		}
+
+		if !seen[days] {
+			seen[days] = true
+			sorted = append(sorted, days)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+
+	return sorted, nil
+}
+
+func (n *NotificationServiceImpl) ScheduleDue(ctx context.Context) (int, error) {
+	log := logger.Get(ctx)
+
+	rules, err := n.notificationRepository.GetRules(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	scheduled := 0
+	for _, rule := range rules {
+		certs, err := n.ruleCertificates(ctx, rule)
+		if err != nil {
+			log.WithError(err).Errorf("failed to list certificates for notification rule %s", rule.ID)
+			continue
+		}
+
+		for _, certDao := range certs {
+			cert, err := n.certificateService.GetCert(ctx, certDao.ID)
+			if err != nil {
+				log.WithError(err).Errorf("failed to read certificate %s", certDao.ID)
+				continue
+			}
+
+			// Revoked, expired and renewed certificates are nothing to warn about
+			if cert.RevokedAt != nil || !now.Before(cert.NotAfter) ||
+				cert.History[len(cert.History)-1].ID != cert.ID {
+				continue
+			}
+
+			daysBefore, ok := expiryThreshold(rule.DaysBefore, cert.NotAfter, now)
+			if !ok {
+				continue
+			}
+
+			err = n.notificationRepository.CreateDelivery(
+				ctx, &daos.NotificationDelivery{
+					RuleID:        rule.ID,
+					CertificateID: cert.ID,
+					DaysBefore:    daysBefore,
+					NotAfter:      cert.NotAfter,
+					Status:        daos.DeliveryPending,
+					NextAttempt:   now,
+				},
+			)
+			if errors.Is(err, repositories.ErrDuplicateRecord) {
+				continue
+			} else if err != nil {
+				log.WithError(err).Errorf("failed to schedule notification for %s", cert.ID)
+				continue
+			}
+
+			scheduled++
+		}
+	}
+
+	return scheduled, nil
+}
+
+// ruleCertificates lists the certificates a rule watches, OCSP responders are left out as the
+// platform replaces them itself
+func (n *NotificationServiceImpl) ruleCertificates(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+) ([]*daos.Certificate, error) {
+	if rule.CAID == "" {
+		return n.certRepository.GetCertsByUserID(
+			ctx,
+			rule.UserID,
+			[]string{
+				CertTypeRootCA.String(),
+				CertTypeIntermediateCA.String(),
+				CertTypeCertificate.String(),
+			},
+		)
+	}
+
+	issued, err := n.certRepository.GetCertsByParentCA(ctx, rule.CAID)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]*daos.Certificate, 0, len(issued))
+	for _, cert := range issued {
+		if cert.UserID == rule.UserID && cert.Type != CertTypeOCSPResponder.String() {
+			certs = append(certs, cert)
+		}
+	}
+
+	return certs, nil
+}
+
+// expiryThreshold returns the nearest of the thresholds, sorted from furthest to nearest, that
+// notAfter is already within at now
+func expiryThreshold(daysBefore []int, notAfter time.Time, now time.Time) (int, bool) {
+	for i := len(daysBefore) - 1; i >= 0; i-- {
+		if !now.Before(notAfter.Add(-time.Duration(daysBefore[i]) * 24 * time.Hour)) {
+			return daysBefore[i], true
+		}
+	}
+
+	return 0, false
+}
+
+func (n *NotificationServiceImpl) DeliverDue(ctx context.Context) (int, error) {
+	log := logger.Get(ctx)
+
+	deliveries, err := n.notificationRepository.GetDeliveriesDue(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		err = n.deliver(ctx, delivery)
+		if err != nil {
+			log.WithError(err).Warnf("unable to deliver notification %s", delivery.ID)
+			continue
+		}
+
+		delivered++
+	}
+
+	return delivered, nil
+}
+
+// deliver sends one delivery and records the attempt on it
+func (n *NotificationServiceImpl) deliver(
+	ctx context.Context,
+	delivery *daos.NotificationDelivery,
+) error {
+	rule, err := n.notificationRepository.GetRule(ctx, delivery.RuleID)
+	if err != nil {
+		return err
+	}
+
+	sendErr := n.send(ctx, rule, delivery)
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttempt = &now
+	if sendErr == nil {
+		delivery.Status = daos.DeliveryDelivered
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = sendErr.Error()
+
+		// A deleted certificate can't be described, retrying won't change that
+		if errors.Is(sendErr, repositories.ErrNoRecord) ||
+			delivery.Attempts >= notificationMaxAttempts {
+			delivery.Status = daos.DeliveryFailed
+		} else {
+			delivery.NextAttempt = now.Add(
+				retryBackoff(delivery.Attempts, notificationRetryDelay, notificationMaxRetryDelay),
+			)
+		}
+	}
+
+	err = n.notificationRepository.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		return err
+	}
+
+	return sendErr
+}
+
+func (n *NotificationServiceImpl) send(
+	ctx context.Context,
+	rule *daos.NotificationRule,
+	delivery *daos.NotificationDelivery,
+) error {
+	channel, ok := n.channels[rule.Channel]
+	if !ok {
+		return fmt.Errorf("channel %q is not available", rule.Channel)
+	}
+
+	var secret []byte
+	if len(rule.SealedSecret) > 0 {
+		var err error
+		secret, err = utils.Open(n.secretKey, rule.SealedSecret)
+		if err != nil {
+			return err
+		}
+	}
+
+	cert, err := n.certificateService.GetCert(ctx, delivery.CertificateID)
+	if err != nil {
+		return err
+	}
+
+	return channel.Send(
+		ctx, rule.Target, secret, &contracts.ExpiryNotification{
+			Type:          notificationTypeExpiring,
+			DeliveryID:    delivery.ID,
+			RuleID:        rule.ID,
+			CertificateID: cert.ID,
+			Name:          cert.Name,
+			CommonName:    cert.Subject.CommonName,
+			SerialNumber:  cert.SerialNumber,
+			NotAfter:      cert.NotAfter,
+			DaysBefore:    delivery.DaysBefore,
+		},
+	)
+}
@@ -0,0 +1,652 @@
+package services
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspValidity is the window between thisUpdate and nextUpdate on a signed response
+	ocspValidity = 4 * time.Hour
+	// ocspResponderValidity is the lifetime of an automatically issued delegated responder. It
+	// carries id-pkix-ocsp-nocheck so it can't be revoked, only kept short lived.
+	ocspResponderValidity = 4 * 24 * time.Hour
+	// ocspResponderRotateWindow is how long before expiry a responder is replaced on schedule
+	ocspResponderRotateWindow = 2 * 24 * time.Hour
+	// ocspResponderPasswordLength is the length of the random password sealing a responder key
+	ocspResponderPasswordLength = 32
+	// ocspCacheSize is how many signed responses are kept before the least recently used go
+	ocspCacheSize = 10000
+	// ocspSignerLifetime is how long a decrypted signing key is kept before the responder for its
+	// CA is looked up again, so responders issued by other replicas are picked up
+	ocspSignerLifetime = time.Hour
+)
+
+// oidOCSPNoCheck is id-pkix-ocsp-nocheck from RFC 6960 section 4.2.2.2.1
+var oidOCSPNoCheck = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 5}
+
+var _ OCSPService = (*OCSPServiceImpl)(nil)
+
+type OCSPService interface {
+	// Respond answers a DER encoded OCSP request for a certificate issued by caID. Protocol level
+	// failures are returned as signed-less OCSP error responses rather than errors.
+	Respond(ctx context.Context, caID string, rawRequest []byte) []byte
+	IssueResponderForUser(
+		ctx context.Context,
+		caID string,
+		userID string,
+		caKeyPassword string,
+	) (*contracts.CertificateLightResponse, error)
+	// RotateDueResponders replaces delegated responders close to expiry, unlocking the CA key
+	// with the password sealed for its CRL refreshes
+	RotateDueResponders(ctx context.Context) (int, error)
+}
+
+type cachedOCSPResponse struct {
+	key        string
+	data       []byte
+	nextUpdate time.Time
+}
+
+// ocspSigner is the decrypted key signing the responses of a CA and the certificate naming it
+type ocspSigner struct {
+	signer    crypto.Signer
+	cert      *x509.Certificate
+	expiresAt time.Time
+}
+
+type OCSPServiceImpl struct {
+	certRepository  repositories.CertRepository
+	keyService      KeyService
+	transparencyLog TransparencyLogService
+	transactor      repositories.Transactor
+	secretKey       string
+
+	// cache holds signed responses in an LRU list of cachedOCSPResponse, most recent first
+	cacheLock  sync.Mutex
+	cache      map[string]*list.Element
+	cacheOrder *list.List
+	cacheSize  int
+
+	signersLock sync.Mutex
+	signers     map[string]*ocspSigner
+}
+
+func NewOCSPServiceImpl(
+	certRepository repositories.CertRepository,
+	keyService KeyService,
+	transparencyLog TransparencyLogService,
+	transactor repositories.Transactor,
+	secretKey string,
+) *OCSPServiceImpl {
+	return &OCSPServiceImpl{
+		certRepository:  certRepository,
+		keyService:      keyService,
+		transparencyLog: transparencyLog,
+		transactor:      transactor,
+		secretKey:       secretKey,
+		cache:           make(map[string]*list.Element),
+		cacheOrder:      list.New(),
+		cacheSize:       ocspCacheSize,
+		signers:         make(map[string]*ocspSigner),
+	}
+}
+
+func (o *OCSPServiceImpl) Respond(ctx context.Context, caID string, rawRequest []byte) []byte {
+	log := logger.Get(ctx)
+
+	req, err := ocsp.ParseRequest(rawRequest)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse
+	}
+
+	ca, err := o.certRepository.GetCertByID(ctx, caID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNoRecord) {
+			log.WithError(err).Error("failed to get CA for OCSP request")
+			return ocsp.InternalErrorErrorResponse
+		}
+
+		return ocsp.UnauthorizedErrorResponse
+	}
+
+	caCert, err := x509.ParseCertificate(ca.Data)
+	if err != nil {
+		log.WithError(err).Error("failed to parse CA for OCSP request")
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	if !ocspIssuerMatches(req, caCert) {
+		return ocsp.UnauthorizedErrorResponse
+	}
+
+	cert, err := o.certRepository.GetCertByIssuerAndSerial(ctx, caID, req.SerialNumber)
+	if errors.Is(err, repositories.ErrNoRecord) {
+		cert = nil
+	} else if err != nil {
+		log.WithError(err).Error("failed to look up certificate for OCSP request")
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: req.SerialNumber,
+		IssuerHash:   req.HashAlgorithm,
+	}
+	if cert != nil {
+		template.Status = ocsp.Good
+		if cert.IsRevoked() {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = *cert.RevokedAt
+			template.RevocationReason = cert.RevocationReason
+		}
+	}
+
+	cacheKey := fmt.Sprintf(
+		"%s/%d/%s/%d",
+		caID,
+		req.HashAlgorithm,
+		req.SerialNumber.Text(16),
+		template.Status,
+	)
+	if data := o.cached(cacheKey); data != nil {
+		return data
+	}
+
+	signer, responderCert, err := o.signerForCA(ctx, ca, caCert)
+	if err != nil {
+		log.WithError(err).Errorf("no OCSP signing key available for CA %s", caID)
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	if responderCert != caCert {
+		template.Certificate = responderCert
+	}
+
+	template.ThisUpdate = time.Now()
+	template.NextUpdate = template.ThisUpdate.Add(ocspValidity)
+
+	data, err := ocsp.CreateResponse(caCert, responderCert, template, signer)
+	if err != nil {
+		log.WithError(err).Error("failed to sign OCSP response")
+		return ocsp.InternalErrorErrorResponse
+	}
+
+	// Unknown serials are whatever a client makes up, so caching them would let anyone fill the
+	// cache and push out responses that matter
+	if template.Status != ocsp.Unknown {
+		o.store(cacheKey, data, template.NextUpdate)
+	}
+
+	return data
+}
+
+func (o *OCSPServiceImpl) IssueResponderForUser(
+	ctx context.Context,
+	caID string,
+	userID string,
+	caKeyPassword string,
+) (*contracts.CertificateLightResponse, error) {
+	ca, err := o.certRepository.GetCertByID(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ca.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	caCert, err := x509.ParseCertificate(ca.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !caCert.IsCA {
+		return nil, errors.New("certificate is not a certificate authority")
+	}
+
+	caKey, err := o.keyService.GetDecryptedKeyForUser(ctx, ca.KeyID, userID, caKeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	var responder *contracts.CertificateLightResponse
+	err = o.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			var err error
+			responder, err = o.issueResponder(ctx, ca, caCert, caKey, userID)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	o.forgetSigner(caID)
+
+	return responder, nil
+}
+
+func (o *OCSPServiceImpl) RotateDueResponders(ctx context.Context) (int, error) {
+	log := logger.Get(ctx)
+
+	responders, err := o.certRepository.GetCertsByType(
+		ctx,
+		[]string{CertTypeOCSPResponder.String()},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	byCA := make(map[string][]*daos.Certificate)
+	for _, responder := range responders {
+		byCA[responder.ParentCertificate] = append(byCA[responder.ParentCertificate], responder)
+	}
+
+	rotated := 0
+	for caID, caResponders := range byCA {
+		ok, err := o.rotateResponder(ctx, caID, caResponders)
+		if err != nil {
+			log.WithError(err).Warnf("unable to rotate OCSP responder for CA %s", caID)
+			continue
+		}
+
+		if ok {
+			rotated++
+		}
+	}
+
+	return rotated, nil
+}
+
+// rotateResponder issues a new responder for the CA when the current one expires within the
+// rotate window or predates sealed responder keys, then deletes the keys of the ones it replaces.
+// Responders are unlinked from their key once it is gone, so each is only cleaned up once.
+func (o *OCSPServiceImpl) rotateResponder(
+	ctx context.Context,
+	caID string,
+	responders []*daos.Certificate,
+) (bool, error) {
+	rotateBy := time.Now().Add(ocspResponderRotateWindow)
+
+	current, _, err := currentResponder(responders, rotateBy)
+	if err != nil {
+		return false, err
+	}
+
+	if current != nil && len(current.SealedKeyPassword) > 0 {
+		return false, nil
+	}
+
+	ca, err := o.certRepository.GetCertByID(ctx, caID)
+	if errors.Is(err, repositories.ErrNoRecord) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	caCert, err := x509.ParseCertificate(ca.Data)
+	if err != nil {
+		return false, err
+	}
+
+	// A successor would be cut short by the CA's own expiry just the same
+	if ca.IsRevoked() || caCert.NotAfter.Before(rotateBy) {
+		return false, nil
+	}
+
+	caKeyPassword, err := unsealKeyPassword(o.secretKey, ca)
+	if err != nil {
+		return false, err
+	}
+
+	caKey, err := o.keyService.GetDecryptedKeyForUser(ctx, ca.KeyID, ca.UserID, caKeyPassword)
+	if err != nil {
+		return false, err
+	}
+
+	err = o.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			_, err := o.issueResponder(ctx, ca, caCert, caKey, ca.UserID)
+			return err
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	o.forgetSigner(caID)
+
+	log := logger.Get(ctx)
+	for _, responder := range responders {
+		if responder.KeyID == "" {
+			continue
+		}
+
+		err = o.keyService.DeleteKeyForUser(ctx, responder.KeyID, responder.UserID)
+		if errors.Is(err, repositories.ErrNoRecord) {
+			// The key went some other way, only the link to it is left to clean up
+			err = o.certRepository.UnlinkKey(ctx, responder.KeyID)
+		}
+		if err != nil {
+			log.WithError(err).Warnf("failed to delete key of replaced OCSP responder %s", responder.ID)
+		}
+	}
+
+	return true, nil
+}
+
+// issueResponder creates the responder key and certifies it for OCSP signing with the CA key.
+// The key is protected by a random password sealed with the server secret onto the responder
+// certificate, so responses can be signed online without keeping the key in the clear.
+func (o *OCSPServiceImpl) issueResponder(
+	ctx context.Context,
+	ca *daos.Certificate,
+	caCert *x509.Certificate,
+	caKey PrivateKey,
+	userID string,
+) (*contracts.CertificateLightResponse, error) {
+	name := ca.Name + " OCSP Responder"
+
+	keyPassword, err := utils.GenerateRandomString(ocspResponderPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	sealedKeyPassword, err := utils.Seal(o.secretKey, []byte(keyPassword))
+	if err != nil {
+		return nil, err
+	}
+
+	keyResp, err := o.keyService.CreateKey(
+		ctx,
+		userID,
+		name,
+		contracts.ECDSA,
+		contracts.KeyParameters{Curve: contracts.P256},
+		keyPassword,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	responderKey, err := o.keyService.GetDecryptedKeyForUser(ctx, keyResp.ID, userID, keyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now()
+	notAfter := notBefore.Add(ocspResponderValidity)
+	if caCert.NotAfter.Before(notAfter) {
+		notAfter = caCert.NotAfter
+	}
+
+	serialNumber, err := newSerialNumber(ctx, o.certRepository, ca.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	certTemplate := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName: name,
+		},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		BasicConstraintsValid: true,
+		ExtraExtensions: []pkix.Extension{
+			{
+				Id:    oidOCSPNoCheck,
+				Value: asn1.NullBytes,
+			},
+		},
+	}
+
+	certData, err := x509.CreateCertificate(
+		rand.Reader,
+		&certTemplate,
+		caCert,
+		responderKey.Public(),
+		caKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	dao, err := o.certRepository.CreateCert(
+		ctx,
+		userID,
+		name,
+		certData,
+		CertTypeOCSPResponder.String(),
+		ca.ID,
+		keyResp.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.certRepository.SetCertSealedKeyPassword(ctx, dao.ID, sealedKeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.transparencyLog.AppendCertificate(ctx, dao)
+	if err != nil {
+		return nil, err
+	}
+
+	return dao.ToLightResponse(), nil
+}
+
+// signerForCA returns the signer for OCSP responses from the CA, decrypting its key only when the
+// one kept from earlier responses has expired
+func (o *OCSPServiceImpl) signerForCA(
+	ctx context.Context,
+	ca *daos.Certificate,
+	caCert *x509.Certificate,
+) (crypto.Signer, *x509.Certificate, error) {
+	now := time.Now()
+
+	o.signersLock.Lock()
+	cached, ok := o.signers[ca.ID]
+	o.signersLock.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.signer, cached.cert, nil
+	}
+
+	signer, signingCert, err := o.responderForCA(ctx, ca, caCert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A delegated responder is only used while it outlives the responses it signs
+	expiresAt := now.Add(ocspSignerLifetime)
+	responderExpiry := signingCert.NotAfter.Add(-ocspValidity)
+	if signingCert != caCert && responderExpiry.Before(expiresAt) {
+		expiresAt = responderExpiry
+	}
+
+	o.signersLock.Lock()
+	o.signers[ca.ID] = &ocspSigner{signer: signer, cert: signingCert, expiresAt: expiresAt}
+	o.signersLock.Unlock()
+
+	return signer, signingCert, nil
+}
+
+// forgetSigner drops the signer kept for the CA so the next response uses its newest responder
+func (o *OCSPServiceImpl) forgetSigner(caID string) {
+	o.signersLock.Lock()
+	defer o.signersLock.Unlock()
+
+	delete(o.signers, caID)
+}
+
+// responderForCA returns the signer for OCSP responses from the CA, preferring the most recently
+// issued delegated responder and falling back to the CA key itself when it is not password
+// protected.
+func (o *OCSPServiceImpl) responderForCA(
+	ctx context.Context,
+	ca *daos.Certificate,
+	caCert *x509.Certificate,
+) (crypto.Signer, *x509.Certificate, error) {
+	children, err := o.certRepository.GetCertsByParentCA(ctx, ca.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responder, responderCert, err := currentResponder(children, time.Now().Add(ocspValidity))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyID := ca.KeyID
+	keyPassword := ""
+	signingCert := caCert
+	if responder != nil {
+		keyID = responder.KeyID
+		signingCert = responderCert
+
+		keyPassword, err = unsealKeyPassword(o.secretKey, responder)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	key, err := o.keyService.GetDecryptedKeyForUser(ctx, keyID, ca.UserID, keyPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("OCSP key is not capable of signing")
+	}
+
+	return signer, signingCert, nil
+}
+
+// currentResponder picks the most recently issued unrevoked OCSP responder amongst certs that is
+// still valid at validUntil and has its key, or nothing when there is none
+func currentResponder(
+	certs []*daos.Certificate,
+	validUntil time.Time,
+) (*daos.Certificate, *x509.Certificate, error) {
+	var responder *daos.Certificate
+	var responderCert *x509.Certificate
+	for _, cert := range certs {
+		if cert.Type != CertTypeOCSPResponder.String() || cert.IsRevoked() || cert.KeyID == "" {
+			continue
+		}
+
+		parsed, err := x509.ParseCertificate(cert.Data)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if parsed.NotAfter.Before(validUntil) {
+			continue
+		}
+
+		// Responders issued within the same second are told apart by when they were stored
+		if responderCert == nil || parsed.NotBefore.After(responderCert.NotBefore) ||
+			parsed.NotBefore.Equal(responderCert.NotBefore) && cert.Created.After(responder.Created) {
+			responder = cert
+			responderCert = parsed
+		}
+	}
+
+	return responder, responderCert, nil
+}
+
+func (o *OCSPServiceImpl) cached(key string) []byte {
+	o.cacheLock.Lock()
+	defer o.cacheLock.Unlock()
+
+	element, ok := o.cache[key]
+	if !ok {
+		return nil
+	}
+
+	entry := element.Value.(*cachedOCSPResponse)
+	if time.Now().After(entry.nextUpdate) {
+		o.cacheOrder.Remove(element)
+		delete(o.cache, key)
+		return nil
+	}
+
+	o.cacheOrder.MoveToFront(element)
+
+	return entry.data
+}
+
+// store caches a signed response, evicting the least recently used one when the cache is full
+func (o *OCSPServiceImpl) store(key string, data []byte, nextUpdate time.Time) {
+	o.cacheLock.Lock()
+	defer o.cacheLock.Unlock()
+
+	entry := &cachedOCSPResponse{
+		key:        key,
+		data:       data,
+		nextUpdate: nextUpdate,
+	}
+
+	if element, ok := o.cache[key]; ok {
+		element.Value = entry
+		o.cacheOrder.MoveToFront(element)
+		return
+	}
+
+	o.cache[key] = o.cacheOrder.PushFront(entry)
+
+	for o.cacheOrder.Len() > o.cacheSize {
+		oldest := o.cacheOrder.Back()
+		o.cacheOrder.Remove(oldest)
+		delete(o.cache, oldest.Value.(*cachedOCSPResponse).key)
+	}
+}
+
+// ocspIssuerMatches checks the issuer name and key hashes of the request against the CA
+func ocspIssuerMatches(req *ocsp.Request, caCert *x509.Certificate) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	_, err := asn1.Unmarshal(caCert.RawSubjectPublicKeyInfo, &publicKeyInfo)
+	if err != nil {
+		return false
+	}
+
+	h := req.HashAlgorithm.New()
+	h.Write(caCert.RawSubject)
+	nameHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(publicKeyInfo.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	return bytes.Equal(nameHash, req.IssuerNameHash) && bytes.Equal(keyHash, req.IssuerKeyHash)
+}
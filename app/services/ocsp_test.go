@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspCountingKeyService counts the keys the OCSP service decrypts and deletes
+type ocspCountingKeyService struct {
+	KeyService
+	decrypted int
+	deleted   int
+}
+
+func (k *ocspCountingKeyService) GetDecryptedKeyForUser(
+	ctx context.Context,
+	keyID string,
+	userID string,
+	password string,
+) (PrivateKey, error) {
+	k.decrypted++
+	return k.KeyService.GetDecryptedKeyForUser(ctx, keyID, userID, password)
+}
+
+func (k *ocspCountingKeyService) DeleteKeyForUser(
+	ctx context.Context,
+	keyID string,
+	userID string,
+) error {
+	k.deleted++
+	return k.KeyService.DeleteKeyForUser(ctx, keyID, userID)
+}
+
+// newOCSPTestCA sets up a CA with its CRL and responder, answering OCSP through a service that
+// counts key use
+func newOCSPTestCA(t *testing.T) (
+	*certificateTestPlatform,
+	*ocspCountingKeyService,
+	*daos.Certificate,
+	*x509.Certificate,
+) {
+	platform := newCertificateTestPlatform(t, "secret")
+	keyService := &ocspCountingKeyService{KeyService: platform.keyService}
+	platform.ocspService = NewOCSPServiceImpl(
+		platform.certRepository,
+		keyService,
+		platform.ocspService.transparencyLog,
+		platform.ocspService.transactor,
+		"secret",
+	)
+
+	ca := platform.createRootCA(t, "OCSP Test CA")
+	err := SetupCA(
+		context.Background(),
+		platform.certificateService,
+		platform.ocspService,
+		ca.ID,
+		certificateTestUserID,
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	caCert, err := x509.ParseCertificate(ca.Data)
+	require.NoError(t, err)
+
+	keyService.decrypted = 0
+
+	return platform, keyService, ca, caCert
+}
+
+// ocspRespond asks the platform for the status of cert and parses the signed answer
+func ocspRespond(
+	t *testing.T,
+	platform *certificateTestPlatform,
+	ca *daos.Certificate,
+	caCert *x509.Certificate,
+	cert *x509.Certificate,
+) ([]byte, *ocsp.Response) {
+	request, err := ocsp.CreateRequest(cert, caCert, nil)
+	require.NoError(t, err)
+
+	data := platform.ocspService.Respond(context.Background(), ca.ID, request)
+	response, err := ocsp.ParseResponseForCert(data, cert, caCert)
+	require.NoError(t, err)
+
+	return data, response
+}
+
+func TestOCSPRespond(t *testing.T) {
+	ctx := context.Background()
+	platform, _, ca, caCert := newOCSPTestCA(t)
+
+	_, good := platform.issueLeaf(t, ca)
+	revokedLeaf, revoked := platform.issueLeaf(t, ca)
+	err := platform.certificateService.RevokeCertForUser(
+		ctx,
+		revokedLeaf.ID,
+		certificateTestUserID,
+		contracts.ReasonSuperseded,
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err)
+
+	unknown := testutils.Certificate(
+		t,
+		&x509.Certificate{SerialNumber: big.NewInt(4242)},
+		testutils.Key(t, "ecdsa"),
+		nil,
+		nil,
+	)
+
+	tests := []struct {
+		name           string
+		cert           *x509.Certificate
+		expectedStatus int
+		expectedReason int
+	}{
+		{name: "good", cert: good, expectedStatus: ocsp.Good},
+		{
+			name:           "revoked",
+			cert:           revoked,
+			expectedStatus: ocsp.Revoked,
+			expectedReason: int(contracts.ReasonSuperseded),
+		},
+		{name: "unknown", cert: unknown, expectedStatus: ocsp.Unknown},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				_, response := ocspRespond(t, platform, ca, caCert, test.cert)
+				assert.Equal(t, test.expectedStatus, response.Status)
+				assert.Equal(t, test.expectedReason, response.RevocationReason)
+				assert.Equal(t, test.cert.SerialNumber, response.SerialNumber)
+				assert.WithinDuration(
+					t,
+					time.Now().Add(ocspValidity),
+					response.NextUpdate,
+					time.Minute,
+				)
+
+				// Signed by the delegated responder, not the CA
+				require.NotNil(t, response.Certificate)
+				assert.Equal(
+					t,
+					[]x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+					response.Certificate.ExtKeyUsage,
+				)
+				publicKey, ok := response.Certificate.PublicKey.(*ecdsa.PublicKey)
+				require.True(t, ok)
+				assert.Equal(t, elliptic.P256(), publicKey.Curve)
+			},
+		)
+	}
+
+	otherCA := testutils.CACertificate(t, "Other CA", testutils.Key(t, "ecdsa"), nil, nil)
+	request, err := ocsp.CreateRequest(good, otherCA, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ocsp.UnauthorizedErrorResponse, platform.ocspService.Respond(ctx, ca.ID, request))
+
+	data := platform.ocspService.Respond(ctx, ca.ID, []byte("not a request"))
+	assert.Equal(t, ocsp.MalformedRequestErrorResponse, data)
+}
+
+func TestOCSPRespondCache(t *testing.T) {
+	platform, keyService, ca, caCert := newOCSPTestCA(t)
+	_, good := platform.issueLeaf(t, ca)
+	unknown := testutils.LeafCertificate(t, "Unknown", testutils.Key(t, "ecdsa"), nil, nil)
+
+	first, _ := ocspRespond(t, platform, ca, caCert, good)
+	second, _ := ocspRespond(t, platform, ca, caCert, good)
+	assert.Equal(t, first, second, "served from the cache")
+	assert.Equal(t, 1, keyService.decrypted)
+
+	ocspRespond(t, platform, ca, caCert, unknown)
+	ocspRespond(t, platform, ca, caCert, unknown)
+	assert.Len(t, platform.ocspService.cache, 1, "unknown responses are not cached")
+	assert.Equal(t, 1, keyService.decrypted, "the responder key is kept decrypted")
+
+	responder, err := platform.ocspService.IssueResponderForUser(
+		context.Background(),
+		ca.ID,
+		certificateTestUserID,
+		certificateTestKeyPassword,
+	)
+	require.NoError(t, err)
+	keyService.decrypted = 0
+
+	responderDao, err := platform.certRepository.GetCertByID(context.Background(), responder.ID)
+	require.NoError(t, err)
+	responderCert, err := x509.ParseCertificate(responderDao.Data)
+	require.NoError(t, err)
+
+	_, response := ocspRespond(t, platform, ca, caCert, unknown)
+	assert.Equal(t, 1, keyService.decrypted)
+	assert.Equal(t, responderCert.SerialNumber, response.Certificate.SerialNumber, "the new one signs")
+}
+
+func TestOCSPResponseCacheEviction(t *testing.T) {
+	service := NewOCSPServiceImpl(nil, nil, nil, nil, "")
+	service.cacheSize = 2
+	nextUpdate := time.Now().Add(time.Hour)
+
+	service.store("a", []byte("a"), nextUpdate)
+	service.store("b", []byte("b"), nextUpdate)
+	assert.Equal(t, []byte("a"), service.cached("a"))
+
+	service.store("c", []byte("c"), nextUpdate)
+	assert.Nil(t, service.cached("b"), "least recently used")
+	assert.Equal(t, []byte("a"), service.cached("a"))
+	assert.Equal(t, []byte("c"), service.cached("c"))
+
+	service.store("a", []byte("replaced"), nextUpdate)
+	assert.Equal(t, []byte("replaced"), service.cached("a"))
+	assert.Len(t, service.cache, 2)
+
+	service.store("expired", []byte("expired"), time.Now().Add(-time.Second))
+	assert.Nil(t, service.cached("expired"))
+	assert.Len(t, service.cache, 1)
+	assert.Equal(t, 1, service.cacheOrder.Len())
+}
+
+func TestOCSPRotateResponders(t *testing.T) {
+	ctx := context.Background()
+	platform, keyService, ca, caCert := newOCSPTestCA(t)
+	_, good := platform.issueLeaf(t, ca)
+
+	rotated, err := platform.ocspService.RotateDueResponders(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, rotated, "the responder is fresh")
+
+	// rotateToNewest turns the newest responder into one predating sealed keys, which is rotated
+	// right away, and returns the responder replacing it
+	rotateToNewest := func(t *testing.T) *daos.Certificate {
+		children, err := platform.certRepository.GetCertsByParentCA(ctx, ca.ID)
+		require.NoError(t, err)
+		current, _, err := currentResponder(children, time.Now())
+		require.NoError(t, err)
+		require.NoError(t, platform.certRepository.SetCertSealedKeyPassword(ctx, current.ID, nil))
+
+		keyService.deleted = 0
+		rotated, err := platform.ocspService.RotateDueResponders(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, rotated)
+
+		replaced, err := platform.certRepository.GetCertByID(ctx, current.ID)
+		require.NoError(t, err)
+		assert.Empty(t, replaced.KeyID, "the replaced responder is cleaned up")
+
+		children, err = platform.certRepository.GetCertsByParentCA(ctx, ca.ID)
+		require.NoError(t, err)
+		successor, _, err := currentResponder(children, time.Now())
+		require.NoError(t, err)
+		require.NotEqual(t, current.ID, successor.ID)
+
+		return successor
+	}
+
+	successor := rotateToNewest(t)
+	assert.Equal(t, 1, keyService.deleted)
+
+	_, response := ocspRespond(t, platform, ca, caCert, good)
+	successorCert, err := x509.ParseCertificate(successor.Data)
+	require.NoError(t, err)
+	assert.Equal(t, successorCert.SerialNumber, response.Certificate.SerialNumber)
+
+	successor = rotateToNewest(t)
+	assert.Equal(t, 1, keyService.deleted, "responders cleaned up earlier are left alone")
+
+	// A key that went missing another way only has its link cleaned up, once
+	require.NoError(t, platform.keyRepository.DeleteKey(ctx, successor.KeyID))
+	rotateToNewest(t)
+	assert.Equal(t, 1, keyService.deleted)
+
+	rotateToNewest(t)
+	assert.Equal(t, 1, keyService.deleted)
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+)
+
+const (
+	// serialNumberBits of CSPRNG output go into every serial, well above the 64 bit minimum
+	serialNumberBits = 128
+	// serialNumberAttempts bounds the retries when a generated serial is already in use
+	serialNumberAttempts = 5
+)
+
+var ErrSerialNumberExhausted = errors.New("unable to generate a unique serial number")
+var ErrInvalidSerialNumber = errors.New("invalid serial number")
+
+// newSerialNumber returns a random positive serial that has not been used by issuerID yet.
+// Root CAs are their own issuer and pass an empty issuerID.
+func newSerialNumber(
+	ctx context.Context,
+	certRepository repositories.CertRepository,
+	issuerID string,
+) (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), serialNumberBits)
+
+	for i := 0; i < serialNumberAttempts; i++ {
+		serial, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if serial.Sign() == 0 {
+			continue
+		}
+
+		_, err = certRepository.GetCertByIssuerAndSerial(ctx, issuerID, serial)
+		if errors.Is(err, repositories.ErrNoRecord) {
+			return serial, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrSerialNumberExhausted
+}
+
+// parseSerialNumber accepts a decimal serial, or hex when prefixed with 0x or written with colons
+// the way openssl prints it
+func parseSerialNumber(serialNumber string) (*big.Int, error) {
+	serialNumber = strings.TrimSpace(serialNumber)
+
+	base := 10
+	switch {
+	case strings.HasPrefix(serialNumber, "0x"):
+		serialNumber = strings.TrimPrefix(serialNumber, "0x")
+		base = 16
+	case strings.Contains(serialNumber, ":"):
+		serialNumber = strings.ReplaceAll(serialNumber, ":", "")
+		base = 16
+	}
+
+	serial, ok := new(big.Int).SetString(serialNumber, base)
+	if !ok || serial.Sign() <= 0 {
+		return nil, ErrInvalidSerialNumber
+	}
+
+	return serial, nil
+}
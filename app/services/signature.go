@@ -0,0 +1,481 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+)
+
+var _ SignatureService = (*SignatureServiceImpl)(nil)
+
+var ErrInvalidSignatureRequest = errors.New("invalid signature request")
+
+// signatureDigestAlgorithms are the digestAlgorithm names accepted when signing and verifying
+var signatureDigestAlgorithms = map[string]crypto.Hash{
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// SignatureService makes and checks detached CMS signatures over documents, using certificates
+// issued on the platform and the keys KeyService manages
+type SignatureService interface {
+	SignDataForUser(
+		ctx context.Context,
+		userID string,
+		request *contracts.SignDataRequest,
+	) (*contracts.SignDataResponse, error)
+	// VerifyForUser checks every signer against the user's CA TrustAnchorID. Certificates are
+	// checked at the time of verification, as the signing time attribute is only asserted by
+	// the signer. A signature that doesn't verify is reported in the response, errors are kept
+	// for requests that can't be checked at all.
+	VerifyForUser(
+		ctx context.Context,
+		userID string,
+		request *contracts.VerifySignatureRequest,
+	) (*contracts.VerifySignatureResponse, error)
+}
+
+type SignatureServiceImpl struct {
+	certRepository repositories.CertRepository
+	keyService     KeyService
+	auditService   AuditService
+}
+
+func NewSignatureServiceImpl(
+	certRepository repositories.CertRepository,
+	keyService KeyService,
+	auditService AuditService,
+) *SignatureServiceImpl {
+	return &SignatureServiceImpl{
+		certRepository: certRepository,
+		keyService:     keyService,
+		auditService:   auditService,
+	}
+}
+
+func (s *SignatureServiceImpl) SignDataForUser(
+	ctx context.Context,
+	userID string,
+	request *contracts.SignDataRequest,
+) (resp *contracts.SignDataResponse, err error) {
+	defer func() {
+		s.auditService.Record(ctx, userID, AuditCertificateSignData, request.CertificateID, err)
+	}()
+
+	data, err := decodeSignatureField("data", request.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := decodeSignatureField("digest", request.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if (data == nil) == (digest == nil) {
+		return nil, fmt.Errorf("%w: one of data or digest is required", ErrInvalidSignatureRequest)
+	}
+
+	cert, err := s.certRepository.GetCertByID(ctx, request.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	if cert.KeyID == "" {
+		return nil, ErrCertHasNoKey
+	}
+
+	if cert.IsRevoked() {
+		return nil, fmt.Errorf("%w: certificate has been revoked", ErrInvalidSignatureRequest)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Data)
+	if err != nil {
+		return nil, ErrUnknownCertFormat
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if now.Before(x509Cert.NotBefore) || now.After(x509Cert.NotAfter) {
+		return nil, fmt.Errorf("%w: certificate is not currently valid", ErrInvalidSignatureRequest)
+	}
+
+	if !signingKeyUsage(x509Cert) {
+		return nil, fmt.Errorf(
+			"%w: certificate key usage does not allow signing",
+			ErrInvalidSignatureRequest,
+		)
+	}
+
+	digestAlgorithm := request.DigestAlgorithm
+	if digestAlgorithm == "" {
+		digestAlgorithm = "sha256"
+		// RFC 8419 pairs Ed25519 with SHA-512
+		if _, ok := x509Cert.PublicKey.(ed25519.PublicKey); ok {
+			digestAlgorithm = "sha512"
+		}
+	}
+
+	hash, ok := signatureDigestAlgorithms[digestAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: unknown digest algorithm %s",
+			ErrInvalidSignatureRequest,
+			digestAlgorithm,
+		)
+	}
+
+	if data != nil {
+		h := hash.New()
+		h.Write(data)
+		digest = h.Sum(nil)
+	} else if len(digest) != hash.Size() {
+		return nil, fmt.Errorf(
+			"%w: %s digests are %d bytes",
+			ErrInvalidSignatureRequest,
+			digestAlgorithm,
+			hash.Size(),
+		)
+	}
+
+	certificates, crls, err := s.signatureAttachments(ctx, cert, request)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.keyService.GetDecryptedKeyForUser(ctx, cert.KeyID, userID, request.KeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("certificate key is not capable of signing")
+	}
+
+	signature, err := signCMS(
+		&cmsSignParams{
+			ContentType:  oidCMSData,
+			Digest:       digest,
+			Hash:         hash,
+			Certificate:  x509Cert,
+			Key:          signer,
+			SigningTime:  now,
+			Certificates: certificates,
+			CRLs:         crls,
+		},
+	)
+	if errors.Is(err, errUnsupportedCMS) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignatureRequest, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &contracts.SignDataResponse{
+		Signature:       base64.StdEncoding.EncodeToString(signature),
+		CertificateID:   cert.ID,
+		DigestAlgorithm: digestAlgorithm,
+		SigningTime:     now,
+	}, nil
+}
+
+// decodeSignatureField decodes a base64 request field, nil when it was left empty
+func decodeSignatureField(name string, value string) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not valid base64", ErrInvalidSignatureRequest, name)
+	}
+
+	return decoded, nil
+}
+
+// signatureAttachments collects the certificates and CRLs embedded alongside a signature. The
+// signer certificate is always included, its issuers only when asked for.
+func (s *SignatureServiceImpl) signatureAttachments(
+	ctx context.Context,
+	cert *daos.Certificate,
+	request *contracts.SignDataRequest,
+) ([][]byte, [][]byte, error) {
+	certificates := [][]byte{cert.Data}
+	if !request.IncludeChain && !request.IncludeRevocationInfo {
+		return certificates, nil, nil
+	}
+
+	chain, err := certChain(ctx, s.certRepository, cert, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if request.IncludeChain {
+		for _, issuer := range chain[1:] {
+			certificates = append(certificates, issuer.Data)
+		}
+	}
+
+	var crls [][]byte
+	if request.IncludeRevocationInfo {
+		for _, issuer := range chain[1:] {
+			crl, err := s.certRepository.GetLatestCRL(ctx, issuer.ID)
+			if errors.Is(err, repositories.ErrNoRecord) {
+				continue
+			} else if err != nil {
+				return nil, nil, err
+			}
+
+			crls = append(crls, crl.Data)
+		}
+	}
+
+	return certificates, crls, nil
+}
+
+// signingKeyUsage reports whether a certificate may sign documents. Certificates without a key
+// usage extension are unrestricted.
+func signingKeyUsage(cert *x509.Certificate) bool {
+	return cert.KeyUsage == 0 ||
+		cert.KeyUsage&(x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment) != 0
+}
+
+func (s *SignatureServiceImpl) VerifyForUser(
+	ctx context.Context,
+	userID string,
+	request *contracts.VerifySignatureRequest,
+) (*contracts.VerifySignatureResponse, error) {
+	anchor, err := s.certRepository.GetCertByID(ctx, request.TrustAnchorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if anchor.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	if anchor.Type != CertTypeRootCA.String() && anchor.Type != CertTypeIntermediateCA.String() {
+		return nil, fmt.Errorf(
+			"%w: trust anchor must be a certificate authority",
+			ErrInvalidSignatureRequest,
+		)
+	}
+
+	anchorCert, err := x509.ParseCertificate(anchor.Data)
+	if err != nil {
+		return nil, ErrUnknownCertFormat
+	}
+
+	signature, err := decodeSignatureField("signature", request.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := parseCMS(signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSignatureRequest, err)
+	}
+
+	digestFor, err := signatureDigestFunc(message, request)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(anchorCert)
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range message.certificates {
+		intermediates.AddCert(cert)
+	}
+
+	resp := &contracts.VerifySignatureResponse{
+		Valid:   true,
+		Signers: make([]*contracts.SignerResponse, 0),
+	}
+
+	for _, result := range message.verifySigners(digestFor) {
+		if result.err == nil {
+			result.err = s.checkSignerTrust(
+				ctx,
+				anchor,
+				message,
+				result.certificate,
+				roots,
+				intermediates,
+			)
+		}
+
+		signer := &contracts.SignerResponse{
+			SigningTime: result.signingTime,
+			Valid:       result.err == nil,
+		}
+
+		if result.certificate != nil {
+			signer.Subject = result.certificate.Subject.String()
+			signer.SerialNumber = result.certificate.SerialNumber.String()
+		}
+
+		if result.err != nil {
+			signer.Error = result.err.Error()
+			if resp.Valid {
+				resp.Valid = false
+				resp.Error = result.err.Error()
+			}
+		}
+
+		resp.Signers = append(resp.Signers, signer)
+	}
+
+	if len(resp.Signers) == 0 {
+		resp.Valid = false
+		resp.Error = "signature has no signers"
+	}
+
+	return resp, nil
+}
+
+// signatureDigestFunc hashes the content a signature is checked against. That is the request's
+// data, the digest it carries or, failing both, content encapsulated in the signature itself.
+func signatureDigestFunc(
+	message *cmsSignedMessage,
+	request *contracts.VerifySignatureRequest,
+) (func(hash crypto.Hash) ([]byte, error), error) {
+	data, err := decodeSignatureField("data", request.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := decodeSignatureField("digest", request.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil && digest == nil {
+		data = message.signedData.EncapContentInfo.EContent
+	}
+
+	if data != nil {
+		return func(hash crypto.Hash) ([]byte, error) {
+			h := hash.New()
+			h.Write(data)
+			return h.Sum(nil), nil
+		}, nil
+	}
+
+	if digest == nil {
+		return nil, fmt.Errorf(
+			"%w: data or digest is required for a detached signature",
+			ErrInvalidSignatureRequest,
+		)
+	}
+
+	digestHash, ok := signatureDigestAlgorithms[request.DigestAlgorithm]
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: unknown digest algorithm %s",
+			ErrInvalidSignatureRequest,
+			request.DigestAlgorithm,
+		)
+	}
+
+	return func(hash crypto.Hash) ([]byte, error) {
+		if hash != digestHash {
+			return nil, fmt.Errorf("signer used %s rather than %s", hash, digestHash)
+		}
+
+		return digest, nil
+	}, nil
+}
+
+// checkSignerTrust chains a signer certificate up to the trust anchor and checks nothing along
+// the way has been revoked, either by a CRL embedded in the signature or on the platform
+func (s *SignatureServiceImpl) checkSignerTrust(
+	ctx context.Context,
+	anchor *daos.Certificate,
+	message *cmsSignedMessage,
+	cert *x509.Certificate,
+	roots *x509.CertPool,
+	intermediates *x509.CertPool,
+) error {
+	if !signingKeyUsage(cert) {
+		return errors.New("signer certificate key usage does not allow signing")
+	}
+
+	chains, err := cert.Verify(
+		x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	chain := chains[0]
+	for i := 0; i < len(chain)-1; i++ {
+		if embeddedCRLRevokes(message, chain[i], chain[i+1]) {
+			return fmt.Errorf("certificate %s has been revoked", chain[i].Subject)
+		}
+	}
+
+	// Walk down from the anchor, matching each certificate to its platform record by serial
+	parentID := anchor.ID
+	for i := len(chain) - 2; i >= 0; i-- {
+		dao, err := s.certRepository.GetCertByIssuerAndSerial(ctx, parentID, chain[i].SerialNumber)
+		if errors.Is(err, repositories.ErrNoRecord) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(dao.Data, chain[i].Raw) {
+			return nil
+		}
+
+		if dao.IsRevoked() {
+			return fmt.Errorf("certificate %s has been revoked", chain[i].Subject)
+		}
+
+		parentID = dao.ID
+	}
+
+	return nil
+}
+
+// embeddedCRLRevokes reports whether a CRL embedded in the signature, signed by issuer, lists
+// cert
+func embeddedCRLRevokes(
+	message *cmsSignedMessage,
+	cert *x509.Certificate,
+	issuer *x509.Certificate,
+) bool {
+	for _, crl := range message.crls {
+		if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) || crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// Key revocation lists in the format of OpenSSH's PROTOCOL.krl, as read by sshd's RevokedKeys
+// and ssh-keygen -Q
+
+const (
+	krlMagic         uint64 = 0x5353484b524c0a00
+	krlFormatVersion uint32 = 1
+
+	krlSectionCertificates   byte = 1
+	krlSectionCertSerialList byte = 0x20
+)
+
+// marshalKRL encodes a KRL revoking serials issued by the CA with the SSH wire format caKey. An
+// empty list still produces a valid KRL, which revokes nothing.
+func marshalKRL(caKey []byte, serials []uint64, version uint64, generated time.Time) []byte {
+	krl := binary.BigEndian.AppendUint64(nil, krlMagic)
+	krl = binary.BigEndian.AppendUint32(krl, krlFormatVersion)
+	krl = binary.BigEndian.AppendUint64(krl, version)
+	krl = binary.BigEndian.AppendUint64(krl, uint64(generated.Unix()))
+	// flags, reserved and comment
+	krl = binary.BigEndian.AppendUint64(krl, 0)
+	krl = appendSSHString(krl, nil)
+	krl = appendSSHString(krl, nil)
+
+	if len(serials) == 0 {
+		return krl
+	}
+
+	serialList := make([]byte, 0, 8*len(serials))
+	for _, serial := range serials {
+		serialList = binary.BigEndian.AppendUint64(serialList, serial)
+	}
+
+	section := appendSSHString(nil, caKey)
+	// reserved
+	section = appendSSHString(section, nil)
+	section = append(section, krlSectionCertSerialList)
+	section = appendSSHString(section, serialList)
+
+	krl = append(krl, krlSectionCertificates)
+
+	return appendSSHString(krl, section)
+}
+
+// appendSSHString appends data as an RFC 4251 string, prefixed by its length
+func appendSSHString(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+
+	return append(b, data...)
+}
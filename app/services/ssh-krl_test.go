@@ -0,0 +1,61 @@
+package services
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// The golden KRLs were written by OpenSSH 9.2's ssh-keygen -k -s ssh-krl-ca.pub, which picks
+// the serial list section when the serials are too sparse for a range or bitmap
+func TestMarshalKRL(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ssh-krl-ca.pub"))
+	require.NoError(t, err)
+
+	caKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		serials   []uint64
+		generated time.Time
+		golden    string
+	}{
+		{
+			name:      "no serials",
+			generated: time.Unix(0x6ad35bda, 0),
+			golden:    "krl-empty.golden",
+		},
+		{
+			name:      "serials",
+			serials:   []uint64{7, 1 << 40, 1 << 60},
+			generated: time.Unix(0x6ad35bdd, 0),
+			golden:    "krl-serials.golden",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				krl := marshalKRL(caKey.Marshal(), test.serials, 0, test.generated)
+
+				path := filepath.Join("testdata", test.golden)
+				if *updateGolden {
+					require.NoError(t, os.WriteFile(path, krl, 0o644))
+				}
+
+				expected, err := os.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, expected, krl)
+			},
+		)
+	}
+}
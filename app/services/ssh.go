@@ -0,0 +1,559 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ SSHService = (*SSHServiceImpl)(nil)
+
+var ErrSSHCAUnauthorized = errors.New("user does not have access to this SSH CA")
+var ErrSSHCertificateUnauthorized = errors.New(
+	"user does not have access to this SSH certificate",
+)
+var ErrSSHCertificateAlreadyRevoked = errors.New("SSH certificate has already been revoked")
+var ErrInvalidSSHCertificateRequest = errors.New("invalid SSH certificate request")
+
+// SSHCAKeyFormat selects how ExportCAPublicKey writes the CA key
+type SSHCAKeyFormat string
+
+const (
+	// SSHCAKeyFormatTrustedUserCAKeys is a line for the file sshd's TrustedUserCAKeys points at
+	SSHCAKeyFormatTrustedUserCAKeys SSHCAKeyFormat = "trusted-user-ca-keys"
+	// SSHCAKeyFormatKnownHosts is an @cert-authority line for ssh's known_hosts
+	SSHCAKeyFormatKnownHosts SSHCAKeyFormat = "known-hosts"
+)
+
+const (
+	sshCriticalOptionForceCommand  = "force-command"
+	sshCriticalOptionSourceAddress = "source-address"
+)
+
+// sshDefaultUserExtensions are what ssh-keygen grants user certificates unless told otherwise
+var sshDefaultUserExtensions = map[string]string{
+	"permit-X11-forwarding":   "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+	"permit-pty":              "",
+	"permit-user-rc":          "",
+}
+
+// SSHService runs SSH certificate authorities on top of the keys KeyService manages. Issued
+// certificates are stored so they can be listed and revoked through a KRL.
+type SSHService interface {
+	CreateCAForUser(
+		ctx context.Context,
+		userID string,
+		request *contracts.CreateSSHCARequest,
+	) (*contracts.SSHCAResponse, error)
+	GetCAsForUser(ctx context.Context, userID string) ([]*contracts.SSHCAResponse, error)
+	// ExportCAPublicKey returns the CA key as a line for sshd or ssh to trust. hosts is the
+	// known_hosts host pattern list, * when empty.
+	ExportCAPublicKey(
+		ctx context.Context,
+		caID string,
+		format SSHCAKeyFormat,
+		hosts string,
+	) ([]byte, error)
+	SignCertificateForUser(
+		ctx context.Context,
+		caID string,
+		userID string,
+		request *contracts.SignSSHCertificateRequest,
+	) (*contracts.SSHCertificateResponse, error)
+	GetCertificatesForUser(
+		ctx context.Context,
+		caID string,
+		userID string,
+	) ([]*contracts.SSHCertificateResponse, error)
+	GetCertificateForUser(
+		ctx context.Context,
+		id string,
+		userID string,
+	) (*contracts.SSHCertificateResponse, error)
+	RevokeCertificateForUser(ctx context.Context, id string, userID string) error
+	// GetKRL returns a key revocation list of every certificate the CA revoked
+	GetKRL(ctx context.Context, caID string) ([]byte, error)
+}
+
+type SSHServiceImpl struct {
+	sshRepository repositories.SSHRepository
+	keyService    KeyService
+	auditService  AuditService
+}
+
+func NewSSHServiceImpl(
+	sshRepository repositories.SSHRepository,
+	keyService KeyService,
+	auditService AuditService,
+) *SSHServiceImpl {
+	return &SSHServiceImpl{
+		sshRepository: sshRepository,
+		keyService:    keyService,
+		auditService:  auditService,
+	}
+}
+
+func (s *SSHServiceImpl) CreateCAForUser(
+	ctx context.Context,
+	userID string,
+	request *contracts.CreateSSHCARequest,
+) (*contracts.SSHCAResponse, error) {
+	if request.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidSSHCertificateRequest)
+	}
+
+	signer, err := s.getSigner(ctx, request.KeyID, userID, request.KeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	ca := &daos.SSHCertificateAuthority{
+		UserID:    userID,
+		Name:      request.Name,
+		KeyID:     request.KeyID,
+		PublicKey: signer.PublicKey().Marshal(),
+	}
+
+	err = s.sshRepository.CreateCA(ctx, ca)
+	if err != nil {
+		return nil, err
+	}
+
+	return sshCAResponse(ca)
+}
+
+func (s *SSHServiceImpl) GetCAsForUser(
+	ctx context.Context,
+	userID string,
+) ([]*contracts.SSHCAResponse, error) {
+	cas, err := s.sshRepository.GetCAsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*contracts.SSHCAResponse, len(cas))
+	for i, ca := range cas {
+		response[i], err = sshCAResponse(ca)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+func sshCAResponse(ca *daos.SSHCertificateAuthority) (*contracts.SSHCAResponse, error) {
+	publicKey, err := ssh.ParsePublicKey(ca.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contracts.SSHCAResponse{
+		ID:        ca.ID,
+		Name:      ca.Name,
+		KeyID:     ca.KeyID,
+		PublicKey: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		Created:   ca.Created,
+	}, nil
+}
+
+// getSigner unlocks a key for signing SSH certificates
+func (s *SSHServiceImpl) getSigner(
+	ctx context.Context,
+	keyID string,
+	userID string,
+	password string,
+) (ssh.Signer, error) {
+	key, err := s.keyService.GetDecryptedKeyForUser(ctx, keyID, userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	cryptoSigner, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("SSH CA key is not capable of signing")
+	}
+
+	return ssh.NewSignerFromSigner(cryptoSigner)
+}
+
+func (s *SSHServiceImpl) ExportCAPublicKey(
+	ctx context.Context,
+	caID string,
+	format SSHCAKeyFormat,
+	hosts string,
+) ([]byte, error) {
+	ca, err := s.sshRepository.GetCA(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := ssh.ParsePublicKey(ca.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comments run to the end of the line, so the name needs no quoting
+	line := fmt.Sprintf(
+		"%s %s\n",
+		strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		ca.Name,
+	)
+
+	switch format {
+	case "", SSHCAKeyFormatTrustedUserCAKeys:
+		return []byte(line), nil
+	case SSHCAKeyFormatKnownHosts:
+		if hosts == "" {
+			hosts = "*"
+		}
+
+		if strings.ContainsAny(hosts, " \t\r\n") {
+			return nil, fmt.Errorf(
+				"%w: host patterns are separated by commas, not whitespace",
+				ErrInvalidSSHCertificateRequest,
+			)
+		}
+
+		return []byte("@cert-authority " + hosts + " " + line), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyFormat, format)
+	}
+}
+
+func (s *SSHServiceImpl) SignCertificateForUser(
+	ctx context.Context,
+	caID string,
+	userID string,
+	request *contracts.SignSSHCertificateRequest,
+) (resp *contracts.SSHCertificateResponse, err error) {
+	defer func() {
+		certID := ""
+		if resp != nil {
+			certID = resp.ID
+		}
+
+		s.auditService.Record(ctx, userID, AuditSSHCertificateSign, certID, err)
+	}()
+
+	ca, err := s.getCAForUser(ctx, caID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := sshCertificateTemplate(request)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := s.getSigner(ctx, ca.KeyID, userID, request.CAKeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	certDao := &daos.SSHCertificate{
+		UserID:      userID,
+		CAID:        ca.ID,
+		CertType:    request.CertType,
+		KeyIdentity: cert.KeyId,
+		Principals:  cert.ValidPrincipals,
+		ValidAfter:  time.Unix(int64(cert.ValidAfter), 0),
+		ValidBefore: time.Unix(int64(cert.ValidBefore), 0),
+	}
+
+	for attempt := 0; attempt < serialNumberAttempts; attempt++ {
+		cert.Serial, err = newSSHSerial()
+		if err != nil {
+			return nil, err
+		}
+
+		err = cert.SignCert(rand.Reader, signer)
+		if err != nil {
+			return nil, err
+		}
+
+		certDao.Serial = int64(cert.Serial)
+		certDao.Data = cert.Marshal()
+
+		err = s.sshRepository.CreateCertificate(ctx, certDao)
+		if !errors.Is(err, repositories.ErrDuplicateRecord) {
+			break
+		}
+	}
+	if errors.Is(err, repositories.ErrDuplicateRecord) {
+		return nil, ErrSerialNumberExhausted
+	} else if err != nil {
+		return nil, err
+	}
+
+	return sshCertificateResponse(certDao)
+}
+
+// sshCertificateTemplate validates a signing request and turns it into an unsigned certificate
+func sshCertificateTemplate(
+	request *contracts.SignSSHCertificateRequest,
+) (*ssh.Certificate, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(request.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: public key: %v", ErrInvalidSSHCertificateRequest, err)
+	}
+
+	if _, ok := publicKey.(*ssh.Certificate); ok {
+		return nil, fmt.Errorf(
+			"%w: the public key is already a certificate",
+			ErrInvalidSSHCertificateRequest,
+		)
+	}
+
+	if request.KeyID == "" {
+		return nil, fmt.Errorf("%w: keyId is required", ErrInvalidSSHCertificateRequest)
+	}
+
+	// A certificate without principals is valid for every user or host
+	if len(request.Principals) == 0 {
+		return nil, fmt.Errorf(
+			"%w: at least one principal is required",
+			ErrInvalidSSHCertificateRequest,
+		)
+	}
+
+	notBefore := request.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+
+	if !request.Expiration.After(notBefore) {
+		return nil, fmt.Errorf(
+			"%w: expiration must be after notBefore",
+			ErrInvalidSSHCertificateRequest,
+		)
+	}
+
+	cert := &ssh.Certificate{
+		Key:             publicKey,
+		KeyId:           request.KeyID,
+		ValidPrincipals: request.Principals,
+		ValidAfter:      uint64(notBefore.Unix()),
+		ValidBefore:     uint64(request.Expiration.Unix()),
+	}
+
+	switch request.CertType {
+	case contracts.SSHCertTypeUser:
+		cert.CertType = ssh.UserCert
+		cert.CriticalOptions, err = sshCriticalOptions(request)
+		if err != nil {
+			return nil, err
+		}
+
+		cert.Extensions = request.Extensions
+		if cert.Extensions == nil {
+			cert.Extensions = sshDefaultUserExtensions
+		}
+	case contracts.SSHCertTypeHost:
+		// OpenSSH defines no critical options or extensions for host certificates
+		if request.ForceCommand != "" || len(request.SourceAddresses) > 0 ||
+			len(request.Extensions) > 0 {
+			return nil, fmt.Errorf(
+				"%w: host certificates take no critical options or extensions",
+				ErrInvalidSSHCertificateRequest,
+			)
+		}
+
+		cert.CertType = ssh.HostCert
+	default:
+		return nil, fmt.Errorf(
+			"%w: certType must be %s or %s",
+			ErrInvalidSSHCertificateRequest,
+			contracts.SSHCertTypeUser,
+			contracts.SSHCertTypeHost,
+		)
+	}
+
+	return cert, nil
+}
+
+func sshCriticalOptions(request *contracts.SignSSHCertificateRequest) (map[string]string, error) {
+	options := map[string]string{}
+
+	if request.ForceCommand != "" {
+		options[sshCriticalOptionForceCommand] = request.ForceCommand
+	}
+
+	for _, address := range request.SourceAddresses {
+		_, _, err := net.ParseCIDR(address)
+		if err != nil && net.ParseIP(address) == nil {
+			return nil, fmt.Errorf(
+				"%w: source address %q is neither an address nor a CIDR range",
+				ErrInvalidSSHCertificateRequest,
+				address,
+			)
+		}
+	}
+
+	if len(request.SourceAddresses) > 0 {
+		options[sshCriticalOptionSourceAddress] = strings.Join(request.SourceAddresses, ",")
+	}
+
+	return options, nil
+}
+
+// newSSHSerial returns a random serial that fits the signed columns it is stored in and is not
+// zero, which OpenSSH refuses to revoke
+func newSSHSerial() (uint64, error) {
+	b := make([]byte, 8)
+	for {
+		_, err := rand.Read(b)
+		if err != nil {
+			return 0, err
+		}
+
+		serial := binary.BigEndian.Uint64(b) >> 1
+		if serial != 0 {
+			return serial, nil
+		}
+	}
+}
+
+func sshCertificateResponse(
+	certDao *daos.SSHCertificate,
+) (*contracts.SSHCertificateResponse, error) {
+	cert, err := ssh.ParsePublicKey(certDao.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := certDao.ToResponse()
+	resp.Certificate = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert)))
+
+	return resp, nil
+}
+
+func (s *SSHServiceImpl) getCAForUser(
+	ctx context.Context,
+	caID string,
+	userID string,
+) (*daos.SSHCertificateAuthority, error) {
+	ca, err := s.sshRepository.GetCA(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ca.UserID != userID {
+		return nil, ErrSSHCAUnauthorized
+	}
+
+	return ca, nil
+}
+
+func (s *SSHServiceImpl) GetCertificatesForUser(
+	ctx context.Context,
+	caID string,
+	userID string,
+) ([]*contracts.SSHCertificateResponse, error) {
+	_, err := s.getCAForUser(ctx, caID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := s.sshRepository.GetCertificatesForCA(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*contracts.SSHCertificateResponse, len(certs))
+	for i, cert := range certs {
+		response[i], err = sshCertificateResponse(cert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+func (s *SSHServiceImpl) GetCertificateForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) (*contracts.SSHCertificateResponse, error) {
+	cert, err := s.getCertificateForUser(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sshCertificateResponse(cert)
+}
+
+func (s *SSHServiceImpl) getCertificateForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) (*daos.SSHCertificate, error) {
+	cert, err := s.sshRepository.GetCertificate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.UserID != userID {
+		return nil, ErrSSHCertificateUnauthorized
+	}
+
+	return cert, nil
+}
+
+func (s *SSHServiceImpl) RevokeCertificateForUser(
+	ctx context.Context,
+	id string,
+	userID string,
+) (err error) {
+	defer func() {
+		s.auditService.Record(ctx, userID, AuditSSHCertificateRevoke, id, err)
+	}()
+
+	cert, err := s.getCertificateForUser(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if cert.IsRevoked() {
+		return ErrSSHCertificateAlreadyRevoked
+	}
+
+	return s.sshRepository.RevokeCertificate(ctx, id, time.Now())
+}
+
+// GetKRL builds the list on every request. It is unsigned, like those ssh-keygen writes by
+// default, and versioned by when it was generated.
+func (s *SSHServiceImpl) GetKRL(ctx context.Context, caID string) ([]byte, error) {
+	ca, err := s.sshRepository.GetCA(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.sshRepository.GetRevokedCertificates(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	serials := make([]uint64, len(revoked))
+	for i, cert := range revoked {
+		serials[i] = uint64(cert.Serial)
+	}
+
+	now := time.Now()
+
+	return marshalKRL(ca.PublicKey, serials, uint64(now.Unix()), now), nil
+}
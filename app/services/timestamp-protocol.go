@@ -0,0 +1,250 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time-Stamp Protocol messages from RFC 3161, along with the ESS signing certificate attribute
+// from RFC 5035 that binds a token to the TSA certificate
+
+var (
+	oidTSTInfo              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+)
+
+// PKIStatus values a TimeStampResp can carry
+const (
+	tspStatusGranted         = 0
+	tspStatusGrantedWithMods = 1
+	tspStatusRejection       = 2
+)
+
+// PKIFailureInfo bits explaining a rejection
+const (
+	tspFailureBadAlg              = 0
+	tspFailureBadRequest          = 2
+	tspFailureBadDataFormat       = 5
+	tspFailureUnacceptedPolicy    = 15
+	tspFailureUnacceptedExtension = 16
+	tspFailureSystemFailure       = 25
+)
+
+// tspHashAlgorithms are the message imprint algorithms the TSA accepts, by the names tokens are
+// recorded under
+var tspHashAlgorithms = map[crypto.Hash]string{
+	crypto.SHA256: "sha256",
+	crypto.SHA384: "sha384",
+	crypto.SHA512: "sha512",
+}
+
+type tspMessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type tspRequest struct {
+	Version        int
+	MessageImprint tspMessageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+type tspAccuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tspInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint tspMessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       tspAccuracy      `asn1:"optional"`
+	Ordering       bool             `asn1:"optional"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+type tspStatusInfo struct {
+	Status int
+	// StatusString holds UTF8Strings, which a []string would write as PrintableString
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+type tspResponse struct {
+	Status         tspStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type essSigningCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// essCertIDv2 leaves the hash algorithm out, which DER requires when it is the SHA-256 default
+type essCertIDv2 struct {
+	HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+	CertHash      []byte
+	IssuerSerial  asn1.RawValue `asn1:"optional"`
+}
+
+// tspRejection is a TimeStampResp refusing a request, failureBit is one of the tspFailure values
+func tspRejection(failureBit int, reason string) ([]byte, error) {
+	failInfo := asn1.BitString{
+		Bytes:     make([]byte, failureBit/8+1),
+		BitLength: failureBit + 1,
+	}
+	failInfo.Bytes[failureBit/8] = 0x80 >> (failureBit % 8)
+
+	return asn1.Marshal(
+		tspResponse{
+			Status: tspStatusInfo{
+				Status: tspStatusRejection,
+				StatusString: []asn1.RawValue{
+					{Tag: asn1.TagUTF8String, Bytes: []byte(reason)},
+				},
+				FailInfo: failInfo,
+			},
+		},
+	)
+}
+
+// tspGranted wraps a token in a TimeStampResp
+func tspGranted(token []byte) ([]byte, error) {
+	return asn1.Marshal(
+		tspResponse{
+			Status:         tspStatusInfo{Status: tspStatusGranted},
+			TimeStampToken: asn1.RawValue{FullBytes: token},
+		},
+	)
+}
+
+// tspToken pulls the token out of a TimeStampResp, or returns der unchanged when it is a bare
+// token. The two are told apart by their first element, a TimeStampResp starts with the status
+// SEQUENCE and a ContentInfo with its content type.
+func tspToken(der []byte) ([]byte, error) {
+	var outer asn1.RawValue
+	_, err := asn1.Unmarshal(der, &outer)
+	if err != nil {
+		return nil, err
+	}
+
+	var first asn1.RawValue
+	_, err = asn1.Unmarshal(outer.Bytes, &first)
+	if err != nil {
+		return nil, err
+	}
+
+	if first.Tag == asn1.TagOID {
+		return der, nil
+	}
+
+	var resp tspResponse
+	rest, err := asn1.Unmarshal(der, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after time stamp response")
+	}
+
+	status := resp.Status.Status
+	if status != tspStatusGranted && status != tspStatusGrantedWithMods {
+		return nil, errors.New("time stamp response does not carry a token")
+	}
+
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+// newSigningCertificateV2 returns the attribute identifying the TSA certificate by its hash
+func newSigningCertificateV2(certDER []byte) (cmsAttribute, error) {
+	hash := sha256.Sum256(certDER)
+
+	return newCMSAttribute(
+		oidSigningCertificateV2,
+		essSigningCertificateV2{
+			Certs: []essCertIDv2{{CertHash: hash[:]}},
+		},
+	)
+}
+
+// signingCertificateMatches reports whether the signer's signingCertificateV2 attribute names
+// the certificate. Only the SHA-256 form written by newSigningCertificateV2 is recognised.
+func signingCertificateMatches(signerInfo *cmsSignerInfo, certDER []byte) (bool, error) {
+	hash := sha256.Sum256(certDER)
+
+	rest := signerInfo.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		var attribute cmsAttribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &attribute)
+		if err != nil {
+			return false, err
+		}
+
+		if !attribute.Type.Equal(oidSigningCertificateV2) {
+			continue
+		}
+
+		var signingCertificate essSigningCertificateV2
+		_, err = asn1.Unmarshal(attribute.Values.Bytes, &signingCertificate)
+		if err != nil {
+			return false, err
+		}
+
+		if len(signingCertificate.Certs) == 0 {
+			return false, nil
+		}
+
+		// The first ESSCertIDv2 is the signer's, the rest are only hints
+		certID := signingCertificate.Certs[0]
+		algorithm := certID.HashAlgorithm.Algorithm
+		if len(algorithm) > 0 && !algorithm.Equal(oidDigestSHA256) {
+			return false, fmt.Errorf("%w: signing certificate hash %s", errUnsupportedCMS, algorithm)
+		}
+
+		return bytes.Equal(certID.CertHash, hash[:]), nil
+	}
+
+	return false, nil
+}
+
+// parsePolicyOID reads a dotted decimal object identifier such as 1.3.6.1.4.1.99999.1
+func parsePolicyOID(policy string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(policy, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%s is not an object identifier", policy)
+	}
+
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		arc, err := strconv.Atoi(part)
+		if err != nil || arc < 0 {
+			return nil, fmt.Errorf("%s is not an object identifier", policy)
+		}
+
+		oid[i] = arc
+	}
+
+	if oid[0] > 2 || (oid[0] < 2 && oid[1] > 39) {
+		return nil, fmt.Errorf("%s is not an object identifier", policy)
+	}
+
+	return oid, nil
+}
@@ -0,0 +1,672 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/context/logger"
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+)
+
+// revocationReasonKeyCompromise voids every token a TSA issued, other revocation reasons only
+// void the tokens issued after it
+const revocationReasonKeyCompromise = 1
+
+var _ TimestampService = (*TimestampServiceImpl)(nil)
+
+var ErrTimestampAuthorityUnauthorized = errors.New("user does not have access to this TSA")
+var ErrInvalidTimestampRequest = errors.New("invalid time stamp request")
+
+// TimestampService runs RFC 3161 time-stamping authorities on certificates issued for the
+// timeStamping extended key usage. Every issued token is recorded so it can be listed and
+// checked later.
+type TimestampService interface {
+	CreateAuthorityForUser(
+		ctx context.Context,
+		userID string,
+		request *contracts.CreateTimestampAuthorityRequest,
+	) (*contracts.TimestampAuthorityResponse, error)
+	GetAuthoritiesForUser(
+		ctx context.Context,
+		userID string,
+	) ([]*contracts.TimestampAuthorityResponse, error)
+	GetTokensForUser(
+		ctx context.Context,
+		tsaID string,
+		userID string,
+	) ([]*contracts.TimestampTokenResponse, error)
+	// Respond answers a DER encoded TimeStampReq. Requests the TSA can't grant are answered with
+	// a rejection TimeStampResp, errors are kept for a TSA that can't be found.
+	Respond(ctx context.Context, tsaID string, rawRequest []byte) ([]byte, error)
+	// Verify checks a token against the TSA that issued it. A token that doesn't verify is
+	// reported in the response, errors are kept for requests that can't be checked at all.
+	Verify(
+		ctx context.Context,
+		tsaID string,
+		request *contracts.VerifyTimestampRequest,
+	) (*contracts.VerifyTimestampResponse, error)
+}
+
+type TimestampServiceImpl struct {
+	timestampRepository repositories.TimestampRepository
+	certRepository      repositories.CertRepository
+	keyService          KeyService
+	secretKey           string
+}
+
+func NewTimestampServiceImpl(
+	timestampRepository repositories.TimestampRepository,
+	certRepository repositories.CertRepository,
+	keyService KeyService,
+	secretKey string,
+) *TimestampServiceImpl {
+	return &TimestampServiceImpl{
+		timestampRepository: timestampRepository,
+		certRepository:      certRepository,
+		keyService:          keyService,
+		secretKey:           secretKey,
+	}
+}
+
+func (s *TimestampServiceImpl) CreateAuthorityForUser(
+	ctx context.Context,
+	userID string,
+	request *contracts.CreateTimestampAuthorityRequest,
+) (*contracts.TimestampAuthorityResponse, error) {
+	if request.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTimestampRequest)
+	}
+
+	for _, policy := range append([]string{request.Policy}, request.AcceptedPolicies...) {
+		_, err := parsePolicyOID(policy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: policy %s", ErrInvalidTimestampRequest, err)
+		}
+	}
+
+	if request.AccuracyMillis < 0 {
+		return nil, fmt.Errorf("%w: accuracy can't be negative", ErrInvalidTimestampRequest)
+	}
+
+	cert, err := s.certRepository.GetCertByID(ctx, request.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.UserID != userID {
+		return nil, ErrCertUnautorized
+	}
+
+	if cert.KeyID == "" {
+		return nil, ErrCertHasNoKey
+	}
+
+	if cert.IsRevoked() {
+		return nil, fmt.Errorf("%w: certificate has been revoked", ErrInvalidTimestampRequest)
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Data)
+	if err != nil {
+		return nil, ErrUnknownCertFormat
+	}
+
+	if !timestampingCertificate(x509Cert) {
+		return nil, fmt.Errorf(
+			"%w: certificate must have timeStamping as its only, critical, extended key usage",
+			ErrInvalidTimestampRequest,
+		)
+	}
+
+	// Make sure the password actually unlocks the key before it is stored
+	key, err := s.keyService.GetDecryptedKeyForUser(ctx, cert.KeyID, userID, request.KeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("certificate key is not capable of signing")
+	}
+
+	_, err = cmsSignatureAlgorithm(signer.Public(), timestampSigningHash(signer.Public()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimestampRequest, err)
+	}
+
+	sealedKeyPassword, err := utils.Seal(s.secretKey, []byte(request.KeyPassword))
+	if err != nil {
+		return nil, err
+	}
+
+	acceptedPolicies := request.AcceptedPolicies
+	if acceptedPolicies == nil {
+		acceptedPolicies = []string{}
+	}
+
+	tsa := &daos.TimestampAuthority{
+		UserID:            userID,
+		Name:              request.Name,
+		CertificateID:     cert.ID,
+		SealedKeyPassword: sealedKeyPassword,
+		Policy:            request.Policy,
+		AcceptedPolicies:  acceptedPolicies,
+		AccuracyMillis:    request.AccuracyMillis,
+		RequireNonce:      request.RequireNonce,
+	}
+
+	err = s.timestampRepository.CreateAuthority(ctx, tsa)
+	if err != nil {
+		return nil, err
+	}
+
+	return tsa.ToResponse(), nil
+}
+
+// timestampingCertificate reports whether a certificate may sign time stamps. RFC 3161 requires
+// timeStamping to be its only extended key usage, in a critical extension.
+func timestampingCertificate(cert *x509.Certificate) bool {
+	if len(cert.ExtKeyUsage) != 1 || cert.ExtKeyUsage[0] != x509.ExtKeyUsageTimeStamping ||
+		len(cert.UnknownExtKeyUsage) > 0 {
+		return false
+	}
+
+	for _, extension := range cert.Extensions {
+		if extension.Id.Equal(oidExtensionExtKeyUsage) {
+			return extension.Critical
+		}
+	}
+
+	return false
+}
+
+// timestampSigningHash matches the digest to the key's strength, RFC 8419 pairs Ed25519 with
+// SHA-512
+func timestampSigningHash(publicKey crypto.PublicKey) crypto.Hash {
+	switch publicKey := publicKey.(type) {
+	case ed25519.PublicKey:
+		return crypto.SHA512
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve {
+		case elliptic.P384():
+			return crypto.SHA384
+		case elliptic.P521():
+			return crypto.SHA512
+		}
+	}
+
+	return crypto.SHA256
+}
+
+func (s *TimestampServiceImpl) GetAuthoritiesForUser(
+	ctx context.Context,
+	userID string,
+) ([]*contracts.TimestampAuthorityResponse, error) {
+	tsas, err := s.timestampRepository.GetAuthoritiesForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*contracts.TimestampAuthorityResponse, len(tsas))
+	for i, tsa := range tsas {
+		response[i] = tsa.ToResponse()
+	}
+
+	return response, nil
+}
+
+func (s *TimestampServiceImpl) GetTokensForUser(
+	ctx context.Context,
+	tsaID string,
+	userID string,
+) ([]*contracts.TimestampTokenResponse, error) {
+	tsa, err := s.timestampRepository.GetAuthority(ctx, tsaID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tsa.UserID != userID {
+		return nil, ErrTimestampAuthorityUnauthorized
+	}
+
+	tokens, err := s.timestampRepository.GetTokensForAuthority(ctx, tsaID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*contracts.TimestampTokenResponse, len(tokens))
+	for i, token := range tokens {
+		response[i] = token.ToResponse()
+	}
+
+	return response, nil
+}
+
+func (s *TimestampServiceImpl) Respond(
+	ctx context.Context,
+	tsaID string,
+	rawRequest []byte,
+) ([]byte, error) {
+	log := logger.Get(ctx)
+
+	tsa, err := s.timestampRepository.GetAuthority(ctx, tsaID)
+	if err != nil {
+		return nil, err
+	}
+
+	var req tspRequest
+	rest, err := asn1.Unmarshal(rawRequest, &req)
+	if err != nil || len(rest) > 0 {
+		return tspRejection(tspFailureBadDataFormat, "request is not a DER TimeStampReq")
+	}
+
+	if req.Version != 1 {
+		return tspRejection(tspFailureBadRequest, "unsupported request version")
+	}
+
+	hash, err := cmsHashForOID(req.MessageImprint.HashAlgorithm.Algorithm)
+	hashName, ok := tspHashAlgorithms[hash]
+	if err != nil || !ok {
+		return tspRejection(tspFailureBadAlg, "unsupported message imprint algorithm")
+	}
+
+	if len(req.MessageImprint.HashedMessage) != hash.Size() {
+		return tspRejection(tspFailureBadDataFormat, "message imprint has the wrong length")
+	}
+
+	policy, ok := timestampPolicy(tsa, req.ReqPolicy)
+	if !ok {
+		return tspRejection(tspFailureUnacceptedPolicy, "requested policy is not accepted")
+	}
+
+	if tsa.RequireNonce && req.Nonce == nil {
+		return tspRejection(tspFailureBadRequest, "a nonce is required")
+	}
+
+	if len(req.Extensions) > 0 {
+		return tspRejection(tspFailureUnacceptedExtension, "extensions are not supported")
+	}
+
+	token, err := s.issueToken(ctx, tsa, &req, policy, hashName)
+	if err != nil {
+		log.WithError(err).Errorf("failed to issue time stamp for TSA %s", tsaID)
+		return tspRejection(tspFailureSystemFailure, "time stamp could not be issued")
+	}
+
+	return tspGranted(token)
+}
+
+// timestampPolicy picks the policy for a token, the TSA's own unless the request asked for one
+// it accepts
+func timestampPolicy(
+	tsa *daos.TimestampAuthority,
+	requested asn1.ObjectIdentifier,
+) (asn1.ObjectIdentifier, bool) {
+	policy, err := parsePolicyOID(tsa.Policy)
+	if err != nil || len(requested) == 0 || requested.Equal(policy) {
+		return policy, err == nil
+	}
+
+	for _, accepted := range tsa.AcceptedPolicies {
+		if accepted == requested.String() {
+			return requested, true
+		}
+	}
+
+	return nil, false
+}
+
+// issueToken signs and records a token for an accepted request
+func (s *TimestampServiceImpl) issueToken(
+	ctx context.Context,
+	tsa *daos.TimestampAuthority,
+	req *tspRequest,
+	policy asn1.ObjectIdentifier,
+	hashName string,
+) ([]byte, error) {
+	cert, err := s.certRepository.GetCertByID(ctx, tsa.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.IsRevoked() {
+		return nil, errors.New("TSA certificate has been revoked")
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	genTime := time.Now().UTC().Truncate(time.Second)
+	if genTime.Before(x509Cert.NotBefore) || genTime.After(x509Cert.NotAfter) {
+		return nil, errors.New("TSA certificate is not currently valid")
+	}
+
+	keyPassword, err := utils.Open(s.secretKey, tsa.SealedKeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.keyService.GetDecryptedKeyForUser(
+		ctx,
+		cert.KeyID,
+		tsa.UserID,
+		string(keyPassword),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("TSA key is not capable of signing")
+	}
+
+	serialNumber, err := s.newTimestampSerial(ctx, tsa.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	info := tspInfo{
+		Version:        1,
+		Policy:         policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   serialNumber,
+		GenTime:        genTime,
+		Accuracy: tspAccuracy{
+			Seconds: tsa.AccuracyMillis / 1000,
+			Millis:  tsa.AccuracyMillis % 1000,
+		},
+		Nonce: req.Nonce,
+	}
+
+	content, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	signingCertificate, err := newSigningCertificateV2(cert.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var certificates [][]byte
+	if req.CertReq {
+		chain, err := certChain(ctx, s.certRepository, cert, false)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, chainCert := range chain {
+			certificates = append(certificates, chainCert.Data)
+		}
+	}
+
+	hash := timestampSigningHash(signer.Public())
+	h := hash.New()
+	h.Write(content)
+
+	token, err := signCMS(
+		&cmsSignParams{
+			ContentType:     oidTSTInfo,
+			Content:         content,
+			Digest:          h.Sum(nil),
+			Hash:            hash,
+			Certificate:     x509Cert,
+			Key:             signer,
+			SigningTime:     genTime,
+			ExtraAttributes: []cmsAttribute{signingCertificate},
+			Certificates:    certificates,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &daos.Timestamp{
+		TSAID:          tsa.ID,
+		SerialNumber:   serialNumber.String(),
+		GenTime:        genTime,
+		Policy:         policy.String(),
+		HashAlgorithm:  hashName,
+		MessageImprint: req.MessageImprint.HashedMessage,
+		Token:          token,
+	}
+	if req.Nonce != nil {
+		record.Nonce = req.Nonce.String()
+	}
+
+	err = s.timestampRepository.CreateToken(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// newTimestampSerial returns a random positive token serial the TSA has not used yet
+func (s *TimestampServiceImpl) newTimestampSerial(
+	ctx context.Context,
+	tsaID string,
+) (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), serialNumberBits)
+
+	for i := 0; i < serialNumberAttempts; i++ {
+		serial, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		if serial.Sign() == 0 {
+			continue
+		}
+
+		_, err = s.timestampRepository.GetTokenBySerial(ctx, tsaID, serial.String())
+		if errors.Is(err, repositories.ErrNoRecord) {
+			return serial, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, ErrSerialNumberExhausted
+}
+
+func (s *TimestampServiceImpl) Verify(
+	ctx context.Context,
+	tsaID string,
+	request *contracts.VerifyTimestampRequest,
+) (*contracts.VerifyTimestampResponse, error) {
+	tsa, err := s.timestampRepository.GetAuthority(ctx, tsaID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := decodeTimestampField("token", request.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decodeTimestampField("data", request.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := decodeTimestampField("digest", request.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw == nil {
+		return nil, fmt.Errorf("%w: token is required", ErrInvalidTimestampRequest)
+	}
+
+	if (data == nil) == (digest == nil) {
+		return nil, fmt.Errorf("%w: one of data or digest is required", ErrInvalidTimestampRequest)
+	}
+
+	token, err := tspToken(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimestampRequest, err)
+	}
+
+	message, err := parseCMS(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimestampRequest, err)
+	}
+
+	encapsulated := message.signedData.EncapContentInfo
+	if !encapsulated.EContentType.Equal(oidTSTInfo) {
+		return nil, fmt.Errorf("%w: token does not hold a TSTInfo", ErrInvalidTimestampRequest)
+	}
+
+	var info tspInfo
+	_, err = asn1.Unmarshal(encapsulated.EContent, &info)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimestampRequest, err)
+	}
+
+	resp := &contracts.VerifyTimestampResponse{
+		SerialNumber: info.SerialNumber.String(),
+		GenTime:      info.GenTime,
+		Policy:       info.Policy.String(),
+	}
+	if info.Nonce != nil {
+		resp.Nonce = info.Nonce.String()
+	}
+
+	cert, err := s.certRepository.GetCertByID(ctx, tsa.CertificateID)
+	if err != nil {
+		return nil, err
+	}
+
+	x509Cert, err := x509.ParseCertificate(cert.Data)
+	if err != nil {
+		return nil, ErrUnknownCertFormat
+	}
+
+	// A serial the TSA never issued leaves record nil, which checkToken reports
+	record, err := s.timestampRepository.GetTokenBySerial(ctx, tsa.ID, info.SerialNumber.String())
+	if err != nil && !errors.Is(err, repositories.ErrNoRecord) {
+		return nil, err
+	}
+
+	err = checkToken(token, message, &info, cert, x509Cert, record, data, digest)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp, nil
+	}
+
+	resp.Valid = true
+
+	return resp, nil
+}
+
+// decodeTimestampField decodes a base64 request field, nil when it was left empty
+func decodeTimestampField(name string, value string) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not valid base64", ErrInvalidTimestampRequest, name)
+	}
+
+	return decoded, nil
+}
+
+// checkToken returns why a parsed token doesn't hold up, nil when it does. cert is the TSA
+// certificate and record the token the TSA recorded under the same serial, if any.
+func checkToken(
+	token []byte,
+	message *cmsSignedMessage,
+	info *tspInfo,
+	cert *daos.Certificate,
+	x509Cert *x509.Certificate,
+	record *daos.Timestamp,
+	data []byte,
+	digest []byte,
+) error {
+	// Tokens only carry the TSA certificate when the requester asked for it
+	message.certificates = append(message.certificates, x509Cert)
+
+	if len(message.signedData.SignerInfos) != 1 {
+		return errors.New("token must have exactly one signer")
+	}
+
+	result := message.verifySigners(
+		func(hash crypto.Hash) ([]byte, error) {
+			h := hash.New()
+			h.Write(message.signedData.EncapContentInfo.EContent)
+			return h.Sum(nil), nil
+		},
+	)[0]
+	if result.err != nil {
+		return result.err
+	}
+
+	if !bytes.Equal(result.certificate.Raw, x509Cert.Raw) {
+		return errors.New("token was not signed by this TSA")
+	}
+
+	matches, err := signingCertificateMatches(&message.signedData.SignerInfos[0], cert.Data)
+	if err != nil {
+		return err
+	}
+
+	if !matches {
+		return errors.New("signing certificate attribute does not name the TSA certificate")
+	}
+
+	if !timestampingCertificate(x509Cert) {
+		return errors.New("TSA certificate is not a time stamping certificate")
+	}
+
+	if info.GenTime.Before(x509Cert.NotBefore) || info.GenTime.After(x509Cert.NotAfter) {
+		return errors.New("token was issued outside the TSA certificate's validity period")
+	}
+
+	if cert.IsRevoked() &&
+		(cert.RevocationReason == revocationReasonKeyCompromise ||
+			!info.GenTime.Before(*cert.RevokedAt)) {
+		return errors.New("TSA certificate has been revoked")
+	}
+
+	hash, err := cmsHashForOID(info.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	if data != nil {
+		h := hash.New()
+		h.Write(data)
+		digest = h.Sum(nil)
+	}
+
+	if !bytes.Equal(digest, info.MessageImprint.HashedMessage) {
+		return errors.New("message imprint does not match the data")
+	}
+
+	if record == nil || !bytes.Equal(record.Token, token) {
+		return errors.New("token was not issued by this TSA")
+	}
+
+	return nil
+}
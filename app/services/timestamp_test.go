@@ -0,0 +1,636 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/testutils"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TimeStampReqs written by openssl ts -query for the data below, and edits of them
+const (
+	timestampTestData = "time stamped document"
+
+	// -sha256 -cert, with a nonce
+	tsqNonceCertReq = "30440201013031300d060960864801650304020105000420cb74ab5e9a061377e7b514e8c19c" +
+		"d30f532df3eaf3c8358f2a0f0238ebce41df02090084a578999a2519780101ff"
+	// -sha256 -no_nonce
+	tsqNoNonce = "30360201013031300d060960864801650304020105000420cb74ab5e9a061377e7b514e8c19cd30f" +
+		"532df3eaf3c8358f2a0f0238ebce41df"
+	// -sha256 -tspolicy 1.3.6.1.4.1.99999.2, with a nonce
+	tsqAcceptedPolicy = "304b0201013031300d060960864801650304020105000420cb74ab5e9a061377e7b514e8c1" +
+		"9cd30f532df3eaf3c8358f2a0f0238ebce41df06092b06010401868d1f020208512864681ef09127"
+	// -sha256 -tspolicy 1.3.6.1.4.1.99999.7, with a nonce
+	tsqUnacceptedPolicy = "304b0201013031300d060960864801650304020105000420cb74ab5e9a061377e7b514e8" +
+		"c19cd30f532df3eaf3c8358f2a0f0238ebce41df06092b06010401868d1f07020876780ebc809779c6"
+	// -sha1, with a nonce
+	tsqSHA1 = "30300201013021300906052b0e03021a0500041421b12978942796bd00831528e952ddb0207a39a9" +
+		"02087b26a1b0528884d0"
+	// -sha512 -no_nonce
+	tsqSHA512 = "30560201013051300d060960864801650304020305000440b1ef42c4aec215cb8ea44c82d9aabebc" +
+		"7a7620b522f3b197f57dadf7335f9b82fa975109278039e18aff1f1aefc63784a63a4049c37c8d5c4bac" +
+		"338a48a6cfdb"
+	// tsqNoNonce claiming SHA-384 for its SHA-256 imprint
+	tsqImprintMismatch = "30360201013031300d060960864801650304020205000420cb74ab5e9a061377e7b514e8c" +
+		"19cd30f532df3eaf3c8358f2a0f0238ebce41df"
+	// tsqNoNonce as version 2
+	tsqVersion2 = "30360201023031300d060960864801650304020105000420cb74ab5e9a061377e7b514e8c19cd30" +
+		"f532df3eaf3c8358f2a0f0238ebce41df"
+
+	timestampTestPolicy         = "1.3.6.1.4.1.99999.1"
+	timestampTestAcceptedPolicy = "1.3.6.1.4.1.99999.2"
+)
+
+// timestampTestKeyService hands out the TSA key whatever is asked for
+type timestampTestKeyService struct {
+	KeyService
+	key crypto.Signer
+}
+
+func (k *timestampTestKeyService) GetDecryptedKeyForUser(
+	ctx context.Context,
+	keyID string,
+	userID string,
+	password string,
+) (PrivateKey, error) {
+	return k.key.(PrivateKey), nil
+}
+
+type timestampTestTSA struct {
+	service  *TimestampServiceImpl
+	tsa      *daos.TimestampAuthority
+	cert     *daos.Certificate
+	x509Cert *x509.Certificate
+}
+
+// testTSACertificate issues a self-signed certificate with timeStamping as its only, critical,
+// extended key usage
+func testTSACertificate(t *testing.T, key crypto.Signer) *x509.Certificate {
+	extKeyUsage, err := asn1.Marshal([]asn1.ObjectIdentifier{{1, 3, 6, 1, 5, 5, 7, 3, 8}})
+	require.NoError(t, err)
+
+	cert := testutils.Certificate(
+		t,
+		&x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "Test TSA"},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtraExtensions: []pkix.Extension{
+				{Id: oidExtensionExtKeyUsage, Critical: true, Value: extKeyUsage},
+			},
+		},
+		key,
+		nil,
+		nil,
+	)
+	require.True(t, timestampingCertificate(cert))
+
+	return cert
+}
+
+func newTimestampTestTSA(t *testing.T, requireNonce bool) *timestampTestTSA {
+	ctx := context.Background()
+	key := testutils.Key(t, "ecdsa")
+	x509Cert := testTSACertificate(t, key)
+
+	certRepository := repositories.NewCertRepositoryMemory()
+	cert, err := certRepository.CreateCert(
+		ctx,
+		"user",
+		"Test TSA",
+		x509Cert.Raw,
+		CertTypeCertificate.String(),
+		"",
+		"key",
+	)
+	require.NoError(t, err)
+
+	sealedKeyPassword, err := utils.Seal("secret", []byte("password"))
+	require.NoError(t, err)
+
+	tsa := &daos.TimestampAuthority{
+		UserID:            "user",
+		Name:              "Test TSA",
+		CertificateID:     cert.ID,
+		SealedKeyPassword: sealedKeyPassword,
+		Policy:            timestampTestPolicy,
+		AcceptedPolicies:  []string{timestampTestAcceptedPolicy},
+		AccuracyMillis:    1500,
+		RequireNonce:      requireNonce,
+	}
+
+	timestampRepository := repositories.NewTimestampRepositoryMemory()
+	require.NoError(t, timestampRepository.CreateAuthority(ctx, tsa))
+
+	return &timestampTestTSA{
+		service: NewTimestampServiceImpl(
+			timestampRepository,
+			certRepository,
+			&timestampTestKeyService{key: key},
+			"secret",
+		),
+		tsa:      tsa,
+		cert:     cert,
+		x509Cert: x509Cert,
+	}
+}
+
+// respond answers the hex encoded query and parses the TimeStampResp
+func (s *timestampTestTSA) respond(t *testing.T, query string) *tspResponse {
+	rawRequest, err := hex.DecodeString(query)
+	require.NoError(t, err)
+
+	der, err := s.service.Respond(context.Background(), s.tsa.ID, rawRequest)
+	require.NoError(t, err)
+
+	var resp tspResponse
+	rest, err := asn1.Unmarshal(der, &resp)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+
+	return &resp
+}
+
+// parseToken returns a token's SignedData and TSTInfo
+func parseToken(t *testing.T, token []byte) (*cmsSignedMessage, *tspInfo) {
+	message, err := parseCMS(token)
+	require.NoError(t, err)
+	require.True(t, message.signedData.EncapContentInfo.EContentType.Equal(oidTSTInfo))
+
+	var info tspInfo
+	_, err = asn1.Unmarshal(message.signedData.EncapContentInfo.EContent, &info)
+	require.NoError(t, err)
+
+	return message, &info
+}
+
+func TestTimestampRespond(t *testing.T) {
+	imprint := sha256.Sum256([]byte(timestampTestData))
+
+	tests := []struct {
+		name         string
+		query        string
+		requireNonce bool
+		failure      int
+		policy       string
+		nonce        string
+		certificates int
+	}{
+		{
+			name:         "nonce and certificate",
+			query:        tsqNonceCertReq,
+			policy:       timestampTestPolicy,
+			nonce:        "84a578999a251978",
+			certificates: 1,
+		},
+		{
+			name:   "no nonce",
+			query:  tsqNoNonce,
+			policy: timestampTestPolicy,
+		},
+		{
+			name:         "no nonce when one is required",
+			query:        tsqNoNonce,
+			requireNonce: true,
+			failure:      tspFailureBadRequest,
+		},
+		{
+			name:         "nonce when one is required",
+			query:        tsqNonceCertReq,
+			requireNonce: true,
+			policy:       timestampTestPolicy,
+			nonce:        "84a578999a251978",
+			certificates: 1,
+		},
+		{
+			name:   "accepted policy",
+			query:  tsqAcceptedPolicy,
+			policy: timestampTestAcceptedPolicy,
+			nonce:  "512864681ef09127",
+		},
+		{
+			name:    "unaccepted policy",
+			query:   tsqUnacceptedPolicy,
+			failure: tspFailureUnacceptedPolicy,
+		},
+		{
+			name:    "SHA-1 imprint",
+			query:   tsqSHA1,
+			failure: tspFailureBadAlg,
+		},
+		{
+			name:   "SHA-512 imprint",
+			query:  tsqSHA512,
+			policy: timestampTestPolicy,
+		},
+		{
+			name:    "imprint length does not match its algorithm",
+			query:   tsqImprintMismatch,
+			failure: tspFailureBadDataFormat,
+		},
+		{
+			name:    "version 2",
+			query:   tsqVersion2,
+			failure: tspFailureBadRequest,
+		},
+		{
+			name:    "not DER",
+			query:   hex.EncodeToString([]byte("not a request")),
+			failure: tspFailureBadDataFormat,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				tsa := newTimestampTestTSA(t, test.requireNonce)
+				resp := tsa.respond(t, test.query)
+
+				if test.policy == "" {
+					assert.Equal(t, tspStatusRejection, resp.Status.Status)
+					assert.Equal(t, 1, resp.Status.FailInfo.At(test.failure))
+					assert.Empty(t, resp.TimeStampToken.FullBytes)
+					return
+				}
+
+				require.Equal(t, tspStatusGranted, resp.Status.Status)
+
+				message, info := parseToken(t, resp.TimeStampToken.FullBytes)
+				assert.Equal(t, test.policy, info.Policy.String())
+				assert.Len(t, message.certificates, test.certificates)
+				assert.Equal(t, 1, info.Accuracy.Seconds)
+				assert.Equal(t, 500, info.Accuracy.Millis)
+
+				if test.nonce == "" {
+					assert.Nil(t, info.Nonce)
+				} else {
+					require.NotNil(t, info.Nonce)
+					assert.Equal(t, test.nonce, info.Nonce.Text(16))
+				}
+
+				if test.query != tsqSHA512 {
+					assert.Equal(t, imprint[:], info.MessageImprint.HashedMessage)
+				}
+			},
+		)
+	}
+}
+
+func TestTSPRejection(t *testing.T) {
+	tests := []struct {
+		failureBit int
+		expected   string
+	}{
+		{failureBit: tspFailureBadAlg, expected: "300e300c02010230030c017803020780"},
+		{failureBit: tspFailureUnacceptedPolicy, expected: "300f300d02010230030c01780303000001"},
+		{failureBit: tspFailureSystemFailure, expected: "3011300f02010230030c017803050600000040"},
+	}
+
+	for _, test := range tests {
+		der, err := tspRejection(test.failureBit, "x")
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, hex.EncodeToString(der), "failure bit %d", test.failureBit)
+	}
+}
+
+func TestTSPToken(t *testing.T) {
+	tsa := newTimestampTestTSA(t, false)
+	token := tsa.respond(t, tsqNonceCertReq).TimeStampToken.FullBytes
+
+	granted, err := tspGranted(token)
+	require.NoError(t, err)
+
+	rejected, err := tspRejection(tspFailureBadRequest, "no")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		der     []byte
+		wantErr string
+	}{
+		{name: "response", der: granted},
+		{name: "bare token", der: token},
+		{name: "rejection", der: rejected, wantErr: "does not carry a token"},
+		{name: "trailing data", der: append(granted, 0), wantErr: "trailing data"},
+		{name: "not DER", der: []byte("token"), wantErr: "asn1"},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				extracted, err := tspToken(test.der)
+				if test.wantErr != "" {
+					assert.ErrorContains(t, err, test.wantErr)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, token, extracted)
+			},
+		)
+	}
+}
+
+func TestSigningCertificateMatches(t *testing.T) {
+	tsa := newTimestampTestTSA(t, false)
+	other := testTSACertificate(t, testutils.Key(t, "ecdsa"))
+	hash := sha256.Sum256(tsa.cert.Data)
+
+	signingCertificate := func(certID essCertIDv2) cmsAttribute {
+		attribute, err := newCMSAttribute(
+			oidSigningCertificateV2,
+			essSigningCertificateV2{Certs: []essCertIDv2{certID}},
+		)
+		require.NoError(t, err)
+
+		return attribute
+	}
+
+	signingTime, err := newCMSAttribute(oidCMSAttributeSigningTime, time.Now().UTC())
+	require.NoError(t, err)
+
+	current, err := newSigningCertificateV2(tsa.cert.Data)
+	require.NoError(t, err)
+
+	otherCert, err := newSigningCertificateV2(other.Raw)
+	require.NoError(t, err)
+
+	empty, err := newCMSAttribute(oidSigningCertificateV2, essSigningCertificateV2{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		attributes []cmsAttribute
+		matches    bool
+		wantErr    bool
+	}{
+		{
+			name:       "TSA certificate",
+			attributes: []cmsAttribute{signingTime, current},
+			matches:    true,
+		},
+		{
+			name: "explicit SHA-256",
+			attributes: []cmsAttribute{
+				signingCertificate(
+					essCertIDv2{
+						HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256},
+						CertHash:      hash[:],
+					},
+				),
+			},
+			matches: true,
+		},
+		{
+			name: "SHA-384",
+			attributes: []cmsAttribute{
+				signingCertificate(
+					essCertIDv2{
+						HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA384},
+						CertHash:      hash[:],
+					},
+				),
+			},
+			wantErr: true,
+		},
+		{name: "other certificate", attributes: []cmsAttribute{otherCert}},
+		{name: "no certificates", attributes: []cmsAttribute{empty}},
+		{name: "no attribute", attributes: []cmsAttribute{signingTime}},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				signedAttrs, err := marshalCMSAttributes(test.attributes)
+				require.NoError(t, err)
+
+				var set asn1.RawValue
+				_, err = asn1.Unmarshal(signedAttrs, &set)
+				require.NoError(t, err)
+
+				matches, err := signingCertificateMatches(
+					&cmsSignerInfo{SignedAttrs: asn1.RawValue{Bytes: set.Bytes}},
+					tsa.cert.Data,
+				)
+				if test.wantErr {
+					assert.ErrorIs(t, err, errUnsupportedCMS)
+					return
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, test.matches, matches)
+			},
+		)
+	}
+}
+
+func TestTimestampPolicy(t *testing.T) {
+	tsa := &daos.TimestampAuthority{
+		Policy:           timestampTestPolicy,
+		AcceptedPolicies: []string{timestampTestAcceptedPolicy},
+	}
+
+	tests := []struct {
+		name      string
+		requested asn1.ObjectIdentifier
+		policy    string
+		ok        bool
+	}{
+		{name: "none requested", policy: timestampTestPolicy, ok: true},
+		{
+			name:      "own policy",
+			requested: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1},
+			policy:    timestampTestPolicy,
+			ok:        true,
+		},
+		{
+			name:      "accepted policy",
+			requested: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 2},
+			policy:    timestampTestAcceptedPolicy,
+			ok:        true,
+		},
+		{name: "other policy", requested: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 7}},
+	}
+
+	for _, test := range tests {
+		policy, ok := timestampPolicy(tsa, test.requested)
+		assert.Equal(t, test.ok, ok, test.name)
+		if test.ok {
+			assert.Equal(t, test.policy, policy.String(), test.name)
+		}
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	ctx := context.Background()
+	tsa := newTimestampTestTSA(t, false)
+	token := tsa.respond(t, tsqNonceCertReq).TimeStampToken.FullBytes
+	_, info := parseToken(t, token)
+
+	record, err := tsa.service.timestampRepository.GetTokenBySerial(
+		ctx,
+		tsa.tsa.ID,
+		info.SerialNumber.String(),
+	)
+	require.NoError(t, err)
+
+	data := []byte(timestampTestData)
+	sha256Digest := sha256.Sum256(data)
+	sha512Digest := sha512.Sum512(data)
+	otherTSA := newTimestampTestTSA(t, false)
+
+	revoked := func(reason int, at time.Time) *daos.Certificate {
+		cert := *tsa.cert
+		cert.RevokedAt = &at
+		cert.RevocationReason = reason
+		return &cert
+	}
+
+	tests := []struct {
+		name     string
+		cert     *daos.Certificate
+		x509Cert *x509.Certificate
+		record   *daos.Timestamp
+		data     []byte
+		digest   []byte
+		wantErr  string
+	}{
+		{name: "data", data: data},
+		{name: "digest", digest: sha256Digest[:]},
+		{
+			name:    "different data",
+			data:    []byte("another document"),
+			wantErr: "message imprint does not match",
+		},
+		{
+			name:    "digest of another hash algorithm",
+			digest:  sha512Digest[:],
+			wantErr: "message imprint does not match",
+		},
+		{
+			name:     "other TSA",
+			cert:     otherTSA.cert,
+			x509Cert: otherTSA.x509Cert,
+			data:     data,
+			wantErr:  "not signed by this TSA",
+		},
+		{
+			name:    "not recorded",
+			record:  &daos.Timestamp{},
+			data:    data,
+			wantErr: "not issued by this TSA",
+		},
+		{
+			name:    "key compromised after issue",
+			cert:    revoked(revocationReasonKeyCompromise, info.GenTime.Add(time.Hour)),
+			data:    data,
+			wantErr: "has been revoked",
+		},
+		{
+			name: "superseded after issue",
+			cert: revoked(4, info.GenTime.Add(time.Hour)),
+			data: data,
+		},
+		{
+			name:    "superseded before issue",
+			cert:    revoked(4, info.GenTime.Add(-time.Hour)),
+			data:    data,
+			wantErr: "has been revoked",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(
+			test.name, func(t *testing.T) {
+				cert, x509Cert, tokenRecord := tsa.cert, tsa.x509Cert, record
+				if test.cert != nil {
+					cert = test.cert
+				}
+				if test.x509Cert != nil {
+					x509Cert = test.x509Cert
+				}
+				if test.record != nil {
+					tokenRecord = test.record
+				}
+
+				// checkToken adds the TSA certificate to the message, so each case parses afresh
+				message, info := parseToken(t, token)
+				err := checkToken(
+					token,
+					message,
+					info,
+					cert,
+					x509Cert,
+					tokenRecord,
+					test.data,
+					test.digest,
+				)
+				if test.wantErr == "" {
+					assert.NoError(t, err)
+				} else {
+					assert.ErrorContains(t, err, test.wantErr)
+				}
+			},
+		)
+	}
+}
+
+func TestTimestampVerify(t *testing.T) {
+	ctx := context.Background()
+	tsa := newTimestampTestTSA(t, false)
+	token := tsa.respond(t, tsqNonceCertReq).TimeStampToken.FullBytes
+
+	granted, err := tspGranted(token)
+	require.NoError(t, err)
+
+	resp, err := tsa.service.Verify(
+		ctx,
+		tsa.tsa.ID,
+		&contracts.VerifyTimestampRequest{
+			Token: base64.StdEncoding.EncodeToString(granted),
+			Data:  base64.StdEncoding.EncodeToString([]byte(timestampTestData)),
+		},
+	)
+	require.NoError(t, err)
+	assert.True(t, resp.Valid, resp.Error)
+	assert.Equal(t, timestampTestPolicy, resp.Policy)
+
+	nonce, ok := new(big.Int).SetString("84a578999a251978", 16)
+	require.True(t, ok)
+	assert.Equal(t, nonce.String(), resp.Nonce)
+
+	otherTSA := newTimestampTestTSA(t, false)
+	resp, err = otherTSA.service.Verify(
+		ctx,
+		otherTSA.tsa.ID,
+		&contracts.VerifyTimestampRequest{
+			Token: base64.StdEncoding.EncodeToString(token),
+			Data:  base64.StdEncoding.EncodeToString([]byte(timestampTestData)),
+		},
+	)
+	require.NoError(t, err)
+	assert.False(t, resp.Valid)
+
+	_, err = tsa.service.Verify(
+		ctx,
+		tsa.tsa.ID,
+		&contracts.VerifyTimestampRequest{Token: base64.StdEncoding.EncodeToString(token)},
+	)
+	assert.ErrorIs(t, err, ErrInvalidTimestampRequest, "data or digest is required")
+}
@@ -0,0 +1,595 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fapiko/john-hancock-platform/app/contracts"
+	"github.com/fapiko/john-hancock-platform/app/repositories"
+	"github.com/fapiko/john-hancock-platform/app/repositories/daos"
+	"github.com/fapiko/john-hancock-platform/app/utils"
+)
+
+var _ TransparencyLogService = (*TransparencyLogServiceImpl)(nil)
+
+var ErrInvalidTransparencyQuery = errors.New("invalid transparency log query")
+
+// maxTransparencyLogEntries caps how many entries one GetEntries call returns
+const maxTransparencyLogEntries = 1000
+
+// TransparencyLogService keeps an append only Merkle tree log per CA of every certificate it
+// issued, structured as in RFC 6962 so that auditors can check a certificate was logged and
+// monitors can check the log only ever grew. A root CA's log starts with the root itself.
+type TransparencyLogService interface {
+	// AppendCertificate logs a newly issued certificate in its issuer's log. It should run in
+	// the transaction storing the certificate, so neither exists without the other.
+	AppendCertificate(ctx context.Context, cert *daos.Certificate) error
+	GetLog(ctx context.Context, caID string) (*contracts.TransparencyLogResponse, error)
+	// GetSignedTreeHead returns the head signed when the last entry was appended, signing one
+	// when the log has none yet
+	GetSignedTreeHead(ctx context.Context, caID string) (*contracts.SignedTreeHeadResponse, error)
+	// GetInclusionProof returns the audit path of the leaf with leafHash, or of the entry for
+	// certificateID when no hash is given, in the tree of treeSize leaves. A zero treeSize
+	// means the current tree.
+	GetInclusionProof(
+		ctx context.Context,
+		caID string,
+		leafHash []byte,
+		certificateID string,
+		treeSize int64,
+	) (*contracts.InclusionProofResponse, error)
+	GetConsistencyProof(
+		ctx context.Context,
+		caID string,
+		first int64,
+		second int64,
+	) (*contracts.ConsistencyProofResponse, error)
+	// GetEntries returns the entries from start to end inclusive, possibly fewer when the range
+	// is large
+	GetEntries(
+		ctx context.Context,
+		caID string,
+		start int64,
+		end int64,
+	) (*contracts.TransparencyLogEntriesResponse, error)
+}
+
+// TransparencyLogServiceImpl stores the hash of every perfect subtree as leaves are appended and
+// signs the new tree head on each append, so serving a head or a proof reads a handful of
+// hashes instead of rehashing the whole log.
+type TransparencyLogServiceImpl struct {
+	logRepository  repositories.TransparencyLogRepository
+	certRepository repositories.CertRepository
+	transactor     repositories.Transactor
+	secretKey      string
+}
+
+// NewTransparencyLogServiceImpl seals each log's tree head signing key with secretKey
+func NewTransparencyLogServiceImpl(
+	logRepository repositories.TransparencyLogRepository,
+	certRepository repositories.CertRepository,
+	transactor repositories.Transactor,
+	secretKey string,
+) *TransparencyLogServiceImpl {
+	return &TransparencyLogServiceImpl{
+		logRepository:  logRepository,
+		certRepository: certRepository,
+		transactor:     transactor,
+		secretKey:      secretKey,
+	}
+}
+
+func (t *TransparencyLogServiceImpl) AppendCertificate(
+	ctx context.Context,
+	cert *daos.Certificate,
+) error {
+	caID := cert.ParentCertificate
+	if caID == "" {
+		caID = cert.ID
+	}
+
+	err := t.logRepository.EnsureLog(ctx, caID)
+	if err != nil {
+		return err
+	}
+
+	leafInput := merkleTreeLeaf(time.Now(), cert.Data)
+	entry := &daos.TransparencyLogEntry{
+		CAID:          caID,
+		CertificateID: cert.ID,
+		LeafHash:      merkleLeafHash(leafInput),
+		LeafInput:     leafInput,
+	}
+
+	return t.transactor.WithinTransaction(
+		ctx, func(ctx context.Context) error {
+			// Holds back other appends to the log until the transaction ends
+			err := t.logRepository.AppendEntry(ctx, entry)
+			if err != nil {
+				return err
+			}
+
+			// The previous tree's root covers every sibling the new leaf completes a subtree with
+			nodes, err := t.loadNodes(
+				ctx, caID, func(nodes *merkleNodes) {
+					merkleRoot(nodes, entry.LeafIndex)
+				},
+			)
+			if err != nil {
+				return err
+			}
+
+			added := merkleAppendNodes(nodes, entry.LeafIndex, entry.LeafHash)
+			err = t.storeNodes(ctx, caID, added)
+			if err != nil {
+				return err
+			}
+
+			for id, hash := range added {
+				nodes.hashes[id] = hash
+			}
+
+			treeSize := entry.LeafIndex + 1
+
+			return t.signTreeHead(ctx, caID, treeSize, merkleRoot(nodes, treeSize))
+		},
+	)
+}
+
+// loadNodes loads the subtree hashes compute looks up. Logs started before the hashes were
+// stored lack some of them, those are rebuilt from the leaves and stored on the way.
+func (t *TransparencyLogServiceImpl) loadNodes(
+	ctx context.Context,
+	caID string,
+	compute func(nodes *merkleNodes),
+) (*merkleNodes, error) {
+	nodes := newMerkleNodes()
+	compute(nodes)
+	if len(nodes.missing) == 0 {
+		return nodes, nil
+	}
+
+	ids := make([]repositories.TransparencyLogNodeID, len(nodes.missing))
+	for i, id := range nodes.missing {
+		ids[i] = repositories.TransparencyLogNodeID{Level: id.Level, NodeIndex: id.Index}
+	}
+
+	stored, err := t.logRepository.GetNodes(ctx, caID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range stored {
+		nodes.hashes[merkleNodeID{Level: node.Level, Index: node.NodeIndex}] = node.Hash
+	}
+
+	rebuilt := make(map[merkleNodeID][]byte)
+	for _, id := range nodes.missing {
+		if _, ok := nodes.hashes[id]; ok {
+			continue
+		}
+
+		start := id.Index << id.Level
+		leaves, err := t.logRepository.GetLeafHashes(ctx, caID, start, start+1<<id.Level)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(leaves) != 1<<id.Level {
+			return nil, fmt.Errorf("transparency log %s is missing leaves from %d", caID, start)
+		}
+
+		hash := merkleRoot(merkleNodesFromLeaves(leaves), int64(len(leaves)))
+		nodes.hashes[id] = hash
+		rebuilt[id] = hash
+	}
+
+	nodes.missing = nil
+
+	return nodes, t.storeNodes(ctx, caID, rebuilt)
+}
+
+func (t *TransparencyLogServiceImpl) storeNodes(
+	ctx context.Context,
+	caID string,
+	hashes map[merkleNodeID][]byte,
+) error {
+	nodes := make([]*daos.TransparencyLogNode, 0, len(hashes))
+	for id, hash := range hashes {
+		nodes = append(
+			nodes, &daos.TransparencyLogNode{
+				CAID:      caID,
+				Level:     id.Level,
+				NodeIndex: id.Index,
+				Hash:      hash,
+			},
+		)
+	}
+
+	return t.logRepository.AddNodes(ctx, nodes)
+}
+
+// signTreeHead stores the head of the tree of treeSize leaves. Without a server secret the head
+// is stored unsigned, issuing certificates doesn't depend on the log being able to sign.
+func (t *TransparencyLogServiceImpl) signTreeHead(
+	ctx context.Context,
+	caID string,
+	treeSize int64,
+	root []byte,
+) error {
+	timestamp := time.Now().UnixMilli()
+
+	signature, err := t.treeHeadSignature(ctx, caID, timestamp, treeSize, root)
+	if err != nil && !errors.Is(err, utils.ErrNoSecret) {
+		return err
+	}
+
+	return t.logRepository.SetTreeHead(ctx, caID, treeSize, timestamp, root, signature)
+}
+
+func (t *TransparencyLogServiceImpl) treeHeadSignature(
+	ctx context.Context,
+	caID string,
+	timestamp int64,
+	treeSize int64,
+	root []byte,
+) ([]byte, error) {
+	log, err := t.logRepository.GetLog(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := t.signingKey(ctx, log)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(treeHeadSignatureInput(timestamp, treeSize, root))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return digitallySigned(signature), nil
+}
+
+// getLog returns the CA's log, creating an empty one for CAs that haven't issued anything since
+// logging began
+func (t *TransparencyLogServiceImpl) getLog(
+	ctx context.Context,
+	caID string,
+) (*daos.TransparencyLog, error) {
+	log, err := t.logRepository.GetLog(ctx, caID)
+	if !errors.Is(err, repositories.ErrNoRecord) {
+		return log, err
+	}
+
+	ca, err := t.certRepository.GetCertByID(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isCAType(ca.Type) {
+		return nil, repositories.ErrNoRecord
+	}
+
+	err = t.logRepository.EnsureLog(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.logRepository.GetLog(ctx, caID)
+}
+
+func (t *TransparencyLogServiceImpl) GetLog(
+	ctx context.Context,
+	caID string,
+) (*contracts.TransparencyLogResponse, error) {
+	log, err := t.getLog(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &contracts.TransparencyLogResponse{
+		CAID:     caID,
+		TreeSize: log.TreeSize,
+	}
+
+	if log.PublicKey != nil {
+		logID := sha256.Sum256(log.PublicKey)
+		resp.LogID = base64.StdEncoding.EncodeToString(logID[:])
+		resp.PublicKey = string(
+			pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: log.PublicKey}),
+		)
+	}
+
+	return resp, nil
+}
+
+// signingKey opens the log's tree head key, generating it when the log has none. Replicas racing
+// to generate one settle on whichever was stored first.
+func (t *TransparencyLogServiceImpl) signingKey(
+	ctx context.Context,
+	log *daos.TransparencyLog,
+) (*ecdsa.PrivateKey, error) {
+	if log.SealedPrivateKey == nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		sealed, err := utils.Seal(t.secretKey, der)
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			return nil, err
+		}
+
+		err = t.logRepository.SetLogKey(ctx, log.CAID, publicKey, sealed)
+		if err == nil {
+			return key, nil
+		}
+
+		if !errors.Is(err, repositories.ErrDuplicateRecord) {
+			return nil, err
+		}
+
+		log, err = t.logRepository.GetLog(ctx, log.CAID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	der, err := utils.Open(t.secretKey, log.SealedPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected transparency log key type %T", key)
+	}
+
+	return ecdsaKey, nil
+}
+
+func (t *TransparencyLogServiceImpl) GetSignedTreeHead(
+	ctx context.Context,
+	caID string,
+) (*contracts.SignedTreeHeadResponse, error) {
+	log, err := t.getLog(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Empty logs, logs started before heads were stored and heads appended without a secret
+	if log.TreeHeadSize != log.TreeSize || log.TreeHeadSignature == nil {
+		nodes, err := t.loadNodes(
+			ctx, caID, func(nodes *merkleNodes) {
+				merkleRoot(nodes, log.TreeSize)
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		log.TreeHeadSize = log.TreeSize
+		log.TreeHeadTimestamp = time.Now().UnixMilli()
+		log.RootHash = merkleRoot(nodes, log.TreeSize)
+		log.TreeHeadSignature, err = t.treeHeadSignature(
+			ctx,
+			caID,
+			log.TreeHeadTimestamp,
+			log.TreeHeadSize,
+			log.RootHash,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = t.logRepository.SetTreeHead(
+			ctx,
+			caID,
+			log.TreeHeadSize,
+			log.TreeHeadTimestamp,
+			log.RootHash,
+			log.TreeHeadSignature,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &contracts.SignedTreeHeadResponse{
+		TreeSize:          log.TreeHeadSize,
+		Timestamp:         log.TreeHeadTimestamp,
+		SHA256RootHash:    base64.StdEncoding.EncodeToString(log.RootHash),
+		TreeHeadSignature: base64.StdEncoding.EncodeToString(log.TreeHeadSignature),
+	}, nil
+}
+
+// checkTreeSize resolves a requested tree size against the current one, zero meaning current
+func checkTreeSize(log *daos.TransparencyLog, treeSize int64) (int64, error) {
+	if treeSize == 0 {
+		return log.TreeSize, nil
+	}
+
+	if treeSize < 0 || treeSize > log.TreeSize {
+		return 0, fmt.Errorf(
+			"%w: tree size must be between 1 and %d",
+			ErrInvalidTransparencyQuery,
+			log.TreeSize,
+		)
+	}
+
+	return treeSize, nil
+}
+
+func (t *TransparencyLogServiceImpl) GetInclusionProof(
+	ctx context.Context,
+	caID string,
+	leafHash []byte,
+	certificateID string,
+	treeSize int64,
+) (*contracts.InclusionProofResponse, error) {
+	log, err := t.getLog(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	treeSize, err = checkTreeSize(log, treeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *daos.TransparencyLogEntry
+	switch {
+	case len(leafHash) > 0:
+		entry, err = t.logRepository.GetEntryByLeafHash(ctx, caID, leafHash)
+	case certificateID != "":
+		entry, err = t.logRepository.GetEntryByCertificateID(ctx, caID, certificateID)
+	default:
+		return nil, fmt.Errorf(
+			"%w: a leaf hash or certificate ID is needed",
+			ErrInvalidTransparencyQuery,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.LeafIndex >= treeSize {
+		return nil, fmt.Errorf(
+			"%w: leaf %d is not in the tree of size %d",
+			ErrInvalidTransparencyQuery,
+			entry.LeafIndex,
+			treeSize,
+		)
+	}
+
+	nodes, err := t.loadNodes(
+		ctx, caID, func(nodes *merkleNodes) {
+			merkleInclusionProof(nodes, entry.LeafIndex, treeSize)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contracts.InclusionProofResponse{
+		LeafIndex:     entry.LeafIndex,
+		LeafHash:      base64.StdEncoding.EncodeToString(entry.LeafHash),
+		CertificateID: entry.CertificateID,
+		TreeSize:      treeSize,
+		AuditPath:     encodeHashes(merkleInclusionProof(nodes, entry.LeafIndex, treeSize)),
+	}, nil
+}
+
+func (t *TransparencyLogServiceImpl) GetConsistencyProof(
+	ctx context.Context,
+	caID string,
+	first int64,
+	second int64,
+) (*contracts.ConsistencyProofResponse, error) {
+	log, err := t.getLog(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	if first < 1 || first > second || second > log.TreeSize {
+		return nil, fmt.Errorf(
+			"%w: sizes must satisfy 1 <= first <= second <= %d",
+			ErrInvalidTransparencyQuery,
+			log.TreeSize,
+		)
+	}
+
+	nodes, err := t.loadNodes(
+		ctx, caID, func(nodes *merkleNodes) {
+			merkleConsistencyProof(nodes, first, second)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contracts.ConsistencyProofResponse{
+		First:       first,
+		Second:      second,
+		Consistency: encodeHashes(merkleConsistencyProof(nodes, first, second)),
+	}, nil
+}
+
+func (t *TransparencyLogServiceImpl) GetEntries(
+	ctx context.Context,
+	caID string,
+	start int64,
+	end int64,
+) (*contracts.TransparencyLogEntriesResponse, error) {
+	log, err := t.getLog(ctx, caID)
+	if err != nil {
+		return nil, err
+	}
+
+	if start < 0 || end < start || start >= log.TreeSize {
+		return nil, fmt.Errorf(
+			"%w: entries must satisfy 0 <= start <= end and start < %d",
+			ErrInvalidTransparencyQuery,
+			log.TreeSize,
+		)
+	}
+
+	if end-start >= maxTransparencyLogEntries {
+		end = start + maxTransparencyLogEntries - 1
+	}
+
+	entries, err := t.logRepository.GetEntries(ctx, caID, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &contracts.TransparencyLogEntriesResponse{
+		Entries: make([]*contracts.TransparencyLogEntryResponse, len(entries)),
+	}
+	for i, entry := range entries {
+		resp.Entries[i] = &contracts.TransparencyLogEntryResponse{
+			LeafIndex:     entry.LeafIndex,
+			CertificateID: entry.CertificateID,
+			Timestamp:     merkleTreeLeafTimestamp(entry.LeafInput),
+			LeafInput:     base64.StdEncoding.EncodeToString(entry.LeafInput),
+		}
+	}
+
+	return resp, nil
+}
+
+func encodeHashes(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = base64.StdEncoding.EncodeToString(hash)
+	}
+
+	return encoded
+}
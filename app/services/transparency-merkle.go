@@ -0,0 +1,237 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// Structures and hashing from RFC 6962 section 2 and 3
+
+const (
+	ctVersionV1            = 0
+	ctLeafTypeTimestamp    = 0
+	ctEntryTypeX509        = 0
+	ctSignatureTypeTree    = 1
+	tlsHashAlgorithmSHA256 = 4
+	tlsSignatureECDSA      = 3
+)
+
+// merkleTreeLeaf encodes the MerkleTreeLeaf of an X.509 entry
+func merkleTreeLeaf(timestamp time.Time, certDER []byte) []byte {
+	leaf := []byte{ctVersionV1, ctLeafTypeTimestamp}
+	leaf = binary.BigEndian.AppendUint64(leaf, uint64(timestamp.UnixMilli()))
+	leaf = binary.BigEndian.AppendUint16(leaf, ctEntryTypeX509)
+	leaf = append(leaf, byte(len(certDER)>>16), byte(len(certDER)>>8), byte(len(certDER)))
+	leaf = append(leaf, certDER...)
+
+	// No extensions
+	return binary.BigEndian.AppendUint16(leaf, 0)
+}
+
+// merkleTreeLeafTimestamp reads the timestamp back out of a MerkleTreeLeaf
+func merkleTreeLeafTimestamp(leaf []byte) int64 {
+	if len(leaf) < 10 {
+		return 0
+	}
+
+	return int64(binary.BigEndian.Uint64(leaf[2:10]))
+}
+
+// treeHeadSignatureInput encodes the TreeHeadSignature struct a signed tree head signs
+func treeHeadSignatureInput(timestamp int64, treeSize int64, rootHash []byte) []byte {
+	input := []byte{ctVersionV1, ctSignatureTypeTree}
+	input = binary.BigEndian.AppendUint64(input, uint64(timestamp))
+	input = binary.BigEndian.AppendUint64(input, uint64(treeSize))
+
+	return append(input, rootHash...)
+}
+
+// digitallySigned wraps an ECDSA SHA-256 signature in the TLS DigitallySigned struct
+func digitallySigned(signature []byte) []byte {
+	signed := []byte{tlsHashAlgorithmSHA256, tlsSignatureECDSA}
+	signed = binary.BigEndian.AppendUint16(signed, uint16(len(signature)))
+
+	return append(signed, signature...)
+}
+
+func merkleLeafHash(leaf []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{0})
+	hash.Write(leaf)
+
+	return hash.Sum(nil)
+}
+
+func merkleNodeHash(left []byte, right []byte) []byte {
+	hash := sha256.New()
+	hash.Write([]byte{1})
+	hash.Write(left)
+	hash.Write(right)
+
+	return hash.Sum(nil)
+}
+
+// merkleSplit is the largest power of two smaller than n
+func merkleSplit(n int64) int64 {
+	var k int64 = 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// merkleNodeID names the perfect subtree of 2^Level leaves starting at leaf Index << Level
+type merkleNodeID struct {
+	Level int
+	Index int64
+}
+
+// merkleRangeNodes splits the leaves from start up to end into the fewest perfect subtrees,
+// largest first. Every range the RFC 6962 recursion visits splits this way, so the stored
+// subtree hashes are all any root or proof needs.
+func merkleRangeNodes(start int64, end int64) []merkleNodeID {
+	ids := make([]merkleNodeID, 0)
+	for start < end {
+		level := 0
+		for start%(2<<level) == 0 && start+(2<<level) <= end {
+			level++
+		}
+
+		ids = append(ids, merkleNodeID{Level: level, Index: start >> level})
+		start += 1 << level
+	}
+
+	return ids
+}
+
+// merkleNodes holds the subtree hashes a computation works from. Looking up a node it doesn't
+// hold records it as missing and yields a placeholder, so a dry run against an empty set lists
+// the nodes to load.
+type merkleNodes struct {
+	hashes  map[merkleNodeID][]byte
+	missing []merkleNodeID
+}
+
+func newMerkleNodes() *merkleNodes {
+	return &merkleNodes{hashes: make(map[merkleNodeID][]byte)}
+}
+
+// merkleNodesFromLeaves computes every subtree hash of the given leaves
+func merkleNodesFromLeaves(leaves [][]byte) *merkleNodes {
+	nodes := newMerkleNodes()
+	for level := 0; len(leaves) > 0; level++ {
+		parents := make([][]byte, 0, len(leaves)/2)
+		for i, hash := range leaves {
+			nodes.hashes[merkleNodeID{Level: level, Index: int64(i)}] = hash
+			if i%2 == 1 {
+				parents = append(parents, merkleNodeHash(leaves[i-1], hash))
+			}
+		}
+
+		leaves = parents
+	}
+
+	return nodes
+}
+
+func (n *merkleNodes) get(id merkleNodeID) []byte {
+	hash, ok := n.hashes[id]
+	if !ok {
+		n.missing = append(n.missing, id)
+		return make([]byte, sha256.Size)
+	}
+
+	return hash
+}
+
+// merkleRangeRoot is MTH over the leaves from start up to end
+func merkleRangeRoot(nodes *merkleNodes, start int64, end int64) []byte {
+	ids := merkleRangeNodes(start, end)
+	if len(ids) == 0 {
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	}
+
+	root := nodes.get(ids[len(ids)-1])
+	for i := len(ids) - 2; i >= 0; i-- {
+		root = merkleNodeHash(nodes.get(ids[i]), root)
+	}
+
+	return root
+}
+
+// merkleRoot is MTH over the first size leaves
+func merkleRoot(nodes *merkleNodes, size int64) []byte {
+	return merkleRangeRoot(nodes, 0, size)
+}
+
+// merkleInclusionProof is PATH(m, D[size]), the audit path of leaf m
+func merkleInclusionProof(nodes *merkleNodes, m int64, size int64) [][]byte {
+	return merklePath(nodes, m, 0, size)
+}
+
+func merklePath(nodes *merkleNodes, m int64, start int64, end int64) [][]byte {
+	if end-start <= 1 {
+		return [][]byte{}
+	}
+
+	k := merkleSplit(end - start)
+	if m < start+k {
+		return append(merklePath(nodes, m, start, start+k), merkleRangeRoot(nodes, start+k, end))
+	}
+
+	return append(merklePath(nodes, m, start+k, end), merkleRangeRoot(nodes, start, start+k))
+}
+
+// merkleConsistencyProof is PROOF(m, D[size]), proving the tree of the first m leaves is a
+// prefix of the tree of size leaves
+func merkleConsistencyProof(nodes *merkleNodes, m int64, size int64) [][]byte {
+	return merkleSubproof(nodes, m, 0, size, true)
+}
+
+func merkleSubproof(
+	nodes *merkleNodes,
+	m int64,
+	start int64,
+	end int64,
+	complete bool,
+) [][]byte {
+	if m == end {
+		if complete {
+			return [][]byte{}
+		}
+
+		return [][]byte{merkleRangeRoot(nodes, start, end)}
+	}
+
+	k := merkleSplit(end - start)
+	if m <= start+k {
+		return append(
+			merkleSubproof(nodes, m, start, start+k, complete),
+			merkleRangeRoot(nodes, start+k, end),
+		)
+	}
+
+	return append(
+		merkleSubproof(nodes, m, start+k, end, false),
+		merkleRangeRoot(nodes, start, start+k),
+	)
+}
+
+// merkleAppendNodes returns the subtree hashes completed by appending leafHash as leaf index.
+// Each new node's left sibling is part of the tree before the append, read from nodes.
+func merkleAppendNodes(nodes *merkleNodes, index int64, leafHash []byte) map[merkleNodeID][]byte {
+	id := merkleNodeID{Level: 0, Index: index}
+	added := map[merkleNodeID][]byte{id: leafHash}
+
+	hash := leafHash
+	for id.Index%2 == 1 {
+		hash = merkleNodeHash(nodes.get(merkleNodeID{Level: id.Level, Index: id.Index - 1}), hash)
+		id = merkleNodeID{Level: id.Level + 1, Index: id.Index / 2}
+		added[id] = hash
+	}
+
+	return added
+}
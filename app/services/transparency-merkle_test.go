@@ -0,0 +1,357 @@
+package services
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Leaves, roots and proofs of the reference test vectors shared by the certificate transparency
+// implementations. Leaf indexes in the proof vectors count from 1 as in those sources.
+var merkleTestLeaves = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+var merkleTestRoots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+var merkleTestInclusionProofs = []struct {
+	leaf     int64
+	treeSize int64
+	path     []string
+}{
+	{leaf: 1, treeSize: 1, path: []string{}},
+	{
+		leaf:     1,
+		treeSize: 8,
+		path: []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		},
+	},
+	{
+		leaf:     6,
+		treeSize: 8,
+		path: []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		},
+	},
+	{
+		leaf:     3,
+		treeSize: 3,
+		path:     []string{"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125"},
+	},
+	{
+		leaf:     2,
+		treeSize: 5,
+		path: []string{
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		},
+	},
+}
+
+var merkleTestConsistencyProofs = []struct {
+	first  int64
+	second int64
+	proof  []string
+}{
+	{first: 1, second: 1, proof: []string{}},
+	{
+		first:  1,
+		second: 8,
+		proof: []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		},
+	},
+	{
+		first:  6,
+		second: 8,
+		proof: []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		},
+	},
+	{
+		first:  2,
+		second: 5,
+		proof: []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		},
+	},
+	{
+		first:  4,
+		second: 8,
+		proof:  []string{"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4"},
+	},
+}
+
+func merkleTestLeafHashes(t *testing.T) [][]byte {
+	hashes := make([][]byte, len(merkleTestLeaves))
+	for i, leaf := range merkleTestLeaves {
+		data, err := hex.DecodeString(leaf)
+		require.NoError(t, err)
+
+		hashes[i] = merkleLeafHash(data)
+	}
+
+	return hashes
+}
+
+func decodeHexHashes(t *testing.T, encoded []string) [][]byte {
+	hashes := make([][]byte, len(encoded))
+	for i, hash := range encoded {
+		var err error
+		hashes[i], err = hex.DecodeString(hash)
+		require.NoError(t, err)
+	}
+
+	return hashes
+}
+
+// appendedMerkleNodes builds the subtree hashes one leaf at a time, as appending to a log does
+func appendedMerkleNodes(t *testing.T, leaves [][]byte) *merkleNodes {
+	nodes := newMerkleNodes()
+	for i, leaf := range leaves {
+		for id, hash := range merkleAppendNodes(nodes, int64(i), leaf) {
+			nodes.hashes[id] = hash
+		}
+	}
+	require.Empty(t, nodes.missing, "appends only need nodes of the tree so far")
+
+	return nodes
+}
+
+func TestMerkleRoot(t *testing.T) {
+	leaves := merkleTestLeafHashes(t)
+
+	empty := merkleRoot(newMerkleNodes(), 0)
+	assert.Equal(
+		t,
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		hex.EncodeToString(empty),
+	)
+
+	for size := 1; size <= len(leaves); size++ {
+		t.Run(
+			fmt.Sprintf("size %d", size), func(t *testing.T) {
+				expected := merkleTestRoots[size-1]
+
+				nodes := merkleNodesFromLeaves(leaves[:size])
+				assert.Equal(t, expected, hex.EncodeToString(merkleRoot(nodes, int64(size))))
+
+				nodes = appendedMerkleNodes(t, leaves[:size])
+				assert.Equal(t, expected, hex.EncodeToString(merkleRoot(nodes, int64(size))))
+				assert.Empty(t, nodes.missing)
+			},
+		)
+	}
+
+	t.Run(
+		"earlier sizes from a larger tree", func(t *testing.T) {
+			nodes := appendedMerkleNodes(t, leaves)
+			for size := 1; size <= len(leaves); size++ {
+				assert.Equal(
+					t,
+					merkleTestRoots[size-1],
+					hex.EncodeToString(merkleRoot(nodes, int64(size))),
+					"size %d",
+					size,
+				)
+			}
+			assert.Empty(t, nodes.missing)
+		},
+	)
+}
+
+func TestMerkleInclusionProof(t *testing.T) {
+	nodes := merkleNodesFromLeaves(merkleTestLeafHashes(t))
+
+	for _, test := range merkleTestInclusionProofs {
+		t.Run(
+			fmt.Sprintf("leaf %d of %d", test.leaf, test.treeSize), func(t *testing.T) {
+				path := merkleInclusionProof(nodes, test.leaf-1, test.treeSize)
+				assert.Equal(t, decodeHexHashes(t, test.path), path)
+				assert.Empty(t, nodes.missing)
+			},
+		)
+	}
+}
+
+func TestMerkleInclusionProofVerifies(t *testing.T) {
+	leaves := merkleTestLeafHashes(t)
+	nodes := merkleNodesFromLeaves(leaves)
+
+	for size := int64(1); size <= int64(len(leaves)); size++ {
+		root := merkleRoot(nodes, size)
+		for m := int64(0); m < size; m++ {
+			path := merkleInclusionProof(nodes, m, size)
+			assert.Equal(
+				t,
+				root,
+				rootFromInclusionProof(m, size, leaves[m], path),
+				"leaf %d of %d",
+				m,
+				size,
+			)
+		}
+	}
+	assert.Empty(t, nodes.missing)
+}
+
+func TestMerkleConsistencyProof(t *testing.T) {
+	nodes := merkleNodesFromLeaves(merkleTestLeafHashes(t))
+
+	for _, test := range merkleTestConsistencyProofs {
+		t.Run(
+			fmt.Sprintf("%d to %d", test.first, test.second), func(t *testing.T) {
+				proof := merkleConsistencyProof(nodes, test.first, test.second)
+				assert.Equal(t, decodeHexHashes(t, test.proof), proof)
+				assert.Empty(t, nodes.missing)
+			},
+		)
+	}
+}
+
+func TestMerkleConsistencyProofVerifies(t *testing.T) {
+	leaves := merkleTestLeafHashes(t)
+	nodes := merkleNodesFromLeaves(leaves)
+
+	for second := int64(1); second <= int64(len(leaves)); second++ {
+		for first := int64(1); first <= second; first++ {
+			proof := merkleConsistencyProof(nodes, first, second)
+			if first == second {
+				assert.Empty(t, proof, "%d to %d", first, second)
+				continue
+			}
+
+			firstRoot := merkleRoot(nodes, first)
+			provenFirst, provenSecond := rootsFromConsistencyProof(first, second, firstRoot, proof)
+			assert.Equal(t, firstRoot, provenFirst, "%d to %d", first, second)
+			assert.Equal(t, merkleRoot(nodes, second), provenSecond, "%d to %d", first, second)
+		}
+	}
+	assert.Empty(t, nodes.missing)
+}
+
+func TestMerkleRangeNodes(t *testing.T) {
+	assert.Empty(t, merkleRangeNodes(0, 0))
+	assert.Equal(t, []merkleNodeID{{Level: 3, Index: 0}}, merkleRangeNodes(0, 8))
+	assert.Equal(
+		t,
+		[]merkleNodeID{{Level: 2, Index: 0}, {Level: 1, Index: 2}, {Level: 0, Index: 6}},
+		merkleRangeNodes(0, 7),
+	)
+	assert.Equal(
+		t,
+		[]merkleNodeID{{Level: 0, Index: 5}, {Level: 1, Index: 3}},
+		merkleRangeNodes(5, 8),
+	)
+}
+
+// rootFromInclusionProof is the verification algorithm of RFC 9162 section 2.1.3.2
+func rootFromInclusionProof(m int64, size int64, leaf []byte, path [][]byte) []byte {
+	fn, sn := m, size-1
+	root := leaf
+	for _, p := range path {
+		if sn == 0 {
+			return nil
+		}
+
+		if fn%2 == 1 || fn == sn {
+			root = merkleNodeHash(p, root)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			root = merkleNodeHash(root, p)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return nil
+	}
+
+	return root
+}
+
+// rootsFromConsistencyProof is the verification algorithm of RFC 9162 section 2.1.4.2, returning
+// the roots of both trees the proof leads to
+func rootsFromConsistencyProof(
+	first int64,
+	second int64,
+	firstRoot []byte,
+	proof [][]byte,
+) ([]byte, []byte) {
+	if len(proof) == 0 {
+		return nil, nil
+	}
+
+	// A first tree of a power of two leaves is a subtree of the second, which the proof omits
+	if first&(first-1) == 0 {
+		proof = append([][]byte{firstRoot}, proof...)
+	}
+
+	fn, sn := first-1, second-1
+	for fn%2 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return nil, nil
+		}
+
+		if fn%2 == 1 || fn == sn {
+			fr = merkleNodeHash(c, fr)
+			sr = merkleNodeHash(c, sr)
+			for fn%2 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = merkleNodeHash(sr, c)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return nil, nil
+	}
+
+	return fr, sr
+}
@@ -0,0 +1,159 @@
+// Package testutils builds the keys, certificates and CRLs tests across packages use as fixtures
+package testutils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Key generates a key of the given kind, rsa, ecdsa or ed25519
+func Key(t testing.TB, kind string) crypto.Signer {
+	t.Helper()
+
+	var key crypto.Signer
+	var err error
+	switch kind {
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ecdsa":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		t.Fatalf("unknown key kind %s", kind)
+	}
+	require.NoError(t, err)
+
+	return key
+}
+
+// Certificate signs template for key with issuerKey, self-signed when issuer is nil. A missing
+// serial number is made up and a missing validity runs from an hour ago for a day.
+func Certificate(
+	t testing.TB,
+	template *x509.Certificate,
+	key crypto.Signer,
+	issuer *x509.Certificate,
+	issuerKey crypto.Signer,
+) *x509.Certificate {
+	t.Helper()
+
+	if template.SerialNumber == nil {
+		serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+		require.NoError(t, err)
+
+		template.SerialNumber = serial
+	}
+
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(24 * time.Hour)
+	}
+
+	if issuer == nil {
+		issuer, issuerKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), issuerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+// CACertificate issues a CA certificate named commonName, self-signed when issuer is nil
+func CACertificate(
+	t testing.TB,
+	commonName string,
+	key crypto.Signer,
+	issuer *x509.Certificate,
+	issuerKey crypto.Signer,
+) *x509.Certificate {
+	t.Helper()
+
+	return Certificate(
+		t,
+		&x509.Certificate{
+			Subject: pkix.Name{CommonName: commonName},
+			KeyUsage: x509.KeyUsageDigitalSignature |
+				x509.KeyUsageCertSign |
+				x509.KeyUsageCRLSign,
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+		},
+		key,
+		issuer,
+		issuerKey,
+	)
+}
+
+// LeafCertificate issues a digital signature certificate named commonName
+func LeafCertificate(
+	t testing.TB,
+	commonName string,
+	key crypto.Signer,
+	issuer *x509.Certificate,
+	issuerKey crypto.Signer,
+) *x509.Certificate {
+	t.Helper()
+
+	return Certificate(
+		t,
+		&x509.Certificate{
+			Subject:  pkix.Name{CommonName: commonName},
+			KeyUsage: x509.KeyUsageDigitalSignature,
+		},
+		key,
+		issuer,
+		issuerKey,
+	)
+}
+
+// CRL signs a CRL from ca revoking the given certificates a minute ago
+func CRL(
+	t testing.TB,
+	ca *x509.Certificate,
+	caKey crypto.Signer,
+	revoked ...*x509.Certificate,
+) []byte {
+	t.Helper()
+
+	entries := make([]x509.RevocationListEntry, len(revoked))
+	for i, cert := range revoked {
+		entries[i] = x509.RevocationListEntry{
+			SerialNumber:   cert.SerialNumber,
+			RevocationTime: time.Now().Add(-time.Minute),
+		}
+	}
+
+	der, err := x509.CreateRevocationList(
+		rand.Reader,
+		&x509.RevocationList{
+			RevokedCertificateEntries: entries,
+			Number:                    big.NewInt(1),
+			ThisUpdate:                time.Now().Add(-time.Minute),
+			NextUpdate:                time.Now().Add(time.Hour),
+		},
+		ca,
+		caKey,
+	)
+	require.NoError(t, err)
+
+	return der
+}
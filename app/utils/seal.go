@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+var ErrNoSecret = errors.New("no server secret configured")
+
+// Seal encrypts plaintext with AES-256-GCM under a key derived from the server secret
+func Seal(secret string, plaintext []byte) ([]byte, error) {
+	aead, err := secretAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts data produced by Seal with the same server secret
+func Open(secret string, sealed []byte) ([]byte, error) {
+	aead, err := secretAEAD(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed data is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
+
+func secretAEAD(secret string) (cipher.AEAD, error) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// DeriveKey derives a 256 bit key for purpose from the server secret. Keys for different purposes
+// are independent of each other and of the key Seal uses.
+func DeriveKey(secret string, purpose string) ([]byte, error) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,8 @@
+// Package migrations embeds the schema migrations for each database backend. Every dialect has
+// its own directory of <version>-<name> scripts, each with an optional .down counterpart.
+package migrations
+
+import "embed"
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql neo4j/*.cypher
+var FS embed.FS
@@ -0,0 +1,18 @@
+CREATE TABLE users (
+    id         CHAR(36)     NOT NULL,
+    first_name VARCHAR(255) NOT NULL DEFAULT '',
+    last_name  VARCHAR(255) NOT NULL DEFAULT '',
+    email      VARCHAR(255) NOT NULL,
+    password   VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_users_email (email)
+);
+
+CREATE TABLE sessions (
+    id         CHAR(36)    NOT NULL,
+    created    DATETIME(3) NOT NULL,
+    expiration DATETIME(3) NOT NULL,
+    user_id    CHAR(36)    NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_sessions_expiration (expiration)
+);
@@ -0,0 +1,27 @@
+CREATE TABLE ssh_certificate_authorities (
+    id         CHAR(36)     NOT NULL,
+    user_id    CHAR(36)     NOT NULL,
+    name       VARCHAR(255) NOT NULL,
+    key_id     CHAR(36)     NOT NULL,
+    public_key BLOB         NOT NULL,
+    created    DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_ssh_certificate_authorities_user_id (user_id)
+);
+
+CREATE TABLE ssh_certificates (
+    id           CHAR(36)     NOT NULL,
+    user_id      CHAR(36)     NOT NULL,
+    ca_id        CHAR(36)     NOT NULL,
+    serial       BIGINT       NOT NULL,
+    cert_type    VARCHAR(16)  NOT NULL,
+    key_identity VARCHAR(255) NOT NULL,
+    principals   TEXT         NOT NULL,
+    valid_after  DATETIME(3)  NOT NULL,
+    valid_before DATETIME(3)  NOT NULL,
+    data         BLOB         NOT NULL,
+    revoked_at   DATETIME(3)  NULL,
+    created      DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_ssh_certificates_ca_serial (ca_id, serial)
+);
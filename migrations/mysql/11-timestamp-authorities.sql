@@ -0,0 +1,29 @@
+CREATE TABLE timestamp_authorities (
+    id                  CHAR(36)     NOT NULL,
+    user_id             CHAR(36)     NOT NULL,
+    name                VARCHAR(255) NOT NULL,
+    certificate_id      CHAR(36)     NOT NULL,
+    sealed_key_password BLOB         NOT NULL,
+    policy              VARCHAR(255) NOT NULL,
+    accepted_policies   TEXT         NOT NULL,
+    accuracy_millis     INT          NOT NULL,
+    require_nonce       BOOLEAN      NOT NULL,
+    created             DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_timestamp_authorities_user_id (user_id)
+);
+
+CREATE TABLE timestamps (
+    id              CHAR(36)     NOT NULL,
+    tsa_id          CHAR(36)     NOT NULL,
+    serial_number   VARCHAR(64)  NOT NULL,
+    gen_time        DATETIME(3)  NOT NULL,
+    policy          VARCHAR(255) NOT NULL,
+    hash_algorithm  VARCHAR(16)  NOT NULL,
+    message_imprint BLOB         NOT NULL,
+    nonce           VARCHAR(64)  NOT NULL,
+    token           BLOB         NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_timestamps_tsa_serial (tsa_id, serial_number),
+    KEY idx_timestamps_tsa_gen_time (tsa_id, gen_time)
+);
@@ -0,0 +1,22 @@
+CREATE TABLE jwk_sets (
+    id              CHAR(36)     NOT NULL,
+    user_id         CHAR(36)     NOT NULL,
+    name            VARCHAR(255) NOT NULL,
+    overlap_seconds INT          NOT NULL,
+    created         DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_jwk_sets_user_id (user_id)
+);
+
+CREATE TABLE jwk_set_keys (
+    id         CHAR(36)     NOT NULL,
+    set_id     CHAR(36)     NOT NULL,
+    key_id     CHAR(36)     NOT NULL,
+    kid        VARCHAR(64)  NOT NULL,
+    algorithm  VARCHAR(16)  NOT NULL,
+    jwk        TEXT         NOT NULL,
+    retires_at DATETIME(3)  NULL,
+    created    DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_jwk_set_keys_set_kid (set_id, kid)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE certificates DROP COLUMN refresh_error;
+ALTER TABLE certificates DROP COLUMN sealed_key_password;
@@ -0,0 +1,12 @@
+ALTER TABLE transparency_logs ADD COLUMN tree_head_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transparency_logs ADD COLUMN tree_head_timestamp BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transparency_logs ADD COLUMN root_hash VARBINARY(32) NULL;
+ALTER TABLE transparency_logs ADD COLUMN tree_head_signature BLOB NULL;
+
+CREATE TABLE transparency_log_nodes (
+    ca_id      CHAR(36)      NOT NULL,
+    level      INT           NOT NULL,
+    node_index BIGINT        NOT NULL,
+    hash       VARBINARY(32) NOT NULL,
+    PRIMARY KEY (ca_id, level, node_index)
+);
@@ -0,0 +1 @@
+ALTER TABLE `keys` ADD COLUMN public_key BLOB NULL;
@@ -0,0 +1,41 @@
+CREATE TABLE `keys` (
+    id        CHAR(36)     NOT NULL,
+    user_id   CHAR(36)     NOT NULL,
+    name      VARCHAR(255) NOT NULL DEFAULT '',
+    data      BLOB         NOT NULL,
+    algorithm VARCHAR(16)  NOT NULL,
+    key_size  INT          NOT NULL DEFAULT 0,
+    curve     VARCHAR(16)  NOT NULL DEFAULT '',
+    created   DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_keys_user_id (user_id)
+);
+
+CREATE TABLE certificates (
+    id                 CHAR(36)     NOT NULL,
+    user_id            CHAR(36)     NOT NULL,
+    name               VARCHAR(255) NOT NULL DEFAULT '',
+    data               BLOB         NOT NULL,
+    type               VARCHAR(32)  NOT NULL,
+    created            DATETIME(3)  NOT NULL,
+    parent_certificate VARCHAR(36)  NOT NULL DEFAULT '',
+    serial_number      VARCHAR(64)  NULL,
+    key_id             CHAR(36)     NULL,
+    revoked_at         DATETIME(3)  NULL,
+    revocation_reason  INT          NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_cert_issuer_serial (parent_certificate, serial_number),
+    KEY idx_certificates_user_id_type (user_id, type)
+);
+
+CREATE TABLE crls (
+    id             CHAR(36)    NOT NULL,
+    certificate_id CHAR(36)    NOT NULL,
+    number         BIGINT      NOT NULL,
+    data           MEDIUMBLOB  NOT NULL,
+    this_update    DATETIME(3) NOT NULL,
+    next_update    DATETIME(3) NOT NULL,
+    created        DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_crl_number (certificate_id, number)
+);
@@ -0,0 +1,21 @@
+CREATE TABLE certificate_profiles (
+    id                     CHAR(36)     NOT NULL,
+    user_id                CHAR(36)     NOT NULL,
+    name                   VARCHAR(255) NOT NULL,
+    key_usages             JSON         NULL,
+    ext_key_usages         JSON         NULL,
+    max_validity_days      INT          NOT NULL DEFAULT 0,
+    allowed_key_algorithms JSON         NULL,
+    is_ca                  BOOLEAN      NOT NULL DEFAULT FALSE,
+    max_path_len           INT          NOT NULL DEFAULT 0,
+    organization           VARCHAR(255) NOT NULL DEFAULT '',
+    organizational_unit    VARCHAR(255) NOT NULL DEFAULT '',
+    country                VARCHAR(255) NOT NULL DEFAULT '',
+    province               VARCHAR(255) NOT NULL DEFAULT '',
+    locality               VARCHAR(255) NOT NULL DEFAULT '',
+    street_address         VARCHAR(255) NOT NULL DEFAULT '',
+    postal_code            VARCHAR(255) NOT NULL DEFAULT '',
+    created                DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_certificate_profiles_user_id (user_id)
+);
@@ -0,0 +1,11 @@
+DROP TABLE acme_nonces;
+
+DROP TABLE acme_challenges;
+
+DROP TABLE acme_authorizations;
+
+DROP TABLE acme_orders;
+
+DROP TABLE acme_accounts;
+
+DROP TABLE acme_directories;
@@ -0,0 +1,68 @@
+CREATE TABLE acme_directories (
+    certificate_id      CHAR(36)    NOT NULL,
+    user_id             CHAR(36)    NOT NULL,
+    sealed_key_password BLOB        NULL,
+    cert_validity_days  INT         NOT NULL DEFAULT 0,
+    created             DATETIME(3) NOT NULL,
+    PRIMARY KEY (certificate_id)
+);
+
+CREATE TABLE acme_accounts (
+    id             CHAR(36)     NOT NULL,
+    certificate_id CHAR(36)     NOT NULL,
+    thumbprint     VARCHAR(64)  NOT NULL,
+    jwk            TEXT         NOT NULL,
+    contact        TEXT         NOT NULL,
+    status         VARCHAR(16)  NOT NULL,
+    created        DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_acme_accounts_thumbprint (certificate_id, thumbprint)
+);
+
+CREATE TABLE acme_orders (
+    id             CHAR(36)    NOT NULL,
+    account_id     CHAR(36)    NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    identifiers    TEXT        NOT NULL,
+    not_before     DATETIME(3) NULL,
+    not_after      DATETIME(3) NULL,
+    expires        DATETIME(3) NOT NULL,
+    error          TEXT        NOT NULL,
+    certificate_id VARCHAR(36) NOT NULL DEFAULT '',
+    created        DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_acme_orders_account_id (account_id)
+);
+
+CREATE TABLE acme_authorizations (
+    id               CHAR(36)     NOT NULL,
+    order_id         CHAR(36)     NOT NULL,
+    identifier_type  VARCHAR(16)  NOT NULL,
+    identifier_value VARCHAR(255) NOT NULL,
+    wildcard         BOOLEAN      NOT NULL DEFAULT FALSE,
+    status           VARCHAR(16)  NOT NULL,
+    expires          DATETIME(3)  NOT NULL,
+    created          DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_acme_authorizations_order_id (order_id)
+);
+
+CREATE TABLE acme_challenges (
+    id               CHAR(36)     NOT NULL,
+    authorization_id CHAR(36)     NOT NULL,
+    type             VARCHAR(32)  NOT NULL,
+    token            VARCHAR(128) NOT NULL,
+    status           VARCHAR(16)  NOT NULL,
+    validated        DATETIME(3)  NULL,
+    error            TEXT         NOT NULL,
+    created          DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_acme_challenges_authorization_id (authorization_id)
+);
+
+CREATE TABLE acme_nonces (
+    value   VARCHAR(64) NOT NULL,
+    created DATETIME(3) NOT NULL,
+    PRIMARY KEY (value),
+    KEY idx_acme_nonces_created (created)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE certificates ADD COLUMN replaces CHAR(36) NULL;
+
+CREATE INDEX idx_certificates_replaces ON certificates (replaces);
@@ -0,0 +1,26 @@
+CREATE TABLE auto_renew_policies (
+    certificate_id         CHAR(36)    NOT NULL,
+    user_id                CHAR(36)    NOT NULL,
+    threshold_percent      INT         NOT NULL DEFAULT 0,
+    threshold_days         INT         NOT NULL DEFAULT 0,
+    sealed_ca_key_password BLOB        NULL,
+    sealed_key_password    BLOB        NULL,
+    revoke_predecessor     BOOLEAN     NOT NULL DEFAULT FALSE,
+    renew_at               DATETIME(3) NOT NULL,
+    next_attempt           DATETIME(3) NOT NULL,
+    status                 VARCHAR(16) NOT NULL,
+    failures               INT         NOT NULL DEFAULT 0,
+    last_attempt           DATETIME(3) NULL,
+    last_error             TEXT        NULL,
+    renewed_certificate_id CHAR(36)    NULL,
+    created                DATETIME(3) NOT NULL,
+    PRIMARY KEY (certificate_id),
+    KEY idx_auto_renew_policies_due (status, next_attempt)
+);
+
+CREATE TABLE leases (
+    name    VARCHAR(64)  NOT NULL,
+    holder  VARCHAR(255) NOT NULL,
+    expires DATETIME(3)  NOT NULL,
+    PRIMARY KEY (name)
+);
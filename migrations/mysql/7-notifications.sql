@@ -0,0 +1,30 @@
+CREATE TABLE notification_rules (
+    id            CHAR(36)     NOT NULL,
+    user_id       CHAR(36)     NOT NULL,
+    name          VARCHAR(255) NOT NULL,
+    ca_id         VARCHAR(36)  NOT NULL DEFAULT '',
+    days_before   TEXT         NOT NULL,
+    channel       VARCHAR(16)  NOT NULL,
+    target        TEXT         NOT NULL,
+    sealed_secret BLOB         NULL,
+    created       DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_notification_rules_user_id (user_id)
+);
+
+CREATE TABLE notification_deliveries (
+    id             CHAR(36)    NOT NULL,
+    rule_id        CHAR(36)    NOT NULL,
+    certificate_id CHAR(36)    NOT NULL,
+    days_before    INT         NOT NULL,
+    not_after      DATETIME(3) NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    attempts       INT         NOT NULL DEFAULT 0,
+    next_attempt   DATETIME(3) NOT NULL,
+    last_attempt   DATETIME(3) NULL,
+    last_error     TEXT        NULL,
+    created        DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY idx_notification_deliveries_stage (rule_id, certificate_id, days_before),
+    KEY idx_notification_deliveries_due (status, next_attempt)
+);
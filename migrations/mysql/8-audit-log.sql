@@ -0,0 +1,17 @@
+CREATE TABLE audit_entries (
+    sequence    BIGINT       NOT NULL,
+    actor_id    VARCHAR(36)  NOT NULL DEFAULT '',
+    action      VARCHAR(64)  NOT NULL,
+    target_type VARCHAR(32)  NOT NULL DEFAULT '',
+    target_id   VARCHAR(255) NOT NULL DEFAULT '',
+    outcome     VARCHAR(16)  NOT NULL,
+    error       TEXT         NOT NULL,
+    source_ip   VARCHAR(45)  NOT NULL DEFAULT '',
+    user_agent  TEXT         NOT NULL,
+    timestamp   DATETIME(3)  NOT NULL,
+    prev_hash   CHAR(64)     NOT NULL,
+    hash        CHAR(64)     NOT NULL,
+    PRIMARY KEY (sequence),
+    KEY idx_audit_entries_actor_id (actor_id, sequence),
+    KEY idx_audit_entries_target_id (target_id)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE transparency_logs (
+    ca_id              CHAR(36)    NOT NULL,
+    tree_size          BIGINT      NOT NULL DEFAULT 0,
+    public_key         BLOB        NULL,
+    sealed_private_key BLOB        NULL,
+    created            DATETIME(3) NOT NULL,
+    PRIMARY KEY (ca_id)
+);
+
+CREATE TABLE transparency_log_entries (
+    ca_id          CHAR(36)      NOT NULL,
+    leaf_index     BIGINT        NOT NULL,
+    certificate_id CHAR(36)      NOT NULL,
+    leaf_hash      VARBINARY(32) NOT NULL,
+    leaf_input     MEDIUMBLOB    NOT NULL,
+    PRIMARY KEY (ca_id, leaf_index),
+    KEY idx_transparency_log_entries_leaf_hash (ca_id, leaf_hash),
+    KEY idx_transparency_log_entries_certificate_id (ca_id, certificate_id)
+);
@@ -0,0 +1,3 @@
+DROP CONSTRAINT email_unique IF EXISTS;
+
+DROP CONSTRAINT uuid_unique IF EXISTS;
@@ -0,0 +1,11 @@
+CREATE CONSTRAINT timestamp_authority_id_unique IF NOT EXISTS
+FOR (a:TimestampAuthority)
+REQUIRE a.uuid IS UNIQUE;
+
+CREATE CONSTRAINT timestamp_id_unique IF NOT EXISTS
+FOR (t:Timestamp)
+REQUIRE t.uuid IS UNIQUE;
+
+CREATE CONSTRAINT timestamp_tsa_serial_unique IF NOT EXISTS
+FOR (t:Timestamp)
+REQUIRE t.tsaSerial IS UNIQUE;
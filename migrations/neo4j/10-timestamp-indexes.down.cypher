@@ -0,0 +1,5 @@
+DROP CONSTRAINT timestamp_tsa_serial_unique IF EXISTS;
+
+DROP CONSTRAINT timestamp_id_unique IF EXISTS;
+
+DROP CONSTRAINT timestamp_authority_id_unique IF EXISTS;
@@ -0,0 +1,11 @@
+CREATE CONSTRAINT jwk_set_id_unique IF NOT EXISTS
+FOR (s:JWKSet)
+REQUIRE s.uuid IS UNIQUE;
+
+CREATE CONSTRAINT jwk_set_key_id_unique IF NOT EXISTS
+FOR (k:JWKSetKey)
+REQUIRE k.uuid IS UNIQUE;
+
+CREATE CONSTRAINT jwk_set_key_set_kid_unique IF NOT EXISTS
+FOR (k:JWKSetKey)
+REQUIRE k.setKid IS UNIQUE;
@@ -0,0 +1,5 @@
+DROP CONSTRAINT jwk_set_key_set_kid_unique IF EXISTS;
+
+DROP CONSTRAINT jwk_set_key_id_unique IF EXISTS;
+
+DROP CONSTRAINT jwk_set_id_unique IF EXISTS;
@@ -0,0 +1,3 @@
+CREATE INDEX transparency_log_node_index IF NOT EXISTS
+FOR (n:TransparencyLogNode)
+ON (n.caID, n.level, n.nodeIndex);
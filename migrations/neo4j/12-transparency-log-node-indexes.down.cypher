@@ -0,0 +1 @@
+DROP INDEX transparency_log_node_index IF EXISTS;
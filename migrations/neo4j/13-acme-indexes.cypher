@@ -0,0 +1,35 @@
+CREATE CONSTRAINT acme_directory_certificate_unique IF NOT EXISTS
+FOR (d:AcmeDirectory)
+REQUIRE d.certificateID IS UNIQUE;
+
+CREATE CONSTRAINT acme_account_id_unique IF NOT EXISTS
+FOR (a:AcmeAccount)
+REQUIRE a.uuid IS UNIQUE;
+
+CREATE INDEX acme_account_thumbprint_index IF NOT EXISTS
+FOR (a:AcmeAccount)
+ON (a.certificateID, a.thumbprint);
+
+CREATE CONSTRAINT acme_order_id_unique IF NOT EXISTS
+FOR (o:AcmeOrder)
+REQUIRE o.uuid IS UNIQUE;
+
+CREATE INDEX acme_order_account_index IF NOT EXISTS
+FOR (o:AcmeOrder)
+ON (o.accountID);
+
+CREATE CONSTRAINT acme_authorization_id_unique IF NOT EXISTS
+FOR (a:AcmeAuthorization)
+REQUIRE a.uuid IS UNIQUE;
+
+CREATE CONSTRAINT acme_challenge_id_unique IF NOT EXISTS
+FOR (c:AcmeChallenge)
+REQUIRE c.uuid IS UNIQUE;
+
+CREATE CONSTRAINT acme_nonce_value_unique IF NOT EXISTS
+FOR (n:AcmeNonce)
+REQUIRE n.value IS UNIQUE;
+
+CREATE INDEX acme_nonce_created_index IF NOT EXISTS
+FOR (n:AcmeNonce)
+ON (n.created);
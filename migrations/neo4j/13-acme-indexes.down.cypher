@@ -0,0 +1,17 @@
+DROP INDEX acme_nonce_created_index IF EXISTS;
+
+DROP CONSTRAINT acme_nonce_value_unique IF EXISTS;
+
+DROP CONSTRAINT acme_challenge_id_unique IF EXISTS;
+
+DROP CONSTRAINT acme_authorization_id_unique IF EXISTS;
+
+DROP INDEX acme_order_account_index IF EXISTS;
+
+DROP CONSTRAINT acme_order_id_unique IF EXISTS;
+
+DROP INDEX acme_account_thumbprint_index IF EXISTS;
+
+DROP CONSTRAINT acme_account_id_unique IF EXISTS;
+
+DROP CONSTRAINT acme_directory_certificate_unique IF EXISTS;
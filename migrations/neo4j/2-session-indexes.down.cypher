@@ -0,0 +1,3 @@
+DROP INDEX session_expires_index IF EXISTS;
+
+DROP CONSTRAINT session_id_unique IF EXISTS;
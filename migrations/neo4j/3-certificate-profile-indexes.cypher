@@ -0,0 +1,3 @@
+CREATE CONSTRAINT certificate_profile_id_unique IF NOT EXISTS
+FOR (p:CertificateProfile)
+REQUIRE p.uuid IS UNIQUE;
@@ -0,0 +1 @@
+DROP CONSTRAINT certificate_profile_id_unique IF EXISTS;
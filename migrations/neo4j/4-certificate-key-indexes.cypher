@@ -0,0 +1,27 @@
+CREATE CONSTRAINT certificate_id_unique IF NOT EXISTS
+FOR (c:Certificate)
+REQUIRE c.uuid IS UNIQUE;
+
+CREATE CONSTRAINT certificate_issuer_serial_unique IF NOT EXISTS
+FOR (c:Certificate)
+REQUIRE c.issuerSerial IS UNIQUE;
+
+CREATE INDEX certificate_type_index IF NOT EXISTS
+FOR (c:Certificate)
+ON (c.type);
+
+CREATE CONSTRAINT key_id_unique IF NOT EXISTS
+FOR (k:Key)
+REQUIRE k.uuid IS UNIQUE;
+
+CREATE CONSTRAINT crl_id_unique IF NOT EXISTS
+FOR (l:CRL)
+REQUIRE l.uuid IS UNIQUE;
+
+CREATE CONSTRAINT crl_number_unique IF NOT EXISTS
+FOR (l:CRL)
+REQUIRE l.caNumber IS UNIQUE;
+
+CREATE INDEX crl_certificate_index IF NOT EXISTS
+FOR (l:CRL)
+ON (l.certificateID);
@@ -0,0 +1,13 @@
+DROP INDEX crl_certificate_index IF EXISTS;
+
+DROP CONSTRAINT crl_number_unique IF EXISTS;
+
+DROP CONSTRAINT crl_id_unique IF EXISTS;
+
+DROP CONSTRAINT key_id_unique IF EXISTS;
+
+DROP INDEX certificate_type_index IF EXISTS;
+
+DROP CONSTRAINT certificate_issuer_serial_unique IF EXISTS;
+
+DROP CONSTRAINT certificate_id_unique IF EXISTS;
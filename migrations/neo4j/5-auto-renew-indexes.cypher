@@ -0,0 +1,11 @@
+CREATE CONSTRAINT auto_renew_policy_certificate_unique IF NOT EXISTS
+FOR (p:AutoRenewPolicy)
+REQUIRE p.certificateID IS UNIQUE;
+
+CREATE INDEX auto_renew_policy_due_index IF NOT EXISTS
+FOR (p:AutoRenewPolicy)
+ON (p.status, p.nextAttempt);
+
+CREATE CONSTRAINT lease_name_unique IF NOT EXISTS
+FOR (l:Lease)
+REQUIRE l.name IS UNIQUE;
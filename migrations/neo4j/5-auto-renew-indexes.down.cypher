@@ -0,0 +1,5 @@
+DROP CONSTRAINT lease_name_unique IF EXISTS;
+
+DROP INDEX auto_renew_policy_due_index IF EXISTS;
+
+DROP CONSTRAINT auto_renew_policy_certificate_unique IF EXISTS;
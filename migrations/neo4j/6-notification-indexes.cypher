@@ -0,0 +1,15 @@
+CREATE CONSTRAINT notification_rule_id_unique IF NOT EXISTS
+FOR (r:NotificationRule)
+REQUIRE r.uuid IS UNIQUE;
+
+CREATE CONSTRAINT notification_delivery_id_unique IF NOT EXISTS
+FOR (d:NotificationDelivery)
+REQUIRE d.uuid IS UNIQUE;
+
+CREATE CONSTRAINT notification_delivery_stage_unique IF NOT EXISTS
+FOR (d:NotificationDelivery)
+REQUIRE d.stage IS UNIQUE;
+
+CREATE INDEX notification_delivery_due_index IF NOT EXISTS
+FOR (d:NotificationDelivery)
+ON (d.status, d.nextAttempt);
@@ -0,0 +1,7 @@
+DROP INDEX notification_delivery_due_index IF EXISTS;
+
+DROP CONSTRAINT notification_delivery_stage_unique IF EXISTS;
+
+DROP CONSTRAINT notification_delivery_id_unique IF EXISTS;
+
+DROP CONSTRAINT notification_rule_id_unique IF EXISTS;
@@ -0,0 +1,11 @@
+CREATE CONSTRAINT audit_entry_sequence_unique IF NOT EXISTS
+FOR (e:AuditEntry)
+REQUIRE e.sequence IS UNIQUE;
+
+CREATE INDEX audit_entry_actor_index IF NOT EXISTS
+FOR (e:AuditEntry)
+ON (e.actorID);
+
+CREATE INDEX audit_entry_target_index IF NOT EXISTS
+FOR (e:AuditEntry)
+ON (e.targetID);
@@ -0,0 +1,5 @@
+DROP INDEX audit_entry_target_index IF EXISTS;
+
+DROP INDEX audit_entry_actor_index IF EXISTS;
+
+DROP CONSTRAINT audit_entry_sequence_unique IF EXISTS;
@@ -0,0 +1,11 @@
+CREATE CONSTRAINT transparency_log_ca_unique IF NOT EXISTS
+FOR (l:TransparencyLog)
+REQUIRE l.caID IS UNIQUE;
+
+CREATE INDEX transparency_log_entry_index IF NOT EXISTS
+FOR (e:TransparencyLogEntry)
+ON (e.caID, e.leafIndex);
+
+CREATE INDEX transparency_log_entry_certificate_index IF NOT EXISTS
+FOR (e:TransparencyLogEntry)
+ON (e.caID, e.certificateID);
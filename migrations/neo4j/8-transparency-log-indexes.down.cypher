@@ -0,0 +1,5 @@
+DROP INDEX transparency_log_entry_certificate_index IF EXISTS;
+
+DROP INDEX transparency_log_entry_index IF EXISTS;
+
+DROP CONSTRAINT transparency_log_ca_unique IF EXISTS;
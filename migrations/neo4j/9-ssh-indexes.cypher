@@ -0,0 +1,11 @@
+CREATE CONSTRAINT ssh_ca_id_unique IF NOT EXISTS
+FOR (a:SSHCertificateAuthority)
+REQUIRE a.uuid IS UNIQUE;
+
+CREATE CONSTRAINT ssh_certificate_id_unique IF NOT EXISTS
+FOR (c:SSHCertificate)
+REQUIRE c.uuid IS UNIQUE;
+
+CREATE CONSTRAINT ssh_certificate_ca_serial_unique IF NOT EXISTS
+FOR (c:SSHCertificate)
+REQUIRE c.caSerial IS UNIQUE;
@@ -0,0 +1,5 @@
+DROP CONSTRAINT ssh_certificate_ca_serial_unique IF EXISTS;
+
+DROP CONSTRAINT ssh_certificate_id_unique IF EXISTS;
+
+DROP CONSTRAINT ssh_ca_id_unique IF EXISTS;
@@ -0,0 +1,20 @@
+CREATE TABLE users (
+    id         VARCHAR(36)  NOT NULL,
+    first_name VARCHAR(255) NOT NULL DEFAULT '',
+    last_name  VARCHAR(255) NOT NULL DEFAULT '',
+    email      VARCHAR(255) NOT NULL,
+    password   VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+CREATE TABLE sessions (
+    id         VARCHAR(36) NOT NULL,
+    created    TIMESTAMPTZ NOT NULL,
+    expiration TIMESTAMPTZ NOT NULL,
+    user_id    VARCHAR(36) NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_sessions_expiration ON sessions (expiration);
@@ -0,0 +1,29 @@
+CREATE TABLE ssh_certificate_authorities (
+    id         VARCHAR(36)  NOT NULL,
+    user_id    VARCHAR(36)  NOT NULL,
+    name       VARCHAR(255) NOT NULL,
+    key_id     VARCHAR(36)  NOT NULL,
+    public_key BYTEA        NOT NULL,
+    created    TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_ssh_certificate_authorities_user_id ON ssh_certificate_authorities (user_id);
+
+CREATE TABLE ssh_certificates (
+    id           VARCHAR(36)  NOT NULL,
+    user_id      VARCHAR(36)  NOT NULL,
+    ca_id        VARCHAR(36)  NOT NULL,
+    serial       BIGINT       NOT NULL,
+    cert_type    VARCHAR(16)  NOT NULL,
+    key_identity VARCHAR(255) NOT NULL,
+    principals   TEXT         NOT NULL,
+    valid_after  TIMESTAMPTZ  NOT NULL,
+    valid_before TIMESTAMPTZ  NOT NULL,
+    data         BYTEA        NOT NULL,
+    revoked_at   TIMESTAMPTZ  NULL,
+    created      TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_ssh_certificates_ca_serial ON ssh_certificates (ca_id, serial);
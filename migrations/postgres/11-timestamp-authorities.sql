@@ -0,0 +1,31 @@
+CREATE TABLE timestamp_authorities (
+    id                  VARCHAR(36)  NOT NULL,
+    user_id             VARCHAR(36)  NOT NULL,
+    name                VARCHAR(255) NOT NULL,
+    certificate_id      VARCHAR(36)  NOT NULL,
+    sealed_key_password BYTEA        NOT NULL,
+    policy              VARCHAR(255) NOT NULL,
+    accepted_policies   TEXT         NOT NULL,
+    accuracy_millis     INT          NOT NULL,
+    require_nonce       BOOLEAN      NOT NULL,
+    created             TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_timestamp_authorities_user_id ON timestamp_authorities (user_id);
+
+CREATE TABLE timestamps (
+    id              VARCHAR(36)  NOT NULL,
+    tsa_id          VARCHAR(36)  NOT NULL,
+    serial_number   VARCHAR(64)  NOT NULL,
+    gen_time        TIMESTAMPTZ  NOT NULL,
+    policy          VARCHAR(255) NOT NULL,
+    hash_algorithm  VARCHAR(16)  NOT NULL,
+    message_imprint BYTEA        NOT NULL,
+    nonce           VARCHAR(64)  NOT NULL,
+    token           BYTEA        NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_timestamps_tsa_serial ON timestamps (tsa_id, serial_number);
+CREATE INDEX idx_timestamps_tsa_gen_time ON timestamps (tsa_id, gen_time);
@@ -0,0 +1,3 @@
+DROP TABLE jwk_set_keys;
+
+DROP TABLE jwk_sets;
@@ -0,0 +1,24 @@
+CREATE TABLE jwk_sets (
+    id              VARCHAR(36)  NOT NULL,
+    user_id         VARCHAR(36)  NOT NULL,
+    name            VARCHAR(255) NOT NULL,
+    overlap_seconds INT          NOT NULL,
+    created         TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_jwk_sets_user_id ON jwk_sets (user_id);
+
+CREATE TABLE jwk_set_keys (
+    id         VARCHAR(36)  NOT NULL,
+    set_id     VARCHAR(36)  NOT NULL,
+    key_id     VARCHAR(36)  NOT NULL,
+    kid        VARCHAR(64)  NOT NULL,
+    algorithm  VARCHAR(16)  NOT NULL,
+    jwk        TEXT         NOT NULL,
+    retires_at TIMESTAMPTZ  NULL,
+    created    TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_jwk_set_keys_set_kid ON jwk_set_keys (set_id, kid);
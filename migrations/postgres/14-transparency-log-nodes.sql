@@ -0,0 +1,12 @@
+ALTER TABLE transparency_logs ADD COLUMN tree_head_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transparency_logs ADD COLUMN tree_head_timestamp BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE transparency_logs ADD COLUMN root_hash BYTEA NULL;
+ALTER TABLE transparency_logs ADD COLUMN tree_head_signature BYTEA NULL;
+
+CREATE TABLE transparency_log_nodes (
+    ca_id      VARCHAR(36) NOT NULL,
+    level      INTEGER     NOT NULL,
+    node_index BIGINT      NOT NULL,
+    hash       BYTEA       NOT NULL,
+    PRIMARY KEY (ca_id, level, node_index)
+);
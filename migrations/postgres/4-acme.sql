@@ -0,0 +1,73 @@
+CREATE TABLE acme_directories (
+    certificate_id      VARCHAR(36) NOT NULL,
+    user_id             VARCHAR(36) NOT NULL,
+    sealed_key_password BYTEA       NULL,
+    cert_validity_days  INT         NOT NULL DEFAULT 0,
+    created             TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (certificate_id)
+);
+
+CREATE TABLE acme_accounts (
+    id             VARCHAR(36) NOT NULL,
+    certificate_id VARCHAR(36) NOT NULL,
+    thumbprint     VARCHAR(64) NOT NULL,
+    jwk            TEXT        NOT NULL,
+    contact        TEXT        NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    created        TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_acme_accounts_thumbprint ON acme_accounts (certificate_id, thumbprint);
+
+CREATE TABLE acme_orders (
+    id             VARCHAR(36) NOT NULL,
+    account_id     VARCHAR(36) NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    identifiers    TEXT        NOT NULL,
+    not_before     TIMESTAMPTZ NULL,
+    not_after      TIMESTAMPTZ NULL,
+    expires        TIMESTAMPTZ NOT NULL,
+    error          TEXT        NOT NULL,
+    certificate_id VARCHAR(36) NOT NULL DEFAULT '',
+    created        TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_acme_orders_account_id ON acme_orders (account_id);
+
+CREATE TABLE acme_authorizations (
+    id               VARCHAR(36)  NOT NULL,
+    order_id         VARCHAR(36)  NOT NULL,
+    identifier_type  VARCHAR(16)  NOT NULL,
+    identifier_value VARCHAR(255) NOT NULL,
+    wildcard         BOOLEAN      NOT NULL DEFAULT FALSE,
+    status           VARCHAR(16)  NOT NULL,
+    expires          TIMESTAMPTZ  NOT NULL,
+    created          TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_acme_authorizations_order_id ON acme_authorizations (order_id);
+
+CREATE TABLE acme_challenges (
+    id               VARCHAR(36)  NOT NULL,
+    authorization_id VARCHAR(36)  NOT NULL,
+    type             VARCHAR(32)  NOT NULL,
+    token            VARCHAR(128) NOT NULL,
+    status           VARCHAR(16)  NOT NULL,
+    validated        TIMESTAMPTZ  NULL,
+    error            TEXT         NOT NULL,
+    created          TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_acme_challenges_authorization_id ON acme_challenges (authorization_id);
+
+CREATE TABLE acme_nonces (
+    value   VARCHAR(64) NOT NULL,
+    created TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (value)
+);
+
+CREATE INDEX idx_acme_nonces_created ON acme_nonces (created);
@@ -0,0 +1,3 @@
+ALTER TABLE certificates ADD COLUMN replaces VARCHAR(36) NULL;
+
+CREATE INDEX idx_certificates_replaces ON certificates (replaces);
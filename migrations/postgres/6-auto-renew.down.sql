@@ -0,0 +1,3 @@
+DROP TABLE leases;
+
+DROP TABLE auto_renew_policies;
@@ -0,0 +1,27 @@
+CREATE TABLE auto_renew_policies (
+    certificate_id         VARCHAR(36) NOT NULL,
+    user_id                VARCHAR(36) NOT NULL,
+    threshold_percent      INT         NOT NULL DEFAULT 0,
+    threshold_days         INT         NOT NULL DEFAULT 0,
+    sealed_ca_key_password BYTEA       NULL,
+    sealed_key_password    BYTEA       NULL,
+    revoke_predecessor     BOOLEAN     NOT NULL DEFAULT FALSE,
+    renew_at               TIMESTAMPTZ NOT NULL,
+    next_attempt           TIMESTAMPTZ NOT NULL,
+    status                 VARCHAR(16) NOT NULL,
+    failures               INT         NOT NULL DEFAULT 0,
+    last_attempt           TIMESTAMPTZ NULL,
+    last_error             TEXT        NULL,
+    renewed_certificate_id VARCHAR(36) NULL,
+    created                TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (certificate_id)
+);
+
+CREATE INDEX idx_auto_renew_policies_due ON auto_renew_policies (status, next_attempt);
+
+CREATE TABLE leases (
+    name    VARCHAR(64)  NOT NULL,
+    holder  VARCHAR(255) NOT NULL,
+    expires TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (name)
+);
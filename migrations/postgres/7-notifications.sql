@@ -0,0 +1,33 @@
+CREATE TABLE notification_rules (
+    id            VARCHAR(36)  NOT NULL,
+    user_id       VARCHAR(36)  NOT NULL,
+    name          VARCHAR(255) NOT NULL,
+    ca_id         VARCHAR(36)  NOT NULL DEFAULT '',
+    days_before   TEXT         NOT NULL,
+    channel       VARCHAR(16)  NOT NULL,
+    target        TEXT         NOT NULL,
+    sealed_secret BYTEA        NULL,
+    created       TIMESTAMPTZ  NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_notification_rules_user_id ON notification_rules (user_id);
+
+CREATE TABLE notification_deliveries (
+    id             VARCHAR(36) NOT NULL,
+    rule_id        VARCHAR(36) NOT NULL,
+    certificate_id VARCHAR(36) NOT NULL,
+    days_before    INT         NOT NULL,
+    not_after      TIMESTAMPTZ NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    attempts       INT         NOT NULL DEFAULT 0,
+    next_attempt   TIMESTAMPTZ NOT NULL,
+    last_attempt   TIMESTAMPTZ NULL,
+    last_error     TEXT        NULL,
+    created        TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_notification_deliveries_stage
+    ON notification_deliveries (rule_id, certificate_id, days_before);
+CREATE INDEX idx_notification_deliveries_due ON notification_deliveries (status, next_attempt);
@@ -0,0 +1,18 @@
+CREATE TABLE audit_entries (
+    sequence    BIGINT       NOT NULL,
+    actor_id    VARCHAR(36)  NOT NULL DEFAULT '',
+    action      VARCHAR(64)  NOT NULL,
+    target_type VARCHAR(32)  NOT NULL DEFAULT '',
+    target_id   VARCHAR(255) NOT NULL DEFAULT '',
+    outcome     VARCHAR(16)  NOT NULL,
+    error       TEXT         NOT NULL DEFAULT '',
+    source_ip   VARCHAR(45)  NOT NULL DEFAULT '',
+    user_agent  TEXT         NOT NULL DEFAULT '',
+    timestamp   TIMESTAMPTZ  NOT NULL,
+    prev_hash   CHAR(64)     NOT NULL,
+    hash        CHAR(64)     NOT NULL,
+    PRIMARY KEY (sequence)
+);
+
+CREATE INDEX idx_audit_entries_actor_id ON audit_entries (actor_id, sequence);
+CREATE INDEX idx_audit_entries_target_id ON audit_entries (target_id);
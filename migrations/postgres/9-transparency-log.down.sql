@@ -0,0 +1,3 @@
+DROP TABLE transparency_log_entries;
+
+DROP TABLE transparency_logs;
@@ -0,0 +1,22 @@
+CREATE TABLE transparency_logs (
+    ca_id              VARCHAR(36) NOT NULL,
+    tree_size          BIGINT      NOT NULL DEFAULT 0,
+    public_key         BYTEA       NULL,
+    sealed_private_key BYTEA       NULL,
+    created            TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (ca_id)
+);
+
+CREATE TABLE transparency_log_entries (
+    ca_id          VARCHAR(36) NOT NULL,
+    leaf_index     BIGINT      NOT NULL,
+    certificate_id VARCHAR(36) NOT NULL,
+    leaf_hash      BYTEA       NOT NULL,
+    leaf_input     BYTEA       NOT NULL,
+    PRIMARY KEY (ca_id, leaf_index)
+);
+
+CREATE INDEX idx_transparency_log_entries_leaf_hash
+    ON transparency_log_entries (ca_id, leaf_hash);
+CREATE INDEX idx_transparency_log_entries_certificate_id
+    ON transparency_log_entries (ca_id, certificate_id);
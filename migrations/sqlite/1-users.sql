@@ -0,0 +1,20 @@
+CREATE TABLE users (
+    id         CHAR(36)     NOT NULL,
+    first_name VARCHAR(255) NOT NULL DEFAULT '',
+    last_name  VARCHAR(255) NOT NULL DEFAULT '',
+    email      VARCHAR(255) NOT NULL,
+    password   VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+CREATE TABLE sessions (
+    id         CHAR(36) NOT NULL,
+    created    DATETIME NOT NULL,
+    expiration DATETIME NOT NULL,
+    user_id    CHAR(36) NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_sessions_expiration ON sessions (expiration);
@@ -0,0 +1,3 @@
+DROP TABLE ssh_certificates;
+
+DROP TABLE ssh_certificate_authorities;
@@ -0,0 +1,3 @@
+DROP TABLE timestamps;
+
+DROP TABLE timestamp_authorities;
@@ -0,0 +1,2 @@
+ALTER TABLE certificates ADD COLUMN sealed_key_password BLOB NULL;
+ALTER TABLE certificates ADD COLUMN refresh_error TEXT NULL;
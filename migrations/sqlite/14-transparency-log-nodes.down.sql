@@ -0,0 +1,6 @@
+DROP TABLE transparency_log_nodes;
+
+ALTER TABLE transparency_logs DROP COLUMN tree_head_signature;
+ALTER TABLE transparency_logs DROP COLUMN root_hash;
+ALTER TABLE transparency_logs DROP COLUMN tree_head_timestamp;
+ALTER TABLE transparency_logs DROP COLUMN tree_head_size;
@@ -0,0 +1,12 @@
+ALTER TABLE transparency_logs ADD COLUMN tree_head_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transparency_logs ADD COLUMN tree_head_timestamp INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transparency_logs ADD COLUMN root_hash BLOB NULL;
+ALTER TABLE transparency_logs ADD COLUMN tree_head_signature BLOB NULL;
+
+CREATE TABLE transparency_log_nodes (
+    ca_id      CHAR(36) NOT NULL,
+    level      INTEGER  NOT NULL,
+    node_index INTEGER  NOT NULL,
+    hash       BLOB     NOT NULL,
+    PRIMARY KEY (ca_id, level, node_index)
+);
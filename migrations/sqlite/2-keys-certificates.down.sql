@@ -0,0 +1,5 @@
+DROP TABLE crls;
+
+DROP TABLE certificates;
+
+DROP TABLE keys;
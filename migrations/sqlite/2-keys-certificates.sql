@@ -0,0 +1,45 @@
+CREATE TABLE keys (
+    id        CHAR(36)     NOT NULL,
+    user_id   CHAR(36)     NOT NULL,
+    name      VARCHAR(255) NOT NULL DEFAULT '',
+    data      BLOB         NOT NULL,
+    algorithm VARCHAR(16)  NOT NULL,
+    key_size  INTEGER      NOT NULL DEFAULT 0,
+    curve     VARCHAR(16)  NOT NULL DEFAULT '',
+    created   DATETIME     NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_keys_user_id ON keys (user_id);
+
+CREATE TABLE certificates (
+    id                 CHAR(36)     NOT NULL,
+    user_id            CHAR(36)     NOT NULL,
+    name               VARCHAR(255) NOT NULL DEFAULT '',
+    data               BLOB         NOT NULL,
+    type               VARCHAR(32)  NOT NULL,
+    created            DATETIME     NOT NULL,
+    parent_certificate VARCHAR(36)  NOT NULL DEFAULT '',
+    serial_number      VARCHAR(64)  NULL,
+    key_id             CHAR(36)     NULL,
+    revoked_at         DATETIME     NULL,
+    revocation_reason  INTEGER      NOT NULL DEFAULT 0,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_cert_issuer_serial ON certificates (parent_certificate, serial_number);
+
+CREATE INDEX idx_certificates_user_id_type ON certificates (user_id, type);
+
+CREATE TABLE crls (
+    id             CHAR(36) NOT NULL,
+    certificate_id CHAR(36) NOT NULL,
+    number         INTEGER  NOT NULL,
+    data           BLOB     NOT NULL,
+    this_update    DATETIME NOT NULL,
+    next_update    DATETIME NOT NULL,
+    created        DATETIME NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_crl_number ON crls (certificate_id, number);
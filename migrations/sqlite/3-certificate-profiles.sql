@@ -0,0 +1,22 @@
+CREATE TABLE certificate_profiles (
+    id                     CHAR(36)     NOT NULL,
+    user_id                CHAR(36)     NOT NULL,
+    name                   VARCHAR(255) NOT NULL,
+    key_usages             TEXT         NULL,
+    ext_key_usages         TEXT         NULL,
+    max_validity_days      INTEGER      NOT NULL DEFAULT 0,
+    allowed_key_algorithms TEXT         NULL,
+    is_ca                  BOOLEAN      NOT NULL DEFAULT FALSE,
+    max_path_len           INTEGER      NOT NULL DEFAULT 0,
+    organization           VARCHAR(255) NOT NULL DEFAULT '',
+    organizational_unit    VARCHAR(255) NOT NULL DEFAULT '',
+    country                VARCHAR(255) NOT NULL DEFAULT '',
+    province               VARCHAR(255) NOT NULL DEFAULT '',
+    locality               VARCHAR(255) NOT NULL DEFAULT '',
+    street_address         VARCHAR(255) NOT NULL DEFAULT '',
+    postal_code            VARCHAR(255) NOT NULL DEFAULT '',
+    created                DATETIME     NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_certificate_profiles_user_id ON certificate_profiles (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX idx_certificates_replaces;
+
+ALTER TABLE certificates DROP COLUMN replaces;
@@ -0,0 +1,27 @@
+CREATE TABLE auto_renew_policies (
+    certificate_id         CHAR(36)    NOT NULL,
+    user_id                CHAR(36)    NOT NULL,
+    threshold_percent      INTEGER     NOT NULL DEFAULT 0,
+    threshold_days         INTEGER     NOT NULL DEFAULT 0,
+    sealed_ca_key_password BLOB        NULL,
+    sealed_key_password    BLOB        NULL,
+    revoke_predecessor     BOOLEAN     NOT NULL DEFAULT FALSE,
+    renew_at               DATETIME    NOT NULL,
+    next_attempt           DATETIME    NOT NULL,
+    status                 VARCHAR(16) NOT NULL,
+    failures               INTEGER     NOT NULL DEFAULT 0,
+    last_attempt           DATETIME    NULL,
+    last_error             TEXT        NULL,
+    renewed_certificate_id CHAR(36)    NULL,
+    created                DATETIME    NOT NULL,
+    PRIMARY KEY (certificate_id)
+);
+
+CREATE INDEX idx_auto_renew_policies_due ON auto_renew_policies (status, next_attempt);
+
+CREATE TABLE leases (
+    name    VARCHAR(64)  NOT NULL,
+    holder  VARCHAR(255) NOT NULL,
+    expires DATETIME     NOT NULL,
+    PRIMARY KEY (name)
+);
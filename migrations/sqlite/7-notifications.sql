@@ -0,0 +1,33 @@
+CREATE TABLE notification_rules (
+    id            CHAR(36)     NOT NULL,
+    user_id       CHAR(36)     NOT NULL,
+    name          VARCHAR(255) NOT NULL,
+    ca_id         VARCHAR(36)  NOT NULL DEFAULT '',
+    days_before   TEXT         NOT NULL,
+    channel       VARCHAR(16)  NOT NULL,
+    target        TEXT         NOT NULL,
+    sealed_secret BLOB         NULL,
+    created       DATETIME     NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX idx_notification_rules_user_id ON notification_rules (user_id);
+
+CREATE TABLE notification_deliveries (
+    id             CHAR(36)    NOT NULL,
+    rule_id        CHAR(36)    NOT NULL,
+    certificate_id CHAR(36)    NOT NULL,
+    days_before    INTEGER     NOT NULL,
+    not_after      DATETIME    NOT NULL,
+    status         VARCHAR(16) NOT NULL,
+    attempts       INTEGER     NOT NULL DEFAULT 0,
+    next_attempt   DATETIME    NOT NULL,
+    last_attempt   DATETIME    NULL,
+    last_error     TEXT        NULL,
+    created        DATETIME    NOT NULL,
+    PRIMARY KEY (id)
+);
+
+CREATE UNIQUE INDEX idx_notification_deliveries_stage
+    ON notification_deliveries (rule_id, certificate_id, days_before);
+CREATE INDEX idx_notification_deliveries_due ON notification_deliveries (status, next_attempt);
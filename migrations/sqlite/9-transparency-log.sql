@@ -0,0 +1,22 @@
+CREATE TABLE transparency_logs (
+    ca_id              CHAR(36) NOT NULL,
+    tree_size          INTEGER  NOT NULL DEFAULT 0,
+    public_key         BLOB     NULL,
+    sealed_private_key BLOB     NULL,
+    created            DATETIME NOT NULL,
+    PRIMARY KEY (ca_id)
+);
+
+CREATE TABLE transparency_log_entries (
+    ca_id          CHAR(36) NOT NULL,
+    leaf_index     INTEGER  NOT NULL,
+    certificate_id CHAR(36) NOT NULL,
+    leaf_hash      BLOB     NOT NULL,
+    leaf_input     BLOB     NOT NULL,
+    PRIMARY KEY (ca_id, leaf_index)
+);
+
+CREATE INDEX idx_transparency_log_entries_leaf_hash
+    ON transparency_log_entries (ca_id, leaf_hash);
+CREATE INDEX idx_transparency_log_entries_certificate_id
+    ON transparency_log_entries (ca_id, certificate_id);